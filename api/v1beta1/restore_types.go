@@ -54,6 +54,105 @@ const (
 	CleanupTypeNone = "None"
 )
 
+// RestoreWaitCondition defines the status condition the restored resources
+// of a kind must have before the restore is completed
+type RestoreWaitCondition struct {
+	// Kind of the restored resources, using the kind.group format,
+	// for example managedcluster.cluster.open-cluster-management.io
+	// +kubebuilder:validation:Required
+	Kind string `json:"kind"`
+	// ConditionType is the type of the status condition to wait for,
+	// for example ManagedClusterConditionAvailable
+	// +kubebuilder:validation:Required
+	ConditionType string `json:"conditionType"`
+	// ConditionStatus is the expected status of the condition.
+	// If not defined, the value is set to True.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=True;False;Unknown
+	ConditionStatus metav1.ConditionStatus `json:"conditionStatus,omitempty"`
+}
+
+// HubReadinessCheck is a hub health indicator checked once the Velero restores are completed
+// +kubebuilder:validation:Enum=MultiClusterHub;ManagedClusters;Policies
+type HubReadinessCheck string
+
+const (
+	// HubReadinessCheckMultiClusterHub checks the MultiClusterHub resource is in the Running phase
+	HubReadinessCheckMultiClusterHub HubReadinessCheck = "MultiClusterHub"
+	// HubReadinessCheckManagedClusters checks the managed clusters restored by this restore
+	// are connected to the hub and available
+	HubReadinessCheckManagedClusters HubReadinessCheck = "ManagedClusters"
+	// HubReadinessCheckPolicies checks the root policies are reconciled and report a compliance status
+	HubReadinessCheckPolicies HubReadinessCheck = "Policies"
+)
+
+// HubReadinessCheckStatus shows the result of a hub readiness check
+type HubReadinessCheckStatus struct {
+	// Check is the hub readiness check
+	Check HubReadinessCheck `json:"check"`
+	// Ready is true if the check passed
+	Ready bool `json:"ready"`
+	// Message shows what the check is waiting for
+	// +kubebuilder:validation:Optional
+	Message string `json:"message,omitempty"`
+}
+
+// PlannedVeleroRestore shows a Velero restore which would be created by a dry run restore,
+// or which is created once the WaitConditions are met
+type PlannedVeleroRestore struct {
+	// Type is the backup type restored by the Velero restore
+	Type string `json:"type"`
+	// Name is the name of the Velero restore
+	Name string `json:"name"`
+	// BackupName is the name of the Velero backup restored
+	BackupName string `json:"backupName"`
+	// LabelSelector shows the label selector used to restore the backup resources
+	// +kubebuilder:validation:Optional
+	LabelSelector string `json:"labelSelector,omitempty"`
+}
+
+// BackupTypeRestore shows the outcome of the Velero restore created for a backup type
+type BackupTypeRestore struct {
+	// Type is the backup type restored by the Velero restore
+	Type string `json:"type"`
+	// Name is the name of the Velero restore
+	Name string `json:"name"`
+	// Phase is the phase of the Velero restore
+	// +kubebuilder:validation:Optional
+	Phase string `json:"phase,omitempty"`
+	// ItemsRestored is the number of items restored by the Velero restore, as reported by Velero
+	// +kubebuilder:validation:Optional
+	ItemsRestored int `json:"itemsRestored,omitempty"`
+	// Warnings is the number of warnings of the Velero restore, set when the Velero restore is finished
+	// +kubebuilder:validation:Optional
+	Warnings int `json:"warnings,omitempty"`
+	// Errors is the number of errors of the Velero restore, set when the Velero restore is finished
+	// +kubebuilder:validation:Optional
+	Errors int `json:"errors,omitempty"`
+}
+
+// RestoredItemsTotal is the total of the items restored, warnings and errors of the Velero restores
+type RestoredItemsTotal struct {
+	// ItemsRestored is the number of items restored by all Velero restores
+	ItemsRestored int `json:"itemsRestored"`
+	// Warnings is the number of warnings of all Velero restores
+	Warnings int `json:"warnings"`
+	// Errors is the number of errors of all Velero restores
+	Errors int `json:"errors"`
+}
+
+// RestoreFinalizerRemoval defines the finalizers removed from the restored resources of a kind
+type RestoreFinalizerRemoval struct {
+	// Kind of the restored resources, using the kind.group format,
+	// for example managedcluster.cluster.open-cluster-management.io
+	// +kubebuilder:validation:Required
+	Kind string `json:"kind"`
+	// Finalizers removed from the restored resources of this kind
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinItems=1
+	Finalizers []string `json:"finalizers"`
+}
+
 // RestoreSpec defines the desired state of Restore
 type RestoreSpec struct {
 	// VeleroManagedClustersBackupName is the name of the velero back-up used to restore managed clusters.
@@ -96,6 +195,151 @@ type RestoreSpec struct {
 	// When SyncRestoreWithNewBackups is set to true, defines the duration for checking on new backups
 	// If not defined and SyncRestoreWithNewBackups is set to true, it defaults to 30minutes
 	RestoreSyncInterval metav1.Duration `json:"restoreSyncInterval,omitempty"`
+	// +kubebuilder:validation:Optional
+	// RestoreLabelSelector is applied to all Velero restores created by this resource,
+	// for all backup types, so only resources matching the selector are restored.
+	// If not defined, all resources from the selected backups are restored.
+	RestoreLabelSelector *metav1.LabelSelector `json:"restoreLabelSelector,omitempty"`
+	// +kubebuilder:validation:Optional
+	// SourceScheduleName is the name of the BackupSchedule resource that created the backups to restore.
+	// Use it when more than one hub is writing backups to the same storage location;
+	// only backups created by this schedule are used when looking for the latest backup
+	// or for the backup names set by this restore.
+	// If not defined, backups created by any schedule are used.
+	SourceScheduleName string `json:"sourceScheduleName,omitempty"`
+	// +kubebuilder:validation:Optional
+	// SourceHubID is the cluster ID of the hub that created the backups to restore.
+	// Use it when more than one hub is writing backups to the same storage location;
+	// only backups created on this hub are used when looking for the latest backup
+	// or for the backup names set by this restore.
+	// If not defined, backups created on any hub are used.
+	SourceHubID string `json:"sourceHubID,omitempty"`
+	// +kubebuilder:validation:Optional
+	// WaitConditions defines, per kind, the status condition the restored resources must have
+	// before the next Velero restore is created and before the restore is completed.
+	// The Velero restores are created one at a time, in the restore order, each one once
+	// all resources of these kinds restored so far have the condition set; the restore stays
+	// in the Running phase while waiting. A restore syncing with new backups only waits
+	// for the conditions before it is completed.
+	WaitConditions []RestoreWaitCondition `json:"waitConditions,omitempty"`
+	// +kubebuilder:validation:Optional
+	// WaitTimeout is the maximum time to wait for the WaitConditions after each Velero restore
+	// is completed. The restore is set to FinishedWithErrors when the timeout is reached,
+	// without creating the next Velero restores.
+	// If not defined, it defaults to 10 minutes
+	WaitTimeout metav1.Duration `json:"waitTimeout,omitempty"`
+	// +kubebuilder:validation:Optional
+	// StorageLocation is the name of the velero.io.BackupStorageLocation storing the backups to restore.
+	// Use it when more than one hub is writing backups to the same bucket, using different prefixes;
+	// only backups synced from this storage location are restored.
+	// If not defined, backups from any storage location are used.
+	StorageLocation string `json:"storageLocation,omitempty"`
+	// +kubebuilder:validation:Optional
+	// StorageLocationPrefix is the object store prefix of the velero.io.BackupStorageLocation
+	// storing the backups to restore. The storage location using this prefix is resolved
+	// when the restore starts; it must match the StorageLocation, if set.
+	StorageLocationPrefix string `json:"storageLocationPrefix,omitempty"`
+	// +kubebuilder:validation:Optional
+	// RemoveFinalizers defines, per kind, the finalizers removed from the restored resources
+	// once the Velero restores are completed. Use it for finalizers handled by controllers
+	// not running on this hub, which would block the deletion of the restored resources.
+	RemoveFinalizers []RestoreFinalizerRemoval `json:"removeFinalizers,omitempty"`
+	// +kubebuilder:validation:Optional
+	// HubReadinessChecks defines the hub health indicators checked once the Velero restores
+	// are completed. The restore stays in the Running phase until all checks pass.
+	// The checks are not used when the restore syncs with new backups.
+	HubReadinessChecks []HubReadinessCheck `json:"hubReadinessChecks,omitempty"`
+	// +kubebuilder:validation:Optional
+	// HubReadinessTimeout is the maximum time to wait for the HubReadinessChecks after the
+	// Velero restores are completed. The restore is set to FinishedWithErrors when the timeout is reached.
+	// If not defined, it defaults to 30 minutes
+	HubReadinessTimeout metav1.Duration `json:"hubReadinessTimeout,omitempty"`
+	// +kubebuilder:validation:Optional
+	// DryRun selects the backups to restore and shows the Velero restores in the PlannedRestores status,
+	// without creating the Velero restores or cleaning up any resources.
+	// If not defined, the value is set to false.
+	DryRun bool `json:"dryRun,omitempty"`
+	// +kubebuilder:validation:Optional
+	// IncludedBackupTypes restores only the backups of these types; Velero restores
+	// are not created for the other backup types. Valid values are credentials, credentialsHive,
+	// credentialsCluster, resources, resourcesGeneric, namespaceContents and managedClusters.
+	// If not defined, all backup types are restored, except the ones set to skip.
+	IncludedBackupTypes []string `json:"includedBackupTypes,omitempty"`
+	// +kubebuilder:validation:Optional
+	// RestoreToTime restores, for the backup types set to latest, the most recent Completed backup
+	// created at or before this time. The backup time is the timestamp in the backup name.
+	// If not defined, the latest backup is restored.
+	RestoreToTime *metav1.Time `json:"restoreToTime,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=0
+	// MaxRestoreAttempts is the maximum number of times a restore in Error phase is retried;
+	// the retries are delayed with an exponential backoff. Once the attempts are used
+	// the restore is not retried and the Failed condition reason is RestoreAttemptsExhausted.
+	// If not defined, or set to 0, the restore is retried until it succeeds.
+	MaxRestoreAttempts int `json:"maxRestoreAttempts,omitempty"`
+	// +kubebuilder:validation:Optional
+	// ActivateManagedClusters activates the restored managed clusters on this hub, once the
+	// managed clusters Velero restore is completed; the restored ManagedCluster resources are
+	// accepted by this hub. The Activated condition shows the activation result.
+	// If not defined, the value is set to false.
+	ActivateManagedClusters bool `json:"activateManagedClusters,omitempty"`
+	// +kubebuilder:validation:Optional
+	// NamespaceMapping maps the namespaces of the backed up resources to the namespaces
+	// they are restored into; it is set on all Velero restores created by this resource.
+	// Both the keys and the values must be valid namespace names.
+	// If not defined, the resources are restored into their original namespaces.
+	NamespaceMapping map[string]string `json:"namespaceMapping,omitempty"`
+	// +kubebuilder:validation:Optional
+	// AllowSelfRestore allows restoring backups created on this hub. Restoring the backups
+	// of the hub the restore runs on is blocked, since it overwrites the live hub state;
+	// the SameHubRestore condition shows the backups created on this hub.
+	// If not defined, the value is set to false.
+	AllowSelfRestore bool `json:"allowSelfRestore,omitempty"`
+	// +kubebuilder:validation:Optional
+	// WaitForBackupsTimeout is the maximum time to wait for the backups to be synced from the
+	// storage location, when no backup is found for a backup type set to latest. The restore
+	// stays in the Started phase with the WaitingForBackups condition set and looks for the
+	// backups again, with an increasing interval. The timeout starts when the restore is created.
+	// If not defined, the restore is set to the Error phase when no backup is found.
+	WaitForBackupsTimeout metav1.Duration `json:"waitForBackupsTimeout,omitempty"`
+	// +kubebuilder:validation:Optional
+	// IncludeClusterResources is set on all Velero restores created by this resource; set it to
+	// false to restore only the namespaced resources, for example to keep the CRDs and other
+	// cluster-scoped resources already on this hub. The cluster-scoped resources not restored
+	// are shown by the ExcludedClusterResources status.
+	// If not defined, Velero restores the cluster-scoped resources.
+	IncludeClusterResources *bool `json:"includeClusterResources,omitempty"`
+	// +kubebuilder:validation:Optional
+	// IncludedClusterRBACResources lists the cluster-scoped RBAC kinds, in the kind.group format,
+	// restored from the generic resources backup, for example
+	// clusterrolebinding.rbac.authorization.k8s.io. The generic resources backup backs up the
+	// resources with the cluster.open-cluster-management.io/backup label, so only the RBAC
+	// resources tied to ACM are restored. Only the clusterrole and clusterrolebinding kinds
+	// of the rbac.authorization.k8s.io group are accepted; the kinds not listed are excluded
+	// from the Velero restores of the generic resources backup.
+	// If not defined, all the generic resources backup resources are restored.
+	IncludedClusterRBACResources []string `json:"includedClusterRBACResources,omitempty"`
+	// +kubebuilder:validation:Optional
+	// VeleroNamespace is the namespace of the Velero install used by this restore, when more than
+	// one Velero is installed on the hub. Only the storage locations in this namespace are used and
+	// the restore must be created in this namespace.
+	// If not specified, the Velero namespace is found using the available storage locations.
+	VeleroNamespace string `json:"veleroNamespace,omitempty"`
+	// +kubebuilder:validation:Optional
+	// RestoreContinueOnError lets the restore continue with the other backup types when the
+	// Velero restore of a backup type fails. The restore is set to FinishedWithErrors when
+	// some of the Velero restores fail and to Error only when all of them fail;
+	// the BackupTypeRestores status shows the outcome of each backup type.
+	// If not defined, the value is set to false and the restore is set to Error
+	// as soon as a Velero restore fails.
+	RestoreContinueOnError bool `json:"restoreContinueOnError,omitempty"`
+	// +kubebuilder:validation:Optional
+	// CleanupRestoresOnDelete deletes the Velero restores not finished yet when this restore is
+	// deleted, which stops reporting their progress; Velero doesn't cancel a Velero restore in
+	// progress, it keeps restoring the resources. The Velero restores created by this restore
+	// are deleted before the restore is removed; if not defined, the value is set to false and
+	// the restore is removed only once its Velero restores are finished.
+	CleanupRestoresOnDelete bool `json:"cleanupRestoresOnDelete,omitempty"`
 }
 
 // RestoreStatus defines the observed state of Restore
@@ -106,12 +350,75 @@ type RestoreStatus struct {
 	VeleroResourcesRestoreName string `json:"veleroResourcesRestoreName,omitempty"`
 	// +kubebuilder:validation:Optional
 	VeleroCredentialsRestoreName string `json:"veleroCredentialsRestoreName,omitempty"`
+	// StorageLocation is the velero.io.BackupStorageLocation storing the restored backups,
+	// set when the StorageLocation or StorageLocationPrefix is defined for this restore
+	// +kubebuilder:validation:Optional
+	StorageLocation string `json:"storageLocation,omitempty"`
 	// Phase is the current phase of the restore
 	// +kubebuilder:validation:Optional
 	Phase RestorePhase `json:"phase"`
 	// Message on the last operation
 	// +kubebuilder:validation:Optional
 	LastMessage string `json:"lastMessage"`
+	// StorageLocationProbe shows the result of the last storage location connectivity probe,
+	// set when the operator runs with a storage location probe timeout
+	// +kubebuilder:validation:Optional
+	StorageLocationProbe string `json:"storageLocationProbe,omitempty"`
+	// RemovedFinalizers shows the finalizers removed from the restored resources
+	// and the number of resources updated for each finalizer
+	// +kubebuilder:validation:Optional
+	RemovedFinalizers []string `json:"removedFinalizers,omitempty"`
+	// HubReadiness shows the result of each hub readiness check
+	// +kubebuilder:validation:Optional
+	HubReadiness []HubReadinessCheckStatus `json:"hubReadiness,omitempty"`
+	// RestoredClusterFleet shows the cluster sets and claims restored with the managed clusters,
+	// set once the managed clusters restore is completed and reported by an event
+	// +kubebuilder:validation:Optional
+	RestoredClusterFleet string `json:"restoredClusterFleet,omitempty"`
+	// VeleroPlacementDecisionsRestoreName is the Velero restore of the placement decisions,
+	// created from the managed clusters backup once the managed clusters restore is completed
+	// +kubebuilder:validation:Optional
+	VeleroPlacementDecisionsRestoreName string `json:"veleroPlacementDecisionsRestoreName,omitempty"`
+	// PlacementRestoreOrder shows the Velero restores used for the placement kinds available
+	// on the hub, in the order they are restored
+	// +kubebuilder:validation:Optional
+	PlacementRestoreOrder string `json:"placementRestoreOrder,omitempty"`
+	// PlannedRestores shows the Velero restores which would be created, set for a DryRun restore,
+	// or the Velero restores created one at a time, set for a restore with WaitConditions
+	// +kubebuilder:validation:Optional
+	PlannedRestores []PlannedVeleroRestore `json:"plannedRestores,omitempty"`
+	// SkippedBackupTypes shows the backup types not restored, because they are not
+	// in the IncludedBackupTypes or their backup name is set to skip
+	// +kubebuilder:validation:Optional
+	SkippedBackupTypes []string `json:"skippedBackupTypes,omitempty"`
+	// LatestBackupSets shows the most recent complete backup sets which can be restored,
+	// set when the restore doesn't select the backups by name or by time
+	// +kubebuilder:validation:Optional
+	LatestBackupSets []RestorableBackupSet `json:"latestBackupSets,omitempty"`
+	// ExcludedClusterResources shows the cluster-scoped resources backed up by the restored
+	// backups which are not restored, set when IncludeClusterResources is false
+	// +kubebuilder:validation:Optional
+	ExcludedClusterResources []string `json:"excludedClusterResources,omitempty"`
+	// BackupTypeRestores shows the Velero restore created for each backup type, its phase
+	// and the number of items restored, warnings and errors
+	// +kubebuilder:validation:Optional
+	BackupTypeRestores []BackupTypeRestore `json:"backupTypeRestores,omitempty"`
+	// RestoredItemsTotal is the total of the items restored, warnings and errors
+	// of the Velero restores shown by the BackupTypeRestores
+	// +kubebuilder:validation:Optional
+	RestoredItemsTotal *RestoredItemsTotal `json:"restoredItemsTotal,omitempty"`
+	// RestoreAttempts is the number of times the restore ended in Error phase
+	// +kubebuilder:validation:Optional
+	RestoreAttempts int `json:"restoreAttempts,omitempty"`
+	// NextRestoreAttempt is the time of the next attempt of a restore in Error phase;
+	// the restore is not processed again before this time
+	// +kubebuilder:validation:Optional
+	NextRestoreAttempt *metav1.Time `json:"nextRestoreAttempt,omitempty"`
+	// Conditions show the restore state using the Complete and Failed condition types
+	// +kubebuilder:validation:Optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -132,16 +439,58 @@ type Restore struct {
 
 // Restore condition type
 const (
-	// RestoreComplete means the restore runs to completion
+	// RestoreComplete means the restore runs to completion; not set for a DryRun restore
 	RestoreComplete = "Complete"
+	// RestoreFailed means the restore failed or finished with errors
+	RestoreFailed = "Failed"
+	// RestoreDryRunComplete means the Velero restores for a DryRun restore are computed
+	RestoreDryRunComplete = "DryRunComplete"
+	// RestoreBackupInvalid means a backup selected for the restore can't be restored
+	RestoreBackupInvalid = "BackupInvalid"
+	// RestoreActivated means the restored managed clusters are activated on this hub
+	RestoreActivated = "Activated"
+	// RestoreSameHubRestore means a backup selected for the restore was created on this hub
+	RestoreSameHubRestore = "SameHubRestore"
+	// RestoreWaitingForBackups means the restore waits for the backups to be synced from the storage location
+	RestoreWaitingForBackups = "WaitingForBackups"
+	// RestoreVeleroNotInstalled means the Velero CRDs are not installed on the hub,
+	// so no Velero restore can be created
+	RestoreVeleroNotInstalled = "VeleroNotInstalled"
+	// RestoreNoMatchingBackups means no backup in the storage location matches the restore,
+	// the condition is Unknown while the storage location is not synced yet
+	RestoreNoMatchingBackups = "NoMatchingBackups"
 )
 
 // Valid Restore Reason
 const (
-	RestoreReasonNotStarted = "RestoreNotStarted"
-	RestoreReasonStarted    = "RestoreStarted"
-	RestoreReasonRunning    = "RestoreRunning"
-	RestoreReasonFinished   = "RestoreFinished"
+	RestoreReasonNotStarted             = "RestoreNotStarted"
+	RestoreReasonStarted                = "RestoreStarted"
+	RestoreReasonRunning                = "RestoreRunning"
+	RestoreReasonFinished               = "RestoreFinished"
+	RestoreReasonFinishedWithErrors     = "RestoreFinishedWithErrors"
+	RestoreReasonError                  = "RestoreError"
+	RestoreReasonUnknown                = "RestoreUnknown"
+	RestoreReasonSyncEnabled            = "RestoreSyncEnabled"
+	RestoreReasonDryRunComplete         = "RestoreDryRunComplete"
+	RestoreReasonDryRunFailed           = "RestoreDryRunFailed"
+	RestoreReasonBackupInvalid          = "RestoreBackupInvalid"
+	RestoreReasonBackupValid            = "RestoreBackupValid"
+	RestoreReasonAttemptsExhausted      = "RestoreAttemptsExhausted"
+	RestoreReasonActivationPending      = "ManagedClustersActivationPending"
+	RestoreReasonActivated              = "ManagedClustersActivated"
+	RestoreReasonActivationFailed       = "ManagedClustersActivationFailed"
+	RestoreReasonSameHubBlocked         = "SameHubRestoreBlocked"
+	RestoreReasonSameHubAllowed         = "SameHubRestoreAllowed"
+	RestoreReasonOtherHubBackups        = "BackupsFromOtherHub"
+	RestoreReasonBackupsNotFound        = "BackupsNotFound"
+	RestoreReasonBackupsFound           = "BackupsFound"
+	RestoreReasonWaitForBackupsTimedOut = "WaitForBackupsTimedOut"
+	RestoreReasonVeleroCRDsNotFound     = "VeleroCRDsNotFound"
+	RestoreReasonVeleroCRDsFound        = "VeleroCRDsFound"
+	RestoreReasonNoBackupsFromSource    = "NoBackupsFromSource"
+	RestoreReasonNoBackupsInLocation    = "NoBackupsInStorageLocation"
+	RestoreReasonWaitingForBackupSync   = "WaitingForBackupSync"
+	RestoreReasonMatchingBackupsFound   = "MatchingBackupsFound"
 )
 
 //+kubebuilder:object:root=true
@@ -44,6 +44,20 @@ const (
 	// another cluster pushes backups to the same storage location
 	// resulting in a backup collision
 	SchedulePhaseBackupCollision SchedulePhase = "BackupCollision"
+	// SchedulePhasePaused means the schedule is paused and doesn't trigger backups
+	SchedulePhasePaused SchedulePhase = "Paused"
+)
+
+// NamespaceBackupMode defines how the generic backup label set on a namespace is handled
+type NamespaceBackupMode string
+
+const (
+	// NamespaceBackupModeNamespaceOnly means only the labeled namespace resource is backed up;
+	// the resources in that namespace are backed up only if they are labeled themselves
+	NamespaceBackupModeNamespaceOnly NamespaceBackupMode = "NamespaceOnly"
+	// NamespaceBackupModeNamespaceContents means the labeled namespace and all the resources
+	// it contains are backed up
+	NamespaceBackupModeNamespaceContents NamespaceBackupMode = "NamespaceContents"
 )
 
 // NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
@@ -54,11 +68,242 @@ type BackupScheduleSpec struct {
 	// the Velero Backup
 	// +kubebuilder:validation:Required
 	VeleroSchedule string `json:"veleroSchedule"`
+	// VeleroScheduleOverrides sets a Cron expression for a backup type, used instead of the
+	// VeleroSchedule by the Velero schedules of this backup type. The keys are the backup types
+	// restored together, managedClusters, credentials and resources; the credentials Cron also
+	// applies to the credentialsHive and credentialsCluster backups, the resources Cron to the
+	// resourcesGeneric backups. If a backup type is not specified, its Velero schedules use the
+	// VeleroSchedule. A backup set uses the most recent backup of the overridden backup types
+	// created before its resources backup.
+	// +kubebuilder:validation:Optional
+	VeleroScheduleOverrides map[string]string `json:"veleroScheduleOverrides,omitempty"`
+	// ValidationSchedule is a Cron expression defining when to run the validation backup,
+	// a small backup of the BackupSchedule resource used to check the backups reach the storage
+	// location; the BackupChainHealthy condition is True if a validation backup completed
+	// within the validation cron interval. Set it to check the backups more often than
+	// the VeleroSchedule. If not specified, the validation backup uses the VeleroSchedule.
+	// +kubebuilder:validation:Optional
+	ValidationSchedule string `json:"validationSchedule,omitempty"`
+	// ScheduleJitter is a time.Duration-parseable string, of at most 59m, used to spread the backups
+	// of hubs sharing the same storage or monitoring backend. The minutes of the Velero schedules cron
+	// are shifted by an offset between 0 and the ScheduleJitter, computed from the hub cluster id so the
+	// offset of a hub doesn't change after a restart. Only a cron with a list of minutes or a */step
+	// minutes field is shifted. If not specified, the cron expressions are used as set.
+	// +kubebuilder:validation:Optional
+	ScheduleJitter metav1.Duration `json:"scheduleJitter,omitempty"`
 	// TTL is a time.Duration-parseable string describing how long
 	// the Velero Backup should be retained for. If not specified
 	// the maximum default value set by velero is used - 720h
 	// +kubebuilder:validation:Optional
 	VeleroTTL metav1.Duration `json:"veleroTtl,omitempty"`
+	// NamespaceBackupMode defines how a namespace labeled with
+	// cluster.open-cluster-management.io/backup is backed up.
+	// NamespaceOnly backs up just the namespace resource, NamespaceContents
+	// backs up the namespace and all the resources it contains.
+	// If not specified, NamespaceOnly is used.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=NamespaceOnly;NamespaceContents
+	NamespaceBackupMode NamespaceBackupMode `json:"namespaceBackupMode,omitempty"`
+	// AllowEmptyBackups set to true means a backup with no resources is healthy
+	// if there are no resources on the hub matching that backup type.
+	// A backup with no resources is always reported if the hub has resources matching that backup type.
+	// If not specified, a warning is shown for all backups with no resources.
+	// +kubebuilder:validation:Optional
+	AllowEmptyBackups bool `json:"allowEmptyBackups,omitempty"`
+	// BackupTransientResources set to true means the resources regenerated by their
+	// controllers, such as pods, replicasets and endpointslices, are not excluded
+	// from the generic resources backup.
+	// If not specified, these resources are excluded from the generic resources backup.
+	// +kubebuilder:validation:Optional
+	BackupTransientResources bool `json:"backupTransientResources,omitempty"`
+	// ExcludedAPIGroups is a list of API groups excluded from the generic resources backup,
+	// acm-resources-generic-schedule. A group is excluded if its name is listed or ends with
+	// a listed value, so monitoring.coreos.com also excludes the groups with that suffix.
+	// The resources of these groups are not backed up, even if they are labeled
+	// with cluster.open-cluster-management.io/backup.
+	// +kubebuilder:validation:Optional
+	ExcludedAPIGroups []string `json:"excludedAPIGroups,omitempty"`
+	// ExcludedNamespaces is a list of namespaces excluded from the resources backups,
+	// in addition to the namespaces excluded by the operator.
+	// Resources in these namespaces are not backed up, even if they are labeled
+	// with cluster.open-cluster-management.io/backup.
+	// +kubebuilder:validation:Optional
+	ExcludedNamespaces []string `json:"excludedNamespaces,omitempty"`
+	// GenericResourceLabelSelector selects the resources backed up by the generic resources
+	// backup, acm-resources-generic-schedule. When set, it replaces the default selector,
+	// which selects the resources labeled with cluster.open-cluster-management.io/backup.
+	// +kubebuilder:validation:Optional
+	GenericResourceLabelSelector *metav1.LabelSelector `json:"genericResourceLabelSelector,omitempty"`
+	// ManagedClustersLabelSelector selects the ManagedCluster resources backed up by the managed
+	// clusters backup, acm-managed-clusters-schedule, to back up only a labeled subset of the
+	// managed clusters. The namespaces of the managed clusters not selected are excluded from the
+	// managed clusters backup and these ManagedCluster resources are labeled with
+	// velero.io/exclude-from-backup. The other resources of the managed clusters backup are not
+	// filtered. If not specified, all managed clusters are backed up.
+	// +kubebuilder:validation:Optional
+	ManagedClustersLabelSelector *metav1.LabelSelector `json:"managedClustersLabelSelector,omitempty"`
+	// Paused set to true stops the backups without deleting the BackupSchedule.
+	// The Velero schedules are removed while the BackupSchedule is paused and the existing
+	// backups are kept. When set back to false, the Velero schedules are created again
+	// and the next backup runs when the veleroSchedule cron expression fires.
+	// +kubebuilder:validation:Optional
+	Paused bool `json:"paused,omitempty"`
+	// SnapshotVolumes sets the snapshotVolumes property of the Velero backups created by this schedule.
+	// If not specified, the credentials and resources backups don't snapshot volumes,
+	// since these backups are resource-only, and the other backups use the Velero default.
+	// +kubebuilder:validation:Optional
+	SnapshotVolumes *bool `json:"snapshotVolumes,omitempty"`
+	// CollisionPreventionWindow is a time.Duration-parseable string. Before creating the Velero schedules,
+	// the operator looks for backups created by another hub within this window, and not restored
+	// on this hub; if such a backup exists, the backups are not scheduled and the BackupSchedule
+	// is set to a BackupCollision phase. If not specified, this check is not done.
+	// +kubebuilder:validation:Optional
+	CollisionPreventionWindow metav1.Duration `json:"collisionPreventionWindow,omitempty"`
+	// BackupTimeout is a time.Duration-parseable string. A Velero backup created by this schedule
+	// and InProgress for longer than this timeout is reported with a Warning event and
+	// the BackupTimedOut condition. If not specified, the backups are not checked.
+	// +kubebuilder:validation:Optional
+	BackupTimeout metav1.Duration `json:"backupTimeout,omitempty"`
+	// FailureThreshold is the number of consecutive failed, partially failed or failed validation
+	// backups of a backup type after which the Degraded condition is set to True, so a single
+	// transient failure doesn't flip the schedule state. The count is reset by a completed backup.
+	// If not specified, or set to 0, the Degraded condition is not set.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=0
+	FailureThreshold int `json:"failureThreshold,omitempty"`
+	// Hooks are the Velero backup hooks set on the resources backup, acm-resources-schedule,
+	// for example to quiesce a database before the backup runs. Velero runs the exec commands
+	// in the pods backed up by that backup and matching the hook namespaces, resources and label selector.
+	// If not specified, no hooks are run.
+	// +kubebuilder:validation:Optional
+	Hooks veleroapi.BackupHooks `json:"hooks,omitempty"`
+	// VeleroNamespace is the namespace of the Velero install used by this schedule, when more than
+	// one Velero is installed on the hub. Only the storage locations in this namespace are used and
+	// the BackupSchedule must be created in this namespace.
+	// If not specified, the Velero namespace is found using the available storage locations.
+	// +kubebuilder:validation:Optional
+	VeleroNamespace string `json:"veleroNamespace,omitempty"`
+	// MaxBackups is the number of complete backup sets kept in the storage location, in addition
+	// to the Velero TTL. The backups of the sets older than the newest MaxBackups complete sets are
+	// deleted using velero.io.DeleteBackupRequest resources.
+	// If not specified, or set to 0, the backups are deleted only when they expire.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=0
+	MaxBackups int `json:"maxBackups,omitempty"`
+	// BackupNow, when set to true, creates right away a Velero backup for each backup type, out of the
+	// cron schedule, using the backup templates of the Velero schedules. The property is reset to false
+	// once the backups are created; the backups are shown by the BackupNowBackups status.
+	// +kubebuilder:validation:Optional
+	BackupNow bool `json:"backupNow,omitempty"`
+	// BackupLabels are added to the labels of the Velero schedules created by this BackupSchedule
+	// and of the Velero backups they create, for example for cost allocation. The labels set by
+	// the operator and the labels using the cluster.open-cluster-management.io or velero.io prefixes
+	// are not overwritten.
+	// If not specified, only the labels set by the operator are used.
+	// +kubebuilder:validation:Optional
+	BackupLabels map[string]string `json:"backupLabels,omitempty"`
+	// BackupAnnotations are added to the annotations of the Velero schedules created by this
+	// BackupSchedule and of the Velero backups they create. The annotations set by the operator and
+	// the annotations using the cluster.open-cluster-management.io or velero.io prefixes
+	// are not overwritten.
+	// If not specified, only the annotations set by the operator are used.
+	// +kubebuilder:validation:Optional
+	BackupAnnotations map[string]string `json:"backupAnnotations,omitempty"`
+	// SkipUnchangedBackups, when set to true, skips a scheduled backup if the hub resources backed up
+	// by the Velero schedules didn't change since the last scheduled backup. The resources are compared
+	// using a fingerprint, the resources count and a hash of their resourceVersions, taken before the
+	// backup is due. The validation backup, the backups created with BackupNow and the backups of the
+	// backup types set in VeleroScheduleOverrides are never skipped. A backup is not skipped if the
+	// last backup set created by the hub is older than half of the VeleroTTL.
+	// +kubebuilder:validation:Optional
+	SkipUnchangedBackups bool `json:"skipUnchangedBackups,omitempty"`
+}
+
+// RestorableBackupSet is a set of completed backups, one for each backup type,
+// created by the same hub and which can be restored together; the backup types scheduled
+// using VeleroScheduleOverrides use their most recent backup created before the set
+type RestorableBackupSet struct {
+	// Name of the resources backup identifying this backup set
+	Name string `json:"name"`
+	// Timestamp of the backup set, from the resources backup name
+	Timestamp metav1.Time `json:"timestamp"`
+	// HubID is the id of the hub which created the backups
+	// +kubebuilder:validation:Optional
+	HubID string `json:"hubId,omitempty"`
+	// Backups is the list of backups in this set
+	Backups []string `json:"backups"`
+}
+
+// LastSuccessfulBackup is the most recent completed backup of a backup type
+type LastSuccessfulBackup struct {
+	// BackupType is the type of the backup, as set by the cluster.open-cluster-management.io/backup-schedule-type label
+	BackupType string `json:"backupType"`
+	// LastSuccessfulTimestamp is the timestamp of the backup, from the backup name
+	LastSuccessfulTimestamp metav1.Time `json:"lastSuccessfulTimestamp"`
+	// LastBackupName is the name of the backup
+	LastBackupName string `json:"lastBackupName"`
+	// ItemsBackedUp is the number of items written to the backup, as reported by Velero
+	// +kubebuilder:validation:Optional
+	ItemsBackedUp int `json:"itemsBackedUp,omitempty"`
+	// ResourceKindsCount is the number of resource kinds backed up by the backup,
+	// read from the backup resource list stored by Velero
+	// +kubebuilder:validation:Optional
+	ResourceKindsCount int `json:"resourceKindsCount,omitempty"`
+	// ResourceKinds lists the first resource kinds backed up by the backup,
+	// in the kind.group format, sorted by group then by kind
+	// +kubebuilder:validation:Optional
+	ResourceKinds []string `json:"resourceKinds,omitempty"`
+	// ResourceKindsRequested is true when the backup resource list could not be downloaded,
+	// the resource kinds are then the kinds requested by the backup, its included resources
+	// less its excluded resources
+	// +kubebuilder:validation:Optional
+	ResourceKindsRequested bool `json:"resourceKindsRequested,omitempty"`
+}
+
+// InProgressBackup is a backup not finished yet, with the backup progress reported by Velero
+type InProgressBackup struct {
+	// BackupType is the type of the backup, as set by the cluster.open-cluster-management.io/backup-schedule-type label
+	BackupType string `json:"backupType"`
+	// BackupName is the name of the backup
+	BackupName string `json:"backupName"`
+	// ItemsBackedUp is the number of items written to the backup so far
+	// +kubebuilder:validation:Optional
+	ItemsBackedUp int `json:"itemsBackedUp,omitempty"`
+	// TotalItems is the total number of items to back up; Velero may change it while the backup runs
+	// +kubebuilder:validation:Optional
+	TotalItems int `json:"totalItems,omitempty"`
+}
+
+// StorageLocationStats shows the Velero backups stored in a storage location. Velero doesn't
+// report the size of the backups or the object store usage, so the backed up items are counted
+type StorageLocationStats struct {
+	// Name is the name of the velero.io.BackupStorageLocation
+	Name string `json:"name"`
+	// Backups is the number of Velero backups stored in the storage location, by any hub
+	Backups int `json:"backups"`
+	// ItemsBackedUp is the total number of items written to these backups, as reported by Velero
+	// +kubebuilder:validation:Optional
+	ItemsBackedUp int `json:"itemsBackedUp,omitempty"`
+}
+
+// BackupTypeFailures is the number of consecutive failed backups of a backup type
+type BackupTypeFailures struct {
+	// BackupType is the type of the backup, as set by the cluster.open-cluster-management.io/backup-schedule-type label
+	BackupType string `json:"backupType"`
+	// ConsecutiveFailures is the number of finished backups of this type not completed
+	// since the last completed backup; 0 if the last finished backup is completed
+	ConsecutiveFailures int `json:"consecutiveFailures"`
+	// LastFailedBackup is the name of the last backup of this type not completed
+	// +kubebuilder:validation:Optional
+	LastFailedBackup string `json:"lastFailedBackup,omitempty"`
+}
+
+// BackupFingerprint is the fingerprint of the hub resources backed up by a scheduled backup
+type BackupFingerprint struct {
+	// Fingerprint is the count of the backed up resources and a hash of their resourceVersions
+	Fingerprint string `json:"fingerprint"`
+	// ScheduledTime is the time the backup was scheduled by the cron expression
+	ScheduledTime metav1.Time `json:"scheduledTime"`
 }
 
 // BackupScheduleStatus defines the observed state of BackupSchedule
@@ -78,6 +323,46 @@ type BackupScheduleStatus struct {
 	// Velero Schedule for backing up credentials
 	// +kubebuilder:validation:Optional
 	VeleroScheduleCredentials *veleroapi.Schedule `json:"veleroScheduleCredentials,omitempty"`
+	// StorageLocationProbe shows the result of the last storage location connectivity probe,
+	// set when the operator runs with a storage location probe timeout
+	// +kubebuilder:validation:Optional
+	StorageLocationProbe string `json:"storageLocationProbe,omitempty"`
+	// StorageLocationStats shows, for each available storage location in the schedule namespace,
+	// the number of Velero backups and the items they contain, to spot a storage location
+	// filling up before the backups fail
+	// +kubebuilder:validation:Optional
+	StorageLocationStats []StorageLocationStats `json:"storageLocationStats,omitempty"`
+	// RestorableBackups lists the backup sets available in the storage location
+	// which can be restored, most recent first
+	// +kubebuilder:validation:Optional
+	RestorableBackups []RestorableBackupSet `json:"restorableBackups,omitempty"`
+	// LastSuccessfulBackups shows the most recent completed backup created by this
+	// schedule for each backup type
+	// +kubebuilder:validation:Optional
+	LastSuccessfulBackups []LastSuccessfulBackup `json:"lastSuccessfulBackups,omitempty"`
+	// BackupNowBackups lists the Velero backups created by the last BackupNow trigger
+	// +kubebuilder:validation:Optional
+	BackupNowBackups []string `json:"backupNowBackups,omitempty"`
+	// BackupNowTime is the time the backups of the last BackupNow trigger were created
+	// +kubebuilder:validation:Optional
+	BackupNowTime *metav1.Time `json:"backupNowTime,omitempty"`
+	// InProgressBackups shows the backups not finished yet, for each backup type, and their progress
+	// +kubebuilder:validation:Optional
+	InProgressBackups []InProgressBackup `json:"inProgressBackups,omitempty"`
+	// BackupFailures shows, for each backup type with a finished backup, the number of
+	// consecutive backups not completed, compared with the FailureThreshold
+	// +kubebuilder:validation:Optional
+	BackupFailures []BackupTypeFailures `json:"backupFailures,omitempty"`
+	// LastBackupFingerprint is the fingerprint of the hub resources taken for the last scheduled
+	// backup, set when SkipUnchangedBackups is enabled
+	// +kubebuilder:validation:Optional
+	LastBackupFingerprint *BackupFingerprint `json:"lastBackupFingerprint,omitempty"`
+	// Conditions show the schedule state using the Ready, BackupCollision, Paused and BackupTimedOut
+	// condition types, and the last finished backup of each backup type using the <type>Backup condition types
+	// +kubebuilder:validation:Optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -96,6 +381,81 @@ type BackupSchedule struct {
 	Status BackupScheduleStatus `json:"status,omitempty"`
 }
 
+// BackupSchedule condition type
+const (
+	// BackupScheduleReady means the Velero schedules are enabled and triggering backups
+	BackupScheduleReady = "Ready"
+	// BackupScheduleCollision means another hub is writing backups to the same storage location
+	BackupScheduleCollision = "BackupCollision"
+	// BackupScheduleResourceRulesConflict means a resource is both included and excluded
+	// by a Velero schedule; the resource is excluded from the backups
+	BackupScheduleResourceRulesConflict = "ResourceRulesConflict"
+	// BackupSchedulePaused means the schedule is paused and the Velero schedules are removed
+	BackupSchedulePaused = "Paused"
+	// the <type>Backup conditions show the phase of the last finished Velero backup of each backup type;
+	// True when that backup is completed
+	BackupScheduleCredentialsBackup        = "CredentialsBackup"
+	BackupScheduleCredentialsHiveBackup    = "CredentialsHiveBackup"
+	BackupScheduleCredentialsClusterBackup = "CredentialsClusterBackup"
+	BackupScheduleResourcesBackup          = "ResourcesBackup"
+	BackupScheduleResourcesGenericBackup   = "ResourcesGenericBackup"
+	BackupScheduleManagedClustersBackup    = "ManagedClustersBackup"
+	BackupScheduleValidationBackup         = "ValidationBackup"
+	// BackupScheduleBackupChainHealthy means a validation backup completed within the
+	// validation schedule interval, so the backups reach the storage location
+	BackupScheduleBackupChainHealthy = "BackupChainHealthy"
+	// BackupScheduleDegraded means the backups of a backup type failed FailureThreshold
+	// or more consecutive times
+	BackupScheduleDegraded = "Degraded"
+	// BackupScheduleBackupTimedOut means a Velero backup is InProgress for longer than the BackupTimeout
+	BackupScheduleBackupTimedOut = "BackupTimedOut"
+	// BackupScheduleStorageEncryption shows if server-side encryption is configured
+	// for the storage locations the backups are written to; informational only
+	BackupScheduleStorageEncryption = "StorageEncryption"
+	// BackupScheduleVeleroNotInstalled means the Velero CRDs are not installed on the hub,
+	// so no Velero schedule can be created
+	BackupScheduleVeleroNotInstalled = "VeleroNotInstalled"
+)
+
+// Valid BackupSchedule Reason
+const (
+	BackupScheduleReasonNotStarted       = "ScheduleNotStarted"
+	BackupScheduleReasonNew              = "ScheduleNew"
+	BackupScheduleReasonEnabled          = "ScheduleEnabled"
+	BackupScheduleReasonFailedValidation = "ScheduleFailedValidation"
+	BackupScheduleReasonFailed           = "ScheduleFailed"
+	BackupScheduleReasonUnknown          = "ScheduleUnknown"
+	BackupScheduleReasonBackupCollision  = "ScheduleBackupCollision"
+	BackupScheduleReasonNoCollision      = "ScheduleNoCollision"
+	// reasons for the ResourceRulesConflict condition type
+	BackupScheduleReasonResourceRulesConflict   = "ScheduleResourceRulesConflict"
+	BackupScheduleReasonNoResourceRulesConflict = "ScheduleNoResourceRulesConflict"
+	// reasons for the Paused condition type
+	BackupScheduleReasonPaused    = "SchedulePaused"
+	BackupScheduleReasonNotPaused = "ScheduleNotPaused"
+	// reasons for the <type>Backup condition types
+	BackupScheduleReasonBackupCompleted  = "BackupCompleted"
+	BackupScheduleReasonBackupFailed     = "BackupFailed"
+	BackupScheduleReasonNoFinishedBackup = "NoFinishedBackup"
+	// reasons for the BackupChainHealthy condition type
+	BackupScheduleReasonValidationBackupCurrent = "ValidationBackupCurrent"
+	BackupScheduleReasonValidationBackupMissing = "ValidationBackupMissing"
+	BackupScheduleReasonValidationBackupPending = "ValidationBackupPending"
+	// reasons for the Degraded condition type
+	BackupScheduleReasonFailureThresholdReached    = "FailureThresholdReached"
+	BackupScheduleReasonFailureThresholdNotReached = "FailureThresholdNotReached"
+	// reasons for the BackupTimedOut condition type
+	BackupScheduleReasonBackupTimedOut   = "BackupTimedOut"
+	BackupScheduleReasonNoBackupTimedOut = "NoBackupTimedOut"
+	// reasons for the StorageEncryption condition type
+	BackupScheduleReasonEncryptionVerified = "EncryptionVerified"
+	BackupScheduleReasonEncryptionDisabled = "EncryptionDisabled"
+	BackupScheduleReasonEncryptionUnknown  = "EncryptionUnknown"
+	// reasons for the VeleroNotInstalled condition type
+	BackupScheduleReasonVeleroCRDsNotFound = "VeleroCRDsNotFound"
+	BackupScheduleReasonVeleroCRDsFound    = "VeleroCRDsFound"
+)
+
 //+kubebuilder:object:root=true
 
 // BackupScheduleList contains a list of backup schedules
@@ -23,15 +23,32 @@ package v1beta1
 
 import (
 	"github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BackupFingerprint) DeepCopyInto(out *BackupFingerprint) {
+	*out = *in
+	in.ScheduledTime.DeepCopyInto(&out.ScheduledTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupFingerprint.
+func (in *BackupFingerprint) DeepCopy() *BackupFingerprint {
+	if in == nil {
+		return nil
+	}
+	out := new(BackupFingerprint)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *BackupSchedule) DeepCopyInto(out *BackupSchedule) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
+	in.Spec.DeepCopyInto(&out.Spec)
 	in.Status.DeepCopyInto(&out.Status)
 }
 
@@ -88,7 +105,57 @@ func (in *BackupScheduleList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *BackupScheduleSpec) DeepCopyInto(out *BackupScheduleSpec) {
 	*out = *in
+	if in.VeleroScheduleOverrides != nil {
+		in, out := &in.VeleroScheduleOverrides, &out.VeleroScheduleOverrides
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	out.ScheduleJitter = in.ScheduleJitter
 	out.VeleroTTL = in.VeleroTTL
+	if in.ExcludedAPIGroups != nil {
+		in, out := &in.ExcludedAPIGroups, &out.ExcludedAPIGroups
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ExcludedNamespaces != nil {
+		in, out := &in.ExcludedNamespaces, &out.ExcludedNamespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.GenericResourceLabelSelector != nil {
+		in, out := &in.GenericResourceLabelSelector, &out.GenericResourceLabelSelector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ManagedClustersLabelSelector != nil {
+		in, out := &in.ManagedClustersLabelSelector, &out.ManagedClustersLabelSelector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SnapshotVolumes != nil {
+		in, out := &in.SnapshotVolumes, &out.SnapshotVolumes
+		*out = new(bool)
+		**out = **in
+	}
+	out.CollisionPreventionWindow = in.CollisionPreventionWindow
+	out.BackupTimeout = in.BackupTimeout
+	in.Hooks.DeepCopyInto(&out.Hooks)
+	if in.BackupLabels != nil {
+		in, out := &in.BackupLabels, &out.BackupLabels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.BackupAnnotations != nil {
+		in, out := &in.BackupAnnotations, &out.BackupAnnotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupScheduleSpec.
@@ -119,6 +186,56 @@ func (in *BackupScheduleStatus) DeepCopyInto(out *BackupScheduleStatus) {
 		*out = new(v1.Schedule)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.StorageLocationStats != nil {
+		in, out := &in.StorageLocationStats, &out.StorageLocationStats
+		*out = make([]StorageLocationStats, len(*in))
+		copy(*out, *in)
+	}
+	if in.RestorableBackups != nil {
+		in, out := &in.RestorableBackups, &out.RestorableBackups
+		*out = make([]RestorableBackupSet, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.LastSuccessfulBackups != nil {
+		in, out := &in.LastSuccessfulBackups, &out.LastSuccessfulBackups
+		*out = make([]LastSuccessfulBackup, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.BackupNowBackups != nil {
+		in, out := &in.BackupNowBackups, &out.BackupNowBackups
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.BackupNowTime != nil {
+		in, out := &in.BackupNowTime, &out.BackupNowTime
+		*out = (*in).DeepCopy()
+	}
+	if in.InProgressBackups != nil {
+		in, out := &in.InProgressBackups, &out.InProgressBackups
+		*out = make([]InProgressBackup, len(*in))
+		copy(*out, *in)
+	}
+	if in.BackupFailures != nil {
+		in, out := &in.BackupFailures, &out.BackupFailures
+		*out = make([]BackupTypeFailures, len(*in))
+		copy(*out, *in)
+	}
+	if in.LastBackupFingerprint != nil {
+		in, out := &in.LastBackupFingerprint, &out.LastBackupFingerprint
+		*out = new(BackupFingerprint)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupScheduleStatus.
@@ -131,13 +248,130 @@ func (in *BackupScheduleStatus) DeepCopy() *BackupScheduleStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BackupTypeFailures) DeepCopyInto(out *BackupTypeFailures) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupTypeFailures.
+func (in *BackupTypeFailures) DeepCopy() *BackupTypeFailures {
+	if in == nil {
+		return nil
+	}
+	out := new(BackupTypeFailures)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BackupTypeRestore) DeepCopyInto(out *BackupTypeRestore) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupTypeRestore.
+func (in *BackupTypeRestore) DeepCopy() *BackupTypeRestore {
+	if in == nil {
+		return nil
+	}
+	out := new(BackupTypeRestore)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HubReadinessCheckStatus) DeepCopyInto(out *HubReadinessCheckStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HubReadinessCheckStatus.
+func (in *HubReadinessCheckStatus) DeepCopy() *HubReadinessCheckStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(HubReadinessCheckStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *InProgressBackup) DeepCopyInto(out *InProgressBackup) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InProgressBackup.
+func (in *InProgressBackup) DeepCopy() *InProgressBackup {
+	if in == nil {
+		return nil
+	}
+	out := new(InProgressBackup)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LastSuccessfulBackup) DeepCopyInto(out *LastSuccessfulBackup) {
+	*out = *in
+	in.LastSuccessfulTimestamp.DeepCopyInto(&out.LastSuccessfulTimestamp)
+	if in.ResourceKinds != nil {
+		in, out := &in.ResourceKinds, &out.ResourceKinds
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LastSuccessfulBackup.
+func (in *LastSuccessfulBackup) DeepCopy() *LastSuccessfulBackup {
+	if in == nil {
+		return nil
+	}
+	out := new(LastSuccessfulBackup)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PlannedVeleroRestore) DeepCopyInto(out *PlannedVeleroRestore) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PlannedVeleroRestore.
+func (in *PlannedVeleroRestore) DeepCopy() *PlannedVeleroRestore {
+	if in == nil {
+		return nil
+	}
+	out := new(PlannedVeleroRestore)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RestorableBackupSet) DeepCopyInto(out *RestorableBackupSet) {
+	*out = *in
+	in.Timestamp.DeepCopyInto(&out.Timestamp)
+	if in.Backups != nil {
+		in, out := &in.Backups, &out.Backups
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RestorableBackupSet.
+func (in *RestorableBackupSet) DeepCopy() *RestorableBackupSet {
+	if in == nil {
+		return nil
+	}
+	out := new(RestorableBackupSet)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Restore) DeepCopyInto(out *Restore) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Restore.
@@ -158,6 +392,26 @@ func (in *Restore) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RestoreFinalizerRemoval) DeepCopyInto(out *RestoreFinalizerRemoval) {
+	*out = *in
+	if in.Finalizers != nil {
+		in, out := &in.Finalizers, &out.Finalizers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RestoreFinalizerRemoval.
+func (in *RestoreFinalizerRemoval) DeepCopy() *RestoreFinalizerRemoval {
+	if in == nil {
+		return nil
+	}
+	out := new(RestoreFinalizerRemoval)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *RestoreList) DeepCopyInto(out *RestoreList) {
 	*out = *in
@@ -209,6 +463,56 @@ func (in *RestoreSpec) DeepCopyInto(out *RestoreSpec) {
 		**out = **in
 	}
 	out.RestoreSyncInterval = in.RestoreSyncInterval
+	if in.RestoreLabelSelector != nil {
+		in, out := &in.RestoreLabelSelector, &out.RestoreLabelSelector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.WaitConditions != nil {
+		in, out := &in.WaitConditions, &out.WaitConditions
+		*out = make([]RestoreWaitCondition, len(*in))
+		copy(*out, *in)
+	}
+	out.WaitTimeout = in.WaitTimeout
+	if in.RemoveFinalizers != nil {
+		in, out := &in.RemoveFinalizers, &out.RemoveFinalizers
+		*out = make([]RestoreFinalizerRemoval, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.HubReadinessChecks != nil {
+		in, out := &in.HubReadinessChecks, &out.HubReadinessChecks
+		*out = make([]HubReadinessCheck, len(*in))
+		copy(*out, *in)
+	}
+	out.HubReadinessTimeout = in.HubReadinessTimeout
+	if in.IncludedBackupTypes != nil {
+		in, out := &in.IncludedBackupTypes, &out.IncludedBackupTypes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.RestoreToTime != nil {
+		in, out := &in.RestoreToTime, &out.RestoreToTime
+		*out = (*in).DeepCopy()
+	}
+	if in.NamespaceMapping != nil {
+		in, out := &in.NamespaceMapping, &out.NamespaceMapping
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.IncludeClusterResources != nil {
+		in, out := &in.IncludeClusterResources, &out.IncludeClusterResources
+		*out = new(bool)
+		**out = **in
+	}
+	if in.IncludedClusterRBACResources != nil {
+		in, out := &in.IncludedClusterRBACResources, &out.IncludedClusterRBACResources
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RestoreSpec.
@@ -224,6 +528,59 @@ func (in *RestoreSpec) DeepCopy() *RestoreSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *RestoreStatus) DeepCopyInto(out *RestoreStatus) {
 	*out = *in
+	if in.RemovedFinalizers != nil {
+		in, out := &in.RemovedFinalizers, &out.RemovedFinalizers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.HubReadiness != nil {
+		in, out := &in.HubReadiness, &out.HubReadiness
+		*out = make([]HubReadinessCheckStatus, len(*in))
+		copy(*out, *in)
+	}
+	if in.PlannedRestores != nil {
+		in, out := &in.PlannedRestores, &out.PlannedRestores
+		*out = make([]PlannedVeleroRestore, len(*in))
+		copy(*out, *in)
+	}
+	if in.LatestBackupSets != nil {
+		in, out := &in.LatestBackupSets, &out.LatestBackupSets
+		*out = make([]RestorableBackupSet, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.SkippedBackupTypes != nil {
+		in, out := &in.SkippedBackupTypes, &out.SkippedBackupTypes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ExcludedClusterResources != nil {
+		in, out := &in.ExcludedClusterResources, &out.ExcludedClusterResources
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.BackupTypeRestores != nil {
+		in, out := &in.BackupTypeRestores, &out.BackupTypeRestores
+		*out = make([]BackupTypeRestore, len(*in))
+		copy(*out, *in)
+	}
+	if in.RestoredItemsTotal != nil {
+		in, out := &in.RestoredItemsTotal, &out.RestoredItemsTotal
+		*out = new(RestoredItemsTotal)
+		**out = **in
+	}
+	if in.NextRestoreAttempt != nil {
+		in, out := &in.NextRestoreAttempt, &out.NextRestoreAttempt
+		*out = (*in).DeepCopy()
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RestoreStatus.
@@ -235,3 +592,48 @@ func (in *RestoreStatus) DeepCopy() *RestoreStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RestoredItemsTotal) DeepCopyInto(out *RestoredItemsTotal) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RestoredItemsTotal.
+func (in *RestoredItemsTotal) DeepCopy() *RestoredItemsTotal {
+	if in == nil {
+		return nil
+	}
+	out := new(RestoredItemsTotal)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RestoreWaitCondition) DeepCopyInto(out *RestoreWaitCondition) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RestoreWaitCondition.
+func (in *RestoreWaitCondition) DeepCopy() *RestoreWaitCondition {
+	if in == nil {
+		return nil
+	}
+	out := new(RestoreWaitCondition)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StorageLocationStats) DeepCopyInto(out *StorageLocationStats) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StorageLocationStats.
+func (in *StorageLocationStats) DeepCopy() *StorageLocationStats {
+	if in == nil {
+		return nil
+	}
+	out := new(StorageLocationStats)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -18,7 +18,9 @@ package controllers
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
@@ -28,8 +30,10 @@ import (
 	k8serr "k8s.io/apimachinery/pkg/api/errors"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
 	chnv1 "open-cluster-management.io/multicloud-operators-channel/pkg/apis/apps/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
@@ -44,7 +48,33 @@ var (
 	BackupScheduleClusterLabel string = "cluster.open-cluster-management.io/backup-cluster"
 	// BackupScheduleActivationLabel stores the name of the restore resources that resulted in creating this backup
 	BackupScheduleActivationLabel string = "cluster.open-cluster-management.io/backup-activation-restore"
+	// BackupClusterDiffAnnotation is the annotation key listing, as JSON, the resource kinds found only
+	// in the backup or only on the hub; see CompareBackupToCluster
+	BackupClusterDiffAnnotation string = "cluster.open-cluster-management.io/backup-cluster-diff"
+	// BackupClusterDiffErrorAnnotation is the annotation key storing the error comparing the backup
+	// to the hub resources; the backup is not compared again
+	BackupClusterDiffErrorAnnotation string = "cluster.open-cluster-management.io/backup-cluster-diff-error"
+	// VeleroScheduleSpecHashAnnotation is the annotation key storing the hash of the velero schedule
+	// applied by the operator; the velero schedule is not applied again while the hash is unchanged
+	VeleroScheduleSpecHashAnnotation string = "cluster.open-cluster-management.io/schedule-spec-hash"
+	// ManagedClusterBackupExcludedAnnotation is set on the managed clusters labeled by the operator
+	// with velero.io/exclude-from-backup, since they are not selected by the managedClustersLabelSelector
+	// of the backup schedule
+	ManagedClusterBackupExcludedAnnotation string = "cluster.open-cluster-management.io/backup-excluded"
 )
+
+// ResourceDiff is a resource kind included by a backup with no matching resources on the hub,
+// or a resource kind with hub resources matching the backup which is not included by the backup
+type ResourceDiff struct {
+	// Resource is the resource kind, using the kind.group format
+	Resource string `json:"resource"`
+	// InBackup is true if the resource kind is included by the backup
+	InBackup bool `json:"inBackup"`
+	// OnCluster is the number of hub resources of this kind matching the backup
+	// label selector and namespaces
+	OnCluster int `json:"onCluster"`
+}
+
 var (
 	// include resources from these api groups
 	includedAPIGroupsSuffix = []string{
@@ -91,6 +121,18 @@ var (
 		"discoveredcluster.discovery.open-cluster-management.io",
 	}
 
+	// resources regenerated by their controllers, excluded by default from the generic resources backup
+	// restoring them creates conflicts with the resources recreated by the controllers
+	transientResources = []string{
+		"pod",
+		"replicaset.apps",
+		"controllerrevision.apps",
+		"endpoints",
+		"endpointslice.discovery.k8s.io",
+		"event",
+		"event.events.k8s.io",
+	}
+
 	// resources used to activate the connection between hub and managed clusters - activation resources
 	backupManagedClusterResources = []string{
 		"managedcluster.cluster.open-cluster-management.io", //global
@@ -104,6 +146,26 @@ var (
 		"baremetalhost.metal3.io",
 		"bmceventsubscription.metal3.io",
 		"hostfirmwaresettings.metal3.io",
+		// cluster sets organize the managed clusters, restore them with the managed clusters
+		"managedclusterset.cluster.open-cluster-management.io",
+		"managedclustersetbinding.cluster.open-cluster-management.io",
+		// placement decisions select managed clusters, they are backed up with the managed clusters
+		// and restored by a separate Velero restore, once the managed clusters are restored
+		placementDecisionResource,
+	}
+
+	// resources organizing the managed clusters, restored with the managed clusters
+	// and reported when the managed clusters restore is completed
+	clusterFleetResources = []string{
+		"managedclusterset.cluster.open-cluster-management.io",
+		"managedclustersetbinding.cluster.open-cluster-management.io",
+		"clusterclaim.hive.openshift.io",
+	}
+
+	// placement resources, restored with the other acm resources
+	placementResources = []string{
+		"placement.cluster.open-cluster-management.io",
+		"placementrule.apps.open-cluster-management.io",
 	}
 
 	// all backup resources, except secrets, configmaps and managed cluster activation resources
@@ -127,6 +189,18 @@ var (
 	policyRootLabel         = "policy.open-cluster-management.io/root-policy"
 )
 
+// placement decisions resource, backed up with the managed clusters activation resources
+const placementDecisionResource = "placementdecision.cluster.open-cluster-management.io"
+
+// the resources with this label set to true are not backed up by Velero
+const veleroExcludeFromBackupLabel = "velero.io/exclude-from-backup"
+
+var managedClustersGVR = schema.GroupVersionResource{
+	Group:    "cluster.open-cluster-management.io",
+	Version:  "v1",
+	Resource: "managedclusters",
+}
+
 var (
 	apiGVString = v1beta1.GroupVersion.String()
 	// create credentials schedule first since this is the fastest one, followed by resources
@@ -140,8 +214,19 @@ var (
 		ManagedClusters:    "acm-managed-clusters-schedule",
 		ValidationSchedule: "acm-validation-policy-schedule",
 	}
+	// the schedule backing up the contents of the labeled namespaces, in the NamespaceContents mode;
+	// not part of veleroScheduleNames since it exists only when there are labeled namespaces
+	namespaceContentsScheduleName = "acm-namespace-contents-schedule"
 )
 
+// returns the name of the velero schedule creating the backups of this backup type
+func getBackupTypeScheduleName(backupType ResourceType) string {
+	if backupType == NamespaceContents {
+		return namespaceContentsScheduleName
+	}
+	return veleroScheduleNames[backupType]
+}
+
 // set all acm resources backup info
 func setResourcesBackupInfo(
 	ctx context.Context,
@@ -217,6 +302,7 @@ func setResourcesBackupInfo(
 func setGenericResourcesBackupInfo(
 	ctx context.Context,
 	veleroBackupTemplate *veleroapi.BackupSpec,
+	backupSchedule *v1beta1.BackupSchedule,
 	c client.Client,
 ) {
 
@@ -244,20 +330,224 @@ func setGenericResourcesBackupInfo(
 		)
 	}
 
-	if veleroBackupTemplate.LabelSelector == nil {
-		labels := &v1.LabelSelector{}
-		veleroBackupTemplate.LabelSelector = labels
+	if !backupSchedule.Spec.BackupTransientResources {
+		for i := range transientResources { // exclude resources regenerated by their controllers
+			veleroBackupTemplate.ExcludedResources = appendUnique(
+				veleroBackupTemplate.ExcludedResources,
+				transientResources[i],
+			)
+		}
+	}
 
-		requirements := make([]v1.LabelSelectorRequirement, 0)
-		veleroBackupTemplate.LabelSelector.MatchExpressions = requirements
+	veleroBackupTemplate.LabelSelector = getGenericResourcesBackupLabelSelector(backupSchedule)
+}
+
+// returns the label selector used by the generic resources backup;
+// the selector set on the backup schedule replaces the default backup label selector
+func getGenericResourcesBackupLabelSelector(
+	backupSchedule *v1beta1.BackupSchedule,
+) *v1.LabelSelector {
+
+	if backupSchedule.Spec.GenericResourceLabelSelector != nil {
+		return backupSchedule.Spec.GenericResourceLabelSelector.DeepCopy()
 	}
+
+	labelSelector := &v1.LabelSelector{}
 	req := &v1.LabelSelectorRequirement{}
 	req.Key = backupCredsClusterLabel
 	req.Operator = "Exists"
-	veleroBackupTemplate.LabelSelector.MatchExpressions = append(
-		veleroBackupTemplate.LabelSelector.MatchExpressions,
-		*req,
-	)
+	labelSelector.MatchExpressions = append(labelSelector.MatchExpressions, *req)
+	return labelSelector
+}
+
+// returns the sorted names of the managed clusters not selected by the
+// managedClustersLabelSelector of the backup schedule;
+// no managed cluster is returned if the backup schedule doesn't set a selector
+func getUnselectedManagedClusters(
+	ctx context.Context,
+	backupSchedule *v1beta1.BackupSchedule,
+	dyn dynamic.Interface,
+) ([]string, error) {
+
+	if backupSchedule.Spec.ManagedClustersLabelSelector == nil {
+		return nil, nil
+	}
+	selector, err := v1.LabelSelectorAsSelector(backupSchedule.Spec.ManagedClustersLabelSelector)
+	if err != nil {
+		return nil, err
+	}
+	managedClusters, err := dyn.Resource(managedClustersGVR).List(ctx, v1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for i := range managedClusters.Items {
+		if !selector.Matches(labels.Set(managedClusters.Items[i].GetLabels())) {
+			names = append(names, managedClusters.Items[i].GetName())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// exclude the managed clusters not selected by the backup schedule from the managed clusters backup:
+// the resources in the managed cluster namespace are excluded with the namespace
+func setUnselectedManagedClustersBackupInfo(
+	veleroBackupTemplate *veleroapi.BackupSpec,
+	unselectedClusters []string,
+) {
+	for _, name := range unselectedClusters {
+		veleroBackupTemplate.ExcludedNamespaces = appendUnique(
+			veleroBackupTemplate.ExcludedNamespaces,
+			name,
+		)
+	}
+}
+
+// the ManagedCluster resources are cluster scoped, so they can't be excluded by namespace;
+// label the managed clusters not selected by the backup schedule with velero.io/exclude-from-backup
+// so Velero skips them. The label is removed from the managed clusters labeled by the operator
+// once they are selected again, or when the backup schedule doesn't set a selector anymore
+func setManagedClustersBackupExclusion(
+	ctx context.Context,
+	dyn dynamic.Interface,
+	unselectedClusters []string,
+) error {
+	logger := log.FromContext(ctx)
+
+	managedClusters, err := dyn.Resource(managedClustersGVR).List(ctx, v1.ListOptions{})
+	if err != nil {
+		if k8serr.IsNotFound(err) {
+			// no managed clusters CRD
+			return nil
+		}
+		return err
+	}
+
+	for i := range managedClusters.Items {
+		managedCluster := &managedClusters.Items[i]
+		var value interface{}
+		switch {
+		case findValue(unselectedClusters, managedCluster.GetName()) &&
+			managedCluster.GetLabels()[veleroExcludeFromBackupLabel] != "true":
+			value = "true"
+		case !findValue(unselectedClusters, managedCluster.GetName()) &&
+			managedCluster.GetAnnotations()[ManagedClusterBackupExcludedAnnotation] != "":
+			value = nil // remove the label and the annotation
+		default:
+			continue
+		}
+		patch, err := json.Marshal(map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"labels":      map[string]interface{}{veleroExcludeFromBackupLabel: value},
+				"annotations": map[string]interface{}{ManagedClusterBackupExcludedAnnotation: value},
+			},
+		})
+		if err != nil {
+			return err
+		}
+		logger.Info("update managed cluster backup exclusion",
+			"name", managedCluster.GetName(), "excluded", value != nil)
+		if _, err := dyn.Resource(managedClustersGVR).Patch(ctx, managedCluster.GetName(),
+			types.MergePatchType, patch, v1.PatchOptions{}); err != nil {
+			logger.Error(err, "failed to update managed cluster", "name", managedCluster.GetName())
+		}
+	}
+	return nil
+}
+
+// exclude the namespaces set on the backup schedule from the resources backups;
+// an excluded namespace is removed from the included namespaces
+func setExcludedNamespaces(
+	veleroBackupTemplate *veleroapi.BackupSpec,
+	backupSchedule *v1beta1.BackupSchedule,
+) {
+	for _, namespace := range backupSchedule.Spec.ExcludedNamespaces {
+		veleroBackupTemplate.ExcludedNamespaces = appendUnique(
+			veleroBackupTemplate.ExcludedNamespaces,
+			namespace,
+		)
+	}
+
+	if len(veleroBackupTemplate.IncludedNamespaces) == 0 {
+		return
+	}
+	includedNamespaces := []string{}
+	for _, namespace := range veleroBackupTemplate.IncludedNamespaces {
+		if !findValue(backupSchedule.Spec.ExcludedNamespaces, namespace) {
+			includedNamespaces = append(includedNamespaces, namespace)
+		}
+	}
+	veleroBackupTemplate.IncludedNamespaces = includedNamespaces
+}
+
+// when the schedule uses the NamespaceContents mode, a namespace labeled with
+// cluster.open-cluster-management.io/backup implies all the resources it contains are backed up.
+// Velero applies the backup label selector to each resource and
+// the namespace label is not inherited by the namespaced resources,
+// so the labeled namespaces are backed up by name with a dedicated schedule.
+// Returns the sorted labeled namespaces, except the namespaces excluded by the backup schedule;
+// no namespace is returned for the NamespaceOnly mode
+func getNamespaceContentsNamespaces(
+	ctx context.Context,
+	backupSchedule *v1beta1.BackupSchedule,
+	dyn dynamic.Interface,
+) ([]string, error) {
+
+	if backupSchedule.Spec.NamespaceBackupMode != v1beta1.NamespaceBackupModeNamespaceContents {
+		// NamespaceOnly, the labeled namespace is backed up without its contents
+		return nil, nil
+	}
+
+	namespaces, err := dyn.Resource(schema.GroupVersionResource{
+		Version:  "v1",
+		Resource: "namespaces",
+	}).List(ctx, v1.ListOptions{LabelSelector: backupCredsClusterLabel})
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for i := range namespaces.Items {
+		name := namespaces.Items[i].GetName()
+		if !findValue(backupSchedule.Spec.ExcludedNamespaces, name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// set the namespace contents backup info: all the resources in the labeled namespaces,
+// except the events and the resources not backed up or restored with the managed clusters
+func setNamespaceContentsBackupInfo(
+	veleroBackupTemplate *veleroapi.BackupSpec,
+	namespaces []string,
+) {
+
+	var clusterResource bool = false // namespaced resources only
+	veleroBackupTemplate.IncludeClusterResources = &clusterResource
+	veleroBackupTemplate.IncludedNamespaces = append([]string{}, namespaces...)
+
+	for _, resource := range []string{"events", "events.events.k8s.io"} {
+		veleroBackupTemplate.ExcludedResources = appendUnique(
+			veleroBackupTemplate.ExcludedResources,
+			resource,
+		)
+	}
+	for i := range excludedCRDs { // exclude resources not backed up
+		veleroBackupTemplate.ExcludedResources = appendUnique(
+			veleroBackupTemplate.ExcludedResources,
+			excludedCRDs[i],
+		)
+	}
+	for i := range backupManagedClusterResources { // exclude resources in managed clusters
+		veleroBackupTemplate.ExcludedResources = appendUnique(
+			veleroBackupTemplate.ExcludedResources,
+			backupManagedClusterResources[i],
+		)
+	}
 }
 
 // set credentials backup info
@@ -361,7 +651,7 @@ func setValidationBackupInfo(
 	// in this short interval when the old validation backup is deleted
 	// and the old one is recreated by the cron job validation schedule
 	veleroBackupTemplate.TTL = v1.Duration{Duration: time.Hour * 1}
-	if cronSchedule, err := cron.ParseStandard(backupSchedule.Spec.VeleroSchedule); err == nil {
+	if cronSchedule, err := cron.ParseStandard(getValidationCronSchedule(backupSchedule)); err == nil {
 		currentTime := v1.Now().Time
 		nextRunTime := cronSchedule.Next(currentTime)
 		// add extra 5 minutes to the cron job time before deleting this backup
@@ -403,60 +693,208 @@ func filterBackups(vs []veleroapi.Backup, f func(veleroapi.Backup) bool) []veler
 	return filtered
 }
 
+// returns the included resources matching an excluded resource of the backup template
+// resources are compared using the kind.group format; an entry with no group
+// matches the kind from any group, as done when excluding the generic resources
+func getResourceRulesConflicts(veleroBackupTemplate *veleroapi.BackupSpec) []string {
+
+	conflicts := []string{}
+	for _, included := range veleroBackupTemplate.IncludedResources {
+		if included == "*" {
+			// all resources are included, the excluded resources are not a conflict
+			continue
+		}
+		includedKind, includedGroup := getResourceDetails(strings.ToLower(included))
+		for _, excluded := range veleroBackupTemplate.ExcludedResources {
+			excludedKind, excludedGroup := getResourceDetails(strings.ToLower(excluded))
+			if includedKind == excludedKind &&
+				(includedGroup == excludedGroup || includedGroup == "" || excludedGroup == "") {
+				conflicts = appendUnique(conflicts, included)
+				break
+			}
+		}
+	}
+	return conflicts
+}
+
+// resourceRulesError is returned when all the included resources of a backup are excluded;
+// the backup would include all resources if its included resources were emptied
+type resourceRulesError struct {
+	error
+}
+
+// removes the conflicting resources from the included resources of the backup template
+// the excluded resources take precedence, as they do for Velero; returns an error,
+// without updating the backup template, if all the included resources are excluded
+func resolveResourceRulesConflicts(
+	veleroBackupTemplate *veleroapi.BackupSpec,
+	conflicts []string,
+) error {
+	includedResources := []string{}
+	for _, included := range veleroBackupTemplate.IncludedResources {
+		if !findValue(conflicts, included) {
+			includedResources = append(includedResources, included)
+		}
+	}
+	if len(includedResources) == 0 && len(veleroBackupTemplate.IncludedResources) > 0 {
+		return &resourceRulesError{fmt.Errorf(
+			"all the included resources are excluded from backup: %s",
+			strings.Join(conflicts, ", "))}
+	}
+	veleroBackupTemplate.IncludedResources = includedResources
+	return nil
+}
+
+// returns the excluded resources in the kind.group form, without duplicates; a resource
+// is matched by its kind or by its resource name, so deployments.apps is deployment.apps.
+// A resource with no group is qualified with the group serving that kind; it is kept as is
+// if the kind is served by the core group, by more than one group or by none, so it still
+// matches the kind from any group. The excluded resources are kept if discovery fails
+func normalizeExcludedResources(
+	ctx context.Context,
+	dc discovery.DiscoveryInterface,
+	excludedResources []string,
+) []string {
+
+	groupVersions, err := getServerGroupVersionResources(ctx, dc)
+	if err != nil {
+		log.FromContext(ctx).Info("Failed to discover the excluded resources kinds", "error", err.Error())
+		groupVersions = nil
+	}
+	return canonicalizeExcludedResources(groupVersions, excludedResources)
+}
+
+// returns the resources, in the kind.group form, served by the excluded API groups, see
+// isExcludedAPIGroup; the generic resources backup excludes these resources since Velero
+// can't exclude a whole API group. No resource is returned if discovery fails
+func getExcludedAPIGroupsResources(
+	ctx context.Context,
+	dc discovery.DiscoveryInterface,
+	excludedGroups []string,
+) []string {
+
+	if len(excludedGroups) == 0 {
+		return nil
+	}
+	groupVersions, err := getServerGroupVersionResources(ctx, dc)
+	if err != nil {
+		log.FromContext(ctx).Info("Failed to discover the excluded API groups resources",
+			"error", err.Error())
+		return nil
+	}
+	return getAPIGroupsResources(groupVersions, excludedGroups)
+}
+
+// returns the resources, in the kind.group form, served by the excluded API groups,
+// see getExcludedAPIGroupsResources
+func getAPIGroupsResources(
+	groupVersions []groupVersionResources,
+	excludedGroups []string,
+) []string {
+
+	resources := []string{}
+	for _, groupVersion := range groupVersions {
+		group := strings.ToLower(groupVersion.group.Name)
+		if group == "" || !isExcludedAPIGroup(excludedGroups, group) {
+			continue
+		}
+		for _, resource := range groupVersion.resourceList.APIResources {
+			if strings.Contains(resource.Name, "/") {
+				// ignore subresources
+				continue
+			}
+			resources = appendUnique(resources, strings.ToLower(resource.Kind)+"."+group)
+		}
+	}
+	sort.Strings(resources)
+	return resources
+}
+
+// canonicalize the excluded resources using the server resources, see normalizeExcludedResources
+func canonicalizeExcludedResources(
+	groupVersions []groupVersionResources,
+	excludedResources []string,
+) []string {
+
+	// the groups serving each kind, and the kind of each resource name
+	kindGroups := map[string][]string{}
+	resourceKinds := map[string]string{}
+	for _, groupVersion := range groupVersions {
+		group := strings.ToLower(groupVersion.group.Name)
+		for _, resource := range groupVersion.resourceList.APIResources {
+			if strings.Contains(resource.Name, "/") {
+				// ignore subresources
+				continue
+			}
+			kind := strings.ToLower(resource.Kind)
+			kindGroups[kind] = appendUnique(kindGroups[kind], group)
+			resourceKinds[strings.ToLower(resource.Name)] = kind
+		}
+	}
+
+	canonical := []string{}
+	added := caseInsensitiveSet{}
+	for _, excluded := range excludedResources {
+		name, group := getResourceDetails(strings.ToLower(excluded))
+		if _, ok := kindGroups[name]; !ok {
+			name = resourceKinds[name]
+		}
+		resource := excluded
+		if groups, ok := kindGroups[name]; ok {
+			switch {
+			case group != "":
+				resource = name + "." + group
+			case len(groups) == 1 && groups[0] != "":
+				resource = name + "." + groups[0]
+			default:
+				resource = name
+			}
+		}
+		if added.insert(resource) {
+			canonical = append(canonical, resource)
+		}
+	}
+	return canonical
+}
+
 // get server resources that needs backup
 func getResourcesToBackup(
 	ctx context.Context,
 	dc discovery.DiscoveryInterface,
 ) ([]string, error) {
 
-	backupLogger := log.FromContext(ctx)
+	spanCtx, span := tracer.Start(ctx, "discover resources to backup")
+	defer span.End()
 
-	backupResourceNames := backupResources
+	backupResourceNames := append([]string{}, backupResources...)
+	addedResources := newCaseInsensitiveSet(backupResources)
 
 	// build the list of excluded resources
-	ignoreCRDs := excludedCRDs
+	ignoreCRDs := newCaseInsensitiveSet(excludedCRDs, backupManagedClusterResources)
 
-	groupList, err := dc.ServerGroups()
+	groupVersions, err := getServerGroupVersionResources(spanCtx, dc)
 	if err != nil {
-		return backupResourceNames, fmt.Errorf("failed to get server groups: %v", err)
-	}
-	if groupList == nil {
-		return backupResourceNames, nil
+		return backupResourceNames, err
 	}
-	for _, group := range groupList.Groups {
+	for _, groupVersion := range groupVersions {
+		group := groupVersion.group
 
 		if !shouldBackupAPIGroup(group.Name) {
 			// ignore excluded api groups
 			continue
 		}
 
-		for _, version := range group.Versions {
-			//get all resources for each group version
-			resourceList, err := dc.ServerResourcesForGroupVersion(version.GroupVersion)
-			if err != nil {
-				backupLogger.Info(
-					fmt.Sprintf("Failed to get server resources for group=%s, version=%s, error:%s",
-						group.Name, version.GroupVersion,
-						err.Error()),
-				)
-				continue
-			}
-			if resourceList == nil {
-				continue
-			}
-			for _, resource := range resourceList.APIResources {
-				resourceKind := strings.ToLower(resource.Kind)
-				resourceName := resourceKind + "." + group.Name
-				// if resource kind is not ignored
-				// and kind.group is not used to identify resource to ignore
-				// the resource is not in cluster activation backup group
-				// add it to the generic backup resources
-				if !findValue(ignoreCRDs, resourceKind) &&
-					!findValue(ignoreCRDs, resourceName) &&
-					!findValue(backupManagedClusterResources, resourceKind) &&
-					!findValue(backupManagedClusterResources, resourceName) {
-					backupResourceNames = appendUnique(backupResourceNames, resourceName)
-				}
+		for _, resource := range groupVersion.resourceList.APIResources {
+			resourceKind := strings.ToLower(resource.Kind)
+			resourceName := resourceKind + "." + group.Name
+			// if resource kind is not ignored
+			// and kind.group is not used to identify resource to ignore
+			// the resource is not in cluster activation backup group
+			// add it to the generic backup resources
+			if !ignoreCRDs.has(resourceKind) &&
+				!ignoreCRDs.has(resourceName) &&
+				addedResources.insert(resourceName) {
+				backupResourceNames = append(backupResourceNames, resourceName)
 			}
 		}
 	}
@@ -563,3 +1001,178 @@ func deleteBackup(
 		}
 	}
 }
+
+// compares the resource kinds included by the velero backup with the hub resources
+// matching the backup label selector and namespaces; returns, sorted by resource, the kinds
+// listed by the backup included resources with no hub resources and the kinds with
+// hub resources which are not included by the backup. The hub kinds are the kinds
+// included by the backup and the generic resources kinds, see getGenericCRDFromAPIGroups
+func CompareBackupToCluster(
+	ctx context.Context,
+	dc discovery.DiscoveryInterface,
+	dyn dynamic.Interface,
+	veleroBackup *veleroapi.Backup,
+) ([]ResourceDiff, error) {
+
+	logger := log.FromContext(ctx)
+
+	// the excluded API groups resources are already excluded by the backup
+	genericResources, err := getGenericCRDFromAPIGroups(ctx, dc, veleroBackup, nil)
+	if err != nil {
+		return nil, err
+	}
+	// a backup with no included resources backs up all the resources it selects
+	explicitResources := len(veleroBackup.Spec.IncludedResources) > 0 &&
+		!findValue(veleroBackup.Spec.IncludedResources, "*")
+	backupResources := genericResources
+	if explicitResources {
+		backupResources = []string{}
+		for _, resource := range veleroBackup.Spec.IncludedResources {
+			backupResources = appendUnique(backupResources, strings.ToLower(resource))
+		}
+	}
+
+	selector := labels.Everything()
+	if veleroBackup.Spec.LabelSelector != nil {
+		if selector, err = v1.LabelSelectorAsSelector(veleroBackup.Spec.LabelSelector); err != nil {
+			return nil, err
+		}
+	}
+
+	resourceVersions, err := getListableResources(ctx, dc)
+	if err != nil {
+		return nil, err
+	}
+
+	inBackup := newCaseInsensitiveSet(backupResources)
+	resources := append([]string{}, backupResources...)
+	for _, resource := range genericResources {
+		resources = appendUnique(resources, resource)
+	}
+
+	diffs := []ResourceDiff{}
+	for _, resource := range resources {
+		count := 0
+		if gvr, ok := resourceVersions[resource]; ok {
+			count, err = countBackupResources(ctx, dyn.Resource(gvr), selector, veleroBackup)
+			if err != nil {
+				// the resources can't be compared, ignore this kind
+				logger.Info("Failed to list resources", "resource", resource, "error", err.Error())
+				continue
+			}
+		}
+		included := inBackup.has(resource)
+		if included == (count > 0) || (included && !explicitResources) {
+			// backed up and on the hub, neither, or a kind not explicitly included
+			continue
+		}
+		diffs = append(diffs, ResourceDiff{
+			Resource:  resource,
+			InBackup:  included,
+			OnCluster: count,
+		})
+	}
+	sort.Slice(diffs, func(i, j int) bool {
+		return diffs[i].Resource < diffs[j].Resource
+	})
+	return diffs, nil
+}
+
+// page size used when listing the hub resources compared to a backup
+const compareListPageSize = 500
+
+// returns the number of hub resources listed by the resource client matching the selector
+// and in the backup namespaces; the resources are listed by pages
+func countBackupResources(
+	ctx context.Context,
+	resourceClient dynamic.ResourceInterface,
+	selector labels.Selector,
+	veleroBackup *veleroapi.Backup,
+) (int, error) {
+
+	count := 0
+	listOptions := v1.ListOptions{
+		LabelSelector: selector.String(),
+		Limit:         compareListPageSize,
+	}
+	for {
+		dynamicList, err := resourceClient.List(ctx, listOptions)
+		if err != nil {
+			return 0, err
+		}
+		for i := range dynamicList.Items {
+			if isInBackupNamespaces(veleroBackup, dynamicList.Items[i].GetNamespace()) {
+				count++
+			}
+		}
+		listOptions.Continue = dynamicList.GetContinue()
+		if listOptions.Continue == "" {
+			return count, nil
+		}
+	}
+}
+
+// returns true if the backup was compared to the hub resources, even if the comparison failed
+func isBackupClusterDiffSet(backup *veleroapi.Backup) bool {
+
+	annotations := backup.GetAnnotations()
+	if _, ok := annotations[BackupClusterDiffAnnotation]; ok {
+		return true
+	}
+	_, ok := annotations[BackupClusterDiffErrorAnnotation]
+	return ok
+}
+
+// returns the group version resource for each listable server resource kind,
+// keyed by kind.group, or by kind for the core resources; uses the preferred group version
+func getListableResources(
+	ctx context.Context,
+	dc discovery.DiscoveryInterface,
+) (map[string]schema.GroupVersionResource, error) {
+
+	groupVersions, err := getServerGroupVersionResources(ctx, dc)
+	if err != nil {
+		return nil, err
+	}
+
+	resources := map[string]schema.GroupVersionResource{}
+	for _, groupVersion := range groupVersions {
+		gv, err := schema.ParseGroupVersion(groupVersion.resourceList.GroupVersion)
+		if err != nil {
+			continue
+		}
+		preferred := groupVersion.group.PreferredVersion.GroupVersion == gv.String()
+		for _, resource := range groupVersion.resourceList.APIResources {
+			if strings.Contains(resource.Name, "/") || !findValue(resource.Verbs, "list") {
+				// subresource or not listable
+				continue
+			}
+			resourceName := strings.ToLower(resource.Kind)
+			if gv.Group != "" {
+				resourceName = resourceName + "." + strings.ToLower(gv.Group)
+			}
+			if _, ok := resources[resourceName]; ok && !preferred {
+				continue
+			}
+			resources[resourceName] = gv.WithResource(resource.Name)
+		}
+	}
+	return resources, nil
+}
+
+// returns true if a resource in this namespace is backed up by the velero backup;
+// an empty namespace is used for the cluster scoped resources
+func isInBackupNamespaces(veleroBackup *veleroapi.Backup, namespace string) bool {
+
+	if namespace == "" {
+		return veleroBackup.Spec.IncludeClusterResources == nil ||
+			*veleroBackup.Spec.IncludeClusterResources
+	}
+	if findValue(veleroBackup.Spec.ExcludedNamespaces, namespace) {
+		return false
+	}
+	includedNamespaces := veleroBackup.Spec.IncludedNamespaces
+	return len(includedNamespaces) == 0 ||
+		findValue(includedNamespaces, "*") ||
+		findValue(includedNamespaces, namespace)
+}
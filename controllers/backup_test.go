@@ -1,14 +1,25 @@
 package controllers
 
 import (
+	"context"
 	"math/rand"
+	"reflect"
 	"strings"
+	"testing"
 	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	veleroapi "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/dynamic"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	fakeclientset "k8s.io/client-go/kubernetes/fake"
 )
 
 const letterBytes = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
@@ -50,9 +61,12 @@ var _ = Describe("Backup", func() {
 			//returns the concatenated strings, no trimming
 			Expect(getValidKsRestoreName("a", "b")).Should(Equal("a-b"))
 
-			//returns substring of length 252
+			//returns substring of length 252, ending with a hash of the full name
 			longName := RandStringBytesMask(260)
-			Expect(getValidKsRestoreName(longName, "b")).Should(Equal(longName[:252]))
+			Expect(getValidKsRestoreName(longName, "b")).Should(HaveLen(252))
+			Expect(getValidKsRestoreName(longName, "b")).Should(HavePrefix(strings.ToLower(longName[:243])))
+			Expect(getValidKsRestoreName(longName, "b")).ShouldNot(
+				Equal(getValidKsRestoreName(longName, "c")))
 
 			Expect(isBackupFinished(nil)).Should(BeFalse())
 
@@ -277,3 +291,227 @@ var _ = Describe("Backup", func() {
 
 	})
 })
+
+func Test_CompareBackupToCluster(t *testing.T) {
+
+	listVerbs := []string{"list"}
+	newDiscovery := func() *fakediscovery.FakeDiscovery {
+		fakeDiscovery, ok := fakeclientset.NewSimpleClientset().Discovery().(*fakediscovery.FakeDiscovery)
+		if !ok {
+			t.Fatalf("couldn't convert Discovery() to *FakeDiscovery")
+		}
+		fakeDiscovery.Resources = []*metav1.APIResourceList{
+			{
+				GroupVersion: "v1",
+				APIResources: []metav1.APIResource{
+					{Name: "secrets", Kind: "Secret", Namespaced: true, Verbs: listVerbs},
+					{Name: "configmaps", Kind: "ConfigMap", Namespaced: true, Verbs: listVerbs},
+				},
+			},
+			{
+				GroupVersion: "cluster.open-cluster-management.io/v1",
+				APIResources: []metav1.APIResource{
+					{Name: "managedclusters", Kind: "ManagedCluster", Verbs: listVerbs},
+					{Name: "managedclusters/status", Kind: "ManagedCluster", Verbs: []string{"get"}},
+				},
+			},
+			{
+				GroupVersion: "apps.open-cluster-management.io/v1",
+				APIResources: []metav1.APIResource{
+					{Name: "channels", Kind: "Channel", Namespaced: true, Verbs: listVerbs},
+				},
+			},
+		}
+		return fakeDiscovery
+	}
+	listKinds := map[schema.GroupVersionResource]string{
+		{Version: "v1", Resource: "secrets"}:    "SecretList",
+		{Version: "v1", Resource: "configmaps"}: "ConfigMapList",
+		{Group: "cluster.open-cluster-management.io", Version: "v1",
+			Resource: "managedclusters"}: "ManagedClusterList",
+		{Group: "apps.open-cluster-management.io", Version: "v1",
+			Resource: "channels"}: "ChannelList",
+	}
+	newResource := func(apiVersion, kind, namespace, name string, labeled bool) runtime.Object {
+		resource := &unstructured.Unstructured{}
+		resource.SetAPIVersion(apiVersion)
+		resource.SetKind(kind)
+		resource.SetNamespace(namespace)
+		resource.SetName(name)
+		if labeled {
+			resource.SetLabels(map[string]string{backupCredsUserLabel: "true"})
+		}
+		return resource
+	}
+	labelSelector := &metav1.LabelSelector{
+		MatchExpressions: []metav1.LabelSelectorRequirement{
+			{Key: backupCredsUserLabel, Operator: metav1.LabelSelectorOpExists},
+		},
+	}
+	includeClusterResources := false
+
+	tests := []struct {
+		name    string
+		spec    veleroapi.BackupSpec
+		objects []runtime.Object
+		want    []ResourceDiff
+	}{
+		{
+			name: "missing and extra kinds",
+			spec: veleroapi.BackupSpec{
+				IncludedResources:  []string{"secret", "configmap"},
+				ExcludedNamespaces: []string{"local-cluster"},
+				LabelSelector:      labelSelector,
+			},
+			objects: []runtime.Object{
+				newResource("v1", "Secret", "ns-1", "secret-1", true),
+				// not in the backup namespaces
+				newResource("v1", "ConfigMap", "local-cluster", "configmap-1", true),
+				// not matching the backup label selector
+				newResource("v1", "ConfigMap", "ns-1", "configmap-2", false),
+				newResource("apps.open-cluster-management.io/v1", "Channel", "ns-1", "channel-1", true),
+				newResource("apps.open-cluster-management.io/v1", "Channel", "ns-2", "channel-2", true),
+			},
+			want: []ResourceDiff{
+				{Resource: "channel.apps.open-cluster-management.io", InBackup: false, OnCluster: 2},
+				{Resource: "configmap", InBackup: true, OnCluster: 0},
+			},
+		},
+		{
+			name: "cluster resources not included",
+			spec: veleroapi.BackupSpec{
+				IncludedResources:       []string{"managedcluster.cluster.open-cluster-management.io"},
+				IncludeClusterResources: &includeClusterResources,
+			},
+			objects: []runtime.Object{
+				newResource("cluster.open-cluster-management.io/v1", "ManagedCluster", "", "cluster-1", false),
+			},
+			want: []ResourceDiff{
+				{Resource: "managedcluster.cluster.open-cluster-management.io", InBackup: true, OnCluster: 0},
+			},
+		},
+		{
+			name: "all resources included",
+			spec: veleroapi.BackupSpec{
+				LabelSelector: labelSelector,
+			},
+			objects: []runtime.Object{
+				newResource("apps.open-cluster-management.io/v1", "Channel", "ns-1", "channel-1", true),
+			},
+			want: []ResourceDiff{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			invalidateDiscoveryCache()
+			defer invalidateDiscoveryCache()
+
+			dyn := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+				listKinds, tt.objects...)
+			veleroBackup := &veleroapi.Backup{Spec: tt.spec}
+			got, err := CompareBackupToCluster(context.Background(), newDiscovery(), dyn, veleroBackup)
+			if err != nil {
+				t.Fatalf("CompareBackupToCluster() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CompareBackupToCluster() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// returns the resources by pages, the fake dynamic client doesn't return the continue token
+type pagedResourceClient struct {
+	dynamic.ResourceInterface
+	pages       []*unstructured.UnstructuredList
+	listOptions []metav1.ListOptions
+}
+
+func (c *pagedResourceClient) List(
+	ctx context.Context,
+	opts metav1.ListOptions,
+) (*unstructured.UnstructuredList, error) {
+	c.listOptions = append(c.listOptions, opts)
+	return c.pages[len(c.listOptions)-1], nil
+}
+
+func Test_countBackupResources(t *testing.T) {
+
+	newSecret := func(namespace, name string) unstructured.Unstructured {
+		secret := unstructured.Unstructured{}
+		secret.SetAPIVersion("v1")
+		secret.SetKind("Secret")
+		secret.SetNamespace(namespace)
+		secret.SetName(name)
+		return secret
+	}
+	resourceClient := &pagedResourceClient{
+		pages: []*unstructured.UnstructuredList{
+			{Items: []unstructured.Unstructured{
+				newSecret("ns-1", "secret-1"), newSecret("local-cluster", "secret-2")}},
+			{Items: []unstructured.Unstructured{
+				newSecret("ns-2", "secret-3"), newSecret("ns-2", "secret-4")}},
+			{Items: []unstructured.Unstructured{
+				newSecret("ns-3", "secret-5")}},
+		},
+	}
+	resourceClient.pages[0].SetContinue("page-2")
+	resourceClient.pages[1].SetContinue("page-3")
+
+	veleroBackup := &veleroapi.Backup{
+		Spec: veleroapi.BackupSpec{ExcludedNamespaces: []string{"local-cluster"}},
+	}
+	got, err := countBackupResources(context.Background(), resourceClient,
+		labels.SelectorFromSet(labels.Set{backupCredsUserLabel: "true"}), veleroBackup)
+	if err != nil {
+		t.Fatalf("countBackupResources() error = %v", err)
+	}
+	if got != 4 {
+		t.Errorf("countBackupResources() = %d, want 4", got)
+	}
+	wantOptions := []metav1.ListOptions{}
+	for _, continueToken := range []string{"", "page-2", "page-3"} {
+		wantOptions = append(wantOptions, metav1.ListOptions{
+			LabelSelector: backupCredsUserLabel + "=true",
+			Limit:         compareListPageSize,
+			Continue:      continueToken,
+		})
+	}
+	if !reflect.DeepEqual(resourceClient.listOptions, wantOptions) {
+		t.Errorf("countBackupResources() list options = %v, want %v",
+			resourceClient.listOptions, wantOptions)
+	}
+}
+
+func Test_isBackupClusterDiffSet(t *testing.T) {
+
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        bool
+	}{
+		{
+			name: "not compared",
+			want: false,
+		},
+		{
+			name:        "compared",
+			annotations: map[string]string{BackupClusterDiffAnnotation: "[]"},
+			want:        true,
+		},
+		{
+			name:        "comparison failed",
+			annotations: map[string]string{BackupClusterDiffErrorAnnotation: "discovery failed"},
+			want:        true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backup := &veleroapi.Backup{}
+			backup.SetAnnotations(tt.annotations)
+			if got := isBackupClusterDiffSet(backup); got != tt.want {
+				t.Errorf("isBackupClusterDiffSet() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
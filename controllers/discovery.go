@@ -0,0 +1,248 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	veleroapi "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/discovery"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// how long the server resources returned by the discovery client are reused
+const discoveryCacheTTL = time.Minute * 5
+
+// the velero resources used by the controllers, served once the velero CRDs are installed
+var veleroResources = []string{"backups", "backupstoragelocations", "restores", "schedules"}
+
+// the resources served for a group version
+type groupVersionResources struct {
+	group        v1.APIGroup
+	resourceList *v1.APIResourceList
+}
+
+type discoveryCacheEntry struct {
+	resources []groupVersionResources
+	expires   time.Time
+}
+
+// server resources for each discovery client, reused until the TTL expires
+// or a CRD is created or deleted
+var (
+	discoveryCache     = map[discovery.DiscoveryInterface]discoveryCacheEntry{}
+	discoveryCacheLock sync.Mutex
+)
+
+// how long a group version failing discovery is not logged again; broken aggregated APIs
+// fail on each discovery and would otherwise flood the logs
+const discoveryErrorLogTTL = time.Hour
+
+// the time each group version failing discovery was last logged
+var (
+	discoveryErrorsLogged     = map[string]time.Time{}
+	discoveryErrorsLoggedLock sync.Mutex
+)
+
+// returns the failed group versions not logged within the discoveryErrorLogTTL, sorted,
+// and records them as logged at the given time
+func getDiscoveryErrorsToLog(failedGroupVersions []string, now time.Time) []string {
+
+	discoveryErrorsLoggedLock.Lock()
+	defer discoveryErrorsLoggedLock.Unlock()
+
+	toLog := []string{}
+	for _, groupVersion := range failedGroupVersions {
+		if logged, ok := discoveryErrorsLogged[groupVersion]; ok && now.Sub(logged) < discoveryErrorLogTTL {
+			continue
+		}
+		discoveryErrorsLogged[groupVersion] = now
+		toLog = append(toLog, groupVersion)
+	}
+	sort.Strings(toLog)
+	return toLog
+}
+
+// logs a single summary of the group versions failing discovery; the group versions
+// already logged within the discoveryErrorLogTTL are only counted
+func logDiscoveryErrors(
+	ctx context.Context,
+	discoveryErrors map[string]string,
+	now time.Time,
+) {
+
+	if len(discoveryErrors) == 0 {
+		return
+	}
+	failedGroupVersions := make([]string, 0, len(discoveryErrors))
+	for groupVersion := range discoveryErrors {
+		failedGroupVersions = append(failedGroupVersions, groupVersion)
+	}
+	toLog := getDiscoveryErrorsToLog(failedGroupVersions, now)
+	if len(toLog) == 0 {
+		return
+	}
+	errs := make([]string, 0, len(toLog))
+	for _, groupVersion := range toLog {
+		errs = append(errs, groupVersion+": "+discoveryErrors[groupVersion])
+	}
+	log.FromContext(ctx).Info(
+		fmt.Sprintf("Failed to get server resources for %d group versions, "+
+			"the resources of these group versions are not backed up", len(discoveryErrors)),
+		"newErrors", len(toLog),
+		"alreadyReported", len(discoveryErrors)-len(toLog),
+		"errors", errs,
+	)
+}
+
+// returns the resources for all server group versions,
+// using the cached values if they are not expired
+func getServerGroupVersionResources(
+	ctx context.Context,
+	dc discovery.DiscoveryInterface,
+) ([]groupVersionResources, error) {
+
+	discoveryCacheLock.Lock()
+	defer discoveryCacheLock.Unlock()
+
+	if entry, ok := discoveryCache[dc]; ok && time.Now().Before(entry.expires) {
+		return entry.resources, nil
+	}
+
+	groupList, err := dc.ServerGroups()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get server groups: %v", err)
+	}
+
+	resources := []groupVersionResources{}
+	discoveryErrors := map[string]string{}
+	if groupList != nil {
+		for _, group := range groupList.Groups {
+			for _, version := range group.Versions {
+				//get all resources for each group version
+				resourceList, err := dc.ServerResourcesForGroupVersion(version.GroupVersion)
+				if err != nil {
+					discoveryErrorsTotal.WithLabelValues(group.Name).Inc()
+					discoveryErrors[version.GroupVersion] = err.Error()
+					continue
+				}
+				if resourceList == nil {
+					continue
+				}
+				resources = append(resources, groupVersionResources{
+					group:        group,
+					resourceList: resourceList,
+				})
+			}
+		}
+	}
+
+	logDiscoveryErrors(ctx, discoveryErrors, time.Now())
+
+	discoveryCache[dc] = discoveryCacheEntry{
+		resources: resources,
+		expires:   time.Now().Add(discoveryCacheTTL),
+	}
+	return resources, nil
+}
+
+// drop the cached server resources, called when a CRD is created or deleted
+func invalidateDiscoveryCache() {
+
+	discoveryCacheLock.Lock()
+	defer discoveryCacheLock.Unlock()
+
+	discoveryCache = map[discovery.DiscoveryInterface]discoveryCacheEntry{}
+}
+
+// returns the velero resources not served by the cluster, as resource.group names;
+// all the velero resources are returned if velero is not installed
+func getMissingVeleroResources(
+	ctx context.Context,
+	dc discovery.DiscoveryInterface,
+) ([]string, error) {
+
+	resources, err := getServerGroupVersionResources(ctx, dc)
+	if err != nil {
+		return nil, err
+	}
+
+	veleroAPIResources := []v1.APIResource{}
+	for i := range resources {
+		if resources[i].resourceList.GroupVersion == veleroapi.SchemeGroupVersion.String() {
+			veleroAPIResources = append(veleroAPIResources, resources[i].resourceList.APIResources...)
+		}
+	}
+	return getUnservedVeleroResources(veleroAPIResources), nil
+}
+
+// returns the velero resources not in the served velero group version resources,
+// as resource.group names
+func getUnservedVeleroResources(apiResources []v1.APIResource) []string {
+
+	served := map[string]bool{}
+	for _, resource := range apiResources {
+		served[resource.Name] = true
+	}
+
+	missing := []string{}
+	for _, resource := range veleroResources {
+		if !served[resource] {
+			missing = append(missing, resource+"."+veleroapi.SchemeGroupVersion.Group)
+		}
+	}
+	return missing
+}
+
+// returns true if the velero resources used by the controllers are served; only the velero
+// group version is discovered, without the discovery cache, so the install can be checked often
+func isVeleroServed(dc discovery.DiscoveryInterface) bool {
+
+	resourceList, err := dc.ServerResourcesForGroupVersion(veleroapi.SchemeGroupVersion.String())
+	if err != nil || resourceList == nil {
+		return false
+	}
+	return len(getUnservedVeleroResources(resourceList.APIResources)) == 0
+}
+
+// returns a message with the steps to install velero if the velero resources are not
+// served by the cluster, or an empty string if velero is installed; the velero install
+// is not checked if the server resources can't be discovered
+func getVeleroNotInstalledMessage(
+	ctx context.Context,
+	dc discovery.DiscoveryInterface,
+) string {
+
+	missing, err := getMissingVeleroResources(ctx, dc)
+	if err != nil {
+		log.FromContext(ctx).Info("unable to check the velero install: " + err.Error())
+		return ""
+	}
+	if len(missing) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("Velero is not installed, the %s resources are not found. "+
+		"Set the cluster-backup option to true on the MultiClusterHub resource to install the OADP operator, "+
+		"then create an oadp.openshift.io.DataProtectionApplication resource.",
+		strings.Join(missing, ", "))
+}
@@ -0,0 +1,269 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr/funcr"
+	veleroapi "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	fakeclientset "k8s.io/client-go/kubernetes/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// fake discovery client counting the server resources requests,
+// the requests for the failedGroupVersion return an error
+type countingDiscovery struct {
+	*fakediscovery.FakeDiscovery
+	resourcesCalls     int
+	failedGroupVersion string
+}
+
+func (c *countingDiscovery) ServerResourcesForGroupVersion(
+	groupVersion string,
+) (*metav1.APIResourceList, error) {
+	c.resourcesCalls++
+	if groupVersion == c.failedGroupVersion {
+		return nil, fmt.Errorf("the server is currently unable to handle the request")
+	}
+	return c.FakeDiscovery.ServerResourcesForGroupVersion(groupVersion)
+}
+
+func newCountingDiscovery(t testing.TB, groups int) *countingDiscovery {
+	fakeDiscovery, ok := fakeclientset.NewSimpleClientset().Discovery().(*fakediscovery.FakeDiscovery)
+	if !ok {
+		t.Fatalf("couldn't convert Discovery() to *FakeDiscovery")
+	}
+	for i := 0; i < groups; i++ {
+		fakeDiscovery.Resources = append(fakeDiscovery.Resources, &metav1.APIResourceList{
+			GroupVersion: fmt.Sprintf("group%d.open-cluster-management.io/v1", i),
+			APIResources: []metav1.APIResource{
+				{Name: "configs", Kind: "Config", Namespaced: true},
+				{Name: "policies", Kind: "Policy", Namespaced: true},
+			},
+		})
+	}
+	return &countingDiscovery{FakeDiscovery: fakeDiscovery}
+}
+
+func Test_getServerGroupVersionResources(t *testing.T) {
+
+	invalidateDiscoveryCache()
+	dc := newCountingDiscovery(t, 1)
+	veleroBackup := &veleroapi.Backup{}
+
+	for i := 0; i < 3; i++ {
+		resources, err := getGenericCRDFromAPIGroups(context.Background(), dc, veleroBackup, nil)
+		if err != nil {
+			t.Fatalf("getGenericCRDFromAPIGroups() error = %v", err)
+		}
+		if len(resources) != 2 {
+			t.Errorf("getGenericCRDFromAPIGroups() = %v, want 2 resources", resources)
+		}
+	}
+	if dc.resourcesCalls != 1 {
+		t.Errorf("ServerResourcesForGroupVersion() called %v times within the TTL, want 1",
+			dc.resourcesCalls)
+	}
+
+	// expired entries are discovered again
+	discoveryCacheLock.Lock()
+	entry := discoveryCache[dc]
+	entry.expires = time.Now().Add(-time.Second)
+	discoveryCache[dc] = entry
+	discoveryCacheLock.Unlock()
+	if _, err := getServerGroupVersionResources(context.Background(), dc); err != nil {
+		t.Fatalf("getServerGroupVersionResources() error = %v", err)
+	}
+	if dc.resourcesCalls != 2 {
+		t.Errorf("ServerResourcesForGroupVersion() called %v times after the TTL, want 2",
+			dc.resourcesCalls)
+	}
+
+	// a CRD event drops the cached resources
+	invalidateDiscoveryCache()
+	if _, err := getServerGroupVersionResources(context.Background(), dc); err != nil {
+		t.Fatalf("getServerGroupVersionResources() error = %v", err)
+	}
+	if dc.resourcesCalls != 3 {
+		t.Errorf("ServerResourcesForGroupVersion() called %v times after invalidation, want 3",
+			dc.resourcesCalls)
+	}
+}
+
+func Test_logDiscoveryErrors(t *testing.T) {
+
+	discoveryErrorsLoggedLock.Lock()
+	discoveryErrorsLogged = map[string]time.Time{}
+	discoveryErrorsLoggedLock.Unlock()
+
+	// count the log lines reporting the failed group version
+	failedGroupVersion := "group1.open-cluster-management.io/v1"
+	logged := 0
+	ctx := log.IntoContext(context.Background(), funcr.New(func(prefix, args string) {
+		if strings.Contains(args, failedGroupVersion) {
+			logged++
+		}
+	}, funcr.Options{}))
+
+	dc := newCountingDiscovery(t, 3)
+	dc.failedGroupVersion = failedGroupVersion
+	for i := 0; i < 3; i++ {
+		// discover the resources on each call, as if the cache expired
+		invalidateDiscoveryCache()
+		resources, err := getServerGroupVersionResources(ctx, dc)
+		if err != nil {
+			t.Fatalf("getServerGroupVersionResources() error = %v", err)
+		}
+		if len(resources) != 2 {
+			t.Errorf("getServerGroupVersionResources() = %v, want the 2 group versions not failing",
+				resources)
+		}
+	}
+	if logged != 1 {
+		t.Errorf("failed group version logged %v times, want 1", logged)
+	}
+
+	// logged again after the TTL
+	discoveryErrorsLoggedLock.Lock()
+	discoveryErrorsLogged[failedGroupVersion] = time.Now().Add(-discoveryErrorLogTTL)
+	discoveryErrorsLoggedLock.Unlock()
+	invalidateDiscoveryCache()
+	if _, err := getServerGroupVersionResources(ctx, dc); err != nil {
+		t.Fatalf("getServerGroupVersionResources() error = %v", err)
+	}
+	if logged != 2 {
+		t.Errorf("failed group version logged %v times after the TTL, want 2", logged)
+	}
+
+	// only the group versions not logged within the TTL are returned
+	now := time.Now()
+	want := []string{"a.io/v1", "b.io/v1"}
+	if got := getDiscoveryErrorsToLog([]string{"b.io/v1", "a.io/v1"}, now); !reflect.DeepEqual(got, want) {
+		t.Errorf("getDiscoveryErrorsToLog() = %v, want %v", got, want)
+	}
+	want = []string{"c.io/v1"}
+	if got := getDiscoveryErrorsToLog([]string{"a.io/v1", "c.io/v1"},
+		now.Add(time.Minute)); !reflect.DeepEqual(got, want) {
+		t.Errorf("getDiscoveryErrorsToLog() = %v, want %v", got, want)
+	}
+}
+
+func Test_getMissingVeleroResources(t *testing.T) {
+
+	veleroResources := func(names ...string) *metav1.APIResourceList {
+		resourceList := &metav1.APIResourceList{GroupVersion: veleroapi.SchemeGroupVersion.String()}
+		for _, name := range names {
+			resourceList.APIResources = append(resourceList.APIResources,
+				metav1.APIResource{Name: name, Namespaced: true})
+		}
+		return resourceList
+	}
+
+	tests := []struct {
+		name        string
+		veleroGroup *metav1.APIResourceList
+		want        []string
+		wantMsg     bool
+	}{
+		{
+			name:        "velero not installed",
+			veleroGroup: nil,
+			want: []string{
+				"backups.velero.io",
+				"backupstoragelocations.velero.io",
+				"restores.velero.io",
+				"schedules.velero.io",
+			},
+			wantMsg: true,
+		},
+		{
+			name:        "velero CRDs partially installed",
+			veleroGroup: veleroResources("backups", "backupstoragelocations"),
+			want:        []string{"restores.velero.io", "schedules.velero.io"},
+			wantMsg:     true,
+		},
+		{
+			name: "velero installed",
+			veleroGroup: veleroResources("backups", "backupstoragelocations",
+				"restores", "schedules", "deletebackuprequests"),
+			want:    []string{},
+			wantMsg: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			invalidateDiscoveryCache()
+			dc := newCountingDiscovery(t, 2)
+			if tt.veleroGroup != nil {
+				dc.Resources = append(dc.Resources, tt.veleroGroup)
+			}
+			got, err := getMissingVeleroResources(context.Background(), dc)
+			if err != nil {
+				t.Fatalf("getMissingVeleroResources() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getMissingVeleroResources() = %v, want %v", got, tt.want)
+			}
+			msg := getVeleroNotInstalledMessage(context.Background(), dc)
+			if (msg != "") != tt.wantMsg {
+				t.Errorf("getVeleroNotInstalledMessage() = %q, want a message %v", msg, tt.wantMsg)
+			}
+			if served := isVeleroServed(dc); served == tt.wantMsg {
+				t.Errorf("isVeleroServed() = %v, want %v", served, !tt.wantMsg)
+			}
+			for _, resource := range tt.want {
+				if !strings.Contains(msg, resource) {
+					t.Errorf("getVeleroNotInstalledMessage() = %q, want %s in the message", msg, resource)
+				}
+			}
+		})
+	}
+	invalidateDiscoveryCache()
+}
+
+func Benchmark_getGenericCRDFromAPIGroups(b *testing.B) {
+
+	veleroBackup := &veleroapi.Backup{}
+
+	b.Run("cached", func(b *testing.B) {
+		invalidateDiscoveryCache()
+		dc := newCountingDiscovery(b, 200)
+		for i := 0; i < b.N; i++ {
+			if _, err := getGenericCRDFromAPIGroups(context.Background(), dc, veleroBackup, nil); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("uncached", func(b *testing.B) {
+		dc := newCountingDiscovery(b, 200)
+		for i := 0; i < b.N; i++ {
+			invalidateDiscoveryCache()
+			if _, err := getGenericCRDFromAPIGroups(context.Background(), dc, veleroBackup, nil); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	v1beta1 "github.com/stolostron/cluster-backup-operator/api/v1beta1"
+	"k8s.io/apimachinery/pkg/api/meta"
+)
+
+// DRStatusPath is the path of the endpoint reporting the hub disaster recovery posture
+const DRStatusPath = "/drstatus"
+
+// DRStatusReport is the disaster recovery posture of the hub, returned as JSON by the DRStatusPath endpoint
+type DRStatusReport struct {
+	// BackupSchedule is the namespace/name of the last reconciled BackupSchedule
+	BackupSchedule string `json:"backupSchedule,omitempty"`
+	// Phase is the phase of the BackupSchedule
+	Phase v1beta1.SchedulePhase `json:"phase,omitempty"`
+	// BackupsCurrent is true if the BackupSchedule is enabled and
+	// the last finished backup of each backup type is completed
+	BackupsCurrent bool `json:"backupsCurrent"`
+	// ValidStorageLocation is true if an available storage location exists in the BackupSchedule namespace
+	ValidStorageLocation bool `json:"validStorageLocation"`
+	// LastSuccessfulBackups is the timestamp of the last completed backup of each backup type
+	LastSuccessfulBackups map[string]time.Time `json:"lastSuccessfulBackups,omitempty"`
+	// UpdateTime is the time the report was last updated
+	UpdateTime *time.Time `json:"updateTime,omitempty"`
+}
+
+// DRStatus keeps the disaster recovery posture of the hub, updated by the BackupScheduleReconciler,
+// and serves it as JSON
+type DRStatus struct {
+	lock   sync.RWMutex
+	report DRStatusReport
+}
+
+// NewDRStatus returns an empty disaster recovery posture, updated on the next BackupSchedule reconcile
+func NewDRStatus() *DRStatus {
+	return &DRStatus{}
+}
+
+// ServeHTTP returns the disaster recovery posture as JSON
+func (s *DRStatus) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+
+	if req.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	s.lock.RLock()
+	body, err := json.Marshal(s.report)
+	s.lock.RUnlock()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(body)
+}
+
+// update the disaster recovery posture with the backup schedule state
+func (s *DRStatus) update(
+	backupSchedule *v1beta1.BackupSchedule,
+	validStorageLocation bool,
+	now time.Time,
+) {
+
+	report := DRStatusReport{
+		BackupSchedule:       backupSchedule.Namespace + "/" + backupSchedule.Name,
+		Phase:                backupSchedule.Status.Phase,
+		BackupsCurrent:       areBackupsCurrent(backupSchedule),
+		ValidStorageLocation: validStorageLocation,
+		UpdateTime:           &now,
+	}
+	if len(backupSchedule.Status.LastSuccessfulBackups) > 0 {
+		report.LastSuccessfulBackups = make(map[string]time.Time,
+			len(backupSchedule.Status.LastSuccessfulBackups))
+		for _, lastBackup := range backupSchedule.Status.LastSuccessfulBackups {
+			report.LastSuccessfulBackups[lastBackup.BackupType] = lastBackup.LastSuccessfulTimestamp.UTC()
+		}
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.report = report
+}
+
+// returns true if the backup schedule is enabled and the last finished backup
+// of each backup type is completed, as shown by the <type>Backup conditions
+func areBackupsCurrent(backupSchedule *v1beta1.BackupSchedule) bool {
+
+	if backupSchedule.Status.Phase != v1beta1.SchedulePhaseEnabled {
+		return false
+	}
+	for _, conditionType := range backupTypeConditions {
+		if !meta.IsStatusConditionTrue(backupSchedule.Status.Conditions, conditionType) {
+			return false
+		}
+	}
+	return true
+}
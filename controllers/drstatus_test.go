@@ -0,0 +1,146 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	v1beta1 "github.com/stolostron/cluster-backup-operator/api/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_DRStatus_ServeHTTP(t *testing.T) {
+
+	backupTime := time.Date(2022, 4, 20, 12, 0, 0, 0, time.UTC)
+	updateTime := time.Date(2022, 4, 20, 12, 5, 0, 0, time.UTC)
+
+	enabledSchedule := &v1beta1.BackupSchedule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "schedule-acm",
+			Namespace: "velero-ns",
+		},
+		Status: v1beta1.BackupScheduleStatus{
+			Phase: v1beta1.SchedulePhaseEnabled,
+			LastSuccessfulBackups: []v1beta1.LastSuccessfulBackup{
+				{
+					BackupType:              string(Resources),
+					LastSuccessfulTimestamp: metav1.Time{Time: backupTime},
+					LastBackupName:          "acm-resources-schedule-20220420120000",
+				},
+				{
+					BackupType:              string(Credentials),
+					LastSuccessfulTimestamp: metav1.Time{Time: backupTime.Add(-time.Hour)},
+					LastBackupName:          "acm-credentials-schedule-20220420110000",
+				},
+			},
+		},
+	}
+	for _, conditionType := range backupTypeConditions {
+		enabledSchedule.Status.Conditions = append(enabledSchedule.Status.Conditions,
+			metav1.Condition{
+				Type:   conditionType,
+				Status: metav1.ConditionTrue,
+				Reason: v1beta1.BackupScheduleReasonBackupCompleted,
+			})
+	}
+	failedBackupSchedule := enabledSchedule.DeepCopy()
+	failedBackupSchedule.Status.Conditions[0].Status = metav1.ConditionFalse
+
+	tests := []struct {
+		name                 string
+		backupSchedule       *v1beta1.BackupSchedule
+		validStorageLocation bool
+		want                 DRStatusReport
+	}{
+		{
+			name: "no backup schedule reconciled",
+			want: DRStatusReport{},
+		},
+		{
+			name:                 "backups current",
+			backupSchedule:       enabledSchedule,
+			validStorageLocation: true,
+			want: DRStatusReport{
+				BackupSchedule:       "velero-ns/schedule-acm",
+				Phase:                v1beta1.SchedulePhaseEnabled,
+				BackupsCurrent:       true,
+				ValidStorageLocation: true,
+				LastSuccessfulBackups: map[string]time.Time{
+					string(Resources):   backupTime,
+					string(Credentials): backupTime.Add(-time.Hour),
+				},
+				UpdateTime: &updateTime,
+			},
+		},
+		{
+			name:                 "last backup failed",
+			backupSchedule:       failedBackupSchedule,
+			validStorageLocation: true,
+			want: DRStatusReport{
+				BackupSchedule:       "velero-ns/schedule-acm",
+				Phase:                v1beta1.SchedulePhaseEnabled,
+				BackupsCurrent:       false,
+				ValidStorageLocation: true,
+				LastSuccessfulBackups: map[string]time.Time{
+					string(Resources):   backupTime,
+					string(Credentials): backupTime.Add(-time.Hour),
+				},
+				UpdateTime: &updateTime,
+			},
+		},
+		{
+			name: "no valid storage location",
+			backupSchedule: &v1beta1.BackupSchedule{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "schedule-acm",
+					Namespace: "velero-ns",
+				},
+				Status: v1beta1.BackupScheduleStatus{
+					Phase: v1beta1.SchedulePhaseFailedValidation,
+				},
+			},
+			validStorageLocation: false,
+			want: DRStatusReport{
+				BackupSchedule: "velero-ns/schedule-acm",
+				Phase:          v1beta1.SchedulePhaseFailedValidation,
+				UpdateTime:     &updateTime,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			drStatus := NewDRStatus()
+			if tt.backupSchedule != nil {
+				drStatus.update(tt.backupSchedule, tt.validStorageLocation, updateTime)
+			}
+
+			recorder := httptest.NewRecorder()
+			drStatus.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, DRStatusPath, nil))
+			if recorder.Code != http.StatusOK {
+				t.Fatalf("ServeHTTP() status = %d, want %d", recorder.Code, http.StatusOK)
+			}
+			if contentType := recorder.Header().Get("Content-Type"); contentType != "application/json" {
+				t.Errorf("ServeHTTP() Content-Type = %s, want application/json", contentType)
+			}
+			got := DRStatusReport{}
+			if err := json.Unmarshal(recorder.Body.Bytes(), &got); err != nil {
+				t.Fatalf("ServeHTTP() returned invalid JSON: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ServeHTTP() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	// only GET requests are served
+	recorder := httptest.NewRecorder()
+	NewDRStatus().ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, DRStatusPath, nil))
+	if recorder.Code != http.StatusMethodNotAllowed {
+		t.Errorf("ServeHTTP() status = %d, want %d", recorder.Code, http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,136 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"time"
+
+	veleroapi "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// sets a property of the velero backup returned by newTestBackup
+type testBackupOption func(*veleroapi.Backup)
+
+// returns a velero backup with the name, in the velero-ns namespace,
+// updated by the options
+func newTestBackup(name string, opts ...testBackupOption) *veleroapi.Backup {
+	backup := &veleroapi.Backup{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "velero-ns",
+		},
+	}
+	for _, opt := range opts {
+		opt(backup)
+	}
+	return backup
+}
+
+// returns the resources backup followed by the backupSetTypes backups
+// of a backup set with the timestamp, each updated by the options
+func newTestBackupSet(timestamp string, opts ...testBackupOption) []veleroapi.Backup {
+	backups := []veleroapi.Backup{}
+	for _, backupType := range append([]ResourceType{Resources}, backupSetTypes...) {
+		backups = append(backups, *newTestBackup(veleroScheduleNames[backupType]+"-"+timestamp, opts...))
+	}
+	return backups
+}
+
+// sets the label on the backup
+func withBackupLabel(key, value string) testBackupOption {
+	return func(backup *veleroapi.Backup) {
+		if backup.Labels == nil {
+			backup.Labels = map[string]string{}
+		}
+		backup.Labels[key] = value
+	}
+}
+
+// sets the backup type label on the backup
+func withBackupType(backupType ResourceType) testBackupOption {
+	return withBackupLabel(BackupScheduleTypeLabel, string(backupType))
+}
+
+// sets the hub id label on the backup
+func withHubID(hubID string) testBackupOption {
+	return withBackupLabel(BackupScheduleClusterLabel, hubID)
+}
+
+// sets the backup schedule name label on the backup
+func withScheduleName(scheduleName string) testBackupOption {
+	return withBackupLabel(BackupScheduleNameLabel, scheduleName)
+}
+
+// sets the backup phase
+func withPhase(phase veleroapi.BackupPhase) testBackupOption {
+	return func(backup *veleroapi.Backup) {
+		backup.Status.Phase = phase
+	}
+}
+
+// sets the backup completion time
+func withCompletionTime(completed time.Time) testBackupOption {
+	return func(backup *veleroapi.Backup) {
+		backup.Status.CompletionTimestamp = &metav1.Time{Time: completed}
+	}
+}
+
+// sets the backup creation time
+func withCreationTime(created time.Time) testBackupOption {
+	return func(backup *veleroapi.Backup) {
+		backup.CreationTimestamp = metav1.NewTime(created)
+	}
+}
+
+// sets the storage location of the backup
+func withStorageLocation(storageLocation string) testBackupOption {
+	return func(backup *veleroapi.Backup) {
+		backup.Spec.StorageLocation = storageLocation
+	}
+}
+
+// sets the backup progress
+func withProgress(progress *veleroapi.BackupProgress) testBackupOption {
+	return func(backup *veleroapi.Backup) {
+		backup.Status.Progress = progress
+	}
+}
+
+// sets the number of items backed up, and to back up, in the backup progress
+func withItemsBackedUp(itemsBackedUp int) testBackupOption {
+	return withProgress(&veleroapi.BackupProgress{
+		TotalItems:    itemsBackedUp,
+		ItemsBackedUp: itemsBackedUp,
+	})
+}
+
+// sets the resources included in and excluded from the backup
+func withResources(includedResources, excludedResources []string) testBackupOption {
+	return func(backup *veleroapi.Backup) {
+		backup.Spec.IncludedResources = includedResources
+		backup.Spec.ExcludedResources = excludedResources
+	}
+}
+
+// sets the backup uid
+func withUID(uid types.UID) testBackupOption {
+	return func(backup *veleroapi.Backup) {
+		backup.UID = uid
+	}
+}
@@ -0,0 +1,137 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	veleroapi "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// the backup metrics are served by the controller manager metrics endpoint
+var (
+	backupSuccessTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "acm_backup_success_total",
+			Help: "Number of Velero backups completed successfully",
+		},
+		[]string{"schedule", "type"},
+	)
+	backupFailureTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "acm_backup_failure_total",
+			Help: "Number of Velero backups failed or partially failed",
+		},
+		[]string{"schedule", "type"},
+	)
+	lastSuccessfulBackupTimestamp = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "acm_backup_last_success_timestamp_seconds",
+			Help: "Completion time of the last Velero backup completed successfully",
+		},
+		[]string{"schedule", "type"},
+	)
+	discoveryDurationSeconds = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "acm_backup_discovery_duration_seconds",
+			Help:    "Duration of the discovery of the API groups resources backed up by the generic backup",
+			Buckets: prometheus.DefBuckets,
+		},
+	)
+	discoveryErrorsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "acm_backup_discovery_errors_total",
+			Help: "Number of failed requests for the server resources of an API group",
+		},
+		[]string{"group"},
+	)
+)
+
+// names of the finished backups already counted, with the backup schedule name
+var (
+	countedBackups     = map[string]string{}
+	countedBackupsLock sync.Mutex
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		backupSuccessTotal,
+		backupFailureTotal,
+		lastSuccessfulBackupTimestamp,
+		discoveryDurationSeconds,
+		discoveryErrorsTotal,
+	)
+}
+
+// record the duration of a discovery started at the start time
+func observeDiscoveryDuration(start time.Time) {
+	discoveryDurationSeconds.Observe(time.Since(start).Seconds())
+}
+
+// update the backup metrics with the backups created by the backup schedule;
+// each finished backup is counted once
+func recordBackupMetrics(backupScheduleName string, backups []veleroapi.Backup) {
+
+	countedBackupsLock.Lock()
+	defer countedBackupsLock.Unlock()
+
+	listed := map[string]bool{}
+	lastSuccess := map[string]float64{}
+	for i := range backups {
+		backup := &backups[i]
+		listed[backup.Name] = true
+		backupType := backup.GetLabels()[BackupScheduleTypeLabel]
+
+		var counter *prometheus.CounterVec
+		switch backup.Status.Phase {
+		case veleroapi.BackupPhaseCompleted:
+			counter = backupSuccessTotal
+			if backup.Status.CompletionTimestamp != nil {
+				completion := float64(backup.Status.CompletionTimestamp.Unix())
+				if completion > lastSuccess[backupType] {
+					lastSuccess[backupType] = completion
+				}
+			}
+		case veleroapi.BackupPhaseFailed,
+			veleroapi.BackupPhasePartiallyFailed,
+			veleroapi.BackupPhaseFailedValidation:
+			counter = backupFailureTotal
+		default:
+			// backup not finished yet
+			continue
+		}
+
+		if _, ok := countedBackups[backup.Name]; !ok {
+			counter.WithLabelValues(backupScheduleName, backupType).Inc()
+			countedBackups[backup.Name] = backupScheduleName
+		}
+	}
+
+	for backupType, completion := range lastSuccess {
+		lastSuccessfulBackupTimestamp.WithLabelValues(backupScheduleName, backupType).Set(completion)
+	}
+
+	// forget the backups deleted from the cluster
+	for name, scheduleName := range countedBackups {
+		if scheduleName == backupScheduleName && !listed[name] {
+			delete(countedBackups, name)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	veleroapi "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+)
+
+func Test_recordBackupMetrics(t *testing.T) {
+	scheduleName := "schedule-metrics"
+	completionTime := time.Date(2022, 4, 20, 12, 0, 0, 0, time.UTC)
+
+	completedBackup := *newTestBackup(
+		"acm-resources-schedule-20220420120000",
+		withScheduleName(scheduleName),
+		withBackupType(Resources),
+		withPhase(veleroapi.BackupPhaseCompleted),
+		withCompletionTime(completionTime),
+	)
+	failedBackup := *newTestBackup(
+		"acm-credentials-schedule-20220420120000",
+		withScheduleName(scheduleName),
+		withBackupType(Credentials),
+		withPhase(veleroapi.BackupPhaseFailed),
+	)
+	runningBackup := *newTestBackup(
+		"acm-managed-clusters-schedule-20220420120000",
+		withScheduleName(scheduleName),
+		withBackupType(ManagedClusters),
+		withPhase(veleroapi.BackupPhaseInProgress),
+	)
+
+	// the finished backups are counted once, even if they are listed again
+	recordBackupMetrics(scheduleName, []veleroapi.Backup{completedBackup, runningBackup})
+	recordBackupMetrics(scheduleName, []veleroapi.Backup{completedBackup, failedBackup, runningBackup})
+
+	tests := []struct {
+		name   string
+		metric float64
+		want   float64
+	}{
+		{
+			name:   "completed resources backup",
+			metric: testutil.ToFloat64(backupSuccessTotal.WithLabelValues(scheduleName, string(Resources))),
+			want:   1,
+		},
+		{
+			name:   "no failed resources backup",
+			metric: testutil.ToFloat64(backupFailureTotal.WithLabelValues(scheduleName, string(Resources))),
+			want:   0,
+		},
+		{
+			name:   "failed credentials backup",
+			metric: testutil.ToFloat64(backupFailureTotal.WithLabelValues(scheduleName, string(Credentials))),
+			want:   1,
+		},
+		{
+			name: "running managed clusters backup not counted",
+			metric: testutil.ToFloat64(backupSuccessTotal.WithLabelValues(scheduleName,
+				string(ManagedClusters))) + testutil.ToFloat64(
+				backupFailureTotal.WithLabelValues(scheduleName, string(ManagedClusters))),
+			want: 0,
+		},
+		{
+			name: "last successful resources backup",
+			metric: testutil.ToFloat64(lastSuccessfulBackupTimestamp.WithLabelValues(scheduleName,
+				string(Resources))),
+			want: float64(completionTime.Unix()),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.metric != tt.want {
+				t.Errorf("recordBackupMetrics() metric = %v, want %v", tt.metric, tt.want)
+			}
+		})
+	}
+
+	// the deleted backups are no longer tracked
+	recordBackupMetrics(scheduleName, []veleroapi.Backup{failedBackup})
+	if _, ok := countedBackups[completedBackup.Name]; ok {
+		t.Errorf("recordBackupMetrics() backup %s still tracked after deletion", completedBackup.Name)
+	}
+}
+
+func Test_discoveryMetrics(t *testing.T) {
+
+	getDiscoveryCount := func() uint64 {
+		metric := &dto.Metric{}
+		if err := discoveryDurationSeconds.Write(metric); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+		return metric.GetHistogram().GetSampleCount()
+	}
+
+	invalidateDiscoveryCache()
+	dc := newCountingDiscovery(t, 2)
+	dc.failedGroupVersion = "group1.open-cluster-management.io/v1"
+	failedGroup := "group1.open-cluster-management.io"
+
+	count := getDiscoveryCount()
+	discoveryErrors := testutil.ToFloat64(discoveryErrorsTotal.WithLabelValues(failedGroup))
+
+	resources, err := getGenericCRDFromAPIGroups(context.Background(), dc, &veleroapi.Backup{}, nil)
+	if err != nil {
+		t.Fatalf("getGenericCRDFromAPIGroups() error = %v", err)
+	}
+	if len(resources) != 2 {
+		t.Errorf("getGenericCRDFromAPIGroups() = %v, want the 2 resources of the available group", resources)
+	}
+	if got := getDiscoveryCount(); got != count+1 {
+		t.Errorf("acm_backup_discovery_duration_seconds count = %v, want %v", got, count+1)
+	}
+	if got := testutil.ToFloat64(discoveryErrorsTotal.WithLabelValues(failedGroup)); got != discoveryErrors+1 {
+		t.Errorf("acm_backup_discovery_errors_total{group=%q} = %v, want %v", failedGroup, got, discoveryErrors+1)
+	}
+	invalidateDiscoveryCache()
+}
@@ -19,11 +19,14 @@ package controllers
 import (
 	"context"
 	"fmt"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/go-logr/logr"
 	v1beta1 "github.com/stolostron/cluster-backup-operator/api/v1beta1"
 	veleroapi "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+	"github.com/vmware-tanzu/velero/pkg/label"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
@@ -31,7 +34,9 @@ import (
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/client-go/dynamic"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 )
@@ -60,41 +65,1339 @@ func isVeleroRestoreRunning(restore *veleroapi.Restore) bool {
 	return false
 }
 
+// returns the names of the velero restores not finished yet, sorted by name
+func getUnfinishedVeleroRestores(veleroRestores []veleroapi.Restore) []string {
+
+	names := []string{}
+	for i := range veleroRestores {
+		if !isVeleroRestoreFinished(&veleroRestores[i]) {
+			names = append(names, veleroRestores[i].Name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
 func isValidSyncOptions(restore *v1beta1.Restore) (bool, string) {
 
-	if !restore.Spec.SyncRestoreWithNewBackups {
-		return false, ""
+	if !restore.Spec.SyncRestoreWithNewBackups {
+		return false, ""
+	}
+
+	if restore.Spec.VeleroManagedClustersBackupName == nil ||
+		restore.Spec.VeleroCredentialsBackupName == nil ||
+		restore.Spec.VeleroResourcesBackupName == nil {
+		return false, "Some Velero backup names are not set."
+	}
+
+	backupName := ""
+
+	backupName = *restore.Spec.VeleroManagedClustersBackupName
+	backupName = strings.ToLower(strings.TrimSpace(backupName))
+
+	if backupName != skipRestoreStr && backupName != latestBackupStr {
+		return false, "VeleroManagedClustersBackupName should be set to skip or latest."
+	}
+
+	backupName = *restore.Spec.VeleroCredentialsBackupName
+	backupName = strings.ToLower(strings.TrimSpace(backupName))
+
+	if backupName != latestBackupStr {
+		return false, "VeleroCredentialsBackupName should be set to latest."
+	}
+
+	backupName = *restore.Spec.VeleroResourcesBackupName
+	backupName = strings.ToLower(strings.TrimSpace(backupName))
+
+	if backupName != latestBackupStr {
+		return false, "VeleroResourcesBackupName should be set to latest."
+	}
+
+	if restore.Spec.RestoreToTime != nil {
+		return false, "RestoreToTime should not be set."
+	}
+	return true, ""
+}
+
+// validate the label selector set on the restore resource, if any
+func validateRestoreLabelSelector(restore *v1beta1.Restore) error {
+
+	if restore.Spec.RestoreLabelSelector == nil {
+		return nil
+	}
+
+	if _, err := v1.LabelSelectorAsSelector(restore.Spec.RestoreLabelSelector); err != nil {
+		return fmt.Errorf("invalid RestoreLabelSelector: %v", err)
+	}
+	return nil
+}
+
+// validate the source schedule name set on the restore resource, if any
+func validateSourceScheduleName(restore *v1beta1.Restore) error {
+
+	if restore.Spec.SourceScheduleName == "" {
+		return nil
+	}
+
+	if errs := validation.IsDNS1123Subdomain(restore.Spec.SourceScheduleName); len(errs) > 0 {
+		return fmt.Errorf("invalid SourceScheduleName %s: %s",
+			restore.Spec.SourceScheduleName, strings.Join(errs, ","))
+	}
+	return nil
+}
+
+// validate the namespace mapping set by the restore resource, if any
+func validateNamespaceMapping(restore *v1beta1.Restore) error {
+
+	for source, target := range restore.Spec.NamespaceMapping {
+		if errs := validation.IsDNS1123Label(source); len(errs) > 0 {
+			return fmt.Errorf("invalid NamespaceMapping namespace %s: %s",
+				source, strings.Join(errs, ","))
+		}
+		if errs := validation.IsDNS1123Label(target); len(errs) > 0 {
+			return fmt.Errorf("invalid NamespaceMapping namespace %s mapped from %s: %s",
+				target, source, strings.Join(errs, ","))
+		}
+	}
+	return nil
+}
+
+// backup types which can be restored, the validation backup is not restored
+var restorableBackupTypes = []string{
+	string(Credentials),
+	string(CredentialsHive),
+	string(CredentialsCluster),
+	string(Resources),
+	string(ResourcesGeneric),
+	string(NamespaceContents),
+	string(ManagedClusters),
+}
+
+// validate the backup types restored by the restore resource, if any
+func validateIncludedBackupTypes(restore *v1beta1.Restore) error {
+
+	for _, backupType := range restore.Spec.IncludedBackupTypes {
+		if !findValue(restorableBackupTypes, backupType) {
+			return fmt.Errorf("invalid IncludedBackupTypes value %s, valid values are %s",
+				backupType, strings.Join(restorableBackupTypes, ", "))
+		}
+	}
+	return nil
+}
+
+// returns true if the backup type is restored by the restore resource
+func isBackupTypeIncluded(restore *v1beta1.Restore, backupType ResourceType) bool {
+
+	return len(restore.Spec.IncludedBackupTypes) == 0 ||
+		findValue(restore.Spec.IncludedBackupTypes, string(backupType))
+}
+
+// returns the most recent Completed backup created at or before the restore time,
+// using the timestamp in the backup name; returns nil if there is no such backup
+func findBackupBeforeTime(backups []veleroapi.Backup, restoreToTime time.Time) *veleroapi.Backup {
+
+	candidates := []*veleroapi.Backup{}
+	timestamps := map[string]time.Time{}
+	for i := range backups {
+		backup := &backups[i]
+		if backup.Status.Phase != veleroapi.BackupPhaseCompleted {
+			continue
+		}
+		timestamp, err := getBackupTimestamp(backup.Name)
+		if err != nil || timestamp.After(restoreToTime) {
+			continue
+		}
+		timestamps[backup.Name] = timestamp
+		candidates = append(candidates, backup)
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return timestamps[candidates[i].Name].After(timestamps[candidates[j].Name])
+	})
+	return candidates[0]
+}
+
+// returns true if the restore doesn't select the backups by name or by time,
+// each backup name is not set or set to latest or skip
+func isLatestBackupRestore(restore *v1beta1.Restore) bool {
+
+	if restore.Spec.RestoreToTime != nil {
+		return false
+	}
+	for _, backupName := range []*string{
+		restore.Spec.VeleroManagedClustersBackupName,
+		restore.Spec.VeleroCredentialsBackupName,
+		restore.Spec.VeleroResourcesBackupName,
+	} {
+		if backupName == nil {
+			continue
+		}
+		name := strings.ToLower(strings.TrimSpace(*backupName))
+		if name != latestBackupStr && name != skipRestoreStr {
+			return false
+		}
+	}
+	return true
+}
+
+// classifies the resources, named resource.group as in the backup IncludedResources,
+// as namespaced or cluster-scoped using the RESTMapper; the resources not known on
+// this hub are ignored
+func getResourcesScope(
+	mapper meta.RESTMapper,
+	resources []string,
+) ([]string, []string) {
+
+	namespaced := []string{}
+	clusterScoped := []string{}
+	for _, resource := range resources {
+		name, group := getResourceDetails(resource)
+		gvk, err := mapper.KindFor(schema.GroupVersionResource{
+			Group:    group,
+			Resource: name,
+		})
+		if err != nil {
+			continue
+		}
+		mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		if err != nil {
+			continue
+		}
+		if mapping.Scope.Name() == meta.RESTScopeNameRoot {
+			clusterScoped = appendUnique(clusterScoped, resource)
+		} else {
+			namespaced = appendUnique(namespaced, resource)
+		}
+	}
+	sort.Strings(namespaced)
+	sort.Strings(clusterScoped)
+	return namespaced, clusterScoped
+}
+
+// returns the cluster-scoped resources backed up by the restored backups,
+// when the restore doesn't include the cluster-scoped resources
+func getExcludedClusterResources(
+	mapper meta.RESTMapper,
+	restore *v1beta1.Restore,
+	backups map[ResourceType]*veleroapi.Backup,
+) []string {
+
+	if restore.Spec.IncludeClusterResources == nil || *restore.Spec.IncludeClusterResources {
+		return nil
+	}
+	resources := []string{}
+	for _, backup := range backups {
+		if backup != nil {
+			resources = append(resources, backup.Spec.IncludedResources...)
+		}
+	}
+	_, clusterScoped := getResourcesScope(mapper, resources)
+	if len(clusterScoped) == 0 {
+		return nil
+	}
+	return clusterScoped
+}
+
+// cluster-scoped RBAC kinds which can be selected for the generic resources restore,
+// see IncludedClusterRBACResources
+var clusterRBACKinds = []schema.GroupKind{
+	{Group: "rbac.authorization.k8s.io", Kind: "ClusterRole"},
+	{Group: "rbac.authorization.k8s.io", Kind: "ClusterRoleBinding"},
+}
+
+// resolves the IncludedClusterRBACResources entries, named kind.group, using the RESTMapper;
+// returns the curated cluster-scoped RBAC kinds not selected, in the kind.group format used by
+// the Velero ExcludedResources, or an error if an entry is not a curated cluster-scoped RBAC kind.
+// No kind is returned if IncludedClusterRBACResources is not set
+func resolveClusterRBACResources(
+	mapper meta.RESTMapper,
+	restore *v1beta1.Restore,
+) ([]string, error) {
+
+	if len(restore.Spec.IncludedClusterRBACResources) == 0 {
+		return nil, nil
+	}
+	if restore.Spec.IncludeClusterResources != nil && !*restore.Spec.IncludeClusterResources {
+		return nil, fmt.Errorf(
+			"IncludedClusterRBACResources cannot be set when IncludeClusterResources is false")
+	}
+	selected := map[schema.GroupKind]bool{}
+	invalid := []string{}
+	for _, entry := range restore.Spec.IncludedClusterRBACResources {
+		name, group := getResourceDetails(strings.ToLower(strings.TrimSpace(entry)))
+		gvk, err := mapper.KindFor(schema.GroupVersionResource{
+			Group:    group,
+			Resource: name,
+		})
+		if err != nil {
+			invalid = append(invalid, entry)
+			continue
+		}
+		if !isClusterRBACKind(gvk.GroupKind()) {
+			invalid = append(invalid, entry)
+			continue
+		}
+		mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		if err != nil || mapping.Scope.Name() != meta.RESTScopeNameRoot {
+			invalid = append(invalid, entry)
+			continue
+		}
+		selected[gvk.GroupKind()] = true
+	}
+	if len(invalid) > 0 {
+		validKinds := make([]string, 0, len(clusterRBACKinds))
+		for _, groupKind := range clusterRBACKinds {
+			validKinds = append(validKinds, strings.ToLower(groupKind.Kind)+"."+groupKind.Group)
+		}
+		return nil, fmt.Errorf("invalid IncludedClusterRBACResources values %s, valid values are %s",
+			strings.Join(invalid, ", "), strings.Join(validKinds, ", "))
+	}
+	excluded := []string{}
+	for _, groupKind := range clusterRBACKinds {
+		if !selected[groupKind] {
+			excluded = append(excluded, strings.ToLower(groupKind.Kind)+"."+groupKind.Group)
+		}
+	}
+	return excluded, nil
+}
+
+// returns true if the kind is one of the curated cluster-scoped RBAC kinds
+func isClusterRBACKind(groupKind schema.GroupKind) bool {
+
+	for _, rbacKind := range clusterRBACKinds {
+		if rbacKind == groupKind {
+			return true
+		}
+	}
+	return false
+}
+
+// returns the resources not restored by the Velero restore of this backup type: the generic
+// resources backup, which backs up the resources with the backup label, cluster-scoped RBAC
+// included, restores only the RBAC kinds selected by IncludedClusterRBACResources;
+// the managed clusters backup doesn't restore the placement decisions, which are restored
+// once the managed clusters are restored, see createPlacementDecisionsRestore;
+// returns nil, to restore all the backed up resources, for the other backup types
+func getRestoreExcludedResources(
+	backupType ResourceType,
+	excludedRBACResources []string,
+) []string {
+
+	if backupType == ManagedClusters {
+		return []string{placementDecisionResource}
+	}
+	if backupType != ResourcesGeneric || len(excludedRBACResources) == 0 {
+		return nil
+	}
+	return append([]string{}, excludedRBACResources...)
+}
+
+// returns the most recent complete backup sets, at most maxSets, most recent first,
+// see getCompleteBackupSets
+func getLatestBackupSets(backups []veleroapi.Backup, maxSets int) []v1beta1.RestorableBackupSet {
+
+	latestSets := getCompleteBackupSets(backups)
+	if len(latestSets) > maxSets {
+		latestSets = latestSets[:maxSets]
+	}
+	return latestSets
+}
+
+// returns an error if the backup can't be restored: the backup must be completed,
+// with items backed up, and its storage location must be available
+func validateRestoreBackup(
+	backup *veleroapi.Backup,
+	storageLocations []veleroapi.BackupStorageLocation,
+) error {
+
+	if backup.Status.Phase != veleroapi.BackupPhaseCompleted {
+		return fmt.Errorf("backup %s is in phase %s, only %s backups are restored",
+			backup.Name, backup.Status.Phase, veleroapi.BackupPhaseCompleted)
+	}
+
+	if backup.Spec.StorageLocation == "" {
+		// velero sets the storage location, nothing to check
+		return nil
+	}
+	for i := range storageLocations {
+		storageLocation := &storageLocations[i]
+		if storageLocation.Name != backup.Spec.StorageLocation ||
+			storageLocation.Namespace != backup.Namespace {
+			continue
+		}
+		if storageLocation.Status.Phase != veleroapi.BackupStorageLocationPhaseAvailable {
+			return fmt.Errorf("storage location %s of backup %s is not available",
+				storageLocation.Name, backup.Name)
+		}
+		return nil
+	}
+	return fmt.Errorf("storage location %s of backup %s not found",
+		backup.Spec.StorageLocation, backup.Name)
+}
+
+// validates the backups used by the velero restores, in the backup type order;
+// returns the first validation error
+func validateRestoreBackups(
+	backups map[ResourceType]*veleroapi.Backup,
+	storageLocations []veleroapi.BackupStorageLocation,
+) error {
+
+	for _, backupType := range restorableBackupTypes {
+		backup, ok := backups[ResourceType(backupType)]
+		if !ok || backup == nil {
+			continue
+		}
+		if err := validateRestoreBackup(backup, storageLocations); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sets the BackupInvalid condition from the restore backups validation error, if any
+func setBackupInvalidCondition(restore *v1beta1.Restore, err error) {
+
+	condition := v1.Condition{
+		Type:               v1beta1.RestoreBackupInvalid,
+		Status:             v1.ConditionFalse,
+		Reason:             v1beta1.RestoreReasonBackupValid,
+		ObservedGeneration: restore.Generation,
+	}
+	if err != nil {
+		condition.Status = v1.ConditionTrue
+		condition.Reason = v1beta1.RestoreReasonBackupInvalid
+		condition.Message = err.Error()
+	}
+	meta.SetStatusCondition(&restore.Status.Conditions, condition)
+}
+
+// sameHubRestoreError is returned when the backups selected for the restore were created on
+// this hub and the restore doesn't allow it; the restore is not retried
+type sameHubRestoreError struct {
+	error
+}
+
+// returns a sameHubRestoreError if a backup selected for the restore was created on this hub,
+// unless the restore allows restoring this hub backups; sets the SameHubRestore condition.
+// Nothing is checked if the hub uid is unknown
+func checkSameHubRestore(
+	restore *v1beta1.Restore,
+	backups map[ResourceType]*veleroapi.Backup,
+	hubID string,
+) error {
+
+	if hubID == "" || hubID == unknownHubID {
+		return nil
+	}
+
+	sameHubBackups := []string{}
+	for _, backupType := range restorableBackupTypes {
+		backup, ok := backups[ResourceType(backupType)]
+		if !ok || backup == nil {
+			continue
+		}
+		if backup.GetLabels()[BackupScheduleClusterLabel] == hubID {
+			sameHubBackups = append(sameHubBackups, backup.Name)
+		}
+	}
+
+	condition := v1.Condition{
+		Type:               v1beta1.RestoreSameHubRestore,
+		Status:             v1.ConditionFalse,
+		Reason:             v1beta1.RestoreReasonOtherHubBackups,
+		ObservedGeneration: restore.Generation,
+	}
+	if len(sameHubBackups) == 0 {
+		meta.SetStatusCondition(&restore.Status.Conditions, condition)
+		return nil
+	}
+
+	condition.Status = v1.ConditionTrue
+	condition.Message = fmt.Sprintf("backups %s were created on this hub %s",
+		strings.Join(sameHubBackups, ", "), hubID)
+	if restore.Spec.AllowSelfRestore {
+		condition.Reason = v1beta1.RestoreReasonSameHubAllowed
+		meta.SetStatusCondition(&restore.Status.Conditions, condition)
+		return nil
+	}
+	condition.Reason = v1beta1.RestoreReasonSameHubBlocked
+	meta.SetStatusCondition(&restore.Status.Conditions, condition)
+	return &sameHubRestoreError{fmt.Errorf("restore blocked, %s; set allowSelfRestore to restore them",
+		condition.Message)}
+}
+
+// validate the wait conditions set on the restore resource, if any
+func validateWaitConditions(restore *v1beta1.Restore) error {
+
+	for _, condition := range restore.Spec.WaitConditions {
+		if strings.TrimSpace(condition.Kind) == "" {
+			return fmt.Errorf("invalid WaitConditions: kind is not set")
+		}
+		if strings.TrimSpace(condition.ConditionType) == "" {
+			return fmt.Errorf("invalid WaitConditions: conditionType is not set for kind %s",
+				condition.Kind)
+		}
+		switch condition.ConditionStatus {
+		case "", v1.ConditionTrue, v1.ConditionFalse, v1.ConditionUnknown:
+		default:
+			return fmt.Errorf("invalid WaitConditions: conditionStatus %s is not valid for kind %s",
+				condition.ConditionStatus, condition.Kind)
+		}
+	}
+	return nil
+}
+
+// validate the finalizers removal rules set on the restore resource, if any
+func validateRemoveFinalizers(restore *v1beta1.Restore) error {
+
+	for _, rule := range restore.Spec.RemoveFinalizers {
+		if strings.TrimSpace(rule.Kind) == "" {
+			return fmt.Errorf("invalid RemoveFinalizers: kind is not set")
+		}
+		if len(rule.Finalizers) == 0 {
+			return fmt.Errorf("invalid RemoveFinalizers: finalizers are not set for kind %s",
+				rule.Kind)
+		}
+	}
+	return nil
+}
+
+// removes the finalizers from the resource finalizers list
+// returns the finalizers found and removed from the resource
+func removeFinalizers(
+	resource *unstructured.Unstructured,
+	finalizers []string,
+) []string {
+
+	removed := []string{}
+	var kept []string
+	for _, finalizer := range resource.GetFinalizers() {
+		if findValue(finalizers, finalizer) {
+			removed = append(removed, finalizer)
+		} else {
+			kept = append(kept, finalizer)
+		}
+	}
+	if len(removed) > 0 {
+		resource.SetFinalizers(kept)
+	}
+	return removed
+}
+
+// removes the finalizers defined by the RemoveFinalizers rules from the resources
+// restored by the completed velero restores
+// returns, for each kind and finalizer, the number of resources updated
+func (r *RestoreReconciler) removeRestoredFinalizers(
+	ctx context.Context,
+	acmRestore *v1beta1.Restore,
+	veleroRestoreList *veleroapi.RestoreList,
+) ([]string, error) {
+
+	restoreNames := []string{}
+	for i := range veleroRestoreList.Items {
+		if isVeleroRestoreFinished(&veleroRestoreList.Items[i]) {
+			restoreNames = append(restoreNames, veleroRestoreList.Items[i].Name)
+		}
+	}
+	if len(restoreNames) == 0 {
+		return nil, nil
+	}
+	listOptions := v1.ListOptions{
+		LabelSelector: getRestoreNameSelector(restoreNames...),
+	}
+
+	msgs := []string{}
+	for _, rule := range acmRestore.Spec.RemoveFinalizers {
+		kind, group := getResourceDetails(strings.ToLower(rule.Kind))
+		gvr, err := r.RESTMapper.ResourceFor(schema.GroupVersionResource{
+			Group:    group,
+			Resource: kind,
+		})
+		if err != nil {
+			return msgs, fmt.Errorf("failed to get resource for kind %s: %v", rule.Kind, err)
+		}
+
+		resources, err := r.DynamicClient.Resource(gvr).List(ctx, listOptions)
+		if err != nil {
+			return msgs, fmt.Errorf("failed to list %s: %v", rule.Kind, err)
+		}
+		counts := map[string]int{}
+		for i := range resources.Items {
+			resource := resources.Items[i]
+			removed := removeFinalizers(&resource, rule.Finalizers)
+			if len(removed) == 0 {
+				continue
+			}
+			var updateErr error
+			if resource.GetNamespace() != "" {
+				_, updateErr = r.DynamicClient.Resource(gvr).Namespace(resource.GetNamespace()).
+					Update(ctx, &resource, v1.UpdateOptions{})
+			} else {
+				_, updateErr = r.DynamicClient.Resource(gvr).Update(ctx, &resource, v1.UpdateOptions{})
+			}
+			if updateErr != nil {
+				return msgs, fmt.Errorf("failed to remove finalizers from %s %s: %v",
+					rule.Kind, resource.GetName(), updateErr)
+			}
+			for _, finalizer := range removed {
+				counts[finalizer]++
+			}
+		}
+		for _, finalizer := range rule.Finalizers {
+			if counts[finalizer] > 0 {
+				msgs = append(msgs, fmt.Sprintf("%s removed from %d %s resources",
+					finalizer, counts[finalizer], rule.Kind))
+			}
+		}
+	}
+	return msgs, nil
+}
+
+// returns true if the resource has a status condition
+// with the given type and status
+func isConditionMet(
+	resource unstructured.Unstructured,
+	conditionType string,
+	conditionStatus v1.ConditionStatus,
+) bool {
+
+	if conditionStatus == "" {
+		conditionStatus = v1.ConditionTrue
+	}
+
+	conditions, found, err := unstructured.NestedSlice(resource.Object, "status", "conditions")
+	if err != nil || !found {
+		return false
+	}
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if condition["type"] == conditionType {
+			return condition["status"] == string(conditionStatus)
+		}
+	}
+	return false
+}
+
+// returns the message for the first restored resource not matching
+// the wait conditions set on the restore resource
+// or an empty string if all conditions are met
+func (r *RestoreReconciler) getUnmetWaitCondition(
+	ctx context.Context,
+	acmRestore *v1beta1.Restore,
+	veleroRestoreList *veleroapi.RestoreList,
+) (string, error) {
+
+	restoreNames := []string{}
+	for i := range veleroRestoreList.Items {
+		restoreNames = append(restoreNames, veleroRestoreList.Items[i].Name)
+	}
+	listOptions := v1.ListOptions{
+		LabelSelector: getRestoreNameSelector(restoreNames...),
+	}
+
+	for _, condition := range acmRestore.Spec.WaitConditions {
+		kind, group := getResourceDetails(strings.ToLower(condition.Kind))
+		gvr, err := r.RESTMapper.ResourceFor(schema.GroupVersionResource{
+			Group:    group,
+			Resource: kind,
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to get resource for kind %s: %v", condition.Kind, err)
+		}
+
+		resources, err := r.DynamicClient.Resource(gvr).List(ctx, listOptions)
+		if err != nil {
+			return "", fmt.Errorf("failed to list %s: %v", condition.Kind, err)
+		}
+		for i := range resources.Items {
+			resource := resources.Items[i]
+			if isConditionMet(resource, condition.ConditionType, condition.ConditionStatus) {
+				continue
+			}
+			status := condition.ConditionStatus
+			if status == "" {
+				status = v1.ConditionTrue
+			}
+			name := resource.GetName()
+			if resource.GetNamespace() != "" {
+				name = resource.GetNamespace() + "/" + name
+			}
+			return fmt.Sprintf("Waiting for %s %s condition %s=%s",
+				condition.Kind, name, condition.ConditionType, status), nil
+		}
+	}
+	return "", nil
+}
+
+// maximum number of resource names shown by a hub readiness check message
+const maxHubReadinessNames = 5
+
+// returns the resource names, limited to maxHubReadinessNames
+func getHubReadinessNames(names []string) string {
+
+	if len(names) > maxHubReadinessNames {
+		return strings.Join(names[:maxHubReadinessNames], ", ") + ", ..."
+	}
+	return strings.Join(names, ", ")
+}
+
+// returns true if a MultiClusterHub resource is in the Running phase
+func getMultiClusterHubReadiness(resources []unstructured.Unstructured) (bool, string) {
+
+	if len(resources) == 0 {
+		return false, "MultiClusterHub resource not found"
+	}
+	for i := range resources {
+		phase, _, _ := unstructured.NestedString(resources[i].Object, "status", "phase")
+		if phase != "Running" {
+			return false, fmt.Sprintf("MultiClusterHub %s is in phase %q",
+				resources[i].GetName(), phase)
+		}
+	}
+	return true, "MultiClusterHub is running"
+}
+
+// returns true if all the managed clusters are available
+func getManagedClustersReadiness(resources []unstructured.Unstructured) (bool, string) {
+
+	notAvailable := []string{}
+	for i := range resources {
+		if !isConditionMet(resources[i], "ManagedClusterConditionAvailable", v1.ConditionTrue) {
+			notAvailable = append(notAvailable, resources[i].GetName())
+		}
+	}
+	if len(notAvailable) > 0 {
+		return false, fmt.Sprintf("%d of %d managed clusters are not available: %s",
+			len(notAvailable), len(resources), getHubReadinessNames(notAvailable))
+	}
+	return true, fmt.Sprintf("%d managed clusters are available", len(resources))
+}
+
+// returns true if all the enabled root policies report a compliance status
+func getPoliciesReadiness(resources []unstructured.Unstructured) (bool, string) {
+
+	rootPolicies := 0
+	notReconciled := []string{}
+	for i := range resources {
+		if _, ok := resources[i].GetLabels()[policyRootLabel]; ok {
+			// policies propagated to the managed clusters are reconciled by the root policy
+			continue
+		}
+		if disabled, _, _ := unstructured.NestedBool(resources[i].Object, "spec", "disabled"); disabled {
+			continue
+		}
+		rootPolicies++
+		compliant, _, _ := unstructured.NestedString(resources[i].Object, "status", "compliant")
+		if compliant == "" {
+			notReconciled = append(notReconciled,
+				resources[i].GetNamespace()+"/"+resources[i].GetName())
+		}
+	}
+	if len(notReconciled) > 0 {
+		return false, fmt.Sprintf("%d of %d policies have no compliance status: %s",
+			len(notReconciled), rootPolicies, getHubReadinessNames(notReconciled))
+	}
+	return true, fmt.Sprintf("%d policies report a compliance status", rootPolicies)
+}
+
+// returns the result of the hub readiness check
+func (r *RestoreReconciler) getHubReadinessCheckStatus(
+	ctx context.Context,
+	acmRestore *v1beta1.Restore,
+	check v1beta1.HubReadinessCheck,
+) v1beta1.HubReadinessCheckStatus {
+
+	var resource string
+	listOptions := v1.ListOptions{}
+	var readiness func([]unstructured.Unstructured) (bool, string)
+	switch check {
+	case v1beta1.HubReadinessCheckMultiClusterHub:
+		resource = "multiclusterhub.operator.open-cluster-management.io"
+		readiness = getMultiClusterHubReadiness
+	case v1beta1.HubReadinessCheckManagedClusters:
+		if acmRestore.Status.VeleroManagedClustersRestoreName == "" {
+			return v1beta1.HubReadinessCheckStatus{
+				Check:   check,
+				Ready:   true,
+				Message: "Managed clusters are not restored",
+			}
+		}
+		// check only the managed clusters restored by this restore
+		resource = "managedcluster.cluster.open-cluster-management.io"
+		listOptions.LabelSelector = getRestoreNameSelector(
+			acmRestore.Status.VeleroManagedClustersRestoreName)
+		readiness = getManagedClustersReadiness
+	case v1beta1.HubReadinessCheckPolicies:
+		resource = "policy.policy.open-cluster-management.io"
+		readiness = getPoliciesReadiness
+	default:
+		return v1beta1.HubReadinessCheckStatus{
+			Check:   check,
+			Message: "Unknown hub readiness check",
+		}
+	}
+
+	kind, group := getResourceDetails(resource)
+	gvr, err := r.RESTMapper.ResourceFor(schema.GroupVersionResource{
+		Group:    group,
+		Resource: kind,
+	})
+	if err != nil {
+		return v1beta1.HubReadinessCheckStatus{
+			Check:   check,
+			Message: fmt.Sprintf("%s is not available on the hub", resource),
+		}
+	}
+	resources, err := r.DynamicClient.Resource(gvr).List(ctx, listOptions)
+	if err != nil {
+		return v1beta1.HubReadinessCheckStatus{
+			Check:   check,
+			Message: fmt.Sprintf("failed to list %s: %v", resource, err),
+		}
+	}
+
+	ready, msg := readiness(resources.Items)
+	return v1beta1.HubReadinessCheckStatus{
+		Check:   check,
+		Ready:   ready,
+		Message: msg,
+	}
+}
+
+// the order used to create the Velero restores. Velero runs one restore at a time,
+// in the order the restores are created, so this is also the order of the restores:
+// the resources first, then the credentials, which could have owners in the resources,
+// and the managed clusters last, since restoring them activates the managed clusters
+// on this hub. Velero 1.7 doesn't support resource priorities on a restore; the resources
+// of each Velero restore are restored using the Velero server --restore-resource-priorities,
+// by default the CRDs, namespaces, storage classes, volume snapshot resources, persistent
+// volumes and claims, secrets, config maps, service accounts, limit ranges, pods
+// and replica sets first, then the other resources
+var veleroRestoreOrder = []ResourceType{
+	ResourcesGeneric,
+	Resources,
+	NamespaceContents,
+	CredentialsHive,
+	CredentialsCluster,
+	Credentials,
+	ManagedClusters,
+}
+
+// returns the backup types of the Velero restores to create, sorted with veleroRestoreOrder
+func getVeleroRestoreOrder(
+	veleroRestoresToCreate map[ResourceType]*veleroapi.Restore,
+) []ResourceType {
+
+	restoreKeys := make([]ResourceType, 0, len(veleroRestoresToCreate))
+	for _, key := range veleroRestoreOrder {
+		if _, found := veleroRestoresToCreate[key]; found {
+			restoreKeys = append(restoreKeys, key)
+		}
+	}
+	return restoreKeys
+}
+
+// returns true if the Velero restores are created one at a time, in the veleroRestoreOrder,
+// each one once the resources restored by the previous ones match the wait conditions;
+// the sync and dry run restores don't wait for the restored resources between the restores
+func isStagedRestore(restore *v1beta1.Restore) bool {
+
+	isValidSync, _ := isValidSyncOptions(restore)
+	return len(restore.Spec.WaitConditions) > 0 && !isValidSync && !restore.Spec.DryRun
+}
+
+// returns the first planned Velero restore not created yet, or nil if all are created
+func getNextPlannedRestore(
+	plannedRestores []v1beta1.PlannedVeleroRestore,
+	veleroRestoreList *veleroapi.RestoreList,
+) *v1beta1.PlannedVeleroRestore {
+
+	created := make(map[string]bool, len(veleroRestoreList.Items))
+	for i := range veleroRestoreList.Items {
+		created[veleroRestoreList.Items[i].Name] = true
+	}
+	for i := range plannedRestores {
+		if !created[plannedRestores[i].Name] {
+			return &plannedRestores[i]
+		}
+	}
+	return nil
+}
+
+// returns the label selector of a planned Velero restore, nil if the restore has none
+func parsePlannedLabelSelector(selector string) (*v1.LabelSelector, error) {
+
+	if selector == "" || selector == "<none>" {
+		// FormatLabelSelector shows an empty selector as <none>
+		return nil, nil
+	}
+	return v1.ParseToLabelSelector(selector)
+}
+
+// returns the Velero restores which would be created by a dry run restore,
+// in the order the Velero restores are created, see veleroRestoreOrder
+func getPlannedVeleroRestores(
+	veleroRestoresToCreate map[ResourceType]*veleroapi.Restore,
+) []v1beta1.PlannedVeleroRestore {
+
+	plannedRestores := []v1beta1.PlannedVeleroRestore{}
+	for _, key := range getVeleroRestoreOrder(veleroRestoresToCreate) {
+		veleroRestore := veleroRestoresToCreate[key]
+		plannedRestore := v1beta1.PlannedVeleroRestore{
+			Type:       string(key),
+			Name:       veleroRestore.Name,
+			BackupName: veleroRestore.Spec.BackupName,
+		}
+		if veleroRestore.Spec.LabelSelector != nil {
+			plannedRestore.LabelSelector = v1.FormatLabelSelector(veleroRestore.Spec.LabelSelector)
+		}
+		plannedRestores = append(plannedRestores, plannedRestore)
+	}
+	return plannedRestores
+}
+
+// returns the backup type restored by the Velero restore, using the name of the restored backup,
+// or an empty string if the backup was not created by a backup schedule
+func getRestoreBackupType(veleroRestore *veleroapi.Restore) ResourceType {
+	if strings.HasPrefix(veleroRestore.Spec.BackupName, namespaceContentsScheduleName+"-") {
+		return NamespaceContents
+	}
+	for key, scheduleName := range veleroScheduleNames {
+		if key != ValidationSchedule &&
+			strings.HasPrefix(veleroRestore.Spec.BackupName, scheduleName+"-") {
+			return key
+		}
 	}
+	return ""
+}
 
-	if restore.Spec.VeleroManagedClustersBackupName == nil ||
-		restore.Spec.VeleroCredentialsBackupName == nil ||
-		restore.Spec.VeleroResourcesBackupName == nil {
-		return false, "Some Velero backup names are not set."
+// returns the Velero restore created for each backup type, its phase and the number of items
+// restored, warnings and errors reported by Velero, sorted by backup type
+func getBackupTypeRestores(veleroRestoreList *veleroapi.RestoreList) []v1beta1.BackupTypeRestore {
+
+	backupTypeRestores := []v1beta1.BackupTypeRestore{}
+	for i := range veleroRestoreList.Items {
+		veleroRestore := &veleroRestoreList.Items[i]
+		backupType := getRestoreBackupType(veleroRestore)
+		if backupType == "" {
+			continue
+		}
+		backupTypeRestore := v1beta1.BackupTypeRestore{
+			Type:     string(backupType),
+			Name:     veleroRestore.Name,
+			Phase:    string(veleroRestore.Status.Phase),
+			Warnings: veleroRestore.Status.Warnings,
+			Errors:   veleroRestore.Status.Errors,
+		}
+		if veleroRestore.Status.Progress != nil {
+			backupTypeRestore.ItemsRestored = veleroRestore.Status.Progress.ItemsRestored
+		}
+		backupTypeRestores = append(backupTypeRestores, backupTypeRestore)
 	}
+	sort.Slice(backupTypeRestores, func(i, j int) bool {
+		if backupTypeRestores[i].Type != backupTypeRestores[j].Type {
+			return backupTypeRestores[i].Type < backupTypeRestores[j].Type
+		}
+		return backupTypeRestores[i].Name < backupTypeRestores[j].Name
+	})
+	return backupTypeRestores
+}
 
-	backupName := ""
+// returns the total of the items restored, warnings and errors of the Velero restores,
+// or nil if no Velero restore was created for a backup type
+func getRestoredItemsTotal(backupTypeRestores []v1beta1.BackupTypeRestore) *v1beta1.RestoredItemsTotal {
 
-	backupName = *restore.Spec.VeleroManagedClustersBackupName
-	backupName = strings.ToLower(strings.TrimSpace(backupName))
+	if len(backupTypeRestores) == 0 {
+		return nil
+	}
+	total := &v1beta1.RestoredItemsTotal{}
+	for _, backupTypeRestore := range backupTypeRestores {
+		total.ItemsRestored += backupTypeRestore.ItemsRestored
+		total.Warnings += backupTypeRestore.Warnings
+		total.Errors += backupTypeRestore.Errors
+	}
+	return total
+}
 
-	if backupName != skipRestoreStr && backupName != latestBackupStr {
-		return false, "VeleroManagedClustersBackupName should be set to skip or latest."
+// returns the message describing the order used to restore the placement kinds
+// available on the hub, or an empty string if no placement kind is available
+func getPlacementRestoreOrderMessage(
+	resourcesRestoreName string,
+	managedClustersRestoreName string,
+	placementDecisionsRestoreName string,
+	placementKinds []string,
+) string {
+
+	restoredWithResources := []string{}
+	for _, kind := range placementKinds {
+		if findValue(placementResources, kind) {
+			restoredWithResources = append(restoredWithResources, kind)
+		}
 	}
+	restoredWithClusters := findValue(placementKinds, placementDecisionResource)
 
-	backupName = *restore.Spec.VeleroCredentialsBackupName
-	backupName = strings.ToLower(strings.TrimSpace(backupName))
+	msgs := []string{}
+	if len(restoredWithResources) > 0 && resourcesRestoreName != "" {
+		msgs = append(msgs, fmt.Sprintf("%s restored by %s",
+			strings.Join(restoredWithResources, ", "), resourcesRestoreName))
+	}
+	if restoredWithClusters {
+		switch {
+		case managedClustersRestoreName == "":
+			msgs = append(msgs, fmt.Sprintf("%s not restored, "+
+				"placement decisions are restored after the managed clusters",
+				placementDecisionResource))
+		case placementDecisionsRestoreName == "":
+			msgs = append(msgs, fmt.Sprintf("%s restored once %s is completed",
+				placementDecisionResource, managedClustersRestoreName))
+		default:
+			msgs = append(msgs, fmt.Sprintf("%s restored by %s, after %s is completed",
+				placementDecisionResource, placementDecisionsRestoreName, managedClustersRestoreName))
+		}
+	}
+	return strings.Join(msgs, "; ")
+}
 
-	if backupName != latestBackupStr {
-		return false, "VeleroCredentialsBackupName should be set to latest."
+// shows the order used to restore the placement kinds available on the hub
+// in the restore status, and reports it by an event when it changes
+func (r *RestoreReconciler) setPlacementRestoreOrder(restore *v1beta1.Restore) {
+
+	msg := getPlacementRestoreOrderMessage(
+		restore.Status.VeleroResourcesRestoreName,
+		restore.Status.VeleroManagedClustersRestoreName,
+		restore.Status.VeleroPlacementDecisionsRestoreName,
+		r.getPlacementKinds(),
+	)
+	if msg == "" || msg == restore.Status.PlacementRestoreOrder {
+		return
 	}
+	restore.Status.PlacementRestoreOrder = msg
+	r.Recorder.Event(
+		restore,
+		corev1.EventTypeNormal,
+		"Velero restore placement order:",
+		msg,
+	)
+}
 
-	backupName = *restore.Spec.VeleroResourcesBackupName
-	backupName = strings.ToLower(strings.TrimSpace(backupName))
+// returns the placement kinds available on the hub
+func (r *RestoreReconciler) getPlacementKinds() []string {
+	return r.getAvailableKinds(append([]string{placementDecisionResource}, placementResources...))
+}
 
-	if backupName != latestBackupStr {
-		return false, "VeleroResourcesBackupName should be set to latest."
+// returns the resources, using the kind.group format, available on the hub
+func (r *RestoreReconciler) getAvailableKinds(resources []string) []string {
+
+	kinds := []string{}
+	for _, resource := range resources {
+		kind, group := getResourceDetails(resource)
+		if _, err := r.RESTMapper.ResourceFor(schema.GroupVersionResource{
+			Group:    group,
+			Resource: kind,
+		}); err == nil {
+			kinds = append(kinds, resource)
+		}
 	}
-	return true, ""
+	return kinds
+}
+
+// returns the label selector for the resources restored by the velero restores;
+// Velero sets the restore-name label to a valid label value, which is shortened
+// for the restore names longer than 63 characters
+func getRestoreNameSelector(veleroRestoreNames ...string) string {
+
+	labelValues := make([]string, 0, len(veleroRestoreNames))
+	for _, name := range veleroRestoreNames {
+		labelValues = append(labelValues, label.GetValidName(name))
+	}
+	if len(labelValues) == 1 {
+		return "velero.io/restore-name=" + labelValues[0]
+	}
+	return fmt.Sprintf("velero.io/restore-name in (%s)", strings.Join(labelValues, ","))
+}
+
+// returns the names of the resources restored by the velero restore
+func getRestoredResourceNames(
+	ctx context.Context,
+	dr dynamic.NamespaceableResourceInterface,
+	veleroRestoreName string,
+) ([]string, error) {
+
+	resources, err := dr.List(ctx, v1.ListOptions{
+		LabelSelector: getRestoreNameSelector(veleroRestoreName),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for i := range resources.Items {
+		name := resources.Items[i].GetName()
+		if resources.Items[i].GetNamespace() != "" {
+			name = resources.Items[i].GetNamespace() + "/" + name
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// returns the message listing the cluster sets and cluster claims
+// restored by the managed clusters restore
+func (r *RestoreReconciler) getRestoredClusterFleetMessage(
+	ctx context.Context,
+	veleroRestoreName string,
+) string {
+
+	logger := log.FromContext(ctx)
+
+	msgs := []string{}
+	for _, resource := range r.getAvailableKinds(clusterFleetResources) {
+		kind, group := getResourceDetails(resource)
+		gvr, err := r.RESTMapper.ResourceFor(schema.GroupVersionResource{
+			Group:    group,
+			Resource: kind,
+		})
+		if err != nil {
+			continue
+		}
+		names, err := getRestoredResourceNames(ctx, r.DynamicClient.Resource(gvr), veleroRestoreName)
+		if err != nil {
+			logger.Info("Failed to list restored resources", "kind", resource, "error", err.Error())
+			continue
+		}
+		if len(names) > 0 {
+			msgs = append(msgs, fmt.Sprintf("%s [%s]", resource, strings.Join(names, ", ")))
+		}
+	}
+	if len(msgs) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%s restored %s", veleroRestoreName, strings.Join(msgs, ", "))
+}
+
+// activates the managed clusters restored by the managed clusters Velero restore,
+// once this Velero restore is completed: the restored managed clusters are accepted
+// by this hub. The Activated condition shows the activation result;
+// the activation runs once, until the managed clusters are activated
+func (r *RestoreReconciler) activateManagedClusters(
+	ctx context.Context,
+	restore *v1beta1.Restore,
+	veleroRestoreList *veleroapi.RestoreList,
+) {
+
+	veleroRestoreName := restore.Status.VeleroManagedClustersRestoreName
+	if !restore.Spec.ActivateManagedClusters || veleroRestoreName == "" ||
+		meta.IsStatusConditionTrue(restore.Status.Conditions, v1beta1.RestoreActivated) {
+		return
+	}
+
+	condition := v1.Condition{
+		Type:               v1beta1.RestoreActivated,
+		Status:             v1.ConditionFalse,
+		Reason:             v1beta1.RestoreReasonActivationPending,
+		Message:            fmt.Sprintf("Waiting for Velero restore %s to complete", veleroRestoreName),
+		ObservedGeneration: restore.Generation,
+	}
+	for i := range veleroRestoreList.Items {
+		veleroRestore := &veleroRestoreList.Items[i]
+		if veleroRestore.Name != veleroRestoreName ||
+			veleroRestore.Status.Phase != veleroapi.RestorePhaseCompleted {
+			continue
+		}
+
+		activated, err := r.acceptRestoredManagedClusters(ctx, veleroRestoreName)
+		if err != nil {
+			condition.Reason = v1beta1.RestoreReasonActivationFailed
+			condition.Message = "Failed to activate the managed clusters: " + err.Error()
+			r.Recorder.Event(restore, corev1.EventTypeWarning, "Managed clusters activation:",
+				condition.Message)
+			break
+		}
+		condition.Status = v1.ConditionTrue
+		condition.Reason = v1beta1.RestoreReasonActivated
+		condition.Message = "No managed clusters restored by " + veleroRestoreName
+		if len(activated) > 0 {
+			condition.Message = "Activated managed clusters " + strings.Join(activated, ", ")
+		}
+		r.Recorder.Event(restore, corev1.EventTypeNormal, "Managed clusters activation:",
+			condition.Message)
+	}
+	meta.SetStatusCondition(&restore.Status.Conditions, condition)
+}
+
+// sets hubAcceptsClient on the managed clusters restored by the Velero restore,
+// except the local cluster; returns the names of the activated managed clusters
+func (r *RestoreReconciler) acceptRestoredManagedClusters(
+	ctx context.Context,
+	veleroRestoreName string,
+) ([]string, error) {
+
+	managedClusters := &clusterv1.ManagedClusterList{}
+	if err := r.List(ctx, managedClusters, client.MatchingLabels{
+		"velero.io/restore-name": label.GetValidName(veleroRestoreName),
+	}); err != nil {
+		return nil, err
+	}
+
+	activated := []string{}
+	for i := range managedClusters.Items {
+		managedCluster := &managedClusters.Items[i]
+		if managedCluster.Name == "local-cluster" {
+			continue
+		}
+		if !managedCluster.Spec.HubAcceptsClient {
+			patch := client.MergeFrom(managedCluster.DeepCopy())
+			managedCluster.Spec.HubAcceptsClient = true
+			if err := r.Patch(ctx, managedCluster, patch); err != nil {
+				return activated, err
+			}
+		}
+		activated = append(activated, managedCluster.Name)
+	}
+	return activated, nil
+}
+
+// returns the backups created by the source schedule set on the restore resource
+// or all backups if no source schedule is set
+func filterBackupsBySourceSchedule(
+	restore *v1beta1.Restore,
+	backups []veleroapi.Backup,
+) []veleroapi.Backup {
+
+	if restore.Spec.SourceScheduleName == "" {
+		return backups
+	}
+
+	return filterBackups(backups, func(bkp veleroapi.Backup) bool {
+		return bkp.GetLabels()[BackupScheduleNameLabel] == restore.Spec.SourceScheduleName
+	})
+}
+
+// returns the backups created on the source hub set on the restore resource
+// or all backups if no source hub is set
+func filterBackupsBySourceHub(
+	restore *v1beta1.Restore,
+	backups []veleroapi.Backup,
+) []veleroapi.Backup {
+
+	if restore.Spec.SourceHubID == "" {
+		return backups
+	}
+
+	return filterBackups(backups, func(bkp veleroapi.Backup) bool {
+		return bkp.GetLabels()[BackupScheduleClusterLabel] == restore.Spec.SourceHubID
+	})
+}
+
+// returns the storage location set on the restore resource,
+// by name or by object store prefix; returns nil if none is set
+func findRestoreStorageLocation(
+	restore *v1beta1.Restore,
+	storageLocations []veleroapi.BackupStorageLocation,
+) (*veleroapi.BackupStorageLocation, error) {
+
+	name := restore.Spec.StorageLocation
+	prefix := strings.Trim(restore.Spec.StorageLocationPrefix, "/")
+	if name == "" && prefix == "" {
+		return nil, nil
+	}
+
+	var found *veleroapi.BackupStorageLocation
+	for i := range storageLocations {
+		storageLocation := &storageLocations[i]
+		if name != "" && storageLocation.Name != name {
+			continue
+		}
+		if prefix != "" && (storageLocation.Spec.ObjectStorage == nil ||
+			strings.Trim(storageLocation.Spec.ObjectStorage.Prefix, "/") != prefix) {
+			continue
+		}
+		if found != nil {
+			return nil, fmt.Errorf("more than one storage location uses prefix %s: %s, %s",
+				prefix, found.Name, storageLocation.Name)
+		}
+		found = storageLocation
+	}
+
+	if found == nil {
+		if prefix == "" {
+			return nil, fmt.Errorf("storage location %s not found", name)
+		}
+		if name == "" {
+			return nil, fmt.Errorf("no storage location found with prefix %s", prefix)
+		}
+		return nil, fmt.Errorf("storage location %s does not use prefix %s", name, prefix)
+	}
+	if found.Status.Phase != veleroapi.BackupStorageLocationPhaseAvailable {
+		return nil, fmt.Errorf("storage location %s is not available", found.Name)
+	}
+	return found, nil
+}
+
+// returns the backups synced from the storage location
+// or all backups if no storage location is set
+func filterBackupsByStorageLocation(
+	storageLocation string,
+	backups []veleroapi.Backup,
+) []veleroapi.Backup {
+
+	if storageLocation == "" {
+		return backups
+	}
+
+	return filterBackups(backups, func(bkp veleroapi.Backup) bool {
+		return bkp.Spec.StorageLocation == storageLocation
+	})
+}
+
+// returns the backups stored in the storage location of the most recent backup created
+// by a backup schedule; the backup schedules write the same backups to each additional
+// storage location, so the backups restored together must come from the same storage location
+func filterBackupsByLatestStorageLocation(backups []veleroapi.Backup) []veleroapi.Backup {
+
+	locationBackups := groupBackupsByStorageLocation(backups)
+	if len(locationBackups) < 2 {
+		return backups
+	}
+
+	found := false
+	latestLocation := ""
+	var latestTime time.Time
+	for _, location := range getStorageLocationNames(locationBackups) {
+		for i := range locationBackups[location] {
+			backup := &locationBackups[location][i]
+			if getBackupSetType(backup.Name) == "" {
+				continue
+			}
+			if backupTime := getBackupTime(backup); !found || backupTime.After(latestTime) {
+				found = true
+				latestLocation = location
+				latestTime = backupTime
+			}
+		}
+	}
+	if !found {
+		return backups
+	}
+	return locationBackups[latestLocation]
+}
+
+// returns the bucket and prefix used by the storage location
+func getStorageLocationPath(storageLocation *veleroapi.BackupStorageLocation) string {
+
+	if storageLocation.Spec.ObjectStorage == nil {
+		return ""
+	}
+	if storageLocation.Spec.ObjectStorage.Prefix == "" {
+		return storageLocation.Spec.ObjectStorage.Bucket
+	}
+	return storageLocation.Spec.ObjectStorage.Bucket + "/" +
+		strings.Trim(storageLocation.Spec.ObjectStorage.Prefix, "/")
 }
 
 func isSkipAllRestores(restore *v1beta1.Restore) bool {
@@ -131,6 +1434,342 @@ func isSkipAllRestores(restore *v1beta1.Restore) bool {
 	return true
 }
 
+// maximum delay between the attempts of a restore in Error phase
+const maxRestoreRetryInterval = time.Minute * 30
+
+// returns true if the restore was attempted MaxRestoreAttempts times
+func isRestoreAttemptsExhausted(restore *v1beta1.Restore) bool {
+
+	return restore.Spec.MaxRestoreAttempts > 0 &&
+		restore.Status.RestoreAttempts >= restore.Spec.MaxRestoreAttempts
+}
+
+// counts a failed restore attempt; returns the delay before the next attempt,
+// doubled with each failed attempt, and false if there are no attempts left
+func nextRestoreAttempt(restore *v1beta1.Restore) (time.Duration, bool) {
+
+	restore.Status.RestoreAttempts++
+	if isRestoreAttemptsExhausted(restore) {
+		return 0, false
+	}
+
+	retryAfter := failureInterval
+	for i := 1; i < restore.Status.RestoreAttempts; i++ {
+		retryAfter *= 2
+		if retryAfter >= maxRestoreRetryInterval {
+			return maxRestoreRetryInterval, true
+		}
+	}
+	return retryAfter, true
+}
+
+// counts a failed restore attempt and sets the time of the next attempt in the restore status;
+// returns the delay before the next attempt and false if there are no attempts left
+func setNextRestoreAttempt(restore *v1beta1.Restore, now time.Time) (time.Duration, bool) {
+
+	retryAfter, retry := nextRestoreAttempt(restore)
+	if !retry {
+		restore.Status.NextRestoreAttempt = nil
+		return 0, false
+	}
+	restore.Status.NextRestoreAttempt = &v1.Time{Time: now.Add(retryAfter)}
+	return retryAfter, true
+}
+
+// returns the time left before the next attempt of a restore in Error phase;
+// the restore status updates and the velero restores changes trigger a reconcile,
+// so a failed restore is not processed again before this delay
+func getRestoreAttemptDelay(restore *v1beta1.Restore, now time.Time) time.Duration {
+
+	if restore.Status.Phase != v1beta1.RestorePhaseError ||
+		restore.Status.NextRestoreAttempt == nil {
+		return 0
+	}
+	if delay := restore.Status.NextRestoreAttempt.Sub(now); delay > 0 {
+		return delay
+	}
+	return 0
+}
+
+// backupNotFoundError is returned when no backup is found for a backup type set to latest;
+// the backups could be synced later from the storage location
+type backupNotFoundError struct {
+	error
+}
+
+// sets the WaitingForBackups condition when the restore waits for the backups to be synced from
+// the storage location: no backup was found for a backup type set to latest and the
+// WaitForBackupsTimeout since the restore was created is not reached; returns the delay
+// before looking for the backups again and true if the restore waits for the backups
+func setWaitingForBackups(
+	restore *v1beta1.Restore,
+	err error,
+	now time.Time,
+) (time.Duration, bool) {
+
+	timeout := restore.Spec.WaitForBackupsTimeout.Duration
+	if timeout == 0 {
+		meta.RemoveStatusCondition(&restore.Status.Conditions, v1beta1.RestoreWaitingForBackups)
+		return 0, false
+	}
+
+	notFoundErr, notFound := err.(*backupNotFoundError)
+	if !notFound {
+		if err == nil &&
+			meta.FindStatusCondition(restore.Status.Conditions, v1beta1.RestoreWaitingForBackups) != nil {
+			// the restore waited for the backups, they are available now
+			meta.SetStatusCondition(&restore.Status.Conditions, v1.Condition{
+				Type:               v1beta1.RestoreWaitingForBackups,
+				Status:             v1.ConditionFalse,
+				Reason:             v1beta1.RestoreReasonBackupsFound,
+				Message:            "The backups were synced from the storage location",
+				ObservedGeneration: restore.Generation,
+			})
+		}
+		return 0, false
+	}
+
+	waited := now.Sub(restore.CreationTimestamp.Time)
+	if waited >= timeout {
+		meta.SetStatusCondition(&restore.Status.Conditions, v1.Condition{
+			Type:               v1beta1.RestoreWaitingForBackups,
+			Status:             v1.ConditionFalse,
+			Reason:             v1beta1.RestoreReasonWaitForBackupsTimedOut,
+			Message:            fmt.Sprintf("No backups found after %s: %v", timeout, notFoundErr),
+			ObservedGeneration: restore.Generation,
+		})
+		return 0, false
+	}
+
+	meta.SetStatusCondition(&restore.Status.Conditions, v1.Condition{
+		Type:               v1beta1.RestoreWaitingForBackups,
+		Status:             v1.ConditionTrue,
+		Reason:             v1beta1.RestoreReasonBackupsNotFound,
+		Message:            notFoundErr.Error(),
+		ObservedGeneration: restore.Generation,
+	})
+	restore.Status.Phase = v1beta1.RestorePhaseStarted
+	restore.Status.LastMessage = fmt.Sprintf(
+		"Waiting up to %s for the backups to be synced from the storage location: %v",
+		timeout, notFoundErr)
+
+	// wait as long as the restore has already waited, so the delay doubles with each check
+	retryAfter := waited
+	if retryAfter < failureInterval {
+		retryAfter = failureInterval
+	}
+	if retryAfter > maxRestoreRetryInterval {
+		retryAfter = maxRestoreRetryInterval
+	}
+	if remaining := timeout - waited; retryAfter > remaining {
+		retryAfter = remaining
+	}
+	return retryAfter, true
+}
+
+// returns the NoMatchingBackups condition of a restore for which no backup was found for a
+// backup type set to latest, using the backups and storage locations of the restore namespace.
+// The condition is True if the storage location has backups but none created by the source
+// schedule and hub of the restore, or has no backups and was synced after the restore was created;
+// Unknown if the storage location has no backups and was not synced since the restore was created;
+// False if backups from the source schedule and hub exist, only the backups of a type are missing
+func getNoMatchingBackupsCondition(
+	restore *v1beta1.Restore,
+	backups []veleroapi.Backup,
+	storageLocations []veleroapi.BackupStorageLocation,
+) v1.Condition {
+
+	condition := v1.Condition{
+		Type:               v1beta1.RestoreNoMatchingBackups,
+		ObservedGeneration: restore.Generation,
+	}
+
+	location := "the storage locations"
+	if restore.Status.StorageLocation != "" {
+		location = "storage location " + restore.Status.StorageLocation
+	}
+	backups = filterBackupsByStorageLocation(restore.Status.StorageLocation, backups)
+	if len(backups) == 0 {
+		for i := range storageLocations {
+			storageLocation := &storageLocations[i]
+			if restore.Status.StorageLocation != "" &&
+				storageLocation.Name != restore.Status.StorageLocation {
+				continue
+			}
+			if storageLocation.Status.LastSyncedTime == nil ||
+				storageLocation.Status.LastSyncedTime.Before(&restore.CreationTimestamp) {
+				condition.Status = v1.ConditionUnknown
+				condition.Reason = v1beta1.RestoreReasonWaitingForBackupSync
+				condition.Message = fmt.Sprintf(
+					"No backups found yet, waiting for velero to sync the backups from %s", location)
+				return condition
+			}
+		}
+		condition.Status = v1.ConditionTrue
+		condition.Reason = v1beta1.RestoreReasonNoBackupsInLocation
+		condition.Message = fmt.Sprintf("No backups found in %s, synced after the restore was created",
+			location)
+		return condition
+	}
+
+	matching := filterBackupsBySourceHub(restore, filterBackupsBySourceSchedule(restore, backups))
+	if len(matching) > 0 {
+		condition.Status = v1.ConditionFalse
+		condition.Reason = v1beta1.RestoreReasonMatchingBackupsFound
+		condition.Message = fmt.Sprintf("%d backups matching the restore found in %s",
+			len(matching), location)
+		return condition
+	}
+
+	// show the sources of the backups found, to fix the restore source
+	source := []string{}
+	if restore.Spec.SourceScheduleName != "" {
+		source = append(source, "schedule "+restore.Spec.SourceScheduleName)
+	}
+	if restore.Spec.SourceHubID != "" {
+		source = append(source, "hub "+restore.Spec.SourceHubID)
+	}
+	schedules := []string{}
+	hubs := []string{}
+	for i := range backups {
+		if name := backups[i].GetLabels()[BackupScheduleNameLabel]; name != "" {
+			schedules = appendUnique(schedules, name)
+		}
+		if hub := backups[i].GetLabels()[BackupScheduleClusterLabel]; hub != "" {
+			hubs = appendUnique(hubs, hub)
+		}
+	}
+	sort.Strings(schedules)
+	sort.Strings(hubs)
+	condition.Status = v1.ConditionTrue
+	condition.Reason = v1beta1.RestoreReasonNoBackupsFromSource
+	condition.Message = fmt.Sprintf(
+		"No backups created by %s found in %s; the %d backups found were created by schedules [%s] on hubs [%s]",
+		strings.Join(source, " on "), location, len(backups),
+		strings.Join(schedules, ", "), strings.Join(hubs, ", "))
+	return condition
+}
+
+// sets the NoMatchingBackups condition when the restore found no backup for a backup type set to
+// latest, or to False when the backups are found after the condition was set; returns true if
+// no backup matches the restore, so the restore doesn't wait for the backups to be synced
+func (r *RestoreReconciler) setNoMatchingBackups(
+	ctx context.Context,
+	restore *v1beta1.Restore,
+	err error,
+) bool {
+
+	if _, notFound := err.(*backupNotFoundError); !notFound {
+		if err == nil &&
+			meta.FindStatusCondition(restore.Status.Conditions, v1beta1.RestoreNoMatchingBackups) != nil {
+			meta.SetStatusCondition(&restore.Status.Conditions, v1.Condition{
+				Type:               v1beta1.RestoreNoMatchingBackups,
+				Status:             v1.ConditionFalse,
+				Reason:             v1beta1.RestoreReasonMatchingBackupsFound,
+				Message:            "The backups matching the restore were found",
+				ObservedGeneration: restore.Generation,
+			})
+		}
+		return false
+	}
+
+	restoreLogger := log.FromContext(ctx)
+	veleroBackups := &veleroapi.BackupList{}
+	if err := r.Client.List(ctx, veleroBackups, client.InNamespace(restore.Namespace)); err != nil {
+		restoreLogger.Error(err, "unable to list the velero backups", "namespace", restore.Namespace)
+		return false
+	}
+	storageLocations := &veleroapi.BackupStorageLocationList{}
+	if err := r.Client.List(ctx, storageLocations, client.InNamespace(restore.Namespace)); err != nil {
+		restoreLogger.Error(err, "unable to list the velero storage locations",
+			"namespace", restore.Namespace)
+		return false
+	}
+
+	condition := getNoMatchingBackupsCondition(restore, veleroBackups.Items, storageLocations.Items)
+	meta.SetStatusCondition(&restore.Status.Conditions, condition)
+	if condition.Status != v1.ConditionTrue {
+		return false
+	}
+	restore.Status.LastMessage = condition.Message
+	return true
+}
+
+// set the Complete and Failed conditions for the restore phase
+func setRestoreConditions(restore *v1beta1.Restore) {
+
+	completeStatus := v1.ConditionFalse
+	failedStatus := v1.ConditionFalse
+	var reason string
+	switch restore.Status.Phase {
+	case v1beta1.RestorePhaseStarted:
+		reason = v1beta1.RestoreReasonStarted
+	case v1beta1.RestorePhaseRunning:
+		reason = v1beta1.RestoreReasonRunning
+	case v1beta1.RestorePhaseFinished:
+		completeStatus = v1.ConditionTrue
+		reason = v1beta1.RestoreReasonFinished
+	case v1beta1.RestorePhaseFinishedWithErrors:
+		completeStatus = v1.ConditionTrue
+		failedStatus = v1.ConditionTrue
+		reason = v1beta1.RestoreReasonFinishedWithErrors
+	case v1beta1.RestorePhaseEnabled:
+		// the restores completed, new backups are restored when available
+		completeStatus = v1.ConditionTrue
+		reason = v1beta1.RestoreReasonSyncEnabled
+	case v1beta1.RestorePhaseError:
+		failedStatus = v1.ConditionTrue
+		reason = v1beta1.RestoreReasonError
+		if isRestoreAttemptsExhausted(restore) {
+			reason = v1beta1.RestoreReasonAttemptsExhausted
+		}
+	case v1beta1.RestorePhaseUnknown:
+		completeStatus = v1.ConditionUnknown
+		failedStatus = v1.ConditionUnknown
+		reason = v1beta1.RestoreReasonUnknown
+	default:
+		reason = v1beta1.RestoreReasonNotStarted
+	}
+
+	if restore.Spec.DryRun {
+		// a dry run doesn't restore anything, the DryRunComplete condition shows its result
+		meta.RemoveStatusCondition(&restore.Status.Conditions, v1beta1.RestoreComplete)
+	} else {
+		meta.SetStatusCondition(&restore.Status.Conditions, v1.Condition{
+			Type:               v1beta1.RestoreComplete,
+			Status:             completeStatus,
+			Reason:             reason,
+			Message:            restore.Status.LastMessage,
+			ObservedGeneration: restore.Generation,
+		})
+	}
+	meta.SetStatusCondition(&restore.Status.Conditions, v1.Condition{
+		Type:               v1beta1.RestoreFailed,
+		Status:             failedStatus,
+		Reason:             reason,
+		Message:            restore.Status.LastMessage,
+		ObservedGeneration: restore.Generation,
+	})
+
+	if !restore.Spec.DryRun {
+		meta.RemoveStatusCondition(&restore.Status.Conditions, v1beta1.RestoreDryRunComplete)
+		return
+	}
+	dryRunStatus := v1.ConditionFalse
+	dryRunReason := v1beta1.RestoreReasonDryRunFailed
+	if restore.Status.Phase == v1beta1.RestorePhaseFinished {
+		dryRunStatus = v1.ConditionTrue
+		dryRunReason = v1beta1.RestoreReasonDryRunComplete
+	}
+	meta.SetStatusCondition(&restore.Status.Conditions, v1.Condition{
+		Type:               v1beta1.RestoreDryRunComplete,
+		Status:             dryRunStatus,
+		Reason:             dryRunReason,
+		Message:            restore.Status.LastMessage,
+		ObservedGeneration: restore.Generation,
+	})
+}
+
 func updateRestoreStatus(
 	logger logr.Logger,
 	status v1beta1.RestorePhase,
@@ -143,6 +1782,35 @@ func updateRestoreStatus(
 	restore.Status.LastMessage = msg
 }
 
+// set the VeleroNotInstalled condition to True if the velero not installed message is set;
+// the condition is set to False once velero is installed, if it was set before
+func setRestoreVeleroNotInstalledCondition(
+	restore *v1beta1.Restore,
+	veleroNotInstalledMsg string,
+) {
+
+	if veleroNotInstalledMsg != "" {
+		meta.SetStatusCondition(&restore.Status.Conditions, v1.Condition{
+			Type:               v1beta1.RestoreVeleroNotInstalled,
+			Status:             v1.ConditionTrue,
+			Reason:             v1beta1.RestoreReasonVeleroCRDsNotFound,
+			Message:            veleroNotInstalledMsg,
+			ObservedGeneration: restore.Generation,
+		})
+		return
+	}
+	if meta.FindStatusCondition(restore.Status.Conditions,
+		v1beta1.RestoreVeleroNotInstalled) != nil {
+		meta.SetStatusCondition(&restore.Status.Conditions, v1.Condition{
+			Type:               v1beta1.RestoreVeleroNotInstalled,
+			Status:             v1.ConditionFalse,
+			Reason:             v1beta1.RestoreReasonVeleroCRDsFound,
+			Message:            "The Velero CRDs are installed",
+			ObservedGeneration: restore.Generation,
+		})
+	}
+}
+
 // delete resource
 func deleteDynamicResource(
 	ctx context.Context,
@@ -242,6 +1910,30 @@ func deleteDynamicResource(
 	return true, processed
 }
 
+// returns true for the backup operator and velero resources, which are not cleaned up
+// before a restore: deleting them would stop the restore or the backups
+func isCleanupProtectedResource(groupKind schema.GroupKind) bool {
+
+	if groupKind.Group == veleroapi.SchemeGroupVersion.Group {
+		return true
+	}
+	return groupKind.Group == v1beta1.GroupVersion.Group &&
+		(strings.EqualFold(groupKind.Kind, "BackupSchedule") ||
+			strings.EqualFold(groupKind.Kind, "Restore"))
+}
+
+// returns the label selector used to back up the generic resources;
+// backups created with the default selector use the backup label
+func getGenericResourcesLabelSelector(veleroBackup *veleroapi.Backup) string {
+
+	if veleroBackup.Spec.LabelSelector == nil ||
+		(len(veleroBackup.Spec.LabelSelector.MatchLabels) == 0 &&
+			len(veleroBackup.Spec.LabelSelector.MatchExpressions) == 0) {
+		return backupCredsClusterLabel
+	}
+	return v1.FormatLabelSelector(veleroBackup.Spec.LabelSelector)
+}
+
 // clean up resources for the restored backup resources
 func (r *RestoreReconciler) prepareRestoreForBackup(
 	ctx context.Context,
@@ -273,7 +1965,7 @@ func (r *RestoreReconciler) prepareRestoreForBackup(
 	case Resources:
 		labelSelector = labelSelector + "!" + policyRootLabel
 	case ResourcesGeneric:
-		labelSelector = labelSelector + backupCredsClusterLabel
+		labelSelector = labelSelector + getGenericResourcesLabelSelector(veleroBackup)
 	case Credentials:
 		labelSelector = labelSelector + backupCredsUserLabel
 	case CredentialsHive:
@@ -302,9 +1994,14 @@ func (r *RestoreReconciler) prepareRestoreForBackup(
 		}
 	} else {
 		// for generic resources get all CRDs and exclude the ones in the veleroBackup.Spec.ExcludedResources
-		resources, _ = getGenericCRDFromAPIGroups(ctx, restoreOptions.dynamicArgs.dc, veleroBackup)
+		// the excluded API groups resources are excluded by the backup ExcludedResources
+		resources, _ = getGenericCRDFromAPIGroups(ctx, restoreOptions.dynamicArgs.dc, veleroBackup, nil)
 	}
 
+	// the restore namespace holds the operator and velero resources, it is never cleaned up
+	excludedNamespaces := appendUnique(
+		append([]string{}, veleroBackup.Spec.ExcludedNamespaces...), acmRestore.Namespace)
+
 	for i := range resources {
 
 		kind, groupName := getResourceDetails(resources[i])
@@ -329,6 +2026,10 @@ func (r *RestoreReconciler) prepareRestoreForBackup(
 				groupKind, err.Error()))
 			continue
 		}
+		if isCleanupProtectedResource(mapping.GroupVersionKind.GroupKind()) {
+			// never delete the resources running the restore, even if they have the backup labels
+			continue
+		}
 		var dr = restoreOptions.dynamicArgs.dyn.Resource(mapping.Resource)
 		if dr == nil {
 			continue
@@ -352,7 +2053,7 @@ func (r *RestoreReconciler) prepareRestoreForBackup(
 				dr,
 				dynamiclist.Items[i],
 				restoreOptions.deleteOptions,
-				veleroBackup.Spec.ExcludedNamespaces,
+				excludedNamespaces,
 			)
 
 		}
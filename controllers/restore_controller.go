@@ -26,6 +26,7 @@ import (
 	"github.com/pkg/errors"
 
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
@@ -36,6 +37,7 @@ import (
 	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 
 	v1beta1 "github.com/stolostron/cluster-backup-operator/api/v1beta1"
@@ -54,8 +56,16 @@ const (
 	latestBackupStr     string = "latest"
 	restoreSyncInterval        = time.Minute * 30
 	noopMsg                    = "Nothing to do for restore %s"
+	defaultWaitTimeout         = time.Minute * 10
+	defaultReadyTimeout        = time.Minute * 30
+	// set on the restore resources, removed once the velero restores created
+	// by the restore are deleted
+	restoreCleanupFinalizer = "cluster.open-cluster-management.io/velero-restores-cleanup"
 )
 
+// maximum number of backup sets shown by the restore status
+const maxLatestBackupSets = 3
+
 type DynamicStruct struct {
 	dc     discovery.DiscoveryInterface
 	dyn    dynamic.Interface
@@ -77,16 +87,29 @@ type RestoreReconciler struct {
 	RESTMapper      *restmapper.DeferredDiscoveryRESTMapper
 	Scheme          *runtime.Scheme
 	Recorder        record.EventRecorder
+	// StorageLocationProbeTimeout is the timeout for the storage location
+	// connectivity probe; the probe is disabled if not set
+	StorageLocationProbeTimeout time.Duration
+	// SyncInterval is the interval between the checks for new backups of the restores
+	// syncing with new backups and with no RestoreSyncInterval; restoreSyncInterval if not set
+	SyncInterval time.Duration
+	// AcceptUnownedStorageLocations uses the available storage locations with no owner reference,
+	// for example created by GitOps; only the storage locations owned by the resource used
+	// to install velero are used if not set
+	AcceptUnownedStorageLocations bool
+	// the hub uid, compared with the hub uid of the restored backups
+	hubID hubIdentification
 }
 
 //+kubebuilder:rbac:groups=cluster.open-cluster-management.io,resources=restores,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=cluster.open-cluster-management.io,resources=restores/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=cluster.open-cluster-management.io,resources=restores/finalizers,verbs=update
 //+kubebuilder:rbac:groups=velero.io,resources=backups,verbs=get;list
-//+kubebuilder:rbac:groups=velero.io,resources=restores,verbs=get;list;watch;create;update
+//+kubebuilder:rbac:groups=velero.io,resources=restores,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 //+kubebuilder:rbac:groups=velero.io,resources=backupstoragelocations,verbs=get;list;watch
 //+kubebuilder:rbac:groups=velero.io,resources=deletebackuprequests,verbs=create;list;watch
+//+kubebuilder:rbac:groups=cluster.open-cluster-management.io,resources=managedclusters,verbs=get;list;watch;patch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -94,6 +117,9 @@ func (r *RestoreReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 	restoreLogger := log.FromContext(ctx)
 	restore := &v1beta1.Restore{}
 
+	ctx, span := startSpan(ctx, "Restore reconcile", req.Namespace, req.Name)
+	defer span.End()
+
 	// velero doesn't delete expired backups if they are in FailedValidation
 	// workaround and delete expired or invalid validation backups them now
 	cleanupExpiredValidationBackups(ctx, req.Namespace, r.Client)
@@ -102,12 +128,27 @@ func (r *RestoreReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
+	// delete the velero restores created by this restore before it is removed
+	if !restore.DeletionTimestamp.IsZero() {
+		return r.finalizeRestore(ctx, restore)
+	}
 	if restore.Status.Phase == v1beta1.RestorePhaseFinished ||
 		restore.Status.Phase == v1beta1.RestorePhaseFinishedWithErrors {
 		// don't process a restore resource if it's completed
 		return ctrl.Result{}, nil
 	}
 
+	if restore.Status.Phase == v1beta1.RestorePhaseError && isRestoreAttemptsExhausted(restore) {
+		// don't retry a restore which failed MaxRestoreAttempts times
+		return ctrl.Result{}, nil
+	}
+
+	if delay := getRestoreAttemptDelay(restore, time.Now()); delay > 0 {
+		// the restore failed, wait for the next attempt
+		return ctrl.Result{RequeueAfter: delay}, nil
+	}
+	restore.Status.NextRestoreAttempt = nil
+
 	// don't create restores if there is any other active resource in this namespace
 	activeResourceMsg, err := r.isOtherResourcesRunning(ctx, restore)
 	if err != nil {
@@ -121,72 +162,114 @@ func (r *RestoreReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 			restore,
 		)
 		return ctrl.Result{}, errors.Wrap(
-			r.Client.Status().Update(ctx, restore),
+			r.updateStatus(ctx, restore),
 			activeResourceMsg,
 		)
 	}
 
+	// don't create restores if velero is not installed; the storage locations
+	// can't be found in this case, report the missing velero CRDs instead
+	veleroNotInstalledMsg := getVeleroNotInstalledMessage(ctx, r.DiscoveryClient)
+	setRestoreVeleroNotInstalledCondition(restore, veleroNotInstalledMsg)
+	if veleroNotInstalledMsg != "" {
+		updateRestoreStatus(restoreLogger, v1beta1.RestorePhaseError, veleroNotInstalledMsg, restore)
+		return r.retryFailedRestore(ctx, restore, veleroNotInstalledMsg)
+	}
+
+	// the restore is active and can create velero restores, delete them with the restore
+	if !controllerutil.ContainsFinalizer(restore, restoreCleanupFinalizer) {
+		controllerutil.AddFinalizer(restore, restoreCleanupFinalizer)
+		if err := r.Update(ctx, restore); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
 	// don't create restores if backup storage location doesn't exist or is not avaialble
+	// only the storage locations in the velero namespace are used, if set
 	veleroStorageLocations := &veleroapi.BackupStorageLocationList{}
-	if err := r.Client.List(ctx, veleroStorageLocations, &client.ListOptions{}); err != nil ||
+	if err := r.Client.List(ctx, veleroStorageLocations,
+		client.InNamespace(restore.Spec.VeleroNamespace)); err != nil ||
 		veleroStorageLocations == nil || len(veleroStorageLocations.Items) == 0 {
 
 		msg := "velero.io.BackupStorageLocation resources not found. " +
 			"Verify you have created a konveyor.openshift.io.Velero or oadp.openshift.io.DataProtectionApplications resource."
 		updateRestoreStatus(restoreLogger, v1beta1.RestorePhaseError, msg, restore)
-		// retry after failureInterval
-		return ctrl.Result{RequeueAfter: failureInterval}, errors.Wrap(
-			r.Client.Status().Update(ctx, restore),
-			msg,
-		)
+		return r.retryFailedRestore(ctx, restore, msg)
 	}
 
 	// look for available VeleroStorageLocation
 	// and keep track of the velero oadp namespace
-	isValidStorageLocation, veleroNamespace := isValidStorageLocationDefined(
-		*veleroStorageLocations,
-	)
+	validStorageLocations := getValidStorageLocations(ctx, *veleroStorageLocations,
+		r.AcceptUnownedStorageLocations)
 
 	// if no valid storage location found wait for valid value
-	if !isValidStorageLocation {
+	if len(validStorageLocations) == 0 {
 		msg := "Backup storage location not available in namespace " + req.Namespace +
 			". Check velero.io.BackupStorageLocation and validate storage credentials."
 		updateRestoreStatus(restoreLogger, v1beta1.RestorePhaseError, msg, restore)
-
-		// retry after failureInterval
-		return ctrl.Result{RequeueAfter: failureInterval}, errors.Wrap(
-			r.Client.Status().Update(ctx, restore),
-			msg,
-		)
+		return r.retryFailedRestore(ctx, restore, msg)
 	}
 
 	// return error if the cluster restore file is not in the same namespace with velero
-	if veleroNamespace != req.Namespace {
+	if len(getStorageLocationsInNamespace(validStorageLocations, req.Namespace)) == 0 {
 		msg := fmt.Sprintf(
 			"Restore resource [%s/%s] must be created in the velero namespace [%s]",
 			req.Namespace,
 			req.Name,
-			veleroNamespace,
+			getVeleroNamespace(restore.Spec.VeleroNamespace, validStorageLocations),
 		)
 		updateRestoreStatus(restoreLogger, v1beta1.RestorePhaseError, msg, restore)
 
 		return ctrl.Result{}, errors.Wrap(
-			r.Client.Status().Update(ctx, restore),
+			r.updateStatus(ctx, restore),
 			msg,
 		)
 	}
 
+	// check the storage location object store responds in time
+	if r.StorageLocationProbeTimeout > 0 {
+		probeMsg, err := probeStorageLocations(ctx, veleroStorageLocations.Items,
+			req.Namespace, r.StorageLocationProbeTimeout)
+		restore.Status.StorageLocationProbe = probeMsg
+		if err != nil {
+			msg := "Backup storage location is not reachable: " + err.Error()
+			restore.Status.StorageLocationProbe = err.Error()
+			r.Recorder.Event(restore, v1.EventTypeWarning, "Storage location probe:", err.Error())
+			updateRestoreStatus(restoreLogger, v1beta1.RestorePhaseError, msg, restore)
+			return r.retryFailedRestore(ctx, restore, msg)
+		}
+	}
+
 	if restore.Spec.CleanupBeforeRestore != v1beta1.CleanupTypeNone &&
-		restore.Status.Phase == "" {
+		restore.Status.Phase == "" && !restore.Spec.DryRun {
 		// update state only at the very beginning
 		restore.Status.Phase = v1beta1.RestorePhaseStarted
 		restore.Status.LastMessage = "Prepare to restore, cleaning up resources"
-		if err = r.Client.Status().Update(ctx, restore); err != nil {
+		if err = r.updateStatus(ctx, restore); err != nil {
 			restoreLogger.Info(err.Error())
 		}
 
 	}
 
+	if restore.Spec.DryRun {
+		// show the Velero restores which would be created, don't create them
+		if err := r.planVeleroRestores(ctx, restore); err != nil {
+			restore.Status.Phase = v1beta1.RestorePhaseError
+			restore.Status.LastMessage = err.Error()
+			return ctrl.Result{RequeueAfter: failureInterval}, errors.Wrap(
+				r.updateStatus(ctx, restore),
+				restore.Status.LastMessage,
+			)
+		}
+		return ctrl.Result{}, errors.Wrap(
+			r.updateStatus(ctx, restore),
+			restore.Status.LastMessage,
+		)
+	}
+	if !isStagedRestore(restore) {
+		restore.Status.PlannedRestores = nil
+	}
+
 	// retrieve the velero restore (if any)
 	veleroRestoreList := veleroapi.RestoreList{}
 	if err := r.List(
@@ -211,7 +294,28 @@ func (r *RestoreReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 	sync := isValidSync && restore.Status.Phase == v1beta1.RestorePhaseEnabled
 
 	if len(veleroRestoreList.Items) == 0 || sync {
-		if err := r.initVeleroRestores(ctx, restore, sync); err != nil {
+		err = r.initVeleroRestores(ctx, restore, sync)
+		// don't wait for backups if none in the storage location matches the restore source
+		noMatchingBackups := r.setNoMatchingBackups(ctx, restore, err)
+		if noMatchingBackups {
+			meta.RemoveStatusCondition(&restore.Status.Conditions, v1beta1.RestoreWaitingForBackups)
+		} else if retryAfter, waiting := setWaitingForBackups(restore, err, time.Now()); waiting {
+			// the backups are not synced yet from the storage location, look for them later
+			restoreLogger.Info(restore.Status.LastMessage, "retryAfter", retryAfter)
+			return ctrl.Result{RequeueAfter: retryAfter}, errors.Wrap(
+				r.updateStatus(ctx, restore),
+				restore.Status.LastMessage,
+			)
+		}
+		if _, blocked := err.(*sameHubRestoreError); blocked {
+			// the backups of this hub are not restored until allowSelfRestore is set,
+			// retrying the restore doesn't change this
+			restoreLogger.Info(err.Error())
+			restore.Status.Phase = v1beta1.RestorePhaseError
+			restore.Status.LastMessage = err.Error()
+			return ctrl.Result{}, r.updateStatus(ctx, restore)
+		}
+		if err != nil {
 			msg := fmt.Sprintf(
 				"unable to initialize Velero restores for restore %s/%s: %v",
 				req.Namespace,
@@ -224,14 +328,114 @@ func (r *RestoreReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 			)
 			// set error status for all errors from initVeleroRestores
 			restore.Status.Phase = v1beta1.RestorePhaseError
-			restore.Status.LastMessage = err.Error()
-			return ctrl.Result{RequeueAfter: failureInterval}, errors.Wrap(
-				r.Client.Status().Update(ctx, restore),
-				msg,
-			)
+			if !noMatchingBackups {
+				// the NoMatchingBackups message shows the backups found
+				restore.Status.LastMessage = err.Error()
+			}
+			return r.retryFailedRestore(ctx, restore, msg)
 		}
 	} else {
 		setRestorePhase(&veleroRestoreList, restore)
+		if r.isWaitingForConditions(ctx, &veleroRestoreList, restore) {
+			// check the wait conditions again after failureInterval
+			return ctrl.Result{RequeueAfter: failureInterval}, errors.Wrap(
+				r.updateStatus(ctx, restore),
+				restore.Status.LastMessage,
+			)
+		}
+		// the wait conditions are met, start the next Velero restore, if any
+		if created, err := r.createNextVeleroRestore(ctx, restore,
+			&veleroRestoreList); err != nil || created {
+			if err != nil {
+				msg := fmt.Sprintf(
+					"unable to create the next Velero restore for restore %s/%s: %v",
+					req.Namespace,
+					req.Name,
+					err,
+				)
+				restoreLogger.Error(err, msg)
+				restore.Status.Phase = v1beta1.RestorePhaseError
+				restore.Status.LastMessage = msg
+				return r.retryFailedRestore(ctx, restore, msg)
+			}
+			return ctrl.Result{}, errors.Wrap(
+				r.updateStatus(ctx, restore),
+				restore.Status.LastMessage,
+			)
+		}
+		// the managed clusters are restored, restore the placement decisions selecting them
+		if created, err := r.createPlacementDecisionsRestore(ctx, restore,
+			&veleroRestoreList); err != nil || created {
+			if err != nil {
+				msg := fmt.Sprintf(
+					"unable to create the placement decisions Velero restore for restore %s/%s: %v",
+					req.Namespace,
+					req.Name,
+					err,
+				)
+				restoreLogger.Error(err, msg)
+				restore.Status.Phase = v1beta1.RestorePhaseError
+				restore.Status.LastMessage = msg
+				return r.retryFailedRestore(ctx, restore, msg)
+			}
+			return ctrl.Result{}, errors.Wrap(
+				r.updateStatus(ctx, restore),
+				restore.Status.LastMessage,
+			)
+		}
+		if r.isWaitingForHubReadiness(ctx, &veleroRestoreList, restore) {
+			// check the hub readiness again after failureInterval
+			return ctrl.Result{RequeueAfter: failureInterval}, errors.Wrap(
+				r.updateStatus(ctx, restore),
+				restore.Status.LastMessage,
+			)
+		}
+		if (restore.Status.Phase == v1beta1.RestorePhaseFinished ||
+			restore.Status.Phase == v1beta1.RestorePhaseFinishedWithErrors) &&
+			restore.Status.VeleroManagedClustersRestoreName != "" &&
+			restore.Status.RestoredClusterFleet == "" {
+			// report the cluster sets and claims restored with the managed clusters
+			// once the restore is completed; the status shows the event was sent
+			msg := r.getRestoredClusterFleetMessage(ctx,
+				restore.Status.VeleroManagedClustersRestoreName)
+			if msg != "" {
+				r.Recorder.Event(
+					restore,
+					v1.EventTypeNormal,
+					"Restored cluster sets and claims:",
+					msg,
+				)
+			} else {
+				msg = fmt.Sprintf("%s restored no cluster sets or claims",
+					restore.Status.VeleroManagedClustersRestoreName)
+			}
+			restore.Status.RestoredClusterFleet = msg
+		}
+	}
+
+	// activate the restored managed clusters, once the managed clusters restore is completed
+	if restore.Status.Phase == v1beta1.RestorePhaseFinished ||
+		restore.Status.Phase == v1beta1.RestorePhaseFinishedWithErrors ||
+		restore.Status.Phase == v1beta1.RestorePhaseEnabled {
+		r.activateManagedClusters(ctx, restore, &veleroRestoreList)
+	}
+
+	// remove the finalizers set by the RemoveFinalizers rules from the restored resources
+	// in sync mode this runs on each sync, for the resources restored by the new backups
+	if len(restore.Spec.RemoveFinalizers) > 0 &&
+		(restore.Status.Phase == v1beta1.RestorePhaseFinished ||
+			restore.Status.Phase == v1beta1.RestorePhaseFinishedWithErrors ||
+			restore.Status.Phase == v1beta1.RestorePhaseEnabled) {
+		removed, err := r.removeRestoredFinalizers(ctx, restore, &veleroRestoreList)
+		if err != nil {
+			restoreLogger.Error(err, "Failed to remove finalizers from restored resources")
+			r.Recorder.Event(restore, v1.EventTypeWarning, "Removed finalizers:", err.Error())
+		}
+		if len(removed) > 0 {
+			restore.Status.RemovedFinalizers = removed
+			r.Recorder.Event(restore, v1.EventTypeNormal, "Removed finalizers:",
+				strings.Join(removed, ", "))
+		}
 	}
 
 	if restore.Spec.SyncRestoreWithNewBackups && !isValidSync {
@@ -240,16 +444,55 @@ func (r *RestoreReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 			msg
 	}
 
-	err = r.Client.Status().Update(ctx, restore)
-	return sendResult(restore, err)
+	err = r.updateStatus(ctx, restore)
+	return sendResult(restore, r.getSyncInterval(), err)
+}
+
+// returns the interval between the checks for new backups, if not set on the restore
+func (r *RestoreReconciler) getSyncInterval() time.Duration {
+	if r.SyncInterval > 0 {
+		return r.SyncInterval
+	}
+	return restoreSyncInterval
+}
+
+// set the status conditions for the current phase and update the restore status
+func (r *RestoreReconciler) updateStatus(
+	ctx context.Context,
+	restore *v1beta1.Restore,
+) error {
+	setRestoreConditions(restore)
+	return r.Client.Status().Update(ctx, restore)
+}
+
+// updates the status of a restore in Error phase and retries it later, with an exponential
+// backoff; the restore is not retried once the MaxRestoreAttempts are used
+func (r *RestoreReconciler) retryFailedRestore(
+	ctx context.Context,
+	restore *v1beta1.Restore,
+	msg string,
+) (ctrl.Result, error) {
+
+	retryAfter, retry := setNextRestoreAttempt(restore, time.Now())
+	if !retry {
+		r.Recorder.Event(restore, v1.EventTypeWarning, "Restore failed:",
+			fmt.Sprintf("restore failed after %d attempts: %s",
+				restore.Status.RestoreAttempts, restore.Status.LastMessage))
+		return ctrl.Result{}, errors.Wrap(r.updateStatus(ctx, restore), msg)
+	}
+	return ctrl.Result{RequeueAfter: retryAfter}, errors.Wrap(r.updateStatus(ctx, restore), msg)
 }
 
-func sendResult(restore *v1beta1.Restore, err error) (ctrl.Result, error) {
+func sendResult(
+	restore *v1beta1.Restore,
+	syncInterval time.Duration,
+	err error,
+) (ctrl.Result, error) {
 
 	if restore.Spec.SyncRestoreWithNewBackups &&
 		restore.Status.Phase == v1beta1.RestorePhaseEnabled {
 
-		tryAgain := restoreSyncInterval
+		tryAgain := syncInterval
 		if restore.Spec.RestoreSyncInterval.Duration != 0 {
 			tryAgain = restore.Spec.RestoreSyncInterval.Duration
 		}
@@ -291,8 +534,12 @@ func setRestorePhase(
 		return restore.Status.Phase
 	}
 
+	restore.Status.BackupTypeRestores = getBackupTypeRestores(veleroRestoreList)
+	restore.Status.RestoredItemsTotal = getRestoredItemsTotal(restore.Status.BackupTypeRestores)
+
 	// get all velero restores and check status for each
 	partiallyFailed := false
+	failedRestores := []string{}
 	for i := range veleroRestoreList.Items {
 		veleroRestore := veleroRestoreList.Items[i].DeepCopy()
 
@@ -322,6 +569,11 @@ func setRestorePhase(
 		}
 		if veleroRestore.Status.Phase == veleroapi.RestorePhaseFailed ||
 			veleroRestore.Status.Phase == veleroapi.RestorePhaseFailedValidation {
+			if restore.Spec.RestoreContinueOnError {
+				// check the Velero restores of the other backup types
+				failedRestores = append(failedRestores, veleroRestore.Name)
+				continue
+			}
 			restore.Status.Phase = v1beta1.RestorePhaseError
 			restore.Status.LastMessage = fmt.Sprintf(
 				"Velero restore %s has failed validation or encountered errors",
@@ -335,6 +587,24 @@ func setRestorePhase(
 		}
 	}
 
+	if len(failedRestores) == len(veleroRestoreList.Items) {
+		// the restore fails only if the Velero restores of all backup types failed
+		restore.Status.Phase = v1beta1.RestorePhaseError
+		restore.Status.LastMessage = fmt.Sprintf(
+			"All Velero restores %s have failed validation or encountered errors",
+			strings.Join(failedRestores, ", "),
+		)
+		return restore.Status.Phase
+	}
+	failedMsg := ""
+	if len(failedRestores) > 0 {
+		failedMsg = fmt.Sprintf(
+			"Velero restores %s have failed validation or encountered errors, "+
+				"the other backup types are restored",
+			strings.Join(failedRestores, ", "),
+		)
+	}
+
 	isValidSync, _ := isValidSyncOptions(restore)
 	// sync is enabled only when the backup name for managed clusters is set to skip
 	if isValidSync &&
@@ -342,6 +612,12 @@ func setRestorePhase(
 		restore.Status.Phase = v1beta1.RestorePhaseEnabled
 		restore.Status.LastMessage = "Velero restores have run to completion, " +
 			"restore will continue to sync with new backups"
+		if failedMsg != "" {
+			restore.Status.LastMessage = restore.Status.LastMessage + " ; " + failedMsg
+		}
+	} else if failedMsg != "" {
+		restore.Status.Phase = v1beta1.RestorePhaseFinishedWithErrors
+		restore.Status.LastMessage = failedMsg
 	} else if partiallyFailed {
 		restore.Status.Phase = v1beta1.RestorePhaseFinishedWithErrors
 		restore.Status.LastMessage = "Velero restores have run to completion but encountered 1+ errors"
@@ -353,6 +629,176 @@ func setRestorePhase(
 	return restore.Status.Phase
 }
 
+// once all Velero restores are completed, check the wait conditions
+// set on the restore resource; returns true if the restore resource should
+// stay in the Running phase until the restored resources match the conditions
+func (r *RestoreReconciler) isWaitingForConditions(
+	ctx context.Context,
+	veleroRestoreList *veleroapi.RestoreList,
+	restore *v1beta1.Restore,
+) bool {
+
+	if restore.Status.Phase != v1beta1.RestorePhaseFinished ||
+		len(restore.Spec.WaitConditions) == 0 {
+		return false
+	}
+
+	waitMsg, err := r.getUnmetWaitCondition(ctx, restore, veleroRestoreList)
+	if err != nil {
+		restore.Status.Phase = v1beta1.RestorePhaseFinishedWithErrors
+		restore.Status.LastMessage = "All Velero restores have run successfully " +
+			"but the wait conditions could not be checked: " + err.Error()
+		return false
+	}
+	if waitMsg == "" {
+		return false
+	}
+
+	// the timeout starts when the last Velero restore is completed
+	completedAt := getVeleroRestoresCompletionTime(veleroRestoreList)
+	waitTimeout := defaultWaitTimeout
+	if restore.Spec.WaitTimeout.Duration != 0 {
+		waitTimeout = restore.Spec.WaitTimeout.Duration
+	}
+	if !completedAt.IsZero() && time.Since(completedAt) > waitTimeout {
+		restore.Status.Phase = v1beta1.RestorePhaseFinishedWithErrors
+		restore.Status.LastMessage = fmt.Sprintf(
+			"All Velero restores have run successfully but the wait conditions "+
+				"were not met after %s. %s",
+			waitTimeout, waitMsg)
+		return false
+	}
+
+	restore.Status.Phase = v1beta1.RestorePhaseRunning
+	restore.Status.LastMessage = waitMsg
+	return true
+}
+
+// returns the completion time of the last completed Velero restore
+func getVeleroRestoresCompletionTime(veleroRestoreList *veleroapi.RestoreList) time.Time {
+
+	var completedAt time.Time
+	for i := range veleroRestoreList.Items {
+		completion := veleroRestoreList.Items[i].Status.CompletionTimestamp
+		if completion != nil && completion.Time.After(completedAt) {
+			completedAt = completion.Time
+		}
+	}
+	return completedAt
+}
+
+// once all Velero restores are completed and the wait conditions are met,
+// run the hub readiness checks set on the restore resource; returns true if the
+// restore resource should stay in the Running phase until all checks pass
+func (r *RestoreReconciler) isWaitingForHubReadiness(
+	ctx context.Context,
+	veleroRestoreList *veleroapi.RestoreList,
+	restore *v1beta1.Restore,
+) bool {
+
+	if restore.Status.Phase != v1beta1.RestorePhaseFinished ||
+		len(restore.Spec.HubReadinessChecks) == 0 {
+		return false
+	}
+
+	restore.Status.HubReadiness = []v1beta1.HubReadinessCheckStatus{}
+	pending := []string{}
+	for _, check := range restore.Spec.HubReadinessChecks {
+		checkStatus := r.getHubReadinessCheckStatus(ctx, restore, check)
+		restore.Status.HubReadiness = append(restore.Status.HubReadiness, checkStatus)
+		if !checkStatus.Ready {
+			pending = append(pending, fmt.Sprintf("%s: %s", check, checkStatus.Message))
+		}
+	}
+	if len(pending) == 0 {
+		return false
+	}
+	waitMsg := "Waiting for hub readiness checks. " + strings.Join(pending, "; ")
+
+	// the timeout starts when the last Velero restore is completed
+	completedAt := getVeleroRestoresCompletionTime(veleroRestoreList)
+	readinessTimeout := defaultReadyTimeout
+	if restore.Spec.HubReadinessTimeout.Duration != 0 {
+		readinessTimeout = restore.Spec.HubReadinessTimeout.Duration
+	}
+	if !completedAt.IsZero() && time.Since(completedAt) > readinessTimeout {
+		restore.Status.Phase = v1beta1.RestorePhaseFinishedWithErrors
+		restore.Status.LastMessage = fmt.Sprintf(
+			"All Velero restores have run successfully but the hub readiness checks "+
+				"did not pass after %s. %s",
+			readinessTimeout, waitMsg)
+		return false
+	}
+
+	restore.Status.Phase = v1beta1.RestorePhaseRunning
+	restore.Status.LastMessage = waitMsg
+	return true
+}
+
+// deletes the velero restores created by the restore being deleted, then removes the restore
+// finalizer; the velero restores not finished yet are deleted only if CleanupRestoresOnDelete
+// is set, otherwise the finalizer is removed once they are finished. Velero doesn't cancel
+// a deleted velero restore in progress, it keeps restoring the resources.
+// The finalizer is removed right away if velero is not installed
+func (r *RestoreReconciler) finalizeRestore(
+	ctx context.Context,
+	restore *v1beta1.Restore,
+) (ctrl.Result, error) {
+	restoreLogger := log.FromContext(ctx)
+
+	if !controllerutil.ContainsFinalizer(restore, restoreCleanupFinalizer) {
+		return ctrl.Result{}, nil
+	}
+
+	if msg := getVeleroNotInstalledMessage(ctx, r.DiscoveryClient); msg != "" {
+		// no velero restore to wait for or to delete
+		restoreLogger.Info("Removing the restore finalizer, " + msg)
+		controllerutil.RemoveFinalizer(restore, restoreCleanupFinalizer)
+		return ctrl.Result{}, client.IgnoreNotFound(r.Update(ctx, restore))
+	}
+
+	veleroRestoreList := veleroapi.RestoreList{}
+	if err := r.List(
+		ctx,
+		&veleroRestoreList,
+		client.InNamespace(restore.Namespace),
+		client.MatchingFields{restoreOwnerKey: restore.Name},
+	); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if inProgress := getUnfinishedVeleroRestores(veleroRestoreList.Items); len(inProgress) > 0 &&
+		!restore.Spec.CleanupRestoresOnDelete {
+		// the velero restores owner is notified when they are updated
+		msg := fmt.Sprintf("Waiting for Velero restores %s to finish before deleting the restore, "+
+			"set cleanupRestoresOnDelete to delete them now", strings.Join(inProgress, ", "))
+		restoreLogger.Info(msg)
+		restore.Status.LastMessage = msg
+		return ctrl.Result{}, errors.Wrap(
+			r.updateStatus(ctx, restore),
+			msg,
+		)
+	}
+
+	deleted := []string{}
+	for i := range veleroRestoreList.Items {
+		veleroRestore := &veleroRestoreList.Items[i]
+		if err := r.Delete(ctx, veleroRestore); client.IgnoreNotFound(err) != nil {
+			return ctrl.Result{}, errors.Wrapf(err, "could not delete velero restore %s",
+				veleroRestore.Name)
+		}
+		deleted = append(deleted, veleroRestore.Name)
+	}
+	if len(deleted) > 0 {
+		restoreLogger.Info("Velero restores deleted", "names", deleted)
+		r.Recorder.Event(restore, v1.EventTypeNormal, "Velero restores deleted:",
+			strings.Join(deleted, ", "))
+	}
+
+	controllerutil.RemoveFinalizer(restore, restoreCleanupFinalizer)
+	return ctrl.Result{}, client.IgnoreNotFound(r.Update(ctx, restore))
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *RestoreReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	if err := mgr.GetFieldIndexer().IndexField(
@@ -360,17 +806,11 @@ func (r *RestoreReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		&veleroapi.Restore{},
 		restoreOwnerKey,
 		func(rawObj client.Object) []string {
-			// grab the job object, extract the owner...
-			job := rawObj.(*veleroapi.Restore)
-			owner := metav1.GetControllerOf(job)
-			if owner == nil {
+			ownerName := getRestoreOwnerName(rawObj)
+			if ownerName == "" {
 				return nil
 			}
-			// ..should be a Restore in Group cluster.open-cluster-management.io
-			if owner.APIVersion != apiGVStr || owner.Kind != "Restore" {
-				return nil
-			}
-			return []string{owner.Name}
+			return []string{ownerName}
 		}); err != nil {
 		return err
 	}
@@ -380,6 +820,21 @@ func (r *RestoreReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		Complete(r)
 }
 
+// returns the name of the Restore controlling the velero restore,
+// or an empty string if the velero restore is not created by a Restore
+func getRestoreOwnerName(veleroRestore client.Object) string {
+
+	owner := metav1.GetControllerOf(veleroRestore)
+	if owner == nil {
+		return ""
+	}
+	// should be a Restore in Group cluster.open-cluster-management.io
+	if owner.APIVersion != apiGVStr || owner.Kind != "Restore" {
+		return ""
+	}
+	return owner.Name
+}
+
 // mostRecent defines type and code to sort velero backups
 // according to start timestamp
 type mostRecent []veleroapi.Backup
@@ -408,15 +863,50 @@ func (r *RestoreReconciler) getVeleroBackupName(
 	if len(veleroBackups.Items) == 0 {
 		return "", nil, fmt.Errorf("no velero backups found")
 	}
+	// use only the backups created by the source schedule, if set
+	veleroBackups.Items = filterBackupsBySourceSchedule(restore, veleroBackups.Items)
+	if len(veleroBackups.Items) == 0 {
+		return "", nil, fmt.Errorf("no velero backups found for schedule %s",
+			restore.Spec.SourceScheduleName)
+	}
+	// use only the backups created on the source hub, if set
+	veleroBackups.Items = filterBackupsBySourceHub(restore, veleroBackups.Items)
+	if len(veleroBackups.Items) == 0 {
+		return "", nil, fmt.Errorf("no velero backups found for hub %s",
+			restore.Spec.SourceHubID)
+	}
+	// use only the backups synced from the restore storage location, if set
+	veleroBackups.Items = filterBackupsByStorageLocation(
+		restore.Status.StorageLocation, veleroBackups.Items)
+	if len(veleroBackups.Items) == 0 {
+		return "", nil, fmt.Errorf("no velero backups found in storage location %s",
+			restore.Status.StorageLocation)
+	}
 
 	if backupName == latestBackupStr {
 		// backup name not available, find a proper backup
-		// filter available backups to get only the ones related to this resource type
+		// the backups of all types are restored from the same storage location
+		if restore.Status.StorageLocation == "" {
+			veleroBackups.Items = filterBackupsByLatestStorageLocation(veleroBackups.Items)
+		}
+		// filter available backups to get only the ones related to this resource type;
+		// only Completed backups pass the restore backup validation
 		relatedBackups := filterBackups(veleroBackups.Items, func(bkp veleroapi.Backup) bool {
-			return strings.HasPrefix(bkp.Name, veleroScheduleNames[resourceType]) &&
-				(bkp.Status.Phase == veleroapi.BackupPhaseCompleted ||
-					bkp.Status.Phase == veleroapi.BackupPhasePartiallyFailed)
+			return strings.HasPrefix(bkp.Name, getBackupTypeScheduleName(resourceType)) &&
+				bkp.Status.Phase == veleroapi.BackupPhaseCompleted
 		})
+		if restore.Spec.RestoreToTime != nil {
+			// restore the state of the hub at the requested time
+			backup := findBackupBeforeTime(relatedBackups, restore.Spec.RestoreToTime.Time)
+			if backup == nil {
+				return "", nil, fmt.Errorf(
+					"no Completed backup found for resource type %s created at or before %s",
+					resourceType,
+					restore.Spec.RestoreToTime.UTC().Format(time.RFC3339),
+				)
+			}
+			return backup.Name, backup, nil
+		}
 		if len(relatedBackups) == 0 {
 			return "", nil, fmt.Errorf("no backups found")
 		}
@@ -426,12 +916,20 @@ func (r *RestoreReconciler) getVeleroBackupName(
 
 	// get the backup name for this type of resource, based on the requested resource timestamp
 	switch resourceType {
-	case CredentialsHive, CredentialsCluster, ResourcesGeneric:
+	case CredentialsHive, CredentialsCluster, ResourcesGeneric, NamespaceContents:
+		// use the backups stored in the same storage location as the paired backup
+		for i := range veleroBackups.Items {
+			if veleroBackups.Items[i].Name == backupName {
+				veleroBackups.Items = filterBackupsByStorageLocation(
+					veleroBackups.Items[i].Spec.StorageLocation, veleroBackups.Items)
+				break
+			}
+		}
 		// first try to find a backup for this resourceType with the exact timestamp
 		var computedName string
 		backupTimestamp := strings.LastIndex(backupName, "-")
 		if backupTimestamp != -1 {
-			computedName = veleroScheduleNames[resourceType] + backupName[backupTimestamp:]
+			computedName = getBackupTypeScheduleName(resourceType) + backupName[backupTimestamp:]
 		}
 		exactTimeBackup := filterBackups(veleroBackups.Items, func(bkp veleroapi.Backup) bool {
 			return computedName == bkp.Name
@@ -441,7 +939,7 @@ func (r *RestoreReconciler) getVeleroBackupName(
 		}
 		// next try to find a backup with StartTimestamp in 30s range of the target timestamp
 		targetTimestamp, err := getBackupTimestamp(backupName)
-		if err != nil || targetTimestamp.IsZero() {
+		if err != nil {
 			return "", nil, fmt.Errorf(
 				"cannot find %s Velero Backup for resourceType %s",
 				backupName,
@@ -449,7 +947,7 @@ func (r *RestoreReconciler) getVeleroBackupName(
 			)
 		}
 		timeRangeBackups := filterBackups(veleroBackups.Items[:], func(bkp veleroapi.Backup) bool {
-			if !strings.Contains(bkp.Name, veleroScheduleNames[resourceType]) ||
+			if !strings.Contains(bkp.Name, getBackupTypeScheduleName(resourceType)) ||
 				bkp.Status.StartTimestamp == nil {
 				return false
 			}
@@ -478,6 +976,19 @@ func (r *RestoreReconciler) getVeleroBackupName(
 		&veleroBackup,
 	)
 	if err == nil {
+		if len(filterBackupsBySourceSchedule(restore, []veleroapi.Backup{veleroBackup})) == 0 {
+			return "", nil, fmt.Errorf("backup %s was not created by schedule %s",
+				backupName, restore.Spec.SourceScheduleName)
+		}
+		if len(filterBackupsBySourceHub(restore, []veleroapi.Backup{veleroBackup})) == 0 {
+			return "", nil, fmt.Errorf("backup %s was not created on hub %s",
+				backupName, restore.Spec.SourceHubID)
+		}
+		if len(filterBackupsByStorageLocation(restore.Status.StorageLocation,
+			[]veleroapi.Backup{veleroBackup})) == 0 {
+			return "", nil, fmt.Errorf("backup %s is not stored in storage location %s",
+				backupName, restore.Status.StorageLocation)
+		}
 		return backupName, &veleroBackup, nil
 	}
 	return "", nil, fmt.Errorf("cannot find %s Velero Backup: %v", backupName, err)
@@ -511,11 +1022,13 @@ func (r *RestoreReconciler) initVeleroRestores(
 	}
 
 	// loop through resourceTypes to create a Velero restore per type
+	detailsCtx, span := startSpan(ctx, "retrieve restore details", restore.Namespace, restore.Name)
 	veleroRestoresToCreate, backupsForVeleroRestores, err := r.retrieveRestoreDetails(
-		ctx,
+		detailsCtx,
 		restore,
 		restoreOnlyManagedClusters,
 	)
+	endSpan(span, err)
 	if err != nil {
 		return err
 	}
@@ -525,68 +1038,67 @@ func (r *RestoreReconciler) initVeleroRestores(
 		return nil
 	}
 
+	// don't restore backups which are not completed, are empty or
+	// stored in a storage location not available
+	veleroStorageLocations := &veleroapi.BackupStorageLocationList{}
+	if err := r.List(ctx, veleroStorageLocations, client.InNamespace(restore.Namespace)); err != nil {
+		return fmt.Errorf("unable to list velero storage locations: %v", err)
+	}
+	err = validateRestoreBackups(backupsForVeleroRestores, veleroStorageLocations.Items)
+	setBackupInvalidCondition(restore, err)
+	if err != nil {
+		r.Recorder.Event(restore, v1.EventTypeWarning, "Backup invalid:", err.Error())
+		return err
+	}
+
+	// don't restore the backups created on this hub, unless explicitly allowed
+	hubID, err := r.hubID.get(ctx, r.DynamicClient, r.RESTMapper)
+	if err != nil {
+		restoreLogger.Error(err, "Failed to get the hub identification")
+	}
+	if err := checkSameHubRestore(restore, backupsForVeleroRestores, hubID); err != nil {
+		r.Recorder.Event(restore, v1.EventTypeWarning, "Same hub restore:", err.Error())
+		return err
+	}
+
 	// clean up resources only if requested
-	if err := r.prepareForRestore(ctx, *restore, veleroRestoresToCreate,
-		backupsForVeleroRestores); err != nil {
+	prepareCtx, span := startSpan(ctx, "prepare for restore", restore.Namespace, restore.Name)
+	err = r.prepareForRestore(prepareCtx, *restore, veleroRestoresToCreate,
+		backupsForVeleroRestores)
+	endSpan(span, err)
+	if err != nil {
 		// continue with the restore even if the prepare for restore returned some errors
 		// we don't know how much was cleaned up prior to the error so don't abort the action now
 		restoreLogger.Error(err, "Prepare for restore returned error")
 	}
 
 	newVeleroRestoreCreated := false
+	setGenericRestoreLabelSelector(veleroRestoresToCreate)
 
-	// now create the restore resources and start the actual restore
-	for key := range veleroRestoresToCreate {
+	staged := isStagedRestore(restore)
+	if staged {
+		// keep track of the Velero restores to create in the next stages
+		restore.Status.PlannedRestores = getPlannedVeleroRestores(veleroRestoresToCreate)
+	}
 
-		restoreObj := veleroRestoresToCreate[key]
-		if key == ResourcesGeneric &&
-			veleroRestoresToCreate[ManagedClusters] == nil {
-			// if restoring the resources but not the managed clusters,
-			// do not restore generic resources in the activation stage
-			if restoreObj.Spec.LabelSelector == nil {
-				labels := &metav1.LabelSelector{}
-				restoreObj.Spec.LabelSelector = labels
-
-				requirements := make([]metav1.LabelSelectorRequirement, 0)
-				restoreObj.Spec.LabelSelector.MatchExpressions = requirements
-			}
-			req := &metav1.LabelSelectorRequirement{}
-			req.Key = backupCredsClusterLabel
-			req.Operator = "NotIn"
-			req.Values = []string{"cluster-activation"}
-			restoreObj.Spec.LabelSelector.MatchExpressions = append(
-				restoreObj.Spec.LabelSelector.MatchExpressions,
-				*req,
-			)
-		}
-		if key == ResourcesGeneric &&
-			veleroRestoresToCreate[Resources] == nil {
-			// if restoring the ManagedClusters and resources are not restored
-			// need to restore the generic resources for the activation phase
-			if restoreObj.Spec.LabelSelector == nil {
-				labels := &metav1.LabelSelector{}
-				restoreObj.Spec.LabelSelector = labels
+	// now create the restore resources and start the actual restore,
+	// in the order the backup types must be restored
+	for _, key := range getVeleroRestoreOrder(veleroRestoresToCreate) {
 
-				requirements := make([]metav1.LabelSelectorRequirement, 0)
-				restoreObj.Spec.LabelSelector.MatchExpressions = requirements
+		restoreObj := veleroRestoresToCreate[key]
+		_, createSpan := startSpan(ctx, "create Velero restore",
+			veleroRestoresToCreate[key].Namespace, veleroRestoresToCreate[key].Name)
+		created, err := createVeleroResource(ctx, r.Client, veleroRestoresToCreate[key])
+		endSpan(createSpan, err)
+		if err != nil || !created {
+			if err != nil {
+				restoreLogger.Error(
+					err,
+					"unable to create Velero restore for restore",
+					"namespace", veleroRestoresToCreate[key].Namespace,
+					"name", veleroRestoresToCreate[key].Name,
+				)
 			}
-			req := &metav1.LabelSelectorRequirement{}
-			req.Key = backupCredsClusterLabel
-			req.Operator = "In"
-			req.Values = []string{"cluster-activation"}
-			restoreObj.Spec.LabelSelector.MatchExpressions = append(
-				restoreObj.Spec.LabelSelector.MatchExpressions,
-				*req,
-			)
-		}
-		err := r.Create(ctx, veleroRestoresToCreate[key], &client.CreateOptions{})
-		if err != nil {
-			restoreLogger.Error(
-				err,
-				"unable to create Velero restore for restore",
-				"namespace", veleroRestoresToCreate[key].Namespace,
-				"name", veleroRestoresToCreate[key].Name,
-			)
 			r.Recorder.Event(
 				restore,
 				v1.EventTypeNormal,
@@ -595,26 +1107,28 @@ func (r *RestoreReconciler) initVeleroRestores(
 			)
 		} else {
 			newVeleroRestoreCreated = true
-			r.Recorder.Event(
-				restore,
-				v1.EventTypeNormal,
-				"Velero restore created:",
-				veleroRestoresToCreate[key].Name,
-			)
-			switch key {
-			case ManagedClusters:
-				restore.Status.VeleroManagedClustersRestoreName = veleroRestoresToCreate[key].Name
-			case Credentials:
-				restore.Status.VeleroCredentialsRestoreName = veleroRestoresToCreate[key].Name
-			case Resources:
-				restore.Status.VeleroResourcesRestoreName = veleroRestoresToCreate[key].Name
-			}
+			r.setVeleroRestoreCreated(restore, key, restoreObj)
+		}
+		if staged {
+			// the next Velero restores are created once the resources
+			// restored by this one match the wait conditions
+			break
 		}
 	}
 
 	if newVeleroRestoreCreated {
+		// report the restore order for the placement resources
+		r.setPlacementRestoreOrder(restore)
 		restore.Status.Phase = v1beta1.RestorePhaseStarted
 		restore.Status.LastMessage = fmt.Sprintf("Restore %s started", restore.Name)
+		if restore.Spec.SourceScheduleName != "" {
+			restore.Status.LastMessage = restore.Status.LastMessage +
+				" using backups from schedule " + restore.Spec.SourceScheduleName
+		}
+		if restore.Status.StorageLocation != "" {
+			restore.Status.LastMessage = restore.Status.LastMessage +
+				" from storage location " + restore.Status.StorageLocation
+		}
 	} else {
 		restore.Status.Phase = v1beta1.RestorePhaseFinished
 		restore.Status.LastMessage = fmt.Sprintf(noopMsg, restore.Name)
@@ -622,6 +1136,295 @@ func (r *RestoreReconciler) initVeleroRestores(
 	return nil
 }
 
+// creates the next Velero restore planned by a staged restore, once all the Velero restores
+// created so far are completed and their restored resources match the wait conditions;
+// returns true if a Velero restore was created
+func (r *RestoreReconciler) createNextVeleroRestore(
+	ctx context.Context,
+	restore *v1beta1.Restore,
+	veleroRestoreList *veleroapi.RestoreList,
+) (bool, error) {
+
+	if restore.Status.Phase != v1beta1.RestorePhaseFinished || !isStagedRestore(restore) {
+		return false, nil
+	}
+	plannedRestore := getNextPlannedRestore(restore.Status.PlannedRestores, veleroRestoreList)
+	if plannedRestore == nil {
+		return false, nil
+	}
+
+	key := ResourceType(plannedRestore.Type)
+	veleroBackup := &veleroapi.Backup{}
+	if err := r.Get(ctx, types.NamespacedName{
+		Name:      plannedRestore.BackupName,
+		Namespace: restore.Namespace,
+	}, veleroBackup); err != nil {
+		return false, fmt.Errorf("cannot find %s Velero Backup: %v", plannedRestore.BackupName, err)
+	}
+	excludedRBACResources, err := resolveClusterRBACResources(r.RESTMapper, restore)
+	if err != nil {
+		return false, err
+	}
+	veleroRestore := newVeleroRestore(restore, key, plannedRestore.BackupName,
+		excludedRBACResources)
+	// the label selector could have been updated for the stage, see setGenericRestoreLabelSelector
+	veleroRestore.Spec.LabelSelector, err = parsePlannedLabelSelector(plannedRestore.LabelSelector)
+	if err != nil {
+		return false, err
+	}
+	if err := ctrl.SetControllerReference(restore, veleroRestore, r.Scheme); err != nil {
+		return false, err
+	}
+
+	_, createSpan := startSpan(ctx, "create Velero restore",
+		veleroRestore.Namespace, veleroRestore.Name)
+	created, err := createVeleroResource(ctx, r.Client, veleroRestore)
+	endSpan(createSpan, err)
+	if err != nil || !created {
+		// not created if it was created by a previous reconcile
+		return false, err
+	}
+	r.setVeleroRestoreCreated(restore, key, veleroRestore)
+	restore.Status.Phase = v1beta1.RestorePhaseStarted
+	restore.Status.LastMessage = fmt.Sprintf(
+		"Restore %s started the %s Velero restore, the wait conditions are met",
+		restore.Name, key)
+	return true, nil
+}
+
+// creates the Velero restore of the placement decisions, from the managed clusters backup,
+// once the managed clusters Velero restore is completed: the placement decisions select
+// managed clusters and Velero 1.7 doesn't support resource priorities on a restore, so the
+// managed clusters Velero restore doesn't restore them; returns true if the Velero restore was created
+func (r *RestoreReconciler) createPlacementDecisionsRestore(
+	ctx context.Context,
+	restore *v1beta1.Restore,
+	veleroRestoreList *veleroapi.RestoreList,
+) (bool, error) {
+
+	if (restore.Status.Phase != v1beta1.RestorePhaseFinished &&
+		restore.Status.Phase != v1beta1.RestorePhaseFinishedWithErrors) ||
+		restore.Status.VeleroManagedClustersRestoreName == "" ||
+		restore.Status.VeleroPlacementDecisionsRestoreName != "" {
+		return false, nil
+	}
+	managedClustersRestore := getCompletedVeleroRestore(veleroRestoreList,
+		restore.Status.VeleroManagedClustersRestoreName)
+	if managedClustersRestore == nil ||
+		len(r.getAvailableKinds([]string{placementDecisionResource})) == 0 {
+		// the managed clusters are not restored, or there are no placement decisions to restore
+		return false, nil
+	}
+
+	veleroRestore := newPlacementDecisionsRestore(restore, managedClustersRestore)
+	if err := ctrl.SetControllerReference(restore, veleroRestore, r.Scheme); err != nil {
+		return false, err
+	}
+	_, createSpan := startSpan(ctx, "create Velero restore",
+		veleroRestore.Namespace, veleroRestore.Name)
+	created, err := createVeleroResource(ctx, r.Client, veleroRestore)
+	endSpan(createSpan, err)
+	if err != nil {
+		return false, err
+	}
+	// not created if it was created by a previous reconcile, show it in the status anyway
+	restore.Status.VeleroPlacementDecisionsRestoreName = veleroRestore.Name
+	r.setPlacementRestoreOrder(restore)
+	if !created {
+		return false, nil
+	}
+	r.Recorder.Event(
+		restore,
+		v1.EventTypeNormal,
+		"Velero restore created:",
+		veleroRestore.Name,
+	)
+	restore.Status.Phase = v1beta1.RestorePhaseStarted
+	restore.Status.LastMessage = fmt.Sprintf(
+		"Restore %s started the placement decisions Velero restore %s, %s is completed",
+		restore.Name, veleroRestore.Name, managedClustersRestore.Name)
+	return true, nil
+}
+
+// returns the Velero restore with the name, if it completed or partially failed, nil otherwise
+func getCompletedVeleroRestore(
+	veleroRestoreList *veleroapi.RestoreList,
+	name string,
+) *veleroapi.Restore {
+
+	for i := range veleroRestoreList.Items {
+		veleroRestore := &veleroRestoreList.Items[i]
+		if veleroRestore.Name == name &&
+			(veleroRestore.Status.Phase == veleroapi.RestorePhaseCompleted ||
+				veleroRestore.Status.Phase == veleroapi.RestorePhasePartiallyFailed) {
+			return veleroRestore
+		}
+	}
+	return nil
+}
+
+// records the events for the Velero restore created for the backup type
+// and shows its name in the restore status
+func (r *RestoreReconciler) setVeleroRestoreCreated(
+	restore *v1beta1.Restore,
+	key ResourceType,
+	restoreObj *veleroapi.Restore,
+) {
+
+	r.Recorder.Event(
+		restore,
+		v1.EventTypeNormal,
+		"Velero restore created:",
+		restoreObj.Name,
+	)
+	if restore.Spec.RestoreLabelSelector != nil {
+		r.Recorder.Event(
+			restore,
+			v1.EventTypeNormal,
+			"Velero restore label selector:",
+			fmt.Sprintf("%s restore %s uses label selector %s",
+				key,
+				restoreObj.Name,
+				metav1.FormatLabelSelector(restoreObj.Spec.LabelSelector),
+			),
+		)
+	}
+	if restore.Spec.SourceScheduleName != "" {
+		r.Recorder.Event(
+			restore,
+			v1.EventTypeNormal,
+			"Velero restore source schedule:",
+			fmt.Sprintf("%s restore %s uses backup %s created by schedule %s",
+				key,
+				restoreObj.Name,
+				restoreObj.Spec.BackupName,
+				restore.Spec.SourceScheduleName,
+			),
+		)
+	}
+	if restore.Status.StorageLocation != "" {
+		r.Recorder.Event(
+			restore,
+			v1.EventTypeNormal,
+			"Velero restore storage location:",
+			fmt.Sprintf("%s restore %s uses backup %s from storage location %s",
+				key,
+				restoreObj.Name,
+				restoreObj.Spec.BackupName,
+				restore.Status.StorageLocation,
+			),
+		)
+	}
+	switch key {
+	case ManagedClusters:
+		restore.Status.VeleroManagedClustersRestoreName = restoreObj.Name
+	case Credentials:
+		restore.Status.VeleroCredentialsRestoreName = restoreObj.Name
+	case Resources:
+		restore.Status.VeleroResourcesRestoreName = restoreObj.Name
+	}
+}
+
+// restore the generic resources used to activate the managed clusters
+// only when the managed clusters are restored
+func setGenericRestoreLabelSelector(veleroRestoresToCreate map[ResourceType]*veleroapi.Restore) {
+
+	restoreObj := veleroRestoresToCreate[ResourcesGeneric]
+	if restoreObj == nil {
+		return
+	}
+	if veleroRestoresToCreate[ManagedClusters] == nil {
+		// if restoring the resources but not the managed clusters,
+		// do not restore generic resources in the activation stage
+		if restoreObj.Spec.LabelSelector == nil {
+			labels := &metav1.LabelSelector{}
+			restoreObj.Spec.LabelSelector = labels
+
+			requirements := make([]metav1.LabelSelectorRequirement, 0)
+			restoreObj.Spec.LabelSelector.MatchExpressions = requirements
+		}
+		req := &metav1.LabelSelectorRequirement{}
+		req.Key = backupCredsClusterLabel
+		req.Operator = "NotIn"
+		req.Values = []string{"cluster-activation"}
+		restoreObj.Spec.LabelSelector.MatchExpressions = append(
+			restoreObj.Spec.LabelSelector.MatchExpressions,
+			*req,
+		)
+	}
+	if veleroRestoresToCreate[Resources] == nil {
+		// if restoring the ManagedClusters and resources are not restored
+		// need to restore the generic resources for the activation phase
+		if restoreObj.Spec.LabelSelector == nil {
+			labels := &metav1.LabelSelector{}
+			restoreObj.Spec.LabelSelector = labels
+
+			requirements := make([]metav1.LabelSelectorRequirement, 0)
+			restoreObj.Spec.LabelSelector.MatchExpressions = requirements
+		}
+		req := &metav1.LabelSelectorRequirement{}
+		req.Key = backupCredsClusterLabel
+		req.Operator = "In"
+		req.Values = []string{"cluster-activation"}
+		restoreObj.Spec.LabelSelector.MatchExpressions = append(
+			restoreObj.Spec.LabelSelector.MatchExpressions,
+			*req,
+		)
+	}
+}
+
+// select the backups to restore and show the Velero restores which would be created
+// in the restore status, without creating them or cleaning up any resources
+func (r *RestoreReconciler) planVeleroRestores(
+	ctx context.Context,
+	restore *v1beta1.Restore,
+) error {
+
+	detailsCtx, span := startSpan(ctx, "retrieve restore details", restore.Namespace, restore.Name)
+	veleroRestoresToCreate, _, err := r.retrieveRestoreDetails(detailsCtx, restore, false)
+	endSpan(span, err)
+	if err != nil {
+		return err
+	}
+	setGenericRestoreLabelSelector(veleroRestoresToCreate)
+
+	restore.Status.PlannedRestores = getPlannedVeleroRestores(veleroRestoresToCreate)
+	restore.Status.Phase = v1beta1.RestorePhaseFinished
+	restore.Status.LastMessage = fmt.Sprintf(
+		"Dry run for restore %s completed, %d Velero restores would be created",
+		restore.Name, len(restore.Status.PlannedRestores))
+	return nil
+}
+
+// resolve the storage location set on the restore resource, by name or prefix,
+// and keep track of it in the restore status
+func (r *RestoreReconciler) resolveRestoreStorageLocation(
+	ctx context.Context,
+	acmRestore *v1beta1.Restore,
+) error {
+
+	acmRestore.Status.StorageLocation = ""
+	if acmRestore.Spec.StorageLocation == "" && acmRestore.Spec.StorageLocationPrefix == "" {
+		return nil
+	}
+
+	veleroStorageLocations := &veleroapi.BackupStorageLocationList{}
+	if err := r.Client.List(ctx, veleroStorageLocations,
+		client.InNamespace(acmRestore.Namespace)); err != nil {
+		return fmt.Errorf("unable to list velero storage locations: %v", err)
+	}
+	storageLocation, err := findRestoreStorageLocation(acmRestore, veleroStorageLocations.Items)
+	if err != nil {
+		return err
+	}
+
+	log.FromContext(ctx).Info("restore uses backups from storage location",
+		"name", storageLocation.Name,
+		"path", getStorageLocationPath(storageLocation))
+	acmRestore.Status.StorageLocation = storageLocation.Name
+	return nil
+}
+
 // retrieve the backup details for this restore object
 // based on the restore spec options
 func (r *RestoreReconciler) retrieveRestoreDetails(
@@ -634,6 +1437,43 @@ func (r *RestoreReconciler) retrieveRestoreDetails(
 
 	restoreLogger := log.FromContext(ctx)
 
+	if err := validateRestoreLabelSelector(acmRestore); err != nil {
+		acmRestore.Status.LastMessage = err.Error()
+		return nil, nil, err
+	}
+	if err := validateSourceScheduleName(acmRestore); err != nil {
+		acmRestore.Status.LastMessage = err.Error()
+		return nil, nil, err
+	}
+	if err := validateWaitConditions(acmRestore); err != nil {
+		acmRestore.Status.LastMessage = err.Error()
+		return nil, nil, err
+	}
+	if err := validateRemoveFinalizers(acmRestore); err != nil {
+		acmRestore.Status.LastMessage = err.Error()
+		return nil, nil, err
+	}
+	if err := validateIncludedBackupTypes(acmRestore); err != nil {
+		acmRestore.Status.LastMessage = err.Error()
+		return nil, nil, err
+	}
+	if err := validateNamespaceMapping(acmRestore); err != nil {
+		acmRestore.Status.LastMessage = err.Error()
+		return nil, nil, err
+	}
+	excludedRBACResources, err := resolveClusterRBACResources(r.RESTMapper, acmRestore)
+	if err != nil {
+		acmRestore.Status.LastMessage = err.Error()
+		return nil, nil, err
+	}
+	if err := r.resolveRestoreStorageLocation(ctx, acmRestore); err != nil {
+		acmRestore.Status.LastMessage = err.Error()
+		return nil, nil, err
+	}
+	if !restoreOnlyManagedClusters {
+		r.setLatestBackupSets(ctx, acmRestore)
+	}
+
 	restoreLength := len(veleroScheduleNames) - 1 // ignore validation backup
 	if restoreOnlyManagedClusters {
 		restoreLength = 1 // will get only managed clusters
@@ -649,6 +1489,10 @@ func (r *RestoreReconciler) retrieveRestoreDetails(
 		}
 		restoreKeys = append(restoreKeys, key)
 	}
+	if !restoreOnlyManagedClusters {
+		// the labeled namespaces contents, backed up only in the NamespaceContents mode
+		restoreKeys = append(restoreKeys, NamespaceContents)
+	}
 	// sort restores to restore last credentials, first resources
 	// credentials could have owners in the resources path
 	sort.Slice(restoreKeys, func(i, j int) bool {
@@ -656,11 +1500,20 @@ func (r *RestoreReconciler) retrieveRestoreDetails(
 	})
 	veleroRestoresToCreate := make(map[ResourceType]*veleroapi.Restore, len(restoreKeys))
 	backupsForVeleroRestores := make(map[ResourceType]*veleroapi.Backup, len(restoreKeys))
+	if !restoreOnlyManagedClusters {
+		acmRestore.Status.SkippedBackupTypes = nil
+	}
 
 	for i := range restoreKeys {
 		backupName := latestBackupStr
 
 		key := restoreKeys[i]
+		if !isBackupTypeIncluded(acmRestore, key) {
+			// only the backup types set by the user are restored
+			acmRestore.Status.SkippedBackupTypes = appendUnique(
+				acmRestore.Status.SkippedBackupTypes, string(key))
+			continue
+		}
 		switch key {
 		case ManagedClusters:
 			if acmRestore.Spec.VeleroManagedClustersBackupName != nil {
@@ -685,6 +1538,13 @@ func (r *RestoreReconciler) retrieveRestoreDetails(
 				// for the resources with the label value 'cluster-activation'
 				backupName = *acmRestore.Spec.VeleroManagedClustersBackupName
 			}
+		case NamespaceContents:
+			// restored with the resources backup created at the same time;
+			// the resources are sorted and restored before the namespace contents
+			backupName = skipRestoreStr
+			if resourcesBackup := backupsForVeleroRestores[Resources]; resourcesBackup != nil {
+				backupName = resourcesBackup.Name
+			}
 		}
 
 		backupName = strings.ToLower(strings.TrimSpace(backupName))
@@ -700,10 +1560,11 @@ func (r *RestoreReconciler) retrieveRestoreDetails(
 		}
 
 		if backupName == skipRestoreStr {
+			acmRestore.Status.SkippedBackupTypes = appendUnique(
+				acmRestore.Status.SkippedBackupTypes, string(key))
 			continue
 		}
 
-		veleroRestore := &veleroapi.Restore{}
 		veleroBackupName, veleroBackup, err := r.getVeleroBackupName(
 			ctx,
 			acmRestore,
@@ -722,18 +1583,24 @@ func (r *RestoreReconciler) retrieveRestoreDetails(
 				backupName,
 				key,
 			)
+			if acmRestore.Spec.RestoreToTime != nil && backupName == latestBackupStr {
+				acmRestore.Status.LastMessage = err.Error()
+			}
 
-			if key != CredentialsHive && key != CredentialsCluster && key != ResourcesGeneric {
+			if key != CredentialsHive && key != CredentialsCluster && key != ResourcesGeneric &&
+				key != NamespaceContents {
 				// ignore missing hive or cluster key backup files
 				// for the case when the backups were created with an older controller version
+				// and missing namespace contents backups, created only for labeled namespaces
+				if backupName == latestBackupStr {
+					// the backups could be synced later from the storage location
+					err = &backupNotFoundError{err}
+				}
 				return veleroRestoresToCreate, backupsForVeleroRestores, err
 			}
 		} else {
-			veleroRestore.Name = getValidKsRestoreName(acmRestore.Name, veleroBackupName)
-
-			veleroRestore.Namespace = acmRestore.Namespace
-			veleroRestore.Spec.BackupName = veleroBackupName
-
+			veleroRestore := newVeleroRestore(acmRestore, key, veleroBackupName,
+				excludedRBACResources)
 			if err := ctrl.SetControllerReference(acmRestore, veleroRestore, r.Scheme); err != nil {
 				acmRestore.Status.LastMessage = fmt.Sprintf(
 					"Could not set controller reference for resource type: %s",
@@ -745,9 +1612,90 @@ func (r *RestoreReconciler) retrieveRestoreDetails(
 			backupsForVeleroRestores[key] = veleroBackup
 		}
 	}
+	if !restoreOnlyManagedClusters {
+		acmRestore.Status.ExcludedClusterResources = getExcludedClusterResources(
+			r.RESTMapper, acmRestore, backupsForVeleroRestores)
+	}
 	return veleroRestoresToCreate, backupsForVeleroRestores, nil
 }
 
+// returns the Velero restore for the backup type, restoring the Velero backup
+// with the options set on the restore resource
+func newVeleroRestore(
+	acmRestore *v1beta1.Restore,
+	key ResourceType,
+	veleroBackupName string,
+	excludedRBACResources []string,
+) *veleroapi.Restore {
+
+	veleroRestore := &veleroapi.Restore{}
+	veleroRestore.Name = getValidKsRestoreName(acmRestore.Name, veleroBackupName)
+
+	veleroRestore.Namespace = acmRestore.Namespace
+	veleroRestore.Spec.BackupName = veleroBackupName
+	// restore only resources matching the user defined selector, if any
+	veleroRestore.Spec.LabelSelector = acmRestore.Spec.RestoreLabelSelector.DeepCopy()
+	// restore the resources into the user defined namespaces, if any
+	if len(acmRestore.Spec.NamespaceMapping) > 0 {
+		veleroRestore.Spec.NamespaceMapping = make(map[string]string,
+			len(acmRestore.Spec.NamespaceMapping))
+		for source, target := range acmRestore.Spec.NamespaceMapping {
+			veleroRestore.Spec.NamespaceMapping[source] = target
+		}
+	}
+	// restore the cluster-scoped resources only if not disabled by the user
+	if acmRestore.Spec.IncludeClusterResources != nil {
+		includeClusterResources := *acmRestore.Spec.IncludeClusterResources
+		veleroRestore.Spec.IncludeClusterResources = &includeClusterResources
+	}
+	// restore only the cluster-scoped RBAC kinds selected by the user, if any,
+	// from the generic resources backup
+	veleroRestore.Spec.ExcludedResources = getRestoreExcludedResources(key, excludedRBACResources)
+	return veleroRestore
+}
+
+// returns the Velero restore of the placement decisions backed up with the managed clusters,
+// using the options of the managed clusters Velero restore
+func newPlacementDecisionsRestore(
+	acmRestore *v1beta1.Restore,
+	managedClustersRestore *veleroapi.Restore,
+) *veleroapi.Restore {
+
+	veleroRestore := &veleroapi.Restore{}
+	veleroRestore.Name = getValidKsRestoreName(acmRestore.Name+"-placementdecisions",
+		managedClustersRestore.Spec.BackupName)
+	veleroRestore.Namespace = acmRestore.Namespace
+	veleroRestore.Spec = *managedClustersRestore.Spec.DeepCopy()
+	veleroRestore.Spec.IncludedResources = []string{placementDecisionResource}
+	veleroRestore.Spec.ExcludedResources = nil
+	return veleroRestore
+}
+
+// shows the latest complete backup sets in the restore status when the restore
+// doesn't select the backups by name or by time, using the same source schedule,
+// source hub and storage location filters as the restored backups
+func (r *RestoreReconciler) setLatestBackupSets(
+	ctx context.Context,
+	acmRestore *v1beta1.Restore,
+) {
+
+	acmRestore.Status.LatestBackupSets = nil
+	if !isLatestBackupRestore(acmRestore) {
+		return
+	}
+
+	veleroBackups := &veleroapi.BackupList{}
+	if err := r.Client.List(ctx, veleroBackups, client.InNamespace(acmRestore.Namespace)); err != nil {
+		log.FromContext(ctx).Error(err, "unable to list the velero backups",
+			"namespace", acmRestore.Namespace)
+		return
+	}
+	backups := filterBackupsBySourceSchedule(acmRestore, veleroBackups.Items)
+	backups = filterBackupsBySourceHub(acmRestore, backups)
+	backups = filterBackupsByStorageLocation(acmRestore.Status.StorageLocation, backups)
+	acmRestore.Status.LatestBackupSets = getLatestBackupSets(backups, maxLatestBackupSets)
+}
+
 // before restore clean up resources if required
 func (r *RestoreReconciler) prepareForRestore(
 	ctx context.Context,
@@ -7,12 +7,16 @@ import (
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	v1beta1 "github.com/stolostron/cluster-backup-operator/api/v1beta1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
 	chnv1 "open-cluster-management.io/multicloud-operators-channel/pkg/apis/apps/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 
 	veleroapi "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
 )
@@ -432,6 +436,437 @@ var _ = Describe("Basic Restore controller", func() {
 		})
 	})
 
+	Context("When creating a Restore with dry run enabled", func() {
+		BeforeEach(func() {
+			veleroNamespace.Name = "velero-restore-ns-dry-run"
+			backupStorageLocation.Namespace = veleroNamespace.Name
+			for i := range veleroBackups {
+				veleroBackups[i].Namespace = veleroNamespace.Name
+			}
+			rhacmRestore.Namespace = veleroNamespace.Name
+			rhacmRestore.Spec.DryRun = true
+			rhacmRestore.Spec.SyncRestoreWithNewBackups = false
+		})
+		It("Should report the planned Velero restores without creating them", func() {
+			restoreLookupKey := types.NamespacedName{
+				Name:      restoreName,
+				Namespace: veleroNamespace.Name,
+			}
+			createdRestore := v1beta1.Restore{}
+			By("created restore should list the planned velero restores in status")
+			Eventually(func() v1beta1.RestorePhase {
+				k8sClient.Get(ctx, restoreLookupKey, &createdRestore)
+				return createdRestore.Status.Phase
+			}, timeout, interval).Should(BeEquivalentTo(v1beta1.RestorePhaseFinished))
+			Expect(createdRestore.Status.PlannedRestores).Should(HaveLen(6))
+			plannedTypes := []string{}
+			for _, plannedRestore := range createdRestore.Status.PlannedRestores {
+				plannedTypes = append(plannedTypes, plannedRestore.Type)
+			}
+			// the velero restores are created in this order, the managed clusters last
+			Expect(plannedTypes).Should(Equal([]string{
+				string(ResourcesGeneric),
+				string(Resources),
+				string(CredentialsHive),
+				string(CredentialsCluster),
+				string(Credentials),
+				string(ManagedClusters),
+			}))
+			Expect(createdRestore.Status.VeleroManagedClustersRestoreName).Should(BeEmpty())
+			Expect(
+				meta.IsStatusConditionTrue(
+					createdRestore.Status.Conditions,
+					v1beta1.RestoreDryRunComplete,
+				),
+			).Should(BeTrue())
+			Expect(
+				meta.FindStatusCondition(
+					createdRestore.Status.Conditions,
+					v1beta1.RestoreComplete,
+				),
+			).Should(BeNil())
+
+			By("no velero restore should be created")
+			veleroRestores := veleroapi.RestoreList{}
+			Consistently(func() int {
+				if err := k8sClient.List(ctx, &veleroRestores, client.InNamespace(veleroNamespace.Name)); err != nil {
+					return -1
+				}
+				return len(veleroRestores.Items)
+			}, time.Second*2, interval).Should(Equal(0))
+		})
+	})
+
+	Context("When creating a Restore with included backup types", func() {
+		BeforeEach(func() {
+			veleroNamespace.Name = "velero-restore-ns-included-types"
+			backupStorageLocation.Namespace = veleroNamespace.Name
+			for i := range veleroBackups {
+				veleroBackups[i].Namespace = veleroNamespace.Name
+			}
+			rhacmRestore.Namespace = veleroNamespace.Name
+			rhacmRestore.Spec.SyncRestoreWithNewBackups = false
+			rhacmRestore.Spec.IncludedBackupTypes = []string{string(Credentials)}
+		})
+		It("Should create Velero restores only for the included backup types", func() {
+			restoreLookupKey := types.NamespacedName{
+				Name:      restoreName,
+				Namespace: veleroNamespace.Name,
+			}
+			createdRestore := v1beta1.Restore{}
+			By("created restore should contain only the credentials velero restore in status")
+			Eventually(func() string {
+				k8sClient.Get(ctx, restoreLookupKey, &createdRestore)
+				return createdRestore.Status.VeleroCredentialsRestoreName
+			}, timeout, interval).Should(BeIdenticalTo(restoreName + "-" + veleroCredentialsBackupName))
+			Expect(createdRestore.Status.VeleroManagedClustersRestoreName).Should(BeEmpty())
+			Expect(createdRestore.Status.VeleroResourcesRestoreName).Should(BeEmpty())
+			Expect(createdRestore.Status.SkippedBackupTypes).Should(ConsistOf(
+				string(CredentialsHive),
+				string(CredentialsCluster),
+				string(Resources),
+				string(ResourcesGeneric),
+				string(NamespaceContents),
+				string(ManagedClusters),
+			))
+
+			By("no managed clusters velero restore should be created")
+			veleroRestores := veleroapi.RestoreList{}
+			Expect(k8sClient.List(ctx, &veleroRestores,
+				client.InNamespace(veleroNamespace.Name))).Should(Succeed())
+			Expect(veleroRestores.Items).Should(HaveLen(1))
+			Expect(veleroRestores.Items[0].Spec.BackupName).Should(Equal(veleroCredentialsBackupName))
+		})
+	})
+
+	Context("When deleting a Restore with Velero restores in progress", func() {
+		BeforeEach(func() {
+			veleroNamespace.Name = "velero-restore-ns-delete-wait"
+			backupStorageLocation.Namespace = veleroNamespace.Name
+			for i := range veleroBackups {
+				veleroBackups[i].Namespace = veleroNamespace.Name
+			}
+			rhacmRestore.Namespace = veleroNamespace.Name
+			rhacmRestore.Spec.SyncRestoreWithNewBackups = false
+			rhacmRestore.Spec.IncludedBackupTypes = []string{string(Credentials)}
+		})
+		It("Should delete the Velero restores once they are finished, then remove the restore", func() {
+			restoreLookupKey := types.NamespacedName{
+				Name:      restoreName,
+				Namespace: veleroNamespace.Name,
+			}
+			createdRestore := v1beta1.Restore{}
+			Eventually(func() []string {
+				k8sClient.Get(ctx, restoreLookupKey, &createdRestore)
+				return createdRestore.Finalizers
+			}, timeout, interval).Should(ContainElement(restoreCleanupFinalizer))
+			veleroRestores := veleroapi.RestoreList{}
+			Eventually(func() int {
+				if err := k8sClient.List(ctx, &veleroRestores,
+					client.InNamespace(veleroNamespace.Name)); err != nil {
+					return 0
+				}
+				return len(veleroRestores.Items)
+			}, timeout, interval).Should(Equal(1))
+
+			By("the restore is kept while the velero restore is in progress")
+			Expect(k8sClient.Delete(ctx, &createdRestore)).Should(Succeed())
+			Eventually(func() string {
+				k8sClient.Get(ctx, restoreLookupKey, &createdRestore)
+				return createdRestore.Status.LastMessage
+			}, timeout, interval).Should(ContainSubstring("Waiting for Velero restores"))
+			Consistently(func() error {
+				return k8sClient.Get(ctx, restoreLookupKey, &createdRestore)
+			}, time.Second*2, interval).Should(Succeed())
+			Expect(k8sClient.List(ctx, &veleroRestores,
+				client.InNamespace(veleroNamespace.Name))).Should(Succeed())
+			Expect(veleroRestores.Items).Should(HaveLen(1))
+
+			By("the velero restore is deleted once it is finished, then the restore is removed")
+			veleroRestore := veleroRestores.Items[0]
+			veleroRestore.Status.Phase = veleroapi.RestorePhaseCompleted
+			Expect(k8sClient.Update(ctx, &veleroRestore)).Should(Succeed())
+			Eventually(func() bool {
+				return apierrors.IsNotFound(k8sClient.Get(ctx, restoreLookupKey, &createdRestore))
+			}, timeout, interval).Should(BeTrue())
+			Eventually(func() int {
+				if err := k8sClient.List(ctx, &veleroRestores,
+					client.InNamespace(veleroNamespace.Name)); err != nil {
+					return -1
+				}
+				return len(veleroRestores.Items)
+			}, timeout, interval).Should(Equal(0))
+		})
+	})
+
+	Context("When deleting a Restore with cleanupRestoresOnDelete set", func() {
+		BeforeEach(func() {
+			veleroNamespace.Name = "velero-restore-ns-delete-cleanup"
+			backupStorageLocation.Namespace = veleroNamespace.Name
+			for i := range veleroBackups {
+				veleroBackups[i].Namespace = veleroNamespace.Name
+			}
+			rhacmRestore.Namespace = veleroNamespace.Name
+			rhacmRestore.Spec.SyncRestoreWithNewBackups = false
+			rhacmRestore.Spec.IncludedBackupTypes = []string{string(Credentials)}
+			rhacmRestore.Spec.CleanupRestoresOnDelete = true
+		})
+		It("Should delete the Velero restores in progress and remove the restore", func() {
+			restoreLookupKey := types.NamespacedName{
+				Name:      restoreName,
+				Namespace: veleroNamespace.Name,
+			}
+			createdRestore := v1beta1.Restore{}
+			Eventually(func() []string {
+				k8sClient.Get(ctx, restoreLookupKey, &createdRestore)
+				return createdRestore.Finalizers
+			}, timeout, interval).Should(ContainElement(restoreCleanupFinalizer))
+			veleroRestores := veleroapi.RestoreList{}
+			Eventually(func() int {
+				if err := k8sClient.List(ctx, &veleroRestores,
+					client.InNamespace(veleroNamespace.Name)); err != nil {
+					return 0
+				}
+				return len(veleroRestores.Items)
+			}, timeout, interval).Should(Equal(1))
+
+			Expect(k8sClient.Delete(ctx, &createdRestore)).Should(Succeed())
+			Eventually(func() bool {
+				return apierrors.IsNotFound(k8sClient.Get(ctx, restoreLookupKey, &createdRestore))
+			}, timeout, interval).Should(BeTrue())
+			Expect(k8sClient.List(ctx, &veleroRestores,
+				client.InNamespace(veleroNamespace.Name))).Should(Succeed())
+			Expect(veleroRestores.Items).Should(BeEmpty())
+		})
+	})
+
+	Context("When creating a Restore with a namespace mapping", func() {
+		BeforeEach(func() {
+			veleroNamespace.Name = "velero-restore-ns-namespace-mapping"
+			backupStorageLocation.Namespace = veleroNamespace.Name
+			for i := range veleroBackups {
+				veleroBackups[i].Namespace = veleroNamespace.Name
+			}
+			rhacmRestore.Namespace = veleroNamespace.Name
+			rhacmRestore.Spec.SyncRestoreWithNewBackups = false
+			rhacmRestore.Spec.NamespaceMapping = map[string]string{
+				"open-cluster-management-agent": "open-cluster-management-agent-restored",
+			}
+		})
+		It("Should create Velero restores with the namespace mapping", func() {
+			restoreLookupKey := types.NamespacedName{
+				Name:      restoreName,
+				Namespace: veleroNamespace.Name,
+			}
+			createdRestore := v1beta1.Restore{}
+			Eventually(func() string {
+				k8sClient.Get(ctx, restoreLookupKey, &createdRestore)
+				return createdRestore.Status.VeleroCredentialsRestoreName
+			}, timeout, interval).ShouldNot(BeEmpty())
+
+			By("all velero restores should carry the namespace mapping")
+			veleroRestores := veleroapi.RestoreList{}
+			Expect(k8sClient.List(ctx, &veleroRestores,
+				client.InNamespace(veleroNamespace.Name))).Should(Succeed())
+			Expect(veleroRestores.Items).ShouldNot(BeEmpty())
+			for _, veleroRestore := range veleroRestores.Items {
+				Expect(veleroRestore.Spec.NamespaceMapping).Should(Equal(
+					rhacmRestore.Spec.NamespaceMapping))
+			}
+		})
+	})
+
+	Context("When creating a Restore before the backups are synced", func() {
+		var syncedBackups []veleroapi.Backup
+
+		BeforeEach(func() {
+			veleroNamespace.Name = "velero-restore-ns-wait-for-backups"
+			backupStorageLocation.Namespace = veleroNamespace.Name
+			// the backups are created once the restore waits for them
+			syncedBackups = veleroBackups
+			for i := range syncedBackups {
+				syncedBackups[i].Namespace = veleroNamespace.Name
+			}
+			veleroBackups = []veleroapi.Backup{}
+			rhacmRestore.Namespace = veleroNamespace.Name
+			rhacmRestore.Spec.SyncRestoreWithNewBackups = false
+			rhacmRestore.Spec.VeleroManagedClustersBackupName = &skipRestore
+			rhacmRestore.Spec.VeleroCredentialsBackupName = &latestBackup
+			rhacmRestore.Spec.VeleroResourcesBackupName = &latestBackup
+			rhacmRestore.Spec.WaitForBackupsTimeout = metav1.Duration{Duration: time.Hour}
+		})
+		It("Should wait for the backups and restore them once they are synced", func() {
+			restoreLookupKey := types.NamespacedName{
+				Name:      restoreName,
+				Namespace: veleroNamespace.Name,
+			}
+			createdRestore := v1beta1.Restore{}
+			waitingForBackupsReason := func() string {
+				if err := k8sClient.Get(ctx, restoreLookupKey, &createdRestore); err != nil {
+					return ""
+				}
+				waiting := meta.FindStatusCondition(createdRestore.Status.Conditions,
+					v1beta1.RestoreWaitingForBackups)
+				if waiting == nil {
+					return ""
+				}
+				return waiting.Reason
+			}
+
+			By("the restore should wait for the backups")
+			Eventually(waitingForBackupsReason, timeout, interval).Should(
+				BeIdenticalTo(v1beta1.RestoreReasonBackupsNotFound))
+			Expect(createdRestore.Status.Phase).Should(BeEquivalentTo(v1beta1.RestorePhaseStarted))
+			Expect(meta.IsStatusConditionTrue(createdRestore.Status.Conditions,
+				v1beta1.RestoreWaitingForBackups)).Should(BeTrue())
+			Expect(createdRestore.Status.RestoreAttempts).Should(BeZero())
+
+			By("the backups are restored on a later reconcile, once they are synced")
+			for i := range syncedBackups {
+				Expect(k8sClient.Create(ctx, &syncedBackups[i])).Should(Succeed())
+			}
+			createdRestore.Labels = map[string]string{"backups-synced": "true"}
+			Expect(k8sClient.Update(ctx, &createdRestore)).Should(Succeed())
+			Eventually(waitingForBackupsReason, timeout, interval).Should(
+				BeIdenticalTo(v1beta1.RestoreReasonBackupsFound))
+			Expect(createdRestore.Status.VeleroCredentialsRestoreName).ShouldNot(BeEmpty())
+			Expect(createdRestore.Status.VeleroResourcesRestoreName).ShouldNot(BeEmpty())
+			Expect(meta.IsStatusConditionFalse(createdRestore.Status.Conditions,
+				v1beta1.RestoreWaitingForBackups)).Should(BeTrue())
+		})
+	})
+
+	Context("When a Restore fails MaxRestoreAttempts times", func() {
+		BeforeEach(func() {
+			veleroNamespace.Name = "velero-restore-ns-max-attempts"
+			backupStorageLocation.Namespace = veleroNamespace.Name
+			for i := range veleroBackups {
+				veleroBackups[i].Namespace = veleroNamespace.Name
+			}
+			rhacmRestore.Namespace = veleroNamespace.Name
+			rhacmRestore.Spec.SyncRestoreWithNewBackups = false
+			rhacmRestore.Spec.VeleroCredentialsBackupName = &invalidBackup
+			rhacmRestore.Spec.MaxRestoreAttempts = 1
+		})
+		It("Should not retry the restore", func() {
+			restoreLookupKey := types.NamespacedName{
+				Name:      restoreName,
+				Namespace: veleroNamespace.Name,
+			}
+			createdRestore := v1beta1.Restore{}
+			By("the restore should fail with the attempts exhausted")
+			Eventually(func() string {
+				if err := k8sClient.Get(ctx, restoreLookupKey, &createdRestore); err != nil {
+					return ""
+				}
+				failed := meta.FindStatusCondition(createdRestore.Status.Conditions,
+					v1beta1.RestoreFailed)
+				if failed == nil {
+					return ""
+				}
+				return failed.Reason
+			}, timeout, interval).Should(BeIdenticalTo(v1beta1.RestoreReasonAttemptsExhausted))
+			Expect(createdRestore.Status.Phase).Should(BeEquivalentTo(v1beta1.RestorePhaseError))
+			Expect(createdRestore.Status.RestoreAttempts).Should(Equal(1))
+
+			By("the restore should not be reconciled again")
+			createdRestore.Labels = map[string]string{"retry": "true"}
+			Expect(k8sClient.Update(ctx, &createdRestore)).Should(Succeed())
+			Consistently(func() int {
+				if err := k8sClient.Get(ctx, restoreLookupKey, &createdRestore); err != nil {
+					return -1
+				}
+				return createdRestore.Status.RestoreAttempts
+			}, time.Second*3, interval).Should(Equal(1))
+			veleroRestores := veleroapi.RestoreList{}
+			Expect(k8sClient.List(ctx, &veleroRestores,
+				client.InNamespace(veleroNamespace.Name))).Should(Succeed())
+			Expect(veleroRestores.Items).Should(BeEmpty())
+		})
+	})
+
+	Context("When activating the restored managed clusters", func() {
+		BeforeEach(func() {
+			veleroNamespace.Name = "velero-restore-ns-activation"
+			backupStorageLocation.Namespace = veleroNamespace.Name
+			for i := range veleroBackups {
+				veleroBackups[i].Namespace = veleroNamespace.Name
+			}
+			rhacmRestore.Namespace = veleroNamespace.Name
+			rhacmRestore.Spec.SyncRestoreWithNewBackups = false
+			rhacmRestore.Spec.ActivateManagedClusters = true
+		})
+		It("Should activate the managed clusters only after the managed clusters restore is completed", func() {
+			veleroRestoreName := restoreName + "-" + veleroManagedClustersBackupName
+			managedClusterNames := []string{"activation-cluster-1", "activation-cluster-2"}
+			for _, name := range managedClusterNames {
+				Expect(k8sClient.Create(ctx, &clusterv1.ManagedCluster{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:   name,
+						Labels: map[string]string{"velero.io/restore-name": veleroRestoreName},
+					},
+					Spec: clusterv1.ManagedClusterSpec{
+						HubAcceptsClient: false,
+					},
+				})).Should(Succeed())
+			}
+			isAccepted := func(name string) bool {
+				managedCluster := clusterv1.ManagedCluster{}
+				Expect(k8sClient.Get(ctx, types.NamespacedName{Name: name},
+					&managedCluster)).Should(Succeed())
+				return managedCluster.Spec.HubAcceptsClient
+			}
+
+			reconciler := &RestoreReconciler{
+				Client:   k8sClient,
+				Recorder: record.NewFakeRecorder(10),
+			}
+			restore := rhacmRestore.DeepCopy()
+			restore.Status.VeleroManagedClustersRestoreName = veleroRestoreName
+			veleroRestoreList := veleroapi.RestoreList{
+				Items: []veleroapi.Restore{
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:      veleroRestoreName,
+							Namespace: veleroNamespace.Name,
+						},
+					},
+				},
+			}
+
+			for _, phase := range []veleroapi.RestorePhase{
+				veleroapi.RestorePhaseInProgress,
+				veleroapi.RestorePhasePartiallyFailed,
+			} {
+				By("the managed clusters should not be activated when the restore is " + string(phase))
+				veleroRestoreList.Items[0].Status.Phase = phase
+				reconciler.activateManagedClusters(ctx, restore, &veleroRestoreList)
+				activated := meta.FindStatusCondition(restore.Status.Conditions,
+					v1beta1.RestoreActivated)
+				Expect(activated).NotTo(BeNil())
+				Expect(activated.Status).Should(Equal(metav1.ConditionFalse))
+				Expect(activated.Reason).Should(Equal(v1beta1.RestoreReasonActivationPending))
+				for _, name := range managedClusterNames {
+					Expect(isAccepted(name)).Should(BeFalse())
+				}
+			}
+
+			By("the managed clusters should be activated when the restore is Completed")
+			veleroRestoreList.Items[0].Status.Phase = veleroapi.RestorePhaseCompleted
+			reconciler.activateManagedClusters(ctx, restore, &veleroRestoreList)
+			activated := meta.FindStatusCondition(restore.Status.Conditions,
+				v1beta1.RestoreActivated)
+			Expect(activated).NotTo(BeNil())
+			Expect(activated.Status).Should(Equal(metav1.ConditionTrue))
+			Expect(activated.Reason).Should(Equal(v1beta1.RestoreReasonActivated))
+			Expect(activated.Message).Should(Equal(
+				"Activated managed clusters activation-cluster-1, activation-cluster-2"))
+			for _, name := range managedClusterNames {
+				Expect(isAccepted(name)).Should(BeTrue())
+			}
+		})
+	})
+
 	Context("When creating a Restore with backup names set to latest", func() {
 		BeforeEach(func() {
 			veleroNamespace = &corev1.Namespace{
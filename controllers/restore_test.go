@@ -18,18 +18,29 @@ package controllers
 
 import (
 	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
 	"testing"
 	"time"
 
 	v1beta1 "github.com/stolostron/cluster-backup-operator/api/v1beta1"
 	veleroapi "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+	"github.com/vmware-tanzu/velero/pkg/label"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/discovery/cached/memory"
+	fakediscovery "k8s.io/client-go/discovery/fake"
 	"k8s.io/client-go/dynamic"
+	fakeclientset "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/client-go/tools/record"
 
 	dynamicfake "k8s.io/client-go/dynamic/fake"
 )
@@ -274,6 +285,30 @@ func Test_isValidSyncOptions(t *testing.T) {
 			},
 			want: true,
 		},
+		{
+			name: "Restore to time is set",
+			args: args{
+				restore: &v1beta1.Restore{
+					TypeMeta: metav1.TypeMeta{
+						APIVersion: "cluster.open-cluster-management.io/v1beta1",
+						Kind:       "Restore",
+					},
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "Restore",
+						Namespace: "veleroNamespace",
+					},
+					Spec: v1beta1.RestoreSpec{
+						SyncRestoreWithNewBackups:       true,
+						CleanupBeforeRestore:            v1beta1.CleanupTypeAll,
+						VeleroManagedClustersBackupName: &skipRestore,
+						VeleroCredentialsBackupName:     &latestBackup,
+						VeleroResourcesBackupName:       &latestBackup,
+						RestoreToTime:                   &metav1.Time{Time: time.Now()},
+					},
+				},
+			},
+			want: false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -646,13 +681,66 @@ func Test_sendResults(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if _, err := sendResult(tt.args.restore, tt.args.err); err != tt.want {
+			if _, err := sendResult(tt.args.restore, restoreSyncInterval, tt.args.err); err != tt.want {
 				t.Errorf("isSkipAllRestores() = %v, want %v", err, tt.want)
 			}
 		})
 	}
 }
 
+func Test_getSyncInterval(t *testing.T) {
+
+	newRestore := func(restoreSyncInterval time.Duration) *v1beta1.Restore {
+		return &v1beta1.Restore{
+			Spec: v1beta1.RestoreSpec{
+				SyncRestoreWithNewBackups: true,
+				RestoreSyncInterval:       metav1.Duration{Duration: restoreSyncInterval},
+			},
+			Status: v1beta1.RestoreStatus{
+				Phase: v1beta1.RestorePhaseEnabled,
+			},
+		}
+	}
+
+	tests := []struct {
+		name         string
+		syncInterval time.Duration
+		restore      *v1beta1.Restore
+		want         time.Duration
+	}{
+		{
+			name:         "sync interval not set",
+			syncInterval: 0,
+			restore:      newRestore(0),
+			want:         restoreSyncInterval,
+		},
+		{
+			name:         "sync interval set",
+			syncInterval: time.Minute * 2,
+			restore:      newRestore(0),
+			want:         time.Minute * 2,
+		},
+		{
+			name:         "restore sync interval set on the restore",
+			syncInterval: time.Minute * 2,
+			restore:      newRestore(time.Minute * 20),
+			want:         time.Minute * 20,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &RestoreReconciler{SyncInterval: tt.syncInterval}
+			result, err := sendResult(tt.restore, r.getSyncInterval(), nil)
+			if err != nil {
+				t.Fatalf("sendResult() error = %v", err)
+			}
+			if result.RequeueAfter != tt.want {
+				t.Errorf("sendResult() RequeueAfter = %v, want %v", result.RequeueAfter, tt.want)
+			}
+		})
+	}
+}
+
 func Test_setRestorePhase(t *testing.T) {
 	skipRestore := "skip"
 	latestBackupStr := "latest"
@@ -730,3 +818,3207 @@ func Test_setRestorePhase(t *testing.T) {
 		})
 	}
 }
+
+func Test_setRestorePhase_continueOnError(t *testing.T) {
+	latestBackup := latestBackupStr
+	newVeleroRestore := func(backupName string, phase veleroapi.RestorePhase) veleroapi.Restore {
+		return veleroapi.Restore{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      getValidKsRestoreName("restore-acm", backupName),
+				Namespace: "velero-ns",
+			},
+			Spec: veleroapi.RestoreSpec{
+				BackupName: backupName,
+			},
+			Status: veleroapi.RestoreStatus{
+				Phase: phase,
+			},
+		}
+	}
+	credentialsBackup := "acm-credentials-schedule-20220420120000"
+	resourcesBackup := "acm-resources-schedule-20220420120000"
+	managedClustersBackup := "acm-managed-clusters-schedule-20220420120000"
+
+	tests := []struct {
+		name                   string
+		continueOnError        bool
+		veleroRestores         []veleroapi.Restore
+		wantPhase              v1beta1.RestorePhase
+		wantBackupTypeRestores []v1beta1.BackupTypeRestore
+	}{
+		{
+			name:            "one type failed, continue on error",
+			continueOnError: true,
+			veleroRestores: []veleroapi.Restore{
+				newVeleroRestore(managedClustersBackup, veleroapi.RestorePhaseFailed),
+				newVeleroRestore(credentialsBackup, veleroapi.RestorePhaseCompleted),
+				newVeleroRestore(resourcesBackup, veleroapi.RestorePhaseCompleted),
+			},
+			wantPhase: v1beta1.RestorePhaseFinishedWithErrors,
+			wantBackupTypeRestores: []v1beta1.BackupTypeRestore{
+				{
+					Type:  string(Credentials),
+					Name:  "restore-acm-" + credentialsBackup,
+					Phase: string(veleroapi.RestorePhaseCompleted),
+				},
+				{
+					Type:  string(ManagedClusters),
+					Name:  "restore-acm-" + managedClustersBackup,
+					Phase: string(veleroapi.RestorePhaseFailed),
+				},
+				{
+					Type:  string(Resources),
+					Name:  "restore-acm-" + resourcesBackup,
+					Phase: string(veleroapi.RestorePhaseCompleted),
+				},
+			},
+		},
+		{
+			name: "one type failed, stop on error",
+			veleroRestores: []veleroapi.Restore{
+				newVeleroRestore(managedClustersBackup, veleroapi.RestorePhaseFailed),
+				newVeleroRestore(credentialsBackup, veleroapi.RestorePhaseCompleted),
+				newVeleroRestore(resourcesBackup, veleroapi.RestorePhaseCompleted),
+			},
+			wantPhase: v1beta1.RestorePhaseError,
+			wantBackupTypeRestores: []v1beta1.BackupTypeRestore{
+				{
+					Type:  string(Credentials),
+					Name:  "restore-acm-" + credentialsBackup,
+					Phase: string(veleroapi.RestorePhaseCompleted),
+				},
+				{
+					Type:  string(ManagedClusters),
+					Name:  "restore-acm-" + managedClustersBackup,
+					Phase: string(veleroapi.RestorePhaseFailed),
+				},
+				{
+					Type:  string(Resources),
+					Name:  "restore-acm-" + resourcesBackup,
+					Phase: string(veleroapi.RestorePhaseCompleted),
+				},
+			},
+		},
+		{
+			name:            "one type failed, other types running",
+			continueOnError: true,
+			veleroRestores: []veleroapi.Restore{
+				newVeleroRestore(managedClustersBackup, veleroapi.RestorePhaseFailed),
+				newVeleroRestore(credentialsBackup, veleroapi.RestorePhaseCompleted),
+				newVeleroRestore(resourcesBackup, veleroapi.RestorePhaseInProgress),
+			},
+			wantPhase: v1beta1.RestorePhaseRunning,
+			wantBackupTypeRestores: []v1beta1.BackupTypeRestore{
+				{
+					Type:  string(Credentials),
+					Name:  "restore-acm-" + credentialsBackup,
+					Phase: string(veleroapi.RestorePhaseCompleted),
+				},
+				{
+					Type:  string(ManagedClusters),
+					Name:  "restore-acm-" + managedClustersBackup,
+					Phase: string(veleroapi.RestorePhaseFailed),
+				},
+				{
+					Type:  string(Resources),
+					Name:  "restore-acm-" + resourcesBackup,
+					Phase: string(veleroapi.RestorePhaseInProgress),
+				},
+			},
+		},
+		{
+			name:            "all types failed, continue on error",
+			continueOnError: true,
+			veleroRestores: []veleroapi.Restore{
+				newVeleroRestore(credentialsBackup, veleroapi.RestorePhaseFailed),
+				newVeleroRestore(resourcesBackup, veleroapi.RestorePhaseFailedValidation),
+			},
+			wantPhase: v1beta1.RestorePhaseError,
+			wantBackupTypeRestores: []v1beta1.BackupTypeRestore{
+				{
+					Type:  string(Credentials),
+					Name:  "restore-acm-" + credentialsBackup,
+					Phase: string(veleroapi.RestorePhaseFailed),
+				},
+				{
+					Type:  string(Resources),
+					Name:  "restore-acm-" + resourcesBackup,
+					Phase: string(veleroapi.RestorePhaseFailedValidation),
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			restore := &v1beta1.Restore{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "restore-acm",
+					Namespace: "velero-ns",
+				},
+				Spec: v1beta1.RestoreSpec{
+					CleanupBeforeRestore:            v1beta1.CleanupTypeNone,
+					VeleroManagedClustersBackupName: &latestBackup,
+					VeleroCredentialsBackupName:     &latestBackup,
+					VeleroResourcesBackupName:       &latestBackup,
+					RestoreContinueOnError:          tt.continueOnError,
+				},
+			}
+			veleroRestoreList := &veleroapi.RestoreList{Items: tt.veleroRestores}
+			if phase := setRestorePhase(veleroRestoreList, restore); phase != tt.wantPhase {
+				t.Errorf("setRestorePhase() = %v, want %v", phase, tt.wantPhase)
+			}
+			if !reflect.DeepEqual(restore.Status.BackupTypeRestores, tt.wantBackupTypeRestores) {
+				t.Errorf("BackupTypeRestores = %v, want %v",
+					restore.Status.BackupTypeRestores, tt.wantBackupTypeRestores)
+			}
+		})
+	}
+}
+
+func Test_getRestoredItemsTotal(t *testing.T) {
+	newVeleroRestore := func(backupName string, phase veleroapi.RestorePhase,
+		itemsRestored, warnings, errorCount int) veleroapi.Restore {
+		return veleroapi.Restore{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      getValidKsRestoreName("restore-acm", backupName),
+				Namespace: "velero-ns",
+			},
+			Spec: veleroapi.RestoreSpec{
+				BackupName: backupName,
+			},
+			Status: veleroapi.RestoreStatus{
+				Phase:    phase,
+				Warnings: warnings,
+				Errors:   errorCount,
+				Progress: &veleroapi.RestoreProgress{
+					TotalItems:    itemsRestored,
+					ItemsRestored: itemsRestored,
+				},
+			},
+		}
+	}
+	credentialsBackup := "acm-credentials-schedule-20220420120000"
+	resourcesBackup := "acm-resources-schedule-20220420120000"
+	managedClustersBackup := "acm-managed-clusters-schedule-20220420120000"
+
+	inProgress := newVeleroRestore(managedClustersBackup, veleroapi.RestorePhaseInProgress, 0, 0, 0)
+	inProgress.Status.Progress = nil
+	veleroRestoreList := &veleroapi.RestoreList{
+		Items: []veleroapi.Restore{
+			newVeleroRestore(resourcesBackup, veleroapi.RestorePhasePartiallyFailed, 120, 3, 2),
+			newVeleroRestore(credentialsBackup, veleroapi.RestorePhaseCompleted, 45, 1, 0),
+			inProgress,
+		},
+	}
+
+	wantBackupTypeRestores := []v1beta1.BackupTypeRestore{
+		{
+			Type:          string(Credentials),
+			Name:          "restore-acm-" + credentialsBackup,
+			Phase:         string(veleroapi.RestorePhaseCompleted),
+			ItemsRestored: 45,
+			Warnings:      1,
+		},
+		{
+			Type:  string(ManagedClusters),
+			Name:  "restore-acm-" + managedClustersBackup,
+			Phase: string(veleroapi.RestorePhaseInProgress),
+		},
+		{
+			Type:          string(Resources),
+			Name:          "restore-acm-" + resourcesBackup,
+			Phase:         string(veleroapi.RestorePhasePartiallyFailed),
+			ItemsRestored: 120,
+			Warnings:      3,
+			Errors:        2,
+		},
+	}
+	backupTypeRestores := getBackupTypeRestores(veleroRestoreList)
+	if !reflect.DeepEqual(backupTypeRestores, wantBackupTypeRestores) {
+		t.Errorf("getBackupTypeRestores() = %v, want %v", backupTypeRestores, wantBackupTypeRestores)
+	}
+
+	want := &v1beta1.RestoredItemsTotal{ItemsRestored: 165, Warnings: 4, Errors: 2}
+	if got := getRestoredItemsTotal(backupTypeRestores); !reflect.DeepEqual(got, want) {
+		t.Errorf("getRestoredItemsTotal() = %v, want %v", got, want)
+	}
+	if got := getRestoredItemsTotal([]v1beta1.BackupTypeRestore{}); got != nil {
+		t.Errorf("getRestoredItemsTotal() = %v, want nil with no Velero restore", got)
+	}
+}
+
+func Test_validateRestoreLabelSelector(t *testing.T) {
+	skipRestore := "skip"
+	newRestore := func(selector *v1.LabelSelector) *v1beta1.Restore {
+		return &v1beta1.Restore{
+			Spec: v1beta1.RestoreSpec{
+				CleanupBeforeRestore:            v1beta1.CleanupTypeNone,
+				VeleroManagedClustersBackupName: &skipRestore,
+				VeleroCredentialsBackupName:     &skipRestore,
+				VeleroResourcesBackupName:       &skipRestore,
+				RestoreLabelSelector:            selector,
+			},
+		}
+	}
+	tests := []struct {
+		name    string
+		restore *v1beta1.Restore
+		wantErr bool
+	}{
+		{
+			name:    "no label selector",
+			restore: newRestore(nil),
+			wantErr: false,
+		},
+		{
+			name: "valid label selector",
+			restore: newRestore(&v1.LabelSelector{
+				MatchLabels: map[string]string{"restore": "true"},
+				MatchExpressions: []v1.LabelSelectorRequirement{
+					{Key: "app", Operator: v1.LabelSelectorOpIn, Values: []string{"a", "b"}},
+				},
+			}),
+			wantErr: false,
+		},
+		{
+			name: "In operator with no values",
+			restore: newRestore(&v1.LabelSelector{
+				MatchExpressions: []v1.LabelSelectorRequirement{
+					{Key: "app", Operator: v1.LabelSelectorOpIn},
+				},
+			}),
+			wantErr: true,
+		},
+		{
+			name: "invalid label key",
+			restore: newRestore(&v1.LabelSelector{
+				MatchLabels: map[string]string{"not a valid key": "true"},
+			}),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateRestoreLabelSelector(tt.restore); (err != nil) != tt.wantErr {
+				t.Errorf("validateRestoreLabelSelector() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_filterBackupsBySourceSchedule(t *testing.T) {
+
+	newRestore := func(scheduleName string) *v1beta1.Restore {
+		return &v1beta1.Restore{
+			Spec: v1beta1.RestoreSpec{
+				SourceScheduleName: scheduleName,
+			},
+		}
+	}
+
+	backups := []veleroapi.Backup{
+		*newTestBackup("acm-resources-schedule-20220101010101", withScheduleName("hub-1-schedule")),
+		*newTestBackup("acm-resources-schedule-20220101020202", withScheduleName("hub-2-schedule")),
+		*newTestBackup("acm-resources-schedule-20220101030303"),
+	}
+
+	tests := []struct {
+		name      string
+		restore   *v1beta1.Restore
+		wantNames []string
+		wantErr   bool
+	}{
+		{
+			name:    "no source schedule, use all backups",
+			restore: newRestore(""),
+			wantNames: []string{
+				"acm-resources-schedule-20220101010101",
+				"acm-resources-schedule-20220101020202",
+				"acm-resources-schedule-20220101030303",
+			},
+		},
+		{
+			name:      "source schedule, use only the schedule backups",
+			restore:   newRestore("hub-2-schedule"),
+			wantNames: []string{"acm-resources-schedule-20220101020202"},
+		},
+		{
+			name:      "source schedule with no backups",
+			restore:   newRestore("hub-3-schedule"),
+			wantNames: []string{},
+		},
+		{
+			name:      "invalid source schedule name",
+			restore:   newRestore("Not_A_Valid_Name"),
+			wantNames: []string{},
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateSourceScheduleName(tt.restore); (err != nil) != tt.wantErr {
+				t.Errorf("validateSourceScheduleName() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			gotNames := []string{}
+			for _, backup := range filterBackupsBySourceSchedule(tt.restore, backups) {
+				gotNames = append(gotNames, backup.Name)
+			}
+			if !reflect.DeepEqual(gotNames, tt.wantNames) {
+				t.Errorf("filterBackupsBySourceSchedule() = %v, want %v", gotNames, tt.wantNames)
+			}
+		})
+	}
+}
+
+func Test_filterBackupsBySourceHub(t *testing.T) {
+
+	newRestore := func(hubID string) *v1beta1.Restore {
+		return &v1beta1.Restore{
+			Spec: v1beta1.RestoreSpec{
+				SourceHubID: hubID,
+			},
+		}
+	}
+
+	backups := []veleroapi.Backup{
+		*newTestBackup("acm-resources-schedule-20220101010101", withHubID("hub-1-cluster-id")),
+		*newTestBackup("acm-resources-schedule-20220101020202", withHubID("hub-2-cluster-id")),
+		*newTestBackup("acm-resources-schedule-20220101030303", withHubID("hub-1-cluster-id")),
+		*newTestBackup("acm-resources-schedule-20220101040404"),
+	}
+
+	tests := []struct {
+		name      string
+		restore   *v1beta1.Restore
+		wantNames []string
+	}{
+		{
+			name:    "no source hub, use all backups",
+			restore: newRestore(""),
+			wantNames: []string{
+				"acm-resources-schedule-20220101010101",
+				"acm-resources-schedule-20220101020202",
+				"acm-resources-schedule-20220101030303",
+				"acm-resources-schedule-20220101040404",
+			},
+		},
+		{
+			name:    "source hub 1, use only the hub 1 backups",
+			restore: newRestore("hub-1-cluster-id"),
+			wantNames: []string{
+				"acm-resources-schedule-20220101010101",
+				"acm-resources-schedule-20220101030303",
+			},
+		},
+		{
+			name:      "source hub 2, use only the hub 2 backups",
+			restore:   newRestore("hub-2-cluster-id"),
+			wantNames: []string{"acm-resources-schedule-20220101020202"},
+		},
+		{
+			name:      "source hub with no backups",
+			restore:   newRestore("hub-3-cluster-id"),
+			wantNames: []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotNames := []string{}
+			for _, backup := range filterBackupsBySourceHub(tt.restore, backups) {
+				gotNames = append(gotNames, backup.Name)
+			}
+			if !reflect.DeepEqual(gotNames, tt.wantNames) {
+				t.Errorf("filterBackupsBySourceHub() = %v, want %v", gotNames, tt.wantNames)
+			}
+		})
+	}
+}
+
+func Test_validateWaitConditions(t *testing.T) {
+	tests := []struct {
+		name       string
+		conditions []v1beta1.RestoreWaitCondition
+		wantErr    bool
+	}{
+		{
+			name: "no wait conditions",
+		},
+		{
+			name: "valid wait condition, default status",
+			conditions: []v1beta1.RestoreWaitCondition{
+				{
+					Kind:          "managedcluster.cluster.open-cluster-management.io",
+					ConditionType: "ManagedClusterConditionAvailable",
+				},
+			},
+		},
+		{
+			name: "valid wait condition with status",
+			conditions: []v1beta1.RestoreWaitCondition{
+				{
+					Kind:            "managedcluster.cluster.open-cluster-management.io",
+					ConditionType:   "HubAcceptedManagedCluster",
+					ConditionStatus: metav1.ConditionFalse,
+				},
+			},
+		},
+		{
+			name: "missing kind",
+			conditions: []v1beta1.RestoreWaitCondition{
+				{
+					ConditionType: "ManagedClusterConditionAvailable",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "missing condition type",
+			conditions: []v1beta1.RestoreWaitCondition{
+				{
+					Kind: "managedcluster.cluster.open-cluster-management.io",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid condition status",
+			conditions: []v1beta1.RestoreWaitCondition{
+				{
+					Kind:            "managedcluster.cluster.open-cluster-management.io",
+					ConditionType:   "ManagedClusterConditionAvailable",
+					ConditionStatus: "Yes",
+				},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			restore := &v1beta1.Restore{
+				Spec: v1beta1.RestoreSpec{
+					WaitConditions: tt.conditions,
+				},
+			}
+			if err := validateWaitConditions(restore); (err != nil) != tt.wantErr {
+				t.Errorf("validateWaitConditions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_validateRemoveFinalizers(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   []v1beta1.RestoreFinalizerRemoval
+		wantErr bool
+	}{
+		{
+			name: "no finalizers removal rules",
+		},
+		{
+			name: "valid finalizers removal rule",
+			rules: []v1beta1.RestoreFinalizerRemoval{
+				{
+					Kind:       "clusterdeployment.hive.openshift.io",
+					Finalizers: []string{"hive.openshift.io/deprovision"},
+				},
+			},
+		},
+		{
+			name: "missing kind",
+			rules: []v1beta1.RestoreFinalizerRemoval{
+				{
+					Finalizers: []string{"hive.openshift.io/deprovision"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "missing finalizers",
+			rules: []v1beta1.RestoreFinalizerRemoval{
+				{
+					Kind: "clusterdeployment.hive.openshift.io",
+				},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			restore := &v1beta1.Restore{
+				Spec: v1beta1.RestoreSpec{
+					RemoveFinalizers: tt.rules,
+				},
+			}
+			if err := validateRemoveFinalizers(restore); (err != nil) != tt.wantErr {
+				t.Errorf("validateRemoveFinalizers() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_validateNamespaceMapping(t *testing.T) {
+	tests := []struct {
+		name             string
+		namespaceMapping map[string]string
+		wantErr          bool
+	}{
+		{
+			name: "no namespace mapping",
+		},
+		{
+			name: "valid namespace mapping",
+			namespaceMapping: map[string]string{
+				"ns1": "ns1-restored",
+				"ns2": "ns3",
+			},
+		},
+		{
+			name: "invalid source namespace",
+			namespaceMapping: map[string]string{
+				"Ns1": "ns1-restored",
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid target namespace",
+			namespaceMapping: map[string]string{
+				"ns1": "ns1.restored",
+			},
+			wantErr: true,
+		},
+		{
+			name: "empty target namespace",
+			namespaceMapping: map[string]string{
+				"ns1": "",
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			restore := &v1beta1.Restore{
+				Spec: v1beta1.RestoreSpec{
+					NamespaceMapping: tt.namespaceMapping,
+				},
+			}
+			if err := validateNamespaceMapping(restore); (err != nil) != tt.wantErr {
+				t.Errorf("validateNamespaceMapping() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_validateIncludedBackupTypes(t *testing.T) {
+	tests := []struct {
+		name         string
+		backupTypes  []string
+		wantErr      bool
+		wantIncluded []ResourceType
+		wantSkipped  []ResourceType
+	}{
+		{
+			name:         "no backup types set, all types are restored",
+			wantIncluded: []ResourceType{Credentials, Resources, ResourcesGeneric, ManagedClusters},
+		},
+		{
+			name:         "only credentials",
+			backupTypes:  []string{"credentials"},
+			wantIncluded: []ResourceType{Credentials},
+			wantSkipped:  []ResourceType{CredentialsHive, Resources, ManagedClusters},
+		},
+		{
+			name:         "resources and managed clusters",
+			backupTypes:  []string{"resources", "resourcesGeneric", "managedClusters"},
+			wantIncluded: []ResourceType{Resources, ResourcesGeneric, ManagedClusters},
+			wantSkipped:  []ResourceType{Credentials, CredentialsCluster, NamespaceContents},
+		},
+		{
+			name:         "resources and namespace contents",
+			backupTypes:  []string{"resources", "namespaceContents"},
+			wantIncluded: []ResourceType{Resources, NamespaceContents},
+			wantSkipped:  []ResourceType{ResourcesGeneric, ManagedClusters},
+		},
+		{
+			name:        "validation backup is not restored",
+			backupTypes: []string{"credentials", "validation"},
+			wantErr:     true,
+		},
+		{
+			name:        "unknown backup type",
+			backupTypes: []string{"Credentials"},
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			restore := &v1beta1.Restore{
+				Spec: v1beta1.RestoreSpec{
+					IncludedBackupTypes: tt.backupTypes,
+				},
+			}
+			if err := validateIncludedBackupTypes(restore); (err != nil) != tt.wantErr {
+				t.Errorf("validateIncludedBackupTypes() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			for _, backupType := range tt.wantIncluded {
+				if !isBackupTypeIncluded(restore, backupType) {
+					t.Errorf("isBackupTypeIncluded(%s) = false, want true", backupType)
+				}
+			}
+			for _, backupType := range tt.wantSkipped {
+				if isBackupTypeIncluded(restore, backupType) {
+					t.Errorf("isBackupTypeIncluded(%s) = true, want false", backupType)
+				}
+			}
+		})
+	}
+}
+
+func Test_findBackupBeforeTime(t *testing.T) {
+
+	backups := []veleroapi.Backup{
+		*newTestBackup("acm-resources-schedule-20220420000000", withPhase(veleroapi.BackupPhaseCompleted)),
+		*newTestBackup("acm-resources-schedule-20220420020000", withPhase(veleroapi.BackupPhaseCompleted)),
+		*newTestBackup("acm-resources-schedule-20220420010000", withPhase(veleroapi.BackupPhaseCompleted)),
+		*newTestBackup(
+			"acm-resources-schedule-20220420013000",
+			withPhase(veleroapi.BackupPhasePartiallyFailed),
+		),
+		*newTestBackup("acm-resources-schedule-20220420030000", withPhase(veleroapi.BackupPhaseCompleted)),
+		*newTestBackup("acm-resources-schedule", withPhase(veleroapi.BackupPhaseCompleted)),
+	}
+	restoreTime := func(value string) time.Time {
+		restoreToTime, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return restoreToTime
+	}
+
+	tests := []struct {
+		name          string
+		restoreToTime time.Time
+		want          string
+	}{
+		{
+			name:          "backup created at the restore time",
+			restoreToTime: restoreTime("2022-04-20T02:00:00Z"),
+			want:          "acm-resources-schedule-20220420020000",
+		},
+		{
+			name:          "most recent backup created before the restore time",
+			restoreToTime: restoreTime("2022-04-20T02:59:59Z"),
+			want:          "acm-resources-schedule-20220420020000",
+		},
+		{
+			name:          "partially failed backups are not restored",
+			restoreToTime: restoreTime("2022-04-20T01:45:00Z"),
+			want:          "acm-resources-schedule-20220420010000",
+		},
+		{
+			name:          "restore time after all backups",
+			restoreToTime: restoreTime("2022-04-21T00:00:00Z"),
+			want:          "acm-resources-schedule-20220420030000",
+		},
+		{
+			name:          "no backup created before the restore time",
+			restoreToTime: restoreTime("2022-04-19T23:59:59Z"),
+			want:          "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := findBackupBeforeTime(backups, tt.restoreToTime)
+			gotName := ""
+			if got != nil {
+				gotName = got.Name
+			}
+			if gotName != tt.want {
+				t.Errorf("findBackupBeforeTime() = %s, want %s", gotName, tt.want)
+			}
+		})
+	}
+}
+
+func Test_isLatestBackupRestore(t *testing.T) {
+
+	latestBackup := "Latest"
+	skipRestore := "skip"
+	backupName := "acm-resources-schedule-20220420000000"
+	restoreToTime := v1.NewTime(time.Date(2022, 4, 20, 0, 0, 0, 0, time.UTC))
+
+	tests := []struct {
+		name string
+		spec v1beta1.RestoreSpec
+		want bool
+	}{
+		{
+			name: "backup names not set",
+			spec: v1beta1.RestoreSpec{},
+			want: true,
+		},
+		{
+			name: "latest and skip backups",
+			spec: v1beta1.RestoreSpec{
+				VeleroManagedClustersBackupName: &skipRestore,
+				VeleroCredentialsBackupName:     &latestBackup,
+				VeleroResourcesBackupName:       &latestBackup,
+			},
+			want: true,
+		},
+		{
+			name: "backup selected by name",
+			spec: v1beta1.RestoreSpec{
+				VeleroManagedClustersBackupName: &latestBackup,
+				VeleroCredentialsBackupName:     &latestBackup,
+				VeleroResourcesBackupName:       &backupName,
+			},
+			want: false,
+		},
+		{
+			name: "backups selected by time",
+			spec: v1beta1.RestoreSpec{
+				VeleroManagedClustersBackupName: &latestBackup,
+				VeleroCredentialsBackupName:     &latestBackup,
+				VeleroResourcesBackupName:       &latestBackup,
+				RestoreToTime:                   &restoreToTime,
+			},
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			restore := &v1beta1.Restore{Spec: tt.spec}
+			if got := isLatestBackupRestore(restore); got != tt.want {
+				t.Errorf("isLatestBackupRestore() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_getLatestBackupSets(t *testing.T) {
+
+	completed := withPhase(veleroapi.BackupPhaseCompleted)
+	failedSet := newTestBackupSet("20220420090000", withHubID("hub1"), completed)
+	failedSet[2].Status.Phase = veleroapi.BackupPhaseFailed
+	// a set starts with the resources backup and ends with the managed clusters backup
+	backups := newTestBackupSet("20220420060000", withHubID("hub1"), completed)[:len(backupSetTypes)]
+	backups = append(backups, newTestBackupSet("20220420070000", withHubID("hub1"), completed)...)
+	backups = append(backups, newTestBackupSet("20220420080000", withHubID("hub1"), completed)[1:]...)
+	backups = append(backups, failedSet...)
+	backups = append(backups, newTestBackupSet("20220420100000", withHubID("hub2"), completed)...)
+	backups = append(backups, newTestBackupSet("20220420110000", withHubID("hub2"), completed)...)
+
+	tests := []struct {
+		name      string
+		backups   []veleroapi.Backup
+		maxSets   int
+		wantNames []string
+		wantHubs  []string
+	}{
+		{
+			name:      "no backups",
+			backups:   []veleroapi.Backup{},
+			maxSets:   3,
+			wantNames: []string{},
+			wantHubs:  []string{},
+		},
+		{
+			name:    "sets with a missing or failed backup are skipped",
+			backups: backups,
+			maxSets: 3,
+			wantNames: []string{
+				"acm-resources-schedule-20220420110000",
+				"acm-resources-schedule-20220420100000",
+				"acm-resources-schedule-20220420070000",
+			},
+			wantHubs: []string{"hub2", "hub2", "hub1"},
+		},
+		{
+			name:    "latest sets only",
+			backups: backups,
+			maxSets: 1,
+			wantNames: []string{
+				"acm-resources-schedule-20220420110000",
+			},
+			wantHubs: []string{"hub2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := getLatestBackupSets(tt.backups, tt.maxSets)
+			gotNames := []string{}
+			gotHubs := []string{}
+			for i := range got {
+				gotNames = append(gotNames, got[i].Name)
+				gotHubs = append(gotHubs, got[i].HubID)
+				if len(got[i].Backups) != len(backupSetTypes)+1 {
+					t.Errorf("getLatestBackupSets() backups = %v, want one backup for each type",
+						got[i].Backups)
+				}
+			}
+			if !reflect.DeepEqual(gotNames, tt.wantNames) {
+				t.Errorf("getLatestBackupSets() names = %v, want %v", gotNames, tt.wantNames)
+			}
+			if !reflect.DeepEqual(gotHubs, tt.wantHubs) {
+				t.Errorf("getLatestBackupSets() hubs = %v, want %v", gotHubs, tt.wantHubs)
+			}
+		})
+	}
+}
+
+func Test_validateRestoreBackups(t *testing.T) {
+
+	newStorageLocation := func(
+		name string,
+		phase veleroapi.BackupStorageLocationPhase,
+	) veleroapi.BackupStorageLocation {
+		return veleroapi.BackupStorageLocation{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      name,
+				Namespace: "velero-ns",
+			},
+			Status: veleroapi.BackupStorageLocationStatus{
+				Phase: phase,
+			},
+		}
+	}
+
+	storageLocations := []veleroapi.BackupStorageLocation{
+		newStorageLocation("available", veleroapi.BackupStorageLocationPhaseAvailable),
+		newStorageLocation("unavailable", veleroapi.BackupStorageLocationPhaseUnavailable),
+	}
+	noProgressBackup := newTestBackup(
+		"acm-credentials-schedule-1",
+		withStorageLocation("available"),
+		withPhase(veleroapi.BackupPhaseCompleted),
+		withItemsBackedUp(0),
+	)
+	noProgressBackup.Status.Progress = nil
+
+	tests := []struct {
+		name    string
+		backups map[ResourceType]*veleroapi.Backup
+		wantErr string
+	}{
+		{
+			name: "completed backups in an available storage location",
+			backups: map[ResourceType]*veleroapi.Backup{
+				Credentials: newTestBackup(
+					"acm-credentials-schedule-1",
+					withStorageLocation("available"),
+					withPhase(veleroapi.BackupPhaseCompleted),
+					withItemsBackedUp(10),
+				),
+				Resources: newTestBackup(
+					"acm-resources-schedule-1",
+					withStorageLocation("available"),
+					withPhase(veleroapi.BackupPhaseCompleted),
+					withItemsBackedUp(20),
+				),
+				ManagedClusters: nil,
+			},
+		},
+		{
+			name: "backup storage location not set",
+			backups: map[ResourceType]*veleroapi.Backup{
+				Resources: newTestBackup(
+					"acm-resources-schedule-1",
+					withStorageLocation(""),
+					withPhase(veleroapi.BackupPhaseCompleted),
+					withItemsBackedUp(20),
+				),
+			},
+		},
+		{
+			name: "partially failed backup",
+			backups: map[ResourceType]*veleroapi.Backup{
+				Credentials: newTestBackup(
+					"acm-credentials-schedule-1",
+					withStorageLocation("available"),
+					withPhase(veleroapi.BackupPhaseCompleted),
+					withItemsBackedUp(10),
+				),
+				Resources: newTestBackup(
+					"acm-resources-schedule-1",
+					withStorageLocation("available"),
+					withPhase(veleroapi.BackupPhasePartiallyFailed),
+					withItemsBackedUp(20),
+				),
+			},
+			wantErr: "backup acm-resources-schedule-1 is in phase PartiallyFailed, " +
+				"only Completed backups are restored",
+		},
+		{
+			name: "storage location unavailable",
+			backups: map[ResourceType]*veleroapi.Backup{
+				ManagedClusters: newTestBackup(
+					"acm-managed-clusters-schedule-1",
+					withStorageLocation("unavailable"),
+					withPhase(veleroapi.BackupPhaseCompleted),
+					withItemsBackedUp(5),
+				),
+			},
+			wantErr: "storage location unavailable of backup " +
+				"acm-managed-clusters-schedule-1 is not available",
+		},
+		{
+			name: "storage location not found",
+			backups: map[ResourceType]*veleroapi.Backup{
+				ManagedClusters: newTestBackup(
+					"acm-managed-clusters-schedule-1",
+					withStorageLocation("missing"),
+					withPhase(veleroapi.BackupPhaseCompleted),
+					withItemsBackedUp(5),
+				),
+			},
+			wantErr: "storage location missing of backup " +
+				"acm-managed-clusters-schedule-1 not found",
+		},
+		{
+			name: "completed backup with no items backed up",
+			backups: map[ResourceType]*veleroapi.Backup{
+				Credentials: newTestBackup(
+					"acm-credentials-schedule-1",
+					withStorageLocation("available"),
+					withPhase(veleroapi.BackupPhaseCompleted),
+					withItemsBackedUp(0),
+				),
+			},
+		},
+		{
+			name: "no backup progress",
+			backups: map[ResourceType]*veleroapi.Backup{
+				Credentials: noProgressBackup,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateRestoreBackups(tt.backups, storageLocations)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateRestoreBackups() error = %v, want no error", err)
+				}
+			} else if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("validateRestoreBackups() error = %v, want %s", err, tt.wantErr)
+			}
+
+			restore := &v1beta1.Restore{}
+			setBackupInvalidCondition(restore, err)
+			wantStatus := v1.ConditionFalse
+			if tt.wantErr != "" {
+				wantStatus = v1.ConditionTrue
+			}
+			if !meta.IsStatusConditionPresentAndEqual(restore.Status.Conditions,
+				v1beta1.RestoreBackupInvalid, wantStatus) {
+				t.Errorf("BackupInvalid condition = %v, want %s",
+					restore.Status.Conditions, wantStatus)
+			}
+		})
+	}
+}
+
+func Test_checkSameHubRestore(t *testing.T) {
+
+	backups := map[ResourceType]*veleroapi.Backup{
+		Credentials:     newTestBackup("acm-credentials-schedule-1", withHubID("hub-1")),
+		Resources:       newTestBackup("acm-resources-schedule-1", withHubID("hub-1")),
+		ManagedClusters: nil,
+	}
+
+	tests := []struct {
+		name             string
+		hubID            string
+		allowSelfRestore bool
+		wantErr          bool
+		wantStatus       v1.ConditionStatus
+		wantReason       string
+	}{
+		{
+			name:  "hub uid unknown, nothing is checked",
+			hubID: unknownHubID,
+		},
+		{
+			name:       "backups created on another hub",
+			hubID:      "hub-2",
+			wantStatus: v1.ConditionFalse,
+			wantReason: v1beta1.RestoreReasonOtherHubBackups,
+		},
+		{
+			name:       "backups created on this hub are blocked by default",
+			hubID:      "hub-1",
+			wantErr:    true,
+			wantStatus: v1.ConditionTrue,
+			wantReason: v1beta1.RestoreReasonSameHubBlocked,
+		},
+		{
+			name:             "backups created on this hub are restored when allowed",
+			hubID:            "hub-1",
+			allowSelfRestore: true,
+			wantStatus:       v1.ConditionTrue,
+			wantReason:       v1beta1.RestoreReasonSameHubAllowed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			restore := &v1beta1.Restore{
+				Spec: v1beta1.RestoreSpec{
+					AllowSelfRestore: tt.allowSelfRestore,
+				},
+			}
+			err := checkSameHubRestore(restore, backups, tt.hubID)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkSameHubRestore() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if _, blocked := err.(*sameHubRestoreError); err != nil && !blocked {
+				t.Errorf("checkSameHubRestore() error = %T, want a sameHubRestoreError", err)
+			}
+			condition := meta.FindStatusCondition(restore.Status.Conditions,
+				v1beta1.RestoreSameHubRestore)
+			if tt.wantReason == "" {
+				if condition != nil {
+					t.Errorf("checkSameHubRestore() condition = %v, want none", condition)
+				}
+				return
+			}
+			if condition == nil || condition.Status != tt.wantStatus ||
+				condition.Reason != tt.wantReason {
+				t.Errorf("checkSameHubRestore() condition = %v, want status %s reason %s",
+					condition, tt.wantStatus, tt.wantReason)
+			}
+		})
+	}
+}
+
+func Test_removeFinalizers(t *testing.T) {
+	tests := []struct {
+		name           string
+		finalizers     []string
+		remove         []string
+		wantRemoved    []string
+		wantFinalizers []string
+	}{
+		{
+			name:           "no finalizers on the resource",
+			remove:         []string{"hive.openshift.io/deprovision"},
+			wantRemoved:    []string{},
+			wantFinalizers: nil,
+		},
+		{
+			name:           "finalizer not on the resource",
+			finalizers:     []string{"kubernetes"},
+			remove:         []string{"hive.openshift.io/deprovision"},
+			wantRemoved:    []string{},
+			wantFinalizers: []string{"kubernetes"},
+		},
+		{
+			name:           "finalizers removed, other finalizers kept",
+			finalizers:     []string{"hive.openshift.io/deprovision", "kubernetes", "example.com/cleanup"},
+			remove:         []string{"example.com/cleanup", "hive.openshift.io/deprovision"},
+			wantRemoved:    []string{"hive.openshift.io/deprovision", "example.com/cleanup"},
+			wantFinalizers: []string{"kubernetes"},
+		},
+		{
+			name:           "all finalizers removed",
+			finalizers:     []string{"hive.openshift.io/deprovision"},
+			remove:         []string{"hive.openshift.io/deprovision"},
+			wantRemoved:    []string{"hive.openshift.io/deprovision"},
+			wantFinalizers: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resource := &unstructured.Unstructured{}
+			resource.SetName("cluster1")
+			resource.SetFinalizers(tt.finalizers)
+
+			if got := removeFinalizers(resource, tt.remove); !reflect.DeepEqual(got, tt.wantRemoved) {
+				t.Errorf("removeFinalizers() = %v, want %v", got, tt.wantRemoved)
+			}
+			if got := resource.GetFinalizers(); !reflect.DeepEqual(got, tt.wantFinalizers) {
+				t.Errorf("removeFinalizers() finalizers = %v, want %v", got, tt.wantFinalizers)
+			}
+		})
+	}
+}
+
+func Test_isConditionMet(t *testing.T) {
+
+	newResource := func(conditions ...interface{}) unstructured.Unstructured {
+		res := unstructured.Unstructured{
+			Object: map[string]interface{}{
+				"apiVersion": "cluster.open-cluster-management.io/v1",
+				"kind":       "ManagedCluster",
+				"metadata": map[string]interface{}{
+					"name": "managed1",
+				},
+			},
+		}
+		if conditions != nil {
+			res.Object["status"] = map[string]interface{}{
+				"conditions": conditions,
+			}
+		}
+		return res
+	}
+	available := map[string]interface{}{
+		"type":   "ManagedClusterConditionAvailable",
+		"status": "True",
+	}
+	notJoined := map[string]interface{}{
+		"type":   "ManagedClusterJoined",
+		"status": "False",
+	}
+
+	tests := []struct {
+		name            string
+		resource        unstructured.Unstructured
+		conditionType   string
+		conditionStatus metav1.ConditionStatus
+		want            bool
+	}{
+		{
+			name:          "no status",
+			resource:      newResource(),
+			conditionType: "ManagedClusterConditionAvailable",
+			want:          false,
+		},
+		{
+			name:          "condition met, default status",
+			resource:      newResource(notJoined, available),
+			conditionType: "ManagedClusterConditionAvailable",
+			want:          true,
+		},
+		{
+			name:            "condition set with other status",
+			resource:        newResource(notJoined, available),
+			conditionType:   "ManagedClusterJoined",
+			conditionStatus: metav1.ConditionTrue,
+			want:            false,
+		},
+		{
+			name:            "condition met with status False",
+			resource:        newResource(notJoined, available),
+			conditionType:   "ManagedClusterJoined",
+			conditionStatus: metav1.ConditionFalse,
+			want:            true,
+		},
+		{
+			name:          "condition not set",
+			resource:      newResource(notJoined),
+			conditionType: "ManagedClusterConditionAvailable",
+			want:          false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isConditionMet(tt.resource, tt.conditionType, tt.conditionStatus); got != tt.want {
+				t.Errorf("isConditionMet() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_findRestoreStorageLocation(t *testing.T) {
+
+	newStorageLocation := func(
+		name string,
+		prefix string,
+		phase veleroapi.BackupStorageLocationPhase,
+	) veleroapi.BackupStorageLocation {
+		storageLocation := veleroapi.BackupStorageLocation{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      name,
+				Namespace: "velero-ns",
+			},
+			Status: veleroapi.BackupStorageLocationStatus{
+				Phase: phase,
+			},
+		}
+		storageLocation.Spec.ObjectStorage = &veleroapi.ObjectStorageLocation{
+			Bucket: "acm-bucket",
+			Prefix: prefix,
+		}
+		return storageLocation
+	}
+	newRestore := func(name string, prefix string) *v1beta1.Restore {
+		return &v1beta1.Restore{
+			Spec: v1beta1.RestoreSpec{
+				StorageLocation:       name,
+				StorageLocationPrefix: prefix,
+			},
+		}
+	}
+
+	storageLocations := []veleroapi.BackupStorageLocation{
+		newStorageLocation("hub-1", "hub-1/backups", veleroapi.BackupStorageLocationPhaseAvailable),
+		newStorageLocation("hub-2", "hub-2", veleroapi.BackupStorageLocationPhaseAvailable),
+		newStorageLocation("hub-3", "hub-3", veleroapi.BackupStorageLocationPhaseUnavailable),
+		newStorageLocation("hub-4", "shared", veleroapi.BackupStorageLocationPhaseAvailable),
+		newStorageLocation("hub-5", "shared", veleroapi.BackupStorageLocationPhaseAvailable),
+	}
+
+	tests := []struct {
+		name     string
+		restore  *v1beta1.Restore
+		wantName string
+		wantErr  bool
+	}{
+		{
+			name:     "no storage location set",
+			restore:  newRestore("", ""),
+			wantName: "",
+		},
+		{
+			name:     "storage location found by name",
+			restore:  newRestore("hub-2", ""),
+			wantName: "hub-2",
+		},
+		{
+			name:     "storage location found by prefix",
+			restore:  newRestore("", "/hub-1/backups/"),
+			wantName: "hub-1",
+		},
+		{
+			name:     "storage location name and prefix match",
+			restore:  newRestore("hub-4", "shared"),
+			wantName: "hub-4",
+		},
+		{
+			name:    "storage location name and prefix don't match",
+			restore: newRestore("hub-1", "hub-2"),
+			wantErr: true,
+		},
+		{
+			name:    "storage location not found",
+			restore: newRestore("hub-6", ""),
+			wantErr: true,
+		},
+		{
+			name:    "prefix not found",
+			restore: newRestore("", "hub-6"),
+			wantErr: true,
+		},
+		{
+			name:    "more than one storage location with this prefix",
+			restore: newRestore("", "shared"),
+			wantErr: true,
+		},
+		{
+			name:    "storage location not available",
+			restore: newRestore("hub-3", ""),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := findRestoreStorageLocation(tt.restore, storageLocations)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("findRestoreStorageLocation() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			gotName := ""
+			if got != nil {
+				gotName = got.Name
+			}
+			if gotName != tt.wantName {
+				t.Errorf("findRestoreStorageLocation() = %v, want %v", gotName, tt.wantName)
+			}
+		})
+	}
+}
+
+func Test_filterBackupsByStorageLocation(t *testing.T) {
+
+	backups := []veleroapi.Backup{
+		*newTestBackup("acm-resources-schedule-20220101010101", withStorageLocation("hub-1")),
+		*newTestBackup("acm-resources-schedule-20220101020202", withStorageLocation("hub-2")),
+	}
+
+	tests := []struct {
+		name            string
+		storageLocation string
+		wantNames       []string
+	}{
+		{
+			name:            "no storage location, use all backups",
+			storageLocation: "",
+			wantNames: []string{
+				"acm-resources-schedule-20220101010101",
+				"acm-resources-schedule-20220101020202",
+			},
+		},
+		{
+			name:            "use only the storage location backups",
+			storageLocation: "hub-2",
+			wantNames:       []string{"acm-resources-schedule-20220101020202"},
+		},
+		{
+			name:            "storage location with no backups",
+			storageLocation: "hub-3",
+			wantNames:       []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotNames := []string{}
+			for _, backup := range filterBackupsByStorageLocation(tt.storageLocation, backups) {
+				gotNames = append(gotNames, backup.Name)
+			}
+			if !reflect.DeepEqual(gotNames, tt.wantNames) {
+				t.Errorf("filterBackupsByStorageLocation() = %v, want %v", gotNames, tt.wantNames)
+			}
+		})
+	}
+}
+
+func Test_filterBackupsByLatestStorageLocation(t *testing.T) {
+
+	tests := []struct {
+		name      string
+		backups   []veleroapi.Backup
+		wantNames []string
+	}{
+		{
+			name: "single storage location",
+			backups: []veleroapi.Backup{
+				*newTestBackup("acm-resources-schedule-20220101010101", withStorageLocation("default")),
+				*newTestBackup("acm-credentials-schedule-20220101010101", withStorageLocation("default")),
+			},
+			wantNames: []string{
+				"acm-resources-schedule-20220101010101",
+				"acm-credentials-schedule-20220101010101",
+			},
+		},
+		{
+			name: "storage location of the most recent backup",
+			backups: []veleroapi.Backup{
+				*newTestBackup("acm-resources-schedule-20220101010101", withStorageLocation("default")),
+				*newTestBackup(
+					"acm-resources-schedule-dr-region-20220101020202",
+					withStorageLocation("dr-region"),
+				),
+				*newTestBackup(
+					"acm-credentials-schedule-dr-region-20220101020202",
+					withStorageLocation("dr-region"),
+				),
+			},
+			wantNames: []string{
+				"acm-resources-schedule-dr-region-20220101020202",
+				"acm-credentials-schedule-dr-region-20220101020202",
+			},
+		},
+		{
+			name: "same timestamp, first storage location by name",
+			backups: []veleroapi.Backup{
+				*newTestBackup(
+					"acm-resources-schedule-dr-region-20220101010101",
+					withStorageLocation("dr-region"),
+				),
+				*newTestBackup("acm-resources-schedule-20220101010101", withStorageLocation("default")),
+			},
+			wantNames: []string{"acm-resources-schedule-20220101010101"},
+		},
+		{
+			name: "backups not created by a backup schedule are ignored",
+			backups: []veleroapi.Backup{
+				*newTestBackup("acm-resources-schedule-20220101010101", withStorageLocation("default")),
+				*newTestBackup("manual-backup-20220101020202", withStorageLocation("dr-region")),
+			},
+			wantNames: []string{"acm-resources-schedule-20220101010101"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotNames := []string{}
+			for _, backup := range filterBackupsByLatestStorageLocation(tt.backups) {
+				gotNames = append(gotNames, backup.Name)
+			}
+			if !reflect.DeepEqual(gotNames, tt.wantNames) {
+				t.Errorf("filterBackupsByLatestStorageLocation() = %v, want %v", gotNames, tt.wantNames)
+			}
+		})
+	}
+}
+
+func Test_setRestoreConditions(t *testing.T) {
+
+	restore := &v1beta1.Restore{}
+	restore.Generation = 3
+
+	tests := []struct {
+		name         string
+		phase        v1beta1.RestorePhase
+		wantComplete metav1.ConditionStatus
+		wantFailed   metav1.ConditionStatus
+		wantReason   string
+	}{
+		{
+			name:         "not started",
+			phase:        "",
+			wantComplete: metav1.ConditionFalse,
+			wantFailed:   metav1.ConditionFalse,
+			wantReason:   v1beta1.RestoreReasonNotStarted,
+		},
+		{
+			name:         "running",
+			phase:        v1beta1.RestorePhaseRunning,
+			wantComplete: metav1.ConditionFalse,
+			wantFailed:   metav1.ConditionFalse,
+			wantReason:   v1beta1.RestoreReasonRunning,
+		},
+		{
+			name:         "finished",
+			phase:        v1beta1.RestorePhaseFinished,
+			wantComplete: metav1.ConditionTrue,
+			wantFailed:   metav1.ConditionFalse,
+			wantReason:   v1beta1.RestoreReasonFinished,
+		},
+		{
+			name:         "finished with errors",
+			phase:        v1beta1.RestorePhaseFinishedWithErrors,
+			wantComplete: metav1.ConditionTrue,
+			wantFailed:   metav1.ConditionTrue,
+			wantReason:   v1beta1.RestoreReasonFinishedWithErrors,
+		},
+		{
+			name:         "error",
+			phase:        v1beta1.RestorePhaseError,
+			wantComplete: metav1.ConditionFalse,
+			wantFailed:   metav1.ConditionTrue,
+			wantReason:   v1beta1.RestoreReasonError,
+		},
+		{
+			name:         "sync enabled",
+			phase:        v1beta1.RestorePhaseEnabled,
+			wantComplete: metav1.ConditionTrue,
+			wantFailed:   metav1.ConditionFalse,
+			wantReason:   v1beta1.RestoreReasonSyncEnabled,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			restore.Status.Phase = tt.phase
+			restore.Status.LastMessage = "phase " + string(tt.phase)
+			setRestoreConditions(restore)
+
+			if len(restore.Status.Conditions) != 2 {
+				t.Errorf("setRestoreConditions() got %v conditions, want 2",
+					len(restore.Status.Conditions))
+			}
+			complete := meta.FindStatusCondition(restore.Status.Conditions, v1beta1.RestoreComplete)
+			if complete == nil || complete.Status != tt.wantComplete ||
+				complete.Reason != tt.wantReason ||
+				complete.Message != restore.Status.LastMessage ||
+				complete.ObservedGeneration != 3 {
+				t.Errorf("setRestoreConditions() Complete = %v, want %v %v",
+					complete, tt.wantComplete, tt.wantReason)
+			}
+			failed := meta.FindStatusCondition(restore.Status.Conditions, v1beta1.RestoreFailed)
+			if failed == nil || failed.Status != tt.wantFailed {
+				t.Errorf("setRestoreConditions() Failed = %v, want %v", failed, tt.wantFailed)
+			}
+		})
+	}
+}
+
+func Test_nextRestoreAttempt(t *testing.T) {
+
+	tests := []struct {
+		name            string
+		maxAttempts     int
+		wantRetryAfters []time.Duration
+	}{
+		{
+			name:        "no max attempts, backoff up to the max retry interval",
+			maxAttempts: 0,
+			wantRetryAfters: []time.Duration{
+				time.Minute,
+				2 * time.Minute,
+				4 * time.Minute,
+				8 * time.Minute,
+				16 * time.Minute,
+				30 * time.Minute,
+				30 * time.Minute,
+			},
+		},
+		{
+			name:        "3 attempts, no retry after the third attempt",
+			maxAttempts: 3,
+			wantRetryAfters: []time.Duration{
+				time.Minute,
+				2 * time.Minute,
+			},
+		},
+		{
+			name:            "1 attempt, no retry",
+			maxAttempts:     1,
+			wantRetryAfters: []time.Duration{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			restore := &v1beta1.Restore{
+				Spec: v1beta1.RestoreSpec{
+					MaxRestoreAttempts: tt.maxAttempts,
+				},
+			}
+			restore.Status.Phase = v1beta1.RestorePhaseError
+
+			for i, want := range tt.wantRetryAfters {
+				got, retry := nextRestoreAttempt(restore)
+				if !retry || got != want {
+					t.Errorf("attempt %d: nextRestoreAttempt() = %v, %v, want %v, true",
+						i+1, got, retry, want)
+				}
+				if isRestoreAttemptsExhausted(restore) {
+					t.Errorf("attempt %d: isRestoreAttemptsExhausted() = true, want false", i+1)
+				}
+			}
+			if tt.maxAttempts == 0 {
+				return
+			}
+
+			// the last attempt is not retried
+			if got, retry := nextRestoreAttempt(restore); retry || got != 0 {
+				t.Errorf("last attempt: nextRestoreAttempt() = %v, %v, want 0, false", got, retry)
+			}
+			if restore.Status.RestoreAttempts != tt.maxAttempts {
+				t.Errorf("RestoreAttempts = %d, want %d",
+					restore.Status.RestoreAttempts, tt.maxAttempts)
+			}
+			if !isRestoreAttemptsExhausted(restore) {
+				t.Errorf("isRestoreAttemptsExhausted() = false, want true")
+			}
+			setRestoreConditions(restore)
+			failed := meta.FindStatusCondition(restore.Status.Conditions, v1beta1.RestoreFailed)
+			if failed == nil || failed.Status != metav1.ConditionTrue ||
+				failed.Reason != v1beta1.RestoreReasonAttemptsExhausted {
+				t.Errorf("setRestoreConditions() Failed = %v, want %s",
+					failed, v1beta1.RestoreReasonAttemptsExhausted)
+			}
+		})
+	}
+}
+
+func Test_getRestoreAttemptDelay(t *testing.T) {
+
+	restore := &v1beta1.Restore{
+		Spec: v1beta1.RestoreSpec{
+			MaxRestoreAttempts: 4,
+		},
+	}
+	now := time.Date(2022, 4, 20, 12, 0, 0, 0, time.UTC)
+	if delay := getRestoreAttemptDelay(restore, now); delay != 0 {
+		t.Fatalf("getRestoreAttemptDelay() = %v for a new restore, want 0", delay)
+	}
+
+	// each attempt fails; the reconciles triggered before the next attempt,
+	// for example by the status update, wait and don't count an attempt
+	for i, wantDelay := range []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute} {
+		restore.Status.Phase = v1beta1.RestorePhaseError
+		retryAfter, retry := setNextRestoreAttempt(restore, now)
+		if !retry || retryAfter != wantDelay {
+			t.Fatalf("attempt %d: setNextRestoreAttempt() = %v, %v, want %v, true",
+				i+1, retryAfter, retry, wantDelay)
+		}
+		for _, elapsed := range []time.Duration{0, time.Second, wantDelay / 2} {
+			if delay := getRestoreAttemptDelay(restore, now.Add(elapsed)); delay != wantDelay-elapsed {
+				t.Errorf("attempt %d: getRestoreAttemptDelay() after %v = %v, want %v",
+					i+1, elapsed, delay, wantDelay-elapsed)
+			}
+		}
+		if restore.Status.RestoreAttempts != i+1 {
+			t.Errorf("attempt %d: RestoreAttempts = %d, want %d",
+				i+1, restore.Status.RestoreAttempts, i+1)
+		}
+		now = now.Add(wantDelay)
+		if delay := getRestoreAttemptDelay(restore, now); delay != 0 {
+			t.Errorf("attempt %d: getRestoreAttemptDelay() = %v once the delay is over, want 0",
+				i+1, delay)
+		}
+	}
+
+	// the last attempt is not retried
+	if retryAfter, retry := setNextRestoreAttempt(restore, now); retry || retryAfter != 0 ||
+		restore.Status.NextRestoreAttempt != nil {
+		t.Errorf("last attempt: setNextRestoreAttempt() = %v, %v, next attempt %v, want no attempt",
+			retryAfter, retry, restore.Status.NextRestoreAttempt)
+	}
+
+	// the next attempt time is ignored once the restore is no longer in Error phase
+	restore.Status.NextRestoreAttempt = &metav1.Time{Time: now.Add(time.Hour)}
+	restore.Status.Phase = v1beta1.RestorePhaseStarted
+	if delay := getRestoreAttemptDelay(restore, now); delay != 0 {
+		t.Errorf("getRestoreAttemptDelay() = %v for a Started restore, want 0", delay)
+	}
+}
+
+func Test_setWaitingForBackups(t *testing.T) {
+
+	created := time.Date(2022, 4, 20, 12, 0, 0, 0, time.UTC)
+	notFoundErr := &backupNotFoundError{errors.New("no velero backups found")}
+	waitingCondition := v1.Condition{
+		Type:   v1beta1.RestoreWaitingForBackups,
+		Status: v1.ConditionTrue,
+		Reason: v1beta1.RestoreReasonBackupsNotFound,
+	}
+
+	tests := []struct {
+		name           string
+		timeout        time.Duration
+		conditions     []v1.Condition
+		err            error
+		waited         time.Duration
+		wantRetryAfter time.Duration
+		wantWaiting    bool
+		wantReason     string
+	}{
+		{
+			name:        "no timeout, the restore doesn't wait for backups",
+			timeout:     0,
+			conditions:  []v1.Condition{waitingCondition},
+			err:         notFoundErr,
+			waited:      time.Second,
+			wantWaiting: false,
+		},
+		{
+			name:           "backups not found, first check",
+			timeout:        time.Hour,
+			err:            notFoundErr,
+			waited:         time.Second * 10,
+			wantRetryAfter: failureInterval,
+			wantWaiting:    true,
+			wantReason:     v1beta1.RestoreReasonBackupsNotFound,
+		},
+		{
+			name:           "backups not found, wait as long as already waited",
+			timeout:        time.Hour,
+			conditions:     []v1.Condition{waitingCondition},
+			err:            notFoundErr,
+			waited:         time.Minute * 10,
+			wantRetryAfter: time.Minute * 10,
+			wantWaiting:    true,
+			wantReason:     v1beta1.RestoreReasonBackupsNotFound,
+		},
+		{
+			name:           "backups not found, don't wait past the timeout",
+			timeout:        time.Minute * 55,
+			conditions:     []v1.Condition{waitingCondition},
+			err:            notFoundErr,
+			waited:         time.Minute * 50,
+			wantRetryAfter: time.Minute * 5,
+			wantWaiting:    true,
+			wantReason:     v1beta1.RestoreReasonBackupsNotFound,
+		},
+		{
+			name:        "backups not found after the timeout",
+			timeout:     time.Hour,
+			conditions:  []v1.Condition{waitingCondition},
+			err:         notFoundErr,
+			waited:      time.Hour,
+			wantWaiting: false,
+			wantReason:  v1beta1.RestoreReasonWaitForBackupsTimedOut,
+		},
+		{
+			name:        "backups found on a later reconcile",
+			timeout:     time.Hour,
+			conditions:  []v1.Condition{waitingCondition},
+			err:         nil,
+			waited:      time.Minute * 2,
+			wantWaiting: false,
+			wantReason:  v1beta1.RestoreReasonBackupsFound,
+		},
+		{
+			name:        "backups found, the restore never waited",
+			timeout:     time.Hour,
+			err:         nil,
+			waited:      time.Second,
+			wantWaiting: false,
+		},
+		{
+			name:        "other errors are not waited on",
+			timeout:     time.Hour,
+			err:         errors.New("backup name not found"),
+			waited:      time.Second,
+			wantWaiting: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			restore := &v1beta1.Restore{
+				ObjectMeta: v1.ObjectMeta{
+					CreationTimestamp: v1.NewTime(created),
+				},
+				Spec: v1beta1.RestoreSpec{
+					WaitForBackupsTimeout: v1.Duration{Duration: tt.timeout},
+				},
+			}
+			restore.Status.Conditions = tt.conditions
+
+			gotRetryAfter, gotWaiting := setWaitingForBackups(restore, tt.err, created.Add(tt.waited))
+			if gotWaiting != tt.wantWaiting || gotRetryAfter != tt.wantRetryAfter {
+				t.Errorf("setWaitingForBackups() = %v, %v, want %v, %v",
+					gotRetryAfter, gotWaiting, tt.wantRetryAfter, tt.wantWaiting)
+			}
+			if gotWaiting && restore.Status.Phase != v1beta1.RestorePhaseStarted {
+				t.Errorf("setWaitingForBackups() phase = %v, want %v",
+					restore.Status.Phase, v1beta1.RestorePhaseStarted)
+			}
+			condition := meta.FindStatusCondition(restore.Status.Conditions,
+				v1beta1.RestoreWaitingForBackups)
+			if tt.wantReason == "" {
+				if condition != nil {
+					t.Errorf("setWaitingForBackups() condition = %v, want no condition", condition)
+				}
+				return
+			}
+			if condition == nil || condition.Reason != tt.wantReason {
+				t.Fatalf("setWaitingForBackups() condition = %v, want reason %s", condition, tt.wantReason)
+			}
+			wantStatus := v1.ConditionFalse
+			if tt.wantWaiting {
+				wantStatus = v1.ConditionTrue
+			}
+			if condition.Status != wantStatus {
+				t.Errorf("setWaitingForBackups() condition status = %v, want %v",
+					condition.Status, wantStatus)
+			}
+		})
+	}
+}
+
+func Test_getNoMatchingBackupsCondition(t *testing.T) {
+
+	created := time.Date(2022, 4, 20, 12, 0, 0, 0, time.UTC)
+	newStorageLocation := func(name string, lastSynced *time.Time) veleroapi.BackupStorageLocation {
+		storageLocation := veleroapi.BackupStorageLocation{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+		}
+		if lastSynced != nil {
+			storageLocation.Status.LastSyncedTime = &metav1.Time{Time: *lastSynced}
+		}
+		return storageLocation
+	}
+	syncedBefore := created.Add(-time.Minute)
+	syncedAfter := created.Add(time.Minute)
+
+	tests := []struct {
+		name             string
+		storageLocation  string
+		sourceSchedule   string
+		sourceHub        string
+		backups          []veleroapi.Backup
+		storageLocations []veleroapi.BackupStorageLocation
+		wantStatus       metav1.ConditionStatus
+		wantReason       string
+		wantMessage      string
+	}{
+		{
+			name:             "no backups, storage location not synced yet",
+			storageLocations: []veleroapi.BackupStorageLocation{newStorageLocation("default", nil)},
+			wantStatus:       metav1.ConditionUnknown,
+			wantReason:       v1beta1.RestoreReasonWaitingForBackupSync,
+		},
+		{
+			name:             "no backups, storage location synced before the restore was created",
+			storageLocations: []veleroapi.BackupStorageLocation{newStorageLocation("default", &syncedBefore)},
+			wantStatus:       metav1.ConditionUnknown,
+			wantReason:       v1beta1.RestoreReasonWaitingForBackupSync,
+		},
+		{
+			name:             "no backups, storage location synced after the restore was created",
+			storageLocations: []veleroapi.BackupStorageLocation{newStorageLocation("default", &syncedAfter)},
+			wantStatus:       metav1.ConditionTrue,
+			wantReason:       v1beta1.RestoreReasonNoBackupsInLocation,
+		},
+		{
+			name:            "restore storage location synced, other storage locations ignored",
+			storageLocation: "secondary",
+			backups: []veleroapi.Backup{
+				*newTestBackup(
+					"acm-resources-schedule-20220420110000",
+					withStorageLocation("default"),
+					withScheduleName("schedule-acm"),
+					withHubID("hub-1"),
+				),
+			},
+			storageLocations: []veleroapi.BackupStorageLocation{
+				newStorageLocation("default", nil),
+				newStorageLocation("secondary", &syncedAfter),
+			},
+			wantStatus: metav1.ConditionTrue,
+			wantReason: v1beta1.RestoreReasonNoBackupsInLocation,
+		},
+		{
+			name:           "no backups from the source schedule and hub",
+			sourceSchedule: "schedule-other",
+			sourceHub:      "hub-3",
+			backups: []veleroapi.Backup{
+				*newTestBackup(
+					"acm-resources-schedule-20220420110000",
+					withStorageLocation("default"),
+					withScheduleName("schedule-acm"),
+					withHubID("hub-1"),
+				),
+				*newTestBackup(
+					"acm-resources-schedule-20220420100000",
+					withStorageLocation("default"),
+					withScheduleName("schedule-acm"),
+					withHubID("hub-2"),
+				),
+			},
+			storageLocations: []veleroapi.BackupStorageLocation{newStorageLocation("default", nil)},
+			wantStatus:       metav1.ConditionTrue,
+			wantReason:       v1beta1.RestoreReasonNoBackupsFromSource,
+			wantMessage: "No backups created by schedule schedule-other on hub hub-3 found in " +
+				"the storage locations; the 2 backups found were created by schedules " +
+				"[schedule-acm] on hubs [hub-1, hub-2]",
+		},
+		{
+			name:      "backups from the source hub",
+			sourceHub: "hub-2",
+			backups: []veleroapi.Backup{
+				*newTestBackup(
+					"acm-resources-schedule-20220420110000",
+					withStorageLocation("default"),
+					withScheduleName("schedule-acm"),
+					withHubID("hub-1"),
+				),
+				*newTestBackup(
+					"acm-resources-schedule-20220420100000",
+					withStorageLocation("default"),
+					withScheduleName("schedule-acm"),
+					withHubID("hub-2"),
+				),
+			},
+			storageLocations: []veleroapi.BackupStorageLocation{newStorageLocation("default", nil)},
+			wantStatus:       metav1.ConditionFalse,
+			wantReason:       v1beta1.RestoreReasonMatchingBackupsFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			restore := &v1beta1.Restore{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              "restore-acm",
+					CreationTimestamp: metav1.NewTime(created),
+				},
+				Spec: v1beta1.RestoreSpec{
+					SourceScheduleName: tt.sourceSchedule,
+					SourceHubID:        tt.sourceHub,
+				},
+				Status: v1beta1.RestoreStatus{StorageLocation: tt.storageLocation},
+			}
+			got := getNoMatchingBackupsCondition(restore, tt.backups, tt.storageLocations)
+			if got.Type != v1beta1.RestoreNoMatchingBackups || got.Status != tt.wantStatus ||
+				got.Reason != tt.wantReason {
+				t.Errorf("getNoMatchingBackupsCondition() = %v, want status %s and reason %s",
+					got, tt.wantStatus, tt.wantReason)
+			}
+			if tt.wantMessage != "" && got.Message != tt.wantMessage {
+				t.Errorf("getNoMatchingBackupsCondition() message = %q, want %q",
+					got.Message, tt.wantMessage)
+			}
+		})
+	}
+
+	// the condition is set to False once the backups are found
+	r := &RestoreReconciler{}
+	restore := &v1beta1.Restore{}
+	if r.setNoMatchingBackups(context.Background(), restore, nil) ||
+		len(restore.Status.Conditions) != 0 {
+		t.Errorf("setNoMatchingBackups() set %v, want no condition", restore.Status.Conditions)
+	}
+	meta.SetStatusCondition(&restore.Status.Conditions, metav1.Condition{
+		Type:   v1beta1.RestoreNoMatchingBackups,
+		Status: metav1.ConditionUnknown,
+		Reason: v1beta1.RestoreReasonWaitingForBackupSync,
+	})
+	if r.setNoMatchingBackups(context.Background(), restore, nil) {
+		t.Errorf("setNoMatchingBackups() = true, want false")
+	}
+	if cond := meta.FindStatusCondition(restore.Status.Conditions,
+		v1beta1.RestoreNoMatchingBackups); cond == nil || cond.Status != metav1.ConditionFalse ||
+		cond.Reason != v1beta1.RestoreReasonMatchingBackupsFound {
+		t.Errorf("setNoMatchingBackups() condition = %v, want %s",
+			cond, v1beta1.RestoreReasonMatchingBackupsFound)
+	}
+}
+
+func Test_setRestoreConditionsDryRun(t *testing.T) {
+
+	restore := &v1beta1.Restore{}
+	restore.Spec.DryRun = true
+
+	restore.Status.Phase = v1beta1.RestorePhaseFinished
+	setRestoreConditions(restore)
+	if !meta.IsStatusConditionTrue(restore.Status.Conditions, v1beta1.RestoreDryRunComplete) {
+		t.Errorf("setRestoreConditions() DryRunComplete not true for a finished dry run")
+	}
+	if meta.FindStatusCondition(restore.Status.Conditions, v1beta1.RestoreComplete) != nil {
+		t.Errorf("setRestoreConditions() Complete set for a dry run, nothing is restored")
+	}
+
+	restore.Status.Phase = v1beta1.RestorePhaseError
+	setRestoreConditions(restore)
+	dryRun := meta.FindStatusCondition(restore.Status.Conditions, v1beta1.RestoreDryRunComplete)
+	if dryRun == nil || dryRun.Status != metav1.ConditionFalse ||
+		dryRun.Reason != v1beta1.RestoreReasonDryRunFailed {
+		t.Errorf("setRestoreConditions() DryRunComplete = %v, want failed", dryRun)
+	}
+
+	restore.Spec.DryRun = false
+	setRestoreConditions(restore)
+	if meta.FindStatusCondition(restore.Status.Conditions, v1beta1.RestoreDryRunComplete) != nil {
+		t.Errorf("setRestoreConditions() DryRunComplete not removed when dry run is disabled")
+	}
+	if meta.FindStatusCondition(restore.Status.Conditions, v1beta1.RestoreComplete) == nil {
+		t.Errorf("setRestoreConditions() Complete not set when dry run is disabled")
+	}
+}
+
+func Test_getGenericResourcesLabelSelector(t *testing.T) {
+	tests := []struct {
+		name          string
+		labelSelector *metav1.LabelSelector
+		want          string
+	}{
+		{
+			name:          "no label selector",
+			labelSelector: nil,
+			want:          backupCredsClusterLabel,
+		},
+		{
+			name: "default label selector",
+			labelSelector: getGenericResourcesBackupLabelSelector(
+				&v1beta1.BackupSchedule{}),
+			want: backupCredsClusterLabel,
+		},
+		{
+			name: "label selector set on the backup schedule",
+			labelSelector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{"backup.example.com": "true"},
+			},
+			want: "backup.example.com=true",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			veleroBackup := &veleroapi.Backup{}
+			veleroBackup.Spec.LabelSelector = tt.labelSelector
+			if got := getGenericResourcesLabelSelector(veleroBackup); got != tt.want {
+				t.Errorf("getGenericResourcesLabelSelector() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_getVeleroRestoreOrder(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		restores map[ResourceType]*veleroapi.Restore
+		want     []ResourceType
+	}{
+		{
+			name:     "no restores",
+			restores: map[ResourceType]*veleroapi.Restore{},
+			want:     []ResourceType{},
+		},
+		{
+			name: "all backup types",
+			restores: map[ResourceType]*veleroapi.Restore{
+				ManagedClusters:    {},
+				Credentials:        {},
+				CredentialsHive:    {},
+				CredentialsCluster: {},
+				Resources:          {},
+				ResourcesGeneric:   {},
+				NamespaceContents:  {},
+			},
+			want: []ResourceType{
+				ResourcesGeneric,
+				Resources,
+				NamespaceContents,
+				CredentialsHive,
+				CredentialsCluster,
+				Credentials,
+				ManagedClusters,
+			},
+		},
+		{
+			name: "managed clusters restored after the credentials",
+			restores: map[ResourceType]*veleroapi.Restore{
+				ManagedClusters: {},
+				Credentials:     {},
+			},
+			want: []ResourceType{Credentials, ManagedClusters},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := getVeleroRestoreOrder(tt.restores); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getVeleroRestoreOrder() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_getPlannedVeleroRestores(t *testing.T) {
+	newRestore := func(name, backupName string) *veleroapi.Restore {
+		restore := &veleroapi.Restore{}
+		restore.Name = name
+		restore.Spec.BackupName = backupName
+		return restore
+	}
+	genericRestore := newRestore("restore-acm-resources-generic", "acm-resources-generic-schedule-1")
+	genericRestore.Spec.LabelSelector = &metav1.LabelSelector{
+		MatchExpressions: []metav1.LabelSelectorRequirement{
+			{
+				Key:      backupCredsClusterLabel,
+				Operator: metav1.LabelSelectorOpNotIn,
+				Values:   []string{"cluster-activation"},
+			},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		restores map[ResourceType]*veleroapi.Restore
+		want     []v1beta1.PlannedVeleroRestore
+	}{
+		{
+			name:     "no restores",
+			restores: map[ResourceType]*veleroapi.Restore{},
+			want:     []v1beta1.PlannedVeleroRestore{},
+		},
+		{
+			name: "restores sorted by type",
+			restores: map[ResourceType]*veleroapi.Restore{
+				ManagedClusters: newRestore("restore-acm-managed-clusters",
+					"acm-managed-clusters-schedule-1"),
+				Resources:        newRestore("restore-acm-resources", "acm-resources-schedule-1"),
+				ResourcesGeneric: genericRestore,
+			},
+			want: []v1beta1.PlannedVeleroRestore{
+				{
+					Type:          string(ResourcesGeneric),
+					Name:          "restore-acm-resources-generic",
+					BackupName:    "acm-resources-generic-schedule-1",
+					LabelSelector: backupCredsClusterLabel + " notin (cluster-activation)",
+				},
+				{
+					Type:       string(Resources),
+					Name:       "restore-acm-resources",
+					BackupName: "acm-resources-schedule-1",
+				},
+				{
+					Type:       string(ManagedClusters),
+					Name:       "restore-acm-managed-clusters",
+					BackupName: "acm-managed-clusters-schedule-1",
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := getPlannedVeleroRestores(tt.restores); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getPlannedVeleroRestores() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_getNextPlannedRestore(t *testing.T) {
+	plannedRestores := []v1beta1.PlannedVeleroRestore{
+		{Type: string(Resources), Name: "restore-acm-resources"},
+		{Type: string(Credentials), Name: "restore-acm-credentials"},
+		{Type: string(ManagedClusters), Name: "restore-acm-managed-clusters"},
+	}
+	newRestoreList := func(names ...string) *veleroapi.RestoreList {
+		list := &veleroapi.RestoreList{}
+		for _, name := range names {
+			restore := veleroapi.Restore{}
+			restore.Name = name
+			list.Items = append(list.Items, restore)
+		}
+		return list
+	}
+
+	tests := []struct {
+		name            string
+		plannedRestores []v1beta1.PlannedVeleroRestore
+		restores        *veleroapi.RestoreList
+		want            string
+	}{
+		{
+			name:     "no planned restores",
+			restores: newRestoreList(),
+		},
+		{
+			name:            "first stage not created",
+			plannedRestores: plannedRestores,
+			restores:        newRestoreList(),
+			want:            "restore-acm-resources",
+		},
+		{
+			name:            "second stage",
+			plannedRestores: plannedRestores,
+			restores:        newRestoreList("restore-acm-resources"),
+			want:            "restore-acm-credentials",
+		},
+		{
+			name:            "last stage",
+			plannedRestores: plannedRestores,
+			restores:        newRestoreList("restore-acm-resources", "restore-acm-credentials"),
+			want:            "restore-acm-managed-clusters",
+		},
+		{
+			name:            "all created",
+			plannedRestores: plannedRestores,
+			restores: newRestoreList("restore-acm-resources", "restore-acm-credentials",
+				"restore-acm-managed-clusters"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := getNextPlannedRestore(tt.plannedRestores, tt.restores)
+			gotName := ""
+			if got != nil {
+				gotName = got.Name
+			}
+			if gotName != tt.want {
+				t.Errorf("getNextPlannedRestore() = %v, want %v", gotName, tt.want)
+			}
+		})
+	}
+}
+
+func Test_isStagedRestore(t *testing.T) {
+	latest := latestBackupStr
+	waitConditions := []v1beta1.RestoreWaitCondition{
+		{
+			Kind:          "managedcluster.cluster.open-cluster-management.io",
+			ConditionType: "ManagedClusterConditionAvailable",
+		},
+	}
+
+	tests := []struct {
+		name    string
+		restore *v1beta1.Restore
+		want    bool
+	}{
+		{
+			name:    "no wait conditions",
+			restore: &v1beta1.Restore{},
+		},
+		{
+			name: "wait conditions",
+			restore: &v1beta1.Restore{
+				Spec: v1beta1.RestoreSpec{WaitConditions: waitConditions},
+			},
+			want: true,
+		},
+		{
+			name: "dry run",
+			restore: &v1beta1.Restore{
+				Spec: v1beta1.RestoreSpec{WaitConditions: waitConditions, DryRun: true},
+			},
+		},
+		{
+			name: "sync restore",
+			restore: &v1beta1.Restore{
+				Spec: v1beta1.RestoreSpec{
+					WaitConditions:                  waitConditions,
+					SyncRestoreWithNewBackups:       true,
+					VeleroManagedClustersBackupName: &latest,
+					VeleroCredentialsBackupName:     &latest,
+					VeleroResourcesBackupName:       &latest,
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isStagedRestore(tt.restore); got != tt.want {
+				t.Errorf("isStagedRestore() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_parsePlannedLabelSelector(t *testing.T) {
+	notInActivation := &metav1.LabelSelector{
+		MatchExpressions: []metav1.LabelSelectorRequirement{
+			{
+				Key:      backupCredsClusterLabel,
+				Operator: metav1.LabelSelectorOpNotIn,
+				Values:   []string{"cluster-activation"},
+			},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		selector string
+		want     *metav1.LabelSelector
+		wantErr  bool
+	}{
+		{
+			name: "no selector",
+		},
+		{
+			name:     "empty selector",
+			selector: metav1.FormatLabelSelector(&metav1.LabelSelector{}),
+		},
+		{
+			name:     "planned selector",
+			selector: metav1.FormatLabelSelector(notInActivation),
+			want: &metav1.LabelSelector{
+				MatchLabels:      map[string]string{},
+				MatchExpressions: notInActivation.MatchExpressions,
+			},
+		},
+		{
+			name:     "invalid selector",
+			selector: "a in b",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parsePlannedLabelSelector(tt.selector)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parsePlannedLabelSelector() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parsePlannedLabelSelector() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_getRestoreNameSelector(t *testing.T) {
+	longName := strings.Repeat("restore-acm-", 6) + "acm-resources-schedule-20220101000000"
+
+	tests := []struct {
+		name         string
+		restoreNames []string
+		want         string
+	}{
+		{
+			name:         "one restore",
+			restoreNames: []string{"restore-acm-resources"},
+			want:         "velero.io/restore-name=restore-acm-resources",
+		},
+		{
+			name:         "several restores",
+			restoreNames: []string{"restore-acm-resources", "restore-acm-credentials"},
+			want: "velero.io/restore-name in " +
+				"(restore-acm-resources,restore-acm-credentials)",
+		},
+		{
+			name:         "restore name longer than a label value",
+			restoreNames: []string{longName},
+			want:         "velero.io/restore-name=" + label.GetValidName(longName),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := getRestoreNameSelector(tt.restoreNames...)
+			if got != tt.want {
+				t.Errorf("getRestoreNameSelector() = %v, want %v", got, tt.want)
+			}
+			if len(tt.restoreNames) == 1 && len(strings.TrimPrefix(got,
+				"velero.io/restore-name=")) > validation.LabelValueMaxLength {
+				t.Errorf("getRestoreNameSelector() = %v, not a valid label value", got)
+			}
+		})
+	}
+}
+
+func Test_getPlacementRestoreOrderMessage(t *testing.T) {
+	allKinds := []string{
+		placementDecisionResource,
+		"placement.cluster.open-cluster-management.io",
+		"placementrule.apps.open-cluster-management.io",
+	}
+
+	tests := []struct {
+		name                          string
+		resourcesRestoreName          string
+		managedClustersRestoreName    string
+		placementDecisionsRestoreName string
+		placementKinds                []string
+		want                          string
+	}{
+		{
+			name:                       "no placement kinds on the hub",
+			resourcesRestoreName:       "restore-acm-resources",
+			managedClustersRestoreName: "restore-acm-managed-clusters",
+			placementKinds:             []string{},
+			want:                       "",
+		},
+		{
+			name:                       "placement decisions waiting for the managed clusters",
+			resourcesRestoreName:       "restore-acm-resources",
+			managedClustersRestoreName: "restore-acm-managed-clusters",
+			placementKinds:             allKinds,
+			want: "placement.cluster.open-cluster-management.io, " +
+				"placementrule.apps.open-cluster-management.io restored by restore-acm-resources; " +
+				"placementdecision.cluster.open-cluster-management.io restored once " +
+				"restore-acm-managed-clusters is completed",
+		},
+		{
+			name:                          "all placement kinds restored",
+			resourcesRestoreName:          "restore-acm-resources",
+			managedClustersRestoreName:    "restore-acm-managed-clusters",
+			placementDecisionsRestoreName: "restore-acm-placementdecisions-managed-clusters",
+			placementKinds:                allKinds,
+			want: "placement.cluster.open-cluster-management.io, " +
+				"placementrule.apps.open-cluster-management.io restored by restore-acm-resources; " +
+				"placementdecision.cluster.open-cluster-management.io restored by " +
+				"restore-acm-placementdecisions-managed-clusters, " +
+				"after restore-acm-managed-clusters is completed",
+		},
+		{
+			name:                 "managed clusters not restored",
+			resourcesRestoreName: "restore-acm-resources",
+			placementKinds:       allKinds,
+			want: "placement.cluster.open-cluster-management.io, " +
+				"placementrule.apps.open-cluster-management.io restored by restore-acm-resources; " +
+				"placementdecision.cluster.open-cluster-management.io not restored, " +
+				"placement decisions are restored after the managed clusters",
+		},
+		{
+			name:                          "only managed clusters restored",
+			managedClustersRestoreName:    "restore-acm-managed-clusters",
+			placementDecisionsRestoreName: "restore-acm-placementdecisions-managed-clusters",
+			placementKinds:                []string{placementDecisionResource},
+			want: "placementdecision.cluster.open-cluster-management.io restored by " +
+				"restore-acm-placementdecisions-managed-clusters, " +
+				"after restore-acm-managed-clusters is completed",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := getPlacementRestoreOrderMessage(tt.resourcesRestoreName,
+				tt.managedClustersRestoreName, tt.placementDecisionsRestoreName,
+				tt.placementKinds); got != tt.want {
+				t.Errorf("getPlacementRestoreOrderMessage() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_getCompletedVeleroRestore(t *testing.T) {
+	veleroRestoreList := &veleroapi.RestoreList{
+		Items: []veleroapi.Restore{
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "restore-acm-completed"},
+				Status:     veleroapi.RestoreStatus{Phase: veleroapi.RestorePhaseCompleted},
+			},
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "restore-acm-partially-failed"},
+				Status:     veleroapi.RestoreStatus{Phase: veleroapi.RestorePhasePartiallyFailed},
+			},
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "restore-acm-failed"},
+				Status:     veleroapi.RestoreStatus{Phase: veleroapi.RestorePhaseFailed},
+			},
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "restore-acm-in-progress"},
+				Status:     veleroapi.RestoreStatus{Phase: veleroapi.RestorePhaseInProgress},
+			},
+		},
+	}
+
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{name: "restore-acm-completed", want: true},
+		{name: "restore-acm-partially-failed", want: true},
+		{name: "restore-acm-failed", want: false},
+		{name: "restore-acm-in-progress", want: false},
+		{name: "restore-acm-missing", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := getCompletedVeleroRestore(veleroRestoreList, tt.name)
+			if (got != nil) != tt.want {
+				t.Errorf("getCompletedVeleroRestore() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_newPlacementDecisionsRestore(t *testing.T) {
+	includeClusterResources := false
+	acmRestore := &v1beta1.Restore{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "restore-acm",
+			Namespace: "velero-ns",
+		},
+	}
+	managedClustersRestore := &veleroapi.Restore{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "restore-acm-acm-managed-clusters-schedule-20220420120000",
+			Namespace: "velero-ns",
+		},
+		Spec: veleroapi.RestoreSpec{
+			BackupName:              "acm-managed-clusters-schedule-20220420120000",
+			ExcludedResources:       []string{placementDecisionResource},
+			IncludeClusterResources: &includeClusterResources,
+			NamespaceMapping:        map[string]string{"ns1": "ns2"},
+			LabelSelector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{"restore": "true"},
+			},
+		},
+	}
+
+	got := newPlacementDecisionsRestore(acmRestore, managedClustersRestore)
+	want := &veleroapi.Restore{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "restore-acm-placementdecisions-acm-managed-clusters-schedule-20220420120000",
+			Namespace: "velero-ns",
+		},
+		Spec: veleroapi.RestoreSpec{
+			BackupName:              "acm-managed-clusters-schedule-20220420120000",
+			IncludedResources:       []string{placementDecisionResource},
+			IncludeClusterResources: &includeClusterResources,
+			NamespaceMapping:        map[string]string{"ns1": "ns2"},
+			LabelSelector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{"restore": "true"},
+			},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("newPlacementDecisionsRestore() = %v, want %v", got, want)
+	}
+	// the managed clusters restore is not updated
+	if len(managedClustersRestore.Spec.IncludedResources) != 0 {
+		t.Errorf("managed clusters restore updated, included resources %v",
+			managedClustersRestore.Spec.IncludedResources)
+	}
+}
+
+func Test_getRestoredResourceNames(t *testing.T) {
+	clusterSetGVR := schema.GroupVersionResource{
+		Group:    "cluster.open-cluster-management.io",
+		Version:  "v1beta1",
+		Resource: "managedclustersets",
+	}
+	newClusterSet := func(name string, restoreName string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion("cluster.open-cluster-management.io/v1beta1")
+		obj.SetKind("ManagedClusterSet")
+		obj.SetName(name)
+		if restoreName != "" {
+			obj.SetLabels(map[string]string{"velero.io/restore-name": restoreName})
+		}
+		return obj
+	}
+
+	dynClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			clusterSetGVR: "ManagedClusterSetList",
+		},
+		newClusterSet("set1", "restore-acm-acm-managed-clusters-schedule-20220406"),
+		newClusterSet("set2", "restore-acm-acm-managed-clusters-schedule-20220406"),
+		newClusterSet("set3", "restore-acm-acm-managed-clusters-schedule-20220405"),
+		newClusterSet("set4", ""),
+	)
+
+	tests := []struct {
+		name              string
+		veleroRestoreName string
+		want              []string
+	}{
+		{
+			name:              "resources restored by the velero restore",
+			veleroRestoreName: "restore-acm-acm-managed-clusters-schedule-20220406",
+			want:              []string{"set1", "set2"},
+		},
+		{
+			name:              "no resources restored by the velero restore",
+			veleroRestoreName: "restore-acm-acm-managed-clusters-schedule-20220407",
+			want:              []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := getRestoredResourceNames(context.Background(),
+				dynClient.Resource(clusterSetGVR), tt.veleroRestoreName)
+			if err != nil {
+				t.Errorf("getRestoredResourceNames() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getRestoredResourceNames() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_getHubReadiness(t *testing.T) {
+
+	newResource := func(name string, labels map[string]interface{},
+		spec map[string]interface{}, status map[string]interface{}) unstructured.Unstructured {
+		return unstructured.Unstructured{Object: map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"name":      name,
+				"namespace": "ns",
+				"labels":    labels,
+			},
+			"spec":   spec,
+			"status": status,
+		}}
+	}
+	clusterStatus := func(available string) map[string]interface{} {
+		return map[string]interface{}{
+			"conditions": []interface{}{
+				map[string]interface{}{
+					"type":   "ManagedClusterConditionAvailable",
+					"status": available,
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		name      string
+		readiness func([]unstructured.Unstructured) (bool, string)
+		resources []unstructured.Unstructured
+		want      bool
+		wantMsg   string
+	}{
+		{
+			name:      "MultiClusterHub not found",
+			readiness: getMultiClusterHubReadiness,
+			resources: []unstructured.Unstructured{},
+			want:      false,
+			wantMsg:   "MultiClusterHub resource not found",
+		},
+		{
+			name:      "MultiClusterHub not running",
+			readiness: getMultiClusterHubReadiness,
+			resources: []unstructured.Unstructured{
+				newResource("mch", nil, nil, map[string]interface{}{"phase": "Installing"}),
+			},
+			want:    false,
+			wantMsg: "MultiClusterHub mch is in phase \"Installing\"",
+		},
+		{
+			name:      "MultiClusterHub running",
+			readiness: getMultiClusterHubReadiness,
+			resources: []unstructured.Unstructured{
+				newResource("mch", nil, nil, map[string]interface{}{"phase": "Running"}),
+			},
+			want:    true,
+			wantMsg: "MultiClusterHub is running",
+		},
+		{
+			name:      "managed clusters not available",
+			readiness: getManagedClustersReadiness,
+			resources: []unstructured.Unstructured{
+				newResource("c1", nil, nil, clusterStatus("True")),
+				newResource("c2", nil, nil, clusterStatus("Unknown")),
+				newResource("c3", nil, nil, nil),
+			},
+			want:    false,
+			wantMsg: "2 of 3 managed clusters are not available: c2, c3",
+		},
+		{
+			name:      "managed clusters available",
+			readiness: getManagedClustersReadiness,
+			resources: []unstructured.Unstructured{
+				newResource("c1", nil, nil, clusterStatus("True")),
+			},
+			want:    true,
+			wantMsg: "1 managed clusters are available",
+		},
+		{
+			name:      "policies with no compliance status",
+			readiness: getPoliciesReadiness,
+			resources: []unstructured.Unstructured{
+				newResource("p1", nil, nil, map[string]interface{}{"compliant": "Compliant"}),
+				newResource("p2", nil, nil, nil),
+				newResource("p3", nil, map[string]interface{}{"disabled": true}, nil),
+				newResource("ns.p4", map[string]interface{}{policyRootLabel: "ns.p4"}, nil, nil),
+			},
+			want:    false,
+			wantMsg: "1 of 2 policies have no compliance status: ns/p2",
+		},
+		{
+			name:      "policies report a compliance status",
+			readiness: getPoliciesReadiness,
+			resources: []unstructured.Unstructured{
+				newResource("p1", nil, nil, map[string]interface{}{"compliant": "NonCompliant"}),
+			},
+			want:    true,
+			wantMsg: "1 policies report a compliance status",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, gotMsg := tt.readiness(tt.resources)
+			if got != tt.want || gotMsg != tt.wantMsg {
+				t.Errorf("readiness() = %v, %q, want %v, %q", got, gotMsg, tt.want, tt.wantMsg)
+			}
+		})
+	}
+}
+
+func Test_getResourcesScope(t *testing.T) {
+
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(schema.GroupVersionKind{
+		Group: "cluster.open-cluster-management.io", Version: "v1beta1", Kind: "Placement",
+	}, meta.RESTScopeNamespace)
+	mapper.Add(schema.GroupVersionKind{
+		Group: "cluster.open-cluster-management.io", Version: "v1", Kind: "ManagedCluster",
+	}, meta.RESTScopeRoot)
+	mapper.Add(schema.GroupVersionKind{
+		Group: "", Version: "v1", Kind: "Namespace",
+	}, meta.RESTScopeRoot)
+	mapper.Add(schema.GroupVersionKind{
+		Group: "hive.openshift.io", Version: "v1", Kind: "ClusterDeployment",
+	}, meta.RESTScopeNamespace)
+
+	tests := []struct {
+		name              string
+		resources         []string
+		wantNamespaced    []string
+		wantClusterScoped []string
+	}{
+		{
+			name:              "no resources",
+			resources:         []string{},
+			wantNamespaced:    []string{},
+			wantClusterScoped: []string{},
+		},
+		{
+			name: "namespaced and cluster-scoped resources",
+			resources: []string{
+				"placements.cluster.open-cluster-management.io",
+				"managedclusters.cluster.open-cluster-management.io",
+				"clusterdeployments.hive.openshift.io",
+				"namespace",
+				"placements.cluster.open-cluster-management.io",
+			},
+			wantNamespaced: []string{
+				"clusterdeployments.hive.openshift.io",
+				"placements.cluster.open-cluster-management.io",
+			},
+			wantClusterScoped: []string{
+				"managedclusters.cluster.open-cluster-management.io",
+				"namespace",
+			},
+		},
+		{
+			name: "unknown resources are ignored",
+			resources: []string{
+				"*",
+				"channels.apps.open-cluster-management.io",
+				"managedclusters.cluster.open-cluster-management.io",
+			},
+			wantNamespaced: []string{},
+			wantClusterScoped: []string{
+				"managedclusters.cluster.open-cluster-management.io",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			namespaced, clusterScoped := getResourcesScope(mapper, tt.resources)
+			if !reflect.DeepEqual(namespaced, tt.wantNamespaced) {
+				t.Errorf("getResourcesScope() namespaced = %v, want %v",
+					namespaced, tt.wantNamespaced)
+			}
+			if !reflect.DeepEqual(clusterScoped, tt.wantClusterScoped) {
+				t.Errorf("getResourcesScope() clusterScoped = %v, want %v",
+					clusterScoped, tt.wantClusterScoped)
+			}
+		})
+	}
+}
+
+func Test_getExcludedClusterResources(t *testing.T) {
+
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(schema.GroupVersionKind{
+		Group: "cluster.open-cluster-management.io", Version: "v1beta1", Kind: "Placement",
+	}, meta.RESTScopeNamespace)
+	mapper.Add(schema.GroupVersionKind{
+		Group: "cluster.open-cluster-management.io", Version: "v1beta1", Kind: "ClusterClaim",
+	}, meta.RESTScopeRoot)
+
+	backups := map[ResourceType]*veleroapi.Backup{
+		Resources: {
+			Spec: veleroapi.BackupSpec{
+				IncludedResources: []string{
+					"placements.cluster.open-cluster-management.io",
+					"clusterclaims.cluster.open-cluster-management.io",
+				},
+			},
+		},
+		ManagedClusters: nil,
+	}
+	includeClusterResources := true
+	excludeClusterResources := false
+
+	tests := []struct {
+		name                    string
+		includeClusterResources *bool
+		want                    []string
+	}{
+		{
+			name:                    "cluster resources not set",
+			includeClusterResources: nil,
+			want:                    nil,
+		},
+		{
+			name:                    "cluster resources included",
+			includeClusterResources: &includeClusterResources,
+			want:                    nil,
+		},
+		{
+			name:                    "cluster resources excluded",
+			includeClusterResources: &excludeClusterResources,
+			want:                    []string{"clusterclaims.cluster.open-cluster-management.io"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			restore := &v1beta1.Restore{
+				Spec: v1beta1.RestoreSpec{
+					IncludeClusterResources: tt.includeClusterResources,
+				},
+			}
+			if got := getExcludedClusterResources(mapper, restore, backups); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getExcludedClusterResources() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_resolveClusterRBACResources(t *testing.T) {
+
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(schema.GroupVersionKind{
+		Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "ClusterRole",
+	}, meta.RESTScopeRoot)
+	mapper.Add(schema.GroupVersionKind{
+		Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "ClusterRoleBinding",
+	}, meta.RESTScopeRoot)
+	mapper.Add(schema.GroupVersionKind{
+		Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "RoleBinding",
+	}, meta.RESTScopeNamespace)
+	mapper.Add(schema.GroupVersionKind{
+		Group: "cluster.open-cluster-management.io", Version: "v1beta1", Kind: "ClusterClaim",
+	}, meta.RESTScopeRoot)
+	excludeClusterResources := false
+
+	tests := []struct {
+		name                    string
+		rbacResources           []string
+		includeClusterResources *bool
+		want                    []string
+		wantErr                 bool
+	}{
+		{
+			name:          "no RBAC resources",
+			rbacResources: nil,
+			want:          nil,
+		},
+		{
+			name: "all the cluster RBAC kinds",
+			rbacResources: []string{
+				"ClusterRoleBinding.rbac.authorization.k8s.io",
+				"clusterrole.rbac.authorization.k8s.io",
+				"clusterrolebindings.rbac.authorization.k8s.io",
+			},
+			want: []string{},
+		},
+		{
+			name:          "cluster RBAC kinds not selected are excluded",
+			rbacResources: []string{"clusterrolebindings.rbac.authorization.k8s.io"},
+			want:          []string{"clusterrole.rbac.authorization.k8s.io"},
+		},
+		{
+			name:                    "cluster resources excluded",
+			rbacResources:           []string{"clusterrolebinding.rbac.authorization.k8s.io"},
+			includeClusterResources: &excludeClusterResources,
+			wantErr:                 true,
+		},
+		{
+			name:          "namespaced RBAC kind",
+			rbacResources: []string{"rolebinding.rbac.authorization.k8s.io"},
+			wantErr:       true,
+		},
+		{
+			name:          "cluster-scoped kind not in the RBAC kinds",
+			rbacResources: []string{"clusterclaim.cluster.open-cluster-management.io"},
+			wantErr:       true,
+		},
+		{
+			name:          "kind not known on this hub",
+			rbacResources: []string{"clusterrolebinding.rbac.example.com"},
+			wantErr:       true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			restore := &v1beta1.Restore{
+				Spec: v1beta1.RestoreSpec{
+					IncludeClusterResources:      tt.includeClusterResources,
+					IncludedClusterRBACResources: tt.rbacResources,
+				},
+			}
+			got, err := resolveClusterRBACResources(mapper, restore)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("resolveClusterRBACResources() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("resolveClusterRBACResources() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_getRestoreExcludedResources(t *testing.T) {
+
+	excludedRBACResources := []string{"clusterrole.rbac.authorization.k8s.io"}
+
+	tests := []struct {
+		name                  string
+		backupType            ResourceType
+		excludedRBACResources []string
+		want                  []string
+	}{
+		{
+			name:                  "no cluster RBAC kinds selected",
+			backupType:            ResourcesGeneric,
+			excludedRBACResources: nil,
+			want:                  nil,
+		},
+		{
+			name:                  "generic resources backup",
+			backupType:            ResourcesGeneric,
+			excludedRBACResources: excludedRBACResources,
+			want:                  []string{"clusterrole.rbac.authorization.k8s.io"},
+		},
+		{
+			name:                  "resources backup",
+			backupType:            Resources,
+			excludedRBACResources: excludedRBACResources,
+			want:                  nil,
+		},
+		{
+			name:                  "managed clusters backup",
+			backupType:            ManagedClusters,
+			excludedRBACResources: excludedRBACResources,
+			want:                  []string{placementDecisionResource},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := getRestoreExcludedResources(tt.backupType, tt.excludedRBACResources); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getRestoreExcludedResources() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_getUnfinishedVeleroRestores(t *testing.T) {
+
+	veleroRestore := func(name string, phase veleroapi.RestorePhase) veleroapi.Restore {
+		return veleroapi.Restore{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Status:     veleroapi.RestoreStatus{Phase: phase},
+		}
+	}
+
+	tests := []struct {
+		name           string
+		veleroRestores []veleroapi.Restore
+		want           []string
+	}{
+		{
+			name:           "no velero restores",
+			veleroRestores: nil,
+			want:           []string{},
+		},
+		{
+			name: "all velero restores finished",
+			veleroRestores: []veleroapi.Restore{
+				veleroRestore("restore-credentials", veleroapi.RestorePhaseCompleted),
+				veleroRestore("restore-resources", veleroapi.RestorePhasePartiallyFailed),
+			},
+			want: []string{},
+		},
+		{
+			name: "some velero restores not finished",
+			veleroRestores: []veleroapi.Restore{
+				veleroRestore("restore-resources", veleroapi.RestorePhaseInProgress),
+				veleroRestore("restore-credentials", ""),
+				veleroRestore("restore-managed-clusters", veleroapi.RestorePhaseFailed),
+				veleroRestore("restore-generic", veleroapi.RestorePhaseNew),
+			},
+			want: []string{"restore-credentials", "restore-generic", "restore-resources"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := getUnfinishedVeleroRestores(tt.veleroRestores); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getUnfinishedVeleroRestores() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_getRestoreOwnerName(t *testing.T) {
+
+	isController := true
+	veleroRestore := func(ownerReferences ...metav1.OwnerReference) *veleroapi.Restore {
+		return &veleroapi.Restore{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            "restore-acm-acm-resources-schedule-20220420120000",
+				Namespace:       "velero-ns",
+				OwnerReferences: ownerReferences,
+			},
+		}
+	}
+
+	tests := []struct {
+		name          string
+		veleroRestore *veleroapi.Restore
+		want          string
+	}{
+		{
+			name: "owned by a restore",
+			veleroRestore: veleroRestore(metav1.OwnerReference{
+				APIVersion: apiGVStr,
+				Kind:       "Restore",
+				Name:       "restore-acm",
+				Controller: &isController,
+			}),
+			want: "restore-acm",
+		},
+		{
+			name:          "no owner",
+			veleroRestore: veleroRestore(),
+			want:          "",
+		},
+		{
+			name: "owner is not the controller",
+			veleroRestore: veleroRestore(metav1.OwnerReference{
+				APIVersion: apiGVStr,
+				Kind:       "Restore",
+				Name:       "restore-acm",
+			}),
+			want: "",
+		},
+		{
+			name: "owned by another kind",
+			veleroRestore: veleroRestore(metav1.OwnerReference{
+				APIVersion: apiGVStr,
+				Kind:       "BackupSchedule",
+				Name:       "schedule-acm",
+				Controller: &isController,
+			}),
+			want: "",
+		},
+		{
+			name: "owned by a restore of another group",
+			veleroRestore: veleroRestore(metav1.OwnerReference{
+				APIVersion: "velero.io/v1",
+				Kind:       "Restore",
+				Name:       "restore-acm",
+				Controller: &isController,
+			}),
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := getRestoreOwnerName(tt.veleroRestore); got != tt.want {
+				t.Errorf("getRestoreOwnerName() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_prepareRestoreForBackup(t *testing.T) {
+
+	fakeDiscovery, ok := fakeclientset.NewSimpleClientset().Discovery().(*fakediscovery.FakeDiscovery)
+	if !ok {
+		t.Fatalf("couldn't convert Discovery() to *FakeDiscovery")
+	}
+	fakeDiscovery.Resources = []*metav1.APIResourceList{
+		{
+			GroupVersion: "apps.open-cluster-management.io/v1",
+			APIResources: []metav1.APIResource{
+				{Name: "channels", Namespaced: true, Kind: "Channel"},
+			},
+		},
+		{
+			GroupVersion: "cluster.open-cluster-management.io/v1beta1",
+			APIResources: []metav1.APIResource{
+				{Name: "clusterclaims", Namespaced: false, Kind: "ClusterClaim"},
+				{Name: "restores", Namespaced: true, Kind: "Restore"},
+			},
+		},
+	}
+	mapper := restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(fakeDiscovery))
+	channelsGVR := schema.GroupVersionResource{
+		Group: "apps.open-cluster-management.io", Version: "v1", Resource: "channels",
+	}
+	clusterClaimsGVR := schema.GroupVersionResource{
+		Group: "cluster.open-cluster-management.io", Version: "v1beta1", Resource: "clusterclaims",
+	}
+	restoresGVR := schema.GroupVersionResource{
+		Group: "cluster.open-cluster-management.io", Version: "v1beta1", Resource: "restores",
+	}
+
+	restoreNamespace := "open-cluster-management-backup"
+	restoredLabels := map[string]interface{}{"velero.io/backup-name": "acm-resources-schedule-1"}
+	newResource := func(apiVersion, kind, namespace, name string,
+		labels map[string]interface{}) *unstructured.Unstructured {
+		metadata := map[string]interface{}{"name": name}
+		if namespace != "" {
+			metadata["namespace"] = namespace
+		}
+		if labels != nil {
+			metadata["labels"] = labels
+		}
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": apiVersion,
+			"kind":       kind,
+			"metadata":   metadata,
+		}}
+	}
+	newObjects := func() []runtime.Object {
+		return []runtime.Object{
+			newResource("apps.open-cluster-management.io/v1", "Channel",
+				"app-ns", "restored-channel", restoredLabels),
+			newResource("apps.open-cluster-management.io/v1", "Channel",
+				"app-ns", "hub-channel", nil),
+			newResource("apps.open-cluster-management.io/v1", "Channel",
+				restoreNamespace, "operator-channel", restoredLabels),
+			newResource("cluster.open-cluster-management.io/v1beta1", "ClusterClaim",
+				"", "restored-claim", restoredLabels),
+			newResource("cluster.open-cluster-management.io/v1beta1", "Restore",
+				"app-ns", "restored-restore", restoredLabels),
+		}
+	}
+
+	veleroBackup := &veleroapi.Backup{
+		ObjectMeta: metav1.ObjectMeta{Name: "acm-resources-schedule-1"},
+		Spec: veleroapi.BackupSpec{
+			IncludedResources: []string{
+				"channel.apps.open-cluster-management.io",
+				"clusterclaim.cluster.open-cluster-management.io",
+				"restore.cluster.open-cluster-management.io",
+			},
+		},
+	}
+	deletePolicy := metav1.DeletePropagationForeground
+
+	tests := []struct {
+		name        string
+		cleanupType v1beta1.CleanupType
+		want        []string
+	}{
+		{
+			name:        "no cleanup",
+			cleanupType: v1beta1.CleanupTypeNone,
+			want: []string{
+				"app-ns/hub-channel",
+				"app-ns/restored-channel",
+				"app-ns/restored-restore",
+				"open-cluster-management-backup/operator-channel",
+				"restored-claim",
+			},
+		},
+		{
+			name:        "clean up the restored resources",
+			cleanupType: v1beta1.CleanupTypeRestored,
+			want: []string{
+				"app-ns/hub-channel",
+				"app-ns/restored-restore",
+				"open-cluster-management-backup/operator-channel",
+			},
+		},
+		{
+			name:        "clean up all resources",
+			cleanupType: v1beta1.CleanupTypeAll,
+			want: []string{
+				"app-ns/restored-restore",
+				"open-cluster-management-backup/operator-channel",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dyn := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+				map[schema.GroupVersionResource]string{
+					channelsGVR:      "ChannelList",
+					clusterClaimsGVR: "ClusterClaimList",
+					restoresGVR:      "RestoreList",
+				},
+				newObjects()...,
+			)
+			r := &RestoreReconciler{
+				DiscoveryClient: fakeDiscovery,
+				DynamicClient:   dyn,
+				RESTMapper:      mapper,
+				Recorder:        record.NewFakeRecorder(10),
+			}
+			acmRestore := &v1beta1.Restore{
+				ObjectMeta: metav1.ObjectMeta{Name: "restore-acm", Namespace: restoreNamespace},
+				Spec:       v1beta1.RestoreSpec{CleanupBeforeRestore: tt.cleanupType},
+			}
+			if tt.cleanupType == v1beta1.CleanupTypeNone {
+				if err := r.prepareForRestore(context.Background(), *acmRestore,
+					map[ResourceType]*veleroapi.Restore{Resources: {}},
+					map[ResourceType]*veleroapi.Backup{Resources: veleroBackup}); err != nil {
+					t.Fatalf("prepareForRestore() error = %v", err)
+				}
+			} else {
+				r.prepareRestoreForBackup(context.Background(), acmRestore, RestoreOptions{
+					dynamicArgs: DynamicStruct{
+						dc:     fakeDiscovery,
+						dyn:    dyn,
+						mapper: mapper,
+					},
+					cleanupType:   tt.cleanupType,
+					deleteOptions: metav1.DeleteOptions{PropagationPolicy: &deletePolicy},
+				}, Resources, veleroBackup, "")
+			}
+
+			got := []string{}
+			for _, gvr := range []schema.GroupVersionResource{channelsGVR, clusterClaimsGVR, restoresGVR} {
+				list, err := dyn.Resource(gvr).List(context.Background(), metav1.ListOptions{})
+				if err != nil {
+					t.Fatalf("List() error = %v", err)
+				}
+				for _, item := range list.Items {
+					name := item.GetName()
+					if item.GetNamespace() != "" {
+						name = item.GetNamespace() + "/" + name
+					}
+					got = append(got, name)
+				}
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("resources left after the cleanup = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
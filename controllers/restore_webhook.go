@@ -0,0 +1,185 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	v1beta1 "github.com/stolostron/cluster-backup-operator/api/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+//+kubebuilder:webhook:path=/validate-cluster-open-cluster-management-io-v1beta1-restore,mutating=false,failurePolicy=fail,sideEffects=None,groups=cluster.open-cluster-management.io,resources=restores,verbs=create;update,versions=v1beta1,name=vrestore.kb.io,admissionReviewVersions=v1
+
+// RestoreValidator rejects Restore resources selecting the backups of a backup type
+// with conflicting properties, before they are stored in the cluster
+type RestoreValidator struct{}
+
+var _ admission.CustomValidator = &RestoreValidator{}
+
+// SetupWebhookWithManager registers the Restore validating webhook with the manager
+func (v *RestoreValidator) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&v1beta1.Restore{}).
+		WithValidator(v).
+		Complete()
+}
+
+// ValidateCreate validates the Restore created
+func (v *RestoreValidator) ValidateCreate(ctx context.Context, obj runtime.Object) error {
+	return validateRestoreObject(obj)
+}
+
+// ValidateUpdate validates the Restore updated
+func (v *RestoreValidator) ValidateUpdate(
+	ctx context.Context,
+	oldObj, newObj runtime.Object,
+) error {
+	return validateRestoreObject(newObj)
+}
+
+// ValidateDelete accepts all Restore deletions
+func (v *RestoreValidator) ValidateDelete(ctx context.Context, obj runtime.Object) error {
+	return nil
+}
+
+func validateRestoreObject(obj runtime.Object) error {
+
+	restore, ok := obj.(*v1beta1.Restore)
+	if !ok {
+		return apierrors.NewBadRequest(fmt.Sprintf("expected a Restore, found %T", obj))
+	}
+
+	errs := validateRestoreBackupNameConflicts(restore)
+	if len(errs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(
+		v1beta1.GroupVersion.WithKind("Restore").GroupKind(),
+		restore.Name,
+		errs,
+	)
+}
+
+// the restore backup name properties, with the backup types they select
+var restoreBackupNameFields = []struct {
+	name         string
+	backupTypes  []ResourceType
+	getValueFunc func(restore *v1beta1.Restore) *string
+}{
+	{
+		name:        "veleroManagedClustersBackupName",
+		backupTypes: []ResourceType{ManagedClusters},
+		getValueFunc: func(restore *v1beta1.Restore) *string {
+			return restore.Spec.VeleroManagedClustersBackupName
+		},
+	},
+	{
+		name:        "veleroCredentialsBackupName",
+		backupTypes: []ResourceType{Credentials, CredentialsHive, CredentialsCluster},
+		getValueFunc: func(restore *v1beta1.Restore) *string {
+			return restore.Spec.VeleroCredentialsBackupName
+		},
+	},
+	{
+		name:        "veleroResourcesBackupName",
+		backupTypes: []ResourceType{Resources, ResourcesGeneric},
+		getValueFunc: func(restore *v1beta1.Restore) *string {
+			return restore.Spec.VeleroResourcesBackupName
+		},
+	},
+}
+
+// returns the backup name set for the restore property, in lower case, or latest if not set
+func getRestoreBackupNameValue(
+	restore *v1beta1.Restore,
+	getValueFunc func(*v1beta1.Restore) *string,
+) string {
+	if value := getValueFunc(restore); value != nil {
+		return strings.ToLower(strings.TrimSpace(*value))
+	}
+	return latestBackupStr
+}
+
+// returns the errors for the properties selecting the backups of a backup type in conflicting ways:
+// an included backup type set to skip, a restore time with no backup type set to latest, and
+// a backup name other than latest, or skip for the managed clusters, when syncing with new backups
+func validateRestoreBackupNameConflicts(restore *v1beta1.Restore) field.ErrorList {
+
+	specPath := field.NewPath("spec")
+	errs := field.ErrorList{}
+
+	backupNames := map[ResourceType]string{}
+	fieldNames := map[ResourceType]string{}
+	latestFields := []string{}
+	for _, backupNameField := range restoreBackupNameFields {
+		backupName := getRestoreBackupNameValue(restore, backupNameField.getValueFunc)
+		for _, backupType := range backupNameField.backupTypes {
+			backupNames[backupType] = backupName
+			fieldNames[backupType] = backupNameField.name
+		}
+		if backupName == latestBackupStr {
+			latestFields = append(latestFields, backupNameField.name)
+		}
+	}
+
+	for i, backupType := range restore.Spec.IncludedBackupTypes {
+		if backupNames[ResourceType(backupType)] != skipRestoreStr {
+			continue
+		}
+		if ResourceType(backupType) == ResourcesGeneric &&
+			backupNames[ManagedClusters] != skipRestoreStr {
+			// the generic resources are restored with the managed clusters
+			continue
+		}
+		skippedFields := "spec." + fieldNames[ResourceType(backupType)] + " is"
+		if ResourceType(backupType) == ResourcesGeneric {
+			skippedFields = "spec." + fieldNames[ResourcesGeneric] +
+				" and spec." + fieldNames[ManagedClusters] + " are"
+		}
+		errs = append(errs, field.Invalid(specPath.Child("includedBackupTypes").Index(i), backupType,
+			fmt.Sprintf("the backup type is included but %s set to %s", skippedFields, skipRestoreStr)))
+	}
+
+	if restore.Spec.RestoreToTime != nil && len(latestFields) == 0 {
+		errs = append(errs, field.Invalid(specPath.Child("restoreToTime"),
+			restore.Spec.RestoreToTime.UTC().Format(time.RFC3339),
+			fmt.Sprintf("the restore time selects the %s backups but no backup name is set to %s",
+				latestBackupStr, latestBackupStr)))
+	}
+
+	if restore.Spec.SyncRestoreWithNewBackups {
+		for _, backupNameField := range restoreBackupNameFields {
+			backupName := getRestoreBackupNameValue(restore, backupNameField.getValueFunc)
+			if backupName == latestBackupStr ||
+				(backupName == skipRestoreStr && backupNameField.name == "veleroManagedClustersBackupName") {
+				continue
+			}
+			errs = append(errs, field.Invalid(specPath.Child(backupNameField.name), backupName,
+				fmt.Sprintf("spec.syncRestoreWithNewBackups restores the %s backups, "+
+					"set spec.%s to %s", latestBackupStr, backupNameField.name, latestBackupStr)))
+		}
+	}
+	return errs
+}
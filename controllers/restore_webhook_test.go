@@ -0,0 +1,187 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	v1beta1 "github.com/stolostron/cluster-backup-operator/api/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestRestoreWebhook(t *testing.T) {
+
+	webhookClient, ctx := startWebhookTestEnv(t, func(mgr ctrl.Manager) error {
+		return (&RestoreValidator{}).SetupWebhookWithManager(mgr)
+	})
+	namespace := "restore-webhook-ns"
+	if err := webhookClient.Create(ctx, &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: namespace},
+	}); err != nil {
+		t.Fatalf("unable to create the namespace: %v", err)
+	}
+
+	backupName := "acm-resources-schedule-20220420120000"
+	restoreToTime := metav1.NewTime(time.Date(2022, 4, 20, 12, 0, 0, 0, time.UTC))
+	tests := []struct {
+		name                string
+		managedClusters     string
+		credentials         string
+		resources           string
+		includedBackupTypes []string
+		restoreToTime       *metav1.Time
+		syncWithNewBackups  bool
+		wantErrFields       []string
+	}{
+		{
+			name:            "latest backups",
+			managedClusters: latestBackupStr,
+			credentials:     latestBackupStr,
+			resources:       latestBackupStr,
+		},
+		{
+			name:            "backup names",
+			managedClusters: skipRestoreStr,
+			credentials:     "acm-credentials-schedule-20220420120000",
+			resources:       backupName,
+		},
+		{
+			name:                "included backup types not skipped",
+			managedClusters:     skipRestoreStr,
+			credentials:         latestBackupStr,
+			resources:           backupName,
+			includedBackupTypes: []string{string(Credentials), string(Resources)},
+		},
+		{
+			name:                "generic resources restored with the managed clusters",
+			managedClusters:     latestBackupStr,
+			credentials:         latestBackupStr,
+			resources:           skipRestoreStr,
+			includedBackupTypes: []string{string(ResourcesGeneric), string(ManagedClusters)},
+		},
+		{
+			name:                "included backup type set to skip",
+			managedClusters:     skipRestoreStr,
+			credentials:         latestBackupStr,
+			resources:           skipRestoreStr,
+			includedBackupTypes: []string{string(Credentials), string(Resources)},
+			wantErrFields:       []string{"spec.includedBackupTypes[1]", "spec.veleroResourcesBackupName"},
+		},
+		{
+			name:                "included generic resources with resources and managed clusters skipped",
+			managedClusters:     skipRestoreStr,
+			credentials:         latestBackupStr,
+			resources:           skipRestoreStr,
+			includedBackupTypes: []string{string(ResourcesGeneric)},
+			wantErrFields: []string{"spec.includedBackupTypes[0]",
+				"spec.veleroResourcesBackupName", "spec.veleroManagedClustersBackupName"},
+		},
+		{
+			name:            "restore time with a latest backup",
+			managedClusters: skipRestoreStr,
+			credentials:     latestBackupStr,
+			resources:       backupName,
+			restoreToTime:   &restoreToTime,
+		},
+		{
+			name:            "restore time with backup names only",
+			managedClusters: skipRestoreStr,
+			credentials:     "acm-credentials-schedule-20220420120000",
+			resources:       backupName,
+			restoreToTime:   &restoreToTime,
+			wantErrFields:   []string{"spec.restoreToTime"},
+		},
+		{
+			name:               "sync with new backups",
+			managedClusters:    skipRestoreStr,
+			credentials:        latestBackupStr,
+			resources:          latestBackupStr,
+			syncWithNewBackups: true,
+		},
+		{
+			name:               "sync with new backups and a backup name",
+			managedClusters:    skipRestoreStr,
+			credentials:        latestBackupStr,
+			resources:          backupName,
+			syncWithNewBackups: true,
+			wantErrFields:      []string{"spec.veleroResourcesBackupName"},
+		},
+		{
+			name:               "sync with new backups and skipped credentials",
+			managedClusters:    latestBackupStr,
+			credentials:        skipRestoreStr,
+			resources:          latestBackupStr,
+			syncWithNewBackups: true,
+			wantErrFields:      []string{"spec.veleroCredentialsBackupName"},
+		},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			restore := &v1beta1.Restore{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      fmt.Sprintf("webhook-restore-%d", i),
+					Namespace: namespace,
+				},
+				Spec: v1beta1.RestoreSpec{
+					CleanupBeforeRestore:            v1beta1.CleanupTypeNone,
+					VeleroManagedClustersBackupName: &tt.managedClusters,
+					VeleroCredentialsBackupName:     &tt.credentials,
+					VeleroResourcesBackupName:       &tt.resources,
+					IncludedBackupTypes:             tt.includedBackupTypes,
+					RestoreToTime:                   tt.restoreToTime,
+					SyncRestoreWithNewBackups:       tt.syncWithNewBackups,
+				},
+			}
+			err := webhookClient.Create(ctx, restore)
+			if len(tt.wantErrFields) == 0 {
+				if err != nil {
+					t.Errorf("Create() error = %v, want no error", err)
+				}
+				return
+			}
+			if !apierrors.IsInvalid(err) {
+				t.Fatalf("Create() error = %v, want an invalid error", err)
+			}
+			for _, fieldPath := range tt.wantErrFields {
+				if !strings.Contains(err.Error(), fieldPath) {
+					t.Errorf("Create() error = %v, want an error for %s", err, fieldPath)
+				}
+			}
+		})
+	}
+
+	// updates are validated too
+	restore := &v1beta1.Restore{}
+	if err := webhookClient.Get(ctx, client.ObjectKey{
+		Name: "webhook-restore-0", Namespace: namespace}, restore); err != nil {
+		t.Fatalf("unable to get the restore: %v", err)
+	}
+	restore.Spec.IncludedBackupTypes = []string{string(ManagedClusters)}
+	skip := skipRestoreStr
+	restore.Spec.VeleroManagedClustersBackupName = &skip
+	if err := webhookClient.Update(ctx, restore); !apierrors.IsInvalid(err) ||
+		!strings.Contains(err.Error(), "spec.includedBackupTypes[0]") {
+		t.Errorf("Update() error = %v, want an error for spec.includedBackupTypes[0]", err)
+	}
+}
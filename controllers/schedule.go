@@ -17,18 +17,44 @@ limitations under the License.
 package controllers
 
 import (
+	"compress/gzip"
 	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"net/http"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	hivev1 "github.com/openshift/hive/apis/hive/v1"
 	"github.com/robfig/cron/v3"
 	v1beta1 "github.com/stolostron/cluster-backup-operator/api/v1beta1"
 	veleroapi "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	k8serr "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	apivalidation "k8s.io/apimachinery/pkg/api/validation"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	metav1validation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 )
@@ -49,8 +75,45 @@ const (
 		" This is a backup collision with current cluster [%s] backup." +
 		" Review and resolve the collision then create a new BackupSchedule resource to " +
 		" resume backups from this cluster."
+	// BackupCollisionWindowMsg when another cluster created backups within the collision prevention window
+	BackupCollisionWindowMsg string = "Backup %s, from cluster with id [%s], was created at %s," +
+		" within the collisionPreventionWindow %s. Another cluster could be using the same storage location," +
+		" the backups are not scheduled on the current cluster [%s]." +
+		" Review and resolve the collision then create a new BackupSchedule resource to" +
+		" resume backups from this cluster."
+	// EmptyBackupMsg when a backup completed without any resources
+	EmptyBackupMsg string = "Backup %s has no resources"
+	// AdoptedPhaseMsg for when existing Velero schedules are adopted by the backup schedule
+	AdoptedPhaseMsg string = "Adopted existing Velero schedules: %s"
+	// PausedPhaseMsg for when the backup schedule is paused
+	PausedPhaseMsg string = "BackupSchedule is paused, the Velero schedules are removed " +
+		"and no backups are created"
+	// ShortTTLMsg when backups expire before the operator can act on them
+	ShortTTLMsg string = "VeleroTTL %s is shorter than %s %s, " +
+		"backups could expire before they are validated"
 )
 
+// maximum number of backup sets shown by the backup schedule status
+const maxRestorableBackupSets = 10
+
+// maximum time between the timestamps of the backups of the same backup set
+const backupSetTimestampTolerance = 30 * time.Second
+
+// backup types restored with the resources backup, required for a complete backup set
+var backupSetTypes = []ResourceType{
+	Credentials,
+	CredentialsHive,
+	CredentialsCluster,
+	ResourcesGeneric,
+	ManagedClusters,
+}
+
+// BackupSchedule resource, using the kind.group format
+const backupScheduleResource = "backupschedule.cluster.open-cluster-management.io"
+
+// name of the BackupSchedule resource created for existing Velero schedules
+const adoptedBackupScheduleName = "acm-adopted-schedule"
+
 func updateScheduleStatus(
 	ctx context.Context,
 	veleroSchedule *veleroapi.Schedule,
@@ -127,123 +190,3356 @@ func setSchedulePhase(
 	backupSchedule.Status.LastMessage = EnabledPhaseMsg
 }
 
-func isScheduleSpecUpdated(
+// returns a warning message for a completed backup with no resources
+// matchingResources is the number of hub resources matching the backup label selector,
+// or a negative value if this number is not known for the backup type
+func getEmptyBackupMessage(
+	backup *veleroapi.Backup,
+	matchingResources int,
+	allowEmptyBackups bool,
+) string {
+
+	if backup.Status.Progress == nil || backup.Status.Progress.TotalItems > 0 {
+		return ""
+	}
+
+	msg := fmt.Sprintf(EmptyBackupMsg, backup.Name)
+	if matchingResources > 0 {
+		// resources should have been backed up, this is not an expected empty backup
+		return fmt.Sprintf("%s although %d resources on the hub match the backup label selector.",
+			msg, matchingResources)
+	}
+	if allowEmptyBackups {
+		return ""
+	}
+	if matchingResources == 0 {
+		msg = msg + ", no resources on the hub match the backup label selector"
+	}
+	return msg + ". Set allowEmptyBackups to true if this is expected."
+}
+
+// returns a warning message for the latest backups with no resources, if any
+func (r *BackupScheduleReconciler) getEmptyBackupsMessage(
+	ctx context.Context,
 	schedules *veleroapi.ScheduleList,
 	backupSchedule *v1beta1.BackupSchedule,
-) bool {
+) string {
 
-	if schedules == nil || len(schedules.Items) <= 0 {
-		return false
-	}
+	logger := log.FromContext(ctx)
+	msgs := []string{}
 
 	for i := range schedules.Items {
 		veleroSchedule := &schedules.Items[i]
+		if veleroSchedule.Name == veleroScheduleNames[ValidationSchedule] {
+			// validation backups are not expected to have resources
+			continue
+		}
 
-		if veleroSchedule.Name != veleroScheduleNames[ValidationSchedule] &&
-			veleroSchedule.Spec.Template.TTL.Duration != backupSchedule.Spec.VeleroTTL.Duration {
-			// validation backup TTL should be ignored here
-			// since that one is using the schedule's cron job interval
-			return true
+		backups := veleroapi.BackupList{}
+		if err := r.List(ctx, &backups,
+			client.InNamespace(veleroSchedule.Namespace),
+			client.MatchingLabels{"velero.io/schedule-name": veleroSchedule.Name}); err != nil {
+			logger.Info(err.Error())
+			continue
 		}
-		if veleroSchedule.Spec.Schedule != backupSchedule.Spec.VeleroSchedule {
-			return true
+		completedBackups := filterBackups(backups.Items, func(bkp veleroapi.Backup) bool {
+			return bkp.Status.Phase == veleroapi.BackupPhaseCompleted
+		})
+		if len(completedBackups) == 0 {
+			continue
+		}
+		sort.Sort(mostRecent(completedBackups))
+
+		if msg := getEmptyBackupMessage(
+			&completedBackups[0],
+			r.countResourcesMatchingBackup(ctx, veleroSchedule),
+			backupSchedule.Spec.AllowEmptyBackups,
+		); msg != "" {
+			msgs = append(msgs, msg)
+		}
+	}
+	return strings.Join(msgs, " ")
+}
+
+// returns the name of the completed backup of this type created by the hub and stored in the
+// storage location for the backup set created at timestamp: the backup created within
+// backupSetTimestampTolerance of the set timestamp or, if no backup of this type was created
+// at that time, for example when the type is scheduled using VeleroScheduleOverrides, the most
+// recent backup created before the set; returns an empty string if there is no such backup
+func findBackupSetMember(
+	backups []veleroapi.Backup,
+	backupType ResourceType,
+	timestamp time.Time,
+	hubID string,
+	storageLocation string,
+) string {
+
+	createdWithSet := false
+	previousBackup := ""
+	var previousTimestamp time.Time
+	for i := range backups {
+		if !strings.HasPrefix(backups[i].Name, veleroScheduleNames[backupType]+"-") {
+			continue
+		}
+		backupTimestamp, err := getBackupTimestamp(backups[i].Name)
+		if err != nil {
+			continue
+		}
+		usable := backups[i].Status.Phase == veleroapi.BackupPhaseCompleted &&
+			backups[i].GetLabels()[BackupScheduleClusterLabel] == hubID &&
+			backups[i].Spec.StorageLocation == storageLocation
+		diff := backupTimestamp.Sub(timestamp)
+		if diff <= backupSetTimestampTolerance && diff >= -backupSetTimestampTolerance {
+			if usable {
+				return backups[i].Name
+			}
+			// this type was backed up with the set, an older backup can't replace it
+			createdWithSet = true
+			continue
+		}
+		if usable && diff < 0 && (previousBackup == "" || backupTimestamp.After(previousTimestamp)) {
+			previousBackup = backups[i].Name
+			previousTimestamp = backupTimestamp
 		}
 	}
+	if createdWithSet {
+		return ""
+	}
+	return previousBackup
+}
 
-	return false
+// returns the type of a backup created by a backup schedule, using the backup name;
+// returns an empty string for the validation backups and the backups not created by a backup schedule
+func getBackupSetType(backupName string) ResourceType {
+
+	for backupType, scheduleName := range veleroScheduleNames {
+		if backupType != ValidationSchedule && strings.HasPrefix(backupName, scheduleName+"-") {
+			return backupType
+		}
+	}
+	return ""
 }
 
-func parseCronSchedule(
+// groups the backups by the storage location storing them; the backup schedules write the same
+// backups to each additional storage location, with names using the same backup type prefix,
+// so the backup sets are built from the backups of a single storage location
+func groupBackupsByStorageLocation(backups []veleroapi.Backup) map[string][]veleroapi.Backup {
+
+	locationBackups := map[string][]veleroapi.Backup{}
+	for i := range backups {
+		location := backups[i].Spec.StorageLocation
+		locationBackups[location] = append(locationBackups[location], backups[i])
+	}
+	return locationBackups
+}
+
+// returns the sorted storage location names of the grouped backups
+func getStorageLocationNames(locationBackups map[string][]veleroapi.Backup) []string {
+
+	names := make([]string, 0, len(locationBackups))
+	for name := range locationBackups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GetBackupSets groups the backups created by the backup schedules in the same run,
+// using the timestamp in the backup names. The backups of a run are not all created
+// at the same second, a backup created within backupSetTimestampTolerance of the
+// oldest backup of the set is part of the set. The sets are keyed by the timestamp
+// of their oldest backup; the backups with no timestamp in the name
+// or not created by a backup schedule are ignored. The backups must be stored
+// in the same storage location, see groupBackupsByStorageLocation
+func GetBackupSets(backups []veleroapi.Backup) map[string][]veleroapi.Backup {
+
+	scheduleBackups := filterBackups(backups, func(bkp veleroapi.Backup) bool {
+		_, err := getBackupTimestamp(bkp.Name)
+		return err == nil && getBackupSetType(bkp.Name) != ""
+	})
+	sort.SliceStable(scheduleBackups, func(i, j int) bool {
+		return getBackupTime(&scheduleBackups[i]).Before(getBackupTime(&scheduleBackups[j]))
+	})
+
+	backupSets := map[string][]veleroapi.Backup{}
+	setKey := ""
+	var setTimestamp time.Time
+	for i := range scheduleBackups {
+		timestamp := getBackupTime(&scheduleBackups[i])
+		if setKey == "" || timestamp.Sub(setTimestamp) > backupSetTimestampTolerance {
+			// first backup of a new set
+			setKey = timestamp.Format(backupTimestampLayout)
+			setTimestamp = timestamp
+		}
+		backupSets[setKey] = append(backupSets[setKey], scheduleBackups[i])
+	}
+	return backupSets
+}
+
+// returns the backup sets which can be restored, most recent first, at most maxRestorableBackupSets
+func getRestorableBackupSets(backups []veleroapi.Backup) []v1beta1.RestorableBackupSet {
+
+	backupSets := getCompleteBackupSets(backups)
+	if len(backupSets) > maxRestorableBackupSets {
+		backupSets = backupSets[:maxRestorableBackupSets]
+	}
+	return backupSets
+}
+
+// returns the complete backup sets, most recent first; a backup set is identified by
+// a completed resources backup and has a completed backup for each of the other backup types,
+// created by the same hub and stored in the same storage location, see findBackupSetMember.
+// The backup types scheduled using VeleroScheduleOverrides are backed up at other times,
+// the set uses their most recent backup created before the resources backup
+func getCompleteBackupSets(backups []veleroapi.Backup) []v1beta1.RestorableBackupSet {
+
+	backupSets := []v1beta1.RestorableBackupSet{}
+	for i := range backups {
+		resourcesBackup := &backups[i]
+		if resourcesBackup.Status.Phase != veleroapi.BackupPhaseCompleted ||
+			!strings.HasPrefix(resourcesBackup.Name, veleroScheduleNames[Resources]+"-") {
+			continue
+		}
+		timestamp, err := getBackupTimestamp(resourcesBackup.Name)
+		if err != nil {
+			continue
+		}
+
+		hubID := resourcesBackup.GetLabels()[BackupScheduleClusterLabel]
+		backupSet := v1beta1.RestorableBackupSet{
+			Name:      resourcesBackup.Name,
+			Timestamp: v1.NewTime(timestamp),
+			HubID:     hubID,
+			Backups:   []string{resourcesBackup.Name},
+		}
+		// the hive and cluster credentials are backed up with the credentials,
+		// the generic resources with the resources, see getOverrideBackupType
+		memberTimestamps := map[ResourceType]time.Time{Resources: timestamp}
+		for _, backupType := range backupSetTypes {
+			memberTimestamp := timestamp
+			if parentType := getOverrideBackupType(backupType); parentType != backupType {
+				memberTimestamp = memberTimestamps[parentType]
+			}
+			backupName := findBackupSetMember(backups, backupType, memberTimestamp, hubID,
+				resourcesBackup.Spec.StorageLocation)
+			if backupName == "" {
+				// not a complete backup set
+				backupSet.Backups = nil
+				break
+			}
+			memberTimestamps[backupType], _ = getBackupTimestamp(backupName)
+			backupSet.Backups = append(backupSet.Backups, backupName)
+		}
+		if backupSet.Backups != nil {
+			backupSets = append(backupSets, backupSet)
+		}
+	}
+
+	sort.SliceStable(backupSets, func(i, j int) bool {
+		return backupSets[j].Timestamp.Before(&backupSets[i].Timestamp)
+	})
+	return backupSets
+}
+
+// returns a velero backup for each velero schedule, except the validation schedule, using the
+// schedule backup template and labels; the backups are named as the backups created by velero
+// for the schedule, using the trigger time, so they are part of a backup set and can be restored
+func getBackupNowBackups(veleroSchedules []veleroapi.Schedule, now time.Time) []veleroapi.Backup {
+
+	backups := []veleroapi.Backup{}
+	for i := range veleroSchedules {
+		veleroSchedule := &veleroSchedules[i]
+		if veleroSchedule.GetLabels()[BackupScheduleTypeLabel] == string(ValidationSchedule) {
+			continue
+		}
+		labels := map[string]string{}
+		for key, value := range veleroSchedule.GetLabels() {
+			labels[key] = value
+		}
+		labels["velero.io/schedule-name"] = veleroSchedule.Name
+		annotations := getScheduleBackupAnnotations(veleroSchedule)
+
+		backup := veleroapi.Backup{}
+		backup.Name = veleroSchedule.Name + "-" + now.UTC().Format(backupTimestampLayout)
+		backup.Namespace = veleroSchedule.Namespace
+		backup.SetLabels(labels)
+		if len(annotations) > 0 {
+			backup.SetAnnotations(annotations)
+		}
+		backup.Spec = *veleroSchedule.Spec.Template.DeepCopy()
+		backups = append(backups, backup)
+	}
+	sort.SliceStable(backups, func(i, j int) bool {
+		return backups[i].Name < backups[j].Name
+	})
+	return backups
+}
+
+// creates the backups requested by the BackupNow property, out of the cron schedule,
+// and shows them in the status; the property is reset first, so the backups are created
+// at most once for each trigger. A failed backup creation is reported with a Warning event
+func (r *BackupScheduleReconciler) backupNow(
 	ctx context.Context,
 	backupSchedule *v1beta1.BackupSchedule,
-) []string {
-	var validationErrors []string
+	veleroSchedules []veleroapi.Schedule,
+) error {
 
-	// cron.Parse panics if schedule is empty
-	if len(backupSchedule.Spec.VeleroSchedule) == 0 {
-		validationErrors = append(
-			validationErrors,
-			"Schedule must be a non-empty valid Cron expression",
-		)
-		return validationErrors
+	status := backupSchedule.Status.DeepCopy()
+	patch := client.MergeFrom(backupSchedule.DeepCopy())
+	backupSchedule.Spec.BackupNow = false
+	err := r.Patch(ctx, backupSchedule, patch)
+	// the patch returns the stored status, keep the status set by this reconcile
+	backupSchedule.Status = *status
+	if err != nil {
+		return err
 	}
 
-	scheduleLogger := log.FromContext(ctx)
+	now := v1.Now()
+	backupNames := []string{}
+	backups := getBackupNowBackups(veleroSchedules, now.Time)
+	for i := range backups {
+		if _, err := createVeleroResource(ctx, r.Client, &backups[i]); err != nil {
+			log.FromContext(ctx).Error(err, "Failed to create backup", "name", backups[i].Name)
+			r.Recorder.Event(backupSchedule, corev1.EventTypeWarning, "Backup now failed:",
+				fmt.Sprintf("Backup %s could not be created: %s", backups[i].Name, err.Error()))
+			continue
+		}
+		backupNames = append(backupNames, backups[i].Name)
+	}
+	backupSchedule.Status.BackupNowBackups = backupNames
+	backupSchedule.Status.BackupNowTime = &now
+	if len(backupNames) > 0 {
+		r.Recorder.Event(backupSchedule, corev1.EventTypeNormal, "Backup now:",
+			fmt.Sprintf("Backups created: %s", strings.Join(backupNames, ", ")))
+	}
+	return nil
+}
 
-	// adding a recover() around cron.Parse because it panics on empty string and is possible
-	// that it panics under other scenarios as well.
-	func() {
-		defer func() {
-			if r := recover(); r != nil {
-				scheduleLogger.Info(
-					"Panic parsing schedule",
-					"schedule", backupSchedule.Spec.VeleroSchedule,
-				)
-				validationErrors = append(validationErrors, fmt.Sprintf("invalid schedule: %v", r))
+// returns the backups to delete to keep the newest keepN complete backup sets in each storage
+// location; the backups are grouped in sets using the timestamp in their names, see GetBackupSets,
+// so backups with the same timestamp are kept or deleted together. The sets older than the oldest
+// complete set kept are deleted, complete or not; the incomplete sets more recent than that one,
+// for example with backups still running, are kept. Nothing is deleted if keepN is not positive
+func pruneOldBackups(backups []veleroapi.Backup, keepN int) []veleroapi.Backup {
+
+	backupsToDelete := []veleroapi.Backup{}
+	if keepN <= 0 {
+		return backupsToDelete
+	}
+
+	locationBackups := groupBackupsByStorageLocation(backups)
+	for _, location := range getStorageLocationNames(locationBackups) {
+		backupsToDelete = append(backupsToDelete,
+			pruneOldBackupSets(locationBackups[location], keepN)...)
+	}
+	return backupsToDelete
+}
+
+// returns the backups to delete to keep the newest keepN complete backup sets,
+// for the backups stored in the same storage location; the backups of a kept set
+// created before the set, for the types scheduled using VeleroScheduleOverrides, are kept
+func pruneOldBackupSets(backups []veleroapi.Backup, keepN int) []veleroapi.Backup {
+
+	backupsToDelete := []veleroapi.Backup{}
+	completeSets := getCompleteBackupSets(backups)
+	if len(completeSets) <= keepN {
+		return backupsToDelete
+	}
+	keptBackups := map[string]bool{}
+	for _, backupSet := range completeSets[:keepN] {
+		for _, name := range backupSet.Backups {
+			keptBackups[name] = true
+		}
+	}
+	oldestKeptBackup := completeSets[keepN-1].Name
+
+	backupSets := GetBackupSets(backups)
+	setKeys := make([]string, 0, len(backupSets))
+	for key := range backupSets {
+		setKeys = append(setKeys, key)
+	}
+	// the set keys use the backup timestamp layout, sort them most recent first
+	sort.Sort(sort.Reverse(sort.StringSlice(setKeys)))
+
+	keepSet := true
+	for _, key := range setKeys {
+		if keepSet {
+			// keep the sets down to the one of the oldest complete set kept
+			for i := range backupSets[key] {
+				if backupSets[key][i].Name == oldestKeptBackup {
+					keepSet = false
+				}
 			}
-		}()
+			continue
+		}
+		for i := range backupSets[key] {
+			if !keptBackups[backupSets[key][i].Name] &&
+				backupSets[key][i].Status.Phase != veleroapi.BackupPhaseDeleting {
+				backupsToDelete = append(backupsToDelete, backupSets[key][i])
+			}
+		}
+	}
+	return backupsToDelete
+}
 
-		if _, err := cron.ParseStandard(backupSchedule.Spec.VeleroSchedule); err != nil {
-			scheduleLogger.Error(
-				err,
-				"Error parsing schedule",
-				"schedule", backupSchedule.Spec.VeleroSchedule,
-			)
-			validationErrors = append(validationErrors, fmt.Sprintf("invalid schedule: %v", err))
+// deletes the backups created by the backup schedule which are older than
+// the newest MaxBackups complete backup sets, if MaxBackups is set
+func (r *BackupScheduleReconciler) pruneBackups(
+	ctx context.Context,
+	backupSchedule *v1beta1.BackupSchedule,
+	backups []veleroapi.Backup,
+) {
+
+	if backupSchedule.Spec.MaxBackups <= 0 {
+		return
+	}
+	backupsToDelete := pruneOldBackups(backups, backupSchedule.Spec.MaxBackups)
+	for i := range backupsToDelete {
+		log.FromContext(ctx).Info("Backup is older than the kept backup sets, delete it",
+			"name", backupsToDelete[i].Name, "maxBackups", backupSchedule.Spec.MaxBackups)
+		deleteBackup(ctx, &backupsToDelete[i], r.Client)
+	}
+}
+
+// returns the most recent completed backup for each backup type, sorted by backup type
+func getLastSuccessfulBackups(backups []veleroapi.Backup) []v1beta1.LastSuccessfulBackup {
+
+	lastBackups := map[ResourceType]v1beta1.LastSuccessfulBackup{}
+	for i := range backups {
+		backup := &backups[i]
+		if backup.Status.Phase != veleroapi.BackupPhaseCompleted {
+			continue
+		}
+		backupType := ResourceType(backup.GetLabels()[BackupScheduleTypeLabel])
+		if _, ok := veleroScheduleNames[backupType]; !ok {
+			continue
+		}
+		timestamp, err := getBackupTimestamp(backup.Name)
+		if err != nil {
+			continue
+		}
+		if last, ok := lastBackups[backupType]; ok &&
+			!last.LastSuccessfulTimestamp.Time.Before(timestamp) {
+			continue
+		}
+		lastBackup := v1beta1.LastSuccessfulBackup{
+			BackupType:              string(backupType),
+			LastSuccessfulTimestamp: v1.NewTime(timestamp),
+			LastBackupName:          backup.Name,
+		}
+		if backup.Status.Progress != nil {
+			lastBackup.ItemsBackedUp = backup.Status.Progress.ItemsBackedUp
+		}
+		lastBackups[backupType] = lastBackup
+	}
+
+	backupTypes := make([]ResourceType, 0, len(lastBackups))
+	for backupType := range lastBackups {
+		backupTypes = append(backupTypes, backupType)
+	}
+	sort.Sort(SortResourceType(backupTypes))
+
+	result := make([]v1beta1.LastSuccessfulBackup, 0, len(backupTypes))
+	for _, backupType := range backupTypes {
+		result = append(result, lastBackups[backupType])
+	}
+	return result
+}
+
+// time to wait for Velero to process a download request and to download the requested file
+const downloadRequestTimeout = 30 * time.Second
+
+// returns the resource kinds backed up by a completed velero backup, in the kind.group format,
+// sorted with SortResourceType, read from the backup resource list Velero stores with the
+// backup contents, see getBackupResourceList. Falls back to the kinds requested by the backup,
+// see getRequestedResourceKinds, if the resource list can't be downloaded; returns false
+// in this case. No kind is returned if the backup is not completed or has no backed up item
+func (r *BackupScheduleReconciler) getBackedUpResourceKinds(
+	ctx context.Context,
+	backup *veleroapi.Backup,
+	excludedGroups []string,
+) ([]ResourceType, bool) {
+
+	if backup.Status.Phase != veleroapi.BackupPhaseCompleted &&
+		backup.Status.Phase != veleroapi.BackupPhasePartiallyFailed {
+		return nil, true
+	}
+	if backup.Status.Progress != nil && backup.Status.Progress.ItemsBackedUp == 0 {
+		return nil, true
+	}
+
+	resourceList, err := r.getBackupResourceList(ctx, backup)
+	if err == nil {
+		return getResourceListKinds(resourceList), true
+	}
+	log.FromContext(ctx).Info("Failed to download the backup resource list, "+
+		"showing the requested resource kinds", "backup", backup.Name, "error", err.Error())
+	return r.getRequestedResourceKinds(ctx, backup, excludedGroups), false
+}
+
+// returns the resource kinds requested by the velero backup, in the kind.group format,
+// sorted with SortResourceType: the backup included resources less the excluded resources,
+// or the generic resources kinds served by the hub, less the excluded API groups kinds,
+// for a backup with no included resources
+func (r *BackupScheduleReconciler) getRequestedResourceKinds(
+	ctx context.Context,
+	backup *veleroapi.Backup,
+	excludedGroups []string,
+) []ResourceType {
+
+	resources := []string{}
+	if len(backup.Spec.IncludedResources) == 0 || findValue(backup.Spec.IncludedResources, "*") {
+		genericResources, err := getGenericCRDFromAPIGroups(ctx, r.DiscoveryClient, backup, excludedGroups)
+		if err != nil {
+			log.FromContext(ctx).Info("Failed to discover the requested resource kinds",
+				"backup", backup.Name, "error", err.Error())
+			return nil
+		}
+		resources = genericResources
+	} else {
+		excludedResources := newCaseInsensitiveSet(backup.Spec.ExcludedResources)
+		for _, resource := range backup.Spec.IncludedResources {
+			kind, _ := getResourceDetails(strings.ToLower(resource))
+			if !excludedResources.has(resource) && !excludedResources.has(kind) {
+				resources = appendUnique(resources, strings.ToLower(resource))
+			}
+		}
+	}
+
+	kinds := make([]ResourceType, 0, len(resources))
+	for _, resource := range resources {
+		kinds = append(kinds, ResourceType(resource))
+	}
+	sort.Sort(SortResourceType(kinds))
+	return kinds
+}
+
+// returns the resource kinds of a backup resource list, in the kind.group format, sorted with
+// SortResourceType; the list maps each backed up group/version/kind, such as apps/v1/Deployment
+// or v1/Secret, to the backed up resources
+func getResourceListKinds(resourceList map[string][]string) []ResourceType {
+
+	resources := []string{}
+	for gvk := range resourceList {
+		index := strings.LastIndex(gvk, "/")
+		if index < 0 {
+			continue
+		}
+		gv, err := schema.ParseGroupVersion(gvk[:index])
+		if err != nil {
+			continue
+		}
+		resource := strings.ToLower(gvk[index+1:])
+		if gv.Group != "" {
+			resource = resource + "." + gv.Group
+		}
+		resources = appendUnique(resources, resource)
+	}
+
+	kinds := make([]ResourceType, 0, len(resources))
+	for _, resource := range resources {
+		kinds = append(kinds, ResourceType(resource))
+	}
+	sort.Sort(SortResourceType(kinds))
+	return kinds
+}
+
+// returns the backup resource list Velero stores with the backup contents, using a velero
+// DownloadRequest for the BackupResourceList of the backup; the download request is
+// deleted once the list is downloaded, Velero deletes it only when the download URL expires
+func (r *BackupScheduleReconciler) getBackupResourceList(
+	ctx context.Context,
+	backup *veleroapi.Backup,
+) (map[string][]string, error) {
+
+	downloadCtx, cancel := context.WithTimeout(ctx, downloadRequestTimeout)
+	defer cancel()
+
+	downloadRequest := &veleroapi.DownloadRequest{}
+	downloadRequest.Name = backup.Name + "-resource-list"
+	downloadRequest.Namespace = backup.Namespace
+	downloadRequest.Spec.Target = veleroapi.DownloadTarget{
+		Kind: veleroapi.DownloadTargetKindBackupResourceList,
+		Name: backup.Name,
+	}
+	if _, err := createVeleroResource(downloadCtx, r.Client, downloadRequest); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := r.Delete(ctx, downloadRequest); client.IgnoreNotFound(err) != nil {
+			log.FromContext(ctx).Info("Failed to delete the download request",
+				"name", downloadRequest.Name, "error", err.Error())
 		}
 	}()
 
-	if len(validationErrors) > 0 {
-		return validationErrors
+	key := client.ObjectKeyFromObject(downloadRequest)
+	if err := wait.PollImmediateUntil(time.Second, func() (bool, error) {
+		if err := r.Get(downloadCtx, key, downloadRequest); err != nil {
+			// the download request could be missing from the cache right after it is created
+			return false, client.IgnoreNotFound(err)
+		}
+		return downloadRequest.Status.DownloadURL != "", nil
+	}, downloadCtx.Done()); err != nil {
+		return nil, fmt.Errorf("download request %s not processed by Velero: %v", key.Name, err)
 	}
 
-	return nil
+	// trust the CA bundle set on the backup storage location, as for the storage location probe
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	storageLocation := &veleroapi.BackupStorageLocation{}
+	if err := r.Get(downloadCtx, types.NamespacedName{
+		Name:      backup.Spec.StorageLocation,
+		Namespace: backup.Namespace,
+	}, storageLocation); err == nil {
+		if tlsConfig, err = getStorageLocationTLSConfig(storageLocation); err != nil {
+			return nil, err
+		}
+	}
+	return downloadBackupResourceList(downloadCtx, downloadRequest.Status.DownloadURL, tlsConfig)
 }
 
-// returns true if this schedule has generated the latest backups in the
-// storage location
-func (r *BackupScheduleReconciler) scheduleOwnsLatestStorageBackups(
+// downloads the gzipped JSON backup resource list from the download URL
+// set by Velero on a processed download request
+func downloadBackupResourceList(
 	ctx context.Context,
-	backupSchedule *veleroapi.Schedule,
-) (bool, *veleroapi.Backup) {
+	downloadURL string,
+	tlsConfig *tls.Config,
+) (map[string][]string, error) {
 
-	logger := log.FromContext(ctx)
+	// use the default transport settings, including the proxy from the environment
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	downloadClient := &http.Client{Transport: transport}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, downloadURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := downloadClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download failed: %s", resp.Status)
+	}
 
-	backups := veleroapi.BackupList{}
-	if err := r.List(ctx, &backups,
-		client.MatchingLabels{"velero.io/schedule-name": veleroScheduleNames[Resources]}); err != nil {
-		logger.Info(err.Error())
-		return true, nil
+	gzipReader, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		return nil, err
 	}
-	// get only acm resources backups and not in deleting state
-	// which are backups starting with acm-resources-schedule
-	sliceBackups := filterBackups(backups.Items[:], func(bkp veleroapi.Backup) bool {
-		return bkp.Status.Phase != veleroapi.BackupPhaseDeleting
-	})
+	defer gzipReader.Close()
+	resourceList := map[string][]string{}
+	if err := json.NewDecoder(gzipReader).Decode(&resourceList); err != nil {
+		return nil, err
+	}
+	return resourceList, nil
+}
 
-	// sort backups
-	sort.Slice(sliceBackups, func(i, j int) bool {
-		var timeA int64
-		var timeB int64
-		if sliceBackups[i].Status.StartTimestamp != nil {
-			timeA = sliceBackups[i].Status.StartTimestamp.Time.Unix()
+// maximum number of resource kinds shown for each last successful backup
+const maxReportedResourceKinds = 10
+
+// sets the number of resource kinds backed up by the last successful backups, and their first
+// maxReportedResourceKinds kinds, see getBackedUpResourceKinds; the kinds are looked up once
+// for each backup, the kinds shown by the previous status are kept for the same backup
+func (r *BackupScheduleReconciler) setBackedUpResourceKinds(
+	ctx context.Context,
+	backupSchedule *v1beta1.BackupSchedule,
+	previousBackups []v1beta1.LastSuccessfulBackup,
+	backups []veleroapi.Backup,
+) {
+
+	for i := range backupSchedule.Status.LastSuccessfulBackups {
+		lastBackup := &backupSchedule.Status.LastSuccessfulBackups[i]
+		if previous := findLastSuccessfulBackup(previousBackups,
+			lastBackup.LastBackupName); previous != nil && previous.ResourceKindsCount > 0 {
+			lastBackup.ResourceKindsCount = previous.ResourceKindsCount
+			lastBackup.ResourceKinds = previous.ResourceKinds
+			lastBackup.ResourceKindsRequested = previous.ResourceKindsRequested
+			continue
 		}
-		if sliceBackups[j].Status.StartTimestamp != nil {
-			timeB = sliceBackups[j].Status.StartTimestamp.Time.Unix()
+		for j := range backups {
+			if backups[j].Name != lastBackup.LastBackupName {
+				continue
+			}
+			kinds, backedUp := r.getBackedUpResourceKinds(ctx, &backups[j],
+				backupSchedule.Spec.ExcludedAPIGroups)
+			lastBackup.ResourceKindsCount = len(kinds)
+			lastBackup.ResourceKindsRequested = !backedUp
+			lastBackup.ResourceKinds = nil
+			for k := 0; k < len(kinds) && k < maxReportedResourceKinds; k++ {
+				lastBackup.ResourceKinds = append(lastBackup.ResourceKinds, string(kinds[k]))
+			}
+			break
 		}
-		return timeA < timeB
-	})
+	}
+}
 
-	if len(sliceBackups) == 0 {
-		return true, nil
+// returns the last successful backup with the name, nil if not found
+func findLastSuccessfulBackup(
+	lastBackups []v1beta1.LastSuccessfulBackup,
+	name string,
+) *v1beta1.LastSuccessfulBackup {
+
+	for i := range lastBackups {
+		if lastBackups[i].LastBackupName == name {
+			return &lastBackups[i]
+		}
 	}
-	lastBackup := sliceBackups[len(sliceBackups)-1]
+	return nil
+}
 
-	if lastBackup.Labels[BackupScheduleClusterLabel] != backupSchedule.GetLabels()[BackupScheduleClusterLabel] {
-		return false, &lastBackup
+// returns the backups not finished yet, with the progress reported by Velero,
+// sorted by backup type and name; the validation backups are not shown
+func getInProgressBackupsStatus(backups []veleroapi.Backup) []v1beta1.InProgressBackup {
+
+	inProgressBackups := []v1beta1.InProgressBackup{}
+	for i := range backups {
+		backup := &backups[i]
+		if backup.Status.Phase != veleroapi.BackupPhaseNew &&
+			backup.Status.Phase != veleroapi.BackupPhaseInProgress {
+			continue
+		}
+		backupType := ResourceType(backup.GetLabels()[BackupScheduleTypeLabel])
+		if _, ok := veleroScheduleNames[backupType]; !ok || backupType == ValidationSchedule {
+			continue
+		}
+		inProgressBackup := v1beta1.InProgressBackup{
+			BackupType: string(backupType),
+			BackupName: backup.Name,
+		}
+		if backup.Status.Progress != nil {
+			inProgressBackup.ItemsBackedUp = backup.Status.Progress.ItemsBackedUp
+			inProgressBackup.TotalItems = backup.Status.Progress.TotalItems
+		}
+		inProgressBackups = append(inProgressBackups, inProgressBackup)
 	}
+	sort.Slice(inProgressBackups, func(i, j int) bool {
+		if inProgressBackups[i].BackupType != inProgressBackups[j].BackupType {
+			return inProgressBackups[i].BackupType < inProgressBackups[j].BackupType
+		}
+		return inProgressBackups[i].BackupName < inProgressBackups[j].BackupName
+	})
+	return inProgressBackups
+}
 
-	return true, nil
+// how long before a scheduled backup is due the backed up resources are compared
+// with the resources of the last scheduled backup, when SkipUnchangedBackups is set
+const skipUnchangedBackupsWindow = time.Minute
+
+// the TTL of the backups when the backup schedule doesn't set the veleroTtl
+const veleroDefaultTTL = 720 * time.Hour
+
+// returns true if the newest backup set created by the hub with the clusterID id is
+// more recent than half of the backups TTL; otherwise the scheduled backups are not skipped,
+// so a new backup set is created before the last one expires
+func hasRecentBackupSet(
+	backupSchedule *v1beta1.BackupSchedule,
+	clusterID string,
+	now time.Time,
+) bool {
+
+	ttl := backupSchedule.Spec.VeleroTTL.Duration
+	if ttl == 0 {
+		ttl = veleroDefaultTTL
+	}
+	// the restorable backup sets are sorted, most recent first
+	for _, backupSet := range backupSchedule.Status.RestorableBackups {
+		if backupSet.HubID == clusterID {
+			return now.Sub(backupSet.Timestamp.Time) < ttl/2
+		}
+	}
+	return false
+}
+
+// returns the time the next scheduled backup is due, the earliest next run time of the
+// velero schedules except the validation schedule; the zero time is returned if a
+// velero schedule didn't run yet, velero runs it right away
+func getNextScheduledBackupTime(
+	veleroSchedules []veleroapi.Schedule,
+	cronSchedule cron.Schedule,
+) time.Time {
+
+	var next time.Time
+	for i := range veleroSchedules {
+		veleroSchedule := &veleroSchedules[i]
+		if veleroSchedule.Name == veleroScheduleNames[ValidationSchedule] {
+			continue
+		}
+		if veleroSchedule.Status.LastBackup == nil {
+			return time.Time{}
+		}
+		runTime := cronSchedule.Next(veleroSchedule.Status.LastBackup.Time)
+		if next.IsZero() || runTime.Before(next) {
+			next = runTime
+		}
+	}
+	return next
+}
+
+// returns a fingerprint of the resources, the resources count and a hash of their
+// api version, kind, namespace, name and resourceVersion;
+// the fingerprint doesn't depend on the order of the resources
+func getResourcesFingerprint(resources []unstructured.Unstructured) string {
+
+	keys := make([]string, 0, len(resources))
+	for i := range resources {
+		keys = append(keys, strings.Join([]string{
+			resources[i].GetAPIVersion(),
+			resources[i].GetKind(),
+			resources[i].GetNamespace(),
+			resources[i].GetName(),
+			resources[i].GetResourceVersion(),
+		}, "/"))
+	}
+	sort.Strings(keys)
+	hash := sha256.Sum256([]byte(strings.Join(keys, "\n")))
+	return fmt.Sprintf("%d-%s", len(keys), hex.EncodeToString(hash[:])[:16])
+}
+
+// returns true if the server resource matches one of the resource names, in the kind.group
+// or resource.group form; a name without a group matches the kind from any group
+func isResourceMatched(names []string, group string, resource v1.APIResource) bool {
+
+	for _, name := range names {
+		kind, kindGroup := getResourceDetails(strings.ToLower(name))
+		if (kind == strings.ToLower(resource.Kind) || kind == resource.Name) &&
+			(kindGroup == "" || kindGroup == group) {
+			return true
+		}
+	}
+	return false
+}
+
+// returns true if resources in this namespace are backed up by the backup template;
+// cluster-scoped resources are always returned
+func isNamespaceBackedUp(template *veleroapi.BackupSpec, namespace string) bool {
+
+	if namespace == "" {
+		return true
+	}
+	if len(template.IncludedNamespaces) > 0 &&
+		!findValue(template.IncludedNamespaces, "*") &&
+		!findValue(template.IncludedNamespaces, namespace) {
+		return false
+	}
+	return !findValue(template.ExcludedNamespaces, namespace)
+}
+
+// returns the resource types backed up by the backup template: the included resources or,
+// if the template includes all resources, the listable server resources not excluded by
+// the template. Cluster-scoped resources are returned unless the template excludes them
+func (r *BackupScheduleReconciler) getBackedUpResourceTypes(
+	ctx context.Context,
+	template *veleroapi.BackupSpec,
+) ([]schema.GroupVersionResource, error) {
+
+	gvrs := []schema.GroupVersionResource{}
+	if len(template.IncludedResources) > 0 && !findValue(template.IncludedResources, "*") {
+		for _, resource := range template.IncludedResources {
+			kind, group := getResourceDetails(strings.ToLower(resource))
+			gvr, err := r.RESTMapper.ResourceFor(schema.GroupVersionResource{
+				Group:    group,
+				Resource: kind,
+			})
+			if err != nil {
+				if meta.IsNoMatchError(err) {
+					// not installed on this hub, velero skips it too
+					continue
+				}
+				return nil, fmt.Errorf("failed to get resource for kind %s: %v", resource, err)
+			}
+			gvrs = append(gvrs, gvr)
+		}
+		return gvrs, nil
+	}
+
+	groupVersions, err := getServerGroupVersionResources(ctx, r.DiscoveryClient)
+	if err != nil {
+		return nil, err
+	}
+	includeClusterResources := template.IncludeClusterResources == nil ||
+		*template.IncludeClusterResources
+	for _, groupVersion := range groupVersions {
+		if groupVersion.resourceList.GroupVersion != groupVersion.group.PreferredVersion.GroupVersion {
+			// the same resources are served by the preferred version
+			continue
+		}
+		gv, err := schema.ParseGroupVersion(groupVersion.resourceList.GroupVersion)
+		if err != nil {
+			continue
+		}
+		for _, resource := range groupVersion.resourceList.APIResources {
+			if strings.Contains(resource.Name, "/") ||
+				!findValue(resource.Verbs, "list") ||
+				(!resource.Namespaced && !includeClusterResources) ||
+				isResourceMatched(template.ExcludedResources, gv.Group, resource) {
+				continue
+			}
+			gvrs = append(gvrs, gv.WithResource(resource.Name))
+		}
+	}
+	return gvrs, nil
+}
+
+// returns the fingerprint of the hub resources backed up by the velero schedules,
+// see getResourcesFingerprint; the validation schedule and the storage location
+// schedules, with the same templates as the default location schedules, are ignored
+func (r *BackupScheduleReconciler) getBackedUpResourcesFingerprint(
+	ctx context.Context,
+	veleroSchedules []veleroapi.Schedule,
+) (string, error) {
+
+	resources := []unstructured.Unstructured{}
+	for i := range veleroSchedules {
+		veleroSchedule := &veleroSchedules[i]
+		scheduleType := ResourceType(veleroSchedule.GetLabels()[BackupScheduleTypeLabel])
+		if scheduleType == ValidationSchedule ||
+			veleroSchedule.Name != veleroScheduleNames[scheduleType] {
+			continue
+		}
+		template := &veleroSchedule.Spec.Template
+		selector := labels.Everything()
+		if template.LabelSelector != nil {
+			var err error
+			if selector, err = v1.LabelSelectorAsSelector(template.LabelSelector); err != nil {
+				return "", err
+			}
+		}
+		gvrs, err := r.getBackedUpResourceTypes(ctx, template)
+		if err != nil {
+			return "", err
+		}
+		for _, gvr := range gvrs {
+			list, err := r.DynamicClient.Resource(gvr).List(ctx, v1.ListOptions{
+				LabelSelector: selector.String(),
+			})
+			if err != nil {
+				if k8serr.IsNotFound(err) {
+					continue
+				}
+				return "", fmt.Errorf("failed to list %s: %v", gvr.String(), err)
+			}
+			for j := range list.Items {
+				if isNamespaceBackedUp(template, list.Items[j].GetNamespace()) {
+					resources = append(resources, list.Items[j])
+				}
+			}
+		}
+	}
+	return getResourcesFingerprint(resources), nil
+}
+
+// when SkipUnchangedBackups is set, the fingerprint of the backed up hub resources is compared
+// with the fingerprint of the last scheduled backup shortly before the next backup is due;
+// if the resources didn't change and the last backup set is recent, the velero schedules are
+// created again with the scheduled time as the last backup time, so velero doesn't run this backup.
+// Returns the time to wait before the next check
+func (r *BackupScheduleReconciler) skipUnchangedBackups(
+	ctx context.Context,
+	backupSchedule *v1beta1.BackupSchedule,
+	veleroSchedules []veleroapi.Schedule,
+) time.Duration {
+
+	reconcileInterval := r.getReconcileInterval()
+	if !backupSchedule.Spec.SkipUnchangedBackups ||
+		backupSchedule.Status.Phase != v1beta1.SchedulePhaseEnabled {
+		return reconcileInterval
+	}
+	// the velero schedules using a veleroScheduleOverrides cron are not skipped
+	schedules := []veleroapi.Schedule{}
+	for i := range veleroSchedules {
+		backupType := ResourceType(veleroSchedules[i].GetLabels()[BackupScheduleTypeLabel])
+		if _, ok := backupSchedule.Spec.VeleroScheduleOverrides[string(
+			getOverrideBackupType(backupType))]; !ok {
+			schedules = append(schedules, veleroSchedules[i])
+		}
+	}
+	veleroSchedules = schedules
+	// the velero schedules use the veleroSchedule cron shifted by the scheduleJitter offset
+	clusterID := ""
+	if len(veleroSchedules) > 0 {
+		clusterID = veleroSchedules[0].GetLabels()[BackupScheduleClusterLabel]
+	}
+	cronSchedule, err := cron.ParseStandard(getJitteredVeleroSchedule(backupSchedule, clusterID))
+	if err != nil {
+		return reconcileInterval
+	}
+	scheduledTime := getNextScheduledBackupTime(veleroSchedules, cronSchedule)
+	if scheduledTime.IsZero() {
+		return skipUnchangedBackupsWindow
+	}
+	if wait := time.Until(scheduledTime); wait > skipUnchangedBackupsWindow {
+		// check again shortly before the backup is due
+		if wait-skipUnchangedBackupsWindow/2 < reconcileInterval {
+			return wait - skipUnchangedBackupsWindow/2
+		}
+		return reconcileInterval
+	}
+	lastFingerprint := backupSchedule.Status.LastBackupFingerprint
+	if lastFingerprint != nil && lastFingerprint.ScheduledTime.Time.Equal(scheduledTime) {
+		// the resources changed, wait for velero to run this backup
+		return skipUnchangedBackupsWindow
+	}
+
+	scheduleLogger := log.FromContext(ctx)
+	fingerprint, err := r.getBackedUpResourcesFingerprint(ctx, veleroSchedules)
+	if err != nil {
+		scheduleLogger.Error(err, "Failed to get the backed up resources fingerprint, "+
+			"the scheduled backup is not skipped")
+		return skipUnchangedBackupsWindow
+	}
+	if lastFingerprint == nil || lastFingerprint.Fingerprint != fingerprint ||
+		!hasRecentBackupSet(backupSchedule, clusterID, time.Now()) {
+		// the resources changed, or the last backup set could expire before the next one
+		backupSchedule.Status.LastBackupFingerprint = &v1beta1.BackupFingerprint{
+			Fingerprint:   fingerprint,
+			ScheduledTime: v1.NewTime(scheduledTime),
+		}
+		return skipUnchangedBackupsWindow
+	}
+
+	// the resources didn't change, velero computes the next run time from the last backup time
+	skipped := []string{}
+	for i := range veleroSchedules {
+		veleroSchedule := &veleroSchedules[i]
+		if veleroSchedule.Name == veleroScheduleNames[ValidationSchedule] {
+			continue
+		}
+		if err := r.skipScheduledBackup(ctx, veleroSchedule, scheduledTime); err != nil {
+			scheduleLogger.Error(err, "Failed to skip the scheduled backup", "name", veleroSchedule.Name)
+			continue
+		}
+		skipped = append(skipped, veleroSchedule.Name)
+	}
+	if len(skipped) > 0 {
+		scheduleLogger.Info("Scheduled backups skipped, the backed up resources didn't change",
+			"scheduledTime", scheduledTime, "fingerprint", fingerprint)
+		r.Recorder.Event(backupSchedule, corev1.EventTypeNormal, "SkippedUnchanged",
+			fmt.Sprintf("Backups scheduled at %s skipped for %s, the backed up resources didn't change",
+				scheduledTime.UTC().Format(time.RFC3339), strings.Join(skipped, ", ")))
+	}
+	return skipUnchangedBackupsWindow
+}
+
+// skip the next backup of the velero schedule: the velero schedule is deleted and created again,
+// as when the backup schedule is resumed, with the scheduled time as the last backup time so velero
+// waits for the following run time; the status of the existing velero schedule is not updated.
+// If the velero schedule can't be created, it is created again by the next reconcile
+// and the backup is not skipped
+func (r *BackupScheduleReconciler) skipScheduledBackup(
+	ctx context.Context,
+	veleroSchedule *veleroapi.Schedule,
+	scheduledTime time.Time,
+) error {
+
+	skippedSchedule := getVeleroScheduleToApply(veleroSchedule)
+	lastBackup := v1.NewTime(scheduledTime)
+	skippedSchedule.Status.LastBackup = &lastBackup
+
+	if err := r.Delete(ctx, veleroSchedule); err != nil && !k8serr.IsNotFound(err) {
+		return err
+	}
+	return applyVeleroResource(ctx, r.Client, skippedSchedule)
+}
+
+// finished backups for which an event was recorded, with the backup schedule name and phase
+type recordedBackupEvent struct {
+	scheduleName string
+	phase        veleroapi.BackupPhase
+}
+
+var (
+	recordedBackupEvents     = map[types.UID]recordedBackupEvent{}
+	recordedBackupEventsLock sync.Mutex
+)
+
+// record an event on the backup schedule when a backup created by this schedule
+// is completed or failed; an event is recorded once for each backup phase
+func recordBackupEvents(
+	recorder record.EventRecorder,
+	backupSchedule *v1beta1.BackupSchedule,
+	backups []veleroapi.Backup,
+) {
+
+	recordedBackupEventsLock.Lock()
+	defer recordedBackupEventsLock.Unlock()
+
+	listed := map[types.UID]bool{}
+	for i := range backups {
+		backup := &backups[i]
+		listed[backup.UID] = true
+
+		var eventType, reason string
+		switch backup.Status.Phase {
+		case veleroapi.BackupPhaseCompleted:
+			eventType = corev1.EventTypeNormal
+			reason = "Backup completed:"
+		case veleroapi.BackupPhaseFailed,
+			veleroapi.BackupPhasePartiallyFailed,
+			veleroapi.BackupPhaseFailedValidation:
+			eventType = corev1.EventTypeWarning
+			reason = "Backup failed:"
+		default:
+			// backup not finished yet
+			continue
+		}
+
+		if recorded, ok := recordedBackupEvents[backup.UID]; ok && recorded.phase == backup.Status.Phase {
+			continue
+		}
+		recorder.Event(backupSchedule, eventType, reason,
+			fmt.Sprintf("Backup %s is %s", backup.Name, backup.Status.Phase))
+		recordedBackupEvents[backup.UID] = recordedBackupEvent{
+			scheduleName: backupSchedule.Name,
+			phase:        backup.Status.Phase,
+		}
+	}
+
+	// forget the backups deleted from the cluster
+	for uid, recorded := range recordedBackupEvents {
+		if recorded.scheduleName == backupSchedule.Name && !listed[uid] {
+			delete(recordedBackupEvents, uid)
+		}
+	}
+}
+
+// update the backup metrics, record events and set the last successful and in progress backups
+// for the backups created by the backup schedule; the backups older than the
+// MaxBackups complete backup sets are deleted
+func (r *BackupScheduleReconciler) reportFinishedBackups(
+	ctx context.Context,
+	backupSchedule *v1beta1.BackupSchedule,
+) {
+
+	backups := veleroapi.BackupList{}
+	if err := r.List(ctx, &backups, client.InNamespace(backupSchedule.Namespace),
+		client.MatchingLabels{BackupScheduleNameLabel: backupSchedule.Name}); err != nil {
+		log.FromContext(ctx).Info("Failed to list backups created by the schedule", "error", err.Error())
+		return
+	}
+	recordBackupMetrics(backupSchedule.Name, backups.Items)
+	recordBackupEvents(r.Recorder, backupSchedule, backups.Items)
+	previousBackups := backupSchedule.Status.LastSuccessfulBackups
+	backupSchedule.Status.LastSuccessfulBackups = getLastSuccessfulBackups(backups.Items)
+	r.setBackedUpResourceKinds(ctx, backupSchedule, previousBackups, backups.Items)
+	backupSchedule.Status.InProgressBackups = getInProgressBackupsStatus(backups.Items)
+	setBackupTypeConditions(backupSchedule, backups.Items)
+	setBackupChainHealthyCondition(backupSchedule, backups.Items, time.Now())
+	backupSchedule.Status.BackupFailures = getBackupFailures(backups.Items)
+	setDegradedCondition(backupSchedule, backupSchedule.Status.BackupFailures)
+	r.checkBackupTimeout(backupSchedule, backups.Items)
+	r.annotateBackupClusterDiff(ctx, backupSchedule, backups.Items)
+	r.pruneBackups(ctx, backupSchedule, backups.Items)
+	r.setStorageLocationStats(ctx, backupSchedule)
+}
+
+// sets the StorageLocationStats status for the valid storage locations in the
+// backup schedule namespace, counting all the backups stored in these locations
+func (r *BackupScheduleReconciler) setStorageLocationStats(
+	ctx context.Context,
+	backupSchedule *v1beta1.BackupSchedule,
+) {
+
+	logger := log.FromContext(ctx)
+	veleroStorageLocations := veleroapi.BackupStorageLocationList{}
+	if err := r.List(ctx, &veleroStorageLocations,
+		client.InNamespace(backupSchedule.Namespace)); err != nil {
+		logger.Info("Failed to list storage locations", "error", err.Error())
+		return
+	}
+	storageLocations := getStorageLocationsInNamespace(getValidStorageLocations(ctx,
+		veleroStorageLocations, r.AcceptUnownedStorageLocations), backupSchedule.Namespace)
+
+	// the backups synced from the storage locations are counted too,
+	// they can be created by other hubs
+	backups := veleroapi.BackupList{}
+	if err := r.List(ctx, &backups, client.InNamespace(backupSchedule.Namespace)); err != nil {
+		logger.Info("Failed to list backups", "error", err.Error())
+		return
+	}
+	backupSchedule.Status.StorageLocationStats = getStorageLocationStats(storageLocations, backups.Items)
+}
+
+// returns the number of backups and the sum of their backed up items for each storage
+// location, sorted by name; a backup is stored in the storage location set on the backup,
+// or in the one set by the velero storage location label
+func getStorageLocationStats(
+	storageLocations []storageLocationRef,
+	backups []veleroapi.Backup,
+) []v1beta1.StorageLocationStats {
+
+	if len(storageLocations) == 0 {
+		return nil
+	}
+	stats := make([]v1beta1.StorageLocationStats, 0, len(storageLocations))
+	index := map[string]int{}
+	for i := range storageLocations {
+		if _, found := index[storageLocations[i].Name]; found {
+			continue
+		}
+		index[storageLocations[i].Name] = len(stats)
+		stats = append(stats, v1beta1.StorageLocationStats{Name: storageLocations[i].Name})
+	}
+	for i := range backups {
+		storageLocation := backups[i].Spec.StorageLocation
+		if storageLocation == "" {
+			storageLocation = backups[i].GetLabels()[veleroapi.StorageLocationLabel]
+		}
+		j, found := index[storageLocation]
+		if !found {
+			continue
+		}
+		stats[j].Backups++
+		if backups[i].Status.Progress != nil {
+			stats[j].ItemsBackedUp += backups[i].Status.Progress.ItemsBackedUp
+		}
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Name < stats[j].Name
+	})
+	return stats
+}
+
+// sets the BackupClusterDiffAnnotation on the last successful backup of each type, once
+// for each backup: the backup is not compared again if the annotation is set or if the
+// comparison failed, see BackupClusterDiffErrorAnnotation. The validation backups are not compared
+func (r *BackupScheduleReconciler) annotateBackupClusterDiff(
+	ctx context.Context,
+	backupSchedule *v1beta1.BackupSchedule,
+	backups []veleroapi.Backup,
+) {
+
+	logger := log.FromContext(ctx)
+	for _, lastBackup := range backupSchedule.Status.LastSuccessfulBackups {
+		if lastBackup.BackupType == ValidationSchedule {
+			continue
+		}
+		for i := range backups {
+			backup := &backups[i]
+			if backup.Name != lastBackup.LastBackupName {
+				continue
+			}
+			if backup.Status.Phase != veleroapi.BackupPhaseCompleted || isBackupClusterDiffSet(backup) {
+				break
+			}
+			annotationKey := BackupClusterDiffAnnotation
+			annotationValue := ""
+			diffs, err := CompareBackupToCluster(ctx, r.DiscoveryClient, r.DynamicClient, backup)
+			if err == nil {
+				var value []byte
+				value, err = json.Marshal(diffs)
+				annotationValue = string(value)
+			}
+			if err != nil {
+				logger.Info("Failed to compare the backup to the hub resources",
+					"backup", backup.Name, "error", err.Error())
+				annotationKey = BackupClusterDiffErrorAnnotation
+				annotationValue = err.Error()
+			}
+			patch := client.MergeFrom(backup.DeepCopy())
+			annotations := backup.GetAnnotations()
+			if annotations == nil {
+				annotations = map[string]string{}
+			}
+			annotations[annotationKey] = annotationValue
+			backup.SetAnnotations(annotations)
+			if err := r.Patch(ctx, backup, patch); err != nil {
+				logger.Info("Failed to annotate the backup",
+					"backup", backup.Name, "error", err.Error())
+			}
+			break
+		}
+	}
+}
+
+// set the backup sets available in the storage location which can be restored
+func (r *BackupScheduleReconciler) setRestorableBackupSets(
+	ctx context.Context,
+	backupSchedule *v1beta1.BackupSchedule,
+) {
+
+	backups := veleroapi.BackupList{}
+	if err := r.List(ctx, &backups, client.InNamespace(backupSchedule.Namespace)); err != nil {
+		log.FromContext(ctx).Info("Failed to list backups", "error", err.Error())
+		return
+	}
+	backupSchedule.Status.RestorableBackups = getRestorableBackupSets(backups.Items)
+}
+
+// returns the number of hub secrets and configmaps matching the credentials backup label selector
+// or -1 if the schedule is not backing up credentials
+func (r *BackupScheduleReconciler) countResourcesMatchingBackup(
+	ctx context.Context,
+	veleroSchedule *veleroapi.Schedule,
+) int {
+
+	template := veleroSchedule.Spec.Template
+	if len(template.IncludedResources) == 0 || template.LabelSelector == nil {
+		return -1
+	}
+	for _, resource := range template.IncludedResources {
+		if !findValue(backupCredsResources, resource) {
+			return -1
+		}
+	}
+	selector, err := v1.LabelSelectorAsSelector(template.LabelSelector)
+	if err != nil {
+		return -1
+	}
+
+	count := 0
+	if findValue(template.IncludedResources, "secret") {
+		secrets := &corev1.SecretList{}
+		if err := r.List(ctx, secrets, &client.ListOptions{LabelSelector: selector}); err != nil {
+			return -1
+		}
+		count = count + len(secrets.Items)
+	}
+	if findValue(template.IncludedResources, "configmap") {
+		configMaps := &corev1.ConfigMapList{}
+		if err := r.List(ctx, configMaps, &client.ListOptions{LabelSelector: selector}); err != nil {
+			return -1
+		}
+		count = count + len(configMaps.Items)
+	}
+	return count
+}
+
+func isScheduleSpecUpdated(
+	schedules *veleroapi.ScheduleList,
+	backupSchedule *v1beta1.BackupSchedule,
+) bool {
+
+	if schedules == nil || len(schedules.Items) <= 0 {
+		return false
+	}
+
+	for i := range schedules.Items {
+		veleroSchedule := &schedules.Items[i]
+
+		if veleroSchedule.Name != veleroScheduleNames[ValidationSchedule] &&
+			veleroSchedule.Spec.Template.TTL.Duration != backupSchedule.Spec.VeleroTTL.Duration {
+			// validation backup TTL should be ignored here
+			// since that one is using the schedule's cron job interval
+			return true
+		}
+		if veleroSchedule.Spec.Schedule != getBackupTypeCronSchedule(backupSchedule,
+			ResourceType(veleroSchedule.GetLabels()[BackupScheduleTypeLabel]),
+			veleroSchedule.GetLabels()[BackupScheduleClusterLabel]) {
+			return true
+		}
+		if veleroSchedule.Name == veleroScheduleNames[ResourcesGeneric] &&
+			areTransientResourcesExcluded(veleroSchedule) == backupSchedule.Spec.BackupTransientResources {
+			// the transient resources exclusion doesn't match the backup schedule setting
+			return true
+		}
+		if veleroSchedule.Name == veleroScheduleNames[ResourcesGeneric] &&
+			isExcludedNamespacesUpdated(veleroSchedule, backupSchedule) {
+			return true
+		}
+		if veleroSchedule.Name == veleroScheduleNames[ResourcesGeneric] &&
+			v1.FormatLabelSelector(veleroSchedule.Spec.Template.LabelSelector) !=
+				v1.FormatLabelSelector(getGenericResourcesBackupLabelSelector(backupSchedule)) {
+			// the generic resources label selector doesn't match the backup schedule setting
+			return true
+		}
+		if veleroSchedule.Name == veleroScheduleNames[Resources] &&
+			!equality.Semantic.DeepEqual(veleroSchedule.Spec.Template.Hooks,
+				getBackupHooks(backupSchedule, Resources)) {
+			// the resources backup hooks don't match the backup schedule hooks
+			return true
+		}
+		if isSnapshotVolumesUpdated(veleroSchedule, backupSchedule) {
+			return true
+		}
+		if isBackupMetadataUpdated(veleroSchedule.GetLabels(), backupSchedule.Spec.BackupLabels) ||
+			isBackupMetadataUpdated(veleroSchedule.GetAnnotations(), backupSchedule.Spec.BackupAnnotations) {
+			// the user defined labels or annotations don't match the backup schedule setting
+			return true
+		}
+	}
+
+	return false
+}
+
+// returns the most recent backup created by another hub after the from time, or nil;
+// a backup restored on this hub, or older than a backup restored on this hub from the
+// same hub, is ignored: this hub took over the backups of that hub
+func getCollisionWindowBackup(
+	backups []veleroapi.Backup,
+	restores []veleroapi.Restore,
+	clusterId string,
+	from time.Time,
+) *veleroapi.Backup {
+
+	restoredBackups := map[string]bool{}
+	for i := range restores {
+		restoredBackups[restores[i].Spec.BackupName] = true
+	}
+	isForeign := func(backup *veleroapi.Backup) bool {
+		hubID := backup.GetLabels()[BackupScheduleClusterLabel]
+		return hubID != "" && hubID != unknownHubID && hubID != clusterId
+	}
+
+	// the last backup restored on this hub, for each hub
+	lastRestored := map[string]time.Time{}
+	for i := range backups {
+		backup := &backups[i]
+		if !isForeign(backup) || !restoredBackups[backup.Name] {
+			continue
+		}
+		hubID := backup.GetLabels()[BackupScheduleClusterLabel]
+		if timestamp := getBackupTime(backup); timestamp.After(lastRestored[hubID]) {
+			lastRestored[hubID] = timestamp
+		}
+	}
+
+	var collision *veleroapi.Backup
+	for i := range backups {
+		backup := &backups[i]
+		if !isForeign(backup) {
+			continue
+		}
+		timestamp := getBackupTime(backup)
+		if !timestamp.After(from) ||
+			!timestamp.After(lastRestored[backup.GetLabels()[BackupScheduleClusterLabel]]) {
+			continue
+		}
+		if collision == nil || timestamp.After(getBackupTime(collision)) {
+			collision = backup
+		}
+	}
+	return collision
+}
+
+// returns the backup collision message if another hub created backups within
+// the collision prevention window, or an empty string
+func (r *BackupScheduleReconciler) getCollisionWindowMessage(
+	ctx context.Context,
+	backupSchedule *v1beta1.BackupSchedule,
+	clusterId string,
+) string {
+
+	window := backupSchedule.Spec.CollisionPreventionWindow.Duration
+	if window <= 0 || clusterId == unknownHubID {
+		return ""
+	}
+
+	logger := log.FromContext(ctx)
+	backups := veleroapi.BackupList{}
+	if err := r.List(ctx, &backups, client.InNamespace(backupSchedule.Namespace)); err != nil {
+		logger.Info("Failed to list backups", "error", err.Error())
+		return ""
+	}
+	restores := veleroapi.RestoreList{}
+	if err := r.List(ctx, &restores, client.InNamespace(backupSchedule.Namespace)); err != nil {
+		logger.Info("Failed to list restores", "error", err.Error())
+		return ""
+	}
+
+	collision := getCollisionWindowBackup(backups.Items, restores.Items, clusterId,
+		time.Now().Add(-window))
+	if collision == nil {
+		return ""
+	}
+	timestamp, err := getBackupTimestamp(collision.Name)
+	if err != nil {
+		timestamp = collision.CreationTimestamp.Time
+	}
+	return fmt.Sprintf(BackupCollisionWindowMsg,
+		collision.Name,
+		collision.GetLabels()[BackupScheduleClusterLabel],
+		timestamp.UTC().Format(time.RFC3339),
+		window,
+		clusterId,
+	)
+}
+
+// returns the velero schedules backing up the contents of the namespaces, for the default
+// storage location and for each additional storage location; the schedules use the resources
+// cron schedule, so the namespace contents backups are restored with the resources backup
+// created at the same time
+func getNamespaceContentsSchedules(
+	backupSchedule *v1beta1.BackupSchedule,
+	clusterId string,
+	namespaces []string,
+	storageLocations []storageLocationRef,
+) []*veleroapi.Schedule {
+
+	labels := mergeBackupMetadata(map[string]string{
+		BackupScheduleNameLabel:    backupSchedule.Name,
+		BackupScheduleTypeLabel:    string(NamespaceContents),
+		BackupScheduleClusterLabel: clusterId,
+	}, backupSchedule.Spec.BackupLabels)
+	annotations := mergeBackupMetadata(nil, backupSchedule.Spec.BackupAnnotations)
+
+	veleroSchedule := &veleroapi.Schedule{}
+	veleroSchedule.Name = namespaceContentsScheduleName
+	veleroSchedule.Namespace = backupSchedule.Namespace
+	veleroSchedule.SetLabels(labels)
+	veleroSchedule.SetAnnotations(annotations)
+	setNamespaceContentsBackupInfo(&veleroSchedule.Spec.Template, namespaces)
+	veleroSchedule.Spec.Template.SnapshotVolumes = getSnapshotVolumes(backupSchedule, NamespaceContents)
+	veleroSchedule.Spec.Template.TTL = backupSchedule.Spec.VeleroTTL
+	veleroSchedule.Spec.Schedule = getBackupTypeCronSchedule(backupSchedule, Resources, clusterId)
+
+	veleroSchedules := []*veleroapi.Schedule{veleroSchedule}
+	for _, storageLocation := range storageLocations {
+		locationSchedule := &veleroapi.Schedule{}
+		locationSchedule.Name = getStorageLocationScheduleName(veleroSchedule.Name,
+			storageLocation.Name)
+		locationSchedule.Namespace = veleroSchedule.Namespace
+		locationSchedule.SetLabels(labels)
+		locationSchedule.SetAnnotations(annotations)
+		locationSchedule.Spec = *veleroSchedule.Spec.DeepCopy()
+		locationSchedule.Spec.Template.StorageLocation = storageLocation.Name
+		veleroSchedules = append(veleroSchedules, locationSchedule)
+	}
+	return veleroSchedules
+}
+
+// returns the velero schedules backing up the namespace contents, and the other velero schedules
+func splitNamespaceContentsSchedules(
+	schedules []veleroapi.Schedule,
+) ([]veleroapi.Schedule, []veleroapi.Schedule) {
+
+	namespaceContents := []veleroapi.Schedule{}
+	others := []veleroapi.Schedule{}
+	for i := range schedules {
+		if schedules[i].GetLabels()[BackupScheduleTypeLabel] == string(NamespaceContents) {
+			namespaceContents = append(namespaceContents, schedules[i])
+		} else {
+			others = append(others, schedules[i])
+		}
+	}
+	return namespaceContents, others
+}
+
+// returns the name of the velero schedule writing backups to the storage location
+func getStorageLocationScheduleName(scheduleName string, storageLocation string) string {
+	return scheduleName + "-" + storageLocation
+}
+
+// returns a hash of the velero schedule fields set by the operator: the spec, labels,
+// annotations and owner; the json encoding sorts the map keys, so the hash is the same
+// for the same schedule
+func getVeleroScheduleSpecHash(veleroSchedule *veleroapi.Schedule) (string, error) {
+
+	annotations := map[string]string{}
+	for key, value := range veleroSchedule.Annotations {
+		if key != VeleroScheduleSpecHashAnnotation {
+			annotations[key] = value
+		}
+	}
+	content, err := json.Marshal(struct {
+		Spec            veleroapi.ScheduleSpec `json:"spec"`
+		Labels          map[string]string      `json:"labels,omitempty"`
+		Annotations     map[string]string      `json:"annotations,omitempty"`
+		OwnerReferences []v1.OwnerReference    `json:"ownerReferences,omitempty"`
+	}{
+		Spec:            veleroSchedule.Spec,
+		Labels:          veleroSchedule.Labels,
+		Annotations:     annotations,
+		OwnerReferences: veleroSchedule.OwnerReferences,
+	})
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256(content)
+	return hex.EncodeToString(hash[:]), nil
+}
+
+// the label and annotation prefixes used by the operator and by Velero
+var reservedBackupMetadataPrefixes = []string{
+	"cluster.open-cluster-management.io/",
+	"velero.io/",
+}
+
+// returns the labels or annotations set by the operator, with the user defined ones added;
+// the keys set by the operator or using a reserved prefix are not overwritten
+func mergeBackupMetadata(
+	operatorMetadata map[string]string,
+	userMetadata map[string]string,
+) map[string]string {
+
+	metadata := make(map[string]string, len(operatorMetadata)+len(userMetadata))
+	for key, value := range operatorMetadata {
+		metadata[key] = value
+	}
+	for key, value := range userMetadata {
+		if _, ok := metadata[key]; ok {
+			continue
+		}
+		reserved := false
+		for _, prefix := range reservedBackupMetadataPrefixes {
+			if strings.HasPrefix(key, prefix) {
+				reserved = true
+				break
+			}
+		}
+		if !reserved {
+			metadata[key] = value
+		}
+	}
+	return metadata
+}
+
+// returns true if the labels or annotations of the velero schedule, except the ones using
+// a reserved prefix, don't match the user defined ones
+func isBackupMetadataUpdated(
+	scheduleMetadata map[string]string,
+	userMetadata map[string]string,
+) bool {
+
+	operatorMetadata := map[string]string{}
+	for key, value := range scheduleMetadata {
+		for _, prefix := range reservedBackupMetadataPrefixes {
+			if strings.HasPrefix(key, prefix) {
+				operatorMetadata[key] = value
+				break
+			}
+		}
+	}
+	metadata := mergeBackupMetadata(operatorMetadata, userMetadata)
+	if len(metadata) != len(scheduleMetadata) {
+		return true
+	}
+	for key, value := range metadata {
+		if scheduleValue, ok := scheduleMetadata[key]; !ok || scheduleValue != value {
+			return true
+		}
+	}
+	return false
+}
+
+// returns the annotations of the velero schedule to set on its backups, except the spec hash;
+// Velero copies only the schedule labels to the backups
+func getScheduleBackupAnnotations(veleroSchedule *veleroapi.Schedule) map[string]string {
+
+	annotations := map[string]string{}
+	for key, value := range veleroSchedule.GetAnnotations() {
+		if key != VeleroScheduleSpecHashAnnotation {
+			annotations[key] = value
+		}
+	}
+	return annotations
+}
+
+// Velero 1.7 copies only the schedule labels to the backups created by the schedule;
+// add the annotations of the velero schedules to their backups, if not set yet
+func (r *BackupScheduleReconciler) annotateScheduledBackups(
+	ctx context.Context,
+	veleroSchedules []veleroapi.Schedule,
+) {
+
+	logger := log.FromContext(ctx)
+	for i := range veleroSchedules {
+		annotations := getScheduleBackupAnnotations(&veleroSchedules[i])
+		if len(annotations) == 0 {
+			continue
+		}
+		backups := veleroapi.BackupList{}
+		if err := r.List(ctx, &backups, client.InNamespace(veleroSchedules[i].Namespace),
+			client.MatchingLabels{"velero.io/schedule-name": veleroSchedules[i].Name}); err != nil {
+			logger.Info("Failed to list backups", "error", err.Error())
+			return
+		}
+		for j := range backups.Items {
+			backup := &backups.Items[j]
+			backupAnnotations := backup.GetAnnotations()
+			if backupAnnotations == nil {
+				backupAnnotations = map[string]string{}
+			}
+			patch := client.MergeFrom(backup.DeepCopy())
+			updated := false
+			for key, value := range annotations {
+				if _, ok := backupAnnotations[key]; !ok {
+					backupAnnotations[key] = value
+					updated = true
+				}
+			}
+			if !updated {
+				continue
+			}
+			backup.SetAnnotations(backupAnnotations)
+			if err := r.Patch(ctx, backup, patch); err != nil {
+				logger.Info("Failed to annotate the backup",
+					"backup", backup.Name, "error", err.Error())
+			}
+		}
+	}
+}
+
+// returns the names of the velero schedules created by a backup schedule, one for each
+// backup type and, except for the validation schedule, for each additional storage location
+func getVeleroScheduleNames(storageLocations []storageLocationRef) []string {
+
+	scheduleNames := []string{}
+	for scheduleKey, scheduleName := range veleroScheduleNames {
+		scheduleNames = append(scheduleNames, scheduleName)
+		if scheduleKey == ValidationSchedule {
+			continue
+		}
+		for i := range storageLocations {
+			scheduleNames = append(scheduleNames,
+				getStorageLocationScheduleName(scheduleName, storageLocations[i].Name))
+		}
+	}
+	sort.Strings(scheduleNames)
+	return scheduleNames
+}
+
+// returns the velero schedules controlled by the backup schedule which are not created by it anymore,
+// for example the schedules of a removed backup type or writing backups to a removed storage location;
+// the velero schedules controlled by another backup schedule, even with the same name, are not returned
+func getOrphanedVeleroSchedules(
+	schedules []veleroapi.Schedule,
+	backupSchedule *v1beta1.BackupSchedule,
+	storageLocations []storageLocationRef,
+) []veleroapi.Schedule {
+
+	scheduleNames := getVeleroScheduleNames(storageLocations)
+	orphaned := []veleroapi.Schedule{}
+	for i := range schedules {
+		owner := v1.GetControllerOf(&schedules[i])
+		if owner == nil || owner.UID != backupSchedule.UID ||
+			findValue(scheduleNames, schedules[i].Name) {
+			continue
+		}
+		orphaned = append(orphaned, schedules[i])
+	}
+	return orphaned
+}
+
+// returns the valid storage locations in the namespace, other than the default storage location;
+// backups are written to these storage locations by a separate set of velero schedules
+func (r *BackupScheduleReconciler) getAdditionalStorageLocations(
+	ctx context.Context,
+	namespace string,
+) []storageLocationRef {
+
+	veleroStorageLocations := veleroapi.BackupStorageLocationList{}
+	if err := r.List(ctx, &veleroStorageLocations, client.InNamespace(namespace)); err != nil {
+		log.FromContext(ctx).Info("Failed to list storage locations", "error", err.Error())
+		return nil
+	}
+	return getAdditionalStorageLocations(getWritableStorageLocations(getStorageLocationsInNamespace(
+		getValidStorageLocations(ctx, veleroStorageLocations, r.AcceptUnownedStorageLocations),
+		namespace)))
+}
+
+// returns the additional storage locations, and the storage locations of the velero schedules
+// which still exist in the namespace but are not valid anymore, for example briefly Unavailable;
+// the velero schedules writing backups to these storage locations are not deleted.
+// Returns an error if the storage locations can't be listed
+func (r *BackupScheduleReconciler) getScheduledStorageLocations(
+	ctx context.Context,
+	namespace string,
+	schedules []veleroapi.Schedule,
+	storageLocations []storageLocationRef,
+) ([]storageLocationRef, error) {
+
+	veleroStorageLocations := veleroapi.BackupStorageLocationList{}
+	if err := r.List(ctx, &veleroStorageLocations, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+	return appendScheduledStorageLocations(storageLocations, schedules, veleroStorageLocations), nil
+}
+
+// returns the storage locations with the storage locations of the velero schedules
+// found in the storage locations list
+func appendScheduledStorageLocations(
+	storageLocations []storageLocationRef,
+	schedules []veleroapi.Schedule,
+	veleroStorageLocations veleroapi.BackupStorageLocationList,
+) []storageLocationRef {
+
+	scheduledLocations := append([]storageLocationRef{}, storageLocations...)
+	for i := range schedules {
+		location := schedules[i].Spec.Template.StorageLocation
+		if location == "" {
+			continue
+		}
+		found := false
+		for j := range scheduledLocations {
+			if scheduledLocations[j].Name == location {
+				found = true
+				break
+			}
+		}
+		if found {
+			continue
+		}
+		for j := range veleroStorageLocations.Items {
+			if veleroStorageLocations.Items[j].Name == location {
+				scheduledLocations = append(scheduledLocations, storageLocationRef{
+					Name:       location,
+					Namespace:  veleroStorageLocations.Items[j].Namespace,
+					AccessMode: veleroStorageLocations.Items[j].Spec.AccessMode,
+				})
+				break
+			}
+		}
+	}
+	return scheduledLocations
+}
+
+// returns true if a valid storage location exists in the namespace
+func (r *BackupScheduleReconciler) hasValidStorageLocation(
+	ctx context.Context,
+	namespace string,
+) bool {
+
+	veleroStorageLocations := veleroapi.BackupStorageLocationList{}
+	if err := r.List(ctx, &veleroStorageLocations, client.InNamespace(namespace)); err != nil {
+		log.FromContext(ctx).Info("Failed to list storage locations", "error", err.Error())
+		return false
+	}
+	return len(getStorageLocationsInNamespace(getValidStorageLocations(ctx,
+		veleroStorageLocations, r.AcceptUnownedStorageLocations), namespace)) > 0
+}
+
+// returns a message if backups can't be created because the storage locations are read-only
+func getReadOnlyStorageLocationsMessage(storageLocations []storageLocationRef) string {
+
+	if len(getWritableStorageLocations(storageLocations)) == 0 {
+		return "All available backup storage locations are read-only. " +
+			"Set the velero.io.BackupStorageLocation accessMode to ReadWrite to create backups."
+	}
+	for i := range storageLocations {
+		if storageLocations[i].Default &&
+			storageLocations[i].AccessMode == veleroapi.BackupStorageLocationAccessModeReadOnly {
+			return fmt.Sprintf("The default backup storage location %s is read-only. "+
+				"Set the velero.io.BackupStorageLocation accessMode to ReadWrite to create backups.",
+				storageLocations[i].Name)
+		}
+	}
+	return ""
+}
+
+// returns the storage locations other than the default storage location,
+// or none if the default storage location is not one of them
+func getAdditionalStorageLocations(storageLocations []storageLocationRef) []storageLocationRef {
+
+	additionalStorageLocations := []storageLocationRef{}
+	hasDefault := false
+	for i := range storageLocations {
+		if storageLocations[i].Default {
+			hasDefault = true
+			continue
+		}
+		additionalStorageLocations = append(additionalStorageLocations, storageLocations[i])
+	}
+	if !hasDefault {
+		// the velero schedules use the default storage location
+		return []storageLocationRef{}
+	}
+	return additionalStorageLocations
+}
+
+// returns true if the velero schedules don't write backups
+// to the same additional storage locations
+func isStorageLocationsUpdated(
+	schedules *veleroapi.ScheduleList,
+	storageLocations []storageLocationRef,
+) bool {
+
+	if schedules == nil || len(schedules.Items) <= 0 {
+		return false
+	}
+
+	scheduleLocations := []string{}
+	for i := range schedules.Items {
+		if location := schedules.Items[i].Spec.Template.StorageLocation; location != "" {
+			scheduleLocations = appendUnique(scheduleLocations, location)
+		}
+	}
+	if len(scheduleLocations) != len(storageLocations) {
+		return true
+	}
+	for i := range storageLocations {
+		if !findValue(scheduleLocations, storageLocations[i].Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// returns true if the velero schedule excludes all the transient resources
+func areTransientResourcesExcluded(veleroSchedule *veleroapi.Schedule) bool {
+
+	for i := range transientResources {
+		if !findValueCaseInsensitive(veleroSchedule.Spec.Template.ExcludedResources, transientResources[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// returns the backup hooks for the velero backups of this type;
+// the backup schedule hooks are set only on the resources backup
+func getBackupHooks(backupSchedule *v1beta1.BackupSchedule, scheduleKey ResourceType) veleroapi.BackupHooks {
+
+	if scheduleKey != Resources {
+		return veleroapi.BackupHooks{}
+	}
+	return *backupSchedule.Spec.Hooks.DeepCopy()
+}
+
+// validate the backup hooks namespaces, label selectors and exec commands, if any
+func validateBackupHooks(backupSchedule *v1beta1.BackupSchedule) []string {
+
+	validationErrors := []string{}
+	for i, hook := range backupSchedule.Spec.Hooks.Resources {
+		if hook.Name == "" {
+			validationErrors = append(validationErrors, fmt.Sprintf(
+				"hooks resources[%d] name must be set", i))
+		}
+		for _, namespace := range append(append([]string{}, hook.IncludedNamespaces...),
+			hook.ExcludedNamespaces...) {
+			if namespace == "*" {
+				continue
+			}
+			for _, msg := range validation.IsDNS1123Label(namespace) {
+				validationErrors = append(validationErrors, fmt.Sprintf(
+					"invalid hook %s namespace %q: %s", hook.Name, namespace, msg))
+			}
+		}
+		if hook.LabelSelector != nil {
+			if _, err := v1.LabelSelectorAsSelector(hook.LabelSelector); err != nil {
+				validationErrors = append(validationErrors, fmt.Sprintf(
+					"invalid hook %s labelSelector: %v", hook.Name, err))
+			}
+		}
+		for _, execHook := range append(append([]veleroapi.BackupResourceHook{}, hook.PreHooks...),
+			hook.PostHooks...) {
+			if execHook.Exec == nil || len(execHook.Exec.Command) == 0 {
+				validationErrors = append(validationErrors, fmt.Sprintf(
+					"hook %s must set an exec command", hook.Name))
+				continue
+			}
+			switch execHook.Exec.OnError {
+			case "", veleroapi.HookErrorModeContinue, veleroapi.HookErrorModeFail:
+			default:
+				validationErrors = append(validationErrors, fmt.Sprintf(
+					"hook %s onError must be %s or %s, found %s", hook.Name,
+					veleroapi.HookErrorModeContinue, veleroapi.HookErrorModeFail, execHook.Exec.OnError))
+			}
+		}
+	}
+	return validationErrors
+}
+
+// returns the snapshotVolumes value for the velero backups of this type;
+// the credentials and resources backups are resource-only and don't snapshot volumes,
+// unless snapshotVolumes is set on the backup schedule
+func getSnapshotVolumes(backupSchedule *v1beta1.BackupSchedule, scheduleKey ResourceType) *bool {
+
+	if backupSchedule.Spec.SnapshotVolumes != nil {
+		snapshotVolumes := *backupSchedule.Spec.SnapshotVolumes
+		return &snapshotVolumes
+	}
+	switch scheduleKey {
+	case Credentials, CredentialsHive, CredentialsCluster, Resources, ResourcesGeneric,
+		NamespaceContents:
+		snapshotVolumes := false
+		return &snapshotVolumes
+	}
+	// use the velero default
+	return nil
+}
+
+// returns true if the velero schedule snapshotVolumes value
+// doesn't match the backup schedule setting
+func isSnapshotVolumesUpdated(
+	veleroSchedule *veleroapi.Schedule,
+	backupSchedule *v1beta1.BackupSchedule,
+) bool {
+
+	scheduleKey := ResourceType(veleroSchedule.Labels[BackupScheduleTypeLabel])
+	expected := getSnapshotVolumes(backupSchedule, scheduleKey)
+	current := veleroSchedule.Spec.Template.SnapshotVolumes
+	if expected == nil || current == nil {
+		return expected != current
+	}
+	return *expected != *current
+}
+
+// returns the longest interval between the next runs of the cron job
+func getCronInterval(cronSchedule cron.Schedule, from time.Time) time.Duration {
+
+	var interval time.Duration
+	// the interval may vary for cron jobs such as 0 1,2 * * *
+	// check enough runs to cover these cases
+	runTime := cronSchedule.Next(from)
+	for i := 0; i < 10; i++ {
+		nextRunTime := cronSchedule.Next(runTime)
+		if nextRunTime.Sub(runTime) > interval {
+			interval = nextRunTime.Sub(runTime)
+		}
+		runTime = nextRunTime
+	}
+	return interval
+}
+
+// returns a warning message if the backups TTL is shorter than the schedule
+// cron job interval or than the interval used by the operator to check the backups
+// backups could expire before a new backup is created, or before the operator acts on them
+func getShortTTLMessage(
+	backupSchedule *v1beta1.BackupSchedule,
+	checkInterval time.Duration,
+) string {
+
+	ttl := backupSchedule.Spec.VeleroTTL.Duration
+	if ttl == 0 {
+		// the velero default TTL is used, 720h
+		return ""
+	}
+
+	// the longest interval of the velero schedules cron expressions
+	crons := map[string]string{"the veleroSchedule": backupSchedule.Spec.VeleroSchedule}
+	for backupType, cronSchedule := range backupSchedule.Spec.VeleroScheduleOverrides {
+		crons["the veleroScheduleOverrides "+backupType] = cronSchedule
+	}
+	sources := make([]string, 0, len(crons))
+	for source := range crons {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+	var longest time.Duration
+	longestSource := ""
+	for _, source := range sources {
+		cronSchedule, err := cron.ParseStandard(crons[source])
+		if err != nil {
+			continue
+		}
+		if interval := getCronInterval(cronSchedule, time.Now()); interval > longest {
+			longest = interval
+			longestSource = source
+		}
+	}
+
+	if ttl < longest {
+		return fmt.Sprintf(ShortTTLMsg, ttl, longestSource+" interval", longest)
+	}
+	if ttl < checkInterval {
+		return fmt.Sprintf(ShortTTLMsg, ttl, "the operator check interval", checkInterval)
+	}
+	return ""
+}
+
+// set the Ready, BackupCollision and Paused conditions for the backup schedule phase
+func setBackupScheduleConditions(backupSchedule *v1beta1.BackupSchedule) {
+
+	readyStatus := v1.ConditionFalse
+	collisionStatus := v1.ConditionFalse
+	collisionReason := v1beta1.BackupScheduleReasonNoCollision
+	var reason string
+	switch backupSchedule.Status.Phase {
+	case v1beta1.SchedulePhaseNew:
+		reason = v1beta1.BackupScheduleReasonNew
+	case v1beta1.SchedulePhaseEnabled:
+		readyStatus = v1.ConditionTrue
+		reason = v1beta1.BackupScheduleReasonEnabled
+	case v1beta1.SchedulePhaseFailedValidation:
+		reason = v1beta1.BackupScheduleReasonFailedValidation
+	case v1beta1.SchedulePhaseFailed:
+		reason = v1beta1.BackupScheduleReasonFailed
+	case v1beta1.SchedulePhaseBackupCollision:
+		reason = v1beta1.BackupScheduleReasonBackupCollision
+		collisionStatus = v1.ConditionTrue
+		collisionReason = v1beta1.BackupScheduleReasonBackupCollision
+	case v1beta1.SchedulePhaseUnknown:
+		readyStatus = v1.ConditionUnknown
+		reason = v1beta1.BackupScheduleReasonUnknown
+	case v1beta1.SchedulePhasePaused:
+		reason = v1beta1.BackupScheduleReasonPaused
+	default:
+		reason = v1beta1.BackupScheduleReasonNotStarted
+	}
+
+	meta.SetStatusCondition(&backupSchedule.Status.Conditions, v1.Condition{
+		Type:               v1beta1.BackupScheduleReady,
+		Status:             readyStatus,
+		Reason:             reason,
+		Message:            backupSchedule.Status.LastMessage,
+		ObservedGeneration: backupSchedule.Generation,
+	})
+	collisionMessage := ""
+	if collisionStatus == v1.ConditionTrue {
+		collisionMessage = backupSchedule.Status.LastMessage
+	}
+	meta.SetStatusCondition(&backupSchedule.Status.Conditions, v1.Condition{
+		Type:               v1beta1.BackupScheduleCollision,
+		Status:             collisionStatus,
+		Reason:             collisionReason,
+		Message:            collisionMessage,
+		ObservedGeneration: backupSchedule.Generation,
+	})
+
+	pausedCondition := v1.Condition{
+		Type:               v1beta1.BackupSchedulePaused,
+		Status:             v1.ConditionFalse,
+		Reason:             v1beta1.BackupScheduleReasonNotPaused,
+		ObservedGeneration: backupSchedule.Generation,
+	}
+	if backupSchedule.Status.Phase == v1beta1.SchedulePhasePaused {
+		pausedCondition.Status = v1.ConditionTrue
+		pausedCondition.Reason = v1beta1.BackupScheduleReasonPaused
+		pausedCondition.Message = backupSchedule.Status.LastMessage
+	}
+	meta.SetStatusCondition(&backupSchedule.Status.Conditions, pausedCondition)
+}
+
+// condition type showing the last finished backup of each backup type
+var backupTypeConditions = map[ResourceType]string{
+	Credentials:        v1beta1.BackupScheduleCredentialsBackup,
+	CredentialsHive:    v1beta1.BackupScheduleCredentialsHiveBackup,
+	CredentialsCluster: v1beta1.BackupScheduleCredentialsClusterBackup,
+	Resources:          v1beta1.BackupScheduleResourcesBackup,
+	ResourcesGeneric:   v1beta1.BackupScheduleResourcesGenericBackup,
+	ManagedClusters:    v1beta1.BackupScheduleManagedClustersBackup,
+	ValidationSchedule: v1beta1.BackupScheduleValidationBackup,
+}
+
+// set the <type>Backup condition of each backup type using the last finished backup of that type;
+// the condition is True if this backup is completed and Unknown if there is no finished backup
+func setBackupTypeConditions(
+	backupSchedule *v1beta1.BackupSchedule,
+	backups []veleroapi.Backup,
+) {
+
+	lastBackups := map[ResourceType]*veleroapi.Backup{}
+	for i := range backups {
+		backup := &backups[i]
+		switch backup.Status.Phase {
+		case veleroapi.BackupPhaseCompleted,
+			veleroapi.BackupPhaseFailed,
+			veleroapi.BackupPhasePartiallyFailed,
+			veleroapi.BackupPhaseFailedValidation:
+		default:
+			// backup not finished yet
+			continue
+		}
+		backupType := ResourceType(backup.GetLabels()[BackupScheduleTypeLabel])
+		if last, ok := lastBackups[backupType]; ok && !getBackupTime(backup).After(getBackupTime(last)) {
+			continue
+		}
+		lastBackups[backupType] = backup
+	}
+
+	// set the conditions in the same order on each reconcile
+	backupTypes := make([]ResourceType, 0, len(backupTypeConditions))
+	for backupType := range backupTypeConditions {
+		backupTypes = append(backupTypes, backupType)
+	}
+	sort.Sort(SortResourceType(backupTypes))
+
+	for _, backupType := range backupTypes {
+		condition := v1.Condition{
+			Type:               backupTypeConditions[backupType],
+			Status:             v1.ConditionUnknown,
+			Reason:             v1beta1.BackupScheduleReasonNoFinishedBackup,
+			Message:            fmt.Sprintf("No %s backup is finished", backupType),
+			ObservedGeneration: backupSchedule.Generation,
+		}
+		if backup, ok := lastBackups[backupType]; ok {
+			condition.Status = v1.ConditionFalse
+			condition.Reason = v1beta1.BackupScheduleReasonBackupFailed
+			if backup.Status.Phase == veleroapi.BackupPhaseCompleted {
+				condition.Status = v1.ConditionTrue
+				condition.Reason = v1beta1.BackupScheduleReasonBackupCompleted
+			}
+			condition.Message = fmt.Sprintf("Backup %s is %s", backup.Name, backup.Status.Phase)
+		}
+		// the transition time is updated only when the condition status changes
+		meta.SetStatusCondition(&backupSchedule.Status.Conditions, condition)
+	}
+}
+
+// validationBackupGracePeriod is the time given to velero to complete the validation backup
+// after the validation cron fires
+const validationBackupGracePeriod = 15 * time.Minute
+
+// returns the completion time of a completed backup, or the backup time if not set
+func getBackupCompletionTime(backup *veleroapi.Backup) time.Time {
+
+	if backup.Status.CompletionTimestamp != nil {
+		return backup.Status.CompletionTimestamp.Time
+	}
+	return getBackupTime(backup)
+}
+
+// set the BackupChainHealthy condition using the validation backups created by the backup schedule:
+// the condition is True if a validation backup completed, so was written to the storage location,
+// within the validation cron interval and the validationBackupGracePeriod; Unknown until the first
+// validation backup is expected, and False otherwise
+func setBackupChainHealthyCondition(
+	backupSchedule *v1beta1.BackupSchedule,
+	backups []veleroapi.Backup,
+	now time.Time,
+) {
+
+	condition := v1.Condition{
+		Type:               v1beta1.BackupScheduleBackupChainHealthy,
+		ObservedGeneration: backupSchedule.Generation,
+	}
+	window := time.Hour + validationBackupGracePeriod
+	if cronSchedule, err := cron.ParseStandard(getValidationCronSchedule(backupSchedule)); err == nil {
+		window = getCronInterval(cronSchedule, now) + validationBackupGracePeriod
+	}
+
+	var lastCompleted, lastFailed *veleroapi.Backup
+	for i := range backups {
+		backup := &backups[i]
+		if ResourceType(backup.GetLabels()[BackupScheduleTypeLabel]) != ValidationSchedule {
+			continue
+		}
+		switch backup.Status.Phase {
+		case veleroapi.BackupPhaseCompleted:
+			if lastCompleted == nil ||
+				getBackupCompletionTime(backup).After(getBackupCompletionTime(lastCompleted)) {
+				lastCompleted = backup
+			}
+		case veleroapi.BackupPhaseFailed,
+			veleroapi.BackupPhasePartiallyFailed,
+			veleroapi.BackupPhaseFailedValidation:
+			if lastFailed == nil || getBackupTime(backup).After(getBackupTime(lastFailed)) {
+				lastFailed = backup
+			}
+		}
+	}
+
+	switch {
+	case lastCompleted != nil && now.Sub(getBackupCompletionTime(lastCompleted)) <= window:
+		condition.Status = v1.ConditionTrue
+		condition.Reason = v1beta1.BackupScheduleReasonValidationBackupCurrent
+		condition.Message = fmt.Sprintf("Validation backup %s completed at %s, within the last %s",
+			lastCompleted.Name, getBackupCompletionTime(lastCompleted).UTC().Format(time.RFC3339), window)
+	case lastCompleted == nil && now.Sub(backupSchedule.CreationTimestamp.Time) < window:
+		condition.Status = v1.ConditionUnknown
+		condition.Reason = v1beta1.BackupScheduleReasonValidationBackupPending
+		condition.Message = fmt.Sprintf("Waiting for the first validation backup, expected within %s", window)
+	default:
+		condition.Status = v1.ConditionFalse
+		condition.Reason = v1beta1.BackupScheduleReasonValidationBackupMissing
+		condition.Message = fmt.Sprintf("No validation backup completed within the last %s", window)
+		if lastCompleted != nil {
+			condition.Message += fmt.Sprintf(", the last validation backup %s completed at %s",
+				lastCompleted.Name, getBackupCompletionTime(lastCompleted).UTC().Format(time.RFC3339))
+		}
+		if lastFailed != nil {
+			condition.Message += fmt.Sprintf(", validation backup %s is %s",
+				lastFailed.Name, lastFailed.Status.Phase)
+		}
+	}
+	// the transition time is updated only when the condition status changes
+	meta.SetStatusCondition(&backupSchedule.Status.Conditions, condition)
+}
+
+// returns, for each backup type with a finished backup, the number of finished backups not completed
+// since the last completed backup of that type, sorted by backup type
+func getBackupFailures(backups []veleroapi.Backup) []v1beta1.BackupTypeFailures {
+
+	finishedBackups := map[string][]*veleroapi.Backup{}
+	for i := range backups {
+		backup := &backups[i]
+		switch backup.Status.Phase {
+		case veleroapi.BackupPhaseCompleted,
+			veleroapi.BackupPhaseFailed,
+			veleroapi.BackupPhasePartiallyFailed,
+			veleroapi.BackupPhaseFailedValidation:
+		default:
+			// backup not finished yet
+			continue
+		}
+		backupType := backup.GetLabels()[BackupScheduleTypeLabel]
+		finishedBackups[backupType] = append(finishedBackups[backupType], backup)
+	}
+
+	backupFailures := []v1beta1.BackupTypeFailures{}
+	for backupType, typeBackups := range finishedBackups {
+		// most recent first
+		sort.Slice(typeBackups, func(i, j int) bool {
+			return getBackupTime(typeBackups[i]).After(getBackupTime(typeBackups[j]))
+		})
+		failures := v1beta1.BackupTypeFailures{BackupType: backupType}
+		for _, backup := range typeBackups {
+			if backup.Status.Phase == veleroapi.BackupPhaseCompleted {
+				break
+			}
+			if failures.ConsecutiveFailures == 0 {
+				failures.LastFailedBackup = backup.Name
+			}
+			failures.ConsecutiveFailures++
+		}
+		backupFailures = append(backupFailures, failures)
+	}
+	sort.Slice(backupFailures, func(i, j int) bool {
+		return backupFailures[i].BackupType < backupFailures[j].BackupType
+	})
+	return backupFailures
+}
+
+// set the Degraded condition using the consecutive failures of each backup type: True if a backup type
+// failed FailureThreshold or more consecutive times, so a single transient failure doesn't flip the
+// condition, and False otherwise; the condition is removed if the FailureThreshold is not set
+func setDegradedCondition(
+	backupSchedule *v1beta1.BackupSchedule,
+	backupFailures []v1beta1.BackupTypeFailures,
+) {
+
+	threshold := backupSchedule.Spec.FailureThreshold
+	if threshold <= 0 {
+		meta.RemoveStatusCondition(&backupSchedule.Status.Conditions,
+			v1beta1.BackupScheduleDegraded)
+		return
+	}
+	condition := v1.Condition{
+		Type:               v1beta1.BackupScheduleDegraded,
+		Status:             v1.ConditionFalse,
+		Reason:             v1beta1.BackupScheduleReasonFailureThresholdNotReached,
+		Message:            fmt.Sprintf("No backup type failed %d consecutive times", threshold),
+		ObservedGeneration: backupSchedule.Generation,
+	}
+	degraded := []string{}
+	for _, failures := range backupFailures {
+		if failures.ConsecutiveFailures >= threshold {
+			degraded = append(degraded, fmt.Sprintf("%s (%d, last %s)",
+				failures.BackupType, failures.ConsecutiveFailures, failures.LastFailedBackup))
+		}
+	}
+	if len(degraded) > 0 {
+		condition.Status = v1.ConditionTrue
+		condition.Reason = v1beta1.BackupScheduleReasonFailureThresholdReached
+		condition.Message = fmt.Sprintf("Backup types failed %d or more consecutive times: %s",
+			threshold, strings.Join(degraded, ", "))
+	}
+	// the transition time is updated only when the condition status changes
+	meta.SetStatusCondition(&backupSchedule.Status.Conditions, condition)
+}
+
+// returns the backups InProgress for longer than the timeout, sorted by name;
+// the backup start time is the timestamp in the backup name or the backup creation time
+func getTimedOutBackups(
+	backups []veleroapi.Backup,
+	timeout time.Duration,
+	now time.Time,
+) []*veleroapi.Backup {
+
+	timedOut := []*veleroapi.Backup{}
+	if timeout <= 0 {
+		return timedOut
+	}
+	for i := range backups {
+		backup := &backups[i]
+		if backup.Status.Phase != veleroapi.BackupPhaseInProgress {
+			continue
+		}
+		if now.Sub(getBackupTime(backup)) > timeout {
+			timedOut = append(timedOut, backup)
+		}
+	}
+	sort.Slice(timedOut, func(i, j int) bool {
+		return timedOut[i].Name < timedOut[j].Name
+	})
+	return timedOut
+}
+
+// set the BackupTimedOut condition for the backups InProgress for longer than the BackupTimeout;
+// the condition is removed if the BackupTimeout is not set
+func setBackupTimedOutCondition(
+	backupSchedule *v1beta1.BackupSchedule,
+	timedOut []*veleroapi.Backup,
+) {
+
+	if backupSchedule.Spec.BackupTimeout.Duration == 0 {
+		meta.RemoveStatusCondition(&backupSchedule.Status.Conditions,
+			v1beta1.BackupScheduleBackupTimedOut)
+		return
+	}
+	condition := v1.Condition{
+		Type:               v1beta1.BackupScheduleBackupTimedOut,
+		Status:             v1.ConditionFalse,
+		Reason:             v1beta1.BackupScheduleReasonNoBackupTimedOut,
+		ObservedGeneration: backupSchedule.Generation,
+	}
+	if len(timedOut) > 0 {
+		names := make([]string, 0, len(timedOut))
+		for _, backup := range timedOut {
+			names = append(names, backup.Name)
+		}
+		condition.Status = v1.ConditionTrue
+		condition.Reason = v1beta1.BackupScheduleReasonBackupTimedOut
+		condition.Message = fmt.Sprintf("Backups InProgress for longer than %s: %s",
+			backupSchedule.Spec.BackupTimeout.Duration, strings.Join(names, ", "))
+	}
+	meta.SetStatusCondition(&backupSchedule.Status.Conditions, condition)
+}
+
+// report the backups InProgress for longer than the BackupTimeout with a Warning event,
+// recorded once for each backup, and the BackupTimedOut condition; the backups are only
+// reported, velero 1.7 can't cancel a running backup
+func (r *BackupScheduleReconciler) checkBackupTimeout(
+	backupSchedule *v1beta1.BackupSchedule,
+	backups []veleroapi.Backup,
+) {
+
+	timedOut := getTimedOutBackups(backups, backupSchedule.Spec.BackupTimeout.Duration, time.Now())
+	setBackupTimedOutCondition(backupSchedule, timedOut)
+
+	for _, backup := range timedOut {
+		recordedBackupEventsLock.Lock()
+		recorded, ok := recordedBackupEvents[backup.UID]
+		if !ok || recorded.phase != backup.Status.Phase {
+			r.Recorder.Event(backupSchedule, corev1.EventTypeWarning, "Backup timed out:",
+				fmt.Sprintf("Backup %s is InProgress for longer than %s",
+					backup.Name, backupSchedule.Spec.BackupTimeout.Duration))
+			recordedBackupEvents[backup.UID] = recordedBackupEvent{
+				scheduleName: backupSchedule.Name,
+				phase:        backup.Status.Phase,
+			}
+		}
+		recordedBackupEventsLock.Unlock()
+	}
+}
+
+// set the ResourceRulesConflict condition for the resources both included and excluded
+// by the Velero schedules; the resources are excluded from the backups
+func setResourceRulesConflictCondition(
+	backupSchedule *v1beta1.BackupSchedule,
+	conflicts []string,
+) {
+
+	condition := v1.Condition{
+		Type:               v1beta1.BackupScheduleResourceRulesConflict,
+		Status:             v1.ConditionFalse,
+		Reason:             v1beta1.BackupScheduleReasonNoResourceRulesConflict,
+		ObservedGeneration: backupSchedule.Generation,
+	}
+	if len(conflicts) > 0 {
+		condition.Status = v1.ConditionTrue
+		condition.Reason = v1beta1.BackupScheduleReasonResourceRulesConflict
+		condition.Message = "Resources both included and excluded are not backed up: " +
+			strings.Join(conflicts, ", ")
+	}
+	meta.SetStatusCondition(&backupSchedule.Status.Conditions, condition)
+}
+
+// server-side encryption config keys of the storage location, for the providers
+// where the object store plugin encrypts the backups with these keys
+var storageEncryptionConfigKeys = map[string][]string{
+	"aws": {"serverSideEncryption", "kmsKeyId", "customerKeyEncryptionFile"},
+	"gcp": {"kmsKeyName"},
+}
+
+// returns the StorageEncryption condition reason for the storage location, from the
+// server-side encryption config keys of its provider; the encryption is unknown
+// for the providers with no such keys
+func getStorageLocationEncryption(storageLocation *veleroapi.BackupStorageLocation) string {
+
+	keys, ok := storageEncryptionConfigKeys[strings.TrimPrefix(storageLocation.Spec.Provider, "velero.io/")]
+	if !ok {
+		return v1beta1.BackupScheduleReasonEncryptionUnknown
+	}
+	for _, key := range keys {
+		if storageLocation.Spec.Config[key] != "" {
+			return v1beta1.BackupScheduleReasonEncryptionVerified
+		}
+	}
+	return v1beta1.BackupScheduleReasonEncryptionDisabled
+}
+
+// set the StorageEncryption condition for the storage locations the backups are written to;
+// the condition is False if encryption is not configured for one of them and Unknown
+// if it can't be verified for one of them
+func setStorageEncryptionCondition(
+	backupSchedule *v1beta1.BackupSchedule,
+	storageLocations []veleroapi.BackupStorageLocation,
+) {
+
+	locationsByReason := map[string][]string{}
+	names := make([]string, 0, len(storageLocations))
+	for i := range storageLocations {
+		reason := getStorageLocationEncryption(&storageLocations[i])
+		locationsByReason[reason] = append(locationsByReason[reason], storageLocations[i].Name)
+		names = append(names, storageLocations[i].Name)
+	}
+
+	condition := v1.Condition{
+		Type:               v1beta1.BackupScheduleStorageEncryption,
+		Status:             v1.ConditionTrue,
+		Reason:             v1beta1.BackupScheduleReasonEncryptionVerified,
+		Message:            "Server-side encryption is configured for storage locations: " + strings.Join(names, ", "),
+		ObservedGeneration: backupSchedule.Generation,
+	}
+	switch {
+	case len(locationsByReason[v1beta1.BackupScheduleReasonEncryptionDisabled]) > 0:
+		condition.Status = v1.ConditionFalse
+		condition.Reason = v1beta1.BackupScheduleReasonEncryptionDisabled
+		condition.Message = "Server-side encryption is not configured for storage locations: " +
+			strings.Join(locationsByReason[v1beta1.BackupScheduleReasonEncryptionDisabled], ", ")
+	case len(storageLocations) == 0:
+		condition.Status = v1.ConditionUnknown
+		condition.Reason = v1beta1.BackupScheduleReasonEncryptionUnknown
+		condition.Message = "No storage location found to verify the server-side encryption"
+	case len(locationsByReason[v1beta1.BackupScheduleReasonEncryptionUnknown]) > 0:
+		condition.Status = v1.ConditionUnknown
+		condition.Reason = v1beta1.BackupScheduleReasonEncryptionUnknown
+		condition.Message = "Server-side encryption can't be verified for storage locations: " +
+			strings.Join(locationsByReason[v1beta1.BackupScheduleReasonEncryptionUnknown], ", ")
+	}
+	meta.SetStatusCondition(&backupSchedule.Status.Conditions, condition)
+}
+
+// set the StorageEncryption condition for the writable storage locations in the schedule
+// namespace, if the storage encryption verification is enabled; the backups are created
+// even if encryption is not configured
+func (r *BackupScheduleReconciler) verifyStorageEncryption(
+	ctx context.Context,
+	backupSchedule *v1beta1.BackupSchedule,
+) {
+
+	if !r.VerifyStorageEncryption {
+		meta.RemoveStatusCondition(&backupSchedule.Status.Conditions,
+			v1beta1.BackupScheduleStorageEncryption)
+		return
+	}
+	veleroStorageLocations := veleroapi.BackupStorageLocationList{}
+	if err := r.List(ctx, &veleroStorageLocations,
+		client.InNamespace(backupSchedule.Namespace)); err != nil {
+		log.FromContext(ctx).Info("Failed to list storage locations", "error", err.Error())
+		return
+	}
+	writable := getWritableStorageLocations(getStorageLocationsInNamespace(getValidStorageLocations(ctx,
+		veleroStorageLocations, r.AcceptUnownedStorageLocations), backupSchedule.Namespace))
+	storageLocations := []veleroapi.BackupStorageLocation{}
+	for i := range veleroStorageLocations.Items {
+		for j := range writable {
+			if writable[j].Name == veleroStorageLocations.Items[i].Name {
+				storageLocations = append(storageLocations, veleroStorageLocations.Items[i])
+				break
+			}
+		}
+	}
+	setStorageEncryptionCondition(backupSchedule, storageLocations)
+}
+
+// set the VeleroNotInstalled condition to True if the velero not installed message is set;
+// the condition is set to False once velero is installed, if it was set before
+func setVeleroNotInstalledCondition(
+	backupSchedule *v1beta1.BackupSchedule,
+	veleroNotInstalledMsg string,
+) {
+
+	if veleroNotInstalledMsg != "" {
+		meta.SetStatusCondition(&backupSchedule.Status.Conditions, v1.Condition{
+			Type:               v1beta1.BackupScheduleVeleroNotInstalled,
+			Status:             v1.ConditionTrue,
+			Reason:             v1beta1.BackupScheduleReasonVeleroCRDsNotFound,
+			Message:            veleroNotInstalledMsg,
+			ObservedGeneration: backupSchedule.Generation,
+		})
+		return
+	}
+	if meta.FindStatusCondition(backupSchedule.Status.Conditions,
+		v1beta1.BackupScheduleVeleroNotInstalled) != nil {
+		meta.SetStatusCondition(&backupSchedule.Status.Conditions, v1.Condition{
+			Type:               v1beta1.BackupScheduleVeleroNotInstalled,
+			Status:             v1.ConditionFalse,
+			Reason:             v1beta1.BackupScheduleReasonVeleroCRDsFound,
+			Message:            "The Velero CRDs are installed",
+			ObservedGeneration: backupSchedule.Generation,
+		})
+	}
+}
+
+func parseCronSchedule(
+	ctx context.Context,
+	backupSchedule *v1beta1.BackupSchedule,
+) []string {
+
+	// cron.Parse panics if schedule is empty
+	if len(backupSchedule.Spec.VeleroSchedule) == 0 {
+		return []string{"Schedule must be a non-empty valid Cron expression"}
+	}
+
+	return parseCronExpression(ctx, backupSchedule.Spec.VeleroSchedule)
+}
+
+// returns the errors parsing a non-empty cron expression
+func parseCronExpression(
+	ctx context.Context,
+	cronExpression string,
+) []string {
+	var validationErrors []string
+
+	scheduleLogger := log.FromContext(ctx)
+
+	// adding a recover() around cron.Parse because it panics on empty string and is possible
+	// that it panics under other scenarios as well.
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				scheduleLogger.Info(
+					"Panic parsing schedule",
+					"schedule", cronExpression,
+				)
+				validationErrors = append(validationErrors, fmt.Sprintf("invalid schedule: %v", r))
+			}
+		}()
+
+		if _, err := cron.ParseStandard(cronExpression); err != nil {
+			scheduleLogger.Error(
+				err,
+				"Error parsing schedule",
+				"schedule", cronExpression,
+			)
+			validationErrors = append(validationErrors, fmt.Sprintf("invalid schedule: %v", err))
+		}
+	}()
+
+	return validationErrors
+}
+
+// returns the backup types which can be set in veleroScheduleOverrides: the backup set
+// types restored together, the credentials, resources and managed clusters backups
+func getOverridableBackupTypes() []string {
+
+	backupTypes := []string{string(Credentials), string(ManagedClusters), string(Resources)}
+	sort.Strings(backupTypes)
+	return backupTypes
+}
+
+// returns the veleroScheduleOverrides key setting the cron of the backup type; the hive and
+// cluster credentials use the credentials cron and the generic resources use the resources cron,
+// so they are backed up at the same time as the backups they are restored with
+func getOverrideBackupType(backupType ResourceType) ResourceType {
+
+	switch backupType {
+	case CredentialsHive, CredentialsCluster:
+		return Credentials
+	case ResourcesGeneric:
+		return Resources
+	}
+	return backupType
+}
+
+// returns the cron expression of the velero schedules of the backup type, the
+// veleroScheduleOverrides cron for this type if set, see getOverrideBackupType,
+// or the veleroSchedule cron;
+// the validation schedule uses the validationSchedule cron, see getValidationCronSchedule.
+// The cron is shifted by the scheduleJitter offset of the hub with the clusterID id
+func getBackupTypeCronSchedule(
+	backupSchedule *v1beta1.BackupSchedule,
+	backupType ResourceType,
+	clusterID string,
+) string {
+
+	cronSchedule := backupSchedule.Spec.VeleroSchedule
+	if backupType == ValidationSchedule {
+		cronSchedule = getValidationCronSchedule(backupSchedule)
+	} else if override, ok := backupSchedule.Spec.VeleroScheduleOverrides[string(
+		getOverrideBackupType(backupType))]; ok {
+		cronSchedule = override
+	}
+	jittered, _ := getJitteredCronSchedule(cronSchedule,
+		getScheduleJitterOffset(clusterID, backupSchedule.Spec.ScheduleJitter.Duration))
+	return jittered
+}
+
+// returns the veleroSchedule cron, shifted by the scheduleJitter offset of the hub with
+// the clusterID id; the veleroScheduleOverrides crons are ignored
+func getJitteredVeleroSchedule(backupSchedule *v1beta1.BackupSchedule, clusterID string) string {
+
+	jittered, _ := getJitteredCronSchedule(backupSchedule.Spec.VeleroSchedule,
+		getScheduleJitterOffset(clusterID, backupSchedule.Spec.ScheduleJitter.Duration))
+	return jittered
+}
+
+// returns the cron expression of the validation schedule, the validationSchedule cron if set,
+// or the veleroSchedule cron
+func getValidationCronSchedule(backupSchedule *v1beta1.BackupSchedule) string {
+
+	if backupSchedule.Spec.ValidationSchedule != "" {
+		return backupSchedule.Spec.ValidationSchedule
+	}
+	return backupSchedule.Spec.VeleroSchedule
+}
+
+// maxScheduleJitter is the longest scheduleJitter, the offset only shifts the minutes of the cron
+const maxScheduleJitter = 59 * time.Minute
+
+// returns the offset of the scheduled backups of the hub with the clusterID id, a number of
+// minutes between 0 and the jitter; the offset is computed from the hub id, so it is the
+// same after the operator restarts and different hubs get spread offsets
+func getScheduleJitterOffset(clusterID string, jitter time.Duration) time.Duration {
+
+	if jitter > maxScheduleJitter {
+		jitter = maxScheduleJitter
+	}
+	minutes := uint64(jitter / time.Minute)
+	if minutes == 0 || clusterID == "" {
+		return 0
+	}
+	sum := sha256.Sum256([]byte(clusterID))
+	return time.Duration(binary.BigEndian.Uint64(sum[:8])%(minutes+1)) * time.Minute
+}
+
+// returns the cron expression with the minutes field shifted by the offset, and false
+// if the minutes field can't be shifted. A list of minutes is shifted without moving
+// a run to the next hour, the offset is reduced to the minutes left after the latest run;
+// a */step interval is shifted within the step. Other minutes fields, such as
+// ranges, * and the @daily descriptors, are not shifted
+func getJitteredCronSchedule(cronSchedule string, offset time.Duration) (string, bool) {
+
+	fields := strings.Fields(cronSchedule)
+	minutesField := 0
+	if len(fields) > 0 && (strings.HasPrefix(fields[0], "CRON_TZ=") ||
+		strings.HasPrefix(fields[0], "TZ=")) {
+		minutesField = 1
+	}
+	if len(fields) != minutesField+5 {
+		return cronSchedule, false
+	}
+	shift := int(offset / time.Minute)
+
+	minutes := fields[minutesField]
+	if strings.HasPrefix(minutes, "*/") {
+		step, err := strconv.Atoi(strings.TrimPrefix(minutes, "*/"))
+		if err != nil || step <= 0 || step > 59 {
+			return cronSchedule, false
+		}
+		if shift%step != 0 {
+			fields[minutesField] = fmt.Sprintf("%d/%d", shift%step, step)
+		}
+		return strings.Join(fields, " "), true
+	}
+
+	values := strings.Split(minutes, ",")
+	runs := make([]int, 0, len(values))
+	latest := 0
+	for _, value := range values {
+		minute, err := strconv.Atoi(value)
+		if err != nil || minute < 0 || minute > 59 {
+			return cronSchedule, false
+		}
+		runs = append(runs, minute)
+		if minute > latest {
+			latest = minute
+		}
+	}
+	shift = shift % (60 - latest)
+	if shift == 0 {
+		return strings.Join(fields, " "), true
+	}
+	for i := range runs {
+		values[i] = strconv.Itoa(runs[i] + shift)
+	}
+	fields[minutesField] = strings.Join(values, ",")
+	return strings.Join(fields, " "), true
+}
+
+// validate the scheduleJitter; returns the validation errors and a warning
+// if the veleroSchedule cron can't be shifted by the jitter
+func validateScheduleJitter(backupSchedule *v1beta1.BackupSchedule) ([]string, []string) {
+
+	jitter := backupSchedule.Spec.ScheduleJitter.Duration
+	if jitter < 0 || jitter > maxScheduleJitter {
+		return []string{fmt.Sprintf(
+			"scheduleJitter %s must be between 0 and %s", jitter, maxScheduleJitter)}, []string{}
+	}
+	warnings := []string{}
+	if jitter < time.Minute {
+		return []string{}, warnings
+	}
+	crons := map[string]string{"veleroSchedule": backupSchedule.Spec.VeleroSchedule}
+	for backupType, cronSchedule := range backupSchedule.Spec.VeleroScheduleOverrides {
+		crons["veleroScheduleOverrides "+backupType] = cronSchedule
+	}
+	if backupSchedule.Spec.ValidationSchedule != "" {
+		crons["validationSchedule"] = backupSchedule.Spec.ValidationSchedule
+	}
+	sources := make([]string, 0, len(crons))
+	for source := range crons {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+	for _, source := range sources {
+		if _, ok := getJitteredCronSchedule(crons[source], jitter); !ok {
+			warnings = append(warnings, fmt.Sprintf(
+				"scheduleJitter is not applied to the %s cron %q, the minutes field must be "+
+					"a list of minutes or a */step interval", source, crons[source]))
+		}
+	}
+	return []string{}, warnings
+}
+
+// returns the veleroScheduleOverrides validation errors, with the path of the offending field
+func getVeleroScheduleOverridesErrors(
+	ctx context.Context,
+	backupSchedule *v1beta1.BackupSchedule,
+	specPath *field.Path,
+) field.ErrorList {
+
+	backupTypes := getOverridableBackupTypes()
+	keys := []string{}
+	for backupType := range backupSchedule.Spec.VeleroScheduleOverrides {
+		keys = append(keys, backupType)
+	}
+	sort.Strings(keys)
+
+	errs := field.ErrorList{}
+	for _, backupType := range keys {
+		path := specPath.Child("veleroScheduleOverrides").Key(backupType)
+		cronSchedule := backupSchedule.Spec.VeleroScheduleOverrides[backupType]
+		if !findValue(backupTypes, backupType) {
+			errs = append(errs, field.NotSupported(path, backupType, backupTypes))
+			continue
+		}
+		if cronSchedule == "" {
+			errs = append(errs, field.Required(path, "must be a non-empty valid Cron expression"))
+			continue
+		}
+		for _, msg := range parseCronExpression(ctx, cronSchedule) {
+			errs = append(errs, field.Invalid(path, cronSchedule, msg))
+		}
+	}
+	return errs
+}
+
+// ValidateBackupSchedule validates a backup schedule resource without a cluster connection,
+// using the structural checks run by the operator when the backup schedule is created.
+// Returns the validation errors and the warnings for the backup schedule.
+func ValidateBackupSchedule(
+	ctx context.Context,
+	backupSchedule *v1beta1.BackupSchedule,
+) ([]string, []string) {
+
+	validationErrors := []string{}
+	warnings := []string{}
+
+	// the resource must be a BackupSchedule from the operator api group
+	kind, group := getResourceDetails(backupScheduleResource)
+	gv, err := schema.ParseGroupVersion(backupSchedule.APIVersion)
+	if err != nil || gv.Group != group || gv.Version != v1beta1.GroupVersion.Version ||
+		strings.ToLower(backupSchedule.Kind) != kind {
+		validationErrors = append(validationErrors, fmt.Sprintf(
+			"resource must be a %s, found apiVersion %q and kind %q",
+			v1beta1.GroupVersion.WithKind("BackupSchedule"),
+			backupSchedule.APIVersion, backupSchedule.Kind))
+	}
+
+	if backupSchedule.Name == "" {
+		validationErrors = append(validationErrors, "metadata.name must be set")
+	} else {
+		for _, msg := range validation.IsDNS1123Subdomain(backupSchedule.Name) {
+			validationErrors = append(validationErrors, "invalid metadata.name: "+msg)
+		}
+	}
+
+	validationErrors = append(validationErrors, getBackupScheduleValidationMessages(ctx, backupSchedule)...)
+
+	_, jitterWarnings := validateScheduleJitter(backupSchedule)
+	warnings = append(warnings, jitterWarnings...)
+	if len(validateVeleroTTL(backupSchedule)) == 0 {
+		if msg := getShortTTLMessage(backupSchedule, collisionControlInterval); msg != "" {
+			warnings = append(warnings, msg)
+		}
+	}
+
+	return validationErrors, warnings
+}
+
+// validate the velero TTL; if not set, the velero default TTL is used
+func validateVeleroTTL(backupSchedule *v1beta1.BackupSchedule) []string {
+
+	if backupSchedule.Spec.VeleroTTL.Duration < 0 {
+		return []string{fmt.Sprintf(
+			"veleroTtl %s must not be negative", backupSchedule.Spec.VeleroTTL.Duration)}
+	}
+	return []string{}
+}
+
+// validate the label selector used by the generic resources backup, if any
+func validateGenericResourceLabelSelector(backupSchedule *v1beta1.BackupSchedule) []string {
+
+	if backupSchedule.Spec.GenericResourceLabelSelector == nil {
+		return []string{}
+	}
+	if _, err := v1.LabelSelectorAsSelector(
+		backupSchedule.Spec.GenericResourceLabelSelector); err != nil {
+		return []string{fmt.Sprintf("invalid genericResourceLabelSelector: %v", err)}
+	}
+	return []string{}
+}
+
+// validate the label selector used by the managed clusters backup, if any
+func validateManagedClustersLabelSelector(backupSchedule *v1beta1.BackupSchedule) []string {
+
+	if backupSchedule.Spec.ManagedClustersLabelSelector == nil {
+		return []string{}
+	}
+	if _, err := v1.LabelSelectorAsSelector(
+		backupSchedule.Spec.ManagedClustersLabelSelector); err != nil {
+		return []string{fmt.Sprintf("invalid managedClustersLabelSelector: %v", err)}
+	}
+	return []string{}
+}
+
+// returns the backupLabels and backupAnnotations validation errors, with the path of the offending field
+func getBackupMetadataErrors(backupSchedule *v1beta1.BackupSchedule, specPath *field.Path) field.ErrorList {
+
+	errs := metav1validation.ValidateLabels(backupSchedule.Spec.BackupLabels,
+		specPath.Child("backupLabels"))
+	return append(errs, apivalidation.ValidateAnnotations(backupSchedule.Spec.BackupAnnotations,
+		specPath.Child("backupAnnotations"))...)
+}
+
+// returns true if the namespaces excluded from the generic resources schedule
+// don't match the namespaces excluded by the backup schedule;
+// the operator doesn't exclude other namespaces from this schedule
+func isExcludedNamespacesUpdated(
+	veleroSchedule *veleroapi.Schedule,
+	backupSchedule *v1beta1.BackupSchedule,
+) bool {
+
+	excludedNamespaces := []string{}
+	for _, namespace := range backupSchedule.Spec.ExcludedNamespaces {
+		excludedNamespaces = appendUnique(excludedNamespaces, namespace)
+	}
+	scheduleNamespaces := veleroSchedule.Spec.Template.ExcludedNamespaces
+	if len(scheduleNamespaces) != len(excludedNamespaces) {
+		return true
+	}
+	for _, namespace := range excludedNamespaces {
+		if !findValue(scheduleNamespaces, namespace) {
+			return true
+		}
+	}
+	return false
+}
+
+// returns true if the namespaces excluded from the managed clusters schedules don't match
+// the managed clusters not selected by the backup schedule, for example when a managed cluster
+// is labeled or created after the schedules; the schedules are kept if the managed clusters
+// can't be listed
+func (r *BackupScheduleReconciler) isManagedClustersSelectionUpdated(
+	ctx context.Context,
+	backupSchedule *v1beta1.BackupSchedule,
+	veleroScheduleList *veleroapi.ScheduleList,
+) bool {
+
+	unselectedClusters, err := getUnselectedManagedClusters(ctx, backupSchedule, r.DynamicClient)
+	if err != nil {
+		log.FromContext(ctx).Error(err, "Failed to list the managed clusters")
+		return false
+	}
+	return isManagedClustersExclusionUpdated(veleroScheduleList, unselectedClusters)
+}
+
+// returns true if the namespaces excluded from the managed clusters schedules don't match
+// the namespaces of the unselected managed clusters
+func isManagedClustersExclusionUpdated(
+	veleroScheduleList *veleroapi.ScheduleList,
+	unselectedClusters []string,
+) bool {
+
+	veleroBackupTemplate := &veleroapi.BackupSpec{}
+	setManagedClustersBackupInfo(context.Background(), veleroBackupTemplate, nil)
+	setUnselectedManagedClustersBackupInfo(veleroBackupTemplate, unselectedClusters)
+	excludedNamespaces := veleroBackupTemplate.ExcludedNamespaces
+
+	for i := range veleroScheduleList.Items {
+		veleroSchedule := &veleroScheduleList.Items[i]
+		if veleroSchedule.GetLabels()[BackupScheduleTypeLabel] != string(ManagedClusters) {
+			continue
+		}
+		scheduleNamespaces := veleroSchedule.Spec.Template.ExcludedNamespaces
+		if len(scheduleNamespaces) != len(excludedNamespaces) {
+			return true
+		}
+		for _, namespace := range excludedNamespaces {
+			if !findValue(scheduleNamespaces, namespace) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// returns true if the resources excluded from the generic resources schedules don't match
+// the resources excluded by the backup schedule, for example when the excluded API groups
+// are updated or an excluded API group serves a new resource; the schedules are kept
+// if discovery fails
+func (r *BackupScheduleReconciler) isExcludedAPIGroupsUpdated(
+	ctx context.Context,
+	backupSchedule *v1beta1.BackupSchedule,
+	veleroScheduleList *veleroapi.ScheduleList,
+) bool {
+
+	groupVersions, err := getServerGroupVersionResources(ctx, r.DiscoveryClient)
+	if err != nil {
+		log.FromContext(ctx).Info("Failed to discover the excluded API groups resources",
+			"error", err.Error())
+		return false
+	}
+	return isGenericExcludedResourcesUpdated(veleroScheduleList,
+		getGenericExcludedResources(groupVersions, backupSchedule))
+}
+
+// returns the resources excluded from the generic resources schedules, resolved
+// with the server resources as when the schedules are created
+func getGenericExcludedResources(
+	groupVersions []groupVersionResources,
+	backupSchedule *v1beta1.BackupSchedule,
+) []string {
+
+	veleroBackupTemplate := &veleroapi.BackupSpec{}
+	setGenericResourcesBackupInfo(context.Background(), veleroBackupTemplate, backupSchedule, nil)
+	excludedResources := append(veleroBackupTemplate.ExcludedResources,
+		getAPIGroupsResources(groupVersions, backupSchedule.Spec.ExcludedAPIGroups)...)
+	return canonicalizeExcludedResources(groupVersions, excludedResources)
+}
+
+// returns true if the resources excluded from the generic resources schedules don't match
+// the excluded resources
+func isGenericExcludedResourcesUpdated(
+	veleroScheduleList *veleroapi.ScheduleList,
+	excludedResources []string,
+) bool {
+
+	for i := range veleroScheduleList.Items {
+		veleroSchedule := &veleroScheduleList.Items[i]
+		if veleroSchedule.GetLabels()[BackupScheduleTypeLabel] != string(ResourcesGeneric) {
+			continue
+		}
+		scheduleResources := veleroSchedule.Spec.Template.ExcludedResources
+		if len(scheduleResources) != len(excludedResources) {
+			return true
+		}
+		for _, resource := range excludedResources {
+			if !findValueCaseInsensitive(scheduleResources, resource) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// returns true if this schedule has generated the latest backups in the
+// storage location
+func (r *BackupScheduleReconciler) scheduleOwnsLatestStorageBackups(
+	ctx context.Context,
+	backupSchedule *veleroapi.Schedule,
+) (bool, *veleroapi.Backup) {
+
+	logger := log.FromContext(ctx)
+
+	backups := veleroapi.BackupList{}
+	if err := r.List(ctx, &backups,
+		client.MatchingLabels{"velero.io/schedule-name": veleroScheduleNames[Resources]}); err != nil {
+		logger.Info(err.Error())
+		return true, nil
+	}
+	// get only acm resources backups and not in deleting state
+	// which are backups starting with acm-resources-schedule
+	sliceBackups := filterBackups(backups.Items[:], func(bkp veleroapi.Backup) bool {
+		return bkp.Status.Phase != veleroapi.BackupPhaseDeleting
+	})
+
+	// sort backups
+	sort.Slice(sliceBackups, func(i, j int) bool {
+		var timeA int64
+		var timeB int64
+		if sliceBackups[i].Status.StartTimestamp != nil {
+			timeA = sliceBackups[i].Status.StartTimestamp.Time.Unix()
+		}
+		if sliceBackups[j].Status.StartTimestamp != nil {
+			timeB = sliceBackups[j].Status.StartTimestamp.Time.Unix()
+		}
+		return timeA < timeB
+	})
+
+	if len(sliceBackups) == 0 {
+		return true, nil
+	}
+	lastBackup := sliceBackups[len(sliceBackups)-1]
+
+	if lastBackup.Labels[BackupScheduleClusterLabel] != backupSchedule.GetLabels()[BackupScheduleClusterLabel] {
+		return false, &lastBackup
+	}
+
+	return true, nil
+}
+
+// returns the Velero schedules using the names set by this operator
+// and not owned by any resource, so they can be adopted by a backup schedule
+func getAdoptableVeleroSchedules(schedules []veleroapi.Schedule) []veleroapi.Schedule {
+
+	scheduleNames := make([]string, 0, len(veleroScheduleNames))
+	for _, name := range veleroScheduleNames {
+		scheduleNames = append(scheduleNames, name)
+	}
+
+	adoptable := []veleroapi.Schedule{}
+	for i := range schedules {
+		if findValue(scheduleNames, schedules[i].Name) &&
+			v1.GetControllerOf(&schedules[i]) == nil {
+			adoptable = append(adoptable, schedules[i])
+		}
+	}
+	return adoptable
+}
+
+// returns the backup schedule spec reflecting the existing Velero schedules
+// the cron job and ttl are read from the resources schedule, if found
+func getBackupScheduleSpecFromVeleroSchedules(
+	schedules []veleroapi.Schedule,
+) v1beta1.BackupScheduleSpec {
+
+	spec := v1beta1.BackupScheduleSpec{}
+	if len(schedules) == 0 {
+		return spec
+	}
+
+	source := &schedules[0]
+	for i := range schedules {
+		if schedules[i].Name == veleroScheduleNames[Resources] {
+			source = &schedules[i]
+			break
+		}
+	}
+	spec.VeleroSchedule = source.Spec.Schedule
+	spec.VeleroTTL = source.Spec.Template.TTL
+	return spec
+}
+
+// adopt the Velero schedules created with the operator names and not owned by any resource
+// returns the names of the adopted schedules
+func (r *BackupScheduleReconciler) adoptVeleroSchedules(
+	ctx context.Context,
+	backupSchedule *v1beta1.BackupSchedule,
+	clusterId string,
+) ([]string, error) {
+
+	logger := log.FromContext(ctx)
+
+	veleroScheduleList := veleroapi.ScheduleList{}
+	if err := r.List(ctx, &veleroScheduleList, client.InNamespace(backupSchedule.Namespace)); err != nil {
+		return nil, err
+	}
+
+	adopted := []string{}
+	schedules := getAdoptableVeleroSchedules(veleroScheduleList.Items)
+	for i := range schedules {
+		veleroSchedule := &schedules[i]
+
+		labels := veleroSchedule.GetLabels()
+		if labels == nil {
+			labels = make(map[string]string)
+		}
+		labels[BackupScheduleNameLabel] = backupSchedule.Name
+		for key, value := range veleroScheduleNames {
+			if value == veleroSchedule.Name {
+				labels[BackupScheduleTypeLabel] = string(key)
+			}
+		}
+		labels[BackupScheduleClusterLabel] = clusterId
+		veleroSchedule.SetLabels(labels)
+
+		if err := ctrl.SetControllerReference(backupSchedule, veleroSchedule, r.Scheme); err != nil {
+			return adopted, err
+		}
+		// the schedule was created by another field manager, take over its fields
+		if err := applyVeleroResource(ctx, r.Client, getVeleroScheduleToApply(veleroSchedule),
+			client.ForceOwnership); err != nil {
+			return adopted, err
+		}
+		logger.Info("Velero schedule adopted",
+			"name", veleroSchedule.Name,
+			"namespace", veleroSchedule.Namespace,
+		)
+		adopted = append(adopted, veleroSchedule.Name)
+	}
+	sort.Strings(adopted)
+	return adopted, nil
+}
+
+// CreateBackupSchedulesForVeleroSchedules creates a BackupSchedule resource
+// in each namespace with Velero schedules using the operator names, not owned by any resource,
+// if that namespace has no BackupSchedule; the Velero schedules are adopted
+// by the new BackupSchedule when this one is reconciled
+func CreateBackupSchedulesForVeleroSchedules(
+	ctx context.Context,
+	c client.Client,
+) error {
+
+	logger := log.FromContext(ctx)
+
+	veleroScheduleList := veleroapi.ScheduleList{}
+	if err := c.List(ctx, &veleroScheduleList); err != nil {
+		return err
+	}
+
+	schedulesByNamespace := make(map[string][]veleroapi.Schedule)
+	for _, schedule := range getAdoptableVeleroSchedules(veleroScheduleList.Items) {
+		schedulesByNamespace[schedule.Namespace] = append(
+			schedulesByNamespace[schedule.Namespace],
+			schedule,
+		)
+	}
+
+	for namespace, schedules := range schedulesByNamespace {
+		backupSchedules := v1beta1.BackupScheduleList{}
+		if err := c.List(ctx, &backupSchedules, client.InNamespace(namespace)); err != nil {
+			return err
+		}
+		if len(backupSchedules.Items) > 0 {
+			// the existing BackupSchedule adopts the Velero schedules
+			continue
+		}
+
+		backupSchedule := &v1beta1.BackupSchedule{}
+		backupSchedule.Name = adoptedBackupScheduleName
+		backupSchedule.Namespace = namespace
+		backupSchedule.Spec = getBackupScheduleSpecFromVeleroSchedules(schedules)
+		if err := c.Create(ctx, backupSchedule); err != nil {
+			return err
+		}
+		logger.Info("BackupSchedule created for existing Velero schedules",
+			"name", backupSchedule.Name,
+			"namespace", backupSchedule.Namespace,
+		)
+	}
+	return nil
+}
+
+// returns the backups created by the Velero schedules owned by the backup schedule
+// and labeled with an unknown hub id; returns an error if backups created by
+// another hub are found for this backup schedule, since they can't be told apart
+func getBackupsWithUnknownHubID(
+	backups []veleroapi.Backup,
+	backupScheduleName string,
+	veleroScheduleNames []string,
+	clusterId string,
+) ([]veleroapi.Backup, error) {
+
+	scheduleBackups := filterBackups(backups, func(bkp veleroapi.Backup) bool {
+		return bkp.GetLabels()[BackupScheduleNameLabel] == backupScheduleName &&
+			findValue(veleroScheduleNames, bkp.GetLabels()["velero.io/schedule-name"])
+	})
+
+	unknownBackups := []veleroapi.Backup{}
+	for i := range scheduleBackups {
+		backupClusterId := scheduleBackups[i].GetLabels()[BackupScheduleClusterLabel]
+		if backupClusterId == "" || backupClusterId == unknownHubID {
+			unknownBackups = append(unknownBackups, scheduleBackups[i])
+			continue
+		}
+		if backupClusterId != clusterId {
+			return nil, fmt.Errorf("backup %s was created by hub %s",
+				scheduleBackups[i].Name, backupClusterId)
+		}
+	}
+	return unknownBackups, nil
+}
+
+// UpdateBackupsHubIdentification labels the backups created by this hub
+// with an unknown hub id, with the current hub id;
+// with dryRun, only reports the backups to update.
+// Returns the number of updated backups
+func UpdateBackupsHubIdentification(
+	ctx context.Context,
+	c client.Client,
+	dyn dynamic.Interface,
+	mapper *restmapper.DeferredDiscoveryRESTMapper,
+	dryRun bool,
+) (int, error) {
+
+	logger := log.FromContext(ctx)
+
+	clusterId, err := getHubIdentification(ctx, dyn, mapper)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get the hub identification: %v", err)
+	}
+	if clusterId == unknownHubID {
+		return 0, fmt.Errorf("hub identification not available")
+	}
+
+	backupSchedules := v1beta1.BackupScheduleList{}
+	if err := c.List(ctx, &backupSchedules); err != nil {
+		return 0, err
+	}
+
+	updated := 0
+	for i := range backupSchedules.Items {
+		backupSchedule := &backupSchedules.Items[i]
+		if backupSchedule.Status.Phase == v1beta1.SchedulePhaseBackupCollision {
+			logger.Info("Skipping backup schedule with a backup collision",
+				"name", backupSchedule.Name, "namespace", backupSchedule.Namespace)
+			continue
+		}
+
+		veleroSchedules := veleroapi.ScheduleList{}
+		if err := c.List(ctx, &veleroSchedules, client.InNamespace(backupSchedule.Namespace)); err != nil {
+			return updated, err
+		}
+		ownedSchedules := []*veleroapi.Schedule{}
+		ownedScheduleNames := []string{}
+		for j := range veleroSchedules.Items {
+			owner := v1.GetControllerOf(&veleroSchedules.Items[j])
+			if owner != nil && owner.UID == backupSchedule.UID {
+				ownedSchedules = append(ownedSchedules, &veleroSchedules.Items[j])
+				ownedScheduleNames = append(ownedScheduleNames, veleroSchedules.Items[j].Name)
+			}
+		}
+
+		backups := veleroapi.BackupList{}
+		if err := c.List(ctx, &backups, client.InNamespace(backupSchedule.Namespace)); err != nil {
+			return updated, err
+		}
+		unknownBackups, err := getBackupsWithUnknownHubID(backups.Items, backupSchedule.Name,
+			ownedScheduleNames, clusterId)
+		if err != nil {
+			logger.Info("Skipping backup schedule, backups from other hubs found",
+				"name", backupSchedule.Name, "namespace", backupSchedule.Namespace,
+				"reason", err.Error())
+			continue
+		}
+
+		for j := range unknownBackups {
+			logger.Info("Updating hub id for backup", "name", unknownBackups[j].Name,
+				"namespace", unknownBackups[j].Namespace, "hubId", clusterId, "dryRun", dryRun)
+			if dryRun {
+				updated++
+				continue
+			}
+			unknownBackups[j].Labels[BackupScheduleClusterLabel] = clusterId
+			if err := c.Update(ctx, &unknownBackups[j]); err != nil {
+				logger.Error(err, "Failed to update hub id for backup", "name", unknownBackups[j].Name)
+				continue
+			}
+			updated++
+		}
+
+		if dryRun {
+			continue
+		}
+		// new backups are created with the velero schedule labels
+		for j := range ownedSchedules {
+			if ownedSchedules[j].Labels[BackupScheduleClusterLabel] != unknownHubID {
+				continue
+			}
+			ownedSchedules[j].Labels[BackupScheduleClusterLabel] = clusterId
+			// the schedules created before the operator used server-side apply
+			// are owned by another field manager
+			if err := applyVeleroResource(ctx, c, getVeleroScheduleToApply(ownedSchedules[j]),
+				client.ForceOwnership); err != nil {
+				logger.Error(err, "Failed to update hub id for schedule", "name", ownedSchedules[j].Name)
+			}
+		}
+	}
+	return updated, nil
 }
 
 // prepare resources before backing up
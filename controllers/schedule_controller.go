@@ -27,17 +27,26 @@ import (
 	"github.com/pkg/errors"
 	v1beta1 "github.com/stolostron/cluster-backup-operator/api/v1beta1"
 	veleroapi "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+	k8serr "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/restmapper"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
 // ResourceType is the type to contain resource type string value
@@ -61,6 +70,9 @@ const (
 	// these are user resources, except secrets, labeled with cluster.open-cluster-management.io/backup
 	// secrets labeled with cluster.open-cluster-management.io/backup are already backed up under credentialsCluster
 	ResourcesGeneric ResourceType = "resourcesGeneric"
+	// NamespaceContents all the resources in the namespaces labeled with
+	// cluster.open-cluster-management.io/backup, for the NamespaceContents namespace backup mode
+	NamespaceContents ResourceType = "namespaceContents"
 )
 
 // SecretType is the type of secret
@@ -89,18 +101,42 @@ type BackupScheduleReconciler struct {
 	DynamicClient   dynamic.Interface
 	RESTMapper      *restmapper.DeferredDiscoveryRESTMapper
 	Scheme          *runtime.Scheme
+	Recorder        record.EventRecorder
+	// StorageLocationProbeTimeout is the timeout for the storage location
+	// connectivity probe; the probe is disabled if not set
+	StorageLocationProbeTimeout time.Duration
+	// VerifyStorageEncryption sets the StorageEncryption condition from the
+	// server-side encryption config of the storage locations
+	VerifyStorageEncryption bool
+	// ReconcileInterval is the interval between the reconciles of the backup schedules,
+	// checking for backup collisions; collisionControlInterval if not set
+	ReconcileInterval time.Duration
+	// AcceptUnownedStorageLocations uses the available storage locations with no owner reference,
+	// for example created by GitOps; only the storage locations owned by the resource used
+	// to install velero are used if not set
+	AcceptUnownedStorageLocations bool
+	// DRStatus is updated with the hub disaster recovery posture
+	// on each status update, if set
+	DRStatus *DRStatus
+	// the hub uid, looked up once
+	hubID hubIdentification
 }
 
 //+kubebuilder:rbac:groups=cluster.open-cluster-management.io,resources=backupschedules,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=cluster.open-cluster-management.io,resources=backupschedules/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=cluster.open-cluster-management.io,resources=backupschedules/finalizers,verbs=update
 //+kubebuilder:rbac:groups=hive.openshift.io,resources=clusterpools,verbs=get;list;watch
-//+kubebuilder:rbac:groups=cluster.open-cluster-management.io,resources=managedclusters,verbs=get;list;watch
+//+kubebuilder:rbac:groups=cluster.open-cluster-management.io,resources=managedclusters,verbs=get;list;watch;patch
 //+kubebuilder:rbac:groups=apps.open-cluster-management.io,resources=channels,verbs=get;list;watch
 //+kubebuilder:rbac:groups=velero.io,resources=schedules,verbs=get;list;watch;create;update;patch;delete
-//+kubebuilder:rbac:groups=velero.io,resources=backups,verbs=get;list;watch;create;update;patch
+//+kubebuilder:rbac:groups=velero.io,resources=backups,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=velero.io,resources=restores,verbs=get;list
 //+kubebuilder:rbac:groups=velero.io,resources=backupstoragelocations,verbs=get;list;watch
 //+kubebuilder:rbac:groups=velero.io,resources=deletebackuprequests,verbs=create;list;watch
+//+kubebuilder:rbac:groups=velero.io,resources=downloadrequests,verbs=get;list;watch;create;patch;delete
+//+kubebuilder:rbac:groups=apiextensions.k8s.io,resources=customresourcedefinitions,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+//+kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -112,6 +148,9 @@ func (r *BackupScheduleReconciler) Reconcile(
 ) (ctrl.Result, error) {
 	scheduleLogger := log.FromContext(ctx)
 
+	ctx, span := startSpan(ctx, "BackupSchedule reconcile", req.Namespace, req.Name)
+	defer span.End()
+
 	// velero doesn't delete expired backups if they are in FailedValidation
 	// workaround and delete expired or invalid validation backups them now
 	cleanupExpiredValidationBackups(ctx, req.Namespace, r.Client)
@@ -123,15 +162,17 @@ func (r *BackupScheduleReconciler) Reconcile(
 		// return if the backup configuration on this hub is not properly set
 		return result, err
 	}
+	// report the storage locations server-side encryption, it doesn't block the backups
+	r.verifyStorageEncryption(ctx, backupSchedule)
 
-	// validate the cron job schedule
-	errs := parseCronSchedule(ctx, backupSchedule)
+	// validate the cron job schedule, the backups TTL and the resources backup options
+	errs := getBackupScheduleValidationMessages(ctx, backupSchedule)
 	if len(errs) > 0 {
 		backupSchedule.Status.Phase = v1beta1.SchedulePhaseFailedValidation
 		backupSchedule.Status.LastMessage = strings.Join(errs, ",")
 
 		return ctrl.Result{}, errors.Wrap(
-			r.Client.Status().Update(ctx, backupSchedule),
+			r.updateStatus(ctx, backupSchedule),
 			updateStatusFailedMsg,
 		)
 	}
@@ -153,6 +194,25 @@ func (r *BackupScheduleReconciler) Reconcile(
 		return ctrl.Result{}, err
 	}
 
+	// paused schedule, remove the velero schedules so no backups are created;
+	// the velero schedules are created again when the schedule is resumed
+	if backupSchedule.Spec.Paused {
+		if backupSchedule.Status.Phase != v1beta1.SchedulePhasePaused {
+			scheduleLogger.Info("Pausing backup schedule", "name", backupSchedule.Name)
+		}
+		if err := r.deleteVeleroSchedules(ctx, backupSchedule, &veleroScheduleList); err != nil {
+			return ctrl.Result{}, err
+		}
+		backupSchedule.Status.Phase = v1beta1.SchedulePhasePaused
+		backupSchedule.Status.LastMessage = PausedPhaseMsg
+		r.setRestorableBackupSets(ctx, backupSchedule)
+
+		return ctrl.Result{}, errors.Wrap(
+			r.updateStatus(ctx, backupSchedule),
+			updateStatusFailedMsg,
+		)
+	}
+
 	// enforce backup collision only if this schedule was NOT created now ( current time - creation > 5)
 	// in this case ignore any collisions since the user had initiated this backup
 	if len(veleroScheduleList.Items) > 0 &&
@@ -174,8 +234,9 @@ func (r *BackupScheduleReconciler) Reconcile(
 
 			backupSchedule.Status.Phase = v1beta1.SchedulePhaseBackupCollision
 			backupSchedule.Status.LastMessage = msg
+			r.setRestorableBackupSets(ctx, backupSchedule)
 
-			err := r.Client.Status().Update(ctx, backupSchedule)
+			err := r.updateStatus(ctx, backupSchedule)
 
 			// delete schedules, don't generate new backups
 			for i := range veleroScheduleList.Items {
@@ -187,11 +248,75 @@ func (r *BackupScheduleReconciler) Reconcile(
 			return ctrl.Result{}, errors.Wrap(err, msg)
 		}
 	}
+	// the namespace contents schedules exist only when there are labeled namespaces to back up,
+	// they are not counted with the other velero schedules, see syncNamespaceContentsSchedules
+	namespaceContentsSchedules, otherSchedules := splitNamespaceContentsSchedules(
+		veleroScheduleList.Items)
+	veleroScheduleList.Items = otherSchedules
+
+	// storage locations where backups are written, besides the default storage location
+	storageLocations := r.getAdditionalStorageLocations(ctx, req.Namespace)
+
+	// delete the velero schedules this backup schedule doesn't create anymore,
+	// so they don't keep producing backups; the schedules writing to a storage location
+	// are deleted only once the storage location is deleted
+	scheduledLocations, listErr := r.getScheduledStorageLocations(ctx, req.Namespace,
+		veleroScheduleList.Items, storageLocations)
+	if listErr != nil {
+		scheduleLogger.Info("Failed to list storage locations, orphaned Velero schedules not deleted",
+			"error", listErr.Error())
+	} else if err := r.deleteOrphanedVeleroSchedules(ctx, backupSchedule,
+		&veleroScheduleList, scheduledLocations); err != nil {
+		return ctrl.Result{}, err
+	}
+
 	// no velero schedules, so create them
 	if len(veleroScheduleList.Items) == 0 {
-		clusterId, _ := getHubIdentification(ctx, r.DiscoveryClient, r.DynamicClient, r.RESTMapper)
-		err := r.initVeleroSchedules(ctx, backupSchedule, clusterId)
+		clusterId, _ := r.hubID.get(ctx, r.DynamicClient, r.RESTMapper)
+
+		// don't create backups if another hub has recently written backups
+		// to the same storage location
+		if msg := r.getCollisionWindowMessage(ctx, backupSchedule, clusterId); msg != "" {
+			scheduleLogger.Info(msg)
+			backupSchedule.Status.Phase = v1beta1.SchedulePhaseBackupCollision
+			backupSchedule.Status.LastMessage = msg
+			r.setRestorableBackupSets(ctx, backupSchedule)
+
+			return ctrl.Result{}, errors.Wrap(
+				r.updateStatus(ctx, backupSchedule),
+				updateStatusFailedMsg,
+			)
+		}
+
+		// adopt any existing velero schedules created with the operator names
+		// they are recreated on the next reconcile if they don't match this schedule
+		adopted, err := r.adoptVeleroSchedules(ctx, backupSchedule, clusterId)
 		if err != nil {
+			scheduleLogger.Error(err, "Failed to adopt Velero schedules")
+		}
+		if len(adopted) > 0 {
+			backupSchedule.Status.Phase = v1beta1.SchedulePhaseNew
+			backupSchedule.Status.LastMessage = fmt.Sprintf(AdoptedPhaseMsg, strings.Join(adopted, ", "))
+			return ctrl.Result{RequeueAfter: failureInterval}, errors.Wrap(
+				r.updateStatus(ctx, backupSchedule),
+				updateStatusFailedMsg,
+			)
+		}
+
+		initCtx, initSpan := startSpan(ctx, "create Velero schedules",
+			backupSchedule.Namespace, backupSchedule.Name)
+		err = r.initVeleroSchedules(initCtx, backupSchedule, clusterId, storageLocations)
+		if err == nil {
+			err = r.syncNamespaceContentsSchedules(initCtx, backupSchedule, clusterId,
+				storageLocations, namespaceContentsSchedules)
+		}
+		endSpan(initSpan, err)
+		if _, invalidRules := err.(*resourceRulesError); invalidRules {
+			// the included and excluded resources must be fixed by the user
+			scheduleLogger.Info(err.Error())
+			backupSchedule.Status.LastMessage = err.Error()
+			backupSchedule.Status.Phase = v1beta1.SchedulePhaseFailedValidation
+		} else if err != nil {
 			msg := fmt.Errorf(FailedPhaseMsg+": %v", err)
 			scheduleLogger.Error(err, err.Error())
 			backupSchedule.Status.LastMessage = msg.Error()
@@ -200,8 +325,8 @@ func (r *BackupScheduleReconciler) Reconcile(
 			backupSchedule.Status.LastMessage = NewPhaseMsg
 			backupSchedule.Status.Phase = v1beta1.SchedulePhaseNew
 		}
-		return ctrl.Result{RequeueAfter: collisionControlInterval}, errors.Wrap(
-			r.Client.Status().Update(ctx, backupSchedule),
+		return ctrl.Result{RequeueAfter: r.getReconcileInterval()}, errors.Wrap(
+			r.updateStatus(ctx, backupSchedule),
 			updateStatusFailedMsg,
 		)
 	}
@@ -210,25 +335,65 @@ func (r *BackupScheduleReconciler) Reconcile(
 	// delete velero schedules if their spec needs to be updated or any of them is missing
 	// New velero schedules will be created in the next reconcile triggerd by the deletion
 	if isScheduleSpecUpdated(&veleroScheduleList, backupSchedule) ||
+		(listErr == nil && isStorageLocationsUpdated(&veleroScheduleList, scheduledLocations)) ||
+		r.isManagedClustersSelectionUpdated(ctx, backupSchedule, &veleroScheduleList) ||
+		r.isExcludedAPIGroupsUpdated(ctx, backupSchedule, &veleroScheduleList) ||
 		len(veleroScheduleList.Items) < len(veleroScheduleNames) {
 		if err := r.deleteVeleroSchedules(ctx, backupSchedule, &veleroScheduleList); err != nil {
 			return ctrl.Result{}, err
 		}
 
-		return ctrl.Result{RequeueAfter: collisionControlInterval}, errors.Wrap(
-			r.Client.Status().Update(ctx, backupSchedule),
+		return ctrl.Result{RequeueAfter: r.getReconcileInterval()}, errors.Wrap(
+			r.updateStatus(ctx, backupSchedule),
 			updateStatusFailedMsg,
 		)
 	}
 
+	// create the backups requested out of the cron schedule
+	if backupSchedule.Spec.BackupNow {
+		if err := r.backupNow(ctx, backupSchedule,
+			append(veleroScheduleList.Items, namespaceContentsSchedules...)); err != nil {
+			return ctrl.Result{}, errors.Wrap(err, "could not reset the backupNow property")
+		}
+	}
+
+	// back up the contents of the namespaces labeled or unlabeled since the last reconcile
+	clusterId, _ := r.hubID.get(ctx, r.DynamicClient, r.RESTMapper)
+	if err := r.syncNamespaceContentsSchedules(ctx, backupSchedule, clusterId,
+		storageLocations, namespaceContentsSchedules); err != nil {
+		scheduleLogger.Error(err, "Failed to update the namespace contents Velero schedules")
+	}
+
+	// add the backup annotations to the backups created by the velero schedules
+	r.annotateScheduledBackups(ctx, append(veleroScheduleList.Items, namespaceContentsSchedules...))
+
 	// velero schedules already exist, update schedule status with latest velero schedules
 	for i := range veleroScheduleList.Items {
 		updateScheduleStatus(ctx, &veleroScheduleList.Items[i], backupSchedule)
 	}
 	setSchedulePhase(&veleroScheduleList, backupSchedule)
-
-	err := r.Client.Status().Update(ctx, backupSchedule)
-	return ctrl.Result{RequeueAfter: collisionControlInterval}, errors.Wrap(
+	if backupSchedule.Status.Phase == v1beta1.SchedulePhaseEnabled {
+		// report backups completed without any resources
+		if msg := r.getEmptyBackupsMessage(ctx, &veleroScheduleList, backupSchedule); msg != "" {
+			scheduleLogger.Info(msg)
+			backupSchedule.Status.LastMessage = backupSchedule.Status.LastMessage + ". " + msg
+		}
+		// warn if backups could expire before the operator can act on them
+		if msg := getShortTTLMessage(backupSchedule, r.getReconcileInterval()); msg != "" {
+			scheduleLogger.Info(msg)
+			backupSchedule.Status.LastMessage = backupSchedule.Status.LastMessage + ". " + msg
+		}
+	}
+	// report the backup sets which can be restored from the storage location
+	r.setRestorableBackupSets(ctx, backupSchedule)
+	// count the backups finished since the last reconcile, report them as events
+	// and show the last successful backup of each type
+	r.reportFinishedBackups(ctx, backupSchedule)
+	// skip the next scheduled backup if the backed up resources didn't change
+	requeueAfter := r.skipUnchangedBackups(ctx, backupSchedule, veleroScheduleList.Items)
+
+	err := r.updateStatus(ctx, backupSchedule)
+	return ctrl.Result{RequeueAfter: requeueAfter}, errors.Wrap(
 		err,
 		fmt.Sprintf(
 			"could not update status for schedule %s/%s",
@@ -238,6 +403,27 @@ func (r *BackupScheduleReconciler) Reconcile(
 	)
 }
 
+// returns the interval between the reconciles of the backup schedules
+func (r *BackupScheduleReconciler) getReconcileInterval() time.Duration {
+	if r.ReconcileInterval > 0 {
+		return r.ReconcileInterval
+	}
+	return collisionControlInterval
+}
+
+// set the status conditions for the current phase and update the schedule status
+func (r *BackupScheduleReconciler) updateStatus(
+	ctx context.Context,
+	backupSchedule *v1beta1.BackupSchedule,
+) error {
+	setBackupScheduleConditions(backupSchedule)
+	if r.DRStatus != nil {
+		r.DRStatus.update(backupSchedule,
+			r.hasValidStorageLocation(ctx, backupSchedule.Namespace), time.Now())
+	}
+	return r.Client.Status().Update(ctx, backupSchedule)
+}
+
 // validate backup configuration
 func (r *BackupScheduleReconciler) isValidateConfiguration(
 	ctx context.Context,
@@ -272,14 +458,35 @@ func (r *BackupScheduleReconciler) isValidateConfiguration(
 		return ctrl.Result{RequeueAfter: failureInterval},
 			validConfiguration,
 			errors.Wrap(
-				r.Client.Status().Update(ctx, backupSchedule),
+				r.updateStatus(ctx, backupSchedule),
 				msg,
 			)
 	}
 
+	// don't create schedules if velero is not installed; the storage locations
+	// can't be found in this case, report the missing velero CRDs instead
+	veleroNotInstalledMsg := getVeleroNotInstalledMessage(ctx, r.DiscoveryClient)
+	setVeleroNotInstalledCondition(backupSchedule, veleroNotInstalledMsg)
+	if veleroNotInstalledMsg != "" {
+		scheduleLogger.Info(veleroNotInstalledMsg)
+
+		backupSchedule.Status.Phase = v1beta1.SchedulePhaseFailedValidation
+		backupSchedule.Status.LastMessage = veleroNotInstalledMsg
+
+		// retry after failureInterval
+		return ctrl.Result{RequeueAfter: failureInterval},
+			validConfiguration,
+			errors.Wrap(
+				r.updateStatus(ctx, backupSchedule),
+				veleroNotInstalledMsg,
+			)
+	}
+
 	// don't create schedules if backup storage location doesn't exist or is not avaialble
+	// only the storage locations in the velero namespace are used, if set
 	veleroStorageLocations := &veleroapi.BackupStorageLocationList{}
-	if err := r.Client.List(ctx, veleroStorageLocations, &client.ListOptions{}); err != nil ||
+	if err := r.Client.List(ctx, veleroStorageLocations,
+		client.InNamespace(backupSchedule.Spec.VeleroNamespace)); err != nil ||
 		veleroStorageLocations == nil || len(veleroStorageLocations.Items) == 0 {
 
 		msg := "velero.io.BackupStorageLocation resources not found. " +
@@ -293,19 +500,18 @@ func (r *BackupScheduleReconciler) isValidateConfiguration(
 		return ctrl.Result{RequeueAfter: failureInterval},
 			validConfiguration,
 			errors.Wrap(
-				r.Client.Status().Update(ctx, backupSchedule),
+				r.updateStatus(ctx, backupSchedule),
 				msg,
 			)
 	}
 
 	// look for available VeleroStorageLocation
 	// and keep track of the velero oadp namespace
-	isValidStorageLocation, veleroNamespace := isValidStorageLocationDefined(
-		*veleroStorageLocations,
-	)
+	validStorageLocations := getValidStorageLocations(ctx, *veleroStorageLocations,
+		r.AcceptUnownedStorageLocations)
 
 	// if no valid storage location found wait for valid value
-	if !isValidStorageLocation {
+	if len(validStorageLocations) == 0 {
 		msg := "Backup storage location is not available. " +
 			"Check velero.io.BackupStorageLocation and validate storage credentials."
 		scheduleLogger.Info(msg)
@@ -317,18 +523,18 @@ func (r *BackupScheduleReconciler) isValidateConfiguration(
 		return ctrl.Result{RequeueAfter: failureInterval},
 			validConfiguration,
 			errors.Wrap(
-				r.Client.Status().Update(ctx, backupSchedule),
+				r.updateStatus(ctx, backupSchedule),
 				msg,
 			)
 	}
 
 	// return error if the cluster restore file is not in the same namespace with velero
-	if veleroNamespace != req.Namespace {
+	if len(getStorageLocationsInNamespace(validStorageLocations, req.Namespace)) == 0 {
 		msg := fmt.Sprintf(
 			"Schedule resource [%s/%s] must be created in the velero namespace [%s]",
 			req.Namespace,
 			req.Name,
-			veleroNamespace,
+			getVeleroNamespace(backupSchedule.Spec.VeleroNamespace, validStorageLocations),
 		)
 		scheduleLogger.Info(msg)
 
@@ -337,20 +543,62 @@ func (r *BackupScheduleReconciler) isValidateConfiguration(
 
 		return ctrl.Result{},
 			validConfiguration, errors.Wrap(
-				r.Client.Status().Update(ctx, backupSchedule),
+				r.updateStatus(ctx, backupSchedule),
 				msg,
 			)
 	}
 
+	// backups can't be created in read-only storage locations
+	if msg := getReadOnlyStorageLocationsMessage(
+		getStorageLocationsInNamespace(validStorageLocations, req.Namespace)); msg != "" {
+		scheduleLogger.Info(msg)
+
+		backupSchedule.Status.Phase = v1beta1.SchedulePhaseFailedValidation
+		backupSchedule.Status.LastMessage = msg
+
+		// retry after failureInterval
+		return ctrl.Result{RequeueAfter: failureInterval},
+			validConfiguration,
+			errors.Wrap(
+				r.updateStatus(ctx, backupSchedule),
+				msg,
+			)
+	}
+
+	// check the storage location object store responds in time
+	if r.StorageLocationProbeTimeout > 0 {
+		probeMsg, err := probeStorageLocations(ctx, veleroStorageLocations.Items,
+			req.Namespace, r.StorageLocationProbeTimeout)
+		backupSchedule.Status.StorageLocationProbe = probeMsg
+		if err != nil {
+			msg := "Backup storage location is not reachable: " + err.Error()
+			scheduleLogger.Info(msg)
+
+			backupSchedule.Status.StorageLocationProbe = err.Error()
+			backupSchedule.Status.Phase = v1beta1.SchedulePhaseFailedValidation
+			backupSchedule.Status.LastMessage = msg
+
+			// retry after failureInterval
+			return ctrl.Result{RequeueAfter: failureInterval},
+				validConfiguration,
+				errors.Wrap(
+					r.updateStatus(ctx, backupSchedule),
+					msg,
+				)
+		}
+	}
+
 	validConfiguration = true
 	return ctrl.Result{}, validConfiguration, nil
 }
 
 // create velero.io.Schedule resource for each resource type that needs backup
+// and for each additional storage location
 func (r *BackupScheduleReconciler) initVeleroSchedules(
 	ctx context.Context,
 	backupSchedule *v1beta1.BackupSchedule,
 	clusterId string,
+	storageLocations []storageLocationRef,
 ) error {
 	scheduleLogger := log.FromContext(ctx)
 
@@ -369,9 +617,29 @@ func (r *BackupScheduleReconciler) initVeleroSchedules(
 		swapF(4, 5)
 	}
 
+	// velero runs a backup right away for a schedule without a last backup time;
+	// when the schedule is resumed, wait for the cron expression to fire
+	var lastBackup *metav1.Time
+	if backupSchedule.Status.Phase == v1beta1.SchedulePhasePaused {
+		now := metav1.Now()
+		lastBackup = &now
+	}
+
 	// add any missing labels
 	prepareForBackup(ctx, r.Client)
 
+	// the managed clusters not selected by the backup schedule are not backed up
+	unselectedClusters, err := getUnselectedManagedClusters(ctx, backupSchedule, r.DynamicClient)
+	if err != nil {
+		return err
+	}
+	if err := setManagedClustersBackupExclusion(ctx, r.DynamicClient, unselectedClusters); err != nil {
+		return err
+	}
+
+	// resources both included and excluded, for each Velero schedule
+	resourceRulesConflicts := []string{}
+
 	// loop through schedule names to create a Velero schedule per type
 	for _, scheduleKey := range scheduleKeys {
 		veleroScheduleIdentity := types.NamespacedName{
@@ -392,8 +660,12 @@ func (r *BackupScheduleReconciler) initVeleroSchedules(
 		labels[BackupScheduleTypeLabel] = string(scheduleKey)
 		// set cluster uid
 		labels[BackupScheduleClusterLabel] = clusterId
+		// add the user defined labels and annotations, copied by velero to the backups
+		labels = mergeBackupMetadata(labels, backupSchedule.Spec.BackupLabels)
+		annotations := mergeBackupMetadata(nil, backupSchedule.Spec.BackupAnnotations)
 
 		veleroSchedule.SetLabels(labels)
+		veleroSchedule.SetAnnotations(annotations)
 
 		// create backup based on resource type
 		veleroBackupTemplate := &veleroapi.BackupSpec{}
@@ -401,6 +673,7 @@ func (r *BackupScheduleReconciler) initVeleroSchedules(
 		switch scheduleKey {
 		case ManagedClusters:
 			setManagedClustersBackupInfo(ctx, veleroBackupTemplate, r.Client)
+			setUnselectedManagedClustersBackupInfo(veleroBackupTemplate, unselectedClusters)
 		case Credentials:
 			setCredsBackupInfo(ctx, veleroBackupTemplate, r.Client, string(UserSecret))
 		case CredentialsHive:
@@ -411,7 +684,12 @@ func (r *BackupScheduleReconciler) initVeleroSchedules(
 			setResourcesBackupInfo(ctx, veleroBackupTemplate, resourcesToBackup,
 				backupSchedule.Namespace, r.Client)
 		case ResourcesGeneric:
-			setGenericResourcesBackupInfo(ctx, veleroBackupTemplate, r.Client)
+			setGenericResourcesBackupInfo(ctx, veleroBackupTemplate, backupSchedule, r.Client)
+			veleroBackupTemplate.ExcludedResources = append(veleroBackupTemplate.ExcludedResources,
+				getExcludedAPIGroupsResources(ctx, r.DiscoveryClient,
+					backupSchedule.Spec.ExcludedAPIGroups)...)
+			veleroBackupTemplate.ExcludedResources = normalizeExcludedResources(ctx,
+				r.DiscoveryClient, veleroBackupTemplate.ExcludedResources)
 		case ValidationSchedule:
 			veleroBackupTemplate = setValidationBackupInfo(
 				ctx,
@@ -420,36 +698,166 @@ func (r *BackupScheduleReconciler) initVeleroSchedules(
 				r.Client,
 			)
 		}
+		if scheduleKey == Resources || scheduleKey == ResourcesGeneric {
+			setExcludedNamespaces(veleroBackupTemplate, backupSchedule)
+		}
+
+		// the excluded resources take precedence over the included resources
+		if conflicts := getResourceRulesConflicts(veleroBackupTemplate); len(conflicts) > 0 {
+			scheduleLogger.Info("Resources both included and excluded, they are excluded from backup",
+				"schedule", veleroSchedule.Name, "resources", conflicts)
+			if err := resolveResourceRulesConflicts(veleroBackupTemplate, conflicts); err != nil {
+				return &resourceRulesError{fmt.Errorf("schedule %s: %v", veleroSchedule.Name, err)}
+			}
+			resourceRulesConflicts = append(resourceRulesConflicts,
+				fmt.Sprintf("%s [%s]", veleroSchedule.Name, strings.Join(conflicts, ", ")))
+		}
 
 		veleroSchedule.Spec.Template = *veleroBackupTemplate
-		veleroSchedule.Spec.Schedule = backupSchedule.Spec.VeleroSchedule
+		veleroSchedule.Spec.Template.SnapshotVolumes = getSnapshotVolumes(backupSchedule, scheduleKey)
+		veleroSchedule.Spec.Template.Hooks = getBackupHooks(backupSchedule, scheduleKey)
+		veleroSchedule.Spec.Schedule = getBackupTypeCronSchedule(backupSchedule, scheduleKey, clusterId)
+		veleroSchedule.Status.LastBackup = lastBackup
 		if backupSchedule.Spec.VeleroTTL.Duration != 0 && scheduleKey != ValidationSchedule {
 			// TTL for a validation backup is already set using the cron job interval
 			veleroSchedule.Spec.Template.TTL = backupSchedule.Spec.VeleroTTL
 		}
 
-		if err := ctrl.SetControllerReference(backupSchedule, veleroSchedule, r.Scheme); err != nil {
-			return err
+		veleroSchedules := []*veleroapi.Schedule{veleroSchedule}
+		if scheduleKey != ValidationSchedule {
+			// write the same backups to the additional storage locations
+			// the validation backup only checks the default storage location schedules
+			for _, storageLocation := range storageLocations {
+				locationSchedule := &veleroapi.Schedule{}
+				locationSchedule.Name = getStorageLocationScheduleName(veleroSchedule.Name,
+					storageLocation.Name)
+				locationSchedule.Namespace = veleroSchedule.Namespace
+				locationSchedule.SetLabels(labels)
+				locationSchedule.SetAnnotations(annotations)
+				locationSchedule.Spec = *veleroSchedule.Spec.DeepCopy()
+				locationSchedule.Spec.Template.StorageLocation = storageLocation.Name
+				locationSchedule.Status.LastBackup = lastBackup
+				veleroSchedules = append(veleroSchedules, locationSchedule)
+			}
 		}
 
-		err := r.Create(ctx, veleroSchedule, &client.CreateOptions{})
-		if err != nil {
-			scheduleLogger.Error(
-				err,
-				"Error in creating velero.io.Schedule",
-				"name", veleroScheduleIdentity.Name,
-				"namespace", veleroScheduleIdentity.Namespace,
-			)
-			return err
+		for i := range veleroSchedules {
+			if err := r.createVeleroSchedule(ctx, backupSchedule, veleroSchedules[i]); err != nil {
+				return err
+			}
 		}
-		scheduleLogger.Info(
-			"Velero schedule created",
+
+		// set veleroSchedule in backupSchedule status
+		setVeleroScheduleInStatus(scheduleKey, veleroSchedule, backupSchedule)
+	}
+	setResourceRulesConflictCondition(backupSchedule, resourceRulesConflicts)
+	return nil
+}
+
+// create the velero schedule, owned by the backup schedule, with a server-side apply;
+// an existing velero schedule with the same name is updated, unless its spec hash
+// annotation shows it was already applied with the same spec
+func (r *BackupScheduleReconciler) createVeleroSchedule(
+	ctx context.Context,
+	backupSchedule *v1beta1.BackupSchedule,
+	veleroSchedule *veleroapi.Schedule,
+) error {
+	scheduleLogger := log.FromContext(ctx)
+
+	if err := ctrl.SetControllerReference(backupSchedule, veleroSchedule, r.Scheme); err != nil {
+		return err
+	}
+
+	specHash, err := getVeleroScheduleSpecHash(veleroSchedule)
+	if err != nil {
+		return err
+	}
+	existing := veleroapi.Schedule{}
+	getErr := r.Get(ctx, client.ObjectKeyFromObject(veleroSchedule), &existing)
+	if getErr != nil && !k8serr.IsNotFound(getErr) {
+		return getErr
+	}
+	if getErr == nil && existing.GetAnnotations()[VeleroScheduleSpecHashAnnotation] == specHash {
+		// nothing changed since the last apply
+		return nil
+	}
+	// the annotations map may be shared with the other storage location schedules
+	annotations := map[string]string{}
+	for key, value := range veleroSchedule.GetAnnotations() {
+		annotations[key] = value
+	}
+	annotations[VeleroScheduleSpecHashAnnotation] = specHash
+	veleroSchedule.SetAnnotations(annotations)
+
+	_, createSpan := startSpan(ctx, "create Velero schedule",
+		veleroSchedule.Namespace, veleroSchedule.Name)
+	err = applyVeleroResource(ctx, r.Client, veleroSchedule)
+	endSpan(createSpan, err)
+	if err != nil {
+		scheduleLogger.Error(
+			err,
+			"Error in applying velero.io.Schedule",
 			"name", veleroSchedule.Name,
 			"namespace", veleroSchedule.Namespace,
 		)
+		return err
+	}
+	msg := "Velero schedule created"
+	if getErr == nil {
+		msg = "Velero schedule updated"
+	}
+	scheduleLogger.Info(
+		msg,
+		"name", veleroSchedule.Name,
+		"namespace", veleroSchedule.Namespace,
+	)
+	return nil
+}
 
-		// set veleroSchedule in backupSchedule status
-		setVeleroScheduleInStatus(scheduleKey, veleroSchedule, backupSchedule)
+// create, update or delete the velero schedules backing up the contents of the labeled namespaces,
+// for the default storage location and for each additional storage location;
+// the namespaces are included by name, so the schedules are updated when a namespace is labeled
+// or unlabeled, and deleted when there is no namespace left to back up: Velero would back up
+// all the namespaces for an empty list of included namespaces
+func (r *BackupScheduleReconciler) syncNamespaceContentsSchedules(
+	ctx context.Context,
+	backupSchedule *v1beta1.BackupSchedule,
+	clusterId string,
+	storageLocations []storageLocationRef,
+	existingSchedules []veleroapi.Schedule,
+) error {
+
+	namespaces, err := getNamespaceContentsNamespaces(ctx, backupSchedule, r.DynamicClient)
+	if err != nil {
+		// keep the existing schedules, the namespaces are listed again on the next reconcile
+		return err
+	}
+
+	scheduleNames := []string{}
+	if len(namespaces) > 0 {
+		veleroSchedules := getNamespaceContentsSchedules(backupSchedule, clusterId,
+			namespaces, storageLocations)
+		for i := range veleroSchedules {
+			if err := r.createVeleroSchedule(ctx, backupSchedule, veleroSchedules[i]); err != nil {
+				return err
+			}
+			scheduleNames = append(scheduleNames, veleroSchedules[i].Name)
+		}
+	}
+
+	for i := range existingSchedules {
+		veleroSchedule := &existingSchedules[i]
+		if findValue(scheduleNames, veleroSchedule.Name) {
+			continue
+		}
+		if err := r.Delete(ctx, veleroSchedule); err != nil && !k8serr.IsNotFound(err) {
+			return err
+		}
+		log.FromContext(ctx).Info(
+			"Deleted namespace contents Velero schedule",
+			"name", veleroSchedule.Name,
+			"namespace", veleroSchedule.Namespace,
+		)
 	}
 	return nil
 }
@@ -533,6 +941,51 @@ func (r *BackupScheduleReconciler) deleteVeleroSchedules(
 	return nil
 }
 
+// delete the velero schedules owned by this BackupSchedule but not created by it anymore,
+// and remove them from the schedules list
+func (r *BackupScheduleReconciler) deleteOrphanedVeleroSchedules(
+	ctx context.Context,
+	backupSchedule *v1beta1.BackupSchedule,
+	schedules *veleroapi.ScheduleList,
+	storageLocations []storageLocationRef,
+) error {
+	scheduleLogger := log.FromContext(ctx)
+
+	orphaned := getOrphanedVeleroSchedules(schedules.Items, backupSchedule, storageLocations)
+	if len(orphaned) == 0 {
+		return nil
+	}
+
+	orphanedNames := []string{}
+	for i := range orphaned {
+		veleroSchedule := &orphaned[i]
+		if err := r.Delete(ctx, veleroSchedule); err != nil && !k8serr.IsNotFound(err) {
+			scheduleLogger.Error(
+				err,
+				"Error in deleting orphaned Velero schedule",
+				"name", veleroSchedule.Name,
+				"namespace", veleroSchedule.Namespace,
+			)
+			return err
+		}
+		scheduleLogger.Info(
+			"Deleted orphaned Velero schedule",
+			"name", veleroSchedule.Name,
+			"namespace", veleroSchedule.Namespace,
+		)
+		orphanedNames = append(orphanedNames, veleroSchedule.Name)
+	}
+
+	remaining := []veleroapi.Schedule{}
+	for i := range schedules.Items {
+		if !findValue(orphanedNames, schedules.Items[i].Name) {
+			remaining = append(remaining, schedules.Items[i])
+		}
+	}
+	schedules.Items = remaining
+	return nil
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *BackupScheduleReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	if err := mgr.GetFieldIndexer().IndexField(
@@ -554,14 +1007,163 @@ func (r *BackupScheduleReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		return err
 	}
 
+	// the discovered server resources change when a CRD is created or deleted
+	crd := &metav1.PartialObjectMetadata{}
+	crd.SetGroupVersionKind(schema.GroupVersionKind{
+		Group:   "apiextensions.k8s.io",
+		Version: "v1",
+		Kind:    "CustomResourceDefinition",
+	})
+
+	// ignore updates to the resources status, in which case metadata.Generation does not change
+	ignoreStatusUpdates := predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			return e.ObjectOld.GetGeneration() != e.ObjectNew.GetGeneration()
+		},
+	}
+	// the backup schedules are validated again when a storage location becomes
+	// available or unavailable, so they recover without waiting for the next requeue
+	storageLocationPhaseChanged := predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldLocation, okOld := e.ObjectOld.(*veleroapi.BackupStorageLocation)
+			newLocation, okNew := e.ObjectNew.(*veleroapi.BackupStorageLocation)
+			return okOld && okNew && oldLocation.Status.Phase != newLocation.Status.Phase
+		},
+	}
+
+	// the backup schedule is reconciled when a backup phase changes
+	backupPhaseChanged := predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldBackup, okOld := e.ObjectOld.(*veleroapi.Backup)
+			newBackup, okNew := e.ObjectNew.(*veleroapi.Backup)
+			return okOld && okNew && oldBackup.Status.Phase != newBackup.Status.Phase
+		},
+	}
+	// only the in progress backups status is updated when only a backup progress changes
+	backupProgressChanged := predicate.Funcs{
+		CreateFunc: func(event.CreateEvent) bool { return false },
+		DeleteFunc: func(event.DeleteEvent) bool { return false },
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldBackup, okOld := e.ObjectOld.(*veleroapi.Backup)
+			newBackup, okNew := e.ObjectNew.(*veleroapi.Backup)
+			return okOld && okNew && oldBackup.Status.Phase == newBackup.Status.Phase &&
+				!reflect.DeepEqual(oldBackup.Status.Progress, newBackup.Status.Progress)
+		},
+		GenericFunc: func(event.GenericEvent) bool { return false },
+	}
+	progressController, err := controller.New("backupprogress", mgr, controller.Options{
+		Reconciler: &backupProgressReconciler{Client: mgr.GetClient()},
+	})
+	if err != nil {
+		return err
+	}
+	if err := progressController.Watch(
+		&source.Kind{Type: &veleroapi.Backup{}},
+		handler.EnqueueRequestsFromMapFunc(getBackupBackupSchedule),
+		backupProgressChanged,
+	); err != nil {
+		return err
+	}
+
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&v1beta1.BackupSchedule{}).
-		Owns(&veleroapi.Schedule{}).
-		WithEventFilter(predicate.Funcs{
-			UpdateFunc: func(e event.UpdateEvent) bool {
-				// Ignore updates to CR status in which case metadata.Generation does not change
-				return e.ObjectOld.GetGeneration() != e.ObjectNew.GetGeneration()
+		For(&v1beta1.BackupSchedule{}, builder.WithPredicates(ignoreStatusUpdates)).
+		Owns(&veleroapi.Schedule{}, builder.WithPredicates(ignoreStatusUpdates)).
+		Watches(
+			&source.Kind{Type: &veleroapi.Backup{}},
+			handler.EnqueueRequestsFromMapFunc(getBackupBackupSchedule),
+			builder.WithPredicates(backupPhaseChanged),
+		).
+		Watches(
+			&source.Kind{Type: &veleroapi.BackupStorageLocation{}},
+			handler.EnqueueRequestsFromMapFunc(r.getStorageLocationBackupSchedules),
+			builder.WithPredicates(storageLocationPhaseChanged),
+		).
+		Watches(
+			&source.Kind{Type: crd},
+			handler.Funcs{
+				CreateFunc: func(event.CreateEvent, workqueue.RateLimitingInterface) {
+					invalidateDiscoveryCache()
+				},
+				DeleteFunc: func(event.DeleteEvent, workqueue.RateLimitingInterface) {
+					invalidateDiscoveryCache()
+				},
 			},
-		}).
+			builder.OnlyMetadata,
+		).
 		Complete(r)
 }
+
+// backupProgressReconciler updates the in progress backups shown by the backup schedule status
+// when the progress of a backup changes; Velero updates the progress of a running backup often,
+// so the backup schedule is not fully reconciled for these updates
+type backupProgressReconciler struct {
+	client.Client
+}
+
+// Reconcile sets the in progress backups of the backup schedule, created by its velero schedules
+func (r *backupProgressReconciler) Reconcile(
+	ctx context.Context,
+	req ctrl.Request,
+) (ctrl.Result, error) {
+
+	backupSchedule := &v1beta1.BackupSchedule{}
+	if err := r.Get(ctx, req.NamespacedName, backupSchedule); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	backups := veleroapi.BackupList{}
+	if err := r.List(ctx, &backups, client.InNamespace(backupSchedule.Namespace),
+		client.MatchingLabels{BackupScheduleNameLabel: backupSchedule.Name}); err != nil {
+		return ctrl.Result{}, err
+	}
+	inProgressBackups := getInProgressBackupsStatus(backups.Items)
+	if len(inProgressBackups) == 0 && len(backupSchedule.Status.InProgressBackups) == 0 ||
+		reflect.DeepEqual(inProgressBackups, backupSchedule.Status.InProgressBackups) {
+		return ctrl.Result{}, nil
+	}
+
+	// patch only the in progress backups, the other status fields are set by the BackupSchedule reconcile
+	patch := client.MergeFrom(backupSchedule.DeepCopy())
+	backupSchedule.Status.InProgressBackups = inProgressBackups
+	return ctrl.Result{}, r.Status().Patch(ctx, backupSchedule, patch)
+}
+
+// returns the request to reconcile the backup schedule which created the backup, if any,
+// using the backup schedule name label copied by velero from the velero schedule
+func getBackupBackupSchedule(backup client.Object) []reconcile.Request {
+
+	backupScheduleName := backup.GetLabels()[BackupScheduleNameLabel]
+	if backupScheduleName == "" {
+		return nil
+	}
+	return []reconcile.Request{
+		{
+			NamespacedName: types.NamespacedName{
+				Name:      backupScheduleName,
+				Namespace: backup.GetNamespace(),
+			},
+		},
+	}
+}
+
+// returns the requests to reconcile the backup schedules in the storage location namespace
+func (r *BackupScheduleReconciler) getStorageLocationBackupSchedules(
+	storageLocation client.Object,
+) []reconcile.Request {
+
+	backupSchedules := v1beta1.BackupScheduleList{}
+	if err := r.List(context.Background(), &backupSchedules,
+		client.InNamespace(storageLocation.GetNamespace())); err != nil {
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(backupSchedules.Items))
+	for i := range backupSchedules.Items {
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      backupSchedules.Items[i].Name,
+				Namespace: backupSchedules.Items[i].Namespace,
+			},
+		})
+	}
+	return requests
+}
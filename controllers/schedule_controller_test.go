@@ -12,6 +12,8 @@ import (
 
 	v1beta1 "github.com/stolostron/cluster-backup-operator/api/v1beta1"
 	corev1 "k8s.io/api/core/v1"
+	k8serr "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	clusterv1 "open-cluster-management.io/api/cluster/v1"
@@ -540,6 +542,18 @@ var _ = Describe("BackupSchedule controller", func() {
 				createdBackupSchedule.Status.VeleroScheduleResources.Spec.Template.TTL,
 			).Should(Equal(metav1.Duration{Duration: time.Hour * 72}))
 
+			By("the resources and credentials backups should not snapshot volumes")
+			for _, veleroSchedule := range []*veleroapi.Schedule{
+				createdBackupSchedule.Status.VeleroScheduleResources,
+				createdBackupSchedule.Status.VeleroScheduleCredentials,
+			} {
+				Expect(veleroSchedule.Spec.Template.SnapshotVolumes).NotTo(BeNil())
+				Expect(*veleroSchedule.Spec.Template.SnapshotVolumes).Should(BeFalse())
+			}
+			Expect(
+				createdBackupSchedule.Status.VeleroScheduleManagedClusters.Spec.Template.SnapshotVolumes,
+			).Should(BeNil())
+
 			// update schedule, it should trigger velero schedules deletion
 			createdBackupSchedule.Spec.VeleroTTL = metav1.Duration{Duration: time.Hour * 150}
 			Expect(
@@ -731,6 +745,45 @@ var _ = Describe("BackupSchedule controller", func() {
 				createdBackupScheduleACM.Status.LastMessage,
 			).Should(ContainSubstring("must be created in the velero namespace"))
 
+			// velero namespace set to the acm ns, the storage locations
+			// in the velero ns are not used
+			pinnedBackupName := backupScheduleName + "-pinned"
+			pinnedBackupScheduleACM := v1beta1.BackupSchedule{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "cluster.open-cluster-management.io/v1beta1",
+					Kind:       "BackupSchedule",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      pinnedBackupName,
+					Namespace: acmNamespaceName,
+				},
+				Spec: v1beta1.BackupScheduleSpec{
+					VeleroSchedule:  backupSchedule,
+					VeleroTTL:       metav1.Duration{Duration: time.Hour * 72},
+					VeleroNamespace: acmNamespaceName,
+				},
+			}
+			Expect(k8sClient.Create(ctx, &pinnedBackupScheduleACM)).Should(Succeed())
+
+			By(
+				"backup schedule pinned to the acm ns should not find any storage location",
+			)
+			backupLookupKeyPinned := types.NamespacedName{
+				Name:      pinnedBackupName,
+				Namespace: acmNamespaceName,
+			}
+			createdBackupSchedulePinned := v1beta1.BackupSchedule{}
+			Eventually(func() bool {
+				err := k8sClient.Get(ctx, backupLookupKeyPinned, &createdBackupSchedulePinned)
+				if err != nil {
+					return false
+				}
+				return createdBackupSchedulePinned.Status.Phase == v1beta1.SchedulePhaseFailedValidation
+			}, timeout, interval).Should(BeTrue())
+			Expect(
+				createdBackupSchedulePinned.Status.LastMessage,
+			).Should(ContainSubstring("velero.io.BackupStorageLocation resources not found"))
+
 			// backup with invalid cron job schedule, should fail validation
 			invalidCronExpBackupName := backupScheduleName + "-invalid-cron-exp"
 			invalidCronExpBackupScheduleACM := v1beta1.BackupSchedule{
@@ -879,6 +932,9 @@ var _ = Describe("BackupSchedule controller", func() {
 
 					Expect(findValue(veleroSchedule.Spec.Template.ExcludedResources, //already in cluster resources backup
 						"klusterletaddonconfig.agent.open-cluster-management.io")).Should(BeTrue())
+
+					Expect(findValue(veleroSchedule.Spec.Template.ExcludedResources, //transient resources
+						"replicaset.apps")).Should(BeTrue())
 				}
 			}
 
@@ -1078,4 +1134,1612 @@ var _ = Describe("BackupSchedule controller", func() {
 		)
 	})
 
-})
+	Context("When creating a BackupSchedule with excluded namespaces", func() {
+		var newVeleroNamespace = "velero-ns-excluded"
+		var newAcmNamespace = "acm-ns-excluded"
+		var newChartsv1NSName = "acm-channel-ns-excluded"
+
+		BeforeEach(func() {
+			clusterPoolNS = nil
+			clusterDeploymentNS = nil
+			veleroBackups = []veleroapi.Backup{}
+			chartsv1NS = &corev1.Namespace{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "v1",
+					Kind:       "Namespace",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name: newChartsv1NSName,
+				},
+			}
+			acmNamespace = &corev1.Namespace{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "v1",
+					Kind:       "Namespace",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name: newAcmNamespace,
+				},
+			}
+			veleroNamespace = &corev1.Namespace{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "v1",
+					Kind:       "Namespace",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name: newVeleroNamespace,
+				},
+			}
+			channels = []chnv1.Channel{
+				{
+					TypeMeta: metav1.TypeMeta{
+						APIVersion: "apps.open-cluster-management.io/v1",
+						Kind:       "Channel",
+					},
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "charts-v1",
+						Namespace: newChartsv1NSName,
+					},
+					Spec: chnv1.ChannelSpec{
+						Type:     chnv1.ChannelTypeHelmRepo,
+						Pathname: "http://test.svc.cluster.local:3000/charts",
+					},
+				},
+			}
+			backupStorageLocation = &veleroapi.BackupStorageLocation{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "velero/v1",
+					Kind:       "BackupStorageLocation",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "default-excluded",
+					Namespace: newVeleroNamespace,
+					OwnerReferences: []metav1.OwnerReference{
+						{
+							APIVersion: "oadp.openshift.io/v1alpha1",
+							Kind:       "Velero",
+							Name:       "velero-instnace",
+							UID:        "fed287da-02ea-4c83-a7f8-906ce662451a",
+						},
+					},
+				},
+				Spec: veleroapi.BackupStorageLocationSpec{
+					AccessMode: "ReadWrite",
+					StorageType: veleroapi.StorageType{
+						ObjectStorage: &veleroapi.ObjectStorageLocation{
+							Bucket: "velero-backup-acm-dr",
+							Prefix: "velero",
+						},
+					},
+					Provider: "aws",
+				},
+			}
+		})
+		It("Should exclude the namespaces from the resources backups", func() {
+			Expect(k8sClient.Create(ctx, backupStorageLocation)).Should(Succeed())
+			backupStorageLocation.Status.Phase = veleroapi.BackupStorageLocationPhaseAvailable
+			Expect(k8sClient.Status().Update(ctx, backupStorageLocation)).Should(Succeed())
+
+			excludedNamespaces := []string{"tenant-1", "tenant-2"}
+			rhacmBackupSchedule := v1beta1.BackupSchedule{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "cluster.open-cluster-management.io/v1beta1",
+					Kind:       "BackupSchedule",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      backupScheduleName + "-excluded",
+					Namespace: newVeleroNamespace,
+				},
+				Spec: v1beta1.BackupScheduleSpec{
+					VeleroSchedule:     backupSchedule,
+					VeleroTTL:          metav1.Duration{Duration: time.Hour * 72},
+					ExcludedNamespaces: excludedNamespaces,
+				},
+			}
+			Expect(k8sClient.Create(ctx, &rhacmBackupSchedule)).Should(Succeed())
+
+			veleroSchedules := veleroapi.ScheduleList{}
+			Eventually(func() int {
+				if err := k8sClient.List(ctx, &veleroSchedules,
+					client.InNamespace(newVeleroNamespace)); err != nil {
+					return 0
+				}
+				return len(veleroSchedules.Items)
+			}, timeout, interval).Should(BeNumerically(">", 0))
+
+			for _, veleroSchedule := range veleroSchedules.Items {
+				template := veleroSchedule.Spec.Template
+				switch veleroSchedule.Name {
+				case veleroScheduleNames[Resources], veleroScheduleNames[ResourcesGeneric]:
+					for _, namespace := range excludedNamespaces {
+						Expect(findValue(template.ExcludedNamespaces, namespace)).Should(BeTrue())
+						Expect(findValue(template.IncludedNamespaces, namespace)).Should(BeFalse())
+					}
+				default:
+					for _, namespace := range excludedNamespaces {
+						Expect(findValue(template.ExcludedNamespaces, namespace)).Should(BeFalse())
+					}
+				}
+			}
+
+			// an invalid namespace name fails the validation
+			createdSchedule := v1beta1.BackupSchedule{}
+			scheduleLookupKey := types.NamespacedName{
+				Name:      backupScheduleName + "-excluded",
+				Namespace: newVeleroNamespace,
+			}
+			Expect(k8sClient.Get(ctx, scheduleLookupKey, &createdSchedule)).Should(Succeed())
+			createdSchedule.Spec.ExcludedNamespaces = []string{"Tenant_1"}
+			Expect(k8sClient.Update(ctx, &createdSchedule)).Should(Succeed())
+			Eventually(func() v1beta1.SchedulePhase {
+				err := k8sClient.Get(ctx, scheduleLookupKey, &createdSchedule)
+				Expect(err).NotTo(HaveOccurred())
+				return createdSchedule.Status.Phase
+			}, timeout, interval).Should(BeEquivalentTo(v1beta1.SchedulePhaseFailedValidation))
+			Expect(createdSchedule.Status.LastMessage).Should(ContainSubstring(
+				"invalid excludedNamespaces value \"Tenant_1\""))
+		})
+	})
+
+	Context("When creating a BackupSchedule with a managed clusters label selector", func() {
+		var newVeleroNamespace = "velero-ns-mc-selector"
+		var newAcmNamespace = "acm-ns-mc-selector"
+		var newChartsv1NSName = "acm-channel-ns-mc-selector"
+
+		BeforeEach(func() {
+			clusterPoolNS = nil
+			clusterDeploymentNS = nil
+			veleroBackups = []veleroapi.Backup{}
+			chartsv1NS = &corev1.Namespace{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "v1",
+					Kind:       "Namespace",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name: newChartsv1NSName,
+				},
+			}
+			acmNamespace = &corev1.Namespace{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "v1",
+					Kind:       "Namespace",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name: newAcmNamespace,
+				},
+			}
+			veleroNamespace = &corev1.Namespace{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "v1",
+					Kind:       "Namespace",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name: newVeleroNamespace,
+				},
+			}
+			channels = []chnv1.Channel{
+				{
+					TypeMeta: metav1.TypeMeta{
+						APIVersion: "apps.open-cluster-management.io/v1",
+						Kind:       "Channel",
+					},
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "charts-v1",
+						Namespace: newChartsv1NSName,
+					},
+					Spec: chnv1.ChannelSpec{
+						Type:     chnv1.ChannelTypeHelmRepo,
+						Pathname: "http://test.svc.cluster.local:3000/charts",
+					},
+				},
+			}
+			backupStorageLocation = &veleroapi.BackupStorageLocation{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "velero/v1",
+					Kind:       "BackupStorageLocation",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "default-mc-selector",
+					Namespace: newVeleroNamespace,
+					OwnerReferences: []metav1.OwnerReference{
+						{
+							APIVersion: "oadp.openshift.io/v1alpha1",
+							Kind:       "Velero",
+							Name:       "velero-instnace",
+							UID:        "fed287da-02ea-4c83-a7f8-906ce662451a",
+						},
+					},
+				},
+				Spec: veleroapi.BackupStorageLocationSpec{
+					AccessMode: "ReadWrite",
+					StorageType: veleroapi.StorageType{
+						ObjectStorage: &veleroapi.ObjectStorageLocation{
+							Bucket: "velero-backup-acm-dr",
+							Prefix: "velero",
+						},
+					},
+					Provider: "aws",
+				},
+			}
+		})
+		It("Should exclude the unselected managed clusters from the managed clusters backups", func() {
+			Expect(k8sClient.Create(ctx, backupStorageLocation)).Should(Succeed())
+			backupStorageLocation.Status.Phase = veleroapi.BackupStorageLocationPhaseAvailable
+			Expect(k8sClient.Status().Update(ctx, backupStorageLocation)).Should(Succeed())
+
+			labelSelector := &metav1.LabelSelector{
+				MatchLabels: map[string]string{"dr.example.com/backup": "true"},
+			}
+			rhacmBackupSchedule := v1beta1.BackupSchedule{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "cluster.open-cluster-management.io/v1beta1",
+					Kind:       "BackupSchedule",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      backupScheduleName + "-mc-selector",
+					Namespace: newVeleroNamespace,
+				},
+				Spec: v1beta1.BackupScheduleSpec{
+					VeleroSchedule:               backupSchedule,
+					VeleroTTL:                    metav1.Duration{Duration: time.Hour * 72},
+					ManagedClustersLabelSelector: labelSelector,
+				},
+			}
+			Expect(k8sClient.Create(ctx, &rhacmBackupSchedule)).Should(Succeed())
+
+			veleroSchedules := veleroapi.ScheduleList{}
+			Eventually(func() int {
+				if err := k8sClient.List(ctx, &veleroSchedules,
+					client.InNamespace(newVeleroNamespace)); err != nil {
+					return 0
+				}
+				return len(veleroSchedules.Items)
+			}, timeout, interval).Should(BeNumerically(">", 0))
+
+			found := false
+			for _, veleroSchedule := range veleroSchedules.Items {
+				if veleroSchedule.Name != veleroScheduleNames[ManagedClusters] {
+					continue
+				}
+				found = true
+				// the selector applies only to the managed clusters and their namespaces
+				Expect(veleroSchedule.Spec.Template.LabelSelector).Should(BeNil())
+				Expect(veleroSchedule.Spec.Template.ExcludedNamespaces).Should(ContainElement("managed1"))
+			}
+			Expect(found).Should(BeTrue())
+
+			// the unselected managed cluster is not backed up by velero
+			managedCluster := clusterv1.ManagedCluster{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "managed1"},
+				&managedCluster)).Should(Succeed())
+			Expect(managedCluster.GetLabels()).Should(HaveKeyWithValue(veleroExcludeFromBackupLabel, "true"))
+
+			// an invalid label selector fails the validation
+			createdSchedule := v1beta1.BackupSchedule{}
+			scheduleLookupKey := types.NamespacedName{
+				Name:      backupScheduleName + "-mc-selector",
+				Namespace: newVeleroNamespace,
+			}
+			Expect(k8sClient.Get(ctx, scheduleLookupKey, &createdSchedule)).Should(Succeed())
+			createdSchedule.Spec.ManagedClustersLabelSelector = &metav1.LabelSelector{
+				MatchExpressions: []metav1.LabelSelectorRequirement{
+					{Key: "dr.example.com/backup", Operator: "Like"},
+				},
+			}
+			Expect(k8sClient.Update(ctx, &createdSchedule)).Should(Succeed())
+			Eventually(func() v1beta1.SchedulePhase {
+				err := k8sClient.Get(ctx, scheduleLookupKey, &createdSchedule)
+				Expect(err).NotTo(HaveOccurred())
+				return createdSchedule.Status.Phase
+			}, timeout, interval).Should(BeEquivalentTo(v1beta1.SchedulePhaseFailedValidation))
+			Expect(createdSchedule.Status.LastMessage).Should(ContainSubstring(
+				"invalid managedClustersLabelSelector"))
+		})
+	})
+
+	Context("When pausing and resuming a BackupSchedule", func() {
+		var newVeleroNamespace = "velero-ns-paused"
+		var newAcmNamespace = "acm-ns-paused"
+		var newChartsv1NSName = "acm-channel-ns-paused"
+
+		BeforeEach(func() {
+			clusterPoolNS = nil
+			clusterDeploymentNS = nil
+			veleroBackups = []veleroapi.Backup{}
+			chartsv1NS = &corev1.Namespace{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "v1",
+					Kind:       "Namespace",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name: newChartsv1NSName,
+				},
+			}
+			acmNamespace = &corev1.Namespace{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "v1",
+					Kind:       "Namespace",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name: newAcmNamespace,
+				},
+			}
+			veleroNamespace = &corev1.Namespace{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "v1",
+					Kind:       "Namespace",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name: newVeleroNamespace,
+				},
+			}
+			channels = []chnv1.Channel{
+				{
+					TypeMeta: metav1.TypeMeta{
+						APIVersion: "apps.open-cluster-management.io/v1",
+						Kind:       "Channel",
+					},
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "charts-v1",
+						Namespace: newChartsv1NSName,
+					},
+					Spec: chnv1.ChannelSpec{
+						Type:     chnv1.ChannelTypeHelmRepo,
+						Pathname: "http://test.svc.cluster.local:3000/charts",
+					},
+				},
+			}
+			backupStorageLocation = &veleroapi.BackupStorageLocation{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "velero/v1",
+					Kind:       "BackupStorageLocation",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "default-paused",
+					Namespace: newVeleroNamespace,
+					OwnerReferences: []metav1.OwnerReference{
+						{
+							APIVersion: "oadp.openshift.io/v1alpha1",
+							Kind:       "Velero",
+							Name:       "velero-instnace",
+							UID:        "fed287da-02ea-4c83-a7f8-906ce662451a",
+						},
+					},
+				},
+				Spec: veleroapi.BackupStorageLocationSpec{
+					AccessMode: "ReadWrite",
+					StorageType: veleroapi.StorageType{
+						ObjectStorage: &veleroapi.ObjectStorageLocation{
+							Bucket: "velero-backup-acm-dr",
+							Prefix: "velero",
+						},
+					},
+					Provider: "aws",
+				},
+			}
+		})
+		It("Should remove the Velero schedules when paused and create them when resumed", func() {
+			Expect(k8sClient.Create(ctx, backupStorageLocation)).Should(Succeed())
+			backupStorageLocation.Status.Phase = veleroapi.BackupStorageLocationPhaseAvailable
+			Expect(k8sClient.Status().Update(ctx, backupStorageLocation)).Should(Succeed())
+
+			rhacmBackupSchedule := v1beta1.BackupSchedule{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "cluster.open-cluster-management.io/v1beta1",
+					Kind:       "BackupSchedule",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      backupScheduleName + "-paused",
+					Namespace: newVeleroNamespace,
+				},
+				Spec: v1beta1.BackupScheduleSpec{
+					VeleroSchedule: backupSchedule,
+					VeleroTTL:      metav1.Duration{Duration: time.Hour * 72},
+				},
+			}
+			Expect(k8sClient.Create(ctx, &rhacmBackupSchedule)).Should(Succeed())
+
+			veleroSchedules := veleroapi.ScheduleList{}
+			countVeleroSchedules := func() int {
+				if err := k8sClient.List(ctx, &veleroSchedules,
+					client.InNamespace(newVeleroNamespace)); err != nil {
+					return -1
+				}
+				return len(veleroSchedules.Items)
+			}
+			Eventually(countVeleroSchedules, timeout, interval).Should(BeNumerically(">", 0))
+
+			createdSchedule := v1beta1.BackupSchedule{}
+			scheduleLookupKey := types.NamespacedName{
+				Name:      backupScheduleName + "-paused",
+				Namespace: newVeleroNamespace,
+			}
+			setPaused := func(paused bool) {
+				Eventually(func() error {
+					if err := k8sClient.Get(ctx, scheduleLookupKey, &createdSchedule); err != nil {
+						return err
+					}
+					createdSchedule.Spec.Paused = paused
+					return k8sClient.Update(ctx, &createdSchedule)
+				}, timeout, interval).Should(Succeed())
+			}
+
+			// pausing the schedule removes the velero schedules
+			setPaused(true)
+			Eventually(countVeleroSchedules, timeout, interval).Should(BeZero())
+			Eventually(func() v1beta1.SchedulePhase {
+				err := k8sClient.Get(ctx, scheduleLookupKey, &createdSchedule)
+				Expect(err).NotTo(HaveOccurred())
+				return createdSchedule.Status.Phase
+			}, timeout, interval).Should(BeEquivalentTo(v1beta1.SchedulePhasePaused))
+			Expect(createdSchedule.Status.LastMessage).Should(Equal(PausedPhaseMsg))
+			Expect(meta.IsStatusConditionTrue(createdSchedule.Status.Conditions,
+				v1beta1.BackupSchedulePaused)).Should(BeTrue())
+			Consistently(countVeleroSchedules, time.Second*2, interval).Should(BeZero())
+
+			// resuming the schedule creates the velero schedules again, with the last
+			// backup time set so no backup runs before the cron expression fires
+			resumeTime := metav1.Now().Add(-time.Second)
+			setPaused(false)
+			Eventually(countVeleroSchedules, timeout, interval).Should(BeNumerically(">", 0))
+			for _, veleroSchedule := range veleroSchedules.Items {
+				Expect(veleroSchedule.Status.LastBackup).ShouldNot(BeNil())
+				Expect(veleroSchedule.Status.LastBackup.Time.After(resumeTime)).Should(BeTrue())
+			}
+			Eventually(func() bool {
+				err := k8sClient.Get(ctx, scheduleLookupKey, &createdSchedule)
+				Expect(err).NotTo(HaveOccurred())
+				return createdSchedule.Status.Phase != v1beta1.SchedulePhasePaused &&
+					meta.IsStatusConditionFalse(createdSchedule.Status.Conditions,
+						v1beta1.BackupSchedulePaused)
+			}, timeout, interval).Should(BeTrue())
+		})
+	})
+
+	Context("When a Velero schedule is not created by the BackupSchedule anymore", func() {
+		var newVeleroNamespace = "velero-ns-orphaned"
+		var newAcmNamespace = "acm-ns-orphaned"
+		var newChartsv1NSName = "acm-channel-ns-orphaned"
+
+		BeforeEach(func() {
+			clusterPoolNS = nil
+			clusterDeploymentNS = nil
+			veleroBackups = []veleroapi.Backup{}
+			chartsv1NS = &corev1.Namespace{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "v1",
+					Kind:       "Namespace",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name: newChartsv1NSName,
+				},
+			}
+			acmNamespace = &corev1.Namespace{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "v1",
+					Kind:       "Namespace",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name: newAcmNamespace,
+				},
+			}
+			veleroNamespace = &corev1.Namespace{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "v1",
+					Kind:       "Namespace",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name: newVeleroNamespace,
+				},
+			}
+			channels = []chnv1.Channel{
+				{
+					TypeMeta: metav1.TypeMeta{
+						APIVersion: "apps.open-cluster-management.io/v1",
+						Kind:       "Channel",
+					},
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "charts-v1",
+						Namespace: newChartsv1NSName,
+					},
+					Spec: chnv1.ChannelSpec{
+						Type:     chnv1.ChannelTypeHelmRepo,
+						Pathname: "http://test.svc.cluster.local:3000/charts",
+					},
+				},
+			}
+			backupStorageLocation = &veleroapi.BackupStorageLocation{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "velero/v1",
+					Kind:       "BackupStorageLocation",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "default-orphaned",
+					Namespace: newVeleroNamespace,
+					OwnerReferences: []metav1.OwnerReference{
+						{
+							APIVersion: "oadp.openshift.io/v1alpha1",
+							Kind:       "Velero",
+							Name:       "velero-instnace",
+							UID:        "fed287da-02ea-4c83-a7f8-906ce662451a",
+						},
+					},
+				},
+				Spec: veleroapi.BackupStorageLocationSpec{
+					AccessMode: "ReadWrite",
+					StorageType: veleroapi.StorageType{
+						ObjectStorage: &veleroapi.ObjectStorageLocation{
+							Bucket: "velero-backup-acm-dr",
+							Prefix: "velero",
+						},
+					},
+					Provider: "aws",
+				},
+			}
+		})
+		It("Should delete the orphaned Velero schedule", func() {
+			Expect(k8sClient.Create(ctx, backupStorageLocation)).Should(Succeed())
+			backupStorageLocation.Status.Phase = veleroapi.BackupStorageLocationPhaseAvailable
+			Expect(k8sClient.Status().Update(ctx, backupStorageLocation)).Should(Succeed())
+
+			rhacmBackupSchedule := v1beta1.BackupSchedule{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "cluster.open-cluster-management.io/v1beta1",
+					Kind:       "BackupSchedule",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      backupScheduleName + "-orphaned",
+					Namespace: newVeleroNamespace,
+				},
+				Spec: v1beta1.BackupScheduleSpec{
+					VeleroSchedule: backupSchedule,
+					VeleroTTL:      metav1.Duration{Duration: time.Hour * 72},
+				},
+			}
+			Expect(k8sClient.Create(ctx, &rhacmBackupSchedule)).Should(Succeed())
+
+			veleroSchedules := veleroapi.ScheduleList{}
+			Eventually(func() int {
+				if err := k8sClient.List(ctx, &veleroSchedules,
+					client.InNamespace(newVeleroNamespace)); err != nil {
+					return 0
+				}
+				return len(veleroSchedules.Items)
+			}, timeout, interval).Should(Equal(len(veleroScheduleNames)))
+
+			// a velero schedule for a backup type no longer created by the backup schedule
+			createdSchedule := v1beta1.BackupSchedule{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{
+				Name:      backupScheduleName + "-orphaned",
+				Namespace: newVeleroNamespace,
+			}, &createdSchedule)).Should(Succeed())
+			orphanedSchedule := &veleroapi.Schedule{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "acm-removed-type-schedule",
+					Namespace: newVeleroNamespace,
+					OwnerReferences: []metav1.OwnerReference{
+						*metav1.NewControllerRef(&createdSchedule,
+							v1beta1.GroupVersion.WithKind("BackupSchedule")),
+					},
+				},
+				Spec: veleroapi.ScheduleSpec{
+					Schedule: backupSchedule,
+				},
+			}
+			Expect(k8sClient.Create(ctx, orphanedSchedule)).Should(Succeed())
+			// a velero schedule not owned by the backup schedule
+			unownedSchedule := &veleroapi.Schedule{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "acm-unowned-schedule",
+					Namespace: newVeleroNamespace,
+				},
+				Spec: veleroapi.ScheduleSpec{
+					Schedule: backupSchedule,
+				},
+			}
+			Expect(k8sClient.Create(ctx, unownedSchedule)).Should(Succeed())
+
+			// the orphaned velero schedule is deleted, the other velero schedules are kept
+			Eventually(func() bool {
+				err := k8sClient.Get(ctx, client.ObjectKeyFromObject(orphanedSchedule),
+					&veleroapi.Schedule{})
+				return k8serr.IsNotFound(err)
+			}, timeout, interval).Should(BeTrue())
+			Consistently(func() error {
+				return k8sClient.Get(ctx, client.ObjectKeyFromObject(unownedSchedule),
+					&veleroapi.Schedule{})
+			}, time.Second*2, interval).Should(Succeed())
+			Expect(k8sClient.List(ctx, &veleroSchedules,
+				client.InNamespace(newVeleroNamespace))).Should(Succeed())
+			Expect(veleroSchedules.Items).Should(HaveLen(len(veleroScheduleNames) + 1))
+		})
+	})
+
+	Context("When the backup storage location becomes available", func() {
+		var newVeleroNamespace = "velero-ns-location-available"
+		var newAcmNamespace = "acm-ns-location-available"
+		var newChartsv1NSName = "acm-channel-ns-location-available"
+
+		BeforeEach(func() {
+			clusterPoolNS = nil
+			clusterDeploymentNS = nil
+			veleroBackups = []veleroapi.Backup{}
+			chartsv1NS = &corev1.Namespace{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "v1",
+					Kind:       "Namespace",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name: newChartsv1NSName,
+				},
+			}
+			acmNamespace = &corev1.Namespace{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "v1",
+					Kind:       "Namespace",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name: newAcmNamespace,
+				},
+			}
+			veleroNamespace = &corev1.Namespace{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "v1",
+					Kind:       "Namespace",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name: newVeleroNamespace,
+				},
+			}
+			channels = []chnv1.Channel{
+				{
+					TypeMeta: metav1.TypeMeta{
+						APIVersion: "apps.open-cluster-management.io/v1",
+						Kind:       "Channel",
+					},
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "charts-v1",
+						Namespace: newChartsv1NSName,
+					},
+					Spec: chnv1.ChannelSpec{
+						Type:     chnv1.ChannelTypeHelmRepo,
+						Pathname: "http://test.svc.cluster.local:3000/charts",
+					},
+				},
+			}
+			backupStorageLocation = &veleroapi.BackupStorageLocation{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "velero/v1",
+					Kind:       "BackupStorageLocation",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "default-location-available",
+					Namespace: newVeleroNamespace,
+					OwnerReferences: []metav1.OwnerReference{
+						{
+							APIVersion: "oadp.openshift.io/v1alpha1",
+							Kind:       "Velero",
+							Name:       "velero-instnace",
+							UID:        "fed287da-02ea-4c83-a7f8-906ce662451a",
+						},
+					},
+				},
+				Spec: veleroapi.BackupStorageLocationSpec{
+					AccessMode: "ReadWrite",
+					StorageType: veleroapi.StorageType{
+						ObjectStorage: &veleroapi.ObjectStorageLocation{
+							Bucket: "velero-backup-acm-dr",
+							Prefix: "velero",
+						},
+					},
+					Provider: "aws",
+				},
+			}
+		})
+		It("Should create the Velero schedules when the storage location becomes available", func() {
+			Expect(k8sClient.Create(ctx, backupStorageLocation)).Should(Succeed())
+			backupStorageLocation.Status.Phase = veleroapi.BackupStorageLocationPhaseUnavailable
+			Expect(k8sClient.Status().Update(ctx, backupStorageLocation)).Should(Succeed())
+
+			rhacmBackupSchedule := v1beta1.BackupSchedule{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "cluster.open-cluster-management.io/v1beta1",
+					Kind:       "BackupSchedule",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      backupScheduleName + "-location-available",
+					Namespace: newVeleroNamespace,
+				},
+				Spec: v1beta1.BackupScheduleSpec{
+					VeleroSchedule: backupSchedule,
+					VeleroTTL:      metav1.Duration{Duration: time.Hour * 72},
+				},
+			}
+			Expect(k8sClient.Create(ctx, &rhacmBackupSchedule)).Should(Succeed())
+
+			scheduleLookupKey := types.NamespacedName{
+				Name:      backupScheduleName + "-location-available",
+				Namespace: newVeleroNamespace,
+			}
+			createdSchedule := v1beta1.BackupSchedule{}
+			Eventually(func() v1beta1.SchedulePhase {
+				Expect(k8sClient.Get(ctx, scheduleLookupKey, &createdSchedule)).Should(Succeed())
+				return createdSchedule.Status.Phase
+			}, timeout, interval).Should(BeEquivalentTo(v1beta1.SchedulePhaseFailedValidation))
+			veleroSchedules := veleroapi.ScheduleList{}
+			Expect(k8sClient.List(ctx, &veleroSchedules,
+				client.InNamespace(newVeleroNamespace))).Should(Succeed())
+			Expect(veleroSchedules.Items).Should(BeEmpty())
+
+			// the storage location phase change triggers the reconcile,
+			// before the failed validation requeue interval expires
+			backupStorageLocation.Status.Phase = veleroapi.BackupStorageLocationPhaseAvailable
+			Expect(k8sClient.Status().Update(ctx, backupStorageLocation)).Should(Succeed())
+			Eventually(func() int {
+				if err := k8sClient.List(ctx, &veleroSchedules,
+					client.InNamespace(newVeleroNamespace)); err != nil {
+					return 0
+				}
+				return len(veleroSchedules.Items)
+			}, timeout, interval).Should(Equal(len(veleroScheduleNames)))
+			Eventually(func() v1beta1.SchedulePhase {
+				Expect(k8sClient.Get(ctx, scheduleLookupKey, &createdSchedule)).Should(Succeed())
+				return createdSchedule.Status.Phase
+			}, timeout, interval).ShouldNot(BeEquivalentTo(v1beta1.SchedulePhaseFailedValidation))
+		})
+	})
+
+	Context("When creating a BackupSchedule with backup hooks", func() {
+		var newVeleroNamespace = "velero-ns-hooks"
+		var newAcmNamespace = "acm-ns-hooks"
+		var newChartsv1NSName = "acm-channel-ns-hooks"
+
+		BeforeEach(func() {
+			clusterPoolNS = nil
+			clusterDeploymentNS = nil
+			veleroBackups = []veleroapi.Backup{}
+			chartsv1NS = &corev1.Namespace{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "v1",
+					Kind:       "Namespace",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name: newChartsv1NSName,
+				},
+			}
+			acmNamespace = &corev1.Namespace{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "v1",
+					Kind:       "Namespace",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name: newAcmNamespace,
+				},
+			}
+			veleroNamespace = &corev1.Namespace{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "v1",
+					Kind:       "Namespace",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name: newVeleroNamespace,
+				},
+			}
+			channels = []chnv1.Channel{
+				{
+					TypeMeta: metav1.TypeMeta{
+						APIVersion: "apps.open-cluster-management.io/v1",
+						Kind:       "Channel",
+					},
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "charts-v1",
+						Namespace: newChartsv1NSName,
+					},
+					Spec: chnv1.ChannelSpec{
+						Type:     chnv1.ChannelTypeHelmRepo,
+						Pathname: "http://test.svc.cluster.local:3000/charts",
+					},
+				},
+			}
+			backupStorageLocation = &veleroapi.BackupStorageLocation{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "velero/v1",
+					Kind:       "BackupStorageLocation",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "default-hooks",
+					Namespace: newVeleroNamespace,
+					OwnerReferences: []metav1.OwnerReference{
+						{
+							APIVersion: "oadp.openshift.io/v1alpha1",
+							Kind:       "Velero",
+							Name:       "velero-instnace",
+							UID:        "fed287da-02ea-4c83-a7f8-906ce662451a",
+						},
+					},
+				},
+				Spec: veleroapi.BackupStorageLocationSpec{
+					AccessMode: "ReadWrite",
+					StorageType: veleroapi.StorageType{
+						ObjectStorage: &veleroapi.ObjectStorageLocation{
+							Bucket: "velero-backup-acm-dr",
+							Prefix: "velero",
+						},
+					},
+					Provider: "aws",
+				},
+			}
+		})
+		It("Should set the hooks on the resources Velero schedule", func() {
+			Expect(k8sClient.Create(ctx, backupStorageLocation)).Should(Succeed())
+			backupStorageLocation.Status.Phase = veleroapi.BackupStorageLocationPhaseAvailable
+			Expect(k8sClient.Status().Update(ctx, backupStorageLocation)).Should(Succeed())
+
+			hooks := veleroapi.BackupHooks{
+				Resources: []veleroapi.BackupResourceHookSpec{
+					{
+						Name:               "quiesce-db",
+						IncludedNamespaces: []string{newAcmNamespace},
+						LabelSelector: &metav1.LabelSelector{
+							MatchLabels: map[string]string{"app": "db"},
+						},
+						PreHooks: []veleroapi.BackupResourceHook{
+							{
+								Exec: &veleroapi.ExecHook{
+									Container: "db",
+									Command:   []string{"/bin/sh", "-c", "db-freeze"},
+									OnError:   veleroapi.HookErrorModeFail,
+									Timeout:   metav1.Duration{Duration: time.Minute},
+								},
+							},
+						},
+						PostHooks: []veleroapi.BackupResourceHook{
+							{
+								Exec: &veleroapi.ExecHook{
+									Container: "db",
+									Command:   []string{"/bin/sh", "-c", "db-unfreeze"},
+								},
+							},
+						},
+					},
+				},
+			}
+			rhacmBackupSchedule := v1beta1.BackupSchedule{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "cluster.open-cluster-management.io/v1beta1",
+					Kind:       "BackupSchedule",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      backupScheduleName + "-hooks",
+					Namespace: newVeleroNamespace,
+				},
+				Spec: v1beta1.BackupScheduleSpec{
+					VeleroSchedule: backupSchedule,
+					VeleroTTL:      metav1.Duration{Duration: time.Hour * 72},
+					Hooks:          hooks,
+				},
+			}
+			Expect(k8sClient.Create(ctx, &rhacmBackupSchedule)).Should(Succeed())
+
+			// the hooks are set only on the resources velero schedule
+			veleroSchedules := veleroapi.ScheduleList{}
+			Eventually(func() int {
+				if err := k8sClient.List(ctx, &veleroSchedules,
+					client.InNamespace(newVeleroNamespace)); err != nil {
+					return 0
+				}
+				return len(veleroSchedules.Items)
+			}, timeout, interval).Should(Equal(len(veleroScheduleNames)))
+			for i := range veleroSchedules.Items {
+				if veleroSchedules.Items[i].Name == veleroScheduleNames[Resources] {
+					Expect(veleroSchedules.Items[i].Spec.Template.Hooks).Should(Equal(hooks))
+				} else {
+					Expect(veleroSchedules.Items[i].Spec.Template.Hooks.Resources).Should(BeEmpty())
+				}
+			}
+		})
+	})
+
+	Context("When the backupNow property is set", func() {
+		var newVeleroNamespace = "velero-ns-backup-now"
+		var newAcmNamespace = "acm-ns-backup-now"
+		var newChartsv1NSName = "acm-channel-ns-backup-now"
+
+		BeforeEach(func() {
+			clusterPoolNS = nil
+			clusterDeploymentNS = nil
+			veleroBackups = []veleroapi.Backup{}
+			chartsv1NS = &corev1.Namespace{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "v1",
+					Kind:       "Namespace",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name: newChartsv1NSName,
+				},
+			}
+			acmNamespace = &corev1.Namespace{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "v1",
+					Kind:       "Namespace",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name: newAcmNamespace,
+				},
+			}
+			veleroNamespace = &corev1.Namespace{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "v1",
+					Kind:       "Namespace",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name: newVeleroNamespace,
+				},
+			}
+			channels = []chnv1.Channel{
+				{
+					TypeMeta: metav1.TypeMeta{
+						APIVersion: "apps.open-cluster-management.io/v1",
+						Kind:       "Channel",
+					},
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "charts-v1",
+						Namespace: newChartsv1NSName,
+					},
+					Spec: chnv1.ChannelSpec{
+						Type:     chnv1.ChannelTypeHelmRepo,
+						Pathname: "http://test.svc.cluster.local:3000/charts",
+					},
+				},
+			}
+			backupStorageLocation = &veleroapi.BackupStorageLocation{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "velero/v1",
+					Kind:       "BackupStorageLocation",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "default-backup-now",
+					Namespace: newVeleroNamespace,
+					OwnerReferences: []metav1.OwnerReference{
+						{
+							APIVersion: "oadp.openshift.io/v1alpha1",
+							Kind:       "Velero",
+							Name:       "velero-instnace",
+							UID:        "fed287da-02ea-4c83-a7f8-906ce662451a",
+						},
+					},
+				},
+				Spec: veleroapi.BackupStorageLocationSpec{
+					AccessMode: "ReadWrite",
+					StorageType: veleroapi.StorageType{
+						ObjectStorage: &veleroapi.ObjectStorageLocation{
+							Bucket: "velero-backup-acm-dr",
+							Prefix: "velero",
+						},
+					},
+					Provider: "aws",
+				},
+			}
+		})
+		It("Should create the backups once and reset the property", func() {
+			Expect(k8sClient.Create(ctx, backupStorageLocation)).Should(Succeed())
+			backupStorageLocation.Status.Phase = veleroapi.BackupStorageLocationPhaseAvailable
+			Expect(k8sClient.Status().Update(ctx, backupStorageLocation)).Should(Succeed())
+
+			rhacmBackupSchedule := v1beta1.BackupSchedule{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "cluster.open-cluster-management.io/v1beta1",
+					Kind:       "BackupSchedule",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      backupScheduleName + "-backup-now",
+					Namespace: newVeleroNamespace,
+				},
+				Spec: v1beta1.BackupScheduleSpec{
+					VeleroSchedule: backupSchedule,
+					VeleroTTL:      metav1.Duration{Duration: time.Hour * 72},
+					BackupNow:      true,
+				},
+			}
+			Expect(k8sClient.Create(ctx, &rhacmBackupSchedule)).Should(Succeed())
+
+			By("the property is reset once the velero schedules are created and the backups requested")
+			backupLookupKey := types.NamespacedName{
+				Name:      rhacmBackupSchedule.Name,
+				Namespace: newVeleroNamespace,
+			}
+			createdBackupSchedule := v1beta1.BackupSchedule{}
+			Eventually(func() bool {
+				if err := k8sClient.Get(ctx, backupLookupKey, &createdBackupSchedule); err != nil {
+					return false
+				}
+				return !createdBackupSchedule.Spec.BackupNow &&
+					createdBackupSchedule.Status.BackupNowTime != nil
+			}, timeout, interval).Should(BeTrue())
+			// a backup for each backup type, except the validation backup
+			Expect(createdBackupSchedule.Status.BackupNowBackups).Should(HaveLen(len(veleroScheduleNames) - 1))
+
+			By("the backups are created only once for the trigger")
+			countBackups := func() int {
+				backups := veleroapi.BackupList{}
+				if err := k8sClient.List(ctx, &backups, client.InNamespace(newVeleroNamespace),
+					client.MatchingLabels{BackupScheduleNameLabel: rhacmBackupSchedule.Name}); err != nil {
+					return -1
+				}
+				return len(backups.Items)
+			}
+			Eventually(countBackups, timeout, interval).Should(Equal(len(veleroScheduleNames) - 1))
+			Consistently(countBackups, time.Second*3, interval).Should(Equal(len(veleroScheduleNames) - 1))
+		})
+	})
+	Context("When the backupLabels and backupAnnotations properties are set", func() {
+		var newVeleroNamespace = "velero-ns-backup-labels"
+		var newAcmNamespace = "acm-ns-backup-labels"
+		var newChartsv1NSName = "acm-channel-ns-backup-labels"
+
+		BeforeEach(func() {
+			clusterPoolNS = nil
+			clusterDeploymentNS = nil
+			veleroBackups = []veleroapi.Backup{}
+			chartsv1NS = &corev1.Namespace{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "v1",
+					Kind:       "Namespace",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name: newChartsv1NSName,
+				},
+			}
+			acmNamespace = &corev1.Namespace{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "v1",
+					Kind:       "Namespace",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name: newAcmNamespace,
+				},
+			}
+			veleroNamespace = &corev1.Namespace{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "v1",
+					Kind:       "Namespace",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name: newVeleroNamespace,
+				},
+			}
+			channels = []chnv1.Channel{
+				{
+					TypeMeta: metav1.TypeMeta{
+						APIVersion: "apps.open-cluster-management.io/v1",
+						Kind:       "Channel",
+					},
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "charts-v1",
+						Namespace: newChartsv1NSName,
+					},
+					Spec: chnv1.ChannelSpec{
+						Type:     chnv1.ChannelTypeHelmRepo,
+						Pathname: "http://test.svc.cluster.local:3000/charts",
+					},
+				},
+			}
+			backupStorageLocation = &veleroapi.BackupStorageLocation{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "velero/v1",
+					Kind:       "BackupStorageLocation",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "default-backup-labels",
+					Namespace: newVeleroNamespace,
+					OwnerReferences: []metav1.OwnerReference{
+						{
+							APIVersion: "oadp.openshift.io/v1alpha1",
+							Kind:       "Velero",
+							Name:       "velero-instnace",
+							UID:        "fed287da-02ea-4c83-a7f8-906ce662451a",
+						},
+					},
+				},
+				Spec: veleroapi.BackupStorageLocationSpec{
+					AccessMode: "ReadWrite",
+					StorageType: veleroapi.StorageType{
+						ObjectStorage: &veleroapi.ObjectStorageLocation{
+							Bucket: "velero-backup-acm-dr",
+							Prefix: "velero",
+						},
+					},
+					Provider: "aws",
+				},
+			}
+		})
+		It("Should add them to the velero schedules and keep the operator labels", func() {
+			Expect(k8sClient.Create(ctx, backupStorageLocation)).Should(Succeed())
+			backupStorageLocation.Status.Phase = veleroapi.BackupStorageLocationPhaseAvailable
+			Expect(k8sClient.Status().Update(ctx, backupStorageLocation)).Should(Succeed())
+
+			rhacmBackupSchedule := v1beta1.BackupSchedule{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "cluster.open-cluster-management.io/v1beta1",
+					Kind:       "BackupSchedule",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      backupScheduleName + "-backup-labels",
+					Namespace: newVeleroNamespace,
+				},
+				Spec: v1beta1.BackupScheduleSpec{
+					VeleroSchedule: backupSchedule,
+					VeleroTTL:      metav1.Duration{Duration: time.Hour * 72},
+					BackupLabels: map[string]string{
+						"cost-center":           "dr-1234",
+						BackupScheduleNameLabel: "other-schedule",
+					},
+					BackupAnnotations: map[string]string{
+						"finops.example.com/owner": "platform-team",
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, &rhacmBackupSchedule)).Should(Succeed())
+
+			By("the velero schedules have the custom labels and annotations")
+			veleroSchedules := veleroapi.ScheduleList{}
+			Eventually(func() int {
+				if err := k8sClient.List(ctx, &veleroSchedules,
+					client.InNamespace(newVeleroNamespace)); err != nil {
+					return 0
+				}
+				return len(veleroSchedules.Items)
+			}, timeout, interval).Should(Equal(len(veleroScheduleNames)))
+			for _, veleroSchedule := range veleroSchedules.Items {
+				Expect(veleroSchedule.Labels).Should(HaveKeyWithValue("cost-center", "dr-1234"))
+				Expect(veleroSchedule.Annotations).Should(
+					HaveKeyWithValue("finops.example.com/owner", "platform-team"))
+				// the operator labels are not overwritten
+				Expect(veleroSchedule.Labels).Should(
+					HaveKeyWithValue(BackupScheduleNameLabel, rhacmBackupSchedule.Name))
+				Expect(veleroSchedule.Labels).Should(HaveKey(BackupScheduleTypeLabel))
+				Expect(veleroSchedule.Annotations).Should(HaveKey(VeleroScheduleSpecHashAnnotation))
+			}
+		})
+	})
+	Context("When the veleroScheduleOverrides property is set", func() {
+		var newVeleroNamespace = "velero-ns-schedule-overrides"
+		var newAcmNamespace = "acm-ns-schedule-overrides"
+		var newChartsv1NSName = "acm-channel-ns-schedule-overrides"
+
+		BeforeEach(func() {
+			clusterPoolNS = nil
+			clusterDeploymentNS = nil
+			veleroBackups = []veleroapi.Backup{}
+			chartsv1NS = &corev1.Namespace{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "v1",
+					Kind:       "Namespace",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name: newChartsv1NSName,
+				},
+			}
+			acmNamespace = &corev1.Namespace{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "v1",
+					Kind:       "Namespace",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name: newAcmNamespace,
+				},
+			}
+			veleroNamespace = &corev1.Namespace{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "v1",
+					Kind:       "Namespace",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name: newVeleroNamespace,
+				},
+			}
+			channels = []chnv1.Channel{
+				{
+					TypeMeta: metav1.TypeMeta{
+						APIVersion: "apps.open-cluster-management.io/v1",
+						Kind:       "Channel",
+					},
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "charts-v1",
+						Namespace: newChartsv1NSName,
+					},
+					Spec: chnv1.ChannelSpec{
+						Type:     chnv1.ChannelTypeHelmRepo,
+						Pathname: "http://test.svc.cluster.local:3000/charts",
+					},
+				},
+			}
+			backupStorageLocation = &veleroapi.BackupStorageLocation{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "velero/v1",
+					Kind:       "BackupStorageLocation",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "default-schedule-overrides",
+					Namespace: newVeleroNamespace,
+					OwnerReferences: []metav1.OwnerReference{
+						{
+							APIVersion: "oadp.openshift.io/v1alpha1",
+							Kind:       "Velero",
+							Name:       "velero-instnace",
+							UID:        "fed287da-02ea-4c83-a7f8-906ce662451a",
+						},
+					},
+				},
+				Spec: veleroapi.BackupStorageLocationSpec{
+					AccessMode: "ReadWrite",
+					StorageType: veleroapi.StorageType{
+						ObjectStorage: &veleroapi.ObjectStorageLocation{
+							Bucket: "velero-backup-acm-dr",
+							Prefix: "velero",
+						},
+					},
+					Provider: "aws",
+				},
+			}
+		})
+		It("Should create the velero schedules of each backup type with the backup type cron", func() {
+			Expect(k8sClient.Create(ctx, backupStorageLocation)).Should(Succeed())
+			backupStorageLocation.Status.Phase = veleroapi.BackupStorageLocationPhaseAvailable
+			Expect(k8sClient.Status().Update(ctx, backupStorageLocation)).Should(Succeed())
+
+			rhacmBackupSchedule := v1beta1.BackupSchedule{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "cluster.open-cluster-management.io/v1beta1",
+					Kind:       "BackupSchedule",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      backupScheduleName + "-schedule-overrides",
+					Namespace: newVeleroNamespace,
+				},
+				Spec: v1beta1.BackupScheduleSpec{
+					VeleroSchedule: backupSchedule,
+					VeleroTTL:      metav1.Duration{Duration: time.Hour * 72},
+					VeleroScheduleOverrides: map[string]string{
+						string(Credentials):     "0 * * * *",
+						string(ManagedClusters): "0 2 * * *",
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, &rhacmBackupSchedule)).Should(Succeed())
+
+			wantCrons := map[string]string{
+				veleroScheduleNames[Credentials]:        "0 * * * *",
+				veleroScheduleNames[ManagedClusters]:    "0 2 * * *",
+				veleroScheduleNames[CredentialsHive]:    backupSchedule,
+				veleroScheduleNames[CredentialsCluster]: backupSchedule,
+				veleroScheduleNames[Resources]:          backupSchedule,
+				veleroScheduleNames[ResourcesGeneric]:   backupSchedule,
+				veleroScheduleNames[ValidationSchedule]: backupSchedule,
+			}
+			getCrons := func() map[string]string {
+				veleroSchedules := veleroapi.ScheduleList{}
+				if err := k8sClient.List(ctx, &veleroSchedules,
+					client.InNamespace(newVeleroNamespace)); err != nil {
+					return nil
+				}
+				crons := map[string]string{}
+				for _, veleroSchedule := range veleroSchedules.Items {
+					crons[veleroSchedule.Name] = veleroSchedule.Spec.Schedule
+				}
+				return crons
+			}
+
+			By("the overridden backup types use their own cron, the other backup types the veleroSchedule")
+			Eventually(getCrons, timeout, interval).Should(Equal(wantCrons))
+
+			By("a velero schedule is created again when its backup type cron is updated")
+			Eventually(func() error {
+				if err := k8sClient.Get(ctx, types.NamespacedName{Name: rhacmBackupSchedule.Name,
+					Namespace: newVeleroNamespace}, &rhacmBackupSchedule); err != nil {
+					return err
+				}
+				rhacmBackupSchedule.Spec.VeleroScheduleOverrides[string(Credentials)] = "30 * * * *"
+				return k8sClient.Update(ctx, &rhacmBackupSchedule)
+			}, timeout, interval).Should(Succeed())
+			wantCrons[veleroScheduleNames[Credentials]] = "30 * * * *"
+			Eventually(getCrons, timeout, interval).Should(Equal(wantCrons))
+		})
+	})
+	Context("When a backup created by the schedule is in progress", func() {
+		var newVeleroNamespace = "velero-ns-backup-progress"
+		var newAcmNamespace = "acm-ns-backup-progress"
+		var newChartsv1NSName = "acm-channel-ns-backup-progress"
+
+		BeforeEach(func() {
+			clusterPoolNS = nil
+			clusterDeploymentNS = nil
+			veleroBackups = []veleroapi.Backup{}
+			chartsv1NS = &corev1.Namespace{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "v1",
+					Kind:       "Namespace",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name: newChartsv1NSName,
+				},
+			}
+			acmNamespace = &corev1.Namespace{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "v1",
+					Kind:       "Namespace",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name: newAcmNamespace,
+				},
+			}
+			veleroNamespace = &corev1.Namespace{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "v1",
+					Kind:       "Namespace",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name: newVeleroNamespace,
+				},
+			}
+			channels = []chnv1.Channel{
+				{
+					TypeMeta: metav1.TypeMeta{
+						APIVersion: "apps.open-cluster-management.io/v1",
+						Kind:       "Channel",
+					},
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "charts-v1",
+						Namespace: newChartsv1NSName,
+					},
+					Spec: chnv1.ChannelSpec{
+						Type:     chnv1.ChannelTypeHelmRepo,
+						Pathname: "http://test.svc.cluster.local:3000/charts",
+					},
+				},
+			}
+			backupStorageLocation = &veleroapi.BackupStorageLocation{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "velero/v1",
+					Kind:       "BackupStorageLocation",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "default-backup-progress",
+					Namespace: newVeleroNamespace,
+					OwnerReferences: []metav1.OwnerReference{
+						{
+							APIVersion: "oadp.openshift.io/v1alpha1",
+							Kind:       "Velero",
+							Name:       "velero-instnace",
+							UID:        "fed287da-02ea-4c83-a7f8-906ce662451a",
+						},
+					},
+				},
+				Spec: veleroapi.BackupStorageLocationSpec{
+					AccessMode: "ReadWrite",
+					StorageType: veleroapi.StorageType{
+						ObjectStorage: &veleroapi.ObjectStorageLocation{
+							Bucket: "velero-backup-acm-dr",
+							Prefix: "velero",
+						},
+					},
+					Provider: "aws",
+				},
+			}
+		})
+		It("Should show the backup progress in the status", func() {
+			Expect(k8sClient.Create(ctx, backupStorageLocation)).Should(Succeed())
+			backupStorageLocation.Status.Phase = veleroapi.BackupStorageLocationPhaseAvailable
+			Expect(k8sClient.Status().Update(ctx, backupStorageLocation)).Should(Succeed())
+
+			rhacmBackupSchedule := v1beta1.BackupSchedule{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "cluster.open-cluster-management.io/v1beta1",
+					Kind:       "BackupSchedule",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      backupScheduleName + "-backup-progress",
+					Namespace: newVeleroNamespace,
+				},
+				Spec: v1beta1.BackupScheduleSpec{
+					VeleroSchedule: backupSchedule,
+					VeleroTTL:      metav1.Duration{Duration: time.Hour * 72},
+				},
+			}
+			Expect(k8sClient.Create(ctx, &rhacmBackupSchedule)).Should(Succeed())
+
+			backupLookupKey := types.NamespacedName{
+				Name:      rhacmBackupSchedule.Name,
+				Namespace: newVeleroNamespace,
+			}
+			createdBackupSchedule := v1beta1.BackupSchedule{}
+			Eventually(func() v1beta1.SchedulePhase {
+				if err := k8sClient.Get(ctx, backupLookupKey, &createdBackupSchedule); err != nil {
+					return ""
+				}
+				return createdBackupSchedule.Status.Phase
+			}, timeout, interval).Should(Equal(v1beta1.SchedulePhaseEnabled))
+
+			By("a resources backup with partial progress is shown in the status")
+			veleroBackup := veleroapi.Backup{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      veleroScheduleNames[Resources] + "-20220420120000",
+					Namespace: newVeleroNamespace,
+					Labels: map[string]string{
+						BackupScheduleNameLabel: rhacmBackupSchedule.Name,
+						BackupScheduleTypeLabel: string(Resources),
+					},
+				},
+				Status: veleroapi.BackupStatus{
+					Phase: veleroapi.BackupPhaseInProgress,
+					Progress: &veleroapi.BackupProgress{
+						ItemsBackedUp: 10,
+						TotalItems:    100,
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, &veleroBackup)).Should(Succeed())
+			getInProgressBackups := func() []v1beta1.InProgressBackup {
+				if err := k8sClient.Get(ctx, backupLookupKey, &createdBackupSchedule); err != nil {
+					return nil
+				}
+				return createdBackupSchedule.Status.InProgressBackups
+			}
+			Eventually(getInProgressBackups, timeout, interval).Should(Equal([]v1beta1.InProgressBackup{
+				{
+					BackupType:    string(Resources),
+					BackupName:    veleroBackup.Name,
+					ItemsBackedUp: 10,
+					TotalItems:    100,
+				},
+			}))
+
+			By("the status is updated when the backup progress changes")
+			veleroBackup.Status.Progress.ItemsBackedUp = 50
+			Expect(k8sClient.Update(ctx, &veleroBackup)).Should(Succeed())
+			Eventually(getInProgressBackups, timeout, interval).Should(Equal([]v1beta1.InProgressBackup{
+				{
+					BackupType:    string(Resources),
+					BackupName:    veleroBackup.Name,
+					ItemsBackedUp: 50,
+					TotalItems:    100,
+				},
+			}))
+
+			By("the backup is removed from the status once completed")
+			veleroBackup.Status.Phase = veleroapi.BackupPhaseCompleted
+			Expect(k8sClient.Update(ctx, &veleroBackup)).Should(Succeed())
+			Eventually(getInProgressBackups, timeout, interval).Should(BeEmpty())
+		})
+	})
+})
+
+var _ = Describe("Velero resources server-side apply", func() {
+
+	It("Should not update the velero resource when nothing changed", func() {
+		ctx := context.Background()
+		namespace := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "velero-apply-ns",
+			},
+		}
+		Expect(k8sClient.Create(ctx, namespace)).Should(Succeed())
+
+		newVeleroSchedule := func(cron string) *veleroapi.Schedule {
+			return &veleroapi.Schedule{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "acm-credentials-schedule",
+					Namespace: namespace.Name,
+					Labels: map[string]string{
+						BackupScheduleTypeLabel: string(Credentials),
+					},
+				},
+				Spec: veleroapi.ScheduleSpec{
+					Schedule: cron,
+				},
+			}
+		}
+		scheduleLookupKey := types.NamespacedName{
+			Name:      "acm-credentials-schedule",
+			Namespace: namespace.Name,
+		}
+
+		By("the first apply creates the velero schedule")
+		created, err := createVeleroResource(ctx, k8sClient, newVeleroSchedule("0 */6 * * *"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(created).Should(BeTrue())
+		veleroSchedule := veleroapi.Schedule{}
+		Expect(k8sClient.Get(ctx, scheduleLookupKey, &veleroSchedule)).Should(Succeed())
+		resourceVersion := veleroSchedule.ResourceVersion
+		Expect(veleroSchedule.ManagedFields).ShouldNot(BeEmpty())
+		Expect(veleroSchedule.ManagedFields[0].Manager).Should(Equal(veleroFieldManager))
+
+		By("applying the same velero schedule doesn't change the resource version")
+		Expect(applyVeleroResource(ctx, k8sClient, newVeleroSchedule("0 */6 * * *"))).Should(Succeed())
+		Expect(k8sClient.Get(ctx, scheduleLookupKey, &veleroSchedule)).Should(Succeed())
+		Expect(veleroSchedule.ResourceVersion).Should(Equal(resourceVersion))
+
+		By("creating an existing velero schedule doesn't update it")
+		created, err = createVeleroResource(ctx, k8sClient, newVeleroSchedule("0 */8 * * *"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(created).Should(BeFalse())
+		Expect(k8sClient.Get(ctx, scheduleLookupKey, &veleroSchedule)).Should(Succeed())
+		Expect(veleroSchedule.ResourceVersion).Should(Equal(resourceVersion))
+
+		By("applying a changed velero schedule updates it")
+		err = applyVeleroResource(ctx, k8sClient, newVeleroSchedule("0 */8 * * *"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(k8sClient.Get(ctx, scheduleLookupKey, &veleroSchedule)).Should(Succeed())
+		Expect(veleroSchedule.ResourceVersion).ShouldNot(Equal(resourceVersion))
+		Expect(veleroSchedule.Spec.Schedule).Should(Equal("0 */8 * * *"))
+	})
+
+	It("Should apply the velero schedule only when its spec hash changes", func() {
+		ctx := context.Background()
+		namespace := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "velero-spec-hash-ns",
+			},
+		}
+		Expect(k8sClient.Create(ctx, namespace)).Should(Succeed())
+
+		backupSchedule := &v1beta1.BackupSchedule{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "schedule-spec-hash",
+				Namespace: namespace.Name,
+				UID:       types.UID("schedule-spec-hash-uid"),
+			},
+		}
+		newVeleroSchedule := func(cron string) *veleroapi.Schedule {
+			return &veleroapi.Schedule{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "acm-credentials-schedule",
+					Namespace: namespace.Name,
+				},
+				Spec: veleroapi.ScheduleSpec{
+					Schedule: cron,
+				},
+			}
+		}
+		patchClient := &patchCountingClient{Client: k8sClient}
+		reconciler := &BackupScheduleReconciler{
+			Client: patchClient,
+			Scheme: k8sClient.Scheme(),
+		}
+
+		By("reconciling twice the same velero schedule applies it once")
+		Expect(reconciler.createVeleroSchedule(ctx, backupSchedule,
+			newVeleroSchedule("0 */6 * * *"))).Should(Succeed())
+		Expect(reconciler.createVeleroSchedule(ctx, backupSchedule,
+			newVeleroSchedule("0 */6 * * *"))).Should(Succeed())
+		Expect(patchClient.patches).Should(Equal(1))
+
+		veleroSchedule := veleroapi.Schedule{}
+		Expect(k8sClient.Get(ctx, types.NamespacedName{
+			Name:      "acm-credentials-schedule",
+			Namespace: namespace.Name,
+		}, &veleroSchedule)).Should(Succeed())
+		Expect(veleroSchedule.Annotations[VeleroScheduleSpecHashAnnotation]).ShouldNot(BeEmpty())
+
+		By("a changed velero schedule is applied again")
+		Expect(reconciler.createVeleroSchedule(ctx, backupSchedule,
+			newVeleroSchedule("0 */8 * * *"))).Should(Succeed())
+		Expect(patchClient.patches).Should(Equal(2))
+	})
+})
+
+// counts the patch calls, used to apply the velero resources
+type patchCountingClient struct {
+	client.Client
+	patches int
+}
+
+func (c *patchCountingClient) Patch(
+	ctx context.Context,
+	obj client.Object,
+	patch client.Patch,
+	opts ...client.PatchOption,
+) error {
+	c.patches++
+	return c.Client.Patch(ctx, obj, patch, opts...)
+}
@@ -17,16 +17,37 @@ limitations under the License.
 package controllers
 
 import (
+	"compress/gzip"
 	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
+	"sort"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/robfig/cron/v3"
 	"github.com/stolostron/cluster-backup-operator/api/v1beta1"
 	veleroapi "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	"k8s.io/apimachinery/pkg/version"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	fakediscovery "k8s.io/client-go/discovery/fake"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
 	fakeclientset "k8s.io/client-go/kubernetes/fake"
 )
 
@@ -201,6 +222,167 @@ func Test_isScheduleSpecUpdated(t *testing.T) {
 			},
 			want: true,
 		},
+		{
+			name: "cron spec not updated",
+			args: args{
+				schedules:      initVeleroScheduleList(veleroapi.SchedulePhaseEnabled, "0 6 * * *"),
+				backupSchedule: initBackupSchedule("0 6 * * *"),
+			},
+			want: false,
+		},
+		{
+			name: "transient resources exclusion updated",
+			args: args{
+				schedules: &veleroapi.ScheduleList{
+					Items: []veleroapi.Schedule{
+						{
+							ObjectMeta: metav1.ObjectMeta{
+								Name: veleroScheduleNames[ResourcesGeneric],
+							},
+							Spec: veleroapi.ScheduleSpec{
+								Schedule: "0 6 * * *",
+							},
+						},
+					},
+				},
+				backupSchedule: initBackupSchedule("0 6 * * *"),
+			},
+			want: true,
+		},
+		{
+			name: "excluded namespaces updated",
+			args: args{
+				schedules: &veleroapi.ScheduleList{
+					Items: []veleroapi.Schedule{
+						{
+							ObjectMeta: metav1.ObjectMeta{
+								Name: veleroScheduleNames[ResourcesGeneric],
+							},
+							Spec: veleroapi.ScheduleSpec{
+								Schedule: "0 6 * * *",
+								Template: veleroapi.BackupSpec{
+									ExcludedNamespaces: []string{"tenant-1"},
+									ExcludedResources:  transientResources,
+									LabelSelector: getGenericResourcesBackupLabelSelector(
+										&v1beta1.BackupSchedule{}),
+								},
+							},
+						},
+					},
+				},
+				backupSchedule: &v1beta1.BackupSchedule{
+					Spec: v1beta1.BackupScheduleSpec{
+						VeleroSchedule:     "0 6 * * *",
+						ExcludedNamespaces: []string{"tenant-1", "tenant-2"},
+					},
+				},
+			},
+			want: true,
+		},
+		{
+			name: "excluded namespaces not updated",
+			args: args{
+				schedules: &veleroapi.ScheduleList{
+					Items: []veleroapi.Schedule{
+						{
+							ObjectMeta: metav1.ObjectMeta{
+								Name: veleroScheduleNames[ResourcesGeneric],
+							},
+							Spec: veleroapi.ScheduleSpec{
+								Schedule: "0 6 * * *",
+								Template: veleroapi.BackupSpec{
+									ExcludedNamespaces: []string{"tenant-1", "tenant-2"},
+									ExcludedResources:  transientResources,
+									LabelSelector: getGenericResourcesBackupLabelSelector(
+										&v1beta1.BackupSchedule{}),
+								},
+							},
+						},
+					},
+				},
+				backupSchedule: &v1beta1.BackupSchedule{
+					Spec: v1beta1.BackupScheduleSpec{
+						VeleroSchedule:     "0 6 * * *",
+						ExcludedNamespaces: []string{"tenant-2", "tenant-1"},
+					},
+				},
+			},
+			want: false,
+		},
+		{
+			name: "generic resources label selector updated",
+			args: args{
+				schedules: &veleroapi.ScheduleList{
+					Items: []veleroapi.Schedule{
+						{
+							ObjectMeta: metav1.ObjectMeta{
+								Name: veleroScheduleNames[ResourcesGeneric],
+							},
+							Spec: veleroapi.ScheduleSpec{
+								Schedule: "0 6 * * *",
+								Template: veleroapi.BackupSpec{
+									ExcludedResources: transientResources,
+									LabelSelector: getGenericResourcesBackupLabelSelector(
+										&v1beta1.BackupSchedule{}),
+								},
+							},
+						},
+					},
+				},
+				backupSchedule: &v1beta1.BackupSchedule{
+					Spec: v1beta1.BackupScheduleSpec{
+						VeleroSchedule: "0 6 * * *",
+						GenericResourceLabelSelector: &metav1.LabelSelector{
+							MatchLabels: map[string]string{"backup.example.com": "true"},
+						},
+					},
+				},
+			},
+			want: true,
+		},
+		{
+			name: "snapshot volumes updated",
+			args: args{
+				schedules: &veleroapi.ScheduleList{
+					Items: []veleroapi.Schedule{
+						{
+							ObjectMeta: metav1.ObjectMeta{
+								Name:   veleroScheduleNames[Credentials],
+								Labels: map[string]string{BackupScheduleTypeLabel: string(Credentials)},
+							},
+							Spec: veleroapi.ScheduleSpec{
+								Schedule: "0 6 * * *",
+							},
+						},
+					},
+				},
+				backupSchedule: initBackupSchedule("0 6 * * *"),
+			},
+			want: true,
+		},
+		{
+			name: "snapshot volumes not updated",
+			args: args{
+				schedules: &veleroapi.ScheduleList{
+					Items: []veleroapi.Schedule{
+						{
+							ObjectMeta: metav1.ObjectMeta{
+								Name:   veleroScheduleNames[Credentials],
+								Labels: map[string]string{BackupScheduleTypeLabel: string(Credentials)},
+							},
+							Spec: veleroapi.ScheduleSpec{
+								Schedule: "0 6 * * *",
+								Template: veleroapi.BackupSpec{
+									SnapshotVolumes: getSnapshotVolumes(&v1beta1.BackupSchedule{}, Credentials),
+								},
+							},
+						},
+					},
+				},
+				backupSchedule: initBackupSchedule("0 6 * * *"),
+			},
+			want: false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -210,3 +392,4344 @@ func Test_isScheduleSpecUpdated(t *testing.T) {
 		})
 	}
 }
+
+func Test_getSnapshotVolumes(t *testing.T) {
+	snapshotVolumes := true
+	tests := []struct {
+		name            string
+		snapshotVolumes *bool
+		scheduleKey     ResourceType
+		want            *bool
+	}{
+		{
+			name:        "resources backup, not set",
+			scheduleKey: Resources,
+			want:        new(bool),
+		},
+		{
+			name:        "generic resources backup, not set",
+			scheduleKey: ResourcesGeneric,
+			want:        new(bool),
+		},
+		{
+			name:        "credentials backup, not set",
+			scheduleKey: CredentialsHive,
+			want:        new(bool),
+		},
+		{
+			name:        "managed clusters backup, not set",
+			scheduleKey: ManagedClusters,
+			want:        nil,
+		},
+		{
+			name:            "resources backup, set",
+			snapshotVolumes: &snapshotVolumes,
+			scheduleKey:     Resources,
+			want:            &snapshotVolumes,
+		},
+		{
+			name:            "managed clusters backup, set",
+			snapshotVolumes: &snapshotVolumes,
+			scheduleKey:     ManagedClusters,
+			want:            &snapshotVolumes,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backupSchedule := &v1beta1.BackupSchedule{
+				Spec: v1beta1.BackupScheduleSpec{
+					SnapshotVolumes: tt.snapshotVolumes,
+				},
+			}
+			if got := getSnapshotVolumes(backupSchedule, tt.scheduleKey); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getSnapshotVolumes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_getCollisionWindowBackup(t *testing.T) {
+
+	newRestore := func(backupName string) veleroapi.Restore {
+		return veleroapi.Restore{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "restore-acm-" + backupName,
+			},
+			Spec: veleroapi.RestoreSpec{
+				BackupName: backupName,
+			},
+		}
+	}
+	from := time.Date(2022, 4, 20, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		backups  []veleroapi.Backup
+		restores []veleroapi.Restore
+		want     string
+	}{
+		{
+			name: "no foreign hub backups",
+			backups: []veleroapi.Backup{
+				*newTestBackup("acm-resources-schedule-20220420130000", withHubID("this-hub")),
+				*newTestBackup("acm-credentials-schedule-20220420130000"),
+				*newTestBackup("acm-managed-clusters-schedule-20220420130000", withHubID(unknownHubID)),
+			},
+			want: "",
+		},
+		{
+			name: "foreign hub backups before the window",
+			backups: []veleroapi.Backup{
+				*newTestBackup("acm-resources-schedule-20220420100000", withHubID("other-hub")),
+				*newTestBackup("acm-resources-schedule-20220420120000", withHubID("other-hub")),
+			},
+			want: "",
+		},
+		{
+			name: "foreign hub backups within the window",
+			backups: []veleroapi.Backup{
+				*newTestBackup("acm-resources-schedule-20220420100000", withHubID("other-hub")),
+				*newTestBackup("acm-resources-schedule-20220420130000", withHubID("other-hub")),
+				*newTestBackup("acm-credentials-schedule-20220420140000", withHubID("other-hub")),
+				*newTestBackup("acm-resources-schedule-20220420150000", withHubID("this-hub")),
+			},
+			want: "acm-credentials-schedule-20220420140000",
+		},
+		{
+			name: "foreign hub backups restored on this hub",
+			backups: []veleroapi.Backup{
+				*newTestBackup("acm-resources-schedule-20220420130000", withHubID("other-hub")),
+				*newTestBackup("acm-credentials-schedule-20220420140000", withHubID("other-hub")),
+				*newTestBackup("acm-resources-schedule-20220420140000", withHubID("other-hub")),
+			},
+			restores: []veleroapi.Restore{
+				newRestore("acm-resources-schedule-20220420140000"),
+			},
+			want: "",
+		},
+		{
+			name: "foreign hub backups created after the restored backup",
+			backups: []veleroapi.Backup{
+				*newTestBackup("acm-resources-schedule-20220420130000", withHubID("other-hub")),
+				*newTestBackup("acm-resources-schedule-20220420140000", withHubID("other-hub")),
+				*newTestBackup("acm-resources-schedule-20220420150000", withHubID("other-hub")),
+				*newTestBackup("acm-resources-schedule-20220420160000", withHubID("third-hub")),
+			},
+			restores: []veleroapi.Restore{
+				newRestore("acm-resources-schedule-20220420130000"),
+				newRestore("acm-resources-schedule-20220420160000"),
+			},
+			want: "acm-resources-schedule-20220420150000",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := getCollisionWindowBackup(tt.backups, tt.restores, "this-hub", from)
+			gotName := ""
+			if got != nil {
+				gotName = got.Name
+			}
+			if gotName != tt.want {
+				t.Errorf("getCollisionWindowBackup() = %v, want %v", gotName, tt.want)
+			}
+		})
+	}
+}
+
+func Test_validateVeleroTTL(t *testing.T) {
+	tests := []struct {
+		name string
+		ttl  time.Duration
+		want []string
+	}{
+		{
+			name: "TTL not set",
+			ttl:  0,
+			want: []string{},
+		},
+		{
+			name: "valid TTL",
+			ttl:  72 * time.Hour,
+			want: []string{},
+		},
+		{
+			name: "negative TTL",
+			ttl:  -time.Hour,
+			want: []string{"veleroTtl -1h0m0s must not be negative"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backupSchedule := initBackupSchedule("0 */6 * * *")
+			backupSchedule.Spec.VeleroTTL = metav1.Duration{Duration: tt.ttl}
+			if got := validateVeleroTTL(backupSchedule); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("validateVeleroTTL() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_validateBackupScheduleFields_excludedNamespaces(t *testing.T) {
+	tests := []struct {
+		name               string
+		excludedNamespaces []string
+		wantErrors         int
+	}{
+		{
+			name:               "no excluded namespaces",
+			excludedNamespaces: nil,
+			wantErrors:         0,
+		},
+		{
+			name:               "valid namespaces",
+			excludedNamespaces: []string{"tenant-1", "tenant-2"},
+			wantErrors:         0,
+		},
+		{
+			name:               "invalid namespaces",
+			excludedNamespaces: []string{"tenant-1", "Tenant_2", "tenant.3"},
+			wantErrors:         2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backupSchedule := initBackupSchedule("0 */6 * * *")
+			backupSchedule.Spec.ExcludedNamespaces = tt.excludedNamespaces
+			got := validateBackupScheduleFields(context.TODO(), backupSchedule)
+			if len(got) != tt.wantErrors {
+				t.Errorf("validateBackupScheduleFields() = %v, want %v errors", got, tt.wantErrors)
+			}
+			for _, err := range got {
+				if !strings.HasPrefix(err.Field, "spec.excludedNamespaces[") {
+					t.Errorf("validateBackupScheduleFields() unexpected error %v", err)
+				}
+			}
+		})
+	}
+}
+
+func Test_getBackupMetadataErrors(t *testing.T) {
+	tests := []struct {
+		name              string
+		backupLabels      map[string]string
+		backupAnnotations map[string]string
+		wantErrors        int
+	}{
+		{
+			name:       "no labels or annotations",
+			wantErrors: 0,
+		},
+		{
+			name:              "valid labels and annotations",
+			backupLabels:      map[string]string{"cost-center": "dr-1234", "example.com/team": "platform"},
+			backupAnnotations: map[string]string{"finops.example.com/owner": "platform team"},
+			wantErrors:        0,
+		},
+		{
+			name:              "invalid label key and value, invalid annotation key",
+			backupLabels:      map[string]string{"cost center": "dr-1234", "team": "platform team"},
+			backupAnnotations: map[string]string{"owner name": "platform"},
+			wantErrors:        3,
+		},
+		{
+			// the empty name part fails both the non empty and the format checks
+			name:              "annotation key with an empty name part",
+			backupAnnotations: map[string]string{"owner/": "platform"},
+			wantErrors:        2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backupSchedule := initBackupSchedule("0 */6 * * *")
+			backupSchedule.Spec.BackupLabels = tt.backupLabels
+			backupSchedule.Spec.BackupAnnotations = tt.backupAnnotations
+			got := getBackupMetadataErrors(backupSchedule, field.NewPath("spec"))
+			if len(got) != tt.wantErrors {
+				t.Errorf("getBackupMetadataErrors() = %v, want %v errors", got, tt.wantErrors)
+			}
+		})
+	}
+}
+
+func Test_isBackupMetadataUpdated(t *testing.T) {
+	tests := []struct {
+		name             string
+		scheduleMetadata map[string]string
+		userMetadata     map[string]string
+		want             bool
+	}{
+		{
+			name: "no user defined metadata",
+			scheduleMetadata: map[string]string{
+				BackupScheduleNameLabel: "schedule-acm",
+			},
+			want: false,
+		},
+		{
+			name: "same user defined metadata",
+			scheduleMetadata: map[string]string{
+				BackupScheduleNameLabel: "schedule-acm",
+				"cost-center":           "dr-1234",
+			},
+			userMetadata: map[string]string{"cost-center": "dr-1234"},
+			want:         false,
+		},
+		{
+			name: "reserved key not set on the schedule",
+			scheduleMetadata: map[string]string{
+				BackupScheduleNameLabel: "schedule-acm",
+			},
+			userMetadata: map[string]string{BackupScheduleNameLabel: "other"},
+			want:         false,
+		},
+		{
+			name: "user defined metadata added",
+			scheduleMetadata: map[string]string{
+				BackupScheduleNameLabel: "schedule-acm",
+			},
+			userMetadata: map[string]string{"cost-center": "dr-1234"},
+			want:         true,
+		},
+		{
+			name: "user defined metadata updated",
+			scheduleMetadata: map[string]string{
+				"cost-center": "dr-1234",
+			},
+			userMetadata: map[string]string{"cost-center": "dr-5678"},
+			want:         true,
+		},
+		{
+			name: "user defined metadata removed",
+			scheduleMetadata: map[string]string{
+				VeleroScheduleSpecHashAnnotation: "hash",
+				"owner":                          "platform",
+			},
+			want: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isBackupMetadataUpdated(tt.scheduleMetadata, tt.userMetadata); got != tt.want {
+				t.Errorf("isBackupMetadataUpdated() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_getVeleroScheduleOverridesErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		overrides  map[string]string
+		wantErrors []string
+	}{
+		{
+			name:       "no overrides",
+			wantErrors: []string{},
+		},
+		{
+			name: "valid overrides",
+			overrides: map[string]string{
+				string(Credentials):     "0 * * * *",
+				string(ManagedClusters): "0 2 * * *",
+				string(Resources):       "0 3 * * *",
+			},
+			wantErrors: []string{},
+		},
+		{
+			name: "backup types restored with another backup type",
+			overrides: map[string]string{
+				string(CredentialsCluster): "0 * * * *",
+				string(CredentialsHive):    "0 * * * *",
+			},
+			wantErrors: []string{
+				"spec.veleroScheduleOverrides[credentialsCluster]",
+				"spec.veleroScheduleOverrides[credentialsHive]",
+			},
+		},
+		{
+			name: "invalid cron, empty cron and unknown backup types",
+			overrides: map[string]string{
+				string(Credentials):        "WRONG",
+				string(Resources):          "",
+				string(ResourcesGeneric):   "0 * * * *",
+				string(ValidationSchedule): "0 * * * *",
+				"policies":                 "0 * * * *",
+			},
+			wantErrors: []string{
+				"spec.veleroScheduleOverrides[credentials]",
+				"spec.veleroScheduleOverrides[policies]",
+				"spec.veleroScheduleOverrides[resources]",
+				"spec.veleroScheduleOverrides[resourcesGeneric]",
+				"spec.veleroScheduleOverrides[validation]",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backupSchedule := initBackupSchedule("0 */6 * * *")
+			backupSchedule.Spec.VeleroScheduleOverrides = tt.overrides
+			got := getVeleroScheduleOverridesErrors(context.TODO(), backupSchedule, field.NewPath("spec"))
+			if len(got) != len(tt.wantErrors) {
+				t.Fatalf("getVeleroScheduleOverridesErrors() = %v, want %v errors", got, len(tt.wantErrors))
+			}
+			for i, fieldPath := range tt.wantErrors {
+				if got[i].Field != fieldPath {
+					t.Errorf("getVeleroScheduleOverridesErrors()[%d] = %v, want an error for %s",
+						i, got[i], fieldPath)
+				}
+			}
+		})
+	}
+}
+
+func Test_getBackupTypeCronSchedule(t *testing.T) {
+
+	backupSchedule := initBackupSchedule("0 */6 * * *")
+	backupSchedule.Spec.VeleroScheduleOverrides = map[string]string{
+		string(Credentials):        "0 * * * *",
+		string(ManagedClusters):    "0 2 * * *",
+		string(ValidationSchedule): "0 3 * * *",
+		string(ResourcesGeneric):   "0 4 * * *",
+	}
+	tests := []struct {
+		backupType ResourceType
+		want       string
+	}{
+		{backupType: Credentials, want: "0 * * * *"},
+		{backupType: CredentialsHive, want: "0 * * * *"},
+		{backupType: CredentialsCluster, want: "0 * * * *"},
+		{backupType: ManagedClusters, want: "0 2 * * *"},
+		{backupType: Resources, want: "0 */6 * * *"},
+		{backupType: ResourcesGeneric, want: "0 */6 * * *"},
+		{backupType: ValidationSchedule, want: "0 */6 * * *"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.backupType), func(t *testing.T) {
+			if got := getBackupTypeCronSchedule(backupSchedule, tt.backupType, "cluster-1"); got != tt.want {
+				t.Errorf("getBackupTypeCronSchedule() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_setBackupChainHealthyCondition(t *testing.T) {
+
+	now := time.Date(2022, 4, 20, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name               string
+		validationSchedule string
+		created            time.Time
+		backups            []veleroapi.Backup
+		wantStatus         metav1.ConditionStatus
+		wantReason         string
+	}{
+		{
+			name:    "validation backup completed within the cron interval",
+			created: now.Add(-24 * time.Hour),
+			backups: []veleroapi.Backup{
+				*newTestBackup(
+					"acm-validation-policy-schedule-20220420100000",
+					withBackupType(ValidationSchedule),
+					withPhase(veleroapi.BackupPhaseCompleted),
+					withCompletionTime(now.Add(-2*time.Hour)),
+				),
+				*newTestBackup(
+					"acm-validation-policy-schedule-20220420110000",
+					withBackupType(ValidationSchedule),
+					withPhase(veleroapi.BackupPhaseCompleted),
+					withCompletionTime(now.Add(-50*time.Minute)),
+				),
+			},
+			wantStatus: metav1.ConditionTrue,
+			wantReason: v1beta1.BackupScheduleReasonValidationBackupCurrent,
+		},
+		{
+			name:    "validation backup missing, the last one failed",
+			created: now.Add(-24 * time.Hour),
+			backups: []veleroapi.Backup{
+				*newTestBackup(
+					"acm-validation-policy-schedule-20220420080000",
+					withBackupType(ValidationSchedule),
+					withPhase(veleroapi.BackupPhaseCompleted),
+					withCompletionTime(now.Add(-4*time.Hour)),
+				),
+				*newTestBackup(
+					"acm-validation-policy-schedule-20220420110000",
+					withBackupType(ValidationSchedule),
+					withPhase(veleroapi.BackupPhaseFailed),
+				),
+				*newTestBackup(
+					"acm-resources-schedule-20220420110000",
+					withBackupType(Resources),
+					withPhase(veleroapi.BackupPhaseCompleted),
+					withCompletionTime(now.Add(-50*time.Minute)),
+				),
+			},
+			wantStatus: metav1.ConditionFalse,
+			wantReason: v1beta1.BackupScheduleReasonValidationBackupMissing,
+		},
+		{
+			name:       "no validation backup yet for a new schedule",
+			created:    now.Add(-10 * time.Minute),
+			wantStatus: metav1.ConditionUnknown,
+			wantReason: v1beta1.BackupScheduleReasonValidationBackupPending,
+		},
+		{
+			name:       "no validation backup after the first interval",
+			created:    now.Add(-3 * time.Hour),
+			wantStatus: metav1.ConditionFalse,
+			wantReason: v1beta1.BackupScheduleReasonValidationBackupMissing,
+		},
+		{
+			name:               "validation backup older than the validationSchedule interval",
+			validationSchedule: "*/10 * * * *",
+			created:            now.Add(-24 * time.Hour),
+			backups: []veleroapi.Backup{
+				*newTestBackup(
+					"acm-validation-policy-schedule-20220420110000",
+					withBackupType(ValidationSchedule),
+					withPhase(veleroapi.BackupPhaseCompleted),
+					withCompletionTime(now.Add(-50*time.Minute)),
+				),
+			},
+			wantStatus: metav1.ConditionFalse,
+			wantReason: v1beta1.BackupScheduleReasonValidationBackupMissing,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backupSchedule := initBackupSchedule("0 * * * *")
+			backupSchedule.CreationTimestamp = metav1.NewTime(tt.created)
+			backupSchedule.Spec.ValidationSchedule = tt.validationSchedule
+			setBackupChainHealthyCondition(backupSchedule, tt.backups, now)
+			condition := meta.FindStatusCondition(backupSchedule.Status.Conditions,
+				v1beta1.BackupScheduleBackupChainHealthy)
+			if condition == nil || condition.Status != tt.wantStatus || condition.Reason != tt.wantReason {
+				t.Errorf("setBackupChainHealthyCondition() = %v, want status %s and reason %s",
+					condition, tt.wantStatus, tt.wantReason)
+			}
+		})
+	}
+}
+
+func Test_setDegradedCondition(t *testing.T) {
+
+	// the backups are added one at a time, on the same schedule, with a failure threshold of 3
+	backupSchedule := initBackupSchedule("0 * * * *")
+	backupSchedule.Spec.FailureThreshold = 3
+	backups := []veleroapi.Backup{
+		*newTestBackup(
+			"acm-resources-schedule-20220420080000",
+			withBackupType(Resources),
+			withPhase(veleroapi.BackupPhaseCompleted),
+		),
+		*newTestBackup(
+			"acm-credentials-schedule-20220420080000",
+			withBackupType(Credentials),
+			withPhase(veleroapi.BackupPhaseFailed),
+		),
+	}
+
+	steps := []struct {
+		name         string
+		backup       veleroapi.Backup
+		wantFailures int
+		wantStatus   metav1.ConditionStatus
+		wantReason   string
+	}{
+		{
+			name: "first failure",
+			backup: *newTestBackup(
+				"acm-resources-schedule-20220420090000",
+				withBackupType(Resources),
+				withPhase(veleroapi.BackupPhasePartiallyFailed),
+			),
+			wantFailures: 1,
+			wantStatus:   metav1.ConditionFalse,
+			wantReason:   v1beta1.BackupScheduleReasonFailureThresholdNotReached,
+		},
+		{
+			name: "failures below the threshold",
+			backup: *newTestBackup(
+				"acm-resources-schedule-20220420100000",
+				withBackupType(Resources),
+				withPhase(veleroapi.BackupPhaseFailed),
+			),
+			wantFailures: 2,
+			wantStatus:   metav1.ConditionFalse,
+			wantReason:   v1beta1.BackupScheduleReasonFailureThresholdNotReached,
+		},
+		{
+			name: "failure reaching the threshold",
+			backup: *newTestBackup(
+				"acm-resources-schedule-20220420110000",
+				withBackupType(Resources),
+				withPhase(veleroapi.BackupPhaseFailedValidation),
+			),
+			wantFailures: 3,
+			wantStatus:   metav1.ConditionTrue,
+			wantReason:   v1beta1.BackupScheduleReasonFailureThresholdReached,
+		},
+		{
+			name: "in progress backup doesn't reset the failures",
+			backup: *newTestBackup(
+				"acm-resources-schedule-20220420120000",
+				withBackupType(Resources),
+				withPhase(veleroapi.BackupPhaseInProgress),
+			),
+			wantFailures: 3,
+			wantStatus:   metav1.ConditionTrue,
+			wantReason:   v1beta1.BackupScheduleReasonFailureThresholdReached,
+		},
+		{
+			name: "completed backup resets the failures",
+			backup: *newTestBackup(
+				"acm-resources-schedule-20220420130000",
+				withBackupType(Resources),
+				withPhase(veleroapi.BackupPhaseCompleted),
+			),
+			wantFailures: 0,
+			wantStatus:   metav1.ConditionFalse,
+			wantReason:   v1beta1.BackupScheduleReasonFailureThresholdNotReached,
+		},
+	}
+	for _, step := range steps {
+		backups = append(backups, step.backup)
+		backupFailures := getBackupFailures(backups)
+		setDegradedCondition(backupSchedule, backupFailures)
+
+		if len(backupFailures) != 2 || backupFailures[0].BackupType != string(Credentials) ||
+			backupFailures[0].ConsecutiveFailures != 1 || backupFailures[1].BackupType != string(Resources) {
+			t.Fatalf("%s: getBackupFailures() = %v, want the credentials and resources types",
+				step.name, backupFailures)
+		}
+		if backupFailures[1].ConsecutiveFailures != step.wantFailures {
+			t.Errorf("%s: getBackupFailures() resources failures = %d, want %d",
+				step.name, backupFailures[1].ConsecutiveFailures, step.wantFailures)
+		}
+		condition := meta.FindStatusCondition(backupSchedule.Status.Conditions,
+			v1beta1.BackupScheduleDegraded)
+		if condition == nil || condition.Status != step.wantStatus || condition.Reason != step.wantReason {
+			t.Errorf("%s: setDegradedCondition() = %v, want status %s and reason %s",
+				step.name, condition, step.wantStatus, step.wantReason)
+		}
+	}
+
+	// the condition is removed when the failure threshold is not set
+	backupSchedule.Spec.FailureThreshold = 0
+	setDegradedCondition(backupSchedule, getBackupFailures(backups))
+	if condition := meta.FindStatusCondition(backupSchedule.Status.Conditions,
+		v1beta1.BackupScheduleDegraded); condition != nil {
+		t.Errorf("setDegradedCondition() = %v, want no condition without a failure threshold", condition)
+	}
+}
+
+func Test_getValidationCronSchedule(t *testing.T) {
+
+	backupSchedule := initBackupSchedule("0 */6 * * *")
+	if got := getBackupTypeCronSchedule(backupSchedule, ValidationSchedule, ""); got != "0 */6 * * *" {
+		t.Errorf("getBackupTypeCronSchedule() = %v, want the veleroSchedule", got)
+	}
+	backupSchedule.Spec.ValidationSchedule = "*/15 * * * *"
+	if got := getBackupTypeCronSchedule(backupSchedule, ValidationSchedule, ""); got != "*/15 * * * *" {
+		t.Errorf("getBackupTypeCronSchedule() = %v, want the validationSchedule", got)
+	}
+	if got := getBackupTypeCronSchedule(backupSchedule, Resources, ""); got != "0 */6 * * *" {
+		t.Errorf("getBackupTypeCronSchedule() = %v, want the veleroSchedule", got)
+	}
+	if errs := validateBackupScheduleFields(context.Background(), backupSchedule); len(errs) != 0 {
+		t.Errorf("validateBackupScheduleFields() = %v, want no errors", errs)
+	}
+	backupSchedule.Spec.ValidationSchedule = "every 15 minutes"
+	errs := validateBackupScheduleFields(context.Background(), backupSchedule)
+	if len(errs) != 1 || errs[0].Field != "spec.validationSchedule" {
+		t.Errorf("validateBackupScheduleFields() = %v, want 1 validationSchedule error", errs)
+	}
+}
+
+func Test_getScheduleJitterOffset(t *testing.T) {
+
+	jitter := 20 * time.Minute
+	offsets := map[time.Duration]bool{}
+	for i := 0; i < 100; i++ {
+		clusterID := fmt.Sprintf("cluster-%d", i)
+		offset := getScheduleJitterOffset(clusterID, jitter)
+		if offset < 0 || offset > jitter || offset%time.Minute != 0 {
+			t.Fatalf("getScheduleJitterOffset(%s) = %v, want whole minutes between 0 and %v",
+				clusterID, offset, jitter)
+		}
+		// the offset of a hub is the same each time it is computed
+		if got := getScheduleJitterOffset(clusterID, jitter); got != offset {
+			t.Fatalf("getScheduleJitterOffset(%s) = %v, then %v", clusterID, offset, got)
+		}
+		offsets[offset] = true
+	}
+	if len(offsets) < 2 {
+		t.Errorf("getScheduleJitterOffset() = %v for all hubs, want spread offsets", offsets)
+	}
+
+	if got := getScheduleJitterOffset("", jitter); got != 0 {
+		t.Errorf("getScheduleJitterOffset() without a hub id = %v, want 0", got)
+	}
+	if got := getScheduleJitterOffset("cluster-1", 30*time.Second); got != 0 {
+		t.Errorf("getScheduleJitterOffset() for a jitter under a minute = %v, want 0", got)
+	}
+	for i := 0; i < 100; i++ {
+		if got := getScheduleJitterOffset(fmt.Sprintf("cluster-%d", i), 3*time.Hour); got > maxScheduleJitter {
+			t.Fatalf("getScheduleJitterOffset() = %v, want at most %v", got, maxScheduleJitter)
+		}
+	}
+}
+
+func Test_getJitteredCronSchedule(t *testing.T) {
+
+	tests := []struct {
+		name         string
+		cronSchedule string
+		offset       time.Duration
+		want         string
+		wantOk       bool
+	}{
+		{
+			name:         "no offset",
+			cronSchedule: "0 */6 * * *",
+			want:         "0 */6 * * *",
+			wantOk:       true,
+		},
+		{
+			name:         "single minute",
+			cronSchedule: "0 */6 * * *",
+			offset:       17 * time.Minute,
+			want:         "17 */6 * * *",
+			wantOk:       true,
+		},
+		{
+			name:         "list of minutes kept in the hour",
+			cronSchedule: "0,30 1 * * *",
+			offset:       40 * time.Minute,
+			want:         "10,40 1 * * *",
+			wantOk:       true,
+		},
+		{
+			name:         "latest minute of the hour",
+			cronSchedule: "59 1 * * *",
+			offset:       40 * time.Minute,
+			want:         "59 1 * * *",
+			wantOk:       true,
+		},
+		{
+			name:         "step interval",
+			cronSchedule: "*/15 * * * *",
+			offset:       20 * time.Minute,
+			want:         "5/15 * * * *",
+			wantOk:       true,
+		},
+		{
+			name:         "time zone",
+			cronSchedule: "CRON_TZ=Europe/Paris 5 2 * * *",
+			offset:       10 * time.Minute,
+			want:         "CRON_TZ=Europe/Paris 15 2 * * *",
+			wantOk:       true,
+		},
+		{
+			name:         "range not shifted",
+			cronSchedule: "0-10 * * * *",
+			offset:       10 * time.Minute,
+			want:         "0-10 * * * *",
+		},
+		{
+			name:         "descriptor not shifted",
+			cronSchedule: "@daily",
+			offset:       10 * time.Minute,
+			want:         "@daily",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := getJitteredCronSchedule(tt.cronSchedule, tt.offset)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("getJitteredCronSchedule() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOk)
+			}
+			if _, err := cron.ParseStandard(got); err != nil {
+				t.Errorf("getJitteredCronSchedule() = %v is not a valid cron: %v", got, err)
+			}
+		})
+	}
+
+	// the velero schedules of a hub are shifted by the hub offset
+	backupSchedule := initBackupSchedule("0 */6 * * *")
+	backupSchedule.Spec.ScheduleJitter = metav1.Duration{Duration: 30 * time.Minute}
+	offset := getScheduleJitterOffset("cluster-1", 30*time.Minute)
+	want := fmt.Sprintf("%d */6 * * *", offset/time.Minute)
+	if got := getBackupTypeCronSchedule(backupSchedule, Resources, "cluster-1"); got != want {
+		t.Errorf("getBackupTypeCronSchedule() = %v, want %v", got, want)
+	}
+	// the skipped backups use the veleroSchedule cron, not the resources override
+	backupSchedule.Spec.VeleroScheduleOverrides = map[string]string{
+		string(Resources): "0 */2 * * *",
+	}
+	if got := getJitteredVeleroSchedule(backupSchedule, "cluster-1"); got != want {
+		t.Errorf("getJitteredVeleroSchedule() = %v, want %v", got, want)
+	}
+}
+
+func Test_validateScheduleJitter(t *testing.T) {
+
+	tests := []struct {
+		name         string
+		jitter       time.Duration
+		cronSchedule string
+		wantErrors   int
+		wantWarnings int
+	}{
+		{name: "not set", cronSchedule: "@daily"},
+		{name: "valid", jitter: 30 * time.Minute, cronSchedule: "0 */6 * * *"},
+		{name: "negative", jitter: -time.Minute, cronSchedule: "0 */6 * * *", wantErrors: 1},
+		{name: "too long", jitter: time.Hour, cronSchedule: "0 */6 * * *", wantErrors: 1},
+		{name: "cron not shifted", jitter: 30 * time.Minute, cronSchedule: "@daily", wantWarnings: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backupSchedule := initBackupSchedule(tt.cronSchedule)
+			backupSchedule.Spec.ScheduleJitter = metav1.Duration{Duration: tt.jitter}
+			errs, warnings := validateScheduleJitter(backupSchedule)
+			if len(errs) != tt.wantErrors || len(warnings) != tt.wantWarnings {
+				t.Errorf("validateScheduleJitter() = %v, %v, want %d errors and %d warnings",
+					errs, warnings, tt.wantErrors, tt.wantWarnings)
+			}
+		})
+	}
+}
+
+func Test_mergeBackupMetadata(t *testing.T) {
+	operatorLabels := map[string]string{
+		BackupScheduleNameLabel: "schedule-acm",
+		BackupScheduleTypeLabel: string(Resources),
+	}
+	tests := []struct {
+		name         string
+		operatorData map[string]string
+		userData     map[string]string
+		want         map[string]string
+	}{
+		{
+			name:         "no user labels",
+			operatorData: operatorLabels,
+			want:         operatorLabels,
+		},
+		{
+			name:     "no operator labels",
+			userData: map[string]string{"cost-center": "dr-1234"},
+			want:     map[string]string{"cost-center": "dr-1234"},
+		},
+		{
+			name:         "operator and reserved labels are not overwritten",
+			operatorData: operatorLabels,
+			userData: map[string]string{
+				"cost-center":                   "dr-1234",
+				BackupScheduleNameLabel:         "other-schedule",
+				BackupScheduleClusterLabel:      "other-cluster",
+				"velero.io/storage-location":    "other-location",
+				"example.com/velero.io-purpose": "dr",
+			},
+			want: map[string]string{
+				BackupScheduleNameLabel:         "schedule-acm",
+				BackupScheduleTypeLabel:         string(Resources),
+				"cost-center":                   "dr-1234",
+				"example.com/velero.io-purpose": "dr",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mergeBackupMetadata(tt.operatorData, tt.userData); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("mergeBackupMetadata() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_setExcludedNamespaces(t *testing.T) {
+	backupSchedule := initBackupSchedule("0 */6 * * *")
+	backupSchedule.Spec.ExcludedNamespaces = []string{"tenant-1", "local-cluster"}
+
+	veleroBackupTemplate := &veleroapi.BackupSpec{
+		IncludedNamespaces: []string{"tenant-1", "tenant-2"},
+		ExcludedNamespaces: []string{"local-cluster"},
+	}
+	setExcludedNamespaces(veleroBackupTemplate, backupSchedule)
+
+	wantExcluded := []string{"local-cluster", "tenant-1"}
+	if !reflect.DeepEqual(veleroBackupTemplate.ExcludedNamespaces, wantExcluded) {
+		t.Errorf("setExcludedNamespaces() excluded = %v, want %v",
+			veleroBackupTemplate.ExcludedNamespaces, wantExcluded)
+	}
+	wantIncluded := []string{"tenant-2"}
+	if !reflect.DeepEqual(veleroBackupTemplate.IncludedNamespaces, wantIncluded) {
+		t.Errorf("setExcludedNamespaces() included = %v, want %v",
+			veleroBackupTemplate.IncludedNamespaces, wantIncluded)
+	}
+}
+
+func Test_getGenericResourcesBackupLabelSelector(t *testing.T) {
+	tests := []struct {
+		name          string
+		labelSelector *metav1.LabelSelector
+		want          string
+		wantErrors    int
+	}{
+		{
+			name:          "default label selector",
+			labelSelector: nil,
+			want:          backupCredsClusterLabel,
+			wantErrors:    0,
+		},
+		{
+			name: "label selector set on the backup schedule",
+			labelSelector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{"backup.example.com": "true"},
+			},
+			want:       "backup.example.com=true",
+			wantErrors: 0,
+		},
+		{
+			name: "invalid label selector",
+			labelSelector: &metav1.LabelSelector{
+				MatchExpressions: []metav1.LabelSelectorRequirement{
+					{Key: "backup.example.com", Operator: "Like"},
+				},
+			},
+			want:       "",
+			wantErrors: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backupSchedule := initBackupSchedule("0 */6 * * *")
+			backupSchedule.Spec.GenericResourceLabelSelector = tt.labelSelector
+			if errs := validateGenericResourceLabelSelector(backupSchedule); len(errs) != tt.wantErrors {
+				t.Errorf("validateGenericResourceLabelSelector() = %v, want %v errors", errs, tt.wantErrors)
+			}
+			if tt.wantErrors > 0 {
+				return
+			}
+
+			veleroBackupTemplate := &veleroapi.BackupSpec{}
+			setGenericResourcesBackupInfo(context.Background(), veleroBackupTemplate, backupSchedule, nil)
+			if got := metav1.FormatLabelSelector(veleroBackupTemplate.LabelSelector); got != tt.want {
+				t.Errorf("setGenericResourcesBackupInfo() label selector = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func newManagedCluster(
+	name string,
+	labels map[string]interface{},
+	annotations map[string]interface{},
+) *unstructured.Unstructured {
+	metadata := map[string]interface{}{
+		"name": name,
+	}
+	if labels != nil {
+		metadata["labels"] = labels
+	}
+	if annotations != nil {
+		metadata["annotations"] = annotations
+	}
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "cluster.open-cluster-management.io/v1",
+		"kind":       "ManagedCluster",
+		"metadata":   metadata,
+	}}
+}
+
+func Test_getUnselectedManagedClusters(t *testing.T) {
+
+	dyn := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			managedClustersGVR: "ManagedClusterList",
+		},
+		newManagedCluster("managed3", nil, nil),
+		newManagedCluster("managed1", map[string]interface{}{"region": "east"}, nil),
+		newManagedCluster("managed2", map[string]interface{}{
+			"dr.example.com/backup": "true",
+			"region":                "west",
+		}, nil),
+	)
+
+	tests := []struct {
+		name          string
+		labelSelector *metav1.LabelSelector
+		want          []string
+		wantErrors    int
+	}{
+		{
+			name: "no label selector",
+			want: nil,
+		},
+		{
+			name: "label selector set on the backup schedule",
+			labelSelector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{"dr.example.com/backup": "true"},
+			},
+			want: []string{"managed1", "managed3"},
+		},
+		{
+			name: "label selector with expressions",
+			labelSelector: &metav1.LabelSelector{
+				MatchExpressions: []metav1.LabelSelectorRequirement{
+					{Key: "region", Operator: metav1.LabelSelectorOpExists},
+				},
+			},
+			want: []string{"managed3"},
+		},
+		{
+			name: "invalid label selector",
+			labelSelector: &metav1.LabelSelector{
+				MatchExpressions: []metav1.LabelSelectorRequirement{
+					{Key: "dr.example.com/backup", Operator: "Like"},
+				},
+			},
+			wantErrors: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backupSchedule := initBackupSchedule("0 */6 * * *")
+			backupSchedule.Spec.ManagedClustersLabelSelector = tt.labelSelector
+			if errs := validateManagedClustersLabelSelector(backupSchedule); len(errs) != tt.wantErrors {
+				t.Errorf("validateManagedClustersLabelSelector() = %v, want %v errors", errs, tt.wantErrors)
+			}
+			if tt.wantErrors > 0 {
+				return
+			}
+
+			got, err := getUnselectedManagedClusters(context.Background(), backupSchedule, dyn)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getUnselectedManagedClusters() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_setManagedClustersBackupExclusion(t *testing.T) {
+
+	dyn := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			managedClustersGVR: "ManagedClusterList",
+		},
+		// not selected, labeled by the operator
+		newManagedCluster("managed1", nil, nil),
+		// selected again, the label set by the operator is removed
+		newManagedCluster("managed2", map[string]interface{}{veleroExcludeFromBackupLabel: "true"},
+			map[string]interface{}{ManagedClusterBackupExcludedAnnotation: "true"}),
+		// selected, the label set by the user is kept
+		newManagedCluster("managed3", map[string]interface{}{veleroExcludeFromBackupLabel: "true"}, nil),
+		// selected, not labeled
+		newManagedCluster("managed4", nil, nil),
+	)
+
+	if err := setManagedClustersBackupExclusion(context.Background(), dyn,
+		[]string{"managed1"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := map[string]bool{
+		"managed1": true,
+		"managed2": false,
+		"managed3": true,
+		"managed4": false,
+	}
+	for name, excluded := range want {
+		managedCluster, err := dyn.Resource(managedClustersGVR).Get(context.Background(), name,
+			metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := managedCluster.GetLabels()[veleroExcludeFromBackupLabel] == "true"; got != excluded {
+			t.Errorf("managed cluster %s excluded = %v, want %v", name, got, excluded)
+		}
+		_, annotated := managedCluster.GetAnnotations()[ManagedClusterBackupExcludedAnnotation]
+		if annotated != (name == "managed1") {
+			t.Errorf("managed cluster %s annotated = %v", name, annotated)
+		}
+	}
+}
+
+func Test_isManagedClustersExclusionUpdated(t *testing.T) {
+
+	newSchedule := func(excludedNamespaces ...string) veleroapi.Schedule {
+		return veleroapi.Schedule{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   veleroScheduleNames[ManagedClusters],
+				Labels: map[string]string{BackupScheduleTypeLabel: string(ManagedClusters)},
+			},
+			Spec: veleroapi.ScheduleSpec{
+				Template: veleroapi.BackupSpec{
+					ExcludedNamespaces: excludedNamespaces,
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		name               string
+		schedule           veleroapi.Schedule
+		unselectedClusters []string
+		want               bool
+	}{
+		{
+			name:     "no unselected managed clusters",
+			schedule: newSchedule("local-cluster", "openshift-machine-api"),
+			want:     false,
+		},
+		{
+			name:               "same unselected managed clusters",
+			schedule:           newSchedule("local-cluster", "openshift-machine-api", "managed1"),
+			unselectedClusters: []string{"managed1"},
+			want:               false,
+		},
+		{
+			name:               "new unselected managed cluster",
+			schedule:           newSchedule("local-cluster", "openshift-machine-api", "managed1"),
+			unselectedClusters: []string{"managed1", "managed2"},
+			want:               true,
+		},
+		{
+			name:     "managed cluster selected again",
+			schedule: newSchedule("local-cluster", "openshift-machine-api", "managed1"),
+			want:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			veleroScheduleList := &veleroapi.ScheduleList{
+				Items: []veleroapi.Schedule{tt.schedule},
+			}
+			if got := isManagedClustersExclusionUpdated(veleroScheduleList,
+				tt.unselectedClusters); got != tt.want {
+				t.Errorf("isManagedClustersExclusionUpdated() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_isGenericExcludedResourcesUpdated(t *testing.T) {
+
+	groupVersions := []groupVersionResources{
+		{
+			group: metav1.APIGroup{Name: "monitoring.coreos.com"},
+			resourceList: &metav1.APIResourceList{
+				GroupVersion: "monitoring.coreos.com/v1",
+				APIResources: []metav1.APIResource{
+					{Name: "prometheuses", Kind: "Prometheus", Namespaced: true},
+				},
+			},
+		},
+		{
+			group: metav1.APIGroup{Name: "apps.open-cluster-management.io"},
+			resourceList: &metav1.APIResourceList{
+				GroupVersion: "apps.open-cluster-management.io/v1",
+				APIResources: []metav1.APIResource{
+					{Name: "channels", Kind: "Channel", Namespaced: true},
+				},
+			},
+		},
+	}
+	newBackupSchedule := func(excludedAPIGroups ...string) *v1beta1.BackupSchedule {
+		backupSchedule := initBackupSchedule("0 */6 * * *")
+		backupSchedule.Spec.ExcludedAPIGroups = excludedAPIGroups
+		return backupSchedule
+	}
+	newSchedule := func(backupSchedule *v1beta1.BackupSchedule) veleroapi.Schedule {
+		return veleroapi.Schedule{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   veleroScheduleNames[ResourcesGeneric],
+				Labels: map[string]string{BackupScheduleTypeLabel: string(ResourcesGeneric)},
+			},
+			Spec: veleroapi.ScheduleSpec{
+				Template: veleroapi.BackupSpec{
+					ExcludedResources: getGenericExcludedResources(groupVersions, backupSchedule),
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		name           string
+		schedule       veleroapi.Schedule
+		backupSchedule *v1beta1.BackupSchedule
+		want           bool
+	}{
+		{
+			name:           "no excluded API groups",
+			schedule:       newSchedule(newBackupSchedule()),
+			backupSchedule: newBackupSchedule(),
+			want:           false,
+		},
+		{
+			name:           "same excluded API groups",
+			schedule:       newSchedule(newBackupSchedule("monitoring.coreos.com")),
+			backupSchedule: newBackupSchedule("monitoring.coreos.com"),
+			want:           false,
+		},
+		{
+			name:           "excluded API group added",
+			schedule:       newSchedule(newBackupSchedule()),
+			backupSchedule: newBackupSchedule("monitoring.coreos.com"),
+			want:           true,
+		},
+		{
+			name:           "excluded API group removed",
+			schedule:       newSchedule(newBackupSchedule("monitoring.coreos.com")),
+			backupSchedule: newBackupSchedule(),
+			want:           true,
+		},
+		{
+			name:           "excluded API group not served",
+			schedule:       newSchedule(newBackupSchedule()),
+			backupSchedule: newBackupSchedule("example.com"),
+			want:           false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			veleroScheduleList := &veleroapi.ScheduleList{
+				Items: []veleroapi.Schedule{tt.schedule},
+			}
+			if got := isGenericExcludedResourcesUpdated(veleroScheduleList,
+				getGenericExcludedResources(groupVersions, tt.backupSchedule)); got != tt.want {
+				t.Errorf("isGenericExcludedResourcesUpdated() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_recordBackupEvents(t *testing.T) {
+	backupSchedule := initBackupSchedule("0 */6 * * *")
+	backupSchedule.Name = "schedule-events"
+
+	readEvents := func(recorder *record.FakeRecorder) []string {
+		events := []string{}
+		for len(recorder.Events) > 0 {
+			events = append(events, <-recorder.Events)
+		}
+		return events
+	}
+
+	recorder := record.NewFakeRecorder(10)
+	steps := []struct {
+		name    string
+		backups []veleroapi.Backup
+		want    []string
+	}{
+		{
+			name: "backup in progress",
+			backups: []veleroapi.Backup{*newTestBackup(
+				"backup-1",
+				withUID("backup-1"+"-uid"),
+				withPhase(veleroapi.BackupPhaseInProgress),
+			)},
+			want: []string{},
+		},
+		{
+			name: "backup partially failed",
+			backups: []veleroapi.Backup{*newTestBackup(
+				"backup-1",
+				withUID("backup-1"+"-uid"),
+				withPhase(veleroapi.BackupPhasePartiallyFailed),
+			)},
+			want: []string{"Warning Backup failed: Backup backup-1 is PartiallyFailed"},
+		},
+		{
+			name: "same phase is not reported again",
+			backups: []veleroapi.Backup{*newTestBackup(
+				"backup-1",
+				withUID("backup-1"+"-uid"),
+				withPhase(veleroapi.BackupPhasePartiallyFailed),
+			)},
+			want: []string{},
+		},
+		{
+			name: "new backup completed",
+			backups: []veleroapi.Backup{
+				*newTestBackup(
+					"backup-1",
+					withUID("backup-1"+"-uid"),
+					withPhase(veleroapi.BackupPhasePartiallyFailed),
+				),
+				*newTestBackup(
+					"backup-2",
+					withUID("backup-2"+"-uid"),
+					withPhase(veleroapi.BackupPhaseCompleted),
+				),
+			},
+			want: []string{"Normal Backup completed: Backup backup-2 is Completed"},
+		},
+		{
+			name: "backup failed",
+			backups: []veleroapi.Backup{*newTestBackup(
+				"backup-3",
+				withUID("backup-3"+"-uid"),
+				withPhase(veleroapi.BackupPhaseFailed),
+			)},
+			want: []string{"Warning Backup failed: Backup backup-3 is Failed"},
+		},
+	}
+	for _, step := range steps {
+		recordBackupEvents(recorder, backupSchedule, step.backups)
+		if got := readEvents(recorder); !reflect.DeepEqual(got, step.want) {
+			t.Errorf("%s: recordBackupEvents() = %v, want %v", step.name, got, step.want)
+		}
+	}
+
+	// the deleted backups are forgotten
+	recordedBackupEventsLock.Lock()
+	defer recordedBackupEventsLock.Unlock()
+	kept := 0
+	for _, recorded := range recordedBackupEvents {
+		if recorded.scheduleName == backupSchedule.Name {
+			kept++
+		}
+	}
+	if kept != 1 {
+		t.Errorf("recordBackupEvents() keeps %v backups, want 1", kept)
+	}
+}
+
+func Test_getAdditionalStorageLocations(t *testing.T) {
+	tests := []struct {
+		name             string
+		storageLocations []storageLocationRef
+		want             []storageLocationRef
+	}{
+		{
+			name: "single storage location",
+			storageLocations: []storageLocationRef{
+				{Name: "primary", Namespace: "velero", Default: true},
+			},
+			want: []storageLocationRef{},
+		},
+		{
+			name: "default and additional storage locations",
+			storageLocations: []storageLocationRef{
+				{Name: "primary", Namespace: "velero", Default: true},
+				{Name: "dr-region", Namespace: "velero"},
+			},
+			want: []storageLocationRef{
+				{Name: "dr-region", Namespace: "velero"},
+			},
+		},
+		{
+			name: "no default storage location",
+			storageLocations: []storageLocationRef{
+				{Name: "primary", Namespace: "velero"},
+				{Name: "dr-region", Namespace: "velero"},
+			},
+			want: []storageLocationRef{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := getAdditionalStorageLocations(tt.storageLocations); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getAdditionalStorageLocations() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_getReadOnlyStorageLocationsMessage(t *testing.T) {
+	readOnly := veleroapi.BackupStorageLocationAccessModeReadOnly
+	tests := []struct {
+		name             string
+		storageLocations []storageLocationRef
+		want             string
+	}{
+		{
+			name: "read-write storage locations",
+			storageLocations: []storageLocationRef{
+				{Name: "primary", Default: true},
+				{Name: "dr-region", AccessMode: veleroapi.BackupStorageLocationAccessModeReadWrite},
+			},
+			want: "",
+		},
+		{
+			name: "read-only additional storage location",
+			storageLocations: []storageLocationRef{
+				{Name: "primary", Default: true},
+				{Name: "dr-region", AccessMode: readOnly},
+			},
+			want: "",
+		},
+		{
+			name: "read-only default storage location",
+			storageLocations: []storageLocationRef{
+				{Name: "primary", Default: true, AccessMode: readOnly},
+				{Name: "dr-region"},
+			},
+			want: "The default backup storage location primary is read-only. " +
+				"Set the velero.io.BackupStorageLocation accessMode to ReadWrite to create backups.",
+		},
+		{
+			name: "all storage locations read-only",
+			storageLocations: []storageLocationRef{
+				{Name: "dr-region", AccessMode: readOnly},
+			},
+			want: "All available backup storage locations are read-only. " +
+				"Set the velero.io.BackupStorageLocation accessMode to ReadWrite to create backups.",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := getReadOnlyStorageLocationsMessage(tt.storageLocations); got != tt.want {
+				t.Errorf("getReadOnlyStorageLocationsMessage() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_isStorageLocationsUpdated(t *testing.T) {
+	newSchedule := func(name string, storageLocation string) veleroapi.Schedule {
+		return veleroapi.Schedule{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: name,
+			},
+			Spec: veleroapi.ScheduleSpec{
+				Template: veleroapi.BackupSpec{
+					StorageLocation: storageLocation,
+				},
+			},
+		}
+	}
+	schedules := &veleroapi.ScheduleList{
+		Items: []veleroapi.Schedule{
+			newSchedule(veleroScheduleNames[Resources], ""),
+			newSchedule(getStorageLocationScheduleName(veleroScheduleNames[Resources], "dr-region"),
+				"dr-region"),
+		},
+	}
+
+	tests := []struct {
+		name             string
+		schedules        *veleroapi.ScheduleList
+		storageLocations []storageLocationRef
+		want             bool
+	}{
+		{
+			name:      "no schedules",
+			schedules: nil,
+			want:      false,
+		},
+		{
+			name:      "same storage locations",
+			schedules: schedules,
+			storageLocations: []storageLocationRef{
+				{Name: "dr-region", Namespace: "velero"},
+			},
+			want: false,
+		},
+		{
+			name:             "storage location removed",
+			schedules:        schedules,
+			storageLocations: []storageLocationRef{},
+			want:             true,
+		},
+		{
+			name:      "storage location changed",
+			schedules: schedules,
+			storageLocations: []storageLocationRef{
+				{Name: "other-region", Namespace: "velero"},
+			},
+			want: true,
+		},
+		{
+			name: "storage location added",
+			schedules: &veleroapi.ScheduleList{
+				Items: []veleroapi.Schedule{
+					newSchedule(veleroScheduleNames[Resources], ""),
+				},
+			},
+			storageLocations: []storageLocationRef{
+				{Name: "dr-region", Namespace: "velero"},
+			},
+			want: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isStorageLocationsUpdated(tt.schedules, tt.storageLocations); got != tt.want {
+				t.Errorf("isStorageLocationsUpdated() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_getOrphanedVeleroSchedules(t *testing.T) {
+
+	backupSchedule := initBackupSchedule("0 */6 * * *")
+	backupSchedule.UID = "backup-schedule-uid"
+	isController := true
+	newSchedule := func(name string, ownerUID types.UID) veleroapi.Schedule {
+		schedule := veleroapi.Schedule{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "velero-ns",
+			},
+		}
+		if ownerUID != "" {
+			schedule.OwnerReferences = []metav1.OwnerReference{
+				{
+					APIVersion: v1beta1.GroupVersion.String(),
+					Kind:       "BackupSchedule",
+					Name:       backupSchedule.Name,
+					UID:        ownerUID,
+					Controller: &isController,
+				},
+			}
+		}
+		return schedule
+	}
+	locationScheduleName := getStorageLocationScheduleName(veleroScheduleNames[Resources], "dr-region")
+
+	tests := []struct {
+		name             string
+		schedules        []veleroapi.Schedule
+		storageLocations []storageLocationRef
+		want             []string
+	}{
+		{
+			name: "all schedules created by the backup schedule",
+			schedules: []veleroapi.Schedule{
+				newSchedule(veleroScheduleNames[Resources], backupSchedule.UID),
+				newSchedule(veleroScheduleNames[ValidationSchedule], backupSchedule.UID),
+				newSchedule(locationScheduleName, backupSchedule.UID),
+			},
+			storageLocations: []storageLocationRef{
+				{Name: "dr-region", Namespace: "velero-ns"},
+			},
+			want: []string{},
+		},
+		{
+			name: "backup type removed",
+			schedules: []veleroapi.Schedule{
+				newSchedule(veleroScheduleNames[Resources], backupSchedule.UID),
+				newSchedule("acm-removed-type-schedule", backupSchedule.UID),
+			},
+			want: []string{"acm-removed-type-schedule"},
+		},
+		{
+			name: "storage location removed",
+			schedules: []veleroapi.Schedule{
+				newSchedule(veleroScheduleNames[Resources], backupSchedule.UID),
+				newSchedule(locationScheduleName, backupSchedule.UID),
+			},
+			want: []string{locationScheduleName},
+		},
+		{
+			name: "schedules not controlled by the backup schedule",
+			schedules: []veleroapi.Schedule{
+				newSchedule("acm-removed-type-schedule", "other-backup-schedule-uid"),
+				newSchedule("acm-unowned-schedule", ""),
+			},
+			want: []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []string{}
+			for _, schedule := range getOrphanedVeleroSchedules(tt.schedules, backupSchedule,
+				tt.storageLocations) {
+				got = append(got, schedule.Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getOrphanedVeleroSchedules() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_appendScheduledStorageLocations(t *testing.T) {
+
+	newSchedule := func(storageLocation string) veleroapi.Schedule {
+		return veleroapi.Schedule{
+			Spec: veleroapi.ScheduleSpec{
+				Template: veleroapi.BackupSpec{StorageLocation: storageLocation},
+			},
+		}
+	}
+	veleroStorageLocations := veleroapi.BackupStorageLocationList{
+		Items: []veleroapi.BackupStorageLocation{
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "dr-region", Namespace: "velero-ns"},
+			},
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "unavailable", Namespace: "velero-ns"},
+				Status: veleroapi.BackupStorageLocationStatus{
+					Phase: veleroapi.BackupStorageLocationPhaseUnavailable,
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		name             string
+		schedules        []veleroapi.Schedule
+		storageLocations []storageLocationRef
+		want             []string
+	}{
+		{
+			name:      "default storage location schedules",
+			schedules: []veleroapi.Schedule{newSchedule("")},
+			want:      []string{},
+		},
+		{
+			name:             "valid storage location",
+			schedules:        []veleroapi.Schedule{newSchedule(""), newSchedule("dr-region")},
+			storageLocations: []storageLocationRef{{Name: "dr-region", Namespace: "velero-ns"}},
+			want:             []string{"dr-region"},
+		},
+		{
+			name:             "storage location not valid anymore, schedules kept",
+			schedules:        []veleroapi.Schedule{newSchedule("dr-region"), newSchedule("unavailable")},
+			storageLocations: []storageLocationRef{{Name: "dr-region", Namespace: "velero-ns"}},
+			want:             []string{"dr-region", "unavailable"},
+		},
+		{
+			name:      "storage location deleted",
+			schedules: []veleroapi.Schedule{newSchedule("deleted")},
+			want:      []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []string{}
+			for _, location := range appendScheduledStorageLocations(tt.storageLocations,
+				tt.schedules, veleroStorageLocations) {
+				got = append(got, location.Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("appendScheduledStorageLocations() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_setGenericResourcesBackupInfo_transientResources(t *testing.T) {
+	tests := []struct {
+		name                     string
+		backupTransientResources bool
+		wantExcluded             bool
+	}{
+		{
+			name:                     "transient resources excluded by default",
+			backupTransientResources: false,
+			wantExcluded:             true,
+		},
+		{
+			name:                     "transient resources backed up",
+			backupTransientResources: true,
+			wantExcluded:             false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backupSchedule := initBackupSchedule("0 6 * * *")
+			backupSchedule.Spec.BackupTransientResources = tt.backupTransientResources
+
+			veleroSchedule := &veleroapi.Schedule{}
+			setGenericResourcesBackupInfo(context.Background(),
+				&veleroSchedule.Spec.Template, backupSchedule, nil)
+
+			for _, resource := range []string{"pod", "replicaset.apps", "endpointslice.discovery.k8s.io"} {
+				if got := findValue(veleroSchedule.Spec.Template.ExcludedResources, resource); got != tt.wantExcluded {
+					t.Errorf("setGenericResourcesBackupInfo() %s excluded = %v, want %v",
+						resource, got, tt.wantExcluded)
+				}
+			}
+			if got := areTransientResourcesExcluded(veleroSchedule); got != tt.wantExcluded {
+				t.Errorf("areTransientResourcesExcluded() = %v, want %v", got, tt.wantExcluded)
+			}
+			// resources excluded for all backup schedules
+			if !findValue(veleroSchedule.Spec.Template.ExcludedResources, "secret") {
+				t.Errorf("setGenericResourcesBackupInfo() secret is not excluded")
+			}
+		})
+	}
+}
+
+func Test_getRestorableBackupSets(t *testing.T) {
+
+	backupSetNames := func(timestamp string, resourcesTimestamp string) []string {
+		names := []string{veleroScheduleNames[Resources] + "-" + resourcesTimestamp}
+		for _, backupType := range backupSetTypes {
+			names = append(names, veleroScheduleNames[backupType]+"-"+timestamp)
+		}
+		return names
+	}
+
+	completed := withPhase(veleroapi.BackupPhaseCompleted)
+	incompleteSet := newTestBackupSet("20220420080000", withHubID("hub1"), completed)
+	incompleteSet[1].Status.Phase = veleroapi.BackupPhasePartiallyFailed
+	otherHubSet := newTestBackupSet("20220420100000", withHubID("hub1"), completed)
+	otherHubSet[2].Labels[BackupScheduleClusterLabel] = "hub2"
+	// a backup set member stored in another storage location is not part of the set
+	otherLocationSet := newTestBackupSet("20220420090000", withHubID("hub1"), completed)
+	otherLocationSet[2].Spec.StorageLocation = "dr-region"
+
+	// the resources backup of a set can complete a few seconds after the other backups
+	lateResourcesSet := newTestBackupSet("20220420120000", withHubID("hub2"), completed)
+	lateResourcesSet[0].Name = veleroScheduleNames[Resources] + "-20220420120020"
+
+	backups := append(newTestBackupSet("20220420060000", withHubID("hub1"), completed),
+		lateResourcesSet...)
+	backups = append(backups, incompleteSet...)
+	backups = append(backups, otherHubSet...)
+	backups = append(backups, otherLocationSet...)
+	backups = append(backups,
+		*newTestBackup(
+			veleroScheduleNames[ValidationSchedule]+"-20220420140000",
+			withHubID("hub1"),
+			withPhase(veleroapi.BackupPhaseCompleted),
+		),
+	)
+
+	// backup types scheduled using veleroScheduleOverrides, backed up at other times
+	overriddenBackups := []veleroapi.Backup{
+		*newTestBackup(
+			veleroScheduleNames[ManagedClusters]+"-20220420020000",
+			withHubID("hub1"),
+			withPhase(veleroapi.BackupPhaseCompleted),
+		),
+		*newTestBackup(
+			veleroScheduleNames[Credentials]+"-20220420100000",
+			withHubID("hub1"),
+			withPhase(veleroapi.BackupPhaseCompleted),
+		),
+		*newTestBackup(
+			veleroScheduleNames[CredentialsHive]+"-20220420100000",
+			withHubID("hub1"),
+			withPhase(veleroapi.BackupPhaseCompleted),
+		),
+		*newTestBackup(
+			veleroScheduleNames[CredentialsCluster]+"-20220420100000",
+			withHubID("hub1"),
+			withPhase(veleroapi.BackupPhaseCompleted),
+		),
+		*newTestBackup(
+			veleroScheduleNames[Credentials]+"-20220420110000",
+			withHubID("hub1"),
+			withPhase(veleroapi.BackupPhaseCompleted),
+		),
+		*newTestBackup(
+			veleroScheduleNames[CredentialsHive]+"-20220420110000",
+			withHubID("hub1"),
+			withPhase(veleroapi.BackupPhaseFailed),
+		),
+		*newTestBackup(
+			veleroScheduleNames[CredentialsCluster]+"-20220420110000",
+			withHubID("hub1"),
+			withPhase(veleroapi.BackupPhaseCompleted),
+		),
+		*newTestBackup(
+			veleroScheduleNames[Resources]+"-20220420100010",
+			withHubID("hub1"),
+			withPhase(veleroapi.BackupPhaseCompleted),
+		),
+		*newTestBackup(
+			veleroScheduleNames[ResourcesGeneric]+"-20220420100010",
+			withHubID("hub1"),
+			withPhase(veleroapi.BackupPhaseCompleted),
+		),
+		*newTestBackup(
+			veleroScheduleNames[Resources]+"-20220420120000",
+			withHubID("hub1"),
+			withPhase(veleroapi.BackupPhaseCompleted),
+		),
+		*newTestBackup(
+			veleroScheduleNames[ResourcesGeneric]+"-20220420120000",
+			withHubID("hub1"),
+			withPhase(veleroapi.BackupPhaseCompleted),
+		),
+		*newTestBackup(
+			veleroScheduleNames[Credentials]+"-20220420130000",
+			withHubID("hub1"),
+			withPhase(veleroapi.BackupPhaseCompleted),
+		),
+		*newTestBackup(
+			veleroScheduleNames[CredentialsHive]+"-20220420130000",
+			withHubID("hub1"),
+			withPhase(veleroapi.BackupPhaseCompleted),
+		),
+		*newTestBackup(
+			veleroScheduleNames[CredentialsCluster]+"-20220420130000",
+			withHubID("hub1"),
+			withPhase(veleroapi.BackupPhaseCompleted),
+		),
+	}
+
+	manyBackups := []veleroapi.Backup{}
+	for hour := 0; hour < maxRestorableBackupSets+2; hour++ {
+		timestamp := fmt.Sprintf("20220420%02d0000", hour)
+		manyBackups = append(manyBackups, newTestBackupSet(timestamp, withHubID("hub1"), completed)...)
+	}
+
+	tests := []struct {
+		name      string
+		backups   []veleroapi.Backup
+		wantNames [][]string
+		wantHubs  []string
+	}{
+		{
+			name:      "no backups",
+			backups:   []veleroapi.Backup{},
+			wantNames: [][]string{},
+			wantHubs:  []string{},
+		},
+		{
+			name:    "complete backup sets, most recent first",
+			backups: backups,
+			wantNames: [][]string{
+				backupSetNames("20220420120000", "20220420120020"),
+				backupSetNames("20220420060000", "20220420060000"),
+			},
+			wantHubs: []string{"hub2", "hub1"},
+		},
+		{
+			name:    "backup types backed up at other times use their previous backup",
+			backups: overriddenBackups,
+			wantNames: [][]string{
+				{
+					"acm-resources-schedule-20220420100010",
+					"acm-credentials-schedule-20220420100000",
+					"acm-credentials-hive-schedule-20220420100000",
+					"acm-credentials-cluster-schedule-20220420100000",
+					"acm-resources-generic-schedule-20220420100010",
+					"acm-managed-clusters-schedule-20220420020000",
+				},
+			},
+			wantHubs: []string{"hub1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := getRestorableBackupSets(tt.backups)
+			gotNames := [][]string{}
+			gotHubs := []string{}
+			for i := range got {
+				gotNames = append(gotNames, got[i].Backups)
+				gotHubs = append(gotHubs, got[i].HubID)
+				if got[i].Name != got[i].Backups[0] {
+					t.Errorf("getRestorableBackupSets() name = %v, want %v", got[i].Name, got[i].Backups[0])
+				}
+			}
+			if !reflect.DeepEqual(gotNames, tt.wantNames) {
+				t.Errorf("getRestorableBackupSets() backups = %v, want %v", gotNames, tt.wantNames)
+			}
+			if !reflect.DeepEqual(gotHubs, tt.wantHubs) {
+				t.Errorf("getRestorableBackupSets() hubs = %v, want %v", gotHubs, tt.wantHubs)
+			}
+		})
+	}
+
+	t.Run("backup sets limit", func(t *testing.T) {
+		got := getRestorableBackupSets(manyBackups)
+		if len(got) != maxRestorableBackupSets {
+			t.Errorf("getRestorableBackupSets() returned %d sets, want %d", len(got), maxRestorableBackupSets)
+		}
+		if got[0].Name != veleroScheduleNames[Resources]+"-20220420110000" {
+			t.Errorf("getRestorableBackupSets() most recent = %v", got[0].Name)
+		}
+	})
+}
+
+func Test_GetBackupSets(t *testing.T) {
+
+	backupName := func(backupType ResourceType, timestamp string) string {
+		return veleroScheduleNames[backupType] + "-" + timestamp
+	}
+
+	tests := []struct {
+		name    string
+		backups []veleroapi.Backup
+		want    map[string][]string
+	}{
+		{
+			name:    "no backups",
+			backups: []veleroapi.Backup{},
+			want:    map[string][]string{},
+		},
+		{
+			name: "backups of a run created a few seconds apart",
+			backups: []veleroapi.Backup{
+				*newTestBackup(backupName(ManagedClusters, "20220420120005")),
+				*newTestBackup(backupName(Credentials, "20220420120000")),
+				*newTestBackup(backupName(Resources, "20220420120002")),
+			},
+			want: map[string][]string{
+				"20220420120000": {
+					backupName(Credentials, "20220420120000"),
+					backupName(Resources, "20220420120002"),
+					backupName(ManagedClusters, "20220420120005"),
+				},
+			},
+		},
+		{
+			name: "set with a missing type",
+			backups: []veleroapi.Backup{
+				*newTestBackup(backupName(Credentials, "20220420120000")),
+				*newTestBackup(backupName(Resources, "20220420120000")),
+				*newTestBackup(backupName(Credentials, "20220420130000")),
+				*newTestBackup(backupName(Resources, "20220420130000")),
+				*newTestBackup(backupName(ManagedClusters, "20220420130000")),
+			},
+			want: map[string][]string{
+				"20220420120000": {
+					backupName(Credentials, "20220420120000"),
+					backupName(Resources, "20220420120000"),
+				},
+				"20220420130000": {
+					backupName(Credentials, "20220420130000"),
+					backupName(Resources, "20220420130000"),
+					backupName(ManagedClusters, "20220420130000"),
+				},
+			},
+		},
+		{
+			name: "backups outside the tolerance are in a new set",
+			backups: []veleroapi.Backup{
+				*newTestBackup(backupName(Resources, "20220420120000")),
+				*newTestBackup(backupName(ManagedClusters, "20220420120100")),
+			},
+			want: map[string][]string{
+				"20220420120000": {backupName(Resources, "20220420120000")},
+				"20220420120100": {backupName(ManagedClusters, "20220420120100")},
+			},
+		},
+		{
+			name: "validation, manual and not timestamped backups are ignored",
+			backups: []veleroapi.Backup{
+				*newTestBackup(backupName(Resources, "20220420120000")),
+				*newTestBackup(backupName(ValidationSchedule, "20220420120000")),
+				*newTestBackup("manual-backup-20220420120000"),
+				*newTestBackup(veleroScheduleNames[ManagedClusters]),
+			},
+			want: map[string][]string{
+				"20220420120000": {backupName(Resources, "20220420120000")},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := map[string][]string{}
+			for key, backups := range GetBackupSets(tt.backups) {
+				for i := range backups {
+					got[key] = append(got[key], backups[i].Name)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetBackupSets() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_pruneOldBackups(t *testing.T) {
+
+	backupNames := func(backups []veleroapi.Backup) []string {
+		names := []string{}
+		for i := range backups {
+			names = append(names, backups[i].Name)
+		}
+		sort.Strings(names)
+		return names
+	}
+	joinBackups := func(sets ...[]veleroapi.Backup) []veleroapi.Backup {
+		backups := []veleroapi.Backup{}
+		for _, set := range sets {
+			backups = append(backups, set...)
+		}
+		return backups
+	}
+
+	set10 := newTestBackupSet("20220420100000", withPhase(veleroapi.BackupPhaseCompleted))
+	set11 := newTestBackupSet("20220420110000", withPhase(veleroapi.BackupPhaseCompleted))
+	set12 := newTestBackupSet("20220420120000", withPhase(veleroapi.BackupPhaseCompleted))
+	set13InProgress := newTestBackupSet("20220420130000", withPhase(veleroapi.BackupPhaseInProgress))
+	set11Deleting := newTestBackupSet("20220420110000", withPhase(veleroapi.BackupPhaseDeleting))
+	// the same backup set written to an additional storage location, same timestamp
+	newLocationSet := func(set []veleroapi.Backup, timestamp string) []veleroapi.Backup {
+		locationSet := []veleroapi.Backup{}
+		for _, backup := range set {
+			backup.Name = getStorageLocationScheduleName(strings.TrimSuffix(backup.Name,
+				"-"+timestamp), "dr-region") + "-" + timestamp
+			backup.Spec.StorageLocation = "dr-region"
+			locationSet = append(locationSet, backup)
+		}
+		return locationSet
+	}
+	set11Location := newLocationSet(set11, "20220420110000")
+	set12Location := newLocationSet(set12, "20220420120000")
+	// the resources backups of a set; the other types use a veleroScheduleOverrides cron
+	resourcesOnly := func(set []veleroapi.Backup) []veleroapi.Backup {
+		backups := []veleroapi.Backup{}
+		for _, backup := range set {
+			if getOverrideBackupType(getBackupSetType(backup.Name)) == Resources {
+				backups = append(backups, backup)
+			}
+		}
+		return backups
+	}
+
+	tests := []struct {
+		name    string
+		backups []veleroapi.Backup
+		keepN   int
+		want    []veleroapi.Backup
+	}{
+		{
+			name:    "max backups not set",
+			backups: joinBackups(set10, set11, set12),
+			keepN:   0,
+			want:    []veleroapi.Backup{},
+		},
+		{
+			name:    "no more complete sets than the count",
+			backups: joinBackups(set10, set11, set12),
+			keepN:   3,
+			want:    []veleroapi.Backup{},
+		},
+		{
+			name:    "oldest sets deleted",
+			backups: joinBackups(set12, set10, set11),
+			keepN:   1,
+			want:    joinBackups(set10, set11),
+		},
+		{
+			name:    "incomplete recent set kept, not counted",
+			backups: joinBackups(set10, set11, set12, set13InProgress),
+			keepN:   2,
+			want:    set10,
+		},
+		{
+			name:    "backups already deleting are ignored",
+			backups: joinBackups(set10, set11Deleting, set12),
+			keepN:   1,
+			want:    set10,
+		},
+		{
+			name:    "backups with the same timestamp are kept together",
+			backups: joinBackups(set11, set12, set12Location),
+			keepN:   1,
+			want:    set11,
+		},
+		{
+			name:    "the sets of each storage location are kept",
+			backups: joinBackups(set11, set11Location, set12),
+			keepN:   1,
+			want:    set11,
+		},
+		{
+			name:    "the oldest sets of each storage location are deleted",
+			backups: joinBackups(set11, set11Location, set12, set12Location),
+			keepN:   1,
+			want:    joinBackups(set11, set11Location),
+		},
+		{
+			name:    "older backups of a kept set are kept",
+			backups: joinBackups(set10, set11, resourcesOnly(set12)),
+			keepN:   1,
+			want:    joinBackups(set10, resourcesOnly(set11)),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := pruneOldBackups(tt.backups, tt.keepN)
+			if !reflect.DeepEqual(backupNames(got), backupNames(tt.want)) {
+				t.Errorf("pruneOldBackups() = %v, want %v", backupNames(got), backupNames(tt.want))
+			}
+		})
+	}
+}
+
+func Test_getBackupNowBackups(t *testing.T) {
+
+	now := time.Date(2022, 4, 20, 12, 0, 0, 0, time.UTC)
+	newVeleroSchedule := func(backupType ResourceType, name string) veleroapi.Schedule {
+		return veleroapi.Schedule{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "velero-ns",
+				Labels: map[string]string{
+					BackupScheduleNameLabel: "schedule-acm",
+					BackupScheduleTypeLabel: string(backupType),
+				},
+			},
+			Spec: veleroapi.ScheduleSpec{
+				Schedule: "0 */6 * * *",
+				Template: veleroapi.BackupSpec{
+					IncludedNamespaces: []string{"*"},
+					StorageLocation:    "dr-region",
+				},
+			},
+		}
+	}
+
+	veleroSchedules := []veleroapi.Schedule{
+		newVeleroSchedule(Resources, veleroScheduleNames[Resources]),
+		newVeleroSchedule(ValidationSchedule, veleroScheduleNames[ValidationSchedule]),
+		newVeleroSchedule(Credentials, veleroScheduleNames[Credentials]),
+	}
+	got := getBackupNowBackups(veleroSchedules, now)
+
+	wantNames := []string{
+		"acm-credentials-schedule-20220420120000",
+		"acm-resources-schedule-20220420120000",
+	}
+	gotNames := []string{}
+	for i := range got {
+		gotNames = append(gotNames, got[i].Name)
+	}
+	if !reflect.DeepEqual(gotNames, wantNames) {
+		t.Fatalf("getBackupNowBackups() = %v, want %v", gotNames, wantNames)
+	}
+	// the backups are part of a backup set, like the backups created by the velero schedules
+	if setType := getBackupSetType(got[1].Name); setType != Resources {
+		t.Errorf("getBackupNowBackups() backup set type = %v, want %v", setType, Resources)
+	}
+	wantLabels := map[string]string{
+		BackupScheduleNameLabel:   "schedule-acm",
+		BackupScheduleTypeLabel:   string(Resources),
+		"velero.io/schedule-name": veleroScheduleNames[Resources],
+	}
+	if !reflect.DeepEqual(got[1].Labels, wantLabels) {
+		t.Errorf("getBackupNowBackups() labels = %v, want %v", got[1].Labels, wantLabels)
+	}
+	if !reflect.DeepEqual(got[1].Spec, veleroSchedules[0].Spec.Template) {
+		t.Errorf("getBackupNowBackups() spec = %v, want %v", got[1].Spec, veleroSchedules[0].Spec.Template)
+	}
+	if got[1].Namespace != "velero-ns" {
+		t.Errorf("getBackupNowBackups() namespace = %v, want velero-ns", got[1].Namespace)
+	}
+}
+
+func Test_getLastSuccessfulBackups(t *testing.T) {
+
+	unknownType := *newTestBackup(
+		veleroScheduleNames[Resources]+"-20220420160000",
+		withBackupType(Resources),
+		withPhase(veleroapi.BackupPhaseCompleted),
+	)
+	unknownType.Labels[BackupScheduleTypeLabel] = "unknown"
+	noTimestamp := *newTestBackup(
+		veleroScheduleNames[ManagedClusters]+"-20220420160000",
+		withBackupType(ManagedClusters),
+		withPhase(veleroapi.BackupPhaseCompleted),
+	)
+	noTimestamp.Name = "acm-managed-clusters-backup"
+
+	tests := []struct {
+		name    string
+		backups []veleroapi.Backup
+		want    []v1beta1.LastSuccessfulBackup
+	}{
+		{
+			name:    "no backups",
+			backups: []veleroapi.Backup{},
+			want:    []v1beta1.LastSuccessfulBackup{},
+		},
+		{
+			name: "latest completed backup for each type",
+			backups: []veleroapi.Backup{
+				*newTestBackup(
+					veleroScheduleNames[Resources]+"-20220420120000",
+					withBackupType(Resources),
+					withPhase(veleroapi.BackupPhaseCompleted),
+				),
+				*newTestBackup(
+					veleroScheduleNames[Resources]+"-20220420140000",
+					withBackupType(Resources),
+					withPhase(veleroapi.BackupPhaseCompleted),
+				),
+				*newTestBackup(
+					veleroScheduleNames[Resources]+"-20220420100000",
+					withBackupType(Resources),
+					withPhase(veleroapi.BackupPhaseCompleted),
+				),
+				*newTestBackup(
+					veleroScheduleNames[Resources]+"-20220420150000",
+					withBackupType(Resources),
+					withPhase(veleroapi.BackupPhaseFailed),
+				),
+				*newTestBackup(
+					veleroScheduleNames[Credentials]+"-20220420140000",
+					withBackupType(Credentials),
+					withPhase(veleroapi.BackupPhasePartiallyFailed),
+				),
+				*newTestBackup(
+					veleroScheduleNames[Credentials]+"-20220420120000",
+					withBackupType(Credentials),
+					withPhase(veleroapi.BackupPhaseCompleted),
+				),
+				*newTestBackup(
+					veleroScheduleNames[ManagedClusters]+"-20220420140000",
+					withBackupType(ManagedClusters),
+					withPhase(veleroapi.BackupPhaseCompleted),
+				),
+				*newTestBackup(
+					veleroScheduleNames[ResourcesGeneric]+"-20220420150000",
+					withBackupType(ResourcesGeneric),
+					withPhase(veleroapi.BackupPhaseInProgress),
+				),
+				*newTestBackup(
+					veleroScheduleNames[ValidationSchedule]+"-20220420140000",
+					withBackupType(ValidationSchedule),
+					withPhase(veleroapi.BackupPhaseCompleted),
+				),
+				unknownType,
+				noTimestamp,
+			},
+			want: []v1beta1.LastSuccessfulBackup{
+				{
+					BackupType:              string(Credentials),
+					LastSuccessfulTimestamp: metav1.Date(2022, 4, 20, 12, 0, 0, 0, time.UTC),
+					LastBackupName:          "acm-credentials-schedule-20220420120000",
+				},
+				{
+					BackupType:              string(ManagedClusters),
+					LastSuccessfulTimestamp: metav1.Date(2022, 4, 20, 14, 0, 0, 0, time.UTC),
+					LastBackupName:          "acm-managed-clusters-schedule-20220420140000",
+				},
+				{
+					BackupType:              string(Resources),
+					LastSuccessfulTimestamp: metav1.Date(2022, 4, 20, 14, 0, 0, 0, time.UTC),
+					LastBackupName:          "acm-resources-schedule-20220420140000",
+				},
+				{
+					BackupType:              ValidationSchedule,
+					LastSuccessfulTimestamp: metav1.Date(2022, 4, 20, 14, 0, 0, 0, time.UTC),
+					LastBackupName:          "acm-validation-policy-schedule-20220420140000",
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := getLastSuccessfulBackups(tt.backups); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getLastSuccessfulBackups() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_getStorageLocationStats(t *testing.T) {
+
+	labeledBackup := *newTestBackup(
+		"acm-credentials-schedule-20220420120000",
+		withProgress(&veleroapi.BackupProgress{ItemsBackedUp: 5}),
+	)
+	labeledBackup.Labels = map[string]string{veleroapi.StorageLocationLabel: "dr-region"}
+
+	storageLocations := []storageLocationRef{
+		{Name: "primary", Namespace: "velero-ns", Default: true},
+		{Name: "dr-region", Namespace: "velero-ns"},
+	}
+
+	tests := []struct {
+		name             string
+		storageLocations []storageLocationRef
+		backups          []veleroapi.Backup
+		want             []v1beta1.StorageLocationStats
+	}{
+		{
+			name:             "no storage locations",
+			storageLocations: nil,
+			backups: []veleroapi.Backup{*newTestBackup(
+				"acm-resources-schedule-1",
+				withStorageLocation("primary"),
+				withProgress(&veleroapi.BackupProgress{ItemsBackedUp: 10}),
+			)},
+			want: nil,
+		},
+		{
+			name:             "no backups",
+			storageLocations: storageLocations,
+			backups:          nil,
+			want: []v1beta1.StorageLocationStats{
+				{Name: "dr-region"},
+				{Name: "primary"},
+			},
+		},
+		{
+			name:             "backups summed by storage location",
+			storageLocations: storageLocations,
+			backups: []veleroapi.Backup{
+				*newTestBackup(
+					"acm-resources-schedule-20220420120000",
+					withStorageLocation("primary"),
+					withProgress(&veleroapi.BackupProgress{ItemsBackedUp: 120}),
+				),
+				*newTestBackup(
+					"acm-resources-schedule-20220420130000",
+					withStorageLocation("primary"),
+					withProgress(&veleroapi.BackupProgress{ItemsBackedUp: 80}),
+				),
+				*newTestBackup(
+					"acm-managed-clusters-schedule-20220420120000",
+					withStorageLocation("primary"),
+				),
+				*newTestBackup(
+					"acm-resources-schedule-20220420120001",
+					withStorageLocation("dr-region"),
+					withProgress(&veleroapi.BackupProgress{ItemsBackedUp: 120}),
+				),
+				labeledBackup,
+				*newTestBackup(
+					"acm-resources-schedule-20220420120002",
+					withStorageLocation("unavailable"),
+					withProgress(&veleroapi.BackupProgress{ItemsBackedUp: 40}),
+				),
+			},
+			want: []v1beta1.StorageLocationStats{
+				{Name: "dr-region", Backups: 2, ItemsBackedUp: 125},
+				{Name: "primary", Backups: 3, ItemsBackedUp: 200},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := getStorageLocationStats(tt.storageLocations, tt.backups); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getStorageLocationStats() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// a client.Client failing to get the resources
+type failingGetClient struct {
+	client.Client
+	err error
+}
+
+func (c *failingGetClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+	return c.err
+}
+
+func Test_getBackedUpResourceKinds(t *testing.T) {
+
+	includedResources := []string{"configmap", "channel.apps.open-cluster-management.io"}
+
+	tests := []struct {
+		name         string
+		backup       *veleroapi.Backup
+		want         []ResourceType
+		wantBackedUp bool
+	}{
+		{
+			name: "backup in progress",
+			backup: newTestBackup(
+				"acm-resources-schedule-20220420120000",
+				withResources(includedResources, nil),
+				withPhase(veleroapi.BackupPhaseInProgress),
+				withItemsBackedUp(10),
+			),
+			want:         nil,
+			wantBackedUp: true,
+		},
+		{
+			name: "completed backup with no item",
+			backup: newTestBackup(
+				"acm-resources-schedule-20220420120000",
+				withResources(includedResources, nil),
+				withPhase(veleroapi.BackupPhaseCompleted),
+				withItemsBackedUp(0),
+			),
+			want:         nil,
+			wantBackedUp: true,
+		},
+		{
+			name: "resource list not downloaded, requested kinds",
+			backup: newTestBackup(
+				"acm-resources-schedule-20220420120000",
+				withResources(includedResources, nil),
+				withPhase(veleroapi.BackupPhaseCompleted),
+				withItemsBackedUp(10),
+			),
+			want: []ResourceType{
+				"channel.apps.open-cluster-management.io",
+				"configmap",
+			},
+			wantBackedUp: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &BackupScheduleReconciler{
+				Client: &failingGetClient{err: fmt.Errorf("downloadrequests not found")},
+			}
+			got, backedUp := r.getBackedUpResourceKinds(context.Background(), tt.backup, nil)
+			if !reflect.DeepEqual(got, tt.want) || backedUp != tt.wantBackedUp {
+				t.Errorf("getBackedUpResourceKinds() = %v %v, want %v %v",
+					got, backedUp, tt.want, tt.wantBackedUp)
+			}
+		})
+	}
+}
+
+func Test_getRequestedResourceKinds(t *testing.T) {
+
+	includedResources := []string{
+		"placement.cluster.open-cluster-management.io",
+		"Channel.apps.open-cluster-management.io",
+		"policy.policy.open-cluster-management.io",
+		"channel.apps.open-cluster-management.io",
+		"configmap",
+		"clusterdeployment.hive.openshift.io",
+	}
+
+	tests := []struct {
+		name   string
+		backup *veleroapi.Backup
+		want   []ResourceType
+	}{
+		{
+			name: "backup with included resources",
+			backup: newTestBackup(
+				"acm-resources-schedule-20220420120000",
+				withResources(includedResources, nil),
+			),
+			want: []ResourceType{
+				"channel.apps.open-cluster-management.io",
+				"placement.cluster.open-cluster-management.io",
+				"clusterdeployment.hive.openshift.io",
+				"policy.policy.open-cluster-management.io",
+				"configmap",
+			},
+		},
+		{
+			name: "backup with excluded resources",
+			backup: newTestBackup(
+				"acm-resources-schedule-20220420120000",
+				withResources(includedResources, []string{"ClusterDeployment.hive.openshift.io", "policy"}),
+			),
+			want: []ResourceType{
+				"channel.apps.open-cluster-management.io",
+				"placement.cluster.open-cluster-management.io",
+				"configmap",
+			},
+		},
+		{
+			name: "backup with no included resources",
+			backup: newTestBackup(
+				"acm-resources-schedule-20220420120000",
+				withResources(nil, []string{"config.group0.open-cluster-management.io"}),
+			),
+			want: []ResourceType{
+				"policy.group0.open-cluster-management.io",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			invalidateDiscoveryCache()
+			r := &BackupScheduleReconciler{DiscoveryClient: newCountingDiscovery(t, 1)}
+			got := r.getRequestedResourceKinds(context.Background(), tt.backup, nil)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getRequestedResourceKinds() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	invalidateDiscoveryCache()
+}
+
+func Test_getResourceListKinds(t *testing.T) {
+
+	tests := []struct {
+		name         string
+		resourceList map[string][]string
+		want         []ResourceType
+	}{
+		{
+			name:         "empty resource list",
+			resourceList: map[string][]string{},
+			want:         []ResourceType{},
+		},
+		{
+			name: "core and grouped kinds",
+			resourceList: map[string][]string{
+				"v1/Secret":    {"ns1/secret1", "ns2/secret2"},
+				"v1/ConfigMap": {"ns1/config"},
+				"cluster.open-cluster-management.io/v1/ManagedCluster":         {"cluster1"},
+				"apps.open-cluster-management.io/v1/Channel":                   {"ns1/channel"},
+				"cluster.open-cluster-management.io/v1beta1/PlacementDecision": {"ns1/decision"},
+			},
+			want: []ResourceType{
+				"channel.apps.open-cluster-management.io",
+				"managedcluster.cluster.open-cluster-management.io",
+				"placementdecision.cluster.open-cluster-management.io",
+				"configmap",
+				"secret",
+			},
+		},
+		{
+			name: "invalid entries are skipped",
+			resourceList: map[string][]string{
+				"Secret":          {"ns1/secret1"},
+				"a/b/c/ConfigMap": {"ns1/config"},
+				"v1/Namespace":    {"ns1"},
+			},
+			want: []ResourceType{"namespace"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := getResourceListKinds(tt.resourceList); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getResourceListKinds() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_downloadBackupResourceList(t *testing.T) {
+
+	resourceList := map[string][]string{
+		"v1/Secret": {"ns1/secret1"},
+		"cluster.open-cluster-management.io/v1/ManagedCluster": {"cluster1"},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/resource-list":
+			gzipWriter := gzip.NewWriter(w)
+			_ = json.NewEncoder(gzipWriter).Encode(resourceList)
+			gzipWriter.Close()
+		case "/not-gzipped":
+			_ = json.NewEncoder(w).Encode(resourceList)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		path    string
+		want    map[string][]string
+		wantErr bool
+	}{
+		{
+			name: "resource list downloaded",
+			path: "/resource-list",
+			want: resourceList,
+		},
+		{
+			name:    "resource list not found",
+			path:    "/expired",
+			wantErr: true,
+		},
+		{
+			name:    "resource list not gzipped",
+			path:    "/not-gzipped",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := downloadBackupResourceList(context.Background(),
+				server.URL+tt.path, &tls.Config{MinVersion: tls.VersionTLS12})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("downloadBackupResourceList() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("downloadBackupResourceList() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_setBackedUpResourceKinds(t *testing.T) {
+
+	// only the first kinds are shown in the status, with the kinds count
+	resources := []string{}
+	for i := 0; i < maxReportedResourceKinds+2; i++ {
+		resources = append(resources, fmt.Sprintf("kind%02d.apps.open-cluster-management.io", i))
+	}
+	backup := newTestBackup(
+		"acm-resources-schedule-20220420120000",
+		withResources(resources, nil),
+		withPhase(veleroapi.BackupPhaseCompleted),
+		withItemsBackedUp(20),
+	)
+	backupSchedule := initBackupSchedule("0 6 * * *")
+	backupSchedule.Status.LastSuccessfulBackups = []v1beta1.LastSuccessfulBackup{
+		{BackupType: string(Resources), LastBackupName: backup.Name},
+	}
+	r := &BackupScheduleReconciler{
+		Client: &failingGetClient{err: fmt.Errorf("downloadrequests not found")},
+	}
+	r.setBackedUpResourceKinds(context.Background(), backupSchedule, nil, []veleroapi.Backup{*backup})
+	lastBackup := backupSchedule.Status.LastSuccessfulBackups[0]
+	if lastBackup.ResourceKindsCount != maxReportedResourceKinds+2 ||
+		!reflect.DeepEqual(lastBackup.ResourceKinds, resources[:maxReportedResourceKinds]) ||
+		!lastBackup.ResourceKindsRequested {
+		t.Errorf("setBackedUpResourceKinds() = %v %v %v, want %v %v true",
+			lastBackup.ResourceKindsCount, lastBackup.ResourceKinds, lastBackup.ResourceKindsRequested,
+			maxReportedResourceKinds+2, resources[:maxReportedResourceKinds])
+	}
+
+	// the kinds shown for the same backup are kept, the resource list is not downloaded again
+	previousBackups := []v1beta1.LastSuccessfulBackup{
+		{
+			BackupType:         string(Resources),
+			LastBackupName:     backup.Name,
+			ResourceKindsCount: 1,
+			ResourceKinds:      []string{"channel.apps.open-cluster-management.io"},
+		},
+	}
+	backupSchedule.Status.LastSuccessfulBackups = []v1beta1.LastSuccessfulBackup{
+		{BackupType: string(Resources), LastBackupName: backup.Name},
+	}
+	r.setBackedUpResourceKinds(context.Background(), backupSchedule, previousBackups,
+		[]veleroapi.Backup{*backup})
+	if got := backupSchedule.Status.LastSuccessfulBackups[0]; !reflect.DeepEqual(got, previousBackups[0]) {
+		t.Errorf("setBackedUpResourceKinds() = %v, want %v", got, previousBackups[0])
+	}
+}
+
+func Test_getInProgressBackupsStatus(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		backups []veleroapi.Backup
+		want    []v1beta1.InProgressBackup
+	}{
+		{
+			name:    "no backups",
+			backups: []veleroapi.Backup{},
+			want:    []v1beta1.InProgressBackup{},
+		},
+		{
+			name: "backups with partial progress",
+			backups: []veleroapi.Backup{
+				*newTestBackup(
+					veleroScheduleNames[Resources]+"-20220420120000",
+					withBackupType(Resources),
+					withPhase(veleroapi.BackupPhaseInProgress),
+					withProgress(&veleroapi.BackupProgress{ItemsBackedUp: 120, TotalItems: 480}),
+				),
+				*newTestBackup(
+					veleroScheduleNames[Credentials]+"-20220420120000",
+					withBackupType(Credentials),
+					withPhase(veleroapi.BackupPhaseCompleted),
+					withProgress(&veleroapi.BackupProgress{ItemsBackedUp: 30, TotalItems: 30}),
+				),
+				*newTestBackup(
+					veleroScheduleNames[ManagedClusters]+"-20220420120000",
+					withBackupType(ManagedClusters),
+					withPhase(veleroapi.BackupPhaseNew),
+				),
+				*newTestBackup(
+					veleroScheduleNames[ValidationSchedule]+"-20220420120000",
+					withBackupType(ValidationSchedule),
+					withPhase(veleroapi.BackupPhaseInProgress),
+					withProgress(&veleroapi.BackupProgress{ItemsBackedUp: 1, TotalItems: 2}),
+				),
+			},
+			want: []v1beta1.InProgressBackup{
+				{
+					BackupType: string(ManagedClusters),
+					BackupName: "acm-managed-clusters-schedule-20220420120000",
+				},
+				{
+					BackupType:    string(Resources),
+					BackupName:    "acm-resources-schedule-20220420120000",
+					ItemsBackedUp: 120,
+					TotalItems:    480,
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := getInProgressBackupsStatus(tt.backups); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getInProgressBackupsStatus() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_getBackupBackupSchedule(t *testing.T) {
+
+	backup := &veleroapi.Backup{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "acm-resources-schedule-20220420120000",
+			Namespace: "velero-ns",
+			Labels: map[string]string{
+				BackupScheduleNameLabel: "schedule-acm",
+			},
+		},
+	}
+	want := []reconcile.Request{
+		{NamespacedName: types.NamespacedName{Name: "schedule-acm", Namespace: "velero-ns"}},
+	}
+	if got := getBackupBackupSchedule(backup); !reflect.DeepEqual(got, want) {
+		t.Errorf("getBackupBackupSchedule() = %v, want %v", got, want)
+	}
+
+	// backups not created by a backup schedule are ignored
+	backup.Labels = nil
+	if got := getBackupBackupSchedule(backup); len(got) != 0 {
+		t.Errorf("getBackupBackupSchedule() = %v, want no request", got)
+	}
+}
+
+func Test_getResourcesFingerprint(t *testing.T) {
+
+	newResource := func(kind, namespace, name, resourceVersion string) unstructured.Unstructured {
+		resource := unstructured.Unstructured{}
+		resource.SetAPIVersion("v1")
+		resource.SetKind(kind)
+		resource.SetNamespace(namespace)
+		resource.SetName(name)
+		resource.SetResourceVersion(resourceVersion)
+		return resource
+	}
+	resources := []unstructured.Unstructured{
+		newResource("ConfigMap", "ns1", "config", "100"),
+		newResource("Secret", "ns1", "secret", "101"),
+	}
+	fingerprint := getResourcesFingerprint(resources)
+	if !strings.HasPrefix(fingerprint, "2-") {
+		t.Errorf("getResourcesFingerprint() = %v, want the resources count prefix 2-", fingerprint)
+	}
+
+	tests := []struct {
+		name      string
+		resources []unstructured.Unstructured
+		unchanged bool
+	}{
+		{
+			name:      "same resources",
+			resources: resources,
+			unchanged: true,
+		},
+		{
+			name: "same resources in a different order",
+			resources: []unstructured.Unstructured{
+				newResource("Secret", "ns1", "secret", "101"),
+				newResource("ConfigMap", "ns1", "config", "100"),
+			},
+			unchanged: true,
+		},
+		{
+			name: "resource updated",
+			resources: []unstructured.Unstructured{
+				newResource("ConfigMap", "ns1", "config", "100"),
+				newResource("Secret", "ns1", "secret", "102"),
+			},
+		},
+		{
+			name: "resource added",
+			resources: append([]unstructured.Unstructured{
+				newResource("ConfigMap", "ns2", "config", "103"),
+			}, resources...),
+		},
+		{
+			name:      "resource deleted",
+			resources: resources[:1],
+		},
+		{
+			name: "resource recreated with another name",
+			resources: []unstructured.Unstructured{
+				newResource("ConfigMap", "ns1", "config", "100"),
+				newResource("Secret", "ns1", "secret-new", "101"),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := getResourcesFingerprint(tt.resources)
+			if unchanged := got == fingerprint; unchanged != tt.unchanged {
+				t.Errorf("getResourcesFingerprint() = %v, fingerprint %v, want unchanged %v",
+					got, fingerprint, tt.unchanged)
+			}
+		})
+	}
+}
+
+func Test_hasRecentBackupSet(t *testing.T) {
+
+	now := time.Date(2022, 4, 20, 12, 0, 0, 0, time.UTC)
+	newBackupSet := func(hubID string, age time.Duration) v1beta1.RestorableBackupSet {
+		return v1beta1.RestorableBackupSet{
+			Name:      "acm-resources-schedule-" + now.Add(-age).Format(backupTimestampLayout),
+			Timestamp: metav1.NewTime(now.Add(-age)),
+			HubID:     hubID,
+		}
+	}
+
+	tests := []struct {
+		name       string
+		ttl        time.Duration
+		backupSets []v1beta1.RestorableBackupSet
+		want       bool
+	}{
+		{
+			name: "no backup set",
+			ttl:  time.Hour * 24,
+			want: false,
+		},
+		{
+			name:       "backup set more recent than half the TTL",
+			ttl:        time.Hour * 24,
+			backupSets: []v1beta1.RestorableBackupSet{newBackupSet("hub-1", time.Hour*6)},
+			want:       true,
+		},
+		{
+			name:       "backup set older than half the TTL",
+			ttl:        time.Hour * 24,
+			backupSets: []v1beta1.RestorableBackupSet{newBackupSet("hub-1", time.Hour*13)},
+			want:       false,
+		},
+		{
+			name: "recent backup set created by another hub",
+			ttl:  time.Hour * 24,
+			backupSets: []v1beta1.RestorableBackupSet{
+				newBackupSet("hub-2", time.Hour),
+				newBackupSet("hub-1", time.Hour*13),
+			},
+			want: false,
+		},
+		{
+			name:       "velero default TTL",
+			backupSets: []v1beta1.RestorableBackupSet{newBackupSet("hub-1", time.Hour*24*10)},
+			want:       true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backupSchedule := initBackupSchedule("0 */6 * * *")
+			backupSchedule.Spec.VeleroTTL = metav1.Duration{Duration: tt.ttl}
+			backupSchedule.Status.RestorableBackups = tt.backupSets
+			if got := hasRecentBackupSet(backupSchedule, "hub-1", now); got != tt.want {
+				t.Errorf("hasRecentBackupSet() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_getNextScheduledBackupTime(t *testing.T) {
+
+	cronSchedule, err := cron.ParseStandard("0 */2 * * *")
+	if err != nil {
+		t.Fatalf("unable to parse the cron expression: %v", err)
+	}
+	newSchedule := func(scheduleType ResourceType, lastBackup *time.Time) veleroapi.Schedule {
+		schedule := veleroapi.Schedule{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      veleroScheduleNames[scheduleType],
+				Namespace: "velero-ns",
+			},
+		}
+		if lastBackup != nil {
+			schedule.Status.LastBackup = &metav1.Time{Time: *lastBackup}
+		}
+		return schedule
+	}
+	lastBackup := time.Date(2022, 4, 20, 12, 0, 5, 0, time.UTC)
+	laterBackup := time.Date(2022, 4, 20, 14, 0, 3, 0, time.UTC)
+	validationBackup := time.Date(2022, 4, 20, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		schedules []veleroapi.Schedule
+		want      time.Time
+	}{
+		{
+			name: "next cron time after the last backup",
+			schedules: []veleroapi.Schedule{
+				newSchedule(Resources, &lastBackup),
+				newSchedule(Credentials, &laterBackup),
+			},
+			want: time.Date(2022, 4, 20, 14, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "validation schedule ignored",
+			schedules: []veleroapi.Schedule{
+				newSchedule(Resources, &lastBackup),
+				newSchedule(ValidationSchedule, &validationBackup),
+			},
+			want: time.Date(2022, 4, 20, 14, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "schedule without a last backup",
+			schedules: []veleroapi.Schedule{
+				newSchedule(Resources, &lastBackup),
+				newSchedule(Credentials, nil),
+			},
+			want: time.Time{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := getNextScheduledBackupTime(tt.schedules, cronSchedule); !got.Equal(tt.want) {
+				t.Errorf("getNextScheduledBackupTime() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_isResourceMatched(t *testing.T) {
+
+	resource := metav1.APIResource{Name: "clusterdeployments", Kind: "ClusterDeployment"}
+	tests := []struct {
+		name  string
+		names []string
+		want  bool
+	}{
+		{name: "kind and group", names: []string{"clusterdeployment.hive.openshift.io"}, want: true},
+		{name: "resource and group", names: []string{"clusterdeployments.hive.openshift.io"}, want: true},
+		{name: "kind from any group", names: []string{"ClusterDeployment"}, want: true},
+		{name: "kind from another group", names: []string{"clusterdeployment.other.io"}},
+		{name: "other kinds", names: []string{"secret", "configmap"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isResourceMatched(tt.names, "hive.openshift.io", resource); got != tt.want {
+				t.Errorf("isResourceMatched() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_getResourceRulesConflicts(t *testing.T) {
+	tests := []struct {
+		name             string
+		included         []string
+		excluded         []string
+		wantConflicts    []string
+		wantIncluded     []string
+		wantErr          bool
+		wantConditionMsg string
+	}{
+		{
+			name:          "no conflicts",
+			included:      []string{"placement.cluster.open-cluster-management.io"},
+			excluded:      []string{"secret", "placementrule.apps.open-cluster-management.io"},
+			wantConflicts: []string{},
+			wantIncluded:  []string{"placement.cluster.open-cluster-management.io"},
+		},
+		{
+			name:          "all resources included",
+			included:      []string{"*"},
+			excluded:      []string{"secret"},
+			wantConflicts: []string{},
+			wantIncluded:  []string{"*"},
+		},
+		{
+			name: "same kind and group",
+			included: []string{
+				"placement.cluster.open-cluster-management.io",
+				"ClusterDeployment.hive.openshift.io",
+			},
+			excluded:      []string{"clusterdeployment.hive.openshift.io"},
+			wantConflicts: []string{"ClusterDeployment.hive.openshift.io"},
+			wantIncluded:  []string{"placement.cluster.open-cluster-management.io"},
+			wantConditionMsg: "Resources both included and excluded are not backed up: " +
+				"acm-resources-schedule [ClusterDeployment.hive.openshift.io]",
+		},
+		{
+			name:          "excluded kind with no group",
+			included:      []string{"configmap", "channel.apps.open-cluster-management.io"},
+			excluded:      []string{"channel"},
+			wantConflicts: []string{"channel.apps.open-cluster-management.io"},
+			wantIncluded:  []string{"configmap"},
+			wantConditionMsg: "Resources both included and excluded are not backed up: " +
+				"acm-resources-schedule [channel.apps.open-cluster-management.io]",
+		},
+		{
+			name:          "included kind with no group",
+			included:      []string{"channel"},
+			excluded:      []string{"channel.apps.open-cluster-management.io"},
+			wantConflicts: []string{"channel"},
+			// the backup would include all resources with no included resources
+			wantIncluded: []string{"channel"},
+			wantErr:      true,
+			wantConditionMsg: "Resources both included and excluded are not backed up: " +
+				"acm-resources-schedule [channel]",
+		},
+		{
+			name:          "same kind, different groups",
+			included:      []string{"channel.apps.open-cluster-management.io"},
+			excluded:      []string{"channel.messaging.knative.dev"},
+			wantConflicts: []string{},
+			wantIncluded:  []string{"channel.apps.open-cluster-management.io"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			veleroBackupTemplate := &veleroapi.BackupSpec{
+				IncludedResources: tt.included,
+				ExcludedResources: tt.excluded,
+			}
+			conflicts := getResourceRulesConflicts(veleroBackupTemplate)
+			if !reflect.DeepEqual(conflicts, tt.wantConflicts) {
+				t.Errorf("getResourceRulesConflicts() = %v, want %v", conflicts, tt.wantConflicts)
+			}
+
+			err := resolveResourceRulesConflicts(veleroBackupTemplate, conflicts)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("resolveResourceRulesConflicts() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(veleroBackupTemplate.IncludedResources, tt.wantIncluded) {
+				t.Errorf("resolveResourceRulesConflicts() included = %v, want %v",
+					veleroBackupTemplate.IncludedResources, tt.wantIncluded)
+			}
+
+			scheduleConflicts := []string{}
+			if len(conflicts) > 0 {
+				scheduleConflicts = append(scheduleConflicts,
+					"acm-resources-schedule ["+strings.Join(conflicts, ", ")+"]")
+			}
+			backupSchedule := initBackupSchedule("0 6 * * *")
+			setResourceRulesConflictCondition(backupSchedule, scheduleConflicts)
+			condition := meta.FindStatusCondition(backupSchedule.Status.Conditions,
+				v1beta1.BackupScheduleResourceRulesConflict)
+			if condition == nil {
+				t.Fatalf("setResourceRulesConflictCondition() condition not set")
+			}
+			wantStatus := metav1.ConditionFalse
+			if len(conflicts) > 0 {
+				wantStatus = metav1.ConditionTrue
+			}
+			if condition.Status != wantStatus || condition.Message != tt.wantConditionMsg {
+				t.Errorf("setResourceRulesConflictCondition() = %v %v, want %v %v",
+					condition.Status, condition.Message, wantStatus, tt.wantConditionMsg)
+			}
+		})
+	}
+}
+
+func Test_canonicalizeExcludedResources(t *testing.T) {
+
+	groupVersions := []groupVersionResources{
+		{
+			group: metav1.APIGroup{Name: ""},
+			resourceList: &metav1.APIResourceList{
+				GroupVersion: "v1",
+				APIResources: []metav1.APIResource{
+					{Name: "secrets", Kind: "Secret", Namespaced: true},
+					{Name: "events", Kind: "Event", Namespaced: true},
+					{Name: "pods", Kind: "Pod", Namespaced: true},
+					{Name: "pods/log", Kind: "Pod", Namespaced: true},
+				},
+			},
+		},
+		{
+			group: metav1.APIGroup{Name: "apps"},
+			resourceList: &metav1.APIResourceList{
+				GroupVersion: "apps/v1",
+				APIResources: []metav1.APIResource{
+					{Name: "deployments", Kind: "Deployment", Namespaced: true},
+				},
+			},
+		},
+		{
+			group: metav1.APIGroup{Name: "events.k8s.io"},
+			resourceList: &metav1.APIResourceList{
+				GroupVersion: "events.k8s.io/v1",
+				APIResources: []metav1.APIResource{
+					{Name: "events", Kind: "Event", Namespaced: true},
+				},
+			},
+		},
+		{
+			group: metav1.APIGroup{Name: "apps.open-cluster-management.io"},
+			resourceList: &metav1.APIResourceList{
+				GroupVersion: "apps.open-cluster-management.io/v1",
+				APIResources: []metav1.APIResource{
+					{Name: "channels", Kind: "Channel", Namespaced: true},
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		name              string
+		groupVersions     []groupVersionResources
+		excludedResources []string
+		want              []string
+	}{
+		{
+			name:              "no excluded resources",
+			groupVersions:     groupVersions,
+			excludedResources: nil,
+			want:              []string{},
+		},
+		{
+			name:          "overlapping kind, resource and kind.group entries",
+			groupVersions: groupVersions,
+			excludedResources: []string{
+				"deployments",
+				"deployment.apps",
+				"Deployment.apps",
+				"deployments.apps",
+				"channel",
+				"Channel.apps.open-cluster-management.io",
+			},
+			want: []string{
+				"deployment.apps",
+				"channel.apps.open-cluster-management.io",
+			},
+		},
+		{
+			name:          "core and multi group kinds are kept with no group",
+			groupVersions: groupVersions,
+			excludedResources: []string{
+				"secret",
+				"secrets",
+				"Pod",
+				"event",
+				"event.events.k8s.io",
+				"events.events.k8s.io",
+			},
+			want: []string{
+				"secret",
+				"pod",
+				"event",
+				"event.events.k8s.io",
+			},
+		},
+		{
+			name:          "unknown resources are kept as is",
+			groupVersions: groupVersions,
+			excludedResources: []string{
+				"ManagedClusterView",
+				"managedclusterview",
+				"foo.bar.io",
+			},
+			want: []string{
+				"ManagedClusterView",
+				"foo.bar.io",
+			},
+		},
+		{
+			name:          "no server resources",
+			groupVersions: nil,
+			excludedResources: []string{
+				"deployment.apps",
+				"Deployment.apps",
+				"secret",
+			},
+			want: []string{
+				"deployment.apps",
+				"secret",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := canonicalizeExcludedResources(tt.groupVersions,
+				tt.excludedResources); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("canonicalizeExcludedResources() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_getNamespaceContentsNamespaces(t *testing.T) {
+
+	newNamespace := func(name string, labels map[string]interface{}) *unstructured.Unstructured {
+		metadata := map[string]interface{}{
+			"name": name,
+		}
+		if labels != nil {
+			metadata["labels"] = labels
+		}
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "Namespace",
+			"metadata":   metadata,
+		}}
+	}
+
+	dyn := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			{Version: "v1", Resource: "namespaces"}: "NamespaceList",
+		},
+		newNamespace("ns3", map[string]interface{}{backupCredsClusterLabel: "generic"}),
+		newNamespace("ns1", map[string]interface{}{backupCredsClusterLabel: "generic"}),
+		newNamespace("ns2", nil),
+		newNamespace("excluded", map[string]interface{}{backupCredsClusterLabel: "generic"}),
+	)
+
+	tests := []struct {
+		name string
+		mode v1beta1.NamespaceBackupMode
+		want []string
+	}{
+		{
+			name: "default mode, no namespace contents backed up",
+			mode: "",
+			want: nil,
+		},
+		{
+			name: "NamespaceOnly mode, no namespace contents backed up",
+			mode: v1beta1.NamespaceBackupModeNamespaceOnly,
+			want: nil,
+		},
+		{
+			name: "NamespaceContents mode, labeled namespaces not excluded, sorted",
+			mode: v1beta1.NamespaceBackupModeNamespaceContents,
+			want: []string{"ns1", "ns3"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backupSchedule := initBackupSchedule("0 */6 * * *")
+			backupSchedule.Spec.NamespaceBackupMode = tt.mode
+			backupSchedule.Spec.ExcludedNamespaces = []string{"excluded"}
+
+			got, err := getNamespaceContentsNamespaces(context.Background(), backupSchedule, dyn)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getNamespaceContentsNamespaces() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_getNamespaceContentsSchedules(t *testing.T) {
+
+	backupSchedule := initBackupSchedule("0 */6 * * *")
+	backupSchedule.Spec.VeleroTTL = metav1.Duration{Duration: time.Hour * 72}
+	backupSchedule.Spec.VeleroScheduleOverrides = map[string]string{
+		string(Resources): "0 */2 * * *",
+	}
+
+	schedules := getNamespaceContentsSchedules(backupSchedule, "cluster-id",
+		[]string{"ns1", "ns2"}, []storageLocationRef{{Name: "secondary"}})
+
+	if len(schedules) != 2 {
+		t.Fatalf("getNamespaceContentsSchedules() returned %d schedules, want 2", len(schedules))
+	}
+	wantNames := []string{namespaceContentsScheduleName, namespaceContentsScheduleName + "-secondary"}
+	wantLocations := []string{"", "secondary"}
+	for i, veleroSchedule := range schedules {
+		if veleroSchedule.Name != wantNames[i] {
+			t.Errorf("schedule name = %v, want %v", veleroSchedule.Name, wantNames[i])
+		}
+		if veleroSchedule.Spec.Template.StorageLocation != wantLocations[i] {
+			t.Errorf("schedule %s storage location = %v, want %v", veleroSchedule.Name,
+				veleroSchedule.Spec.Template.StorageLocation, wantLocations[i])
+		}
+		if got := veleroSchedule.GetLabels()[BackupScheduleTypeLabel]; got != string(NamespaceContents) {
+			t.Errorf("schedule %s type = %v, want %v", veleroSchedule.Name, got, NamespaceContents)
+		}
+		if !reflect.DeepEqual(veleroSchedule.Spec.Template.IncludedNamespaces, []string{"ns1", "ns2"}) {
+			t.Errorf("schedule %s included namespaces = %v, want [ns1 ns2]", veleroSchedule.Name,
+				veleroSchedule.Spec.Template.IncludedNamespaces)
+		}
+		if !findValue(veleroSchedule.Spec.Template.ExcludedResources, "events") {
+			t.Errorf("schedule %s doesn't exclude the events", veleroSchedule.Name)
+		}
+		if veleroSchedule.Spec.Template.IncludeClusterResources == nil ||
+			*veleroSchedule.Spec.Template.IncludeClusterResources {
+			t.Errorf("schedule %s includes the cluster resources", veleroSchedule.Name)
+		}
+		// same cron schedule as the resources backup, so the backups are restored together
+		if want := getBackupTypeCronSchedule(backupSchedule, Resources,
+			"cluster-id"); veleroSchedule.Spec.Schedule != want {
+			t.Errorf("schedule %s cron = %v, want %v", veleroSchedule.Name,
+				veleroSchedule.Spec.Schedule, want)
+		}
+		if veleroSchedule.Spec.Template.TTL != backupSchedule.Spec.VeleroTTL {
+			t.Errorf("schedule %s TTL = %v, want %v", veleroSchedule.Name,
+				veleroSchedule.Spec.Template.TTL, backupSchedule.Spec.VeleroTTL)
+		}
+	}
+
+	namespaceContents, others := splitNamespaceContentsSchedules([]veleroapi.Schedule{
+		*schedules[0],
+		{ObjectMeta: metav1.ObjectMeta{Name: veleroScheduleNames[Resources],
+			Labels: map[string]string{BackupScheduleTypeLabel: string(Resources)}}},
+		*schedules[1],
+	})
+	if len(namespaceContents) != 2 || len(others) != 1 ||
+		others[0].Name != veleroScheduleNames[Resources] {
+		t.Errorf("splitNamespaceContentsSchedules() = %d, %d schedules, want 2, 1",
+			len(namespaceContents), len(others))
+	}
+}
+
+func Test_getEmptyBackupMessage(t *testing.T) {
+
+	type args struct {
+		backup            *veleroapi.Backup
+		matchingResources int
+		allowEmptyBackups bool
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{
+			name: "no progress reported",
+			args: args{
+				backup: newTestBackup(
+					"acm-credentials-schedule-20220101010101",
+					withPhase(veleroapi.BackupPhaseCompleted),
+					withProgress(nil),
+				),
+				matchingResources: 2,
+			},
+			want: "",
+		},
+		{
+			name: "backup with resources",
+			args: args{
+				backup: newTestBackup(
+					"acm-credentials-schedule-20220101010101",
+					withPhase(veleroapi.BackupPhaseCompleted),
+					withProgress(&veleroapi.BackupProgress{TotalItems: 3, ItemsBackedUp: 3}),
+				),
+				matchingResources: 3,
+			},
+			want: "",
+		},
+		{
+			name: "empty backup but hub resources match, even if empty backups are allowed",
+			args: args{
+				backup: newTestBackup(
+					"acm-credentials-schedule-20220101010101",
+					withPhase(veleroapi.BackupPhaseCompleted),
+					withProgress(&veleroapi.BackupProgress{}),
+				),
+				matchingResources: 2,
+				allowEmptyBackups: true,
+			},
+			want: "Backup acm-credentials-schedule-20220101010101 has no resources " +
+				"although 2 resources on the hub match the backup label selector.",
+		},
+		{
+			name: "empty backup, no hub resources match, empty backups allowed",
+			args: args{
+				backup: newTestBackup(
+					"acm-credentials-schedule-20220101010101",
+					withPhase(veleroapi.BackupPhaseCompleted),
+					withProgress(&veleroapi.BackupProgress{}),
+				),
+				matchingResources: 0,
+				allowEmptyBackups: true,
+			},
+			want: "",
+		},
+		{
+			name: "empty backup, no hub resources match, empty backups not allowed",
+			args: args{
+				backup: newTestBackup(
+					"acm-credentials-schedule-20220101010101",
+					withPhase(veleroapi.BackupPhaseCompleted),
+					withProgress(&veleroapi.BackupProgress{}),
+				),
+				matchingResources: 0,
+			},
+			want: "Backup acm-credentials-schedule-20220101010101 has no resources, " +
+				"no resources on the hub match the backup label selector. " +
+				"Set allowEmptyBackups to true if this is expected.",
+		},
+		{
+			name: "empty backup, unknown hub resources, empty backups not allowed",
+			args: args{
+				backup: newTestBackup(
+					"acm-credentials-schedule-20220101010101",
+					withPhase(veleroapi.BackupPhaseCompleted),
+					withProgress(&veleroapi.BackupProgress{}),
+				),
+				matchingResources: -1,
+			},
+			want: "Backup acm-credentials-schedule-20220101010101 has no resources. " +
+				"Set allowEmptyBackups to true if this is expected.",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := getEmptyBackupMessage(tt.args.backup, tt.args.matchingResources,
+				tt.args.allowEmptyBackups); got != tt.want {
+				t.Errorf("getEmptyBackupMessage() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_getAdoptableVeleroSchedules(t *testing.T) {
+
+	newSchedule := func(name string, cron string, ttl time.Duration, owned bool) veleroapi.Schedule {
+		schedule := veleroapi.Schedule{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "velero-ns",
+			},
+			Spec: veleroapi.ScheduleSpec{
+				Schedule: cron,
+				Template: veleroapi.BackupSpec{
+					TTL: metav1.Duration{Duration: ttl},
+				},
+			},
+		}
+		if owned {
+			isController := true
+			schedule.SetOwnerReferences([]metav1.OwnerReference{
+				{
+					APIVersion: apiGVString,
+					Kind:       "BackupSchedule",
+					Name:       "schedule-acm",
+					UID:        "123",
+					Controller: &isController,
+				},
+			})
+		}
+		return schedule
+	}
+
+	tests := []struct {
+		name      string
+		schedules []veleroapi.Schedule
+		wantNames []string
+		wantSpec  v1beta1.BackupScheduleSpec
+	}{
+		{
+			name:      "no schedules",
+			schedules: []veleroapi.Schedule{},
+			wantNames: []string{},
+			wantSpec:  v1beta1.BackupScheduleSpec{},
+		},
+		{
+			name: "schedules owned by a backup schedule or not using the operator names",
+			schedules: []veleroapi.Schedule{
+				newSchedule(veleroScheduleNames[Resources], "0 */6 * * *", time.Hour, true),
+				newSchedule("user-schedule", "0 */6 * * *", time.Hour, false),
+			},
+			wantNames: []string{},
+			wantSpec:  v1beta1.BackupScheduleSpec{},
+		},
+		{
+			name: "unowned schedules using the operator names, spec from resources schedule",
+			schedules: []veleroapi.Schedule{
+				newSchedule(veleroScheduleNames[Credentials], "0 */2 * * *", 2*time.Hour, false),
+				newSchedule(veleroScheduleNames[Resources], "0 */6 * * *", 6*time.Hour, false),
+				newSchedule("user-schedule", "0 */6 * * *", time.Hour, false),
+			},
+			wantNames: []string{
+				veleroScheduleNames[Credentials],
+				veleroScheduleNames[Resources],
+			},
+			wantSpec: v1beta1.BackupScheduleSpec{
+				VeleroSchedule: "0 */6 * * *",
+				VeleroTTL:      metav1.Duration{Duration: 6 * time.Hour},
+			},
+		},
+		{
+			name: "unowned schedules with no resources schedule",
+			schedules: []veleroapi.Schedule{
+				newSchedule(veleroScheduleNames[ManagedClusters], "0 */2 * * *", 2*time.Hour, false),
+			},
+			wantNames: []string{veleroScheduleNames[ManagedClusters]},
+			wantSpec: v1beta1.BackupScheduleSpec{
+				VeleroSchedule: "0 */2 * * *",
+				VeleroTTL:      metav1.Duration{Duration: 2 * time.Hour},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			adoptable := getAdoptableVeleroSchedules(tt.schedules)
+			gotNames := []string{}
+			for i := range adoptable {
+				gotNames = append(gotNames, adoptable[i].Name)
+			}
+			if !reflect.DeepEqual(gotNames, tt.wantNames) {
+				t.Errorf("getAdoptableVeleroSchedules() = %v, want %v", gotNames, tt.wantNames)
+			}
+			if got := getBackupScheduleSpecFromVeleroSchedules(adoptable); !reflect.DeepEqual(got, tt.wantSpec) {
+				t.Errorf("getBackupScheduleSpecFromVeleroSchedules() = %v, want %v", got, tt.wantSpec)
+			}
+		})
+	}
+}
+
+func Test_getReconcileInterval(t *testing.T) {
+
+	tests := []struct {
+		name              string
+		reconcileInterval time.Duration
+		want              time.Duration
+	}{
+		{
+			name:              "interval not set",
+			reconcileInterval: 0,
+			want:              collisionControlInterval,
+		},
+		{
+			name:              "interval set",
+			reconcileInterval: time.Minute * 5,
+			want:              time.Minute * 5,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &BackupScheduleReconciler{ReconcileInterval: tt.reconcileInterval}
+			if got := r.getReconcileInterval(); got != tt.want {
+				t.Errorf("getReconcileInterval() = %v, want %v", got, tt.want)
+			}
+			// the enabled backup schedules are reconciled again after this interval
+			backupSchedule := initBackupSchedule("0 6 * * *")
+			backupSchedule.Status.Phase = v1beta1.SchedulePhaseEnabled
+			if got := r.skipUnchangedBackups(context.Background(), backupSchedule, nil); got != tt.want {
+				t.Errorf("skipUnchangedBackups() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_getShortTTLMessage(t *testing.T) {
+
+	newSchedule := func(cronJob string, ttl time.Duration) *v1beta1.BackupSchedule {
+		return &v1beta1.BackupSchedule{
+			Spec: v1beta1.BackupScheduleSpec{
+				VeleroSchedule: cronJob,
+				VeleroTTL:      metav1.Duration{Duration: ttl},
+			},
+		}
+	}
+
+	tests := []struct {
+		name           string
+		backupSchedule *v1beta1.BackupSchedule
+		checkInterval  time.Duration
+		want           string
+	}{
+		{
+			name:           "velero default TTL",
+			backupSchedule: newSchedule("0 */6 * * *", 0),
+			want:           "",
+		},
+		{
+			name:           "invalid cron job",
+			backupSchedule: newSchedule("invalid", time.Hour),
+			want:           "",
+		},
+		{
+			name:           "TTL longer than the schedule interval",
+			backupSchedule: newSchedule("0 */6 * * *", time.Hour*72),
+			want:           "",
+		},
+		{
+			name:           "TTL shorter than the schedule interval",
+			backupSchedule: newSchedule("0 */6 * * *", time.Hour*2),
+			want: "VeleroTTL 2h0m0s is shorter than the veleroSchedule interval 6h0m0s, " +
+				"backups could expire before they are validated",
+		},
+		{
+			name:           "TTL shorter than the longest schedule interval",
+			backupSchedule: newSchedule("0 1,2 * * *", time.Hour*2),
+			want: "VeleroTTL 2h0m0s is shorter than the veleroSchedule interval 23h0m0s, " +
+				"backups could expire before they are validated",
+		},
+		{
+			name: "TTL shorter than a veleroScheduleOverrides interval",
+			backupSchedule: func() *v1beta1.BackupSchedule {
+				backupSchedule := newSchedule("0 * * * *", time.Hour*2)
+				backupSchedule.Spec.VeleroScheduleOverrides = map[string]string{
+					string(ManagedClusters): "0 2 * * *",
+				}
+				return backupSchedule
+			}(),
+			want: "VeleroTTL 2h0m0s is shorter than the veleroScheduleOverrides managedClusters interval " +
+				"24h0m0s, backups could expire before they are validated",
+		},
+		{
+			name:           "TTL shorter than the operator check interval",
+			backupSchedule: newSchedule("*/5 * * * *", time.Minute*10),
+			checkInterval:  collisionControlInterval,
+			want: "VeleroTTL 10m0s is shorter than the operator check interval 30m0s, " +
+				"backups could expire before they are validated",
+		},
+		{
+			name:           "TTL longer than a configured operator check interval",
+			backupSchedule: newSchedule("*/5 * * * *", time.Minute*10),
+			checkInterval:  time.Minute * 5,
+			want:           "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := getShortTTLMessage(tt.backupSchedule, tt.checkInterval); got != tt.want {
+				t.Errorf("getShortTTLMessage() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_ValidateBackupSchedule(t *testing.T) {
+
+	newSchedule := func(
+		kind string,
+		name string,
+		cronJob string,
+		ttl time.Duration,
+		mode v1beta1.NamespaceBackupMode,
+	) *v1beta1.BackupSchedule {
+		return &v1beta1.BackupSchedule{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: "cluster.open-cluster-management.io/v1beta1",
+				Kind:       kind,
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "open-cluster-management-backup",
+			},
+			Spec: v1beta1.BackupScheduleSpec{
+				VeleroSchedule:      cronJob,
+				VeleroTTL:           metav1.Duration{Duration: ttl},
+				NamespaceBackupMode: mode,
+			},
+		}
+	}
+
+	tests := []struct {
+		name           string
+		backupSchedule *v1beta1.BackupSchedule
+		wantErrors     []string
+		wantWarnings   []string
+	}{
+		{
+			name:           "valid backup schedule",
+			backupSchedule: newSchedule("BackupSchedule", "schedule-acm", "0 */6 * * *", time.Hour*72, ""),
+			wantErrors:     []string{},
+			wantWarnings:   []string{},
+		},
+		{
+			name: "valid backup schedule with short TTL",
+			backupSchedule: newSchedule("BackupSchedule", "schedule-acm", "0 */6 * * *", time.Hour*2,
+				v1beta1.NamespaceBackupModeNamespaceContents),
+			wantErrors: []string{},
+			wantWarnings: []string{
+				"VeleroTTL 2h0m0s is shorter than the veleroSchedule interval 6h0m0s, " +
+					"backups could expire before they are validated",
+			},
+		},
+		{
+			name:           "not a backup schedule",
+			backupSchedule: newSchedule("Restore", "schedule-acm", "0 */6 * * *", 0, ""),
+			wantErrors: []string{
+				"resource must be a cluster.open-cluster-management.io/v1beta1, Kind=BackupSchedule, " +
+					"found apiVersion \"cluster.open-cluster-management.io/v1beta1\" and kind \"Restore\"",
+			},
+			wantWarnings: []string{},
+		},
+		{
+			name:           "invalid name",
+			backupSchedule: newSchedule("BackupSchedule", "Schedule_ACM", "0 */6 * * *", 0, ""),
+			wantErrors: []string{
+				"invalid metadata.name: " + validation.IsDNS1123Subdomain("Schedule_ACM")[0],
+			},
+			wantWarnings: []string{},
+		},
+		{
+			name:           "missing cron job and name",
+			backupSchedule: newSchedule("BackupSchedule", "", "", 0, ""),
+			wantErrors: []string{
+				"metadata.name must be set",
+				"spec.veleroSchedule: Invalid value: \"\": Schedule must be a non-empty valid Cron expression",
+			},
+			wantWarnings: []string{},
+		},
+		{
+			name: "negative TTL and invalid namespace backup mode",
+			backupSchedule: newSchedule("BackupSchedule", "schedule-acm", "0 */6 * * *", -time.Hour,
+				"AllContents"),
+			wantErrors: []string{
+				"spec.veleroTtl: Invalid value: \"-1h0m0s\": veleroTtl -1h0m0s must not be negative",
+				"spec.namespaceBackupMode: Unsupported value: \"AllContents\": " +
+					"supported values: \"NamespaceOnly\", \"NamespaceContents\"",
+			},
+			wantWarnings: []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotErrors, gotWarnings := ValidateBackupSchedule(context.Background(), tt.backupSchedule)
+			if !reflect.DeepEqual(gotErrors, tt.wantErrors) {
+				t.Errorf("ValidateBackupSchedule() errors = %v, want %v", gotErrors, tt.wantErrors)
+			}
+			if !reflect.DeepEqual(gotWarnings, tt.wantWarnings) {
+				t.Errorf("ValidateBackupSchedule() warnings = %v, want %v", gotWarnings, tt.wantWarnings)
+			}
+		})
+	}
+}
+
+func Test_getBackupsWithUnknownHubID(t *testing.T) {
+
+	veleroSchedules := []string{"acm-resources-schedule", "acm-credentials-schedule"}
+
+	tests := []struct {
+		name      string
+		backups   []veleroapi.Backup
+		wantNames []string
+		wantErr   bool
+	}{
+		{
+			name: "no backups with unknown hub id",
+			backups: []veleroapi.Backup{
+				*newTestBackup(
+					"acm-resources-schedule-20220101010101",
+					withScheduleName("schedule-acm"),
+					withHubID("hub-1"),
+					withBackupLabel("velero.io/schedule-name", "acm-resources-schedule"),
+				),
+			},
+			wantNames: []string{},
+		},
+		{
+			name: "backups with unknown hub id",
+			backups: []veleroapi.Backup{
+				*newTestBackup(
+					"acm-resources-schedule-20220101010101",
+					withScheduleName("schedule-acm"),
+					withHubID(unknownHubID),
+					withBackupLabel("velero.io/schedule-name", "acm-resources-schedule"),
+				),
+				*newTestBackup(
+					"acm-credentials-schedule-20220101010101",
+					withScheduleName("schedule-acm"),
+					withHubID(""),
+					withBackupLabel("velero.io/schedule-name", "acm-credentials-schedule"),
+				),
+				*newTestBackup(
+					"acm-resources-schedule-20220101020202",
+					withScheduleName("schedule-acm"),
+					withHubID("hub-1"),
+					withBackupLabel("velero.io/schedule-name", "acm-resources-schedule"),
+				),
+			},
+			wantNames: []string{
+				"acm-resources-schedule-20220101010101",
+				"acm-credentials-schedule-20220101010101",
+			},
+		},
+		{
+			name: "ignore backups from other schedules",
+			backups: []veleroapi.Backup{
+				*newTestBackup(
+					"acm-resources-schedule-20220101010101",
+					withScheduleName("other-schedule"),
+					withHubID(unknownHubID),
+					withBackupLabel("velero.io/schedule-name", "acm-resources-schedule"),
+				),
+				*newTestBackup(
+					"acm-managed-clusters-schedule-20220101010101",
+					withScheduleName("schedule-acm"),
+					withHubID(unknownHubID),
+					withBackupLabel("velero.io/schedule-name", "acm-managed-clusters-schedule"),
+				),
+			},
+			wantNames: []string{},
+		},
+		{
+			name: "backups from another hub for this schedule",
+			backups: []veleroapi.Backup{
+				*newTestBackup(
+					"acm-resources-schedule-20220101010101",
+					withScheduleName("schedule-acm"),
+					withHubID(unknownHubID),
+					withBackupLabel("velero.io/schedule-name", "acm-resources-schedule"),
+				),
+				*newTestBackup(
+					"acm-resources-schedule-20220101020202",
+					withScheduleName("schedule-acm"),
+					withHubID("hub-2"),
+					withBackupLabel("velero.io/schedule-name", "acm-resources-schedule"),
+				),
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := getBackupsWithUnknownHubID(tt.backups, "schedule-acm",
+				veleroSchedules, "hub-1")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("getBackupsWithUnknownHubID() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			gotNames := []string{}
+			for _, backup := range got {
+				gotNames = append(gotNames, backup.Name)
+			}
+			if !reflect.DeepEqual(gotNames, tt.wantNames) {
+				t.Errorf("getBackupsWithUnknownHubID() = %v, want %v", gotNames, tt.wantNames)
+			}
+		})
+	}
+}
+
+func Test_setBackupScheduleConditions(t *testing.T) {
+
+	backupSchedule := initBackupSchedule("0 */6 * * *")
+	backupSchedule.Generation = 2
+
+	type wantCondition struct {
+		status metav1.ConditionStatus
+		reason string
+	}
+	tests := []struct {
+		name          string
+		phase         v1beta1.SchedulePhase
+		wantReady     wantCondition
+		wantCollision wantCondition
+		wantPaused    wantCondition
+	}{
+		{
+			name:  "new schedule",
+			phase: v1beta1.SchedulePhaseNew,
+			wantReady: wantCondition{
+				metav1.ConditionFalse, v1beta1.BackupScheduleReasonNew,
+			},
+			wantCollision: wantCondition{
+				metav1.ConditionFalse, v1beta1.BackupScheduleReasonNoCollision,
+			},
+			wantPaused: wantCondition{
+				metav1.ConditionFalse, v1beta1.BackupScheduleReasonNotPaused,
+			},
+		},
+		{
+			name:  "enabled schedule",
+			phase: v1beta1.SchedulePhaseEnabled,
+			wantReady: wantCondition{
+				metav1.ConditionTrue, v1beta1.BackupScheduleReasonEnabled,
+			},
+			wantCollision: wantCondition{
+				metav1.ConditionFalse, v1beta1.BackupScheduleReasonNoCollision,
+			},
+			wantPaused: wantCondition{
+				metav1.ConditionFalse, v1beta1.BackupScheduleReasonNotPaused,
+			},
+		},
+		{
+			name:  "backup collision",
+			phase: v1beta1.SchedulePhaseBackupCollision,
+			wantReady: wantCondition{
+				metav1.ConditionFalse, v1beta1.BackupScheduleReasonBackupCollision,
+			},
+			wantCollision: wantCondition{
+				metav1.ConditionTrue, v1beta1.BackupScheduleReasonBackupCollision,
+			},
+			wantPaused: wantCondition{
+				metav1.ConditionFalse, v1beta1.BackupScheduleReasonNotPaused,
+			},
+		},
+		{
+			name:  "paused schedule",
+			phase: v1beta1.SchedulePhasePaused,
+			wantReady: wantCondition{
+				metav1.ConditionFalse, v1beta1.BackupScheduleReasonPaused,
+			},
+			wantCollision: wantCondition{
+				metav1.ConditionFalse, v1beta1.BackupScheduleReasonNoCollision,
+			},
+			wantPaused: wantCondition{
+				metav1.ConditionTrue, v1beta1.BackupScheduleReasonPaused,
+			},
+		},
+		{
+			name:  "unknown phase",
+			phase: v1beta1.SchedulePhaseUnknown,
+			wantReady: wantCondition{
+				metav1.ConditionUnknown, v1beta1.BackupScheduleReasonUnknown,
+			},
+			wantCollision: wantCondition{
+				metav1.ConditionFalse, v1beta1.BackupScheduleReasonNoCollision,
+			},
+			wantPaused: wantCondition{
+				metav1.ConditionFalse, v1beta1.BackupScheduleReasonNotPaused,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backupSchedule.Status.Phase = tt.phase
+			backupSchedule.Status.LastMessage = string(tt.phase)
+			setBackupScheduleConditions(backupSchedule)
+
+			if len(backupSchedule.Status.Conditions) != 3 {
+				t.Errorf("setBackupScheduleConditions() got %v conditions, want 3",
+					len(backupSchedule.Status.Conditions))
+			}
+			ready := meta.FindStatusCondition(backupSchedule.Status.Conditions,
+				v1beta1.BackupScheduleReady)
+			if ready == nil || ready.Status != tt.wantReady.status ||
+				ready.Reason != tt.wantReady.reason ||
+				ready.Message != string(tt.phase) || ready.ObservedGeneration != 2 {
+				t.Errorf("setBackupScheduleConditions() Ready = %v, want %v", ready, tt.wantReady)
+			}
+			collision := meta.FindStatusCondition(backupSchedule.Status.Conditions,
+				v1beta1.BackupScheduleCollision)
+			if collision == nil || collision.Status != tt.wantCollision.status ||
+				collision.Reason != tt.wantCollision.reason {
+				t.Errorf("setBackupScheduleConditions() BackupCollision = %v, want %v",
+					collision, tt.wantCollision)
+			}
+			paused := meta.FindStatusCondition(backupSchedule.Status.Conditions,
+				v1beta1.BackupSchedulePaused)
+			if paused == nil || paused.Status != tt.wantPaused.status ||
+				paused.Reason != tt.wantPaused.reason {
+				t.Errorf("setBackupScheduleConditions() Paused = %v, want %v",
+					paused, tt.wantPaused)
+			}
+		})
+	}
+}
+
+func Test_setBackupTypeConditions(t *testing.T) {
+
+	checkCondition := func(backupSchedule *v1beta1.BackupSchedule, conditionType string,
+		status metav1.ConditionStatus, reason string, message string) *metav1.Condition {
+		condition := meta.FindStatusCondition(backupSchedule.Status.Conditions, conditionType)
+		if condition == nil {
+			t.Fatalf("condition %s not set", conditionType)
+		}
+		if condition.Status != status || condition.Reason != reason || condition.Message != message {
+			t.Errorf("condition %s = %s/%s/%s, want %s/%s/%s", conditionType,
+				condition.Status, condition.Reason, condition.Message, status, reason, message)
+		}
+		return condition
+	}
+
+	backupSchedule := initBackupSchedule("0 */6 * * *")
+	backups := []veleroapi.Backup{
+		*newTestBackup(
+			"acm-credentials-schedule-20220420120000",
+			withBackupType(Credentials),
+			withPhase(veleroapi.BackupPhaseCompleted),
+		),
+		*newTestBackup(
+			"acm-resources-schedule-20220420100000",
+			withBackupType(Resources),
+			withPhase(veleroapi.BackupPhaseCompleted),
+		),
+		*newTestBackup(
+			"acm-resources-schedule-20220420120000",
+			withBackupType(Resources),
+			withPhase(veleroapi.BackupPhasePartiallyFailed),
+		),
+		*newTestBackup(
+			"acm-resources-schedule-20220420140000",
+			withBackupType(Resources),
+			withPhase(veleroapi.BackupPhaseInProgress),
+		),
+	}
+	setBackupTypeConditions(backupSchedule, backups)
+
+	if len(backupSchedule.Status.Conditions) != len(backupTypeConditions) {
+		t.Errorf("%d conditions set, want %d", len(backupSchedule.Status.Conditions),
+			len(backupTypeConditions))
+	}
+	checkCondition(backupSchedule, v1beta1.BackupScheduleCredentialsBackup,
+		metav1.ConditionTrue, v1beta1.BackupScheduleReasonBackupCompleted,
+		"Backup acm-credentials-schedule-20220420120000 is Completed")
+	checkCondition(backupSchedule, v1beta1.BackupScheduleResourcesBackup,
+		metav1.ConditionFalse, v1beta1.BackupScheduleReasonBackupFailed,
+		"Backup acm-resources-schedule-20220420120000 is PartiallyFailed")
+	checkCondition(backupSchedule, v1beta1.BackupScheduleManagedClustersBackup,
+		metav1.ConditionUnknown, v1beta1.BackupScheduleReasonNoFinishedBackup,
+		"No managedClusters backup is finished")
+
+	// set an old transition time to check which conditions transition
+	oldTime := metav1.NewTime(time.Now().Add(-time.Hour).Truncate(time.Second))
+	for i := range backupSchedule.Status.Conditions {
+		backupSchedule.Status.Conditions[i].LastTransitionTime = oldTime
+	}
+
+	backups[3].Status.Phase = veleroapi.BackupPhaseCompleted
+	backups = append(backups, *newTestBackup(
+		"acm-credentials-schedule-20220420140000",
+		withBackupType(Credentials),
+		withPhase(veleroapi.BackupPhaseCompleted),
+	))
+	setBackupTypeConditions(backupSchedule, backups)
+
+	// same status, the transition time is not updated
+	if condition := checkCondition(backupSchedule, v1beta1.BackupScheduleCredentialsBackup,
+		metav1.ConditionTrue, v1beta1.BackupScheduleReasonBackupCompleted,
+		"Backup acm-credentials-schedule-20220420140000 is Completed"); !condition.LastTransitionTime.Equal(&oldTime) {
+		t.Errorf("credentials condition transition time updated, the status didn't change")
+	}
+	if condition := checkCondition(backupSchedule, v1beta1.BackupScheduleManagedClustersBackup,
+		metav1.ConditionUnknown, v1beta1.BackupScheduleReasonNoFinishedBackup,
+		"No managedClusters backup is finished"); !condition.LastTransitionTime.Equal(&oldTime) {
+		t.Errorf("managed clusters condition transition time updated, the status didn't change")
+	}
+	// status changed, the transition time is updated
+	if condition := checkCondition(backupSchedule, v1beta1.BackupScheduleResourcesBackup,
+		metav1.ConditionTrue, v1beta1.BackupScheduleReasonBackupCompleted,
+		"Backup acm-resources-schedule-20220420140000 is Completed"); condition.LastTransitionTime.Equal(&oldTime) {
+		t.Errorf("resources condition transition time not updated, the status changed")
+	}
+}
+
+func Test_getTimedOutBackups(t *testing.T) {
+
+	now := time.Date(2022, 4, 20, 14, 0, 0, 0, time.UTC)
+	backups := []veleroapi.Backup{
+		// started 4 hours ago
+		*newTestBackup(
+			"acm-resources-schedule-20220420100000",
+			withCreationTime(now),
+			withPhase(veleroapi.BackupPhaseInProgress),
+		),
+		// started 30 minutes ago
+		*newTestBackup(
+			"acm-credentials-schedule-20220420133000",
+			withCreationTime(now),
+			withPhase(veleroapi.BackupPhaseInProgress),
+		),
+		// started 4 hours ago, completed
+		*newTestBackup(
+			"acm-managed-clusters-schedule-20220420100000",
+			withCreationTime(now),
+			withPhase(veleroapi.BackupPhaseCompleted),
+		),
+		// no timestamp in the name, created 2 hours ago
+		*newTestBackup(
+			"acm-validation-backup",
+			withCreationTime(now.Add(-2*time.Hour)),
+			withPhase(veleroapi.BackupPhaseInProgress),
+		),
+	}
+
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		want    []string
+	}{
+		{
+			name: "no timeout",
+			want: []string{},
+		},
+		{
+			name:    "backups started before the timeout",
+			timeout: time.Hour,
+			want: []string{
+				"acm-resources-schedule-20220420100000",
+				"acm-validation-backup",
+			},
+		},
+		{
+			name:    "backup started before a longer timeout",
+			timeout: 3 * time.Hour,
+			want:    []string{"acm-resources-schedule-20220420100000"},
+		},
+		{
+			name:    "no backup started before the timeout",
+			timeout: 5 * time.Hour,
+			want:    []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []string{}
+			for _, backup := range getTimedOutBackups(backups, tt.timeout, now) {
+				got = append(got, backup.Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getTimedOutBackups() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_checkBackupTimeout(t *testing.T) {
+
+	backupSchedule := initBackupSchedule("0 */6 * * *")
+	backupSchedule.Spec.BackupTimeout = metav1.Duration{Duration: time.Hour}
+	backups := []veleroapi.Backup{
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "acm-resources-schedule-" +
+					time.Now().UTC().Add(-2*time.Hour).Format(backupTimestampLayout),
+				UID: types.UID("timed-out-backup-uid"),
+			},
+			Status: veleroapi.BackupStatus{
+				Phase: veleroapi.BackupPhaseInProgress,
+			},
+		},
+	}
+	recorder := record.NewFakeRecorder(10)
+	r := &BackupScheduleReconciler{Recorder: recorder}
+
+	// the event is recorded once for the timed out backup
+	r.checkBackupTimeout(backupSchedule, backups)
+	r.checkBackupTimeout(backupSchedule, backups)
+	if len(recorder.Events) != 1 {
+		t.Errorf("%d events recorded, want 1", len(recorder.Events))
+	}
+	if event := <-recorder.Events; !strings.HasPrefix(event, "Warning Backup timed out:") ||
+		!strings.Contains(event, backups[0].Name) {
+		t.Errorf("event = %s, want a backup timed out warning for %s", event, backups[0].Name)
+	}
+	if !meta.IsStatusConditionTrue(backupSchedule.Status.Conditions,
+		v1beta1.BackupScheduleBackupTimedOut) {
+		t.Errorf("BackupTimedOut condition = %v, want True", backupSchedule.Status.Conditions)
+	}
+
+	// the condition is False once the backup is finished
+	backups[0].Status.Phase = veleroapi.BackupPhaseCompleted
+	r.checkBackupTimeout(backupSchedule, backups)
+	if !meta.IsStatusConditionFalse(backupSchedule.Status.Conditions,
+		v1beta1.BackupScheduleBackupTimedOut) {
+		t.Errorf("BackupTimedOut condition = %v, want False", backupSchedule.Status.Conditions)
+	}
+
+	// the condition is removed if the timeout is not set
+	backupSchedule.Spec.BackupTimeout = metav1.Duration{}
+	r.checkBackupTimeout(backupSchedule, backups)
+	if meta.FindStatusCondition(backupSchedule.Status.Conditions,
+		v1beta1.BackupScheduleBackupTimedOut) != nil {
+		t.Errorf("BackupTimedOut condition = %v, want none", backupSchedule.Status.Conditions)
+	}
+	recordedBackupEventsLock.Lock()
+	delete(recordedBackupEvents, backups[0].UID)
+	recordedBackupEventsLock.Unlock()
+}
+
+func Test_setStorageEncryptionCondition(t *testing.T) {
+
+	newStorageLocation := func(name string, provider string, config map[string]string) veleroapi.BackupStorageLocation {
+		return veleroapi.BackupStorageLocation{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "velero-ns",
+			},
+			Spec: veleroapi.BackupStorageLocationSpec{
+				Provider: provider,
+				Config:   config,
+			},
+		}
+	}
+	awsSSE := newStorageLocation("aws-sse", "aws", map[string]string{
+		"region":               "us-east-1",
+		"serverSideEncryption": "AES256",
+	})
+	awsKMS := newStorageLocation("aws-kms", "velero.io/aws", map[string]string{
+		"region":   "us-east-1",
+		"kmsKeyId": "502b409c-4da1-419f-a16e-eif453b3i49f",
+	})
+	awsNoSSE := newStorageLocation("aws-no-sse", "aws", map[string]string{
+		"region": "us-east-1",
+	})
+	gcpNoKMS := newStorageLocation("gcp-no-kms", "gcp", nil)
+	azure := newStorageLocation("azure", "azure", map[string]string{
+		"storageAccount": "acmbackups",
+	})
+
+	tests := []struct {
+		name             string
+		storageLocations []veleroapi.BackupStorageLocation
+		wantStatus       metav1.ConditionStatus
+		wantReason       string
+		wantMessage      string
+	}{
+		{
+			name:             "server-side encryption configured",
+			storageLocations: []veleroapi.BackupStorageLocation{awsSSE, awsKMS},
+			wantStatus:       metav1.ConditionTrue,
+			wantReason:       v1beta1.BackupScheduleReasonEncryptionVerified,
+			wantMessage:      "Server-side encryption is configured for storage locations: aws-sse, aws-kms",
+		},
+		{
+			name:             "server-side encryption not configured",
+			storageLocations: []veleroapi.BackupStorageLocation{awsSSE, awsNoSSE, gcpNoKMS, azure},
+			wantStatus:       metav1.ConditionFalse,
+			wantReason:       v1beta1.BackupScheduleReasonEncryptionDisabled,
+			wantMessage:      "Server-side encryption is not configured for storage locations: aws-no-sse, gcp-no-kms",
+		},
+		{
+			name:             "provider not verified",
+			storageLocations: []veleroapi.BackupStorageLocation{awsSSE, azure},
+			wantStatus:       metav1.ConditionUnknown,
+			wantReason:       v1beta1.BackupScheduleReasonEncryptionUnknown,
+			wantMessage:      "Server-side encryption can't be verified for storage locations: azure",
+		},
+		{
+			name:             "no storage location",
+			storageLocations: []veleroapi.BackupStorageLocation{},
+			wantStatus:       metav1.ConditionUnknown,
+			wantReason:       v1beta1.BackupScheduleReasonEncryptionUnknown,
+			wantMessage:      "No storage location found to verify the server-side encryption",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backupSchedule := initBackupSchedule("0 6 * * *")
+			setStorageEncryptionCondition(backupSchedule, tt.storageLocations)
+			condition := meta.FindStatusCondition(backupSchedule.Status.Conditions,
+				v1beta1.BackupScheduleStorageEncryption)
+			if condition == nil {
+				t.Fatalf("setStorageEncryptionCondition() condition not set")
+			}
+			if condition.Status != tt.wantStatus || condition.Reason != tt.wantReason ||
+				condition.Message != tt.wantMessage {
+				t.Errorf("setStorageEncryptionCondition() = %v %v %v, want %v %v %v",
+					condition.Status, condition.Reason, condition.Message,
+					tt.wantStatus, tt.wantReason, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func Test_setVeleroNotInstalledCondition(t *testing.T) {
+
+	backupSchedule := initBackupSchedule("0 6 * * *")
+
+	setVeleroNotInstalledCondition(backupSchedule, "")
+	if condition := meta.FindStatusCondition(backupSchedule.Status.Conditions,
+		v1beta1.BackupScheduleVeleroNotInstalled); condition != nil {
+		t.Errorf("setVeleroNotInstalledCondition() = %v, want no condition if velero is installed", condition)
+	}
+
+	setVeleroNotInstalledCondition(backupSchedule, "Velero is not installed")
+	condition := meta.FindStatusCondition(backupSchedule.Status.Conditions,
+		v1beta1.BackupScheduleVeleroNotInstalled)
+	if condition == nil || condition.Status != metav1.ConditionTrue ||
+		condition.Reason != v1beta1.BackupScheduleReasonVeleroCRDsNotFound ||
+		condition.Message != "Velero is not installed" {
+		t.Errorf("setVeleroNotInstalledCondition() = %v, want a True condition", condition)
+	}
+
+	// the condition is reset once velero is installed
+	setVeleroNotInstalledCondition(backupSchedule, "")
+	condition = meta.FindStatusCondition(backupSchedule.Status.Conditions,
+		v1beta1.BackupScheduleVeleroNotInstalled)
+	if condition == nil || condition.Status != metav1.ConditionFalse ||
+		condition.Reason != v1beta1.BackupScheduleReasonVeleroCRDsFound {
+		t.Errorf("setVeleroNotInstalledCondition() = %v, want a False condition", condition)
+	}
+}
+
+func Test_validateBackupHooks(t *testing.T) {
+
+	execHook := func(command ...string) veleroapi.BackupResourceHook {
+		return veleroapi.BackupResourceHook{
+			Exec: &veleroapi.ExecHook{
+				Command: command,
+			},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		hooks    []veleroapi.BackupResourceHookSpec
+		wantErrs int
+	}{
+		{
+			name:     "no hooks",
+			hooks:    nil,
+			wantErrs: 0,
+		},
+		{
+			name: "valid hooks",
+			hooks: []veleroapi.BackupResourceHookSpec{
+				{
+					Name:               "quiesce-db",
+					IncludedNamespaces: []string{"db-ns"},
+					ExcludedNamespaces: []string{"*"},
+					LabelSelector: &metav1.LabelSelector{
+						MatchLabels: map[string]string{"app": "db"},
+					},
+					PreHooks:  []veleroapi.BackupResourceHook{execHook("db-freeze")},
+					PostHooks: []veleroapi.BackupResourceHook{execHook("db-unfreeze")},
+				},
+			},
+			wantErrs: 0,
+		},
+		{
+			name: "invalid namespaces and label selector",
+			hooks: []veleroapi.BackupResourceHookSpec{
+				{
+					Name:               "quiesce-db",
+					IncludedNamespaces: []string{"DB_NS"},
+					ExcludedNamespaces: []string{"-ns"},
+					LabelSelector: &metav1.LabelSelector{
+						MatchLabels: map[string]string{"app": "db!"},
+					},
+				},
+			},
+			wantErrs: 3,
+		},
+		{
+			name: "missing name, exec command and invalid onError",
+			hooks: []veleroapi.BackupResourceHookSpec{
+				{
+					PreHooks: []veleroapi.BackupResourceHook{
+						{},
+						execHook(),
+						{
+							Exec: &veleroapi.ExecHook{
+								Command: []string{"db-freeze"},
+								OnError: "Retry",
+							},
+						},
+					},
+				},
+			},
+			wantErrs: 4,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backupSchedule := initBackupSchedule("0 6 * * *")
+			backupSchedule.Spec.Hooks.Resources = tt.hooks
+			if got := validateBackupHooks(backupSchedule); len(got) != tt.wantErrs {
+				t.Errorf("validateBackupHooks() = %v, want %d errors", got, tt.wantErrs)
+			}
+		})
+	}
+}
+
+func Test_getBackupHooks(t *testing.T) {
+
+	backupSchedule := initBackupSchedule("0 6 * * *")
+	backupSchedule.Spec.Hooks.Resources = []veleroapi.BackupResourceHookSpec{
+		{
+			Name: "quiesce-db",
+			PreHooks: []veleroapi.BackupResourceHook{
+				{
+					Exec: &veleroapi.ExecHook{
+						Command: []string{"db-freeze"},
+					},
+				},
+			},
+		},
+	}
+
+	if got := getBackupHooks(backupSchedule, Resources); !reflect.DeepEqual(got, backupSchedule.Spec.Hooks) {
+		t.Errorf("getBackupHooks() = %v, want %v", got, backupSchedule.Spec.Hooks)
+	}
+	for _, scheduleKey := range []ResourceType{Credentials, ManagedClusters, ResourcesGeneric, ValidationSchedule} {
+		if got := getBackupHooks(backupSchedule, scheduleKey); len(got.Resources) != 0 {
+			t.Errorf("getBackupHooks() for %s = %v, want no hooks", scheduleKey, got)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	v1beta1 "github.com/stolostron/cluster-backup-operator/api/v1beta1"
+	veleroapi "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+//+kubebuilder:webhook:path=/validate-cluster-open-cluster-management-io-v1beta1-backupschedule,mutating=false,failurePolicy=fail,sideEffects=None,groups=cluster.open-cluster-management.io,resources=backupschedules,verbs=create;update;delete,versions=v1beta1,name=vbackupschedule.kb.io,admissionReviewVersions=v1
+
+// BackupScheduleValidator rejects BackupSchedule resources with an invalid cron schedule,
+// velero TTL or resources filters, before they are stored in the cluster,
+// and the deletion of a BackupSchedule with backups in progress
+type BackupScheduleValidator struct {
+	// Client reads the velero backups created by the BackupSchedule;
+	// the deletions are not validated if not set
+	Client client.Reader
+}
+
+var _ admission.CustomValidator = &BackupScheduleValidator{}
+
+// SetupWebhookWithManager registers the BackupSchedule validating webhook with the manager
+func (v *BackupScheduleValidator) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&v1beta1.BackupSchedule{}).
+		WithValidator(v).
+		Complete()
+}
+
+// ValidateCreate validates the BackupSchedule created
+func (v *BackupScheduleValidator) ValidateCreate(ctx context.Context, obj runtime.Object) error {
+	return validateBackupScheduleObject(ctx, obj)
+}
+
+// ValidateUpdate validates the BackupSchedule updated
+func (v *BackupScheduleValidator) ValidateUpdate(
+	ctx context.Context,
+	oldObj, newObj runtime.Object,
+) error {
+	return validateBackupScheduleObject(ctx, newObj)
+}
+
+// ValidateDelete rejects the deletion of a BackupSchedule while velero backups
+// created by this schedule are in progress; the deletion is allowed if the velero
+// backups can't be listed, for example if velero is not installed
+func (v *BackupScheduleValidator) ValidateDelete(ctx context.Context, obj runtime.Object) error {
+
+	backupSchedule, ok := obj.(*v1beta1.BackupSchedule)
+	if !ok {
+		return apierrors.NewBadRequest(fmt.Sprintf("expected a BackupSchedule, found %T", obj))
+	}
+	if v.Client == nil {
+		return nil
+	}
+
+	backups := veleroapi.BackupList{}
+	if err := v.Client.List(ctx, &backups, client.InNamespace(backupSchedule.Namespace),
+		client.MatchingLabels{BackupScheduleNameLabel: backupSchedule.Name}); err != nil {
+		// don't block the deletion, the backups in progress can't be checked
+		log.FromContext(ctx).Info("Unable to list the velero backups, allowing the deletion",
+			"backupSchedule", backupSchedule.Name,
+			"noKindMatch", meta.IsNoMatchError(err),
+			"error", err.Error())
+		return nil
+	}
+	if inProgress := getInProgressBackups(backups.Items); len(inProgress) > 0 {
+		return apierrors.NewForbidden(
+			v1beta1.GroupVersion.WithResource("backupschedules").GroupResource(),
+			backupSchedule.Name,
+			fmt.Errorf("velero backups %s are in progress, delete the BackupSchedule "+
+				"once these backups are finished", strings.Join(inProgress, ", ")),
+		)
+	}
+	return nil
+}
+
+// returns the names of the velero backups not finished yet
+func getInProgressBackups(backups []veleroapi.Backup) []string {
+
+	inProgress := []string{}
+	for i := range backups {
+		switch backups[i].Status.Phase {
+		case veleroapi.BackupPhaseNew, veleroapi.BackupPhaseInProgress:
+			inProgress = append(inProgress, backups[i].Name)
+		}
+	}
+	sort.Strings(inProgress)
+	return inProgress
+}
+
+func validateBackupScheduleObject(ctx context.Context, obj runtime.Object) error {
+
+	backupSchedule, ok := obj.(*v1beta1.BackupSchedule)
+	if !ok {
+		return apierrors.NewBadRequest(fmt.Sprintf("expected a BackupSchedule, found %T", obj))
+	}
+
+	errs := validateBackupScheduleFields(ctx, backupSchedule)
+	if len(errs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(
+		v1beta1.GroupVersion.WithKind("BackupSchedule").GroupKind(),
+		backupSchedule.Name,
+		errs,
+	)
+}
+
+// returns the backup schedule spec validation errors, with the path of the offending field
+func validateBackupScheduleFields(
+	ctx context.Context,
+	backupSchedule *v1beta1.BackupSchedule,
+) field.ErrorList {
+
+	specPath := field.NewPath("spec")
+	errs := field.ErrorList{}
+
+	for _, msg := range parseCronSchedule(ctx, backupSchedule) {
+		errs = append(errs, field.Invalid(specPath.Child("veleroSchedule"),
+			backupSchedule.Spec.VeleroSchedule, msg))
+	}
+	errs = append(errs, getVeleroScheduleOverridesErrors(ctx, backupSchedule, specPath)...)
+	if backupSchedule.Spec.ValidationSchedule != "" {
+		for _, msg := range parseCronExpression(ctx, backupSchedule.Spec.ValidationSchedule) {
+			errs = append(errs, field.Invalid(specPath.Child("validationSchedule"),
+				backupSchedule.Spec.ValidationSchedule, msg))
+		}
+	}
+	for _, msg := range validateVeleroTTL(backupSchedule) {
+		errs = append(errs, field.Invalid(specPath.Child("veleroTtl"),
+			backupSchedule.Spec.VeleroTTL.Duration.String(), msg))
+	}
+	jitterErrs, _ := validateScheduleJitter(backupSchedule)
+	for _, msg := range jitterErrs {
+		errs = append(errs, field.Invalid(specPath.Child("scheduleJitter"),
+			backupSchedule.Spec.ScheduleJitter.Duration.String(), msg))
+	}
+	for i, namespace := range backupSchedule.Spec.ExcludedNamespaces {
+		for _, msg := range validation.IsDNS1123Label(namespace) {
+			errs = append(errs, field.Invalid(specPath.Child("excludedNamespaces").Index(i),
+				namespace, msg))
+		}
+	}
+	for _, msg := range validateGenericResourceLabelSelector(backupSchedule) {
+		errs = append(errs, field.Invalid(specPath.Child("genericResourceLabelSelector"),
+			backupSchedule.Spec.GenericResourceLabelSelector, msg))
+	}
+	for _, msg := range validateManagedClustersLabelSelector(backupSchedule) {
+		errs = append(errs, field.Invalid(specPath.Child("managedClustersLabelSelector"),
+			backupSchedule.Spec.ManagedClustersLabelSelector, msg))
+	}
+	for _, msg := range validateBackupHooks(backupSchedule) {
+		errs = append(errs, field.Invalid(specPath.Child("hooks"),
+			backupSchedule.Spec.Hooks, msg))
+	}
+	errs = append(errs, getBackupMetadataErrors(backupSchedule, specPath)...)
+
+	switch backupSchedule.Spec.NamespaceBackupMode {
+	case "", v1beta1.NamespaceBackupModeNamespaceOnly, v1beta1.NamespaceBackupModeNamespaceContents:
+	default:
+		errs = append(errs, field.NotSupported(specPath.Child("namespaceBackupMode"),
+			backupSchedule.Spec.NamespaceBackupMode, []string{
+				string(v1beta1.NamespaceBackupModeNamespaceOnly),
+				string(v1beta1.NamespaceBackupModeNamespaceContents),
+			}))
+	}
+
+	return errs
+}
+
+// returns the messages of the backup schedule spec validation errors,
+// as reported by the schedule controller and the validate-schedule command
+func getBackupScheduleValidationMessages(
+	ctx context.Context,
+	backupSchedule *v1beta1.BackupSchedule,
+) []string {
+
+	validationErrors := []string{}
+	for _, err := range validateBackupScheduleFields(ctx, backupSchedule) {
+		validationErrors = append(validationErrors, err.Error())
+	}
+	return validationErrors
+}
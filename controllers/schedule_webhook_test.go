@@ -0,0 +1,341 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	v1beta1 "github.com/stolostron/cluster-backup-operator/api/v1beta1"
+	veleroapi "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+)
+
+// starts a test environment with the webhooks of config/webhook and a manager serving
+// the webhooks registered by setupWebhooks; returns a client of the test environment
+// and a context canceled, stopping the manager, when the test completes
+func startWebhookTestEnv(
+	t *testing.T,
+	setupWebhooks func(mgr ctrl.Manager) error,
+) (client.Client, context.Context) {
+
+	webhookTestEnv := &envtest.Environment{
+		CRDDirectoryPaths:     []string{filepath.Join("..", "config", "crd", "bases")},
+		ErrorIfCRDPathMissing: true,
+		WebhookInstallOptions: envtest.WebhookInstallOptions{
+			Paths: []string{filepath.Join("..", "config", "webhook")},
+		},
+	}
+	cfg, err := webhookTestEnv.Start()
+	if err != nil {
+		t.Fatalf("unable to start the test environment: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := webhookTestEnv.Stop(); err != nil {
+			t.Errorf("unable to stop the test environment: %v", err)
+		}
+	})
+
+	webhookScheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(webhookScheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := v1beta1.AddToScheme(webhookScheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := veleroapi.AddToScheme(webhookScheme); err != nil {
+		t.Fatal(err)
+	}
+
+	webhookInstallOptions := &webhookTestEnv.WebhookInstallOptions
+	mgr, err := ctrl.NewManager(cfg, ctrl.Options{
+		Scheme:             webhookScheme,
+		Host:               webhookInstallOptions.LocalServingHost,
+		Port:               webhookInstallOptions.LocalServingPort,
+		CertDir:            webhookInstallOptions.LocalServingCertDir,
+		LeaderElection:     false,
+		MetricsBindAddress: "0",
+	})
+	if err != nil {
+		t.Fatalf("unable to create the manager: %v", err)
+	}
+	if err := setupWebhooks(mgr); err != nil {
+		t.Fatalf("unable to set up the webhook: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	// registered after the test environment stop, so it runs first
+	t.Cleanup(cancel)
+	go func() {
+		if err := mgr.Start(ctx); err != nil {
+			t.Errorf("problem running manager: %v", err)
+		}
+	}()
+
+	// wait for the webhook server to serve requests
+	address := net.JoinHostPort(webhookInstallOptions.LocalServingHost,
+		fmt.Sprint(webhookInstallOptions.LocalServingPort))
+	dialer := &net.Dialer{Timeout: time.Second}
+	if err := waitFor(10*time.Second, func() bool {
+		conn, err := tls.DialWithDialer(dialer, "tcp", address,
+			&tls.Config{InsecureSkipVerify: true}) // #nosec G402 test webhook server
+		if err != nil {
+			return false
+		}
+		conn.Close()
+		return true
+	}); err != nil {
+		t.Fatalf("webhook server not ready: %v", err)
+	}
+
+	webhookClient, err := client.New(cfg, client.Options{Scheme: webhookScheme})
+	if err != nil {
+		t.Fatalf("unable to create the client: %v", err)
+	}
+	return webhookClient, ctx
+}
+
+// the webhook runs in its own test environment, the controller suite
+// creates invalid backup schedules to test the operator validation
+func TestBackupScheduleWebhook(t *testing.T) {
+
+	webhookClient, ctx := startWebhookTestEnv(t, func(mgr ctrl.Manager) error {
+		return (&BackupScheduleValidator{
+			Client: mgr.GetAPIReader(),
+		}).SetupWebhookWithManager(mgr)
+	})
+
+	namespace := "webhook-ns"
+	if err := webhookClient.Create(ctx, &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: namespace},
+	}); err != nil {
+		t.Fatalf("unable to create the namespace: %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		schedule      string
+		ttl           time.Duration
+		overrides     map[string]string
+		wantErrFields []string
+	}{
+		{
+			name:     "valid schedule",
+			schedule: "0 */6 * * *",
+			ttl:      time.Hour * 72,
+		},
+		{
+			name:     "valid schedule descriptor",
+			schedule: "@every 2h",
+		},
+		{
+			name:          "empty schedule",
+			schedule:      "",
+			wantErrFields: []string{"spec.veleroSchedule"},
+		},
+		{
+			name:          "missing schedule fields",
+			schedule:      "* * *",
+			wantErrFields: []string{"spec.veleroSchedule"},
+		},
+		{
+			name:          "minute out of range",
+			schedule:      "61 * * * *",
+			wantErrFields: []string{"spec.veleroSchedule"},
+		},
+		{
+			name:          "not a cron expression",
+			schedule:      "not a cron",
+			wantErrFields: []string{"spec.veleroSchedule"},
+		},
+		{
+			name:          "negative ttl",
+			schedule:      "0 */6 * * *",
+			ttl:           -time.Hour,
+			wantErrFields: []string{"spec.veleroTtl"},
+		},
+		{
+			name:          "invalid schedule and negative ttl",
+			schedule:      "0 25 * * *",
+			ttl:           -time.Hour,
+			wantErrFields: []string{"spec.veleroSchedule", "spec.veleroTtl"},
+		},
+		{
+			name:     "valid schedule overrides",
+			schedule: "0 */6 * * *",
+			overrides: map[string]string{
+				string(Credentials):     "0 * * * *",
+				string(ManagedClusters): "0 2 * * *",
+			},
+		},
+		{
+			name:     "invalid schedule override",
+			schedule: "0 */6 * * *",
+			overrides: map[string]string{
+				string(Credentials): "0 25 * * *",
+				"policies":          "0 * * * *",
+			},
+			wantErrFields: []string{"spec.veleroScheduleOverrides[credentials]",
+				"spec.veleroScheduleOverrides[policies]"},
+		},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backupSchedule := &v1beta1.BackupSchedule{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      fmt.Sprintf("webhook-schedule-%d", i),
+					Namespace: namespace,
+				},
+				Spec: v1beta1.BackupScheduleSpec{
+					VeleroSchedule:          tt.schedule,
+					VeleroTTL:               metav1.Duration{Duration: tt.ttl},
+					VeleroScheduleOverrides: tt.overrides,
+				},
+			}
+			err := webhookClient.Create(ctx, backupSchedule)
+			if len(tt.wantErrFields) == 0 {
+				if err != nil {
+					t.Errorf("Create() error = %v, want no error", err)
+				}
+				return
+			}
+			if !apierrors.IsInvalid(err) {
+				t.Fatalf("Create() error = %v, want an invalid error", err)
+			}
+			for _, fieldPath := range tt.wantErrFields {
+				if !strings.Contains(err.Error(), fieldPath) {
+					t.Errorf("Create() error = %v, want an error for %s", err, fieldPath)
+				}
+			}
+		})
+	}
+
+	// updates are validated too
+	backupSchedule := &v1beta1.BackupSchedule{}
+	if err := webhookClient.Get(ctx, client.ObjectKey{
+		Name: "webhook-schedule-0", Namespace: namespace}, backupSchedule); err != nil {
+		t.Fatalf("unable to get the backup schedule: %v", err)
+	}
+	backupSchedule.Spec.VeleroSchedule = "0 0 31 2 * *"
+	if err := webhookClient.Update(ctx, backupSchedule); !apierrors.IsInvalid(err) ||
+		!strings.Contains(err.Error(), "spec.veleroSchedule") {
+		t.Errorf("Update() error = %v, want an error for spec.veleroSchedule", err)
+	}
+
+	// a backup schedule can't be deleted while its backups are in progress
+	veleroBackup := &veleroapi.Backup{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "acm-resources-schedule-20220420120000",
+			Namespace: namespace,
+			Labels: map[string]string{
+				BackupScheduleNameLabel: "webhook-schedule-0",
+			},
+		},
+		Status: veleroapi.BackupStatus{
+			Phase: veleroapi.BackupPhaseInProgress,
+		},
+	}
+	if err := webhookClient.Create(ctx, veleroBackup); err != nil {
+		t.Fatalf("unable to create the velero backup: %v", err)
+	}
+	if err := webhookClient.Delete(ctx, backupSchedule); !apierrors.IsForbidden(err) ||
+		!strings.Contains(err.Error(), veleroBackup.Name) {
+		t.Errorf("Delete() error = %v, want a forbidden error for %s", err, veleroBackup.Name)
+	}
+
+	// and can be deleted once the backups are finished
+	veleroBackup.Status.Phase = veleroapi.BackupPhaseCompleted
+	if err := webhookClient.Update(ctx, veleroBackup); err != nil {
+		t.Fatalf("unable to update the velero backup: %v", err)
+	}
+	if err := webhookClient.Delete(ctx, backupSchedule); err != nil {
+		t.Errorf("Delete() error = %v, want no error", err)
+	}
+}
+
+// polls the condition until it returns true or the timeout expires
+// a client.Reader failing to list the resources
+type failingListReader struct {
+	client.Reader
+	err error
+}
+
+func (r *failingListReader) List(
+	ctx context.Context,
+	list client.ObjectList,
+	opts ...client.ListOption,
+) error {
+	return r.err
+}
+
+func Test_BackupScheduleValidator_ValidateDelete_listErrors(t *testing.T) {
+
+	backupSchedule := &v1beta1.BackupSchedule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "schedule-acm",
+			Namespace: "open-cluster-management-backup",
+		},
+	}
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{
+			name: "velero backup kind not installed",
+			err: &meta.NoKindMatchError{
+				GroupKind: veleroapi.SchemeGroupVersion.WithKind("Backup").GroupKind(),
+			},
+		},
+		{
+			name: "list error",
+			err:  apierrors.NewServiceUnavailable("the cache is not synced"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			validator := &BackupScheduleValidator{Client: &failingListReader{err: tt.err}}
+			if err := validator.ValidateDelete(context.Background(), backupSchedule); err != nil {
+				t.Errorf("ValidateDelete() error = %v, want the deletion allowed", err)
+			}
+		})
+	}
+}
+
+func waitFor(timeout time.Duration, condition func() bool) error {
+	deadline := time.Now().Add(timeout)
+	for !condition() {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("condition not met after %s", timeout)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return nil
+}
@@ -138,6 +138,15 @@ var _ = BeforeSuite(func() {
 			{Name: "managedclustermutators", Namespaced: false, Kind: "AdmissionReview"},
 		},
 	}
+	veleroInfo := metav1.APIResourceList{
+		GroupVersion: "velero.io/v1",
+		APIResources: []metav1.APIResource{
+			{Name: "backups", Namespaced: true, Kind: "Backup"},
+			{Name: "backupstoragelocations", Namespaced: true, Kind: "BackupStorageLocation"},
+			{Name: "restores", Namespaced: true, Kind: "Restore"},
+			{Name: "schedules", Namespaced: true, Kind: "Schedule"},
+		},
+	}
 	hiveInfo := metav1.APIResourceList{
 		GroupVersion: "hive.openshift.io/v1",
 		APIResources: []metav1.APIResource{
@@ -167,6 +176,8 @@ var _ = BeforeSuite(func() {
 			list = &argov1alphaInfo
 		case "/apis/config.openshift.io/v1":
 			list = &openshiftv1Info
+		case "/apis/velero.io/v1":
+			list = &veleroInfo
 
 		case "/api":
 			list = &metav1.APIVersions{
@@ -244,6 +255,12 @@ var _ = BeforeSuite(func() {
 							{GroupVersion: "apps.open-cluster-management.io/v1", Version: "v1"},
 						},
 					},
+					{
+						Name: "velero.io",
+						Versions: []metav1.GroupVersionForDiscovery{
+							{GroupVersion: "velero.io/v1", Version: "v1"},
+						},
+					},
 				},
 			}
 		default:
@@ -410,6 +427,7 @@ var _ = BeforeSuite(func() {
 		DiscoveryClient: fakeDiscovery,
 		DynamicClient:   dyn,
 		RESTMapper:      mapper,
+		Recorder:        mgr.GetEventRecorderFor("schedule reconciler"),
 	}).SetupWithManager(mgr)
 	Expect(err).ToNot(HaveOccurred())
 
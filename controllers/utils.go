@@ -18,17 +18,29 @@ package controllers
 
 import (
 	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
 	"fmt"
+	"net/http"
 	"reflect"
+	"regexp"
 	"strings"
+	"sync"
 	"time"
 
 	veleroapi "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/restmapper"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 )
 
@@ -59,6 +71,48 @@ func findValue(slice []string, val string) bool {
 	return ok
 }
 
+// findValueCaseInsensitive returns true if the slice contains the value, ignoring case;
+// used to compare resource kinds and groups, which can differ by case
+// between the discovery api and the user values
+func findValueCaseInsensitive(slice []string, val string) bool {
+	for _, item := range slice {
+		if strings.EqualFold(item, val) {
+			return true
+		}
+	}
+	return false
+}
+
+// set of strings compared ignoring case, for the membership checks on large lists
+type caseInsensitiveSet map[string]struct{}
+
+// returns a set with the values of all the slices
+func newCaseInsensitiveSet(slices ...[]string) caseInsensitiveSet {
+	set := caseInsensitiveSet{}
+	for _, slice := range slices {
+		for _, value := range slice {
+			set.insert(value)
+		}
+	}
+	return set
+}
+
+// adds the value to the set, returns false if the value is already in the set
+func (s caseInsensitiveSet) insert(value string) bool {
+	key := strings.ToLower(value)
+	if _, ok := s[key]; ok {
+		return false
+	}
+	s[key] = struct{}{}
+	return true
+}
+
+// returns true if the set contains the value
+func (s caseInsensitiveSet) has(value string) bool {
+	_, ok := s[strings.ToLower(value)]
+	return ok
+}
+
 //append unique value to a list
 func appendUnique(slice []string, value string) []string {
 	// check if the NS exists
@@ -77,60 +131,345 @@ func min(x, y int) int {
 	return y
 }
 
+// number of hash characters appended to a trimmed velero restore name
+const restoreNameHashLength = 8
+
+// returns the name in lower case, with the characters not valid in a DNS-1123 subdomain
+// replaced by '-'; each '.' separated label starts and ends with an alphanumeric character,
+// the leading and trailing '-' of the labels and the empty labels are removed
+func sanitizeName(name string) string {
+	sanitized := strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '-' || r == '.' {
+			return r
+		}
+		return '-'
+	}, strings.ToLower(name))
+
+	labels := []string{}
+	for _, label := range strings.Split(sanitized, ".") {
+		if label = strings.Trim(label, "-"); label != "" {
+			labels = append(labels, label)
+		}
+	}
+	return strings.Join(labels, ".")
+}
+
 // returns a valid name for the velero restore kubernetes resource
-// by trimming the concatenated cluster restore and backup names
+// by sanitizing and trimming the concatenated cluster restore and backup names;
+// a sanitized or trimmed name ends with a hash of the full name so that
+// names sharing a long common prefix, or differing only by invalid characters,
+// don't collide
 func getValidKsRestoreName(clusterRestoreName string, backupName string) string {
 	//max name for ns or resources is 253 chars
 	fullName := clusterRestoreName + "-" + backupName
+	validName := sanitizeName(fullName)
 
-	if len(fullName) > 252 {
-		return fullName[:252]
+	if validName == fullName && len(validName) <= 252 &&
+		len(validation.IsDNS1123Subdomain(validName)) == 0 {
+		return validName
+	}
+	hash := sha256.Sum256([]byte(fullName))
+	nameHash := hex.EncodeToString(hash[:])[:restoreNameHashLength]
+	prefix := strings.TrimRight(validName[:min(len(validName), 252-restoreNameHashLength-1)], "-.")
+	if prefix == "" {
+		return nameHash
 	}
-	return fullName
+	return prefix + "-" + nameHash
 }
 
+// layout of the timestamp set by Velero on the backup names
+const backupTimestampLayout = "20060102150405"
+
+// matches the 14 digits timestamp at the end of a Velero backup name
+var backupTimestampRegexp = regexp.MustCompile(`-([0-9]{14})$`)
+
 // Velero uses TimestampedName for backups using the follwoing format
 // by setting the default backup name format based on the schedule
 // fmt.Sprintf("%s-%s", s.Name, timestamp.Format("20060102150405"))
 // this function parses Velero backupName and returns the timestamp
+// or an error if the name doesn't end with a valid timestamp
 func getBackupTimestamp(backupName string) (time.Time, error) {
-	timestampIndex := strings.LastIndex(backupName, "-")
-	if timestampIndex != -1 {
-		timestampStr := strings.Trim(backupName[timestampIndex:], "-")
-		return time.Parse("20060102150405", timestampStr)
+	match := backupTimestampRegexp.FindStringSubmatch(backupName)
+	if match == nil || len(match[1]) != len(backupTimestampLayout) {
+		return time.Time{}, fmt.Errorf("no timestamp found in backup name %s", backupName)
+	}
+	return time.Parse(backupTimestampLayout, match[1])
+}
+
+// returns the backup time, the timestamp in the backup name,
+// or the backup creation time if the name has no timestamp
+func getBackupTime(backup *veleroapi.Backup) time.Time {
+	if timestamp, err := getBackupTimestamp(backup.Name); err == nil {
+		return timestamp
 	}
-	return time.Time{}, nil
+	return backup.CreationTimestamp.Time
 }
 
-// SortResourceType implements sort.Interface
+// SortResourceType implements sort.Interface, sorting the resources
+// in the kind.group format by group, then by kind; resources with no group are last
 type SortResourceType []ResourceType
 
-func (a SortResourceType) Len() int           { return len(a) }
-func (a SortResourceType) Less(i, j int) bool { return a[i] < a[j] }
-func (a SortResourceType) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a SortResourceType) Len() int      { return len(a) }
+func (a SortResourceType) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a SortResourceType) Less(i, j int) bool {
+	kindI, groupI := getResourceDetails(string(a[i]))
+	kindJ, groupJ := getResourceDetails(string(a[j]))
+	if groupI != groupJ {
+		if groupI == "" || groupJ == "" {
+			// the resources with no group are sorted last
+			return groupJ == ""
+		}
+		return groupI < groupJ
+	}
+	return kindI < kindJ
+}
+
+// a valid velero storage location
+type storageLocationRef struct {
+	Name      string
+	Namespace string
+	// true if this is the default storage location used by velero
+	Default bool
+	// ReadOnly storage locations can be used to restore backups but not to create them
+	AccessMode veleroapi.BackupStorageLocationAccessMode
+}
+
+// how long a storage location not used since it has no owner is not logged again;
+// the storage locations are validated several times on each reconcile
+const unownedStorageLocationLogTTL = time.Hour
 
-// check if we have a valid storage location object
-func isValidStorageLocationDefined(
+// the time each unowned storage location was last logged, by namespace/name
+var (
+	unownedStorageLocationsLogged     = map[string]time.Time{}
+	unownedStorageLocationsLoggedLock sync.Mutex
+)
+
+// returns true if the unowned storage location was not logged within the
+// unownedStorageLocationLogTTL, and records it as logged at the given time
+func isUnownedStorageLocationToLog(
+	storageLocation *veleroapi.BackupStorageLocation,
+	now time.Time,
+) bool {
+
+	unownedStorageLocationsLoggedLock.Lock()
+	defer unownedStorageLocationsLoggedLock.Unlock()
+
+	key := storageLocation.Namespace + "/" + storageLocation.Name
+	if logged, ok := unownedStorageLocationsLogged[key]; ok &&
+		now.Sub(logged) < unownedStorageLocationLogTTL {
+		return false
+	}
+	unownedStorageLocationsLogged[key] = now
+	return true
+}
+
+// returns the valid storage locations, which are available and owned by the resource
+// used to install velero; if acceptUnowned is set, the available storage locations
+// with no owner, for example created by GitOps, are valid too
+func getValidStorageLocations(
+	ctx context.Context,
 	veleroStorageLocations veleroapi.BackupStorageLocationList,
-) (bool, string) {
-	isValidStorageLocation := false
-	veleroNamespace := ""
+	acceptUnowned bool,
+) []storageLocationRef {
+	logger := log.FromContext(ctx)
+	validStorageLocations := []storageLocationRef{}
 	for i := range veleroStorageLocations.Items {
-		if veleroStorageLocations.Items[i].OwnerReferences != nil &&
-			veleroStorageLocations.Items[i].Status.Phase == veleroapi.BackupStorageLocationPhaseAvailable {
-			for _, ref := range veleroStorageLocations.Items[i].OwnerReferences {
-				if ref.Kind != "" {
-					isValidStorageLocation = true
-					veleroNamespace = veleroStorageLocations.Items[i].Namespace
-					break
-				}
+		storageLocation := &veleroStorageLocations.Items[i]
+		if storageLocation.Status.Phase != veleroapi.BackupStorageLocationPhaseAvailable {
+			logger.V(1).Info("Storage location not used, it is not available",
+				"name", storageLocation.Name, "namespace", storageLocation.Namespace,
+				"phase", storageLocation.Status.Phase)
+			continue
+		}
+		if !acceptUnowned && !isStorageLocationOwned(storageLocation) {
+			if isUnownedStorageLocationToLog(storageLocation, time.Now()) {
+				logger.Info("Storage location not used, it has no owner reference. "+
+					"Run the operator with --accept-unowned-storage-locations to use it",
+					"name", storageLocation.Name, "namespace", storageLocation.Namespace)
 			}
+			continue
+		}
+		validStorageLocations = append(validStorageLocations, storageLocationRef{
+			Name:       storageLocation.Name,
+			Namespace:  storageLocation.Namespace,
+			Default:    storageLocation.Spec.Default,
+			AccessMode: storageLocation.Spec.AccessMode,
+		})
+	}
+	return validStorageLocations
+}
+
+// returns true if the storage location has an owner reference with a kind,
+// usually the resource used to install velero
+func isStorageLocationOwned(storageLocation *veleroapi.BackupStorageLocation) bool {
+	for _, ref := range storageLocation.OwnerReferences {
+		if ref.Kind != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// returns the storage locations where backups can be created
+func getWritableStorageLocations(storageLocations []storageLocationRef) []storageLocationRef {
+	writableStorageLocations := []storageLocationRef{}
+	for i := range storageLocations {
+		if storageLocations[i].AccessMode != veleroapi.BackupStorageLocationAccessModeReadOnly {
+			writableStorageLocations = append(writableStorageLocations, storageLocations[i])
+		}
+	}
+	return writableStorageLocations
+}
+
+// returns the storage locations in the namespace
+func getStorageLocationsInNamespace(
+	storageLocations []storageLocationRef,
+	namespace string,
+) []storageLocationRef {
+	namespaceStorageLocations := []storageLocationRef{}
+	for i := range storageLocations {
+		if storageLocations[i].Namespace == namespace {
+			namespaceStorageLocations = append(namespaceStorageLocations, storageLocations[i])
+		}
+	}
+	return namespaceStorageLocations
+}
+
+// returns the namespace of the velero install: the velero namespace set on the resource,
+// or the namespace of the first valid storage location if not set
+func getVeleroNamespace(veleroNamespace string, validStorageLocations []storageLocationRef) string {
+	if veleroNamespace != "" {
+		return veleroNamespace
+	}
+	if len(validStorageLocations) > 0 {
+		return validStorageLocations[0].Namespace
+	}
+	return ""
+}
+
+// returns the object store endpoint for the storage location,
+// or an empty string if the endpoint can't be found from the storage location config
+func getStorageLocationEndpoint(storageLocation *veleroapi.BackupStorageLocation) string {
+
+	config := storageLocation.Spec.Config
+	if s3Url := config["s3Url"]; s3Url != "" {
+		return s3Url
+	}
+	switch strings.TrimPrefix(storageLocation.Spec.Provider, "velero.io/") {
+	case "aws":
+		if region := config["region"]; region != "" {
+			return fmt.Sprintf("https://s3.%s.amazonaws.com", region)
+		}
+	case "azure":
+		if storageAccount := config["storageAccount"]; storageAccount != "" {
+			return fmt.Sprintf("https://%s.blob.core.windows.net", storageAccount)
+		}
+	case "gcp":
+		return "https://storage.googleapis.com"
+	}
+	return ""
+}
+
+// returns the TLS config used to probe the storage location object store,
+// trusting the CA bundle set on the storage location, as velero does
+func getStorageLocationTLSConfig(
+	storageLocation *veleroapi.BackupStorageLocation,
+) (*tls.Config, error) {
+
+	tlsConfig := &tls.Config{
+		// #nosec G402 -- set by the storage location insecureSkipTLSVerify config
+		InsecureSkipVerify: storageLocation.Spec.Config["insecureSkipTLSVerify"] == "true",
+	}
+	if storageLocation.Spec.ObjectStorage == nil ||
+		len(storageLocation.Spec.ObjectStorage.CACert) == 0 {
+		return tlsConfig, nil
+	}
+
+	rootCAs, err := x509.SystemCertPool()
+	if err != nil {
+		rootCAs = x509.NewCertPool()
+	}
+	if !rootCAs.AppendCertsFromPEM(storageLocation.Spec.ObjectStorage.CACert) {
+		return nil, fmt.Errorf("caCert doesn't contain a valid PEM encoded certificate")
+	}
+	tlsConfig.RootCAs = rootCAs
+	return tlsConfig, nil
+}
+
+// sends a request to the storage location object store endpoint
+// and returns the time it took to get a response
+// any response means the object store is reachable; credentials are validated by velero
+func probeStorageLocation(
+	ctx context.Context,
+	endpoint string,
+	tlsConfig *tls.Config,
+	timeout time.Duration,
+) (time.Duration, error) {
+
+	// use the default transport settings, including the proxy from the environment
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	probeClient := &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, endpoint, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	start := time.Now()
+	resp, err := probeClient.Do(req)
+	latency := time.Since(start)
+	if err != nil {
+		return latency, err
+	}
+	resp.Body.Close()
+	return latency, nil
+}
+
+// probe the available storage locations in the velero namespace
+// returns the probe latency for each storage location, or an error
+// if a storage location object store doesn't respond within the timeout
+func probeStorageLocations(
+	ctx context.Context,
+	veleroStorageLocations []veleroapi.BackupStorageLocation,
+	veleroNamespace string,
+	timeout time.Duration,
+) (string, error) {
+
+	logger := log.FromContext(ctx)
+
+	results := []string{}
+	for i := range veleroStorageLocations {
+		storageLocation := &veleroStorageLocations[i]
+		if storageLocation.Namespace != veleroNamespace ||
+			storageLocation.Status.Phase != veleroapi.BackupStorageLocationPhaseAvailable {
+			continue
+		}
+		endpoint := getStorageLocationEndpoint(storageLocation)
+		if endpoint == "" {
+			logger.Info("Skipping probe for storage location, endpoint not found",
+				"name", storageLocation.Name)
+			continue
+		}
+
+		tlsConfig, err := getStorageLocationTLSConfig(storageLocation)
+		if err != nil {
+			return strings.Join(results, ", "), fmt.Errorf(
+				"storage location %s cannot be probed: %v", storageLocation.Name, err)
 		}
-		if isValidStorageLocation {
-			break
+		latency, err := probeStorageLocation(ctx, endpoint, tlsConfig, timeout)
+		if err != nil {
+			return strings.Join(results, ", "), fmt.Errorf(
+				"storage location %s did not respond within %s: %v",
+				storageLocation.Name, timeout, err)
 		}
+		logger.Info("Storage location probe", "name", storageLocation.Name, "latency", latency)
+		results = append(results, fmt.Sprintf("%s responded in %s",
+			storageLocation.Name, latency.Round(time.Millisecond)))
 	}
-	return isValidStorageLocation, veleroNamespace
+	return strings.Join(results, ", "), nil
 }
 
 // having a resourceKind.resourceGroup string, return (resourceKind, resourceGroup)
@@ -146,63 +485,201 @@ func getResourceDetails(resourceName string) (string, string) {
 
 // retrurn the set of CRDs for a potential generic resource,
 // backed up by acm-resources-generic-schedule
-// labeled by cluster.open-cluster-management.io/backup
+// and selected by the backup label selector, see getGenericResourcesLabelSelector;
+// the resources of the excluded API groups are skipped, see isExcludedAPIGroup
 func getGenericCRDFromAPIGroups(
 	ctx context.Context,
 	dc discovery.DiscoveryInterface,
 	veleroBackup *veleroapi.Backup,
+	excludedGroups []string,
 ) ([]string, error) {
 
-	logger := log.FromContext(ctx)
-
-	resources := []string{}
+	spanCtx, span := tracer.Start(ctx, "discover generic resources")
+	defer span.End()
+	defer observeDiscoveryDuration(time.Now())
 
-	groupList, err := dc.ServerGroups()
+	groupVersions, err := getServerGroupVersionResources(spanCtx, dc)
 	if err != nil {
-		return resources, fmt.Errorf("failed to get server groups: %v", err)
+		return []string{}, err
 	}
-	if groupList == nil {
-		return resources, nil
+	resources := getGenericResources(groupVersions, excludedGroups, veleroBackup.Spec.ExcludedResources)
+
+	// an excluded resource with a typo silently excludes nothing, report it
+	if unmatched := getUnmatchedExcludedResources(groupVersions,
+		veleroBackup.Spec.ExcludedResources); len(unmatched) > 0 {
+		log.FromContext(ctx).Info("Excluded resources not matching any resource kind on the hub",
+			"backup", veleroBackup.Name, "resources", unmatched)
 	}
-	for _, group := range groupList.Groups {
-		for _, version := range group.Versions {
-			//get all resources for each group version
-			resourceList, err := dc.ServerResourcesForGroupVersion(version.GroupVersion)
-			if err != nil {
-				logger.Error(err, "failed to get server resources")
+
+	return resources, nil
+}
+
+// returns the generic resources served by the API groups, in the canonical kind.group format,
+// see getCanonicalResourceName; a kind served by more than one version of a group is listed once.
+// The resources with no API group, the subresources and the resources of the excluded groups
+// are skipped, as are the excluded resources set using the kind or the kind.group format
+func getGenericResources(
+	groupVersions []groupVersionResources,
+	excludedGroups []string,
+	excludedResources []string,
+) []string {
+
+	resources := []string{}
+	// the excluded resources are set by users and can differ by case from the discovered kinds
+	excluded := caseInsensitiveSet{}
+	for _, resource := range excludedResources {
+		excluded.insert(getCanonicalResourceName(resource))
+	}
+	added := caseInsensitiveSet{}
+	for _, groupVersion := range groupVersions {
+		group := groupVersion.group
+		if group.Name == "" {
+			// don't want any resource with no apigroup
+			continue
+		}
+		if isExcludedAPIGroup(excludedGroups, group.Name) {
+			continue
+		}
+		for _, resource := range groupVersion.resourceList.APIResources {
+			if strings.Contains(resource.Name, "/") {
+				// subresources, such as deployments/scale, use the kind of another resource
 				continue
 			}
-			if resourceList == nil || group.Name == "" {
-				// don't want any resource with no apigroup
-				continue
+
+			resourceName := getCanonicalResourceName(resource.Kind + "." + group.Name)
+			resourceKind, _ := getResourceDetails(resourceName)
+
+			if !excluded.has(resourceName) &&
+				!excluded.has(resourceKind) &&
+				added.insert(resourceName) {
+				resources = append(resources, resourceName)
 			}
-			for _, resource := range resourceList.APIResources {
+		}
+	}
+	return resources
+}
+
+// returns the resource name in the canonical kind.group format used by the backups,
+// with a lowercase kind and group and no surrounding spaces or dots; a resource with no group
+// is returned as the lowercase kind
+func getCanonicalResourceName(resourceName string) string {
+
+	kind, group := getResourceDetails(strings.ToLower(strings.TrimSpace(resourceName)))
+	group = strings.Trim(group, ". ")
+	if group == "" {
+		return kind
+	}
+	return kind + "." + group
+}
 
-				resourceKind := strings.ToLower(resource.Kind)
-				resourceName := resourceKind + "." + group.Name
+// returns true if the API group is one of the excluded groups set by the user,
+// or ends with one of them, for example the BackupSchedule ExcludedAPIGroups
+func isExcludedAPIGroup(excludedGroups []string, group string) bool {
 
-				if !findValue(veleroBackup.Spec.ExcludedResources, resourceName) &&
-					!findValue(veleroBackup.Spec.ExcludedResources, resourceKind) {
-					resources = appendUnique(resources, resourceName)
-				}
+	if len(excludedGroups) == 0 {
+		return false
+	}
+	_, found := findSuffix(excludedGroups, strings.ToLower(group))
+	return found
+}
+
+// returns the excluded resources which match no server resource kind, using the kind
+// or the kind.group format; the resources excluded by the operator are not checked,
+// they are not expected to be installed on all hubs
+func getUnmatchedExcludedResources(
+	groupVersions []groupVersionResources,
+	excludedResources []string,
+) []string {
+
+	operatorExcluded := newCaseInsensitiveSet(excludedCRDs, backupCredsResources,
+		backupManagedClusterResources, transientResources)
+	serverResources := caseInsensitiveSet{}
+	for _, groupVersion := range groupVersions {
+		for _, resource := range groupVersion.resourceList.APIResources {
+			resourceKind := strings.ToLower(resource.Kind)
+			serverResources.insert(resourceKind)
+			if groupVersion.group.Name != "" {
+				serverResources.insert(resourceKind + "." + groupVersion.group.Name)
 			}
 		}
 	}
 
-	return resources, nil
+	unmatched := []string{}
+	for _, excluded := range excludedResources {
+		if !operatorExcluded.has(excluded) && !serverResources.has(excluded) {
+			unmatched = append(unmatched, excluded)
+		}
+	}
+	return unmatched
 }
 
+// hub uid set on backups when the hub identification is not available
+const unknownHubID = "unknown"
+
 // return hub uid, used to annotate backup schedules
 // to know what hub is pushing the backups to the storage location
-// info used when switching active - passive clusters
+// info used when switching active - passive clusters.
+// On clusters without the ClusterVersion resource, like kind clusters,
+// the kube-system namespace uid is used; the hub uid is unknown if none is found.
+// An error is returned only if the ClusterVersion resource can't be read
 func getHubIdentification(
 	ctx context.Context,
-	dc discovery.DiscoveryInterface,
 	dyn dynamic.Interface,
 	mapper *restmapper.DeferredDiscoveryRESTMapper,
 ) (string, error) {
 
-	uid := "unknown"
+	uid, _, err := lookupHubIdentification(ctx, dyn, mapper)
+	return uid, err
+}
+
+// returns the hub uid, see getHubIdentification, and true if it is the ClusterVersion
+// clusterID, false if it is the kube-system namespace fallback uid or unknown
+func lookupHubIdentification(
+	ctx context.Context,
+	dyn dynamic.Interface,
+	mapper *restmapper.DeferredDiscoveryRESTMapper,
+) (string, bool, error) {
+
+	uid, err := getClusterVersionID(ctx, dyn, mapper)
+	if err != nil && !meta.IsNoMatchError(err) && !apierrors.IsNotFound(err) {
+		return unknownHubID, false, err
+	}
+	if uid != unknownHubID {
+		return uid, true, nil
+	}
+
+	// no ClusterVersion resource, use the kube-system namespace uid
+	return getKubeSystemNamespaceID(ctx, dyn), false, nil
+}
+
+// returns the kube-system namespace uid, used as the hub uid when the
+// cluster has no ClusterVersion resource
+func getKubeSystemNamespaceID(
+	ctx context.Context,
+	dyn dynamic.Interface,
+) string {
+
+	logger := log.FromContext(ctx)
+
+	namespace, err := dyn.Resource(schema.GroupVersionResource{
+		Version:  "v1",
+		Resource: "namespaces",
+	}).Get(ctx, "kube-system", v1.GetOptions{})
+	if err != nil || namespace.GetUID() == "" {
+		logger.Info("Hub identification not available, the hub uid is unknown")
+		return unknownHubID
+	}
+	return string(namespace.GetUID())
+}
+
+// return the clusterID set on the ClusterVersion resource
+func getClusterVersionID(
+	ctx context.Context,
+	dyn dynamic.Interface,
+	mapper *restmapper.DeferredDiscoveryRESTMapper,
+) (string, error) {
+
+	uid := unknownHubID
 	logger := log.FromContext(ctx)
 	groupKind := schema.GroupKind{
 		Group: "config.openshift.io",
@@ -239,3 +716,94 @@ func getHubIdentification(
 	}
 	return uid, nil
 }
+
+// caches the ClusterVersion hub uid, which doesn't change for the life of the operator;
+// the hub uid is looked up again while it is unknown or set from the kube-system
+// namespace fallback, so a ClusterVersion available later is used on the next reconcile
+type hubIdentification struct {
+	lock sync.Mutex
+	uid  string
+}
+
+// return the cached hub uid, or look it up if it is not known yet
+func (h *hubIdentification) get(
+	ctx context.Context,
+	dyn dynamic.Interface,
+	mapper *restmapper.DeferredDiscoveryRESTMapper,
+) (string, error) {
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if h.uid != "" {
+		return h.uid, nil
+	}
+	uid, fromClusterVersion, err := lookupHubIdentification(ctx, dyn, mapper)
+	if fromClusterVersion {
+		// the kube-system namespace fallback uid is not cached
+		h.uid = uid
+	}
+	return uid, err
+}
+
+// field manager used to apply the velero resources owned by the operator
+const veleroFieldManager = "cluster-backup-operator"
+
+// creates or updates the velero resource with a server-side apply, using the operator
+// field manager; a conflict error is returned if a field set by the operator is owned
+// by another field manager, unless the apply forces the ownership. The apply must set
+// all the fields owned by the operator: the fields previously applied and not set are removed
+func applyVeleroResource(
+	ctx context.Context,
+	c client.Client,
+	obj client.Object,
+	opts ...client.PatchOption,
+) error {
+
+	gvk, err := apiutil.GVKForObject(obj, c.Scheme())
+	if err != nil {
+		return err
+	}
+	obj.GetObjectKind().SetGroupVersionKind(gvk)
+
+	return c.Patch(ctx, obj, client.Apply,
+		append([]client.PatchOption{client.FieldOwner(veleroFieldManager)}, opts...)...)
+}
+
+// creates the velero resource with a server-side apply, see applyVeleroResource, if it
+// doesn't exist; returns false if the resource already exists. The velero backups and
+// restores are not updated once created, velero processes them only once
+func createVeleroResource(
+	ctx context.Context,
+	c client.Client,
+	obj client.Object,
+) (bool, error) {
+
+	existing, ok := obj.DeepCopyObject().(client.Object)
+	if !ok {
+		return false, fmt.Errorf("unable to create %s", obj.GetName())
+	}
+	err := c.Get(ctx, client.ObjectKeyFromObject(obj), existing)
+	if err == nil {
+		return false, nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return false, err
+	}
+	return true, applyVeleroResource(ctx, c, obj)
+}
+
+// returns the velero schedule to apply to update an existing velero schedule: its name,
+// labels, annotations, owner references and spec, with no server set fields, such as
+// the resource version and the managed fields, which can't be applied
+func getVeleroScheduleToApply(veleroSchedule *veleroapi.Schedule) *veleroapi.Schedule {
+
+	toApply := &veleroapi.Schedule{}
+	toApply.Name = veleroSchedule.Name
+	toApply.Namespace = veleroSchedule.Namespace
+	toApply.SetLabels(veleroSchedule.GetLabels())
+	toApply.SetAnnotations(veleroSchedule.GetAnnotations())
+	toApply.SetOwnerReferences(veleroSchedule.GetOwnerReferences())
+	toApply.Spec = *veleroSchedule.Spec.DeepCopy()
+	return toApply
+}
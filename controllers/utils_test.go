@@ -3,21 +3,72 @@
 package controllers
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
 	"testing"
+	"time"
 
 	veleroapi "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/discovery/cached/memory"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	fakeclientset "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/restmapper"
+	k8stesting "k8s.io/client-go/testing"
 )
 
-func Test_isValidStorageLocationDefined(t *testing.T) {
+func Test_getValidStorageLocations(t *testing.T) {
+	newStorageLocation := func(name, namespace string, owned bool) veleroapi.BackupStorageLocation {
+		storageLocation := veleroapi.BackupStorageLocation{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: "velero/v1",
+				Kind:       "BackupStorageLocation",
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: namespace,
+			},
+			Status: veleroapi.BackupStorageLocationStatus{
+				Phase: veleroapi.BackupStorageLocationPhaseAvailable,
+			},
+		}
+		if owned {
+			storageLocation.OwnerReferences = []v1.OwnerReference{
+				{
+					Kind: "DataProtectionApplication",
+				},
+			}
+		}
+		return storageLocation
+	}
+	unavailableStorageLocation := newStorageLocation("unavailable", "default", true)
+	unavailableStorageLocation.Status.Phase = veleroapi.BackupStorageLocationPhaseUnavailable
+	defaultStorageLocation := newStorageLocation("valid-storage", "default", true)
+	defaultStorageLocation.Spec.Default = true
+	readOnlyStorageLocation := newStorageLocation("read-only", "default", true)
+	readOnlyStorageLocation.Spec.AccessMode = veleroapi.BackupStorageLocationAccessModeReadOnly
+
 	type args struct {
 		veleroStorageLocations *veleroapi.BackupStorageLocationList
+		acceptUnowned          bool
 	}
 	tests := []struct {
 		name string
 		args args
-		want bool
+		want []storageLocationRef
 	}{
 		{
 			name: "No storage locations",
@@ -26,67 +77,181 @@ func Test_isValidStorageLocationDefined(t *testing.T) {
 					Items: make([]veleroapi.BackupStorageLocation, 0),
 				},
 			},
-			want: false,
+			want: []storageLocationRef{},
 		},
 		{
 			name: "Storage locations but no owner reference",
 			args: args{
 				veleroStorageLocations: &veleroapi.BackupStorageLocationList{
 					Items: []veleroapi.BackupStorageLocation{
-						veleroapi.BackupStorageLocation{
-							TypeMeta: metav1.TypeMeta{
-								APIVersion: "velero/v1",
-								Kind:       "BackupStorageLocation",
-							},
-							ObjectMeta: metav1.ObjectMeta{
-								Name:      "invalid-location-no-owner-ref",
-								Namespace: "default",
-							},
-							Status: veleroapi.BackupStorageLocationStatus{
-								Phase: veleroapi.BackupStorageLocationPhaseAvailable,
-							},
-						},
+						newStorageLocation("invalid-location-no-owner-ref", "default", false),
+					},
+				},
+			},
+			want: []storageLocationRef{},
+		},
+		{
+			name: "Owned and unowned storage locations",
+			args: args{
+				veleroStorageLocations: &veleroapi.BackupStorageLocationList{
+					Items: []veleroapi.BackupStorageLocation{
+						newStorageLocation("owned", "default", true),
+						newStorageLocation("unowned", "default", false),
+					},
+				},
+			},
+			want: []storageLocationRef{
+				{Name: "owned", Namespace: "default"},
+			},
+		},
+		{
+			name: "Owned and unowned storage locations, unowned storage locations accepted",
+			args: args{
+				veleroStorageLocations: &veleroapi.BackupStorageLocationList{
+					Items: []veleroapi.BackupStorageLocation{
+						newStorageLocation("owned", "default", true),
+						newStorageLocation("unowned", "default", false),
+					},
+				},
+				acceptUnowned: true,
+			},
+			want: []storageLocationRef{
+				{Name: "owned", Namespace: "default"},
+				{Name: "unowned", Namespace: "default"},
+			},
+		},
+		{
+			name: "Unavailable storage location, unowned storage locations accepted",
+			args: args{
+				veleroStorageLocations: &veleroapi.BackupStorageLocationList{
+					Items: []veleroapi.BackupStorageLocation{
+						unavailableStorageLocation,
 					},
 				},
+				acceptUnowned: true,
 			},
-			want: false,
+			want: []storageLocationRef{},
 		},
 		{
 			name: "Storage location valid",
 			args: args{
 				veleroStorageLocations: &veleroapi.BackupStorageLocationList{
 					Items: []veleroapi.BackupStorageLocation{
-						veleroapi.BackupStorageLocation{
-							TypeMeta: metav1.TypeMeta{
-								APIVersion: "velero/v1",
-								Kind:       "BackupStorageLocation",
-							},
-							ObjectMeta: metav1.ObjectMeta{
-								Name:      "valid-storage",
-								Namespace: "default",
-								OwnerReferences: []v1.OwnerReference{
-									v1.OwnerReference{
-										Kind: "DataProtectionApplication",
-									},
-								},
-							},
-							Status: veleroapi.BackupStorageLocationStatus{
-								Phase: veleroapi.BackupStorageLocationPhaseAvailable,
-							},
-						},
+						defaultStorageLocation,
+						unavailableStorageLocation,
 					},
 				},
 			},
-			want: true,
+			want: []storageLocationRef{
+				{Name: "valid-storage", Namespace: "default", Default: true},
+			},
+		},
+		{
+			name: "Storage locations valid with read-only access",
+			args: args{
+				veleroStorageLocations: &veleroapi.BackupStorageLocationList{
+					Items: []veleroapi.BackupStorageLocation{
+						defaultStorageLocation,
+						readOnlyStorageLocation,
+					},
+				},
+			},
+			want: []storageLocationRef{
+				{Name: "valid-storage", Namespace: "default", Default: true},
+				{
+					Name:       "read-only",
+					Namespace:  "default",
+					AccessMode: veleroapi.BackupStorageLocationAccessModeReadOnly,
+				},
+			},
+		},
+		{
+			name: "Storage locations valid in different namespaces",
+			args: args{
+				veleroStorageLocations: &veleroapi.BackupStorageLocationList{
+					Items: []veleroapi.BackupStorageLocation{
+						newStorageLocation("primary", "velero", true),
+						newStorageLocation("dr-region", "velero-dr", true),
+					},
+				},
+			},
+			want: []storageLocationRef{
+				{Name: "primary", Namespace: "velero"},
+				{Name: "dr-region", Namespace: "velero-dr"},
+			},
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got, _ := isValidStorageLocationDefined(*tt.args.veleroStorageLocations); got != tt.want {
-				t.Errorf("isValidStorageLocationDefined() = %v, want %v", got, tt.want)
+			if got := getValidStorageLocations(context.Background(), *tt.args.veleroStorageLocations,
+				tt.args.acceptUnowned); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getValidStorageLocations() = %v, want %v", got, tt.want)
 			}
 		})
 	}
+
+	// the storage locations in the velero namespace
+	storageLocations := getValidStorageLocations(context.Background(), veleroapi.BackupStorageLocationList{
+		Items: []veleroapi.BackupStorageLocation{
+			newStorageLocation("primary", "velero", true),
+			newStorageLocation("dr-region", "velero-dr", true),
+		},
+	}, false)
+	want := []storageLocationRef{{Name: "dr-region", Namespace: "velero-dr"}}
+	if got := getStorageLocationsInNamespace(storageLocations, "velero-dr"); !reflect.DeepEqual(got, want) {
+		t.Errorf("getStorageLocationsInNamespace() = %v, want %v", got, want)
+	}
+
+	// the velero namespace is found using the storage locations, unless it is set
+	if got := getVeleroNamespace("", storageLocations); got != "velero" {
+		t.Errorf("getVeleroNamespace() = %v, want velero", got)
+	}
+	if got := getVeleroNamespace("velero-dr", storageLocations); got != "velero-dr" {
+		t.Errorf("getVeleroNamespace() = %v, want velero-dr", got)
+	}
+	if got := getVeleroNamespace("", nil); got != "" {
+		t.Errorf("getVeleroNamespace() = %v, want empty namespace", got)
+	}
+
+	// only the read-write storage locations are used to create backups
+	storageLocations = getValidStorageLocations(context.Background(), veleroapi.BackupStorageLocationList{
+		Items: []veleroapi.BackupStorageLocation{
+			defaultStorageLocation,
+			readOnlyStorageLocation,
+		},
+	}, false)
+	want = []storageLocationRef{{Name: "valid-storage", Namespace: "default", Default: true}}
+	if got := getWritableStorageLocations(storageLocations); !reflect.DeepEqual(got, want) {
+		t.Errorf("getWritableStorageLocations() = %v, want %v", got, want)
+	}
+}
+
+func Test_isUnownedStorageLocationToLog(t *testing.T) {
+
+	unownedStorageLocationsLoggedLock.Lock()
+	unownedStorageLocationsLogged = map[string]time.Time{}
+	unownedStorageLocationsLoggedLock.Unlock()
+
+	storageLocation := &veleroapi.BackupStorageLocation{
+		ObjectMeta: metav1.ObjectMeta{Name: "unowned", Namespace: "default"},
+	}
+	otherStorageLocation := &veleroapi.BackupStorageLocation{
+		ObjectMeta: metav1.ObjectMeta{Name: "unowned", Namespace: "other"},
+	}
+	now := time.Now()
+
+	if !isUnownedStorageLocationToLog(storageLocation, now) {
+		t.Errorf("isUnownedStorageLocationToLog() = false, want true the first time")
+	}
+	if isUnownedStorageLocationToLog(storageLocation, now.Add(time.Minute)) {
+		t.Errorf("isUnownedStorageLocationToLog() = true, want false within the TTL")
+	}
+	if !isUnownedStorageLocationToLog(otherStorageLocation, now.Add(time.Minute)) {
+		t.Errorf("isUnownedStorageLocationToLog() = false, want true for another storage location")
+	}
+	if !isUnownedStorageLocationToLog(storageLocation, now.Add(unownedStorageLocationLogTTL)) {
+		t.Errorf("isUnownedStorageLocationToLog() = false, want true once the TTL expired")
+	}
 }
 
 func Test_getResourceDetails(t *testing.T) {
@@ -125,3 +290,1004 @@ func Test_getResourceDetails(t *testing.T) {
 		})
 	}
 }
+
+func Test_SortResourceType(t *testing.T) {
+	tests := []struct {
+		name      string
+		resources []ResourceType
+		want      []ResourceType
+	}{
+		{
+			name: "backup types, no group",
+			resources: []ResourceType{
+				Resources, ManagedClusters, ResourcesGeneric, CredentialsHive, Credentials,
+			},
+			want: []ResourceType{
+				Credentials, CredentialsHive, ManagedClusters, Resources, ResourcesGeneric,
+			},
+		},
+		{
+			name: "mixed groups, group first and no group last",
+			resources: []ResourceType{
+				"secret",
+				"policy.policy.open-cluster-management.io",
+				"channel.apps.open-cluster-management.io",
+				"configmap",
+				"placement.cluster.open-cluster-management.io",
+				"managedcluster.cluster.open-cluster-management.io",
+				"subscription.apps.open-cluster-management.io",
+				"clusterdeployment.hive.openshift.io",
+			},
+			want: []ResourceType{
+				"channel.apps.open-cluster-management.io",
+				"subscription.apps.open-cluster-management.io",
+				"managedcluster.cluster.open-cluster-management.io",
+				"placement.cluster.open-cluster-management.io",
+				"clusterdeployment.hive.openshift.io",
+				"policy.policy.open-cluster-management.io",
+				"configmap",
+				"secret",
+			},
+		},
+		{
+			name: "same kind in different groups",
+			resources: []ResourceType{
+				"managedcluster.clusterview.open-cluster-management.io",
+				"managedcluster",
+				"managedcluster.cluster.open-cluster-management.io",
+			},
+			want: []ResourceType{
+				"managedcluster.cluster.open-cluster-management.io",
+				"managedcluster.clusterview.open-cluster-management.io",
+				"managedcluster",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the order doesn't depend on the initial order
+			for i := 0; i < 2; i++ {
+				got := append([]ResourceType{}, tt.resources...)
+				if i == 1 {
+					for l, r := 0, len(got)-1; l < r; l, r = l+1, r-1 {
+						got[l], got[r] = got[r], got[l]
+					}
+				}
+				sort.Sort(SortResourceType(got))
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("sort.Sort(SortResourceType) = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func Test_sanitizeName(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "valid name is unchanged",
+			input: "restore-acm.v1",
+			want:  "restore-acm.v1",
+		},
+		{
+			name:  "upper case is lowered",
+			input: "Restore-ACM",
+			want:  "restore-acm",
+		},
+		{
+			name:  "underscores and spaces are replaced",
+			input: "restore_acm schedule",
+			want:  "restore-acm-schedule",
+		},
+		{
+			name:  "leading digits are kept",
+			input: "2022-restore",
+			want:  "2022-restore",
+		},
+		{
+			name:  "leading and trailing separators are removed",
+			input: "_-restore-acm.-",
+			want:  "restore-acm",
+		},
+		{
+			name:  "empty labels are removed",
+			input: "restore..acm",
+			want:  "restore.acm",
+		},
+		{
+			name:  "labels start and end with an alphanumeric character",
+			input: "restore-.acm_.-schedule",
+			want:  "restore.acm.schedule",
+		},
+		{
+			name:  "no valid character",
+			input: "_.-",
+			want:  "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := sanitizeName(tt.input)
+			if got != tt.want {
+				t.Errorf("sanitizeName() = %v, want %v", got, tt.want)
+			}
+			if msgs := validation.IsDNS1123Subdomain(got); got != "" && len(msgs) > 0 {
+				t.Errorf("sanitizeName() = %v, not a valid name: %v", got, msgs)
+			}
+		})
+	}
+}
+
+func Test_getValidKsRestoreName(t *testing.T) {
+	longRestoreName := strings.Repeat("r", 240)
+	// the hash is computed on the name before it is sanitized
+	hash := sha256.Sum256([]byte(strings.Repeat("R", 242) + "-acm-resources-schedule-20220406123522"))
+	upperCaseNameHash := hex.EncodeToString(hash[:])
+	tests := []struct {
+		name        string
+		restoreName string
+		backupName  string
+		want        string
+	}{
+		{
+			name:        "short name is unchanged",
+			restoreName: "restore-acm",
+			backupName:  "acm-resources-schedule-20220406123522",
+			want:        "restore-acm-acm-resources-schedule-20220406123522",
+		},
+		{
+			name:        "name of max length is unchanged",
+			restoreName: strings.Repeat("r", 200),
+			backupName:  strings.Repeat("b", 51),
+			want:        strings.Repeat("r", 200) + "-" + strings.Repeat("b", 51),
+		},
+		{
+			name:        "long name is trimmed and ends with a hash",
+			restoreName: longRestoreName,
+			backupName:  "acm-resources-schedule-20220406123522",
+			want:        longRestoreName + "-ac-ac9e2896",
+		},
+		{
+			name:        "trailing separator is removed before the hash",
+			restoreName: strings.Repeat("r", 242),
+			backupName:  "acm-resources-schedule-20220406123522",
+			want:        strings.Repeat("r", 242) + "-fcc0e171",
+		},
+		{
+			name:        "sanitized name ends with a hash",
+			restoreName: "Restore_ACM",
+			backupName:  "acm-resources-schedule-20220406123522",
+			want:        "restore-acm-acm-resources-schedule-20220406123522-e4e4fa8d",
+		},
+		{
+			name:        "separators are sanitized",
+			restoreName: "restore-.acm",
+			backupName:  "acm-resources-schedule-20220406123522",
+			want:        "restore.acm-acm-resources-schedule-20220406123522-7527969d",
+		},
+		{
+			name:        "no valid character",
+			restoreName: "_",
+			backupName:  "_",
+			want:        "77767769",
+		},
+		{
+			name:        "leading digits are kept",
+			restoreName: "1restore",
+			backupName:  "acm-resources-schedule-20220406123522",
+			want:        "1restore-acm-resources-schedule-20220406123522",
+		},
+		{
+			name:        "long name with invalid characters is sanitized before the hash",
+			restoreName: strings.Repeat("R", 242),
+			backupName:  "acm-resources-schedule-20220406123522",
+			want:        strings.Repeat("r", 242) + "-" + upperCaseNameHash[:restoreNameHashLength],
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := getValidKsRestoreName(tt.restoreName, tt.backupName)
+			if msgs := validation.IsDNS1123Subdomain(got); len(msgs) > 0 {
+				t.Errorf("getValidKsRestoreName() = %v, not a valid name: %v", got, msgs)
+			}
+			if len(got) > 252 {
+				t.Errorf("getValidKsRestoreName() length = %d, want <= 252", len(got))
+			}
+			if got != tt.want {
+				t.Errorf("getValidKsRestoreName() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// names sharing the trimmed prefix must not collide
+	name1 := getValidKsRestoreName(longRestoreName, "acm-resources-schedule-20220406123522")
+	name2 := getValidKsRestoreName(longRestoreName, "acm-resources-schedule-20220406133522")
+	if name1 == name2 {
+		t.Errorf("getValidKsRestoreName() = %v for distinct names", name1)
+	}
+
+	// names differing only by invalid characters must not collide
+	name1 = getValidKsRestoreName("restore_acm", "acm-resources-schedule-20220406123522")
+	name2 = getValidKsRestoreName("restore-acm", "acm-resources-schedule-20220406123522")
+	if name1 == name2 {
+		t.Errorf("getValidKsRestoreName() = %v for distinct names", name1)
+	}
+}
+
+func Test_getBackupTimestamp(t *testing.T) {
+	tests := []struct {
+		name       string
+		backupName string
+		want       time.Time
+		wantErr    bool
+	}{
+		{
+			name:       "backup name with timestamp",
+			backupName: "acm-resources-schedule-20240102150405",
+			want:       time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC),
+		},
+		{
+			name:       "backup name with numeric schedule name",
+			backupName: "backup-20240102150405",
+			want:       time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC),
+		},
+		{
+			name:       "schedule name ending with a numeric segment",
+			backupName: "acm-2024-20240102150405",
+			want:       time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC),
+		},
+		{
+			name:       "name with a numeric segment and no timestamp",
+			backupName: "acm-2024",
+			wantErr:    true,
+		},
+		{
+			name:       "suffix after the timestamp",
+			backupName: "acm-resources-schedule-20240102150405-1",
+			wantErr:    true,
+		},
+		{
+			name:       "timestamp with too many digits",
+			backupName: "acm-resources-schedule-202401021504051",
+			wantErr:    true,
+		},
+		{
+			name:       "timestamp with too few digits",
+			backupName: "acm-resources-schedule-2024010215040",
+			wantErr:    true,
+		},
+		{
+			name:       "invalid timestamp",
+			backupName: "acm-resources-schedule-20241302150405",
+			wantErr:    true,
+		},
+		{
+			name:       "no separator",
+			backupName: "20240102150405",
+			wantErr:    true,
+		},
+		{
+			name:       "empty name",
+			backupName: "",
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := getBackupTimestamp(tt.backupName)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("getBackupTimestamp() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("getBackupTimestamp() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_getStorageLocationEndpoint(t *testing.T) {
+
+	newStorageLocation := func(provider string, config map[string]string) *veleroapi.BackupStorageLocation {
+		return &veleroapi.BackupStorageLocation{
+			Spec: veleroapi.BackupStorageLocationSpec{
+				Provider: provider,
+				Config:   config,
+			},
+		}
+	}
+
+	tests := []struct {
+		name            string
+		storageLocation *veleroapi.BackupStorageLocation
+		want            string
+	}{
+		{
+			name: "s3 url",
+			storageLocation: newStorageLocation("aws", map[string]string{
+				"s3Url":  "https://minio.example.com:9000",
+				"region": "us-east-1",
+			}),
+			want: "https://minio.example.com:9000",
+		},
+		{
+			name:            "aws region",
+			storageLocation: newStorageLocation("velero.io/aws", map[string]string{"region": "us-east-1"}),
+			want:            "https://s3.us-east-1.amazonaws.com",
+		},
+		{
+			name:            "azure storage account",
+			storageLocation: newStorageLocation("azure", map[string]string{"storageAccount": "acmbackup"}),
+			want:            "https://acmbackup.blob.core.windows.net",
+		},
+		{
+			name:            "gcp",
+			storageLocation: newStorageLocation("gcp", nil),
+			want:            "https://storage.googleapis.com",
+		},
+		{
+			name:            "unknown provider",
+			storageLocation: newStorageLocation("other", nil),
+			want:            "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := getStorageLocationEndpoint(tt.storageLocation); got != tt.want {
+				t.Errorf("getStorageLocationEndpoint() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_probeStorageLocations(t *testing.T) {
+
+	reachable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the object store responds even if the request is not authorized
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer reachable.Close()
+
+	hung := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(time.Second)
+	}))
+	defer hung.Close()
+
+	secure := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer secure.Close()
+	secureCACert := pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: secure.Certificate().Raw,
+	})
+
+	newStorageLocation := func(name string, url string,
+		phase veleroapi.BackupStorageLocationPhase) veleroapi.BackupStorageLocation {
+		return veleroapi.BackupStorageLocation{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      name,
+				Namespace: "velero-ns",
+			},
+			Spec: veleroapi.BackupStorageLocationSpec{
+				Provider: "aws",
+				Config:   map[string]string{"s3Url": url},
+			},
+			Status: veleroapi.BackupStorageLocationStatus{
+				Phase: phase,
+			},
+		}
+	}
+	withCACert := func(storageLocation veleroapi.BackupStorageLocation,
+		caCert []byte) veleroapi.BackupStorageLocation {
+		storageLocation.Spec.ObjectStorage = &veleroapi.ObjectStorageLocation{
+			Bucket: "bucket",
+			CACert: caCert,
+		}
+		return storageLocation
+	}
+
+	tests := []struct {
+		name             string
+		storageLocations []veleroapi.BackupStorageLocation
+		wantMsgPrefix    string
+		wantErr          bool
+	}{
+		{
+			name: "storage location reachable",
+			storageLocations: []veleroapi.BackupStorageLocation{
+				newStorageLocation("default", reachable.URL, veleroapi.BackupStorageLocationPhaseAvailable),
+			},
+			wantMsgPrefix: "default responded in ",
+		},
+		{
+			name: "storage location not responding",
+			storageLocations: []veleroapi.BackupStorageLocation{
+				newStorageLocation("default", hung.URL, veleroapi.BackupStorageLocationPhaseAvailable),
+			},
+			wantErr: true,
+		},
+		{
+			name: "storage location with the CA bundle",
+			storageLocations: []veleroapi.BackupStorageLocation{
+				withCACert(newStorageLocation("default", secure.URL,
+					veleroapi.BackupStorageLocationPhaseAvailable), secureCACert),
+			},
+			wantMsgPrefix: "default responded in ",
+		},
+		{
+			name: "storage location certificate not trusted",
+			storageLocations: []veleroapi.BackupStorageLocation{
+				newStorageLocation("default", secure.URL, veleroapi.BackupStorageLocationPhaseAvailable),
+			},
+			wantErr: true,
+		},
+		{
+			name: "storage location with an invalid CA bundle",
+			storageLocations: []veleroapi.BackupStorageLocation{
+				withCACert(newStorageLocation("default", secure.URL,
+					veleroapi.BackupStorageLocationPhaseAvailable), []byte("not a certificate")),
+			},
+			wantErr: true,
+		},
+		{
+			name: "unavailable storage location not probed",
+			storageLocations: []veleroapi.BackupStorageLocation{
+				newStorageLocation("default", hung.URL, veleroapi.BackupStorageLocationPhaseUnavailable),
+			},
+			wantMsgPrefix: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := probeStorageLocations(context.Background(), tt.storageLocations,
+				"velero-ns", time.Millisecond*200)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("probeStorageLocations() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !strings.HasPrefix(got, tt.wantMsgPrefix) {
+				t.Errorf("probeStorageLocations() = %v, want prefix %v", got, tt.wantMsgPrefix)
+			}
+		})
+	}
+}
+
+func Test_findValueCaseInsensitive(t *testing.T) {
+	tests := []struct {
+		name  string
+		slice []string
+		value string
+		want  bool
+	}{
+		{
+			name:  "empty slice",
+			slice: []string{},
+			value: "placement.cluster.open-cluster-management.io",
+			want:  false,
+		},
+		{
+			name:  "same case",
+			slice: []string{"configmap", "placement.cluster.open-cluster-management.io"},
+			value: "placement.cluster.open-cluster-management.io",
+			want:  true,
+		},
+		{
+			name:  "different case",
+			slice: []string{"configmap", "Placement.Cluster.open-cluster-management.io"},
+			value: "placement.cluster.open-cluster-management.io",
+			want:  true,
+		},
+		{
+			name:  "not found",
+			slice: []string{"configmap", "placementdecision.cluster.open-cluster-management.io"},
+			value: "Placement.cluster.open-cluster-management.io",
+			want:  false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := findValueCaseInsensitive(tt.slice, tt.value); got != tt.want {
+				t.Errorf("findValueCaseInsensitive() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_caseInsensitiveSet(t *testing.T) {
+
+	set := newCaseInsensitiveSet([]string{"ConfigMap", "secret"}, []string{"configmap"})
+	if len(set) != 2 {
+		t.Errorf("newCaseInsensitiveSet() has %v values, want 2", len(set))
+	}
+	if !set.has("configMap") || !set.has("SECRET") || set.has("pod") {
+		t.Errorf("caseInsensitiveSet.has() = %v", set)
+	}
+	if set.insert("Secret") {
+		t.Errorf("caseInsensitiveSet.insert() added a value already in the set")
+	}
+	if !set.insert("pod") || !set.has("Pod") {
+		t.Errorf("caseInsensitiveSet.insert() didn't add a new value")
+	}
+}
+
+func Test_getGenericCRDFromAPIGroups_mixedCase(t *testing.T) {
+
+	invalidateDiscoveryCache()
+	dc := newCountingDiscovery(t, 0)
+	dc.Resources = []*metav1.APIResourceList{
+		{
+			GroupVersion: "apps.open-cluster-management.io/v1",
+			APIResources: []metav1.APIResource{
+				{Name: "channels", Kind: "Channel", Namespaced: true},
+				{Name: "subscriptions", Kind: "Subscription", Namespaced: true},
+				{Name: "placementrules", Kind: "PlacementRule", Namespaced: true},
+			},
+		},
+		{
+			GroupVersion: "apps.open-cluster-management.io/v1beta1",
+			APIResources: []metav1.APIResource{
+				{Name: "channels", Kind: "channel", Namespaced: true},
+				{Name: "subscriptions", Kind: "SUBSCRIPTION", Namespaced: true},
+			},
+		},
+	}
+	veleroBackup := &veleroapi.Backup{
+		Spec: veleroapi.BackupSpec{
+			ExcludedResources: []string{"placementRule.apps.open-cluster-management.io"},
+		},
+	}
+
+	got, err := getGenericCRDFromAPIGroups(context.Background(), dc, veleroBackup, nil)
+	if err != nil {
+		t.Fatalf("getGenericCRDFromAPIGroups() error = %v", err)
+	}
+	want := []string{
+		"channel.apps.open-cluster-management.io",
+		"subscription.apps.open-cluster-management.io",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("getGenericCRDFromAPIGroups() = %v, want %v", got, want)
+	}
+	invalidateDiscoveryCache()
+}
+
+func Test_getGenericResources(t *testing.T) {
+
+	newGroupVersion := func(group, version string, resources ...metav1.APIResource) groupVersionResources {
+		return groupVersionResources{
+			group: metav1.APIGroup{Name: group},
+			resourceList: &metav1.APIResourceList{
+				GroupVersion: group + "/" + version,
+				APIResources: resources,
+			},
+		}
+	}
+	groupVersions := []groupVersionResources{
+		newGroupVersion("apps.open-cluster-management.io", "v1",
+			metav1.APIResource{Name: "channels", Kind: "Channel"},
+			metav1.APIResource{Name: "channels/status", Kind: "Channel"},
+			metav1.APIResource{Name: "subscriptions", Kind: "Subscription"},
+			metav1.APIResource{Name: "subscriptions/scale", Kind: "Scale"},
+		),
+		newGroupVersion("apps.open-cluster-management.io", "v1beta1",
+			metav1.APIResource{Name: "channels", Kind: "channel"},
+			metav1.APIResource{Name: "subscriptions", Kind: "SUBSCRIPTION"},
+		),
+		newGroupVersion("Apps.Open-Cluster-Management.io", "v1alpha1",
+			metav1.APIResource{Name: "channels", Kind: "Channel"},
+			metav1.APIResource{Name: "gitopsclusters", Kind: "GitOpsCluster"},
+		),
+		newGroupVersion("", "v1",
+			metav1.APIResource{Name: "configmaps", Kind: "ConfigMap"},
+		),
+	}
+
+	tests := []struct {
+		name              string
+		excludedResources []string
+		want              []string
+	}{
+		{
+			name: "one canonical entry per kind",
+			want: []string{
+				"channel.apps.open-cluster-management.io",
+				"subscription.apps.open-cluster-management.io",
+				"gitopscluster.apps.open-cluster-management.io",
+			},
+		},
+		{
+			name: "excluded resources canonicalized",
+			excludedResources: []string{
+				" Subscription.Apps.Open-Cluster-Management.io. ",
+				"GITOPSCLUSTER",
+			},
+			want: []string{"channel.apps.open-cluster-management.io"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := getGenericResources(groupVersions, nil, tt.excludedResources); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getGenericResources() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_getCanonicalResourceName(t *testing.T) {
+
+	tests := []struct {
+		resourceName string
+		want         string
+	}{
+		{resourceName: "Channel.Apps.Open-Cluster-Management.io", want: "channel.apps.open-cluster-management.io"},
+		{resourceName: " channel.apps.open-cluster-management.io. ", want: "channel.apps.open-cluster-management.io"},
+		{resourceName: "ConfigMap", want: "configmap"},
+		{resourceName: "ConfigMap.", want: "configmap"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.resourceName, func(t *testing.T) {
+			if got := getCanonicalResourceName(tt.resourceName); got != tt.want {
+				t.Errorf("getCanonicalResourceName() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_getGenericCRDFromAPIGroups_excludedAPIGroups(t *testing.T) {
+
+	invalidateDiscoveryCache()
+	dc := newCountingDiscovery(t, 0)
+	dc.Resources = []*metav1.APIResourceList{
+		{
+			GroupVersion: "monitoring.coreos.com/v1",
+			APIResources: []metav1.APIResource{
+				{Name: "prometheuses", Kind: "Prometheus", Namespaced: true},
+				{Name: "servicemonitors", Kind: "ServiceMonitor", Namespaced: true},
+			},
+		},
+		{
+			GroupVersion: "alerts.monitoring.coreos.com/v1",
+			APIResources: []metav1.APIResource{
+				{Name: "alertrules", Kind: "AlertRule", Namespaced: true},
+			},
+		},
+		{
+			GroupVersion: "apps.open-cluster-management.io/v1",
+			APIResources: []metav1.APIResource{
+				{Name: "channels", Kind: "Channel", Namespaced: true},
+				{Name: "channels/status", Kind: "Channel", Namespaced: true},
+			},
+		},
+	}
+	veleroBackup := &veleroapi.Backup{}
+
+	tests := []struct {
+		name           string
+		excludedGroups []string
+		want           []string
+		wantExcluded   []string
+	}{
+		{
+			name:           "no excluded groups",
+			excludedGroups: nil,
+			want: []string{
+				"prometheus.monitoring.coreos.com",
+				"servicemonitor.monitoring.coreos.com",
+				"alertrule.alerts.monitoring.coreos.com",
+				"channel.apps.open-cluster-management.io",
+			},
+			wantExcluded: nil,
+		},
+		{
+			name:           "group and its suffix matches excluded",
+			excludedGroups: []string{"monitoring.coreos.com"},
+			want:           []string{"channel.apps.open-cluster-management.io"},
+			wantExcluded: []string{
+				"alertrule.alerts.monitoring.coreos.com",
+				"prometheus.monitoring.coreos.com",
+				"servicemonitor.monitoring.coreos.com",
+			},
+		},
+		{
+			name:           "only the suffix group excluded",
+			excludedGroups: []string{"alerts.monitoring.coreos.com"},
+			want: []string{
+				"prometheus.monitoring.coreos.com",
+				"servicemonitor.monitoring.coreos.com",
+				"channel.apps.open-cluster-management.io",
+			},
+			wantExcluded: []string{"alertrule.alerts.monitoring.coreos.com"},
+		},
+		{
+			name:           "group not served",
+			excludedGroups: []string{"example.com"},
+			want: []string{
+				"prometheus.monitoring.coreos.com",
+				"servicemonitor.monitoring.coreos.com",
+				"alertrule.alerts.monitoring.coreos.com",
+				"channel.apps.open-cluster-management.io",
+			},
+			wantExcluded: []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := getGenericCRDFromAPIGroups(context.Background(), dc, veleroBackup, tt.excludedGroups)
+			if err != nil {
+				t.Fatalf("getGenericCRDFromAPIGroups() error = %v", err)
+			}
+			// the resources are returned in the fake discovery order, which is not stable
+			sort.Strings(got)
+			sort.Strings(tt.want)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getGenericCRDFromAPIGroups() = %v, want %v", got, tt.want)
+			}
+			gotExcluded := getExcludedAPIGroupsResources(context.Background(), dc, tt.excludedGroups)
+			if !reflect.DeepEqual(gotExcluded, tt.wantExcluded) {
+				t.Errorf("getExcludedAPIGroupsResources() = %v, want %v", gotExcluded, tt.wantExcluded)
+			}
+		})
+	}
+	invalidateDiscoveryCache()
+}
+
+func Test_getUnmatchedExcludedResources(t *testing.T) {
+
+	groupVersions := []groupVersionResources{
+		{
+			group: metav1.APIGroup{Name: ""},
+			resourceList: &metav1.APIResourceList{
+				GroupVersion: "v1",
+				APIResources: []metav1.APIResource{
+					{Name: "configmaps", Kind: "ConfigMap", Namespaced: true},
+				},
+			},
+		},
+		{
+			group: metav1.APIGroup{Name: "apps.open-cluster-management.io"},
+			resourceList: &metav1.APIResourceList{
+				GroupVersion: "apps.open-cluster-management.io/v1",
+				APIResources: []metav1.APIResource{
+					{Name: "channels", Kind: "Channel", Namespaced: true},
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		name              string
+		excludedResources []string
+		want              []string
+	}{
+		{
+			name:              "no excluded resources",
+			excludedResources: nil,
+			want:              []string{},
+		},
+		{
+			name: "excluded resources matching server kinds",
+			excludedResources: []string{
+				"configmap",
+				"Channel.apps.open-cluster-management.io",
+				"channel",
+			},
+			want: []string{},
+		},
+		{
+			name: "excluded resource with a typo",
+			excludedResources: []string{
+				"channel.apps.open-cluster-management.io",
+				"foo.bar.io",
+				"channels.apps.open-cluster-management.io",
+			},
+			want: []string{"foo.bar.io", "channels.apps.open-cluster-management.io"},
+		},
+		{
+			name:              "resources excluded by the operator are not reported",
+			excludedResources: append([]string{"secret"}, transientResources...),
+			want:              []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := getUnmatchedExcludedResources(groupVersions, tt.excludedResources); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getUnmatchedExcludedResources() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_getVeleroScheduleToApply(t *testing.T) {
+
+	isController := true
+	veleroSchedule := &veleroapi.Schedule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "acm-credentials-schedule",
+			Namespace:       "velero-ns",
+			Labels:          map[string]string{BackupScheduleTypeLabel: string(Credentials)},
+			Annotations:     map[string]string{VeleroScheduleSpecHashAnnotation: "hash"},
+			ResourceVersion: "10",
+			UID:             "schedule-uid",
+			ManagedFields: []metav1.ManagedFieldsEntry{
+				{Manager: "velero-server", Operation: metav1.ManagedFieldsOperationUpdate},
+			},
+			OwnerReferences: []metav1.OwnerReference{
+				{APIVersion: apiGVStr, Kind: "BackupSchedule", Name: "schedule-acm", Controller: &isController},
+			},
+		},
+		Spec: veleroapi.ScheduleSpec{
+			Schedule: "0 */6 * * *",
+		},
+		Status: veleroapi.ScheduleStatus{
+			Phase: veleroapi.SchedulePhaseEnabled,
+		},
+	}
+
+	want := &veleroapi.Schedule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            veleroSchedule.Name,
+			Namespace:       veleroSchedule.Namespace,
+			Labels:          veleroSchedule.Labels,
+			Annotations:     veleroSchedule.Annotations,
+			OwnerReferences: veleroSchedule.OwnerReferences,
+		},
+		Spec: veleroSchedule.Spec,
+	}
+	if got := getVeleroScheduleToApply(veleroSchedule); !reflect.DeepEqual(got, want) {
+		t.Errorf("getVeleroScheduleToApply() = %v, want %v", got, want)
+	}
+}
+
+func Test_hubIdentification_get(t *testing.T) {
+
+	fakeDiscovery, ok := fakeclientset.NewSimpleClientset().Discovery().(*fakediscovery.FakeDiscovery)
+	if !ok {
+		t.Fatalf("couldn't convert Discovery() to *FakeDiscovery")
+	}
+	fakeDiscovery.Resources = []*metav1.APIResourceList{
+		{
+			GroupVersion: "config.openshift.io/v1",
+			APIResources: []metav1.APIResource{
+				{Name: "clusterversions", Namespaced: false, Kind: "ClusterVersion"},
+			},
+		},
+	}
+	mapper := restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(fakeDiscovery))
+	clusterVersionsGVR := schema.GroupVersionResource{
+		Group:    "config.openshift.io",
+		Version:  "v1",
+		Resource: "clusterversions",
+	}
+	clusterVersion := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "config.openshift.io/v1",
+		"kind":       "ClusterVersion",
+		"metadata": map[string]interface{}{
+			"name": "version",
+		},
+		"spec": map[string]interface{}{
+			"clusterID": "hub-cluster-id",
+		},
+	}}
+	kubeSystem := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Namespace",
+		"metadata": map[string]interface{}{
+			"name": "kube-system",
+			"uid":  "kube-system-uid",
+		},
+	}}
+
+	tests := []struct {
+		name      string
+		objects   []runtime.Object
+		wantUID   string
+		wantLists int
+	}{
+		{
+			name:      "hub uid found, looked up once",
+			objects:   []runtime.Object{clusterVersion},
+			wantUID:   "hub-cluster-id",
+			wantLists: 1,
+		},
+		{
+			name:      "hub uid unknown, looked up on each call",
+			wantUID:   unknownHubID,
+			wantLists: 3,
+		},
+		{
+			name:      "kube-system namespace uid fallback, looked up on each call",
+			objects:   []runtime.Object{kubeSystem},
+			wantUID:   "kube-system-uid",
+			wantLists: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dyn := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+				map[schema.GroupVersionResource]string{
+					clusterVersionsGVR: "ClusterVersionList",
+				},
+				tt.objects...,
+			)
+			lists := 0
+			dyn.PrependReactor("list", "clusterversions",
+				func(action k8stesting.Action) (bool, runtime.Object, error) {
+					lists++
+					return false, nil, nil
+				})
+
+			hubID := &hubIdentification{}
+			for i := 0; i < 3; i++ {
+				uid, err := hubID.get(context.Background(), dyn, mapper)
+				if err != nil {
+					t.Fatalf("get() error = %v", err)
+				}
+				if uid != tt.wantUID {
+					t.Errorf("get() = %s, want %s", uid, tt.wantUID)
+				}
+			}
+			if lists != tt.wantLists {
+				t.Errorf("ClusterVersion listed %d times, want %d", lists, tt.wantLists)
+			}
+		})
+	}
+}
+
+func Test_getHubIdentification_noClusterVersion(t *testing.T) {
+
+	// no ClusterVersion resource on this cluster, so no RESTMapping for it
+	fakeDiscovery, ok := fakeclientset.NewSimpleClientset().Discovery().(*fakediscovery.FakeDiscovery)
+	if !ok {
+		t.Fatalf("couldn't convert Discovery() to *FakeDiscovery")
+	}
+	fakeDiscovery.Resources = []*metav1.APIResourceList{
+		{
+			GroupVersion: "v1",
+			APIResources: []metav1.APIResource{
+				{Name: "namespaces", Namespaced: false, Kind: "Namespace"},
+			},
+		},
+	}
+	mapper := restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(fakeDiscovery))
+	kubeSystem := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Namespace",
+		"metadata": map[string]interface{}{
+			"name": "kube-system",
+			"uid":  "kube-system-uid",
+		},
+	}}
+
+	tests := []struct {
+		name    string
+		objects []runtime.Object
+		wantUID string
+	}{
+		{
+			name:    "use the kube-system namespace uid",
+			objects: []runtime.Object{kubeSystem},
+			wantUID: "kube-system-uid",
+		},
+		{
+			name:    "no kube-system namespace, the hub uid is unknown",
+			wantUID: unknownHubID,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dyn := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), tt.objects...)
+			uid, err := getHubIdentification(context.Background(), dyn, mapper)
+			if err != nil {
+				t.Errorf("getHubIdentification() error = %v, want no error", err)
+			}
+			if uid != tt.wantUID {
+				t.Errorf("getHubIdentification() = %s, want %s", uid, tt.wantUID)
+			}
+		})
+	}
+}
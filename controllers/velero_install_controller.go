@@ -0,0 +1,133 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/discovery"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	v1beta1 "github.com/stolostron/cluster-backup-operator/api/v1beta1"
+)
+
+// ErrVeleroInstalled is returned by the manager running the VeleroInstallReconciler once
+// velero is installed; the operator must be restarted to start the controllers
+var ErrVeleroInstalled = errors.New("velero is installed, restart to start the controllers")
+
+// how often the velero install is checked when the operator is started without velero
+const veleroInstallCheckInterval = time.Second * 30
+
+// VeleroInstallReconciler reports the VeleroNotInstalled condition on the BackupSchedule and
+// Restore resources when the operator is started without velero. The BackupSchedule and Restore
+// controllers watch and index the velero resources, so they can't start without the velero CRDs;
+// this reconciler doesn't use the velero resources
+type VeleroInstallReconciler struct {
+	client.Client
+	DiscoveryClient discovery.DiscoveryInterface
+}
+
+// IsVeleroInstalled returns true if the velero resources used by the controllers are served
+func IsVeleroInstalled(dc discovery.DiscoveryInterface) bool {
+	return isVeleroServed(dc)
+}
+
+// sets the VeleroNotInstalled condition on the backup schedule
+func (r *VeleroInstallReconciler) reconcileBackupSchedule(
+	ctx context.Context,
+	req ctrl.Request,
+) (ctrl.Result, error) {
+
+	backupSchedule := &v1beta1.BackupSchedule{}
+	if err := r.Get(ctx, req.NamespacedName, backupSchedule); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	veleroNotInstalledMsg := getVeleroNotInstalledMessage(ctx, r.DiscoveryClient)
+	if veleroNotInstalledMsg == "" {
+		// the controllers are started once the operator is restarted
+		return ctrl.Result{}, nil
+	}
+	log.FromContext(ctx).Info(veleroNotInstalledMsg)
+
+	setVeleroNotInstalledCondition(backupSchedule, veleroNotInstalledMsg)
+	backupSchedule.Status.Phase = v1beta1.SchedulePhaseFailedValidation
+	backupSchedule.Status.LastMessage = veleroNotInstalledMsg
+	setBackupScheduleConditions(backupSchedule)
+	return ctrl.Result{}, r.Status().Update(ctx, backupSchedule)
+}
+
+// sets the VeleroNotInstalled condition on the restore, if it is not finished
+func (r *VeleroInstallReconciler) reconcileRestore(
+	ctx context.Context,
+	req ctrl.Request,
+) (ctrl.Result, error) {
+
+	restore := &v1beta1.Restore{}
+	if err := r.Get(ctx, req.NamespacedName, restore); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if restore.Status.Phase == v1beta1.RestorePhaseFinished ||
+		restore.Status.Phase == v1beta1.RestorePhaseFinishedWithErrors {
+		return ctrl.Result{}, nil
+	}
+	veleroNotInstalledMsg := getVeleroNotInstalledMessage(ctx, r.DiscoveryClient)
+	if veleroNotInstalledMsg == "" {
+		// the controllers are started once the operator is restarted
+		return ctrl.Result{}, nil
+	}
+
+	setRestoreVeleroNotInstalledCondition(restore, veleroNotInstalledMsg)
+	updateRestoreStatus(log.FromContext(ctx), v1beta1.RestorePhaseError, veleroNotInstalledMsg, restore)
+	setRestoreConditions(restore)
+	return ctrl.Result{}, r.Status().Update(ctx, restore)
+}
+
+// SetupWithManager sets up the controllers reporting the missing velero install, and stops
+// the manager with ErrVeleroInstalled once velero is installed
+func (r *VeleroInstallReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if err := ctrl.NewControllerManagedBy(mgr).
+		Named("veleroinstall-backupschedule").
+		For(&v1beta1.BackupSchedule{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Complete(reconcile.Func(r.reconcileBackupSchedule)); err != nil {
+		return err
+	}
+	if err := ctrl.NewControllerManagedBy(mgr).
+		Named("veleroinstall-restore").
+		For(&v1beta1.Restore{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Complete(reconcile.Func(r.reconcileRestore)); err != nil {
+		return err
+	}
+	return mgr.Add(manager.RunnableFunc(func(ctx context.Context) error {
+		if err := wait.PollImmediateUntil(veleroInstallCheckInterval, func() (bool, error) {
+			return isVeleroServed(r.DiscoveryClient), nil
+		}, ctx.Done()); err != nil {
+			// the manager is stopped
+			return nil
+		}
+		return ErrVeleroInstalled
+	}))
+}
@@ -17,8 +17,13 @@ limitations under the License.
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
+	"fmt"
 	"os"
+	"path/filepath"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
@@ -27,6 +32,7 @@ import (
 	"k8s.io/client-go/kubernetes"
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 	"k8s.io/client-go/restmapper"
+	"k8s.io/client-go/tools/leaderelection"
 
 	ocinfrav1 "github.com/openshift/api/config/v1"
 	hivev1 "github.com/openshift/hive/apis/hive/v1"
@@ -36,6 +42,7 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/yaml"
 	"k8s.io/client-go/discovery"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	clusterv1 "open-cluster-management.io/api/cluster/v1"
@@ -43,6 +50,7 @@ import (
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
 
 	//operatorapiv1 "open-cluster-management.io/api/operator/v1"
 
@@ -75,6 +83,19 @@ func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var otlpEndpoint string
+	var otlpInsecure bool
+	var adoptVeleroSchedules bool
+	var updateBackupsHubID bool
+	var updateBackupsHubIDDryRun bool
+	var storageLocationProbeTimeout time.Duration
+	var validateSchedule string
+	var enableWebhooks bool
+	var verifyStorageEncryption bool
+	var acceptUnownedStorageLocations bool
+	var backupReconcileInterval time.Duration
+	var restoreReconcileInterval time.Duration
+	var leaderElection leaderElectionArgs
 	flag.StringVar(
 		&metricsAddr,
 		"metrics-bind-address",
@@ -90,6 +111,40 @@ func main() {
 	flag.BoolVar(&enableLeaderElection, "leader-elect", true,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.StringVar(&otlpEndpoint, "otlp-endpoint", "",
+		"The OTLP gRPC endpoint, host:port, receiving the backup and restore traces. "+
+			"Tracing is disabled if not set.")
+	leaderElection.bindFlags(flag.CommandLine)
+	flag.BoolVar(&otlpInsecure, "otlp-insecure", false,
+		"Disable TLS for the connection to the OTLP endpoint.")
+	flag.BoolVar(&adoptVeleroSchedules, "adopt-velero-schedules", false,
+		"Create a BackupSchedule resource for existing Velero schedules using the backup operator names "+
+			"and not owned by a BackupSchedule, so that these schedules are adopted by the operator.")
+	flag.BoolVar(&updateBackupsHubID, "update-backups-hub-id", false,
+		"Label the backups created by this hub with an unknown hub id, with the current hub id.")
+	flag.BoolVar(&updateBackupsHubIDDryRun, "update-backups-hub-id-dry-run", false,
+		"Used with update-backups-hub-id, only report the backups to update.")
+	flag.DurationVar(&storageLocationProbeTimeout, "storage-location-probe-timeout", 0,
+		"Timeout for the request sent to the storage location object store before creating "+
+			"backups or restores. The probe is disabled if not set.")
+	flag.StringVar(&validateSchedule, "validate-schedule", "",
+		"Path to a BackupSchedule manifest file to validate. The manifest is validated "+
+			"without connecting to the cluster, the validation errors are printed and the command exits.")
+	flag.BoolVar(&enableWebhooks, "enable-webhooks", false,
+		"Serve the BackupSchedule and Restore validating admission webhooks. The webhook serving certificates "+
+			"must be mounted in the manager pod.")
+	flag.BoolVar(&verifyStorageEncryption, "verify-storage-encryption", false,
+		"Set the BackupSchedule StorageEncryption condition from the server-side encryption config "+
+			"of the storage locations. The backups are created even if encryption is not configured.")
+	flag.BoolVar(&acceptUnownedStorageLocations, "accept-unowned-storage-locations", false,
+		"Use the available storage locations with no owner reference, for example created by GitOps. "+
+			"By default, only the storage locations owned by the resource used to install velero are used.")
+	flag.DurationVar(&backupReconcileInterval, "backup-reconcile-interval", 0,
+		"Interval between the reconciles of the enabled BackupSchedule resources, checking for backup "+
+			"collisions and updating the backups status. Defaults to 30m if not set.")
+	flag.DurationVar(&restoreReconcileInterval, "restore-reconcile-interval", 0,
+		"Interval between the checks for new backups of the Restore resources syncing with new backups, "+
+			"if restoreSyncInterval is not set on the Restore. Defaults to 30m if not set.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -98,17 +153,59 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
+	if backupReconcileInterval < 0 {
+		setupLog.Error(fmt.Errorf("invalid value %s", backupReconcileInterval),
+			"the backup-reconcile-interval argument must not be negative")
+		os.Exit(1)
+	}
+	if restoreReconcileInterval < 0 {
+		setupLog.Error(fmt.Errorf("invalid value %s", restoreReconcileInterval),
+			"the restore-reconcile-interval argument must not be negative")
+		os.Exit(1)
+	}
+
+	if validateSchedule != "" {
+		os.Exit(validateScheduleManifest(context.Background(), validateSchedule))
+	}
+
+	options := ctrl.Options{
 		Scheme:                 scheme,
 		MetricsBindAddress:     metricsAddr,
 		Port:                   9443,
 		HealthProbeBindAddress: probeAddr,
 		LeaderElection:         enableLeaderElection,
 		LeaderElectionID:       "58497677.cluster.management.io",
-	})
+	}
+	if err := leaderElection.setOptions(&options); err != nil {
+		setupLog.Error(err, "invalid leader election arguments")
+		os.Exit(1)
+	}
+
+	ctx := ctrl.SetupSignalHandler()
+
+	// os.Exit doesn't run the deferred functions, so the spans not exported yet
+	// are flushed explicitly before exiting
+	shutdownTracing := func(context.Context) error { return nil }
+	exit := func(code int) {
+		if err := shutdownTracing(context.Background()); err != nil {
+			setupLog.Error(err, "problem shutting down tracing")
+		}
+		os.Exit(code)
+	}
+	if otlpEndpoint != "" {
+		shutdown, err := controllers.SetupTracing(ctx, otlpEndpoint, otlpInsecure)
+		if err != nil {
+			setupLog.Error(err, "unable to set up tracing")
+			os.Exit(1)
+		}
+		shutdownTracing = shutdown
+		setupLog.Info("tracing enabled", "endpoint", otlpEndpoint)
+	}
+
+	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), options)
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
-		os.Exit(1)
+		exit(1)
 	}
 
 	cfg := mgr.GetConfig()
@@ -116,60 +213,211 @@ func main() {
 	dc, err := discovery.NewDiscoveryClientForConfig(cfg)
 	if err != nil {
 		setupLog.Error(err, "unable to set up discovery client")
-		os.Exit(1)
+		exit(1)
 	}
 
 	// prepare the dynamic client
 	dyn, err := dynamic.NewForConfig(cfg)
 	if err != nil {
 		setupLog.Error(err, "unable to set up dynamic client")
-		os.Exit(1)
+		exit(1)
 	}
 	mapper := restmapper.NewDeferredDiscoveryRESTMapper(
 		memory.NewMemCacheClient(dc),
 	)
 
-	if err = (&controllers.BackupScheduleReconciler{
-		Client:          mgr.GetClient(),
-		DiscoveryClient: dc,
-		DynamicClient:   dyn,
-		RESTMapper:      mapper,
-		Scheme:          mgr.GetScheme(),
-	}).SetupWithManager(mgr); err != nil {
-		setupLog.Error(err, "unable to create Schedule controller")
-		os.Exit(1)
-	}
+	drStatus := controllers.NewDRStatus()
+	// the BackupSchedule and Restore controllers watch the velero resources and can't start
+	// without the velero CRDs; until velero is installed, the missing velero install is reported
+	// on these resources and the operator is restarted once velero is installed
+	if controllers.IsVeleroInstalled(dc) {
+		if err = (&controllers.BackupScheduleReconciler{
+			Client:                        mgr.GetClient(),
+			DiscoveryClient:               dc,
+			DynamicClient:                 dyn,
+			RESTMapper:                    mapper,
+			Scheme:                        mgr.GetScheme(),
+			Recorder:                      mgr.GetEventRecorderFor("BackupSchedule controller"),
+			StorageLocationProbeTimeout:   storageLocationProbeTimeout,
+			VerifyStorageEncryption:       verifyStorageEncryption,
+			ReconcileInterval:             backupReconcileInterval,
+			AcceptUnownedStorageLocations: acceptUnownedStorageLocations,
+			DRStatus:                      drStatus,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create Schedule controller")
+			exit(1)
+		}
 
-	kubeClient, err := kubernetes.NewForConfig(cfg)
-	if err != nil {
-		kubeClient = nil
-	}
-	if err = (&controllers.RestoreReconciler{
-		Client:          mgr.GetClient(),
-		KubeClient:      kubeClient,
-		DiscoveryClient: dc,
-		DynamicClient:   dyn,
-		RESTMapper:      mapper,
-		Scheme:          mgr.GetScheme(),
-		Recorder:        mgr.GetEventRecorderFor("Restore controller"),
-	}).SetupWithManager(mgr); err != nil {
-		setupLog.Error(err, "unable to create Restore controller")
-		os.Exit(1)
+		kubeClient, err := kubernetes.NewForConfig(cfg)
+		if err != nil {
+			kubeClient = nil
+		}
+		if err = (&controllers.RestoreReconciler{
+			Client:                        mgr.GetClient(),
+			KubeClient:                    kubeClient,
+			DiscoveryClient:               dc,
+			DynamicClient:                 dyn,
+			RESTMapper:                    mapper,
+			Scheme:                        mgr.GetScheme(),
+			Recorder:                      mgr.GetEventRecorderFor("Restore controller"),
+			StorageLocationProbeTimeout:   storageLocationProbeTimeout,
+			SyncInterval:                  restoreReconcileInterval,
+			AcceptUnownedStorageLocations: acceptUnownedStorageLocations,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create Restore controller")
+			exit(1)
+		}
+	} else {
+		setupLog.Info("velero is not installed, the controllers start once velero is installed")
+		if err = (&controllers.VeleroInstallReconciler{
+			Client:          mgr.GetClient(),
+			DiscoveryClient: dc,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create VeleroInstall controller")
+			exit(1)
+		}
+	}
+	if enableWebhooks {
+		if err = (&controllers.BackupScheduleValidator{
+			Client: mgr.GetAPIReader(),
+		}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create BackupSchedule webhook")
+			exit(1)
+		}
+		if err = (&controllers.RestoreValidator{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create Restore webhook")
+			exit(1)
+		}
 	}
 	//+kubebuilder:scaffold:builder
 
+	if adoptVeleroSchedules {
+		if err := mgr.Add(manager.RunnableFunc(func(ctx context.Context) error {
+			if err := controllers.CreateBackupSchedulesForVeleroSchedules(ctx, mgr.GetClient()); err != nil {
+				setupLog.Error(err, "unable to create BackupSchedule for existing Velero schedules")
+			}
+			return nil
+		})); err != nil {
+			setupLog.Error(err, "unable to set up Velero schedules adoption")
+			exit(1)
+		}
+	}
+
+	if updateBackupsHubID {
+		if err := mgr.Add(manager.RunnableFunc(func(ctx context.Context) error {
+			updated, err := controllers.UpdateBackupsHubIdentification(ctx, mgr.GetClient(),
+				dyn, mapper, updateBackupsHubIDDryRun)
+			if err != nil {
+				setupLog.Error(err, "unable to update the hub id for backups")
+			}
+			setupLog.Info("updated the hub id for backups",
+				"count", updated, "dryRun", updateBackupsHubIDDryRun)
+			return nil
+		})); err != nil {
+			setupLog.Error(err, "unable to set up the backups hub id update")
+			exit(1)
+		}
+	}
+
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
 		setupLog.Error(err, "unable to set up health check")
-		os.Exit(1)
+		exit(1)
 	}
 	if err := mgr.AddReadyzCheck("readyz", healthz.Ping); err != nil {
 		setupLog.Error(err, "unable to set up ready check")
-		os.Exit(1)
+		exit(1)
+	}
+	// the disaster recovery posture is served by the metrics endpoint,
+	// the health probe endpoint doesn't serve other handlers
+	if err := mgr.AddMetricsExtraHandler(controllers.DRStatusPath, drStatus); err != nil {
+		setupLog.Error(err, "unable to set up the DR status endpoint")
+		exit(1)
 	}
 
 	setupLog.Info("starting manager")
-	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
+	if err := mgr.Start(ctx); err != nil {
+		if errors.Is(err, controllers.ErrVeleroInstalled) {
+			setupLog.Info("velero is installed, restarting to start the controllers")
+			exit(1)
+		}
 		setupLog.Error(err, "problem running manager")
-		os.Exit(1)
+		exit(1)
+	}
+	exit(0)
+}
+
+// leader election lease settings, set from the command line arguments
+type leaderElectionArgs struct {
+	leaseDuration time.Duration
+	renewDeadline time.Duration
+	retryPeriod   time.Duration
+}
+
+// registers the leader election lease arguments, the defaults are the controller-runtime defaults
+func (a *leaderElectionArgs) bindFlags(fs *flag.FlagSet) {
+
+	fs.DurationVar(&a.leaseDuration, "leader-elect-lease-duration", 15*time.Second,
+		"Duration the non-leader candidates wait before forcing to acquire the leadership.")
+	fs.DurationVar(&a.renewDeadline, "leader-elect-renew-deadline", 10*time.Second,
+		"Duration the leader retries refreshing the leadership before giving it up. "+
+			"Must be less than leader-elect-lease-duration.")
+	fs.DurationVar(&a.retryPeriod, "leader-elect-retry-period", 2*time.Second,
+		"Duration the candidates wait between tries of acquiring or renewing the leadership.")
+}
+
+// sets the leader election lease settings on the manager options;
+// returns an error if the renew deadline is not less than the lease duration
+// or the retry period is too long for the renew deadline
+func (a *leaderElectionArgs) setOptions(options *ctrl.Options) error {
+
+	if a.leaseDuration <= 0 || a.renewDeadline <= 0 || a.retryPeriod <= 0 {
+		return fmt.Errorf("the lease duration %s, renew deadline %s and retry period %s must be positive",
+			a.leaseDuration, a.renewDeadline, a.retryPeriod)
+	}
+	if a.renewDeadline >= a.leaseDuration {
+		return fmt.Errorf("the renew deadline %s must be less than the lease duration %s",
+			a.renewDeadline, a.leaseDuration)
+	}
+	// the leader election retries are jittered by up to 1.2 times the retry period
+	if time.Duration(leaderelection.JitterFactor*float64(a.retryPeriod)) >= a.renewDeadline {
+		return fmt.Errorf("the renew deadline %s must be greater than %v times the retry period %s",
+			a.renewDeadline, leaderelection.JitterFactor, a.retryPeriod)
+	}
+	leaseDuration, renewDeadline, retryPeriod := a.leaseDuration, a.renewDeadline, a.retryPeriod
+	options.LeaseDuration = &leaseDuration
+	options.RenewDeadline = &renewDeadline
+	options.RetryPeriod = &retryPeriod
+	return nil
+}
+
+// validates the BackupSchedule manifest file and prints the validation errors and warnings
+// returns the command exit code, 1 if the manifest is not valid
+func validateScheduleManifest(ctx context.Context, path string) int {
+
+	file, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		fmt.Printf("error: unable to open %s: %v\n", path, err)
+		return 1
+	}
+	defer file.Close()
+
+	backupSchedule := &backupv1beta1.BackupSchedule{}
+	if err := yaml.NewYAMLOrJSONDecoder(file, 4096).Decode(backupSchedule); err != nil {
+		fmt.Printf("error: unable to parse %s: %v\n", path, err)
+		return 1
+	}
+
+	validationErrors, warnings := controllers.ValidateBackupSchedule(ctx, backupSchedule)
+	for _, msg := range warnings {
+		fmt.Printf("warning: %s\n", msg)
+	}
+	for _, msg := range validationErrors {
+		fmt.Printf("error: %s\n", msg)
+	}
+	if len(validationErrors) > 0 {
+		fmt.Printf("BackupSchedule %s is not valid\n", backupSchedule.Name)
+		return 1
 	}
+	fmt.Printf("BackupSchedule %s is valid\n", backupSchedule.Name)
+	return 0
 }
@@ -0,0 +1,112 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+	"testing"
+	"time"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func Test_leaderElectionArgs_setOptions(t *testing.T) {
+
+	tests := []struct {
+		name              string
+		args              []string
+		wantErr           bool
+		wantLeaseDuration time.Duration
+		wantRenewDeadline time.Duration
+		wantRetryPeriod   time.Duration
+	}{
+		{
+			name:              "defaults",
+			args:              []string{},
+			wantLeaseDuration: 15 * time.Second,
+			wantRenewDeadline: 10 * time.Second,
+			wantRetryPeriod:   2 * time.Second,
+		},
+		{
+			name: "lease settings set",
+			args: []string{
+				"--leader-elect-lease-duration=60s",
+				"--leader-elect-renew-deadline=40s",
+				"--leader-elect-retry-period=5s",
+			},
+			wantLeaseDuration: 60 * time.Second,
+			wantRenewDeadline: 40 * time.Second,
+			wantRetryPeriod:   5 * time.Second,
+		},
+		{
+			name: "renew deadline equal to the lease duration",
+			args: []string{
+				"--leader-elect-lease-duration=30s",
+				"--leader-elect-renew-deadline=30s",
+			},
+			wantErr: true,
+		},
+		{
+			name: "retry period too long for the renew deadline",
+			args: []string{
+				"--leader-elect-renew-deadline=10s",
+				"--leader-elect-retry-period=9s",
+			},
+			wantErr: true,
+		},
+		{
+			name:    "negative retry period",
+			args:    []string{"--leader-elect-retry-period=-1s"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := flag.NewFlagSet(tt.name, flag.ContinueOnError)
+			leaderElection := leaderElectionArgs{}
+			leaderElection.bindFlags(fs)
+			if err := fs.Parse(tt.args); err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+
+			options := ctrl.Options{LeaderElection: true}
+			err := leaderElection.setOptions(&options)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("setOptions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if options.LeaseDuration != nil || options.RenewDeadline != nil ||
+					options.RetryPeriod != nil {
+					t.Errorf("setOptions() set the options for invalid arguments")
+				}
+				return
+			}
+			if options.LeaseDuration == nil || *options.LeaseDuration != tt.wantLeaseDuration {
+				t.Errorf("setOptions() LeaseDuration = %v, want %v",
+					options.LeaseDuration, tt.wantLeaseDuration)
+			}
+			if options.RenewDeadline == nil || *options.RenewDeadline != tt.wantRenewDeadline {
+				t.Errorf("setOptions() RenewDeadline = %v, want %v",
+					options.RenewDeadline, tt.wantRenewDeadline)
+			}
+			if options.RetryPeriod == nil || *options.RetryPeriod != tt.wantRetryPeriod {
+				t.Errorf("setOptions() RetryPeriod = %v, want %v",
+					options.RetryPeriod, tt.wantRetryPeriod)
+			}
+		})
+	}
+}